@@ -0,0 +1,175 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"nexus/internal/audit"
+)
+
+// maxAuditedBodySize bounds the request bodies copied into audit entries
+const maxAuditedBodySize = 1024
+
+// defaultAuditLimit is the number of entries returned by audit queries without limit
+const defaultAuditLimit = 100
+
+// SetAuditLog sets the log recording admin API changes, which is also queried
+// by the audit endpoint
+func (a *Admin) SetAuditLog(log *audit.Log) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.audit = log
+}
+
+// statusRecorder records the status of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// serveAudited serves a request that may change the runtime state and records
+// it with digests of the routes and drains before and after, rejected
+// requests included
+func (a *Admin) serveAudited(w http.ResponseWriter, r *http.Request, log *audit.Log) {
+	entry := audit.Entry{
+		Actor:     "anonymous",
+		Remote:    r.RemoteAddr,
+		Action:    r.Method + " " + r.URL.Path,
+		OldDigest: a.stateDigest(),
+	}
+
+	// Route configs may carry secrets, they are only part of the digests
+	if r.Body != nil && r.URL.Path != "/admin/routes" {
+		head, err := io.ReadAll(io.LimitReader(r.Body, maxAuditedBodySize+1))
+		if err == nil && len(head) <= maxAuditedBodySize {
+			entry.Request = string(head)
+		}
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(head), r.Body), r.Body}
+	}
+
+	rec := &statusRecorder{ResponseWriter: w}
+	if p, ok := a.authorize(rec, r); ok {
+		if p.Name != "" {
+			entry.Actor = p.Name
+		}
+		a.mux.ServeHTTP(rec, r)
+	} else if p.Name != "" {
+		entry.Actor = p.Name
+	}
+
+	entry.Status = rec.status
+	if entry.Status == 0 {
+		entry.Status = http.StatusOK
+	}
+	entry.NewDigest = a.stateDigest()
+	log.Record(entry)
+}
+
+// stateDigest returns the digest of the routes, drains, runtime faults,
+// paused services and registrations the admin API changes
+func (a *Admin) stateDigest() string {
+	drains := make(map[string]map[string][]string)
+	for name, svc := range a.router.Services() {
+		if drained := svc.Drained(); len(drained) > 0 {
+			drains[name] = drained
+		}
+	}
+	state := map[string]interface{}{
+		"routes": a.router.ListRoutes(),
+		"drains": drains,
+	}
+	a.mu.RLock()
+	faults, intake, registrar := a.faults, a.intake, a.registrar
+	a.mu.RUnlock()
+	if faults != nil {
+		state["faults"] = faults.Overrides()
+	}
+	if intake != nil {
+		paused := make([]string, 0)
+		for _, queue := range intake.Queues() {
+			if queue.Paused {
+				paused = append(paused, queue.Service)
+			}
+		}
+		state["paused"] = paused
+	}
+	if registrar != nil {
+		// Renewals only move the expiry, they leave the digest unchanged
+		registered := make([]string, 0)
+		for _, registration := range registrar.Registrations() {
+			registered = append(registered, registration.Service+" "+registration.Address)
+		}
+		state["registrations"] = registered
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return ""
+	}
+	return audit.Digest(data)
+}
+
+// handleAudit queries the audit log by actor, action and time, returning the
+// latest entries
+func (a *Admin) handleAudit(w http.ResponseWriter, r *http.Request) {
+	a.mu.RLock()
+	log := a.audit
+	a.mu.RUnlock()
+
+	if log == nil {
+		writeError(w, http.StatusNotFound, errors.New("audit log is not enabled"))
+		return
+	}
+
+	q := audit.Query{
+		Actor:  r.URL.Query().Get("actor"),
+		Action: r.URL.Query().Get("action"),
+		Limit:  defaultAuditLimit,
+	}
+	if since := r.URL.Query().Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid since: %w", err))
+			return
+		}
+		q.Since = t
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit: %s", limit))
+			return
+		}
+		q.Limit = n
+	}
+
+	entries, err := log.Query(q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nexus/internal/audit"
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmin_Audit(t *testing.T) {
+	log, err := audit.Open(filepath.Join(t.TempDir(), "audit.log"))
+	require.NoError(t, err)
+	defer log.Close()
+
+	admin := NewAdmin(newTestRouter())
+	admin.SetAuditLog(log)
+	admin.SetAuth(&config.AdminAuthConfig{
+		Tokens: []config.AdminTokenConfig{
+			{Name: "dashboard", Token: "read-token"},
+			{Name: "deploy", Token: "write-token", Role: config.AdminRoleWrite},
+		},
+	})
+	do := func(method, path, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, req)
+		return rec
+	}
+
+	drain := `{"service": "api", "address": "http://backend1"}`
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/admin/backends/drain", drain, "write-token").Code)
+	require.Equal(t, http.StatusForbidden, do(http.MethodPost, "/admin/backends/undrain", drain, "read-token").Code)
+	route := `{"name": "secret-route", "match": {"path": "/new"}, "service": "api"}`
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/admin/routes", route, "write-token").Code)
+	// Reads are not audited
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/admin/backends", "", "read-token").Code)
+
+	rec := do(http.MethodGet, "/admin/audit", "", "read-token")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var entries []audit.Entry
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &entries))
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, "deploy", entries[0].Actor)
+	assert.Equal(t, "POST /admin/backends/drain", entries[0].Action)
+	assert.Equal(t, http.StatusOK, entries[0].Status)
+	assert.Equal(t, drain, entries[0].Request)
+	assert.NotEqual(t, entries[0].OldDigest, entries[0].NewDigest)
+
+	// Rejected changes are recorded and leave the state unchanged
+	assert.Equal(t, "dashboard", entries[1].Actor)
+	assert.Equal(t, http.StatusForbidden, entries[1].Status)
+	assert.Equal(t, entries[1].OldDigest, entries[1].NewDigest)
+
+	// Route configs are only digested
+	assert.Equal(t, http.StatusCreated, entries[2].Status)
+	assert.Empty(t, entries[2].Request)
+	assert.NotEqual(t, entries[2].OldDigest, entries[2].NewDigest)
+
+	rec = do(http.MethodGet, "/admin/audit?actor=deploy&limit=1", "", "read-token")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &entries))
+	require.Len(t, entries, 1)
+	assert.Equal(t, "POST /admin/routes", entries[0].Action)
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/admin/audit?since=yesterday", "", "read-token").Code)
+
+	admin.SetAuditLog(nil)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/admin/audit", "", "read-token").Code)
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+)
+
+// principal is the identity and role a request authenticated with
+type principal struct {
+	Name string
+	Role string
+}
+
+// SetAuth sets the credentials accepted by the admin API, nil accepts every request
+func (a *Admin) SetAuth(auth *config.AdminAuthConfig) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.auth = auth
+}
+
+// authorize authenticates a request and checks its role allows the endpoint,
+// rejected requests are answered
+func (a *Admin) authorize(w http.ResponseWriter, r *http.Request) (principal, bool) {
+	a.mu.RLock()
+	auth := a.auth
+	a.mu.RUnlock()
+
+	if auth == nil {
+		return principal{}, true
+	}
+
+	p, ok := authenticate(auth, r)
+	if !ok {
+		lg.GetInstance().Warn("Rejected unauthenticated admin request %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+		w.Header().Add("WWW-Authenticate", `Bearer realm="nexus"`)
+		w.Header().Add("WWW-Authenticate", `Basic realm="nexus"`)
+		writeError(w, http.StatusUnauthorized, errors.New("authentication required"))
+		return p, false
+	}
+	if p.Role != config.AdminRoleWrite && !readOnly(r) {
+		lg.GetInstance().Warn("Rejected admin request %s %s of read-only %s", r.Method, r.URL.Path, p.Name)
+		writeError(w, http.StatusForbidden, fmt.Errorf("%s is not allowed to change the configuration", p.Name))
+		return p, false
+	}
+
+	return p, true
+}
+
+// readOnly reports whether a request can't change anything. Dry-runs only
+// evaluate the config they are given
+func readOnly(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead || r.URL.Path == "/admin/config/dry-run"
+}
+
+// authenticate returns the principal of the credentials of a request, the
+// highest role wins when several match
+func authenticate(auth *config.AdminAuthConfig, r *http.Request) (principal, bool) {
+	var matched []principal
+
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		for i, t := range auth.Tokens {
+			if credentialEqual(t.Token, token) {
+				name := t.Name
+				if name == "" {
+					name = fmt.Sprintf("token %d", i)
+				}
+				matched = append(matched, principal{Name: name, Role: t.Role})
+			}
+		}
+	}
+	if username, password, ok := r.BasicAuth(); ok {
+		for _, u := range auth.Users {
+			if u.Username == username && credentialEqual(u.Password, password) {
+				matched = append(matched, principal{Name: "user " + u.Username, Role: u.Role})
+			}
+		}
+	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		for _, c := range auth.ClientCerts {
+			if c.CommonName == cn {
+				matched = append(matched, principal{Name: "certificate " + cn, Role: c.Role})
+			}
+		}
+	}
+
+	if len(matched) == 0 {
+		return principal{}, false
+	}
+	best := matched[0]
+	for _, p := range matched[1:] {
+		if p.Role == config.AdminRoleWrite {
+			best = p
+		}
+	}
+	if best.Role == "" {
+		best.Role = config.AdminRoleRead
+	}
+	return best, true
+}
+
+// credentialEqual compares a presented credential with a configured one in
+// constant time, configured credentials may be references
+func credentialEqual(configured, presented string) bool {
+	expected, err := config.ResolveCredential(configured)
+	if err != nil {
+		lg.GetInstance().Error("Failed to resolve admin credential: %v", err)
+		return false
+	}
+	return expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(presented)) == 1
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdmin_Auth(t *testing.T) {
+	t.Setenv("NEXUS_ADMIN_TOKEN", "write-token")
+
+	admin := NewAdmin(newTestRouter())
+	admin.SetAuth(&config.AdminAuthConfig{
+		Tokens: []config.AdminTokenConfig{
+			{Name: "dashboard", Token: "read-token"},
+			{Name: "deploy", Token: "env://NEXUS_ADMIN_TOKEN", Role: config.AdminRoleWrite},
+		},
+		Users:       []config.AdminUserConfig{{Username: "ops", Password: "secret", Role: config.AdminRoleWrite}},
+		ClientCerts: []config.AdminClientCertConfig{{CommonName: "monitoring", Role: config.AdminRoleRead}},
+	})
+
+	drain := `{"service": "api", "address": "http://backend1"}`
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		setup    func(req *http.Request)
+		expected int
+	}{
+		{name: "NoCredentials", method: http.MethodGet, path: "/admin/backends", expected: http.StatusUnauthorized},
+		{
+			name: "UnknownToken", method: http.MethodGet, path: "/admin/backends",
+			setup:    func(req *http.Request) { req.Header.Set("Authorization", "Bearer wrong") },
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name: "ReadTokenReads", method: http.MethodGet, path: "/admin/backends",
+			setup:    func(req *http.Request) { req.Header.Set("Authorization", "Bearer read-token") },
+			expected: http.StatusOK,
+		},
+		{
+			name: "ReadTokenCannotDrain", method: http.MethodPost, path: "/admin/backends/drain", body: drain,
+			setup:    func(req *http.Request) { req.Header.Set("Authorization", "Bearer read-token") },
+			expected: http.StatusForbidden,
+		},
+		{
+			name: "ReadTokenDryRun", method: http.MethodPost, path: "/admin/config/dry-run", body: "routes: []",
+			setup:    func(req *http.Request) { req.Header.Set("Authorization", "Bearer read-token") },
+			expected: http.StatusNotFound, // Passed authorization, sampling is off
+		},
+		{
+			name: "WriteTokenReference", method: http.MethodPost, path: "/admin/backends/drain", body: drain,
+			setup:    func(req *http.Request) { req.Header.Set("Authorization", "Bearer write-token") },
+			expected: http.StatusOK,
+		},
+		{
+			name: "BasicAuth", method: http.MethodPost, path: "/admin/backends/undrain", body: drain,
+			setup:    func(req *http.Request) { req.SetBasicAuth("ops", "secret") },
+			expected: http.StatusOK,
+		},
+		{
+			name: "BasicAuthWrongPassword", method: http.MethodGet, path: "/admin/backends",
+			setup:    func(req *http.Request) { req.SetBasicAuth("ops", "guess") },
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name: "ClientCertificate", method: http.MethodGet, path: "/admin/backends",
+			setup:    func(req *http.Request) { req.TLS = verifiedCert("monitoring") },
+			expected: http.StatusOK,
+		},
+		{
+			name: "ClientCertificateCannotDrain", method: http.MethodPost, path: "/admin/backends/drain", body: drain,
+			setup:    func(req *http.Request) { req.TLS = verifiedCert("monitoring") },
+			expected: http.StatusForbidden,
+		},
+		{
+			name: "UnknownClientCertificate", method: http.MethodGet, path: "/admin/backends",
+			setup:    func(req *http.Request) { req.TLS = verifiedCert("intruder") },
+			expected: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.setup != nil {
+				tt.setup(req)
+			}
+			rec := httptest.NewRecorder()
+			admin.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expected, rec.Code, rec.Body.String())
+			if tt.expected == http.StatusUnauthorized {
+				assert.Len(t, rec.Header().Values("WWW-Authenticate"), 2)
+			}
+		})
+	}
+
+	// Without auth the API stays open
+	admin.SetAuth(nil)
+	assert.Equal(t, http.StatusOK, doRequest(t, admin, http.MethodGet, "/admin/backends", "").Code)
+}
+
+// verifiedCert returns the connection state of a client certificate verified by the listener
+func verifiedCert(commonName string) *tls.ConnectionState {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+	return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"nexus/internal/config"
+	"nexus/internal/fault"
+)
+
+// Fault describes the fault injected into a route, runtime faults replace
+// configured ones
+type Fault struct {
+	Route    string              `json:"route"`
+	Source   string              `json:"source"` // config or runtime
+	Fault    *config.FaultConfig `json:"fault"`
+	Replaced *config.FaultConfig `json:"replaced,omitempty"` // Configured fault replaced by a runtime one
+}
+
+// SetFaultInjector enables setting route faults through the admin API
+func (a *Admin) SetFaultInjector(injector *fault.Injector) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.faults = injector
+}
+
+// faultInjector returns the fault injector, answering 404 when there is none
+func (a *Admin) faultInjector(w http.ResponseWriter) *fault.Injector {
+	a.mu.RLock()
+	injector := a.faults
+	a.mu.RUnlock()
+
+	if injector == nil {
+		writeError(w, http.StatusNotFound, errors.New("fault injection is not enabled"))
+	}
+	return injector
+}
+
+// handleFaults lists the routes with a configured or runtime fault
+func (a *Admin) handleFaults(w http.ResponseWriter, r *http.Request) {
+	injector := a.faultInjector(w)
+	if injector == nil {
+		return
+	}
+
+	overrides := injector.Overrides()
+	faults := make([]Fault, 0)
+	for _, routeConfig := range a.router.ListRoutes() {
+		if override, ok := overrides[routeConfig.Name]; ok {
+			faults = append(faults, Fault{Route: routeConfig.Name, Source: "runtime", Fault: override, Replaced: routeConfig.Fault})
+		} else if routeConfig.Fault != nil {
+			faults = append(faults, Fault{Route: routeConfig.Name, Source: "config", Fault: routeConfig.Fault})
+		}
+	}
+	writeJSON(w, http.StatusOK, faults)
+}
+
+// handleSetFault sets the runtime fault of a route, it lasts until it is
+// removed and survives config reloads
+func (a *Admin) handleSetFault(w http.ResponseWriter, r *http.Request) {
+	injector := a.faultInjector(w)
+	if injector == nil {
+		return
+	}
+
+	name := r.PathValue("route")
+	if !a.hasRoute(name) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("route not found: %s", name))
+		return
+	}
+	var faultConfig config.FaultConfig
+	if err := json.NewDecoder(r.Body).Decode(&faultConfig); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if err := config.ValidateFault(&faultConfig); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	injector.Set(name, &faultConfig)
+	writeJSON(w, http.StatusOK, Fault{Route: name, Source: "runtime", Fault: &faultConfig})
+}
+
+// handleClearFault removes the runtime fault of a route, its configured fault
+// applies again
+func (a *Admin) handleClearFault(w http.ResponseWriter, r *http.Request) {
+	injector := a.faultInjector(w)
+	if injector == nil {
+		return
+	}
+
+	name := r.PathValue("route")
+	if !injector.Clear(name) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("route %s has no runtime fault", name))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Admin) hasRoute(name string) bool {
+	for _, routeConfig := range a.router.ListRoutes() {
+		if routeConfig.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/fault"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmin_Faults(t *testing.T) {
+	router := newTestRouter()
+	require.NoError(t, router.AddRoute(&config.RouteConfig{Name: "api", Match: config.RouteMatch{Path: "/api"}, Service: "api"}))
+	admin := NewAdmin(router)
+	assert.Equal(t, http.StatusNotFound, doRequest(t, admin, http.MethodGet, "/admin/faults", "").Code)
+
+	injector := fault.NewInjector()
+	admin.SetFaultInjector(injector)
+
+	w := doRequest(t, admin, http.MethodPut, "/admin/faults/unknown", `{"abort": {"status": 503}}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = doRequest(t, admin, http.MethodPut, "/admin/faults/api", `{"abort": {"status": 42}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid fault abort status: 42")
+
+	w = doRequest(t, admin, http.MethodPut, "/admin/faults/api", `{"abort": {"status": 503, "percentage": 10}}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	override := injector.Overrides()["api"]
+	require.NotNil(t, override)
+	assert.Equal(t, 503, override.Abort.Status)
+	assert.Equal(t, 10.0, override.Abort.Percentage)
+
+	w = doRequest(t, admin, http.MethodGet, "/admin/faults", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var faults []Fault
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &faults))
+	require.Len(t, faults, 1)
+	assert.Equal(t, "api", faults[0].Route)
+	assert.Equal(t, "runtime", faults[0].Source)
+
+	assert.Equal(t, http.StatusNoContent, doRequest(t, admin, http.MethodDelete, "/admin/faults/api", "").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(t, admin, http.MethodDelete, "/admin/faults/api", "").Code)
+	assert.Empty(t, injector.Overrides())
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Leader reports the leadership of this replica in the fleet, implemented by
+// the leader election
+type Leader interface {
+	ID() string
+	IsLeader() bool
+}
+
+// leaderStatus is the leadership reported by the admin API
+type leaderStatus struct {
+	Replica string `json:"replica"`
+	Leader  bool   `json:"leader"`
+}
+
+// SetLeader enables the leadership endpoint
+func (a *Admin) SetLeader(leader Leader) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.leader = leader
+}
+
+// handleLeader reports whether this replica leads the fleet
+func (a *Admin) handleLeader(w http.ResponseWriter, r *http.Request) {
+	a.mu.RLock()
+	leader := a.leader
+	a.mu.RUnlock()
+
+	if leader == nil {
+		writeError(w, http.StatusNotFound, errors.New("leader election is not enabled"))
+		return
+	}
+	writeJSON(w, http.StatusOK, leaderStatus{Replica: leader.ID(), Leader: leader.IsLeader()})
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeLeader bool
+
+func (l fakeLeader) ID() string     { return "replica1" }
+func (l fakeLeader) IsLeader() bool { return bool(l) }
+
+func TestAdmin_Leader(t *testing.T) {
+	admin := NewAdmin(route.NewRouter(nil, nil))
+	assert.Equal(t, http.StatusNotFound, doRequest(t, admin, http.MethodGet, "/admin/leader", "").Code)
+
+	admin.SetLeader(fakeLeader(true))
+	w := doRequest(t, admin, http.MethodGet, "/admin/leader", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"replica": "replica1", "leader": true}`, w.Body.String())
+}
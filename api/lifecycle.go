@@ -0,0 +1,41 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Lifecycle controls the process through the admin API, replacing signals
+// where they are not available, e.g. Windows services and some containers
+type Lifecycle interface {
+	// Reload applies the config file, whether it changed or not
+	Reload()
+	// ReopenLogs reopens the log files after rotation
+	ReopenLogs()
+	// Shutdown stops the servers gracefully, it returns before they stopped
+	Shutdown()
+}
+
+// SetLifecycle enables the reload, log reopen and shutdown endpoints
+func (a *Admin) SetLifecycle(lifecycle Lifecycle) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.lifecycle = lifecycle
+}
+
+// handleLifecycle calls fn on the lifecycle, answering with status
+func (a *Admin) handleLifecycle(status int, fn func(Lifecycle)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a.mu.RLock()
+		lifecycle := a.lifecycle
+		a.mu.RUnlock()
+
+		if lifecycle == nil {
+			writeError(w, http.StatusNotFound, errors.New("lifecycle control is not enabled, run with -runtime=service"))
+			return
+		}
+		fn(lifecycle)
+		w.WriteHeader(status)
+	}
+}
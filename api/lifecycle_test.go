@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testLifecycle struct {
+	reloads, reopens, shutdowns int
+}
+
+func (l *testLifecycle) Reload()     { l.reloads++ }
+func (l *testLifecycle) ReopenLogs() { l.reopens++ }
+func (l *testLifecycle) Shutdown()   { l.shutdowns++ }
+
+func TestAdmin_Lifecycle(t *testing.T) {
+	admin := NewAdmin(newTestRouter())
+	assert.Equal(t, http.StatusNotFound, doRequest(t, admin, http.MethodPost, "/admin/shutdown", "").Code)
+
+	lifecycle := &testLifecycle{}
+	admin.SetLifecycle(lifecycle)
+	assert.Equal(t, http.StatusOK, doRequest(t, admin, http.MethodPost, "/admin/config/reload", "").Code)
+	assert.Equal(t, http.StatusOK, doRequest(t, admin, http.MethodPost, "/admin/logs/reopen", "").Code)
+	assert.Equal(t, http.StatusAccepted, doRequest(t, admin, http.MethodPost, "/admin/shutdown", "").Code)
+	assert.Equal(t, &testLifecycle{reloads: 1, reopens: 1, shutdowns: 1}, lifecycle)
+}
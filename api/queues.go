@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"nexus/internal/proxy"
+)
+
+// Intake reports the request queues of the services and pauses their intake,
+// implemented by the proxy
+type Intake interface {
+	Queues() []proxy.ServiceQueue
+	PauseIntake(service string, hold time.Duration) error
+	ResumeIntake(service string) error
+}
+
+// intakeRequest is the body of pause and resume requests, hold_timeout is a
+// duration like 45s
+type intakeRequest struct {
+	Service     string `json:"service"`
+	HoldTimeout string `json:"hold_timeout"`
+}
+
+// SetIntake enables the queue endpoints
+func (a *Admin) SetIntake(intake Intake) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.intake = intake
+}
+
+// getIntake returns the intake, answering 404 when there is none
+func (a *Admin) getIntake(w http.ResponseWriter) Intake {
+	a.mu.RLock()
+	intake := a.intake
+	a.mu.RUnlock()
+
+	if intake == nil {
+		writeError(w, http.StatusNotFound, errors.New("queue control is not enabled"))
+	}
+	return intake
+}
+
+// handleQueues lists the in-flight, queued and held requests per service
+func (a *Admin) handleQueues(w http.ResponseWriter, r *http.Request) {
+	intake := a.getIntake(w)
+	if intake == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, intake.Queues())
+}
+
+// handleIntake pauses or resumes the intake of a service, answering with its
+// queue so callers can poll until its in-flight requests completed
+func (a *Admin) handleIntake(pause bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		intake := a.getIntake(w)
+		if intake == nil {
+			return
+		}
+
+		var req intakeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+		if req.Service == "" {
+			writeError(w, http.StatusBadRequest, errors.New("service is required"))
+			return
+		}
+
+		var err error
+		if pause {
+			var hold time.Duration
+			if req.HoldTimeout != "" {
+				if hold, err = time.ParseDuration(req.HoldTimeout); err != nil || hold <= 0 {
+					writeError(w, http.StatusBadRequest, fmt.Errorf("invalid hold_timeout: %s", req.HoldTimeout))
+					return
+				}
+			}
+			err = intake.PauseIntake(req.Service, hold)
+		} else if req.HoldTimeout != "" {
+			writeError(w, http.StatusBadRequest, errors.New("hold_timeout only applies to pauses"))
+			return
+		} else {
+			err = intake.ResumeIntake(req.Service)
+		}
+		if errors.Is(err, proxy.ErrServiceNotFound) {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		for _, queue := range intake.Queues() {
+			if queue.Service == req.Service {
+				writeJSON(w, http.StatusOK, queue)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
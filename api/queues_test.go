@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"nexus/internal/proxy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testIntake struct {
+	paused map[string]time.Duration
+}
+
+func (i *testIntake) Queues() []proxy.ServiceQueue {
+	_, paused := i.paused["api"]
+	return []proxy.ServiceQueue{{Service: "api", InFlight: 2, Paused: paused}}
+}
+
+func (i *testIntake) PauseIntake(service string, hold time.Duration) error {
+	if service != "api" {
+		return fmt.Errorf("%w: %s", proxy.ErrServiceNotFound, service)
+	}
+	i.paused[service] = hold
+	return nil
+}
+
+func (i *testIntake) ResumeIntake(service string) error {
+	delete(i.paused, service)
+	return nil
+}
+
+func TestAdmin_Queues(t *testing.T) {
+	admin := NewAdmin(newTestRouter())
+	assert.Equal(t, http.StatusNotFound, doRequest(t, admin, http.MethodGet, "/admin/queues", "").Code)
+
+	intake := &testIntake{paused: make(map[string]time.Duration)}
+	admin.SetIntake(intake)
+
+	w := doRequest(t, admin, http.MethodPost, "/admin/queues/pause", `{"service": "api", "hold_timeout": "45s"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var queue proxy.ServiceQueue
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &queue))
+	assert.True(t, queue.Paused)
+	assert.Equal(t, 2, queue.InFlight)
+	assert.Equal(t, 45*time.Second, intake.paused["api"])
+
+	assert.Equal(t, http.StatusNotFound, doRequest(t, admin, http.MethodPost, "/admin/queues/pause", `{"service": "web"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, doRequest(t, admin, http.MethodPost, "/admin/queues/pause", `{"service": "api", "hold_timeout": "soon"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, doRequest(t, admin, http.MethodPost, "/admin/queues/resume", `{}`).Code)
+
+	w = doRequest(t, admin, http.MethodGet, "/admin/queues", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var queues []proxy.ServiceQueue
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &queues))
+	require.Len(t, queues, 1)
+	assert.True(t, queues[0].Paused)
+
+	require.Equal(t, http.StatusOK, doRequest(t, admin, http.MethodPost, "/admin/queues/resume", `{"service": "api"}`).Code)
+	assert.Empty(t, intake.paused)
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"nexus/internal/discovery"
+)
+
+// Registrar registers backends with their services, implemented by the
+// service discovery
+type Registrar interface {
+	Register(service string, instance discovery.Instance, ttl time.Duration) (discovery.Registration, error)
+	Deregister(service, address string) error
+	Registrations() []discovery.Registration
+}
+
+// registerRequest is the body of registration and deregistration requests,
+// ttl is a duration like 30s
+type registerRequest struct {
+	Service string            `json:"service"`
+	Address string            `json:"address"`
+	Weight  int               `json:"weight"`
+	Labels  map[string]string `json:"labels"`
+	TTL     string            `json:"ttl"`
+}
+
+// SetRegistrar enables the registration endpoints
+func (a *Admin) SetRegistrar(registrar Registrar) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.registrar = registrar
+}
+
+// getRegistrar returns the registrar, answering 404 when there is none
+func (a *Admin) getRegistrar(w http.ResponseWriter) Registrar {
+	a.mu.RLock()
+	registrar := a.registrar
+	a.mu.RUnlock()
+
+	if registrar == nil {
+		writeError(w, http.StatusNotFound, errors.New("registration is not enabled"))
+	}
+	return registrar
+}
+
+// handleRegistrations lists the registered backends with their expiry
+func (a *Admin) handleRegistrations(w http.ResponseWriter, r *http.Request) {
+	registrar := a.getRegistrar(w)
+	if registrar == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, registrar.Registrations())
+}
+
+// handleRegister registers a backend, or renews its registration when it is
+// already registered, so backends heartbeat by registering again
+func (a *Admin) handleRegister(w http.ResponseWriter, r *http.Request) {
+	registrar := a.getRegistrar(w)
+	if registrar == nil {
+		return
+	}
+
+	req, ok := decodeRegisterRequest(w, r)
+	if !ok {
+		return
+	}
+	var ttl time.Duration
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid ttl: %s", req.TTL))
+			return
+		}
+	}
+
+	registration, err := registrar.Register(req.Service, discovery.Instance{Address: req.Address, Weight: req.Weight, Labels: req.Labels}, ttl)
+	if errors.Is(err, discovery.ErrServiceNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, registration)
+}
+
+// handleDeregister removes a registered backend before its TTL elapses
+func (a *Admin) handleDeregister(w http.ResponseWriter, r *http.Request) {
+	registrar := a.getRegistrar(w)
+	if registrar == nil {
+		return
+	}
+
+	req, ok := decodeRegisterRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := registrar.Deregister(req.Service, req.Address); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeRegisterRequest(w http.ResponseWriter, r *http.Request) (registerRequest, bool) {
+	var req registerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return req, false
+	}
+	if req.Service == "" || req.Address == "" {
+		writeError(w, http.StatusBadRequest, errors.New("service and address are required"))
+		return req, false
+	}
+	return req, true
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/discovery"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmin_Registrations(t *testing.T) {
+	router := route.NewRouter(nil, map[string]*config.ServiceConfig{
+		"api": {
+			Name:         "api",
+			BalancerType: "round_robin",
+			Registration: &config.RegistrationConfig{},
+		},
+		"web": {Name: "web", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: "http://web1"}}},
+	})
+	admin := NewAdmin(router)
+	assert.Equal(t, http.StatusNotFound, doRequest(t, admin, http.MethodGet, "/admin/registrations", "").Code)
+
+	registrar := discovery.New(router)
+	defer registrar.Stop()
+	admin.SetRegistrar(registrar)
+
+	w := doRequest(t, admin, http.MethodPost, "/admin/registrations", `{"service": "api", "address": "http://10.0.0.5:8080", "ttl": "1m"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var registration discovery.Registration
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &registration))
+	assert.Equal(t, "1m0s", registration.TTL)
+	assert.Equal(t, 1, registration.Weight)
+
+	w = doRequest(t, admin, http.MethodGet, "/admin/backends", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "http://10.0.0.5:8080")
+
+	assert.Equal(t, http.StatusNotFound, doRequest(t, admin, http.MethodPost, "/admin/registrations", `{"service": "db", "address": "http://db1"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, doRequest(t, admin, http.MethodPost, "/admin/registrations", `{"service": "web", "address": "http://web2"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, doRequest(t, admin, http.MethodPost, "/admin/registrations", `{"service": "api", "address": "http://10.0.0.6", "ttl": "1h"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, doRequest(t, admin, http.MethodPost, "/admin/registrations", `{"service": "api"}`).Code)
+
+	w = doRequest(t, admin, http.MethodGet, "/admin/registrations", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var registrations []discovery.Registration
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &registrations))
+	require.Len(t, registrations, 1)
+	assert.Equal(t, "http://10.0.0.5:8080", registrations[0].Address)
+
+	w = doRequest(t, admin, http.MethodPost, "/admin/registrations/deregister", `{"service": "api", "address": "http://10.0.0.5:8080"}`)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, router.Services()["api"].Config().Servers)
+	assert.Equal(t, http.StatusNotFound, doRequest(t, admin, http.MethodPost, "/admin/registrations/deregister", `{"service": "api", "address": "http://10.0.0.5:8080"}`).Code)
+}
@@ -1 +1,613 @@
 package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"nexus/internal/audit"
+	"nexus/internal/cache"
+	"nexus/internal/certstore"
+	"nexus/internal/config"
+	"nexus/internal/fault"
+	"nexus/internal/healthcheck"
+	lg "nexus/internal/logger"
+	"nexus/internal/maintenance"
+	"nexus/internal/route"
+	"nexus/internal/state"
+	"nexus/internal/stats"
+)
+
+// ManualDrainReason marks servers drained through the admin API
+const ManualDrainReason = "manual"
+
+// Admin serves the admin REST API
+type Admin struct {
+	mu            sync.RWMutex
+	router        route.Router
+	healthChecker *healthcheck.HealthChecker
+	maintenance   *maintenance.Scheduler
+	stateStore    *state.Store
+	certStore     *certstore.Store
+	cache         *cache.Cache
+	stats         *stats.Collector
+	pool          *stats.Pool
+	sampler       *route.Sampler
+	auth          *config.AdminAuthConfig
+	audit         *audit.Log
+	lifecycle     Lifecycle
+	faults        *fault.Injector
+	intake        Intake
+	registrar     Registrar
+	leader        Leader
+	snapshots     ConfigSnapshots
+	mux           *http.ServeMux
+}
+
+// Backend describes a backend server as reported by the admin API
+type Backend struct {
+	Service     string   `json:"service"`
+	Address     string   `json:"address"`
+	Weight      int      `json:"weight"`
+	Healthy     *bool    `json:"healthy,omitempty"`
+	Drained     []string `json:"drained,omitempty"`
+	Maintenance bool     `json:"maintenance"`
+	Version     string   `json:"version,omitempty"` // Reported by the service's health check info_path
+}
+
+// BackendHistory lists the latest health probes of a backend, transitions
+// counts the healthy/unhealthy flips among them
+type BackendHistory struct {
+	Address     string                    `json:"address"`
+	Healthy     bool                      `json:"healthy"`
+	Transitions int                       `json:"transitions"`
+	Probes      []healthcheck.ProbeResult `json:"probes"`
+}
+
+// drainRequest is the body of drain and undrain requests
+type drainRequest struct {
+	Service string `json:"service"`
+	Address string `json:"address"`
+}
+
+// certReloadRequest is the body of certificate reload requests, an empty
+// server name reloads every certificate
+type certReloadRequest struct {
+	ServerName string `json:"server_name"`
+}
+
+// purgeRequest is the body of cache purge requests, exactly one of the fields
+// selects the purged entries. URLs without a host match every host
+type purgeRequest struct {
+	URL    string `json:"url"`
+	Prefix string `json:"prefix"`
+	Tag    string `json:"tag"`
+}
+
+// maxDryRunConfigSize bounds the candidate config of dry-run requests
+const maxDryRunConfigSize = 10 << 20
+
+// dryRunResponse reports how sampled requests would be routed by a candidate
+// config. Shadowed routes match sampled requests now but none with the candidate
+type dryRunResponse struct {
+	Requests       int              `json:"requests"`
+	Changed        int              `json:"changed"`
+	Unmatched      int              `json:"unmatched"`
+	ShadowedRoutes []string         `json:"shadowed_routes"`
+	Changes        []route.Decision `json:"changes"`
+}
+
+// NewAdmin creates the admin API handler
+func NewAdmin(router route.Router) *Admin {
+	a := &Admin{
+		router: router,
+		mux:    http.NewServeMux(),
+	}
+
+	a.mux.HandleFunc("GET /admin/backends", a.handleBackends)
+	a.mux.HandleFunc("GET /admin/backends/history", a.handleBackendHistory)
+	a.mux.HandleFunc("POST /admin/backends/drain", a.handleDrain(true))
+	a.mux.HandleFunc("POST /admin/backends/undrain", a.handleDrain(false))
+	a.mux.HandleFunc("GET /admin/registrations", a.handleRegistrations)
+	a.mux.HandleFunc("POST /admin/registrations", a.handleRegister)
+	a.mux.HandleFunc("POST /admin/registrations/deregister", a.handleDeregister)
+	a.mux.HandleFunc("GET /admin/leader", a.handleLeader)
+	a.mux.HandleFunc("GET /admin/queues", a.handleQueues)
+	a.mux.HandleFunc("POST /admin/queues/pause", a.handleIntake(true))
+	a.mux.HandleFunc("POST /admin/queues/resume", a.handleIntake(false))
+	a.mux.HandleFunc("GET /admin/maintenance", a.handleMaintenance)
+	a.mux.HandleFunc("GET /admin/certificates", a.handleCertificates)
+	a.mux.HandleFunc("POST /admin/certificates/reload", a.handleCertReload)
+	a.mux.HandleFunc("GET /admin/stats;csv", a.handleStatsCSV)
+	a.mux.HandleFunc("GET /admin/stats/bandwidth", a.handleBandwidth)
+	a.mux.HandleFunc("GET /admin/stats/pool", a.handlePool)
+	a.mux.HandleFunc("GET /admin/stats/balancers", a.handleBalancers)
+	a.mux.HandleFunc("GET /admin/routes", a.handleRoutes)
+	a.mux.HandleFunc("POST /admin/routes", a.handleAddRoute)
+	a.mux.HandleFunc("GET /admin/routes/table", a.handleRouteTable)
+	a.mux.HandleFunc("GET /admin/routes/export", a.handleExportRoutes)
+	a.mux.HandleFunc("POST /admin/routes/import", a.handleImportRoutes)
+	a.mux.HandleFunc("DELETE /admin/routes/{name}", a.handleRemoveRoute)
+	a.mux.HandleFunc("GET /admin/faults", a.handleFaults)
+	a.mux.HandleFunc("PUT /admin/faults/{route}", a.handleSetFault)
+	a.mux.HandleFunc("DELETE /admin/faults/{route}", a.handleClearFault)
+	a.mux.HandleFunc("POST /admin/cache/purge", a.handleCachePurge)
+	a.mux.HandleFunc("POST /admin/config/dry-run", a.handleDryRun)
+	a.mux.HandleFunc("GET /admin/audit", a.handleAudit)
+	a.mux.HandleFunc("POST /admin/config/reload", a.handleLifecycle(http.StatusOK, Lifecycle.Reload))
+	a.mux.HandleFunc("GET /admin/config/snapshots", a.handleSnapshots)
+	a.mux.HandleFunc("POST /admin/config/rollback", a.handleRollback)
+	a.mux.HandleFunc("POST /admin/logs/reopen", a.handleLifecycle(http.StatusOK, Lifecycle.ReopenLogs))
+	a.mux.HandleFunc("POST /admin/shutdown", a.handleLifecycle(http.StatusAccepted, Lifecycle.Shutdown))
+
+	return a
+}
+
+// SetHealthChecker sets the health checker reported by the backends endpoint
+func (a *Admin) SetHealthChecker(healthChecker *healthcheck.HealthChecker) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.healthChecker = healthChecker
+}
+
+// SetMaintenance sets the maintenance scheduler
+func (a *Admin) SetMaintenance(scheduler *maintenance.Scheduler) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.maintenance = scheduler
+}
+
+// SetStateStore sets the store that persists operator decisions across restarts
+func (a *Admin) SetStateStore(store *state.Store) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.stateStore = store
+}
+
+// SetCertStore sets the TLS certificate store managed by the certificates endpoints
+func (a *Admin) SetCertStore(store *certstore.Store) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.certStore = store
+}
+
+// SetCache sets the response cache purged by the cache endpoint
+func (a *Admin) SetCache(c *cache.Cache) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.cache = c
+}
+
+// SetSampler sets the sampler of the recent requests replayed by config dry-runs
+func (a *Admin) SetSampler(sampler *route.Sampler) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.sampler = sampler
+}
+
+// persistState saves the operator drains when a state store is configured
+func (a *Admin) persistState() {
+	a.mu.RLock()
+	store := a.stateStore
+	a.mu.RUnlock()
+
+	if store == nil {
+		return
+	}
+	if err := store.Save(state.Capture(a.router, ManualDrainReason)); err != nil {
+		lg.GetInstance().Error("Failed to persist runtime state to %s: %v", store.Path(), err)
+	}
+}
+
+// ServeHTTP implements the http.Handler interface
+func (a *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.RLock()
+	log := a.audit
+	a.mu.RUnlock()
+
+	if log != nil && !readOnly(r) {
+		a.serveAudited(w, r, log)
+		return
+	}
+	if _, ok := a.authorize(w, r); !ok {
+		return
+	}
+	a.mux.ServeHTTP(w, r)
+}
+
+// handleBackends lists every backend with its health, drain and maintenance
+// state and the version it reported
+func (a *Admin) handleBackends(w http.ResponseWriter, r *http.Request) {
+	a.mu.RLock()
+	healthChecker := a.healthChecker
+	a.mu.RUnlock()
+
+	backends := make([]Backend, 0)
+	for name, svc := range a.router.Services() {
+		drained := svc.Drained()
+		for _, server := range svc.Config().Servers {
+			backend := Backend{
+				Service: name,
+				Address: server.Address,
+				Weight:  server.Weight,
+				Drained: drained[server.Address],
+			}
+			for _, reason := range backend.Drained {
+				if reason == maintenance.DrainReason {
+					backend.Maintenance = true
+				}
+			}
+			if healthChecker != nil {
+				healthy := healthChecker.IsHealthy(server.Address)
+				backend.Healthy = &healthy
+				backend.Version = healthChecker.Version(server.Address)
+			}
+			backends = append(backends, backend)
+		}
+	}
+
+	sort.Slice(backends, func(i, j int) bool {
+		if backends[i].Service != backends[j].Service {
+			return backends[i].Service < backends[j].Service
+		}
+		return backends[i].Address < backends[j].Address
+	})
+
+	writeJSON(w, http.StatusOK, backends)
+}
+
+// handleBackendHistory lists the recent probe results of all backends, or of
+// the one given by the address query parameter
+func (a *Admin) handleBackendHistory(w http.ResponseWriter, r *http.Request) {
+	a.mu.RLock()
+	healthChecker := a.healthChecker
+	a.mu.RUnlock()
+
+	if healthChecker == nil {
+		writeJSON(w, http.StatusOK, []BackendHistory{})
+		return
+	}
+
+	addresses := healthChecker.Servers()
+	if address := r.URL.Query().Get("address"); address != "" {
+		if !slices.Contains(addresses, address) {
+			writeError(w, http.StatusNotFound, fmt.Errorf("backend %s is not health checked", address))
+			return
+		}
+		addresses = []string{address}
+	}
+	sort.Strings(addresses)
+
+	histories := make([]BackendHistory, 0, len(addresses))
+	for _, address := range addresses {
+		probes := healthChecker.History(address)
+		history := BackendHistory{
+			Address: address,
+			Healthy: healthChecker.IsHealthy(address),
+			Probes:  probes,
+		}
+		for i := 1; i < len(probes); i++ {
+			if probes[i].Healthy != probes[i-1].Healthy {
+				history.Transitions++
+			}
+		}
+		histories = append(histories, history)
+	}
+
+	writeJSON(w, http.StatusOK, histories)
+}
+
+// handleDrain manually drains or re-enables a backend
+func (a *Admin) handleDrain(drain bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req drainRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+		if req.Service == "" || req.Address == "" {
+			writeError(w, http.StatusBadRequest, errors.New("service and address are required"))
+			return
+		}
+
+		svc, ok := a.router.Service(req.Service)
+		if !ok {
+			writeError(w, http.StatusNotFound, &config.ServiceNotFoundError{Service: req.Service})
+			return
+		}
+
+		var err error
+		if drain {
+			err = svc.Drain(req.Address, ManualDrainReason)
+		} else {
+			err = svc.Undrain(req.Address, ManualDrainReason)
+		}
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		a.persistState()
+
+		writeJSON(w, http.StatusOK, map[string][]string{"drained": svc.Drained()[req.Address]})
+	}
+}
+
+// handleMaintenance lists the maintenance windows and whether they are active
+func (a *Admin) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	a.mu.RLock()
+	scheduler := a.maintenance
+	a.mu.RUnlock()
+
+	if scheduler == nil {
+		writeJSON(w, http.StatusOK, []maintenance.Status{})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, scheduler.Statuses())
+}
+
+// handleCertificates lists the loaded TLS certificates with their expiry dates
+func (a *Admin) handleCertificates(w http.ResponseWriter, r *http.Request) {
+	a.mu.RLock()
+	store := a.certStore
+	a.mu.RUnlock()
+
+	if store == nil {
+		writeJSON(w, http.StatusOK, []certstore.CertificateInfo{})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, store.Certificates())
+}
+
+// handleCertReload reloads the certificate serving one SNI name, or all of them
+func (a *Admin) handleCertReload(w http.ResponseWriter, r *http.Request) {
+	a.mu.RLock()
+	store := a.certStore
+	a.mu.RUnlock()
+
+	if store == nil {
+		writeError(w, http.StatusNotFound, errors.New("tls is not enabled"))
+		return
+	}
+
+	var req certReloadRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+	}
+
+	if req.ServerName == "" {
+		if err := store.Reload(); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, store.Certificates())
+		return
+	}
+
+	info, err := store.ReloadServerName(req.ServerName)
+	if errors.Is(err, certstore.ErrUnknownServerName) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, []certstore.CertificateInfo{info})
+}
+
+// handleRoutes lists the routes in match order
+func (a *Admin) handleRoutes(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.router.ListRoutes())
+}
+
+// handleRouteTable exports the compiled route tree in lookup order as JSON,
+// an aligned text table (format=text) or a Graphviz graph (format=dot)
+func (a *Admin) handleRouteTable(w http.ResponseWriter, r *http.Request) {
+	entries := a.router.Table()
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		if entries == nil {
+			entries = []route.TableEntry{}
+		}
+		writeJSON(w, http.StatusOK, entries)
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		route.WriteTable(w, entries)
+	case "dot":
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		route.WriteDOT(w, entries)
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unsupported format: %s", format))
+	}
+}
+
+// handleAddRoute registers a route at runtime, it lasts until the next config reload
+func (a *Admin) handleAddRoute(w http.ResponseWriter, r *http.Request) {
+	var routeConfig config.RouteConfig
+	if err := json.NewDecoder(r.Body).Decode(&routeConfig); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if err := config.ValidateRoute(&routeConfig); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err := a.router.AddRoute(&routeConfig)
+	if errors.Is(err, route.ErrRouteExists) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, routeConfig)
+}
+
+// handleRemoveRoute unregisters a route by name
+func (a *Admin) handleRemoveRoute(w http.ResponseWriter, r *http.Request) {
+	if err := a.router.RemoveRoute(r.PathValue("name")); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleCachePurge removes cached responses by URL, path prefix or tag
+func (a *Admin) handleCachePurge(w http.ResponseWriter, r *http.Request) {
+	a.mu.RLock()
+	c := a.cache
+	a.mu.RUnlock()
+
+	if c == nil {
+		writeError(w, http.StatusNotFound, errors.New("response cache is not enabled"))
+		return
+	}
+
+	var req purgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	selectors := 0
+	for _, v := range []string{req.URL, req.Prefix, req.Tag} {
+		if v != "" {
+			selectors++
+		}
+	}
+	if selectors != 1 {
+		writeError(w, http.StatusBadRequest, errors.New("exactly one of url, prefix or tag is required"))
+		return
+	}
+
+	var purged int
+	switch {
+	case req.Tag != "":
+		purged = c.PurgeTag(req.Tag)
+	case req.URL != "":
+		host, uri, err := parsePurgeURL(req.URL)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		purged = c.Purge(host, uri)
+	default:
+		host, prefix, err := parsePurgeURL(req.Prefix)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		purged = c.PurgePrefix(host, prefix)
+	}
+	lg.GetInstance().Info("Purged %d cached responses", purged)
+
+	writeJSON(w, http.StatusOK, map[string]int{"purged": purged})
+}
+
+// handleDryRun routes the recently sampled requests with a candidate config
+// without applying it, the config is YAML unless sent as application/json
+func (a *Admin) handleDryRun(w http.ResponseWriter, r *http.Request) {
+	a.mu.RLock()
+	sampler := a.sampler
+	a.mu.RUnlock()
+
+	if sampler == nil {
+		writeError(w, http.StatusNotFound, errors.New("request sampling is not enabled"))
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDryRunConfigSize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	ext := ".yaml"
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		ext = ".json"
+	}
+	candidate := config.NewConfig()
+	if err := candidate.LoadFromBytes(data, ext); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid config: %w", err))
+		return
+	}
+	if err := candidate.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid config: %w", err))
+		return
+	}
+
+	decisions := route.Simulate(a.router, route.NewRouter(candidate.Routes, candidate.Services), sampler.Signatures())
+	resp := dryRunResponse{Requests: len(decisions), ShadowedRoutes: []string{}, Changes: []route.Decision{}}
+	reached := make(map[string]bool)
+	for _, decision := range decisions {
+		if decision.CandidateRoute != "" {
+			reached[decision.CandidateRoute] = true
+		} else {
+			resp.Unmatched++
+		}
+		if decision.Changed {
+			resp.Changed++
+			resp.Changes = append(resp.Changes, decision)
+		}
+	}
+	shadowed := make(map[string]bool)
+	for _, decision := range decisions {
+		if decision.Route != "" && !reached[decision.Route] && !shadowed[decision.Route] {
+			shadowed[decision.Route] = true
+			resp.ShadowedRoutes = append(resp.ShadowedRoutes, decision.Route)
+		}
+	}
+	sort.Strings(resp.ShadowedRoutes)
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// parsePurgeURL splits an absolute URL or a path into the host and request
+// URI the cache is keyed by
+func parsePurgeURL(rawURL string) (string, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Host == "" && !strings.HasPrefix(u.Path, "/") {
+		return "", "", fmt.Errorf("invalid url %s: must be absolute or start with /", rawURL)
+	}
+
+	return u.Host, u.RequestURI(), nil
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error as a JSON response
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
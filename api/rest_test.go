@@ -0,0 +1,366 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nexus/internal/cache"
+	"nexus/internal/config"
+	"nexus/internal/healthcheck"
+	"nexus/internal/maintenance"
+	"nexus/internal/route"
+	"nexus/internal/state"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRouter() route.Router {
+	return route.NewRouter(nil, map[string]*config.ServiceConfig{
+		"api": {
+			Name:         "api",
+			BalancerType: "weighted_round_robin",
+			Servers: []config.ServerConfig{
+				{Address: "http://backend2", Weight: 1},
+				{Address: "http://backend1", Weight: 3},
+			},
+		},
+	})
+}
+
+func doRequest(t *testing.T, h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdmin_Backends(t *testing.T) {
+	router := newTestRouter()
+	admin := NewAdmin(router)
+
+	checker := healthcheck.NewHealthChecker(true, time.Second, 100*time.Millisecond, "/health")
+	checker.AddServer("http://backend1")
+	checker.UpdateServerStatus("http://backend1", true)
+	admin.SetHealthChecker(checker)
+
+	rec := doRequest(t, admin, http.MethodGet, "/admin/backends", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var backends []Backend
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &backends))
+	require.Len(t, backends, 2)
+	assert.Equal(t, "http://backend1", backends[0].Address)
+	assert.Equal(t, 3, backends[0].Weight)
+	assert.True(t, *backends[0].Healthy)
+	assert.False(t, *backends[1].Healthy)
+}
+
+func TestAdmin_BackendHistory(t *testing.T) {
+	var probes atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Alternate between healthy and failing probes
+		if probes.Add(1)%2 == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer backend.Close()
+
+	admin := NewAdmin(newTestRouter())
+	rec := doRequest(t, admin, http.MethodGet, "/admin/backends/history", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+
+	checker := healthcheck.NewHealthChecker(true, 10*time.Millisecond, time.Second, "/health")
+	checker.AddServer(backend.URL)
+	checker.AddServer("http://backend1")
+	admin.SetHealthChecker(checker)
+	go checker.Start()
+	defer checker.Stop()
+	require.Eventually(t, func() bool { return len(checker.History(backend.URL)) >= 3 }, 2*time.Second, 5*time.Millisecond)
+
+	rec = doRequest(t, admin, http.MethodGet, "/admin/backends/history?address="+url.QueryEscape(backend.URL), "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var histories []BackendHistory
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &histories))
+	require.Len(t, histories, 1)
+	assert.Equal(t, backend.URL, histories[0].Address)
+	require.GreaterOrEqual(t, len(histories[0].Probes), 3)
+	assert.GreaterOrEqual(t, histories[0].Transitions, 2)
+	assert.NotEqual(t, histories[0].Probes[0].Healthy, histories[0].Probes[1].Healthy)
+	assert.False(t, histories[0].Probes[0].Time.Before(histories[0].Probes[1].Time), "most recent probe first")
+
+	rec = doRequest(t, admin, http.MethodGet, "/admin/backends/history", "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &histories))
+	assert.Len(t, histories, 2)
+
+	rec = doRequest(t, admin, http.MethodGet, "/admin/backends/history?address=http://unknown", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAdmin_DrainAndUndrain(t *testing.T) {
+	router := newTestRouter()
+	admin := NewAdmin(router)
+
+	rec := doRequest(t, admin, http.MethodPost, "/admin/backends/drain", `{"service":"api","address":"http://backend1"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{ManualDrainReason}, router.Services()["api"].Drained()["http://backend1"])
+
+	rec = doRequest(t, admin, http.MethodPost, "/admin/backends/undrain", `{"service":"api","address":"http://backend1"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, router.Services()["api"].Drained())
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"InvalidBody", `{`, http.StatusBadRequest},
+		{"MissingAddress", `{"service":"api"}`, http.StatusBadRequest},
+		{"UnknownService", `{"service":"web","address":"http://backend1"}`, http.StatusNotFound},
+		{"UnknownServer", `{"service":"api","address":"http://backend9"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(t, admin, http.MethodPost, "/admin/backends/drain", tt.body)
+			assert.Equal(t, tt.status, rec.Code)
+		})
+	}
+
+	rec = doRequest(t, admin, http.MethodGet, "/admin/backends/drain", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestAdmin_DrainPersistsState(t *testing.T) {
+	store := state.NewStore(filepath.Join(t.TempDir(), "state.json"))
+	admin := NewAdmin(newTestRouter())
+	admin.SetStateStore(store)
+
+	rec := doRequest(t, admin, http.MethodPost, "/admin/backends/drain", `{"service":"api","address":"http://backend1"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	st, err := store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{ManualDrainReason}, st.Drains["api"]["http://backend1"])
+
+	rec = doRequest(t, admin, http.MethodPost, "/admin/backends/undrain", `{"service":"api","address":"http://backend1"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	st, err = store.Load()
+	require.NoError(t, err)
+	assert.Empty(t, st.Drains)
+}
+
+func TestAdmin_Maintenance(t *testing.T) {
+	start := time.Now().Add(-time.Minute)
+	router := route.NewRouter(nil, map[string]*config.ServiceConfig{
+		"api": {
+			Name:         "api",
+			BalancerType: "round_robin",
+			Servers: []config.ServerConfig{
+				{
+					Address:     "http://backend1",
+					Maintenance: []config.MaintenanceWindow{{Start: start, End: start.Add(time.Hour)}},
+				},
+				{Address: "http://backend2"},
+			},
+		},
+	})
+	admin := NewAdmin(router)
+
+	rec := doRequest(t, admin, http.MethodGet, "/admin/maintenance", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+
+	scheduler := maintenance.NewScheduler(router, nil, time.Minute)
+	scheduler.Check()
+	admin.SetMaintenance(scheduler)
+
+	rec = doRequest(t, admin, http.MethodGet, "/admin/maintenance", "")
+	var statuses []maintenance.Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &statuses))
+	require.Len(t, statuses, 1)
+	assert.True(t, statuses[0].Active)
+
+	rec = doRequest(t, admin, http.MethodGet, "/admin/backends", "")
+	var backends []Backend
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &backends))
+	assert.True(t, backends[0].Maintenance)
+	assert.Nil(t, backends[0].Healthy)
+}
+
+func TestAdmin_CertificatesWithoutTLS(t *testing.T) {
+	admin := NewAdmin(newTestRouter())
+
+	rec := doRequest(t, admin, http.MethodGet, "/admin/certificates", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+
+	rec = doRequest(t, admin, http.MethodPost, "/admin/certificates/reload", `{"server_name":"www.example.com"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAdmin_Routes(t *testing.T) {
+	admin := NewAdmin(newTestRouter())
+
+	rec := doRequest(t, admin, http.MethodGet, "/admin/routes", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+
+	body := `{"name":"users","match":{"path":"/users/*"},"service":"api"}`
+	rec = doRequest(t, admin, http.MethodPost, "/admin/routes", body)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = doRequest(t, admin, http.MethodPost, "/admin/routes", body)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	rec = doRequest(t, admin, http.MethodPost, "/admin/routes", `{"name":"broken","service":"api"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = doRequest(t, admin, http.MethodPost, "/admin/routes", `{"name":"orphan","match":{"path":"/"},"service":"missing"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doRequest(t, admin, http.MethodGet, "/admin/routes", "")
+	var routes []config.RouteConfig
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &routes))
+	require.Len(t, routes, 1)
+	assert.Equal(t, "users", routes[0].Name)
+
+	rec = doRequest(t, admin, http.MethodDelete, "/admin/routes/users", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = doRequest(t, admin, http.MethodDelete, "/admin/routes/users", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAdmin_RouteTable(t *testing.T) {
+	admin := NewAdmin(newTestRouter())
+
+	rec := doRequest(t, admin, http.MethodGet, "/admin/routes/table", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+
+	body := `{"name":"users","match":{"path":"/users/*"},"service":"api"}`
+	require.Equal(t, http.StatusCreated, doRequest(t, admin, http.MethodPost, "/admin/routes", body).Code)
+
+	rec = doRequest(t, admin, http.MethodGet, "/admin/routes/table", "")
+	assert.JSONEq(t, `[{"order":1,"host_match":"any","path":"/users/*","wildcard":true,"route":"users","service":"api"}]`, rec.Body.String())
+
+	rec = doRequest(t, admin, http.MethodGet, "/admin/routes/table?format=text", "")
+	assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "/users/*")
+
+	rec = doRequest(t, admin, http.MethodGet, "/admin/routes/table?format=dot", "")
+	assert.Contains(t, rec.Body.String(), `"route:users" -> "service:api";`)
+
+	rec = doRequest(t, admin, http.MethodGet, "/admin/routes/table?format=svg", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAdmin_CachePurge(t *testing.T) {
+	admin := NewAdmin(newTestRouter())
+
+	rec := doRequest(t, admin, http.MethodPost, "/admin/cache/purge", `{"tag":"assets"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	c := cache.New(0)
+	admin.SetCache(c)
+	fill := func() {
+		c.PurgeAll()
+		for _, u := range []string{"a.example.com/static/app.js", "a.example.com/static/app.css", "b.example.com/static/app.js"} {
+			host, uri, _ := strings.Cut(u, "/")
+			c.Set(cache.Key(host, "/"+uri, ""), host, "/"+uri, &cache.Entry{Tags: []string{"assets"}, Expires: time.Now().Add(time.Minute)})
+		}
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		expected string
+	}{
+		{name: "url", body: `{"url":"https://a.example.com/static/app.js"}`, status: http.StatusOK, expected: `{"purged":1}`},
+		{name: "path on every host", body: `{"url":"/static/app.js"}`, status: http.StatusOK, expected: `{"purged":2}`},
+		{name: "prefix", body: `{"prefix":"https://a.example.com/static/"}`, status: http.StatusOK, expected: `{"purged":2}`},
+		{name: "tag", body: `{"tag":"assets"}`, status: http.StatusOK, expected: `{"purged":3}`},
+		{name: "no selector", body: `{}`, status: http.StatusBadRequest},
+		{name: "several selectors", body: `{"url":"/a","tag":"assets"}`, status: http.StatusBadRequest},
+		{name: "relative url", body: `{"prefix":"static/"}`, status: http.StatusBadRequest},
+		{name: "invalid body", body: `{`, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fill()
+			rec := doRequest(t, admin, http.MethodPost, "/admin/cache/purge", tt.body)
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.expected != "" {
+				assert.JSONEq(t, tt.expected, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAdmin_ConfigDryRun(t *testing.T) {
+	services := map[string]*config.ServiceConfig{
+		"api": {Name: "api", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: "http://backend1"}}},
+	}
+	router := route.NewRouter([]*config.RouteConfig{
+		{Name: "api", Match: config.RouteMatch{Path: "/api/*"}, Service: "api"},
+		{Name: "users", Match: config.RouteMatch{Path: "/api/users"}, Service: "api"},
+	}, services)
+	admin := NewAdmin(router)
+
+	candidate := `
+listen_addr: ":8080"
+services:
+  - name: "api"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1"
+routes:
+  - name: "api"
+    match:
+      path: "/api/*"
+    service: "api"
+health_check:
+  interval: 10s
+  timeout: 2s
+`
+	rec := doRequest(t, admin, http.MethodPost, "/admin/config/dry-run", candidate)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	sampler := route.NewSampler(10)
+	admin.SetSampler(sampler)
+	for _, path := range []string{"/api/users", "/api/orders", "/other", "/api/users"} {
+		sampler.Record(httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	rec = doRequest(t, admin, http.MethodPost, "/admin/config/dry-run", candidate)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp dryRunResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, 3, resp.Requests)
+	assert.Equal(t, 1, resp.Changed)
+	assert.Equal(t, 1, resp.Unmatched)
+	assert.Equal(t, []string{"users"}, resp.ShadowedRoutes)
+	require.Len(t, resp.Changes, 1)
+	assert.Equal(t, route.Decision{
+		Signature:        route.Signature{Method: http.MethodGet, Host: "example.com", Path: "/api/users"},
+		Route:            "users",
+		Service:          "api",
+		CandidateRoute:   "api",
+		CandidateService: "api",
+		Changed:          true,
+	}, resp.Changes[0])
+
+	// The candidate is validated and never applied
+	rec = doRequest(t, admin, http.MethodPost, "/admin/config/dry-run", `{"listen_addr": ""}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Len(t, router.ListRoutes(), 2)
+}
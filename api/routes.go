@@ -0,0 +1,224 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"nexus/internal/config"
+)
+
+// maxImportSize bounds the route sets of import requests
+const maxImportSize = 10 << 20
+
+// Route import modes
+const (
+	importReplace = "replace" // The imported routes replace all routes
+	importMerge   = "merge"   // Imported routes replace those of their name, others are kept
+)
+
+// routeSet is the body of exports, a JSON config holding only routes
+type routeSet struct {
+	Routes []*config.RouteConfig `json:"routes"`
+}
+
+// routeError is the validation error of an imported route
+type routeError struct {
+	Index int    `json:"index"` // Position of the route in the import
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// importErrorResponse lists the invalid routes of a rejected import
+type importErrorResponse struct {
+	Error  string       `json:"error"`
+	Routes []routeError `json:"routes"`
+}
+
+// importResponse reports the route changes of an import, not applied for dry-runs
+type importResponse struct {
+	Applied   bool     `json:"applied"`
+	Added     []string `json:"added"`
+	Updated   []string `json:"updated"`
+	Removed   []string `json:"removed"`
+	Unchanged int      `json:"unchanged"`
+}
+
+// invalidRoutesError carries the per-route errors out of the route update
+type invalidRoutesError struct {
+	routes []routeError
+}
+
+func (e *invalidRoutesError) Error() string {
+	return fmt.Sprintf("%d invalid routes", len(e.routes))
+}
+
+// errDryRun aborts the route update of dry-runs once the changes are known
+var errDryRun = errors.New("dry run")
+
+// handleExportRoutes exports the routes in match order as a JSON config,
+// which the import endpoint and the config loader accept
+func (a *Admin) handleExportRoutes(w http.ResponseWriter, r *http.Request) {
+	routes := a.router.ListRoutes()
+	if routes == nil {
+		routes = []*config.RouteConfig{}
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="routes.json"`)
+	writeJSON(w, http.StatusOK, routeSet{Routes: routes})
+}
+
+// handleImportRoutes replaces the routes with those of a config, YAML unless
+// sent as application/json. Every route is validated before any is applied,
+// an invalid one rejects the whole import
+func (a *Admin) handleImportRoutes(w http.ResponseWriter, r *http.Request) {
+	mode := r.URL.Query().Get("mode")
+	switch mode {
+	case "":
+		mode = importReplace
+	case importReplace, importMerge:
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unsupported import mode: %s", mode))
+		return
+	}
+	var dryRun bool
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid dry_run: %q", value))
+			return
+		}
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	ext := ".yaml"
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		ext = ".json"
+	}
+	candidate := config.NewConfig()
+	if err := candidate.LoadFromBytes(data, ext); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid config: %w", err))
+		return
+	}
+	imported := candidate.Routes
+
+	services := make(map[string]*config.ServiceConfig)
+	for name, svc := range a.router.Services() {
+		services[name] = svc.Config()
+	}
+
+	var resp importResponse
+	err = a.router.UpdateRoutes(func(current []*config.RouteConfig) ([]*config.RouteConfig, error) {
+		if invalid := validateImport(imported, services); len(invalid) > 0 {
+			return nil, &invalidRoutesError{routes: invalid}
+		}
+		next := imported
+		if mode == importMerge {
+			next = mergeRoutes(current, imported)
+		}
+		if err := config.ValidateRouteOverlap(next); err != nil {
+			return nil, err
+		}
+
+		resp = diffRoutes(current, next)
+		if dryRun {
+			return nil, errDryRun
+		}
+		return next, nil
+	})
+
+	var invalid *invalidRoutesError
+	switch {
+	case errors.As(err, &invalid):
+		writeJSON(w, http.StatusBadRequest, importErrorResponse{Error: "invalid routes", Routes: invalid.routes})
+	case errors.Is(err, errDryRun):
+		writeJSON(w, http.StatusOK, resp)
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err)
+	default:
+		resp.Applied = true
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// validateImport validates every imported route on its own and against the
+// services, so the errors of all routes are reported at once
+func validateImport(routes []*config.RouteConfig, services map[string]*config.ServiceConfig) []routeError {
+	var invalid []routeError
+	names := make(map[string]bool, len(routes))
+	for i, route := range routes {
+		err := config.ValidateRoute(route)
+		if err == nil && names[route.Name] {
+			err = fmt.Errorf("duplicate route name: %s", route.Name)
+		}
+		if err == nil {
+			err = config.ValidateRouteServices(route, services)
+		}
+		names[route.Name] = true
+		if err != nil {
+			invalid = append(invalid, routeError{Index: i, Name: route.Name, Error: err.Error()})
+		}
+	}
+	return invalid
+}
+
+// mergeRoutes replaces the current routes named like imported ones in place
+// and appends the other imported routes
+func mergeRoutes(current, imported []*config.RouteConfig) []*config.RouteConfig {
+	byName := make(map[string]*config.RouteConfig, len(imported))
+	for _, route := range imported {
+		byName[route.Name] = route
+	}
+
+	merged := make([]*config.RouteConfig, 0, len(current)+len(imported))
+	for _, route := range current {
+		if replacement, ok := byName[route.Name]; ok {
+			merged = append(merged, replacement)
+			delete(byName, route.Name)
+		} else {
+			merged = append(merged, route)
+		}
+	}
+	for _, route := range imported {
+		if _, ok := byName[route.Name]; ok {
+			merged = append(merged, route)
+		}
+	}
+	return merged
+}
+
+// diffRoutes lists the routes an update adds, changes and removes by name
+func diffRoutes(current, next []*config.RouteConfig) importResponse {
+	resp := importResponse{Added: []string{}, Updated: []string{}, Removed: []string{}}
+	existing := make(map[string]*config.RouteConfig, len(current))
+	for _, route := range current {
+		existing[route.Name] = route
+	}
+
+	kept := make(map[string]bool, len(next))
+	for _, route := range next {
+		kept[route.Name] = true
+		previous, ok := existing[route.Name]
+		switch {
+		case !ok:
+			resp.Added = append(resp.Added, route.Name)
+		case !reflect.DeepEqual(previous, route):
+			resp.Updated = append(resp.Updated, route.Name)
+		default:
+			resp.Unchanged++
+		}
+	}
+	for _, route := range current {
+		if !kept[route.Name] {
+			resp.Removed = append(resp.Removed, route.Name)
+		}
+	}
+	return resp
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmin_ExportImportRoutes(t *testing.T) {
+	router := newTestRouter()
+	require.NoError(t, router.AddRoute(&config.RouteConfig{Name: "users", Match: config.RouteMatch{Path: "/users/*"}, Service: "api"}))
+	require.NoError(t, router.AddRoute(&config.RouteConfig{Name: "orders", Match: config.RouteMatch{Path: "/orders/*"}, Service: "api"}))
+	admin := NewAdmin(router)
+
+	rec := doRequest(t, admin, http.MethodGet, "/admin/routes/export", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var exported routeSet
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &exported))
+	require.Len(t, exported.Routes, 2)
+	assert.Equal(t, "users", exported.Routes[0].Name)
+
+	// The export imports again without changes
+	req := httptest.NewRequest(http.MethodPost, "/admin/routes/import", strings.NewReader(rec.Body.String()))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"applied": true, "added": [], "updated": [], "removed": [], "unchanged": 2}`, rec.Body.String())
+
+	replacement := `
+routes:
+  - name: "users"
+    match:
+      path: "/v2/users/*"
+    service: "api"
+  - name: "products"
+    match:
+      path: "/products/*"
+    service: "api"
+`
+	rec = doRequest(t, admin, http.MethodPost, "/admin/routes/import?dry_run=true", replacement)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"applied": false, "added": ["products"], "updated": ["users"], "removed": ["orders"], "unchanged": 0}`, rec.Body.String())
+	assert.Len(t, router.ListRoutes(), 2)
+	assert.Equal(t, "/users/*", router.ListRoutes()[0].Match.Path)
+
+	rec = doRequest(t, admin, http.MethodPost, "/admin/routes/import?mode=merge", replacement)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"applied": true, "added": ["products"], "updated": ["users"], "removed": [], "unchanged": 1}`, rec.Body.String())
+	names := func() []string {
+		var names []string
+		for _, route := range router.ListRoutes() {
+			names = append(names, route.Name)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"users", "orders", "products"}, names())
+
+	rec = doRequest(t, admin, http.MethodPost, "/admin/routes/import", replacement)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"users", "products"}, names())
+}
+
+func TestAdmin_ImportRoutesInvalid(t *testing.T) {
+	router := route.NewRouter(nil, map[string]*config.ServiceConfig{
+		"api": {Name: "api", BalancerType: "round_robin"},
+		"web": {Name: "web", BalancerType: "round_robin"},
+	})
+	require.NoError(t, router.AddRoute(&config.RouteConfig{Name: "users", Match: config.RouteMatch{Path: "/users/*"}, Service: "api"}))
+	admin := NewAdmin(router)
+
+	// Every invalid route is reported and none is applied
+	rec := doRequest(t, admin, http.MethodPost, "/admin/routes/import", `
+routes:
+  - name: "valid"
+    match:
+      path: "/valid"
+    service: "api"
+  - name: "empty"
+    service: "api"
+  - name: "orphan"
+    match:
+      path: "/orphan"
+    service: "missing"
+  - name: "valid"
+    match:
+      path: "/again"
+    service: "api"
+`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	var resp importErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, []routeError{
+		{Index: 1, Name: "empty", Error: "route empty: match condition cannot be empty"},
+		{Index: 2, Name: "orphan", Error: "route orphan: service missing not found"},
+		{Index: 3, Name: "valid", Error: "duplicate route name: valid"},
+	}, resp.Routes)
+	require.Len(t, router.ListRoutes(), 1)
+	assert.Equal(t, "users", router.ListRoutes()[0].Name)
+
+	// Merged routes are checked against the kept ones
+	rec = doRequest(t, admin, http.MethodPost, "/admin/routes/import?mode=merge", `
+routes:
+  - name: "shadow"
+    match:
+      path: "/users/*"
+    service: "web"
+`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "same match conditions as route users")
+	rec = doRequest(t, admin, http.MethodPost, "/admin/routes/import?mode=sync", `routes: []`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = doRequest(t, admin, http.MethodPost, "/admin/routes/import", `routes: [`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Len(t, router.ListRoutes(), 1)
+}
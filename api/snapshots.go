@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"nexus/internal/config"
+	"nexus/internal/snapshot"
+)
+
+// ConfigSnapshots keeps the config files applied and restores them,
+// implemented by the config watcher
+type ConfigSnapshots interface {
+	Snapshots() ([]snapshot.Snapshot, error)
+	Rollback(name string) (string, error)
+}
+
+// rollbackRequest is the body of rollback requests
+type rollbackRequest struct {
+	To string `json:"to"` // Snapshot name, or a unique prefix of it
+}
+
+// rollbackResponse reports the snapshot applied by a rollback
+type rollbackResponse struct {
+	Restored string `json:"restored"`
+}
+
+// SetConfigSnapshots enables the config snapshot and rollback endpoints
+func (a *Admin) SetConfigSnapshots(snapshots ConfigSnapshots) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.snapshots = snapshots
+}
+
+// handleSnapshots lists the config snapshots, latest first
+func (a *Admin) handleSnapshots(w http.ResponseWriter, r *http.Request) {
+	a.mu.RLock()
+	snapshots := a.snapshots
+	a.mu.RUnlock()
+
+	if snapshots == nil {
+		writeError(w, http.StatusNotFound, config.ErrSnapshotsDisabled)
+		return
+	}
+	list, err := snapshots.Snapshots()
+	if errors.Is(err, config.ErrSnapshotsDisabled) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if list == nil {
+		list = []snapshot.Snapshot{}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleRollback restores a config snapshot and applies it
+func (a *Admin) handleRollback(w http.ResponseWriter, r *http.Request) {
+	a.mu.RLock()
+	snapshots := a.snapshots
+	a.mu.RUnlock()
+
+	if snapshots == nil {
+		writeError(w, http.StatusNotFound, config.ErrSnapshotsDisabled)
+		return
+	}
+
+	var req rollbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if req.To == "" {
+		writeError(w, http.StatusBadRequest, errors.New("snapshot name is required"))
+		return
+	}
+
+	name, err := snapshots.Rollback(req.To)
+	switch {
+	case errors.Is(err, config.ErrSnapshotsDisabled), errors.Is(err, snapshot.ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rollbackResponse{Restored: name})
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/snapshot"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmin_ConfigRollback(t *testing.T) {
+	admin := NewAdmin(newTestRouter())
+	assert.Equal(t, http.StatusNotFound, doRequest(t, admin, http.MethodGet, "/admin/config/snapshots", "").Code)
+
+	base := `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+health_check:
+  interval: 10s
+  timeout: 2s
+`
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(base), 0o644))
+	watcher := config.NewConfigWatcher(configFile)
+	watcher.SetSnapshots(config.NewSnapshotStore(config.SnapshotConfig{Dir: filepath.Join(dir, "snapshots")}, configFile))
+	watcher.Reload()
+	require.NoError(t, os.WriteFile(configFile, []byte(base+"log_level: debug\n"), 0o644))
+	watcher.Reload()
+	admin.SetConfigSnapshots(watcher)
+
+	w := doRequest(t, admin, http.MethodGet, "/admin/config/snapshots", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var snapshots []snapshot.Snapshot
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &snapshots))
+	require.Len(t, snapshots, 2)
+
+	assert.Equal(t, http.StatusBadRequest, doRequest(t, admin, http.MethodPost, "/admin/config/rollback", `{}`).Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(t, admin, http.MethodPost, "/admin/config/rollback", `{"to": "missing"}`).Code)
+
+	w = doRequest(t, admin, http.MethodPost, "/admin/config/rollback", `{"to": "`+snapshots[1].Name+`"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"restored": "`+snapshots[1].Name+`"}`, w.Body.String())
+	data, err := os.ReadFile(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, base, string(data))
+}
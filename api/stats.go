@@ -0,0 +1,297 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"nexus/internal/balancer"
+	"nexus/internal/stats"
+)
+
+// statsFields are the HAProxy CSV stats columns, in HAProxy order
+var statsFields = []string{
+	"pxname", "svname", "qcur", "qmax", "scur", "smax", "slim", "stot", "bin", "bout",
+	"dreq", "dresp", "ereq", "econ", "eresp", "wretr", "wredis", "status", "weight", "act",
+	"bck", "chkfail", "chkdown", "lastchg", "downtime", "qlimit", "pid", "iid", "sid", "throttle",
+	"lbtot", "tracked", "type", "rate", "rate_lim", "rate_max", "check_status", "check_code", "check_duration", "hrsp_1xx",
+	"hrsp_2xx", "hrsp_3xx", "hrsp_4xx", "hrsp_5xx", "hrsp_other", "hanafail", "req_rate", "req_rate_max", "req_tot", "cli_abrt",
+	"srv_abrt",
+}
+
+// HAProxy stats row types
+const (
+	statsTypeFrontend = "0"
+	statsTypeBackend  = "1"
+	statsTypeServer   = "2"
+)
+
+// SetStats sets the traffic collector reported by the stats endpoint
+func (a *Admin) SetStats(collector *stats.Collector) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.stats = collector
+}
+
+// SetPool sets the upstream connection collector reported by the pool endpoint
+func (a *Admin) SetPool(pool *stats.Pool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pool = pool
+}
+
+// PoolStats is the upstream connection pool of a server
+type PoolStats struct {
+	Open      int64 `json:"open"`
+	Active    int64 `json:"active"`
+	Idle      int64 `json:"idle"`
+	New       int64 `json:"new"`
+	Reused    int64 `json:"reused"`
+	Discarded int64 `json:"discarded"`
+	Leaked    int64 `json:"leaked"`
+}
+
+// handlePool reports the upstream connections per server address: open,
+// active and idle ones, requests on new and reused connections, and response
+// bodies that were closed early or leaked
+func (a *Admin) handlePool(w http.ResponseWriter, r *http.Request) {
+	a.mu.RLock()
+	pool := a.pool
+	a.mu.RUnlock()
+
+	servers := make(map[string]PoolStats)
+	if pool != nil {
+		for server, cnt := range pool.Snapshot() {
+			servers[server] = PoolStats(cnt)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]map[string]PoolStats{"servers": servers})
+}
+
+// BalancerServerStats is the selections of a server by the balancer of its
+// service and the errors of the requests sent to it
+type BalancerServerStats struct {
+	Selections   uint64     `json:"selections"`
+	Errors       uint64     `json:"errors"`
+	LastSelected *time.Time `json:"last_selected,omitempty"`
+}
+
+// BalancerStats is the balancer of a service and the statistics of its servers
+type BalancerStats struct {
+	Type    string                         `json:"type"`
+	Servers map[string]BalancerServerStats `json:"servers"`
+}
+
+// handleBalancers reports the balancer statistics per service and server
+func (a *Admin) handleBalancers(w http.ResponseWriter, r *http.Request) {
+	services := a.router.Services()
+	resp := make(map[string]BalancerStats, len(services))
+	for name, svc := range services {
+		b := svc.Balancer()
+		resp[name] = BalancerStats{Type: b.Type(), Servers: balancerServers(b.Stats())}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]map[string]BalancerStats{"services": resp})
+}
+
+func balancerServers(stats map[string]balancer.ServerStats) map[string]BalancerServerStats {
+	servers := make(map[string]BalancerServerStats, len(stats))
+	for server, s := range stats {
+		entry := BalancerServerStats{Selections: s.Selections, Errors: s.Errors}
+		if !s.LastSelected.IsZero() {
+			last := s.LastSelected.UTC()
+			entry.LastSelected = &last
+		}
+		servers[server] = entry
+	}
+	return servers
+}
+
+// Bandwidth is the request and byte totals of a route, service or server
+type Bandwidth struct {
+	Requests int64 `json:"requests"`
+	BytesIn  int64 `json:"bytes_in"`
+	BytesOut int64 `json:"bytes_out"`
+}
+
+// bandwidthResponse is the body of the bandwidth endpoint
+type bandwidthResponse struct {
+	Routes   map[string]Bandwidth            `json:"routes"`
+	Services map[string]Bandwidth            `json:"services"`
+	Servers  map[string]map[string]Bandwidth `json:"servers"` // service -> server address
+}
+
+// handleBandwidth reports the totals per route, service and server since the
+// start, e.g. for chargeback
+func (a *Admin) handleBandwidth(w http.ResponseWriter, r *http.Request) {
+	a.mu.RLock()
+	collector := a.stats
+	a.mu.RUnlock()
+
+	snapshot := stats.Snapshot{}
+	if collector != nil {
+		snapshot = collector.Snapshot()
+	}
+
+	resp := bandwidthResponse{
+		Routes:   bandwidth(snapshot.Routes),
+		Services: bandwidth(snapshot.Backends),
+		Servers:  make(map[string]map[string]Bandwidth, len(snapshot.Servers)),
+	}
+	for service, servers := range snapshot.Servers {
+		resp.Servers[service] = bandwidth(servers)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func bandwidth(counters map[string]stats.Counters) map[string]Bandwidth {
+	totals := make(map[string]Bandwidth, len(counters))
+	for name, cnt := range counters {
+		totals[name] = Bandwidth{Requests: cnt.Total, BytesIn: cnt.BytesIn, BytesOut: cnt.BytesOut}
+	}
+	return totals
+}
+
+// handleStatsCSV reports frontend, backend and server rows in the HAProxy
+// stats CSV format, columns nexus doesn't track are left empty
+func (a *Admin) handleStatsCSV(w http.ResponseWriter, r *http.Request) {
+	a.mu.RLock()
+	collector := a.stats
+	healthChecker := a.healthChecker
+	a.mu.RUnlock()
+
+	snapshot := stats.Snapshot{}
+	if collector != nil {
+		snapshot = collector.Snapshot()
+	}
+
+	var b strings.Builder
+	b.WriteString("# " + strings.Join(statsFields, ",") + ",\n")
+
+	proxyID := 0
+	frontends := make([]string, 0, len(snapshot.Frontends))
+	for name := range snapshot.Frontends {
+		frontends = append(frontends, name)
+	}
+	sort.Strings(frontends)
+	for _, name := range frontends {
+		proxyID++
+		cnt := snapshot.Frontends[name]
+		row := countersRow(name, "FRONTEND", cnt, proxyID, 0, statsTypeFrontend)
+		row["status"] = "OPEN"
+		row["ereq"] = strconv.FormatInt(cnt.Errors, 10)
+		row["req_rate"] = row["rate"]
+		row["req_rate_max"] = row["rate_max"]
+		row["req_tot"] = row["stot"]
+		writeStatsRow(&b, row)
+	}
+
+	services := a.router.Services()
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		proxyID++
+		svc := services[name]
+		drained := svc.Drained()
+
+		up, weight, active := 0, 0, 0
+		for i, server := range svc.Config().Servers {
+			cnt := snapshot.Servers[name][server.Address]
+			row := countersRow(name, server.Address, cnt, proxyID, i+1, statsTypeServer)
+			row["eresp"] = "0"
+			row["weight"] = strconv.Itoa(server.Weight)
+			row["act"] = "1"
+			row["bck"] = "0"
+			row["lbtot"] = row["stot"]
+
+			switch {
+			case len(drained[server.Address]) > 0:
+				row["status"] = "MAINT"
+			case healthChecker == nil:
+				row["status"] = "no check"
+				up++
+			case healthChecker.IsHealthy(server.Address):
+				row["status"] = "UP"
+				up++
+			default:
+				row["status"] = "DOWN"
+			}
+			if row["status"] != "MAINT" && row["status"] != "DOWN" {
+				weight += server.Weight
+				active++
+			}
+			writeStatsRow(&b, row)
+		}
+
+		cnt := snapshot.Backends[name]
+		row := countersRow(name, "BACKEND", cnt, proxyID, 0, statsTypeBackend)
+		row["dreq"] = strconv.FormatInt(cnt.Denied, 10)
+		row["econ"] = strconv.FormatInt(cnt.Errors, 10)
+		row["eresp"] = "0"
+		row["weight"] = strconv.Itoa(weight)
+		row["act"] = strconv.Itoa(active)
+		row["bck"] = "0"
+		row["lbtot"] = row["stot"]
+		row["status"] = "UP"
+		if up == 0 {
+			row["status"] = "DOWN"
+		}
+		writeStatsRow(&b, row)
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(b.String()))
+}
+
+// countersRow fills the columns shared by every row type
+func countersRow(pxname, svname string, cnt stats.Counters, iid, sid int, rowType string) map[string]string {
+	row := map[string]string{
+		"pxname":     pxname,
+		"svname":     svname,
+		"scur":       strconv.FormatInt(cnt.Current, 10),
+		"smax":       strconv.FormatInt(cnt.Max, 10),
+		"stot":       strconv.FormatInt(cnt.Total, 10),
+		"bin":        strconv.FormatInt(cnt.BytesIn, 10),
+		"bout":       strconv.FormatInt(cnt.BytesOut, 10),
+		"pid":        "1",
+		"iid":        strconv.Itoa(iid),
+		"sid":        strconv.Itoa(sid),
+		"type":       rowType,
+		"rate":       strconv.FormatInt(cnt.Rate, 10),
+		"rate_max":   strconv.FormatInt(cnt.RateMax, 10),
+		"hrsp_other": strconv.FormatInt(cnt.Responses[5], 10),
+		"cli_abrt":   strconv.FormatInt(cnt.Canceled, 10),
+	}
+	for i, field := range []string{"hrsp_1xx", "hrsp_2xx", "hrsp_3xx", "hrsp_4xx", "hrsp_5xx"} {
+		row[field] = strconv.FormatInt(cnt.Responses[i], 10)
+	}
+
+	return row
+}
+
+// writeStatsRow writes the row in column order, HAProxy ends every line with a comma
+func writeStatsRow(b *strings.Builder, row map[string]string) {
+	for _, field := range statsFields {
+		b.WriteString(csvField(row[field]))
+		b.WriteByte(',')
+	}
+	b.WriteByte('\n')
+}
+
+// csvField keeps separators in server addresses from breaking the row
+func csvField(value string) string {
+	if !strings.ContainsAny(value, ",\"\n") {
+		return value
+	}
+	return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"nexus/internal/stats"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmin_StatsCSV(t *testing.T) {
+	router := newTestRouter()
+	admin := NewAdmin(router)
+	require.NoError(t, router.Services()["api"].Drain("http://backend2", ManualDrainReason))
+
+	collector := stats.NewCollector()
+	req := collector.Start("http", "api")
+	req.SetServer("http://backend1")
+	req.Finish(http.StatusOK, 12, 345)
+	admin.SetStats(collector)
+
+	rec := doRequest(t, admin, http.MethodGet, "/admin/stats;csv", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+	require.True(t, strings.HasPrefix(rec.Body.String(), "# pxname,svname,"))
+
+	reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(rec.Body.String(), "# ")))
+	records, err := reader.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 5)
+
+	rows := make(map[string]map[string]string)
+	for _, record := range records[1:] {
+		row := make(map[string]string)
+		for i, field := range records[0] {
+			row[field] = record[i]
+		}
+		rows[row["pxname"]+"/"+row["svname"]] = row
+	}
+
+	frontend := rows["http/FRONTEND"]
+	assert.Equal(t, "OPEN", frontend["status"])
+	assert.Equal(t, "1", frontend["req_tot"])
+	assert.Equal(t, "0", frontend["type"])
+
+	server := rows["api/http://backend1"]
+	assert.Equal(t, "no check", server["status"])
+	assert.Equal(t, "3", server["weight"])
+	assert.Equal(t, "1", server["stot"])
+	assert.Equal(t, "12", server["bin"])
+	assert.Equal(t, "345", server["bout"])
+	assert.Equal(t, "1", server["hrsp_2xx"])
+	assert.Equal(t, "2", server["type"])
+	assert.Equal(t, "MAINT", rows["api/http://backend2"]["status"])
+
+	backend := rows["api/BACKEND"]
+	assert.Equal(t, "UP", backend["status"])
+	assert.Equal(t, "3", backend["weight"])
+	assert.Equal(t, "1", backend["act"])
+	assert.Equal(t, "1", backend["type"])
+}
+
+func TestAdmin_Bandwidth(t *testing.T) {
+	admin := NewAdmin(newTestRouter())
+	rec := doRequest(t, admin, http.MethodGet, "/admin/stats/bandwidth", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"routes":{},"services":{},"servers":{}}`, rec.Body.String())
+
+	collector := stats.NewCollector()
+	for i := 0; i < 2; i++ {
+		req := collector.Start("http", "api")
+		req.SetRoute("api-route")
+		req.SetServer("http://backend1")
+		req.Finish(http.StatusOK, 10, 100)
+	}
+	admin.SetStats(collector)
+
+	rec = doRequest(t, admin, http.MethodGet, "/admin/stats/bandwidth", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{
+		"routes": {"api-route": {"requests": 2, "bytes_in": 20, "bytes_out": 200}},
+		"services": {"api": {"requests": 2, "bytes_in": 20, "bytes_out": 200}},
+		"servers": {"api": {"http://backend1": {"requests": 2, "bytes_in": 20, "bytes_out": 200}}}
+	}`, rec.Body.String())
+}
+
+func TestAdmin_Pool(t *testing.T) {
+	admin := NewAdmin(newTestRouter())
+	rec := doRequest(t, admin, http.MethodGet, "/admin/stats/pool", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"servers":{}}`, rec.Body.String())
+
+	pool := stats.NewPool()
+	pool.Add("http://backend1", stats.PoolCounters{Open: 2, Active: 1, New: 2, Reused: 3, Discarded: 1})
+	admin.SetPool(pool)
+
+	rec = doRequest(t, admin, http.MethodGet, "/admin/stats/pool", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"servers": {"http://backend1": {
+		"open": 2, "active": 1, "idle": 1, "new": 2, "reused": 3, "discarded": 1, "leaked": 0
+	}}}`, rec.Body.String())
+}
+
+func TestAdmin_Balancers(t *testing.T) {
+	router := newTestRouter()
+	admin := NewAdmin(router)
+	b := router.Services()["api"].Balancer()
+	for i := 0; i < 4; i++ {
+		_, err := b.Next(context.Background())
+		require.NoError(t, err)
+	}
+	b.Failed("http://backend1")
+
+	rec := doRequest(t, admin, http.MethodGet, "/admin/stats/balancers", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp map[string]map[string]BalancerStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+
+	api := resp["services"]["api"]
+	assert.Equal(t, "weighted_round_robin", api.Type)
+	require.Len(t, api.Servers, 2)
+	assert.Equal(t, uint64(1), api.Servers["http://backend2"].Selections)
+	assert.Equal(t, uint64(3), api.Servers["http://backend1"].Selections)
+	assert.Equal(t, uint64(1), api.Servers["http://backend1"].Errors)
+	assert.NotNil(t, api.Servers["http://backend1"].LastSelected)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"nexus/internal/bench"
+)
+
+// headerFlags collects repeated -H "Name: value" flags
+type headerFlags http.Header
+
+func (h headerFlags) String() string {
+	return fmt.Sprint(http.Header(h))
+}
+
+func (h headerFlags) Set(value string) error {
+	name, v, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("invalid header %q, expected Name: value", value)
+	}
+	http.Header(h).Add(strings.TrimSpace(name), strings.TrimSpace(v))
+	return nil
+}
+
+// runBench drives synthetic load against a running instance and prints the
+// latency percentiles, it returns the process exit code
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	target := fs.String("target", "http://localhost:8080", "base URL of the nexus instance")
+	connections := fs.Int("c", 10, "concurrent connections")
+	rps := fs.Int("rps", 0, "requests per second over all connections, 0 means unlimited")
+	duration := fs.Duration("d", 10*time.Second, "run duration, 0 runs until -n requests are sent")
+	requests := fs.Int("n", 0, "total requests, 0 runs for the whole duration")
+	paths := fs.String("paths", "/", "comma separated request paths, used in turn")
+	method := fs.String("method", http.MethodGet, "request method")
+	timeout := fs.Duration("timeout", 10*time.Second, "request timeout")
+	headers := headerFlags{}
+	fs.Var(headers, "H", "request header \"Name: value\", may be repeated")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	// Interrupting the run still reports the requests made so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	opts := bench.Options{
+		Target:      *target,
+		Method:      *method,
+		Paths:       strings.Split(*paths, ","),
+		Headers:     http.Header(headers),
+		Connections: *connections,
+		RPS:         *rps,
+		Duration:    *duration,
+		Requests:    *requests,
+		Timeout:     *timeout,
+	}
+	fmt.Printf("Sending %s requests to %s with %d connections...\n", opts.Method, opts.Target, opts.Connections)
+	result, err := bench.Run(ctx, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bench: %v\n", err)
+		return 1
+	}
+	result.Print(os.Stdout)
+
+	return 0
+}
@@ -2,19 +2,38 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"nexus/api"
+	"nexus/internal/audit"
+	"nexus/internal/balancer"
+	"nexus/internal/certstore"
 	"nexus/internal/config"
+	"nexus/internal/discovery"
+	"nexus/internal/election"
+	"nexus/internal/fault"
+	"nexus/internal/geo"
 	"nexus/internal/healthcheck"
 	lg "nexus/internal/logger"
+	"nexus/internal/maintenance"
+	"nexus/internal/passthrough"
+	"nexus/internal/pressure"
 	px "nexus/internal/proxy"
+	"nexus/internal/ratelimit"
+	"nexus/internal/replay"
 	"nexus/internal/route"
+	"nexus/internal/secrets"
+	"nexus/internal/state"
+	"nexus/internal/stats"
 	"nexus/internal/telemetry"
 
 	"go.opentelemetry.io/otel"
@@ -22,18 +41,45 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "bench":
+			os.Exit(runBench(os.Args[2:]))
+		case "routes":
+			os.Exit(runRoutes(os.Args[2:]))
+		case "replay":
+			os.Exit(runReplay(os.Args[2:]))
+		case "rollback":
+			os.Exit(runRollback(os.Args[2:]))
+		}
+	}
+
 	// Define command line arguments
 	configPath := flag.String("config", "configs/config.yaml", "config file path")
+	runtimeMode := flag.String("runtime", runtimeDefault, "runtime mode: default, or service to control reloads, log reopening and shutdown through the admin API instead of signals")
+	seed := flag.Uint64("seed", 0, "seed of traffic splitting and balancer randomness, so selections can be reproduced while debugging, random when 0")
 	flag.Parse()
+	if err := validateRuntime(*runtimeMode); err != nil {
+		log.Fatal(err)
+	}
 
-	// Load configuration
+	// Load configuration, the secret providers are needed to validate the
+	// references to them
 	cfg := config.NewConfig()
-	if err := config.Validate(*configPath); err != nil {
-		log.Fatalf("config error - %v", err)
-	}
 	if err := cfg.LoadFromFile(*configPath); err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	secretsCfg := cfg.GetSecretsConfig()
+	secretStore, err := secrets.NewStore(secretsCfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize secrets: %v", err)
+	}
+	config.SetSecretResolver(secretStore)
+	if err := config.Validate(*configPath); err != nil {
+		log.Fatalf("config error - %v", err)
+	}
+	go secretStore.Watch(secretsCfg.RefreshInterval)
+	defer secretStore.Stop()
 
 	// Initialize configuration watcher
 	configWatcher := config.NewConfigWatcher(*configPath)
@@ -52,9 +98,16 @@ func main() {
 		healthCheckCfg.Timeout,
 		healthCheckCfg.Path)
 	if healthChecker != nil {
+		healthChecker.SetSharedState(healthcheck.NewSharedState(healthCheckCfg.Shared))
+		healthChecker.SetAssumeHealthy(healthCheckCfg.AssumeHealthy)
+		if err := healthChecker.SetTLSConfig(healthCheckCfg.TLS); err != nil {
+			log.Fatalf("Failed to load health check TLS config: %v", err)
+		}
 		for _, server := range cfg.Services {
 			for _, s := range server.Servers {
 				healthChecker.AddServer(s.Address)
+				healthChecker.SetServerConfig(s.Address, server.HealthCheck)
+				healthChecker.SetServerPath(s.Address, s.HealthPath)
 			}
 		}
 		go healthChecker.Start()
@@ -63,18 +116,198 @@ func main() {
 
 	// Initialize reverse proxy
 	router := route.NewRouter(cfg.Routes, cfg.Services)
+	router.SetCacheSize(cfg.GetRouteCacheConfig().Size)
+	if *seed != 0 {
+		router.SetSource(balancer.NewSeededSource(*seed, nil))
+	}
+
+	// Keep servers failing health checks out of rotation
+	var rotation *healthcheck.Rotation
+	if healthChecker != nil {
+		rotation = healthcheck.NewRotation(router, healthChecker)
+	}
+
+	// Send warm-up requests to servers added by reloads before they enter rotation
+	warmup, err := healthcheck.NewWarmup(router, healthCheckCfg.TLS)
+	if err != nil {
+		log.Fatalf("Failed to initialize warm-up: %v", err)
+	}
+
+	// Health check the servers of all services, discovered ones included
+	syncHealth := func() {
+		if healthChecker == nil {
+			return
+		}
+		var addresses []string
+		for _, svc := range router.Services() {
+			for _, s := range svc.Config().Servers {
+				addresses = append(addresses, s.Address)
+			}
+		}
+		healthChecker.UpdateServers(addresses)
+		for _, svc := range router.Services() {
+			cfg := svc.Config()
+			for _, s := range cfg.Servers {
+				healthChecker.SetServerConfig(s.Address, cfg.HealthCheck)
+				healthChecker.SetServerPath(s.Address, s.HealthPath)
+			}
+		}
+		rotation.Sync()
+	}
+
+	// Add the instances services list at their discovery URL to their servers
+	serviceDiscovery := discovery.New(router)
+	serviceDiscovery.OnChange(func() {
+		warmup.Sync()
+		syncHealth()
+	})
+	serviceDiscovery.Sync()
+	defer serviceDiscovery.Stop()
+
+	// Elect the replica doing the work shared by the fleet
+	electionCfg := cfg.GetLeaderElectionConfig()
+	elector := election.New(electionCfg)
+	if elector != nil {
+		go elector.Start()
+		defer elector.Stop()
+	}
+
+	// Push health summaries to the configured webhook on state changes
+	if reporter := healthcheck.NewReporter(healthCheckCfg.Push, healthChecker, func() map[string][]string {
+		services := make(map[string][]string)
+		for name, svc := range router.Services() {
+			for _, server := range svc.Config().Servers {
+				services[name] = append(services[name], server.Address)
+			}
+		}
+		return services
+	}); reporter != nil {
+		healthChecker.SetReporter(reporter)
+		if elector != nil {
+			// Only the leader pushes, a new leader pushes the current summary
+			reporter.SetLeader(elector)
+			elector.OnChange(func(leader bool) {
+				if leader {
+					reporter.Notify()
+				}
+			})
+		}
+		go reporter.Start()
+		defer reporter.Stop()
+	}
 	proxy := px.NewProxy(router)
+	defer proxy.CloseLogs()
+	proxy.SetDedupConfig(cfg.GetDedupConfig())
+	proxy.SetCacheConfig(cfg.GetCacheConfig())
+	proxy.SetLoadShedConfig(cfg.GetLoadShedConfig())
+	if err := proxy.SetMiddleware(cfg.GetMiddlewareConfig()); err != nil {
+		log.Fatalf("Failed to set up middleware: %v", err)
+	}
+	proxy.SetRateLimitStore(ratelimit.NewStore(cfg.GetRateLimitStoreConfig()))
+	trafficStats := stats.NewCollector()
+	proxy.SetStats(trafficStats)
+	if geoCfg := cfg.GetGeoIPConfig(); geoCfg.Database != "" {
+		resolver, err := geo.OpenMaxMind(geoCfg.Database)
+		if err != nil {
+			log.Fatalf("Failed to open GeoIP database: %v", err)
+		}
+		defer resolver.Close()
+		proxy.SetGeoResolver(resolver)
+	}
 	proxy.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
-		logger.Error("Proxy error: %v", err)
+		info, _ := px.ErrorInfoFromRequest(r)
+		country := ""
+		if loc, ok := geo.FromContext(r.Context()); ok {
+			country = loc.Country
+		}
+		logger.Error("Proxy error: %v (route: %s, service: %s, backend: %s, attempts: %d, country: %s, labels: %v)",
+			err, info.Route, info.Service, info.Backend, info.Attempts, country, info.Labels)
+
+		// Requests that reached a backend failed upstream
+		if info.Attempts > 0 {
+			http.Error(w, "Bad gateway", http.StatusBadGateway)
+			return
+		}
 		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
 	})
 
+	// Restore runtime state persisted by a previous run
+	var stateStore *state.Store
+	if statePath := cfg.GetStateConfig().Path; statePath != "" {
+		stateStore = state.NewStore(statePath)
+		persisted, err := stateStore.Load()
+		if err != nil {
+			log.Fatalf("Failed to load runtime state: %v", err)
+		}
+		state.Apply(router, persisted)
+	}
+
+	// Record matched requests for replaying them against other backends
+	recordCfg := cfg.GetRecordConfig()
+	proxy.SetRecordConfig(recordCfg)
+	proxy.SetUpstreamConfig(cfg.GetUpstreamConfig())
+	proxy.SetPathConfig(cfg.GetPathConfig())
+	proxy.SetSmugglingProtection(cfg.GetSmugglingProtectionConfig())
+	proxy.SetSignatureConfig(cfg.GetSignatureConfig())
+	if err := proxy.SetClassification(cfg.GetClassificationRules()); err != nil {
+		log.Fatalf("Failed to set up classification: %v", err)
+	}
+	if err := proxy.SetRealIP(cfg.GetRealIPConfig()); err != nil {
+		log.Fatalf("Failed to set up real IP: %v", err)
+	}
+	if recordCfg.Enabled {
+		recorder, err := replay.NewRecorder(recordCfg.File)
+		if err != nil {
+			log.Fatalf("Failed to open record file: %v", err)
+		}
+		defer recorder.Close()
+		proxy.SetRecorder(recorder)
+	}
+
+	// Sample resource usage to shed load before the proxy becomes unresponsive
+	var pressureMonitor *pressure.Monitor
+	if shedCfg := cfg.GetLoadShedConfig(); shedCfg.Enabled {
+		pressureMonitor = pressure.NewMonitor(shedCfg)
+		proxy.SetPressureMonitor(pressureMonitor)
+		go pressureMonitor.Start()
+		defer pressureMonitor.Stop()
+	}
+
+	// Initialize maintenance window scheduler
+	scheduler := maintenance.NewScheduler(router, healthChecker, 10*time.Second)
+	go scheduler.Start()
+	defer scheduler.Stop()
+
 	// Initialize OpenTelemetry
 	tel, err := telemetry.NewTelemetry(context.Background(), cfg.Telemetry.OpenTelemetry)
 	if err != nil {
 		log.Fatalf("failed to initialize telemetry: %v", err)
 	}
 	defer tel.Shutdown(context.Background())
+	connMetrics := telemetry.NewConnMetrics()
+	if meter := tel.GetMeter(); meter != nil {
+		proxy.SetMeter(meter)
+		configWatcher.SetMeter(meter)
+		if err := connMetrics.Register(meter); err != nil {
+			logger.Error("Failed to register connection metrics: %v", err)
+		}
+	}
+
+	// Export application logs, and access logs of the otel sink, as OpenTelemetry logs
+	if logExporter := tel.GetLogExporter(); logExporter != nil {
+		logger.SetHook(logExporter.LogHook(logger.ToLogLevel(cfg.Telemetry.OpenTelemetry.Logs.Level)))
+		proxy.SetLogExporter(logExporter)
+	}
+
+	// Initialize the statsd emitter, an alternative to OpenTelemetry metrics
+	statsd, err := telemetry.NewStatsD(cfg.Telemetry.StatsD)
+	if err != nil {
+		log.Fatalf("failed to initialize statsd: %v", err)
+	}
+	if statsd != nil {
+		proxy.SetStatsD(statsd)
+		defer statsd.Close()
+	}
 
 	// Configure trace propagator
 	otel.SetTextMapPropagator(
@@ -83,17 +316,156 @@ func main() {
 			propagation.Baggage{},
 		))
 
+	// Load TLS certificates, they are reloaded independently of the main config
+	var certStore *certstore.Store
+	var clientAuth *certstore.ClientAuth
+	tlsCfg := cfg.GetTLSConfig()
+	if tlsCfg.Enabled {
+		certStore, err = certstore.New(tlsCfg.Certificates)
+		if err != nil {
+			log.Fatalf("Failed to load TLS certificates: %v", err)
+		}
+		if meter := tel.GetMeter(); meter != nil {
+			certStore.SetMeter(meter)
+		}
+		reloadInterval := tlsCfg.ReloadInterval
+		if reloadInterval == 0 {
+			reloadInterval = 30 * time.Second
+		}
+		go certStore.Watch(reloadInterval)
+		defer certStore.Stop()
+
+		if tlsCfg.ClientAuth != nil {
+			clientAuth, err = certstore.NewClientAuth(*tlsCfg.ClientAuth)
+			if err != nil {
+				log.Fatalf("Failed to load client CA: %v", err)
+			}
+			go clientAuth.Watch(reloadInterval)
+			defer clientAuth.Stop()
+		}
+	}
+
+	// Open the audit log of admin API changes and config reloads
+	var auditLog *audit.Log
+	auditCfg := cfg.GetAuditConfig()
+	if auditCfg.File != "" {
+		auditLog, err = audit.Open(auditCfg.File)
+		if err != nil {
+			log.Fatalf("Failed to open audit log: %v", err)
+		}
+		defer auditLog.Close()
+		configWatcher.SetAuditLog(auditLog)
+	}
+
+	// Keep the last config files applied for rollbacks
+	snapshotCfg := cfg.GetSnapshotConfig()
+	configWatcher.SetSnapshots(config.NewSnapshotStore(snapshotCfg, *configPath))
+
+	// Initialize the admin API, its server starts with the others
+	var admin *api.Admin
+	adminCfg := cfg.GetAdminConfig()
+	if adminCfg.Enabled {
+		admin = api.NewAdmin(router)
+		admin.SetAuth(adminCfg.Auth)
+		if auditLog != nil {
+			admin.SetAuditLog(auditLog)
+		}
+		admin.SetHealthChecker(healthChecker)
+		admin.SetMaintenance(scheduler)
+		admin.SetStats(trafficStats)
+		admin.SetPool(proxy.Pool())
+		admin.SetCache(proxy.Cache())
+		sampler := route.NewSampler(route.DefaultSampleSize)
+		proxy.SetSampler(sampler)
+		admin.SetSampler(sampler)
+		faults := fault.NewInjector()
+		proxy.SetFaultInjector(faults)
+		admin.SetFaultInjector(faults)
+		admin.SetIntake(proxy)
+		admin.SetRegistrar(serviceDiscovery)
+		if elector != nil {
+			admin.SetLeader(elector)
+		}
+		if stateStore != nil {
+			admin.SetStateStore(stateStore)
+		}
+		if certStore != nil {
+			admin.SetCertStore(certStore)
+		}
+		admin.SetConfigSnapshots(configWatcher)
+	}
+
+	// Forward TLS connections by SNI without terminating them, the server
+	// starts with the others
+	var passthroughServer *passthrough.Server
+	passthroughCfg := cfg.GetPassthroughConfig()
+	if passthroughCfg.ListenAddr != "" {
+		passthroughServer = passthrough.New(passthroughCfg, router)
+	}
+
 	// Register configuration update callback
 	configWatcher.Watch(func(newCfg *config.Config) {
 		logger.Info("Configuration changed, applying updates...")
 
 		// Update routes
-		router.Update(newCfg.Routes, newCfg.Services)
+		if err := router.Update(newCfg.Routes, newCfg.Services); err != nil {
+			logger.Error("Failed to update routes: %v", err)
+		}
+		serviceDiscovery.Sync()
+		warmup.Sync()
+		router.SetCacheSize(newCfg.GetRouteCacheConfig().Size)
+		proxy.SetDedupConfig(newCfg.GetDedupConfig())
+		proxy.SetCacheConfig(newCfg.GetCacheConfig())
+		proxy.SetLoadShedConfig(newCfg.GetLoadShedConfig())
+		if pressureMonitor != nil {
+			pressureMonitor.SetConfig(newCfg.GetLoadShedConfig())
+		} else if newCfg.GetLoadShedConfig().Enabled {
+			logger.Warn("Enabling load shedding requires a restart")
+		}
+		if err := proxy.SetMiddleware(newCfg.GetMiddlewareConfig()); err != nil {
+			logger.Error("Failed to update middleware: %v", err)
+		}
+		proxy.SetRecordConfig(newCfg.GetRecordConfig())
+		proxy.SetUpstreamConfig(newCfg.GetUpstreamConfig())
+		proxy.SetPathConfig(newCfg.GetPathConfig())
+		proxy.SetSmugglingProtection(newCfg.GetSmugglingProtectionConfig())
+		proxy.SetSignatureConfig(newCfg.GetSignatureConfig())
+		if err := proxy.SetClassification(newCfg.GetClassificationRules()); err != nil {
+			logger.Error("Failed to update classification: %v", err)
+		}
+		if err := proxy.SetRealIP(newCfg.GetRealIPConfig()); err != nil {
+			logger.Error("Failed to update real IP: %v", err)
+		}
+		if admin != nil {
+			admin.SetAuth(newCfg.GetAdminConfig().Auth)
+		}
+		if passthroughServer != nil {
+			passthroughServer.SetConfig(newCfg.GetPassthroughConfig())
+		}
+		if newCfg.GetPassthroughConfig().ListenAddr != passthroughCfg.ListenAddr {
+			logger.Warn("Changing the passthrough listen address requires a restart")
+		}
+		if newCfg.GetLeaderElectionConfig() != electionCfg {
+			logger.Warn("Changing leader election requires a restart")
+		}
+		if newCfg.GetAuditConfig().File != auditCfg.File {
+			logger.Warn("Changing the audit log file requires a restart")
+		}
+		if newCfg.GetSnapshotConfig() != snapshotCfg {
+			logger.Warn("Changing config snapshots requires a restart")
+		}
+		if newRecordCfg := newCfg.GetRecordConfig(); newRecordCfg.Enabled && (!recordCfg.Enabled || newRecordCfg.File != recordCfg.File) {
+			logger.Warn("Changing the record file requires a restart")
+		}
 
 		// Update health check
 		if healthChecker != nil {
 			healthChecker.UpdateInterval(newCfg.GetHealthCheckConfig().Interval)
 			healthChecker.UpdateTimeout(newCfg.GetHealthCheckConfig().Timeout)
+			if err := healthChecker.SetTLSConfig(newCfg.GetHealthCheckConfig().TLS); err != nil {
+				logger.Error("Failed to update health check TLS config: %v", err)
+			}
+			syncHealth()
 		}
 
 		// Update log level
@@ -103,22 +475,138 @@ func main() {
 	// Start configuration watcher
 	configWatcher.Start()
 
+	// Reload on SIGHUP and reopen log files on SIGUSR1, or through the admin
+	// API in service mode
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	control := &lifecycle{
+		reload: func() {
+			logger.Info("Reloading configuration")
+			configWatcher.Reload()
+		},
+		reopenLogs: func() {
+			logger.Info("Reopening log files")
+			if err := proxy.ReopenLogs(); err != nil {
+				logger.Error("Failed to reopen log files: %v", err)
+			}
+			if auditLog != nil {
+				if err := auditLog.Reopen(); err != nil {
+					logger.Error("Failed to reopen audit log: %v", err)
+				}
+			}
+		},
+		shutdown: func() {
+			select {
+			case quit <- syscall.SIGTERM:
+			default:
+			}
+		},
+	}
+	if *runtimeMode == runtimeService {
+		if admin != nil {
+			admin.SetLifecycle(control)
+		}
+		stopped := startService(control.shutdown)
+		defer stopped()
+	} else {
+		handleSignals(control.reload, control.reopenLogs)
+	}
+
 	// Start HTTP server
 	server := &http.Server{
 		Addr:    cfg.GetListenAddr(),
 		Handler: proxy,
 	}
 
+	// Start admin API server
+	var adminServer *http.Server
+	if admin != nil {
+		adminServer = &http.Server{
+			Addr:    adminCfg.ListenAddr,
+			Handler: admin,
+		}
+		if adminCfg.TLS != nil {
+			adminCerts, err := certstore.New([]config.CertificateConfig{adminCfg.TLS.Certificate})
+			if err != nil {
+				log.Fatalf("Failed to load admin TLS certificate: %v", err)
+			}
+			if meter := tel.GetMeter(); meter != nil {
+				adminCerts.SetMeter(meter)
+			}
+			go adminCerts.Watch(30 * time.Second)
+			defer adminCerts.Stop()
+
+			adminServer.TLSConfig = &tls.Config{GetCertificate: adminCerts.GetCertificate}
+			if adminCfg.TLS.ClientAuth != nil {
+				adminClientAuth, err := certstore.NewClientAuth(*adminCfg.TLS.ClientAuth)
+				if err != nil {
+					log.Fatalf("Failed to load admin client CA: %v", err)
+				}
+				go adminClientAuth.Watch(30 * time.Second)
+				defer adminClientAuth.Stop()
+				adminClientAuth.Configure(adminServer.TLSConfig)
+			}
+		}
+		go func() {
+			logger.Info("Starting admin API on %s", adminCfg.ListenAddr)
+			ln, err := connMetrics.Listen("admin", adminServer)
+			if err == nil && adminServer.TLSConfig != nil {
+				err = adminServer.ServeTLS(ln, "", "")
+			} else if err == nil {
+				err = adminServer.Serve(ln)
+			}
+			if err != nil && err != http.ErrServerClosed {
+				logger.Error("Admin API error: %v", err)
+			}
+		}()
+	}
+
+	// Start TLS server
+	var tlsServer *http.Server
+	if certStore != nil {
+		tlsConfig := &tls.Config{GetCertificate: certStore.GetCertificate}
+		if clientAuth != nil {
+			clientAuth.Configure(tlsConfig)
+		}
+		tlsServer = &http.Server{
+			Addr:      tlsCfg.ListenAddr,
+			Handler:   proxy,
+			TLSConfig: tlsConfig,
+		}
+		go func() {
+			logger.Info("Starting TLS server on %s", tlsCfg.ListenAddr)
+			ln, err := connMetrics.Listen("https", tlsServer)
+			if err == nil {
+				err = tlsServer.ServeTLS(ln, "", "")
+			}
+			if err != nil && err != http.ErrServerClosed {
+				logger.Fatal("TLS server error: %v", err)
+			}
+		}()
+	}
+
+	// Start TLS passthrough server
+	if passthroughServer != nil {
+		go func() {
+			logger.Info("Starting TLS passthrough server on %s", passthroughCfg.ListenAddr)
+			if err := passthroughServer.ListenAndServe(); err != nil && !errors.Is(err, net.ErrClosed) {
+				logger.Fatal("TLS passthrough server error: %v", err)
+			}
+		}()
+	}
+
 	go func() {
 		logger.Info("Starting server on %s", cfg.GetListenAddr())
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		ln, err := connMetrics.Listen("http", server)
+		if err == nil {
+			err = server.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Fatal("Server error: %v", err)
 		}
 	}()
 
 	// Graceful shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	logger.Info("Shutting down server...")
 
@@ -127,5 +615,20 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		logger.Error("Server shutdown error: %v", err)
 	}
+	if tlsServer != nil {
+		if err := tlsServer.Shutdown(ctx); err != nil {
+			logger.Error("TLS server shutdown error: %v", err)
+		}
+	}
+	if passthroughServer != nil {
+		if err := passthroughServer.Shutdown(ctx); err != nil {
+			logger.Error("TLS passthrough server shutdown error: %v", err)
+		}
+	}
+	if adminServer != nil {
+		if err := adminServer.Shutdown(ctx); err != nil {
+			logger.Error("Admin API shutdown error: %v", err)
+		}
+	}
 	logger.Info("Server exited")
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"nexus/internal/replay"
+)
+
+// runReplay sends requests recorded by a running instance to another target
+// and reports the responses whose status differs from the recorded one, it
+// returns the process exit code
+func runReplay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	file := fs.String("file", "", "record file written by the record config")
+	target := fs.String("target", "", "base URL the requests are sent to")
+	connections := fs.Int("c", 1, "concurrent connections")
+	routes := fs.String("routes", "", "comma separated recorded routes to replay, all when empty")
+	preserveHost := fs.Bool("preserve-host", false, "send the recorded Host header instead of the target host")
+	timeout := fs.Duration("timeout", 10*time.Second, "request timeout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *file == "" || *target == "" {
+		fmt.Fprintln(os.Stderr, "replay: -file and -target are required")
+		return 2
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replay: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	// Interrupting the run still reports the requests replayed so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	opts := replay.Options{
+		Target:       *target,
+		Connections:  *connections,
+		PreserveHost: *preserveHost,
+		Timeout:      *timeout,
+	}
+	if *routes != "" {
+		opts.Routes = strings.Split(*routes, ",")
+	}
+	fmt.Printf("Replaying %s against %s with %d connections...\n", *file, opts.Target, opts.Connections)
+	result, err := replay.Play(ctx, f, opts)
+	result.Print(os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replay: %v\n", err)
+		return 1
+	}
+	if result.Mismatched > 0 {
+		return 1
+	}
+
+	return 0
+}
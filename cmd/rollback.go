@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/secrets"
+)
+
+// runRollback lists the snapshots of a config file, or replaces the file with
+// one of them. A running instance applies the file like any other change, it
+// returns the process exit code
+func runRollback(args []string) int {
+	fs := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	configPath := fs.String("config", "configs/config.yaml", "config file path")
+	to := fs.String("to", "", "snapshot to restore, its name or a unique prefix of it")
+	list := fs.Bool("list", false, "list the snapshots instead of restoring one")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *to == "" && !*list {
+		fmt.Fprintln(os.Stderr, "rollback: -to or -list is required")
+		return 2
+	}
+
+	cfg := config.NewConfig()
+	if err := cfg.LoadFromFile(*configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "rollback: failed to load config: %v\n", err)
+		return 1
+	}
+	store := config.NewSnapshotStore(cfg.GetSnapshotConfig(), *configPath)
+	if store == nil {
+		fmt.Fprintf(os.Stderr, "rollback: %v\n", config.ErrSnapshotsDisabled)
+		return 1
+	}
+
+	if *list {
+		snapshots, err := store.List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rollback: %v\n", err)
+			return 1
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tTIME\tSIZE")
+		for _, snap := range snapshots {
+			fmt.Fprintf(tw, "%s\t%s\t%d\n", snap.Name, snap.Time.Local().Format(time.RFC3339), snap.Size)
+		}
+		if err := tw.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "rollback: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	// The snapshot is validated like the config at startup
+	secretStore, err := secrets.NewStore(cfg.GetSecretsConfig())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rollback: failed to initialize secrets: %v\n", err)
+		return 1
+	}
+	config.SetSecretResolver(secretStore)
+	name, err := config.RestoreSnapshot(store, *to, *configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rollback: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Restored %s to %s\n", name, *configPath)
+
+	return 0
+}
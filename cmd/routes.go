@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+)
+
+// runRoutes prints the compiled route tree of a config file as a table, a
+// Graphviz graph or JSON, it returns the process exit code
+func runRoutes(args []string) int {
+	fs := flag.NewFlagSet("routes", flag.ContinueOnError)
+	configPath := fs.String("config", "configs/config.yaml", "config file path")
+	format := fs.String("format", "text", "output format: text, dot or json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg := config.NewConfig()
+	if err := cfg.LoadFromFile(*configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "routes: failed to load config: %v\n", err)
+		return 1
+	}
+	entries := route.NewRouter(cfg.Routes, cfg.Services).Table()
+
+	var err error
+	switch *format {
+	case "text":
+		err = route.WriteTable(os.Stdout, entries)
+	case "dot":
+		err = route.WriteDOT(os.Stdout, entries)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(entries)
+	default:
+		fmt.Fprintf(os.Stderr, "routes: unsupported format: %s\n", *format)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "routes: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,33 @@
+package main
+
+import "fmt"
+
+// Runtime modes selected by -runtime
+const (
+	// runtimeDefault reloads the config on SIGHUP and reopens logs on SIGUSR1
+	runtimeDefault = "default"
+	// runtimeService ignores SIGHUP and SIGUSR1, the admin API reloads,
+	// reopens logs and shuts down instead. On Windows it also answers the
+	// service control manager
+	runtimeService = "service"
+)
+
+func validateRuntime(mode string) error {
+	switch mode {
+	case runtimeDefault, runtimeService:
+		return nil
+	default:
+		return fmt.Errorf("unsupported runtime mode: %s", mode)
+	}
+}
+
+// lifecycle implements api.Lifecycle with the functions of main
+type lifecycle struct {
+	reload     func()
+	reopenLogs func()
+	shutdown   func()
+}
+
+func (l *lifecycle) Reload()     { l.reload() }
+func (l *lifecycle) ReopenLogs() { l.reopenLogs() }
+func (l *lifecycle) Shutdown()   { l.shutdown() }
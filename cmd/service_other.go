@@ -0,0 +1,8 @@
+//go:build !windows
+
+package main
+
+// startService does nothing outside Windows, services are stopped with SIGTERM
+func startService(shutdown func()) func() {
+	return func() {}
+}
@@ -0,0 +1,66 @@
+//go:build windows
+
+package main
+
+import (
+	lg "nexus/internal/logger"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// serviceHandler answers the service control manager, stop and shutdown
+// requests stop the servers gracefully
+type serviceHandler struct {
+	shutdown func()
+	stopped  chan struct{}
+}
+
+// Execute implements the svc.Handler interface
+func (h *serviceHandler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				h.shutdown()
+				<-h.stopped
+				return false, 0
+			}
+		case <-h.stopped:
+			// Shut down through the admin API
+			return false, 0
+		}
+	}
+}
+
+// startService runs nexus under the service control manager when it started
+// the process. The returned function reports the servers stopped, it must be
+// called before exiting
+func startService(shutdown func()) func() {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		lg.GetInstance().Error("Failed to detect the Windows service environment: %v", err)
+	}
+	if !isService {
+		return func() {}
+	}
+
+	h := &serviceHandler{shutdown: shutdown, stopped: make(chan struct{})}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := svc.Run("nexus", h); err != nil {
+			lg.GetInstance().Error("Windows service error: %v", err)
+			shutdown()
+		}
+	}()
+
+	return func() {
+		close(h.stopped)
+		<-done
+	}
+}
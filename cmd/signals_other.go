@@ -0,0 +1,7 @@
+//go:build !unix
+
+package main
+
+// handleSignals does nothing where SIGHUP and SIGUSR1 don't exist, the config
+// watcher still picks up changes
+func handleSignals(reload, reopenLogs func()) {}
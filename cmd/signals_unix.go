@@ -0,0 +1,26 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handleSignals reloads the config on SIGHUP, without waiting for the watcher
+// to notice the change, and reopens log files on SIGUSR1 for logrotate
+func handleSignals(reload, reopenLogs func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGUSR1)
+	go func() {
+		for sig := range signals {
+			switch sig {
+			case syscall.SIGHUP:
+				reload()
+			case syscall.SIGUSR1:
+				reopenLogs()
+			}
+		}
+	}()
+}
@@ -0,0 +1,145 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	lg "nexus/internal/logger"
+)
+
+// Entry is one change recorded in the audit log
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Actor     string    `json:"actor"`            // Admin API principal, or the config file of reloads
+	Remote    string    `json:"remote,omitempty"` // Client address of admin API requests
+	Action    string    `json:"action"`           // Method and path of admin API requests, or config.reload
+	Status    int       `json:"status,omitempty"`
+	Request   string    `json:"request,omitempty"`    // Small request bodies
+	Changed   []string  `json:"changed,omitempty"`    // Top-level config sections changed by reloads
+	OldDigest string    `json:"old_digest,omitempty"` // SHA-256 of the state before the change
+	NewDigest string    `json:"new_digest,omitempty"` // SHA-256 of the state after the change
+	Error     string    `json:"error,omitempty"`      // Why the change was not applied
+}
+
+// Query selects audit log entries, zero fields match every entry
+type Query struct {
+	Since  time.Time
+	Actor  string
+	Action string
+	Limit  int // Latest entries returned
+}
+
+// Log appends entries to a JSON lines file, existing entries are never rewritten
+type Log struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// Open opens the audit log file for appending, creating it when missing
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Log{path: path, file: file}, nil
+}
+
+// Path returns the audit log file path
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Record appends an entry, stamped with the current time when it has none
+func (l *Log) Record(e Entry) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		lg.GetInstance().Error("Failed to encode audit entry: %v", err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		lg.GetInstance().Error("Failed to write audit log %s: %v", l.path, err)
+	}
+}
+
+// Query returns the entries matching q, oldest first
+func (l *Log) Query(q Query) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := make([]Entry, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// A torn last line of a crash, the rest of the log stays readable
+			continue
+		}
+		if e.Time.Before(q.Since) || (q.Actor != "" && e.Actor != q.Actor) || (q.Action != "" && e.Action != q.Action) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[len(entries)-q.Limit:]
+	}
+	return entries, nil
+}
+
+// Reopen replaces the audit log file by a new one at the same path, after it
+// was moved away by log rotation. Queries only see the entries of the new file
+func (l *Log) Reopen() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	previous := l.file
+	l.file = file
+	return previous.Close()
+}
+
+// Close closes the audit log file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
+
+// Digest returns the hex SHA-256 of data
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
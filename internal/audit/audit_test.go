@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+	log, err := Open(path)
+	require.NoError(t, err)
+
+	start := time.Now()
+	log.Record(Entry{Time: start.Add(-time.Hour), Actor: "deploy", Action: "POST /admin/routes", Status: 201})
+	log.Record(Entry{Actor: "user ops", Action: "POST /admin/backends/drain", Status: 200})
+	log.Record(Entry{Actor: "deploy", Action: "DELETE /admin/routes/api", Status: 200})
+	require.NoError(t, log.Close())
+
+	// Reopening appends to the existing entries
+	log, err = Open(path)
+	require.NoError(t, err)
+	defer log.Close()
+	log.Record(Entry{Actor: "config file config.yaml", Action: "config.reload", OldDigest: Digest([]byte("a")), NewDigest: Digest([]byte("b"))})
+
+	entries, err := log.Query(Query{})
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+	assert.Equal(t, "POST /admin/routes", entries[0].Action)
+	assert.False(t, entries[1].Time.IsZero())
+
+	entries, err = log.Query(Query{Actor: "deploy"})
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	entries, err = log.Query(Query{Since: start.Add(-time.Minute), Action: "config.reload"})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.NotEqual(t, entries[0].OldDigest, entries[0].NewDigest)
+
+	entries, err = log.Query(Query{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "DELETE /admin/routes/api", entries[0].Action)
+
+	// Torn lines are skipped
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"actor": "trunc`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	entries, err = log.Query(Query{})
+	require.NoError(t, err)
+	assert.Len(t, entries, 4)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
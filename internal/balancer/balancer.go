@@ -2,9 +2,13 @@ package balancer
 
 import (
 	"context"
+	"errors"
 	"nexus/internal/config"
 )
 
+// ErrNoServers is returned by Next when the balancer has no server to select
+var ErrNoServers = errors.New("no servers available")
+
 // Balancer interface defines the basic behavior of a load balancer
 type Balancer interface {
 	Next(ctx context.Context) (string, error)
@@ -12,6 +16,20 @@ type Balancer interface {
 	Remove(server string)
 	UpdateServers(servers []config.ServerConfig)
 	Type() string
+
+	// Failed records an error of a request sent to a server returned by Next
+	Failed(server string)
+	// Stats returns the selections, errors and last selection per server
+	Stats() map[string]ServerStats
+	// SetSource replaces the randomness and clock of the balancer,
+	// DefaultSource until set
+	SetSource(src Source)
+}
+
+// Tracker is implemented by balancers counting the requests in flight per
+// server, Done is called once a request sent to a server returned by Next ended
+type Tracker interface {
+	Done(server string)
 }
 
 // NewBalancer creates a new load balancer based on the type
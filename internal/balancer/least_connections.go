@@ -2,93 +2,139 @@ package balancer
 
 import (
 	"context"
-	"errors"
-	"math"
 	"nexus/internal/config"
 	"sync"
+	"sync/atomic"
 )
 
+// shardSize is the number of servers scanned per shard. Pools up to two shards
+// are scanned completely, larger pools compare the least loaded server of two
+// random shards, so selection cost doesn't grow with the pool
+const shardSize = 32
+
 // LeastConnectionsServer represents a server with its connection count
 type LeastConnectionsServer struct {
 	Server    string
 	ConnCount int
 }
 
-// LeastConnectionsBalancer implements least connections load balancing algorithm
+// lcServer is a server with an atomic connection counter. The padding keeps
+// counters of neighbouring servers on separate cache lines
+type lcServer struct {
+	address  string
+	conns    atomic.Int64
+	counters *serverCounters // Shared by the pools the server is part of
+	_        [32]byte
+}
+
+// lcPool is an immutable snapshot of the server list, replaced on changes
+type lcPool struct {
+	servers []*lcServer
+	index   map[string]*lcServer
+}
+
+// LeastConnectionsBalancer implements least connections load balancing algorithm.
+// Selection and Done are lock-free, the mutex only serializes pool changes
 type LeastConnectionsBalancer struct {
-	mu      sync.RWMutex
-	servers []LeastConnectionsServer
+	mu     sync.Mutex
+	pool   atomic.Pointer[lcPool]
+	source atomic.Pointer[Source]
 }
 
 // NewLeastConnectionsBalancer creates a new least connections load balancer
 func NewLeastConnectionsBalancer() *LeastConnectionsBalancer {
-	return &LeastConnectionsBalancer{
-		servers: make([]LeastConnectionsServer, 0),
+	b := &LeastConnectionsBalancer{}
+	b.pool.Store(newLCPool(nil))
+	b.SetSource(DefaultSource)
+	return b
+}
+
+func newLCPool(servers []*lcServer) *lcPool {
+	pool := &lcPool{
+		servers: servers,
+		index:   make(map[string]*lcServer, len(servers)),
+	}
+	for _, server := range servers {
+		if _, ok := pool.index[server.address]; !ok {
+			pool.index[server.address] = server
+		}
 	}
+	return pool
 }
 
 // Next returns the next available server address
 func (b *LeastConnectionsBalancer) Next(ctx context.Context) (string, error) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
-	if len(b.servers) == 0 {
-		return "", errors.New("no servers available")
+	servers := b.pool.Load().servers
+	if len(servers) == 0 {
+		return "", ErrNoServers
 	}
 
-	var selectedServer *LeastConnectionsServer
-	minConnections := math.MaxInt32
-	allEqual := true
-	firstConnCount := b.servers[0].ConnCount
-
-	for i := range b.servers {
-		server := &b.servers[i]
-
-		// Check if all servers have the same connection count
-		if server.ConnCount != firstConnCount {
-			allEqual = false
+	src := *b.source.Load()
+	var selected int
+	if shards := (len(servers) + shardSize - 1) / shardSize; shards <= 2 {
+		selected = leastLoaded(servers, 0, len(servers))
+	} else {
+		first := src.IntN(shards)
+		second := src.IntN(shards - 1)
+		if second >= first {
+			second++
 		}
-
-		// Find the server with the least connections
-		if server.ConnCount < minConnections {
-			minConnections = server.ConnCount
-			selectedServer = server
+		if first > second {
+			first, second = second, first
+		}
+		selected = leastLoaded(servers, first*shardSize, min((first+1)*shardSize, len(servers)))
+		other := leastLoaded(servers, second*shardSize, min((second+1)*shardSize, len(servers)))
+		if servers[other].conns.Load() < servers[selected].conns.Load() {
+			selected = other
 		}
-	}
-
-	// If all servers have the same connection count, return the first server
-	if allEqual {
-		selectedServer = &b.servers[0]
 	}
 
 	// Increment connection count for selected server
-	selectedServer.ConnCount++
+	server := servers[selected]
+	server.conns.Add(1)
+	server.counters.selected(src.Now())
+
+	traceBackend(ctx, server.address, selected)
 
-	traceBackend(ctx, selectedServer.Server, 0)
+	return server.address, nil
+}
 
-	return selectedServer.Server, nil
+// leastLoaded returns the index of the first server with the fewest
+// connections in servers[from:to]
+func leastLoaded(servers []*lcServer, from, to int) int {
+	selected := from
+	minConnections := servers[from].conns.Load()
+	for i := from + 1; i < to; i++ {
+		if conns := servers[i].conns.Load(); conns < minConnections {
+			selected, minConnections = i, conns
+		}
+	}
+	return selected
 }
 
 // Add adds a new server address
 func (b *LeastConnectionsBalancer) Add(server string) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
-	b.servers = append(b.servers, LeastConnectionsServer{
-		Server:    server,
-		ConnCount: 0,
-	})
+	b.AddWithConnCount(server, 0)
 }
 
-// AddWithConnCount adds a new server address with a specific connection count
+// AddWithConnCount adds a new server address with a specific connection count.
+// Servers already in the pool are left as they are, so their in-flight
+// connections keep being counted
 func (b *LeastConnectionsBalancer) AddWithConnCount(server string, connCount int) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	b.servers = append(b.servers, LeastConnectionsServer{
-		Server:    server,
-		ConnCount: connCount,
-	})
+	pool := b.pool.Load()
+	if _, ok := pool.index[server]; ok {
+		return
+	}
+	added := &lcServer{address: server, counters: &serverCounters{}}
+	added.conns.Store(int64(connCount))
+
+	current := pool.servers
+	servers := make([]*lcServer, 0, len(current)+1)
+	servers = append(servers, current...)
+	b.pool.Store(newLCPool(append(servers, added)))
 }
 
 // Remove removes a server address
@@ -96,43 +142,90 @@ func (b *LeastConnectionsBalancer) Remove(server string) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	for i, s := range b.servers {
-		if s.Server == server {
-			b.servers = append(b.servers[:i], b.servers[i+1:]...)
+	current := b.pool.Load().servers
+	for i, s := range current {
+		if s.address == server {
+			servers := make([]*lcServer, 0, len(current)-1)
+			servers = append(servers, current[:i]...)
+			b.pool.Store(newLCPool(append(servers, current[i+1:]...)))
 			break
 		}
 	}
 }
 
+// Failed records an error of a request sent to a server returned by Next
+func (b *LeastConnectionsBalancer) Failed(server string) {
+	if s, ok := b.pool.Load().index[server]; ok {
+		s.counters.errors.Add(1)
+	}
+}
+
+// Stats returns the selection statistics of the servers
+func (b *LeastConnectionsBalancer) Stats() map[string]ServerStats {
+	servers := b.pool.Load().servers
+	stats := make(map[string]ServerStats, len(servers))
+	for _, server := range servers {
+		if _, ok := stats[server.address]; !ok {
+			stats[server.address] = server.counters.stats()
+		}
+	}
+	return stats
+}
+
+// SetSource sets the randomness of the shards compared in large pools and
+// the clock of the selection statistics
+func (b *LeastConnectionsBalancer) SetSource(src Source) {
+	b.source.Store(&src)
+}
+
 // Done decrements the connection count for a server
 func (b *LeastConnectionsBalancer) Done(server string) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+	s, ok := b.pool.Load().index[server]
+	if !ok {
+		return
+	}
 
-	for i, s := range b.servers {
-		if s.Server == server && s.ConnCount > 0 {
-			b.servers[i].ConnCount--
-			break
+	for {
+		conns := s.conns.Load()
+		if conns <= 0 || s.conns.CompareAndSwap(conns, conns-1) {
+			return
 		}
 	}
 }
 
-// UpdateServers updates the servers in the balancer
+// UpdateServers updates the servers in the balancer. Servers already in the
+// pool are kept with their connection counts and counters, so requests in
+// flight during the update are still accounted for when they're done
 func (b *LeastConnectionsBalancer) UpdateServers(servers []config.ServerConfig) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	b.servers = make([]LeastConnectionsServer, 0, len(servers))
+	pool := b.pool.Load()
+	kept := make(map[string]*lcServer, len(servers))
+	updated := make([]*lcServer, 0, len(servers))
 	for _, server := range servers {
-		b.servers = append(b.servers, LeastConnectionsServer{
-			Server:    server.Address,
-			ConnCount: 0,
-		})
+		s, ok := kept[server.Address]
+		if !ok {
+			if s, ok = pool.index[server.Address]; !ok {
+				s = &lcServer{address: server.Address, counters: &serverCounters{}}
+			}
+			kept[server.Address] = s
+		}
+		updated = append(updated, s)
 	}
+	b.pool.Store(newLCPool(updated))
 }
 
 func (b *LeastConnectionsBalancer) GetServers() []LeastConnectionsServer {
-	return b.servers
+	current := b.pool.Load().servers
+	servers := make([]LeastConnectionsServer, 0, len(current))
+	for _, server := range current {
+		servers = append(servers, LeastConnectionsServer{
+			Server:    server.address,
+			ConnCount: int(server.conns.Load()),
+		})
+	}
+	return servers
 }
 
 func (b *LeastConnectionsBalancer) Type() string {
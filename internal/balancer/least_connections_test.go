@@ -2,6 +2,8 @@ package balancer
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
 
 	"nexus/internal/config"
@@ -132,3 +134,166 @@ func TestLeastConnections_UpdateServers(t *testing.T) {
 		})
 	}
 }
+
+func TestLeastConnections_ConcurrentNextDone(t *testing.T) {
+	balancer := NewLeastConnectionsBalancer()
+	for i := 0; i < 100; i++ {
+		balancer.Add(fmt.Sprintf("http://server%d:8080", i))
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				server, err := balancer.Next(context.Background())
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+					return
+				}
+				balancer.Done(server)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, server := range balancer.GetServers() {
+		if server.ConnCount != 0 {
+			t.Errorf("Expected no connections on %s, got %d", server.Server, server.ConnCount)
+		}
+	}
+}
+
+func TestLeastConnections_LargePoolSpreadsLoad(t *testing.T) {
+	balancer := NewLeastConnectionsBalancer()
+	for i := 0; i < 256; i++ {
+		balancer.Add(fmt.Sprintf("http://server%d:8080", i))
+	}
+
+	// Without Done every pick adds a connection, the comparison of shard
+	// minimums keeps the servers within a few connections of each other
+	for i := 0; i < 256*10; i++ {
+		if _, err := balancer.Next(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	lowest, highest := int(^uint(0)>>1), 0
+	for _, server := range balancer.GetServers() {
+		lowest = min(lowest, server.ConnCount)
+		highest = max(highest, server.ConnCount)
+	}
+	if highest-lowest > 5 {
+		t.Errorf("Expected connections within 5 of each other, got %d to %d", lowest, highest)
+	}
+}
+
+func TestLeastConnections_RemoveKeepsCounts(t *testing.T) {
+	balancer := NewLeastConnectionsBalancer()
+	balancer.AddWithConnCount("http://server1:8080", 3)
+	balancer.AddWithConnCount("http://server2:8080", 1)
+	balancer.AddWithConnCount("http://server3:8080", 2)
+
+	balancer.Remove("http://server2:8080")
+	server, err := balancer.Next(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if server != "http://server3:8080" {
+		t.Errorf("Expected http://server3:8080, got %s", server)
+	}
+
+	// Done on a removed server is ignored
+	balancer.Done("http://server2:8080")
+	servers := balancer.GetServers()
+	if len(servers) != 2 || servers[0].ConnCount != 3 || servers[1].ConnCount != 3 {
+		t.Errorf("Unexpected servers after remove: %+v", servers)
+	}
+}
+
+func TestLeastConnections_UpdateWhileInFlight(t *testing.T) {
+	balancer := NewLeastConnectionsBalancer()
+	balancer.UpdateServers([]config.ServerConfig{
+		{Address: "http://server1:8080"},
+		{Address: "http://server2:8080"},
+	})
+
+	// Two requests in flight on each server
+	var inFlight []string
+	for i := 0; i < 4; i++ {
+		server, err := balancer.Next(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		inFlight = append(inFlight, server)
+	}
+
+	balancer.UpdateServers([]config.ServerConfig{
+		{Address: "http://server1:8080"},
+		{Address: "http://server2:8080"},
+		{Address: "http://server3:8080"},
+	})
+
+	// The new server is idle and takes the next requests until it catches up
+	for i := 0; i < 2; i++ {
+		server, err := balancer.Next(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if server != "http://server3:8080" {
+			t.Errorf("Expected http://server3:8080, got %s", server)
+		}
+	}
+
+	for _, server := range inFlight {
+		balancer.Done(server)
+	}
+	for _, server := range balancer.GetServers() {
+		expected := 0
+		if server.Server == "http://server3:8080" {
+			expected = 2
+		}
+		if server.ConnCount != expected {
+			t.Errorf("Expected %d connections on %s, got %d", expected, server.Server, server.ConnCount)
+		}
+	}
+}
+
+func TestLeastConnections_AddExisting(t *testing.T) {
+	balancer := NewLeastConnectionsBalancer()
+	balancer.AddWithConnCount("http://server1:8080", 2)
+	balancer.AddWithConnCount("http://server1:8080", 0)
+	balancer.Add("http://server2:8080")
+
+	servers := balancer.GetServers()
+	if len(servers) != 2 || servers[0].ConnCount != 2 || servers[1].ConnCount != 0 {
+		t.Fatalf("Unexpected servers after adding an existing one: %+v", servers)
+	}
+
+	balancer.Done("http://server1:8080")
+	balancer.Done("http://server1:8080")
+	if conns := balancer.GetServers()[0].ConnCount; conns != 0 {
+		t.Errorf("Expected no connections on http://server1:8080, got %d", conns)
+	}
+}
+
+func BenchmarkLeastConnections_Parallel(b *testing.B) {
+	for _, size := range []int{4, 128} {
+		b.Run(fmt.Sprintf("servers=%d", size), func(b *testing.B) {
+			balancer := NewLeastConnectionsBalancer()
+			for i := 0; i < size; i++ {
+				balancer.Add(fmt.Sprintf("http://server%d:8080", i))
+			}
+
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				ctx := context.Background()
+				for pb.Next() {
+					server, _ := balancer.Next(ctx)
+					balancer.Done(server)
+				}
+			})
+		})
+	}
+}
@@ -2,23 +2,26 @@ package balancer
 
 import (
 	"context"
-	"errors"
 	"nexus/internal/config"
 	"sync"
 )
 
 // RoundRobinBalancer implements round-robin load balancing algorithm
 type RoundRobinBalancer struct {
-	mu      sync.RWMutex
-	servers []string
-	index   int
+	mu       sync.RWMutex
+	servers  []string
+	index    int
+	counters counterSet
+	source   Source
 }
 
 // NewRoundRobinBalancer creates a new round-robin load balancer
 func NewRoundRobinBalancer() *RoundRobinBalancer {
 	return &RoundRobinBalancer{
-		servers: make([]string, 0),
-		index:   0,
+		servers:  make([]string, 0),
+		index:    0,
+		counters: make(counterSet),
+		source:   DefaultSource,
 	}
 }
 
@@ -28,11 +31,12 @@ func (b *RoundRobinBalancer) Next(ctx context.Context) (string, error) {
 	defer b.mu.Unlock()
 
 	if len(b.servers) == 0 {
-		return "", errors.New("no servers available")
+		return "", ErrNoServers
 	}
 
 	server := b.servers[b.index]
 	b.index = (b.index + 1) % len(b.servers)
+	b.counters.get(server).selected(b.source.Now())
 
 	traceBackend(ctx, server, b.index)
 
@@ -58,6 +62,7 @@ func (b *RoundRobinBalancer) Remove(server string) {
 			if b.index >= len(b.servers) {
 				b.index = 0
 			}
+			b.counters.retain(b.servers)
 			break
 		}
 	}
@@ -75,6 +80,33 @@ func (b *RoundRobinBalancer) UpdateServers(servers []config.ServerConfig) {
 
 	b.servers = newServers
 	b.index = 0
+	b.counters.retain(newServers)
+}
+
+// Failed records an error of a request sent to a server returned by Next
+func (b *RoundRobinBalancer) Failed(server string) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if c, ok := b.counters[server]; ok {
+		c.errors.Add(1)
+	}
+}
+
+// Stats returns the selection statistics of the servers
+func (b *RoundRobinBalancer) Stats() map[string]ServerStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.counters.stats(b.servers)
+}
+
+// SetSource sets the clock of the selection statistics
+func (b *RoundRobinBalancer) SetSource(src Source) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.source = src
 }
 
 func (b *RoundRobinBalancer) GetServers() []string {
@@ -2,7 +2,9 @@ package balancer
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"nexus/internal/config"
 )
@@ -81,10 +83,11 @@ func TestRoundRobinBalancer(t *testing.T) {
 }
 
 func TestEmptyBalancer(t *testing.T) {
-	balancer := NewRoundRobinBalancer()
-	_, err := balancer.Next(context.Background())
-	if err == nil {
-		t.Error("Expected error when no servers are available")
+	for _, balancerType := range []string{"round_robin", "least_connections", "weighted_round_robin"} {
+		_, err := NewBalancer(balancerType).Next(context.Background())
+		if !errors.Is(err, ErrNoServers) {
+			t.Errorf("Expected ErrNoServers from %s balancer without servers, got %v", balancerType, err)
+		}
 	}
 }
 
@@ -145,3 +148,56 @@ func TestBalancer_UpdateRoundRobinServers(t *testing.T) {
 		})
 	}
 }
+
+func TestBalancer_Stats(t *testing.T) {
+	servers := []config.ServerConfig{
+		{Address: "http://server1:8080", Weight: 3},
+		{Address: "http://server2:8080", Weight: 1},
+	}
+	testCases := []struct {
+		balancerType string
+		expected     map[string]uint64 // Selections per server out of 400
+	}{
+		{balancerType: "round_robin", expected: map[string]uint64{"http://server1:8080": 200, "http://server2:8080": 200}},
+		{balancerType: "weighted_round_robin", expected: map[string]uint64{"http://server1:8080": 300, "http://server2:8080": 100}},
+		{balancerType: "least_connections", expected: map[string]uint64{"http://server1:8080": 200, "http://server2:8080": 200}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.balancerType, func(t *testing.T) {
+			balancer := NewBalancer(tc.balancerType)
+			balancer.UpdateServers(servers)
+			before := time.Now()
+			for i := 0; i < 400; i++ {
+				if _, err := balancer.Next(context.Background()); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+			balancer.Failed("http://server2:8080")
+			balancer.Failed("http://unknown:8080")
+
+			stats := balancer.Stats()
+			if len(stats) != len(servers) {
+				t.Fatalf("Expected stats of %d servers, got %v", len(servers), stats)
+			}
+			for server, selections := range tc.expected {
+				if stats[server].Selections != selections {
+					t.Errorf("Expected %d selections of %s, got %d", selections, server, stats[server].Selections)
+				}
+				if stats[server].LastSelected.Before(before) {
+					t.Errorf("Expected %s to be selected after %v, got %v", server, before, stats[server].LastSelected)
+				}
+			}
+			if stats["http://server1:8080"].Errors != 0 || stats["http://server2:8080"].Errors != 1 {
+				t.Errorf("Unexpected errors: %v", stats)
+			}
+
+			// Counters of servers kept by an update survive it
+			balancer.UpdateServers(servers[1:])
+			stats = balancer.Stats()
+			if len(stats) != 1 || stats["http://server2:8080"].Selections != tc.expected["http://server2:8080"] {
+				t.Errorf("Unexpected stats after update: %v", stats)
+			}
+		})
+	}
+}
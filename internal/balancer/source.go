@@ -0,0 +1,59 @@
+package balancer
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Source provides the randomness and time balancers and traffic splitting
+// depend on. Injecting a seeded source makes their selections deterministic,
+// e.g. in tests or to replay a traffic pattern while debugging
+type Source interface {
+	// IntN returns a random number in [0, n), n > 0
+	IntN(n int) int
+	Now() time.Time
+}
+
+// DefaultSource draws from the global source of math/rand/v2 and the wall
+// clock. The global source is per-thread, so concurrent selections don't
+// contend on a lock
+var DefaultSource Source = defaultSource{}
+
+type defaultSource struct{}
+
+func (defaultSource) IntN(n int) int {
+	return rand.IntN(n)
+}
+
+func (defaultSource) Now() time.Time {
+	return time.Now()
+}
+
+// seededSource serializes draws from a seeded generator, the sequence only
+// depends on the seed and the order of the draws
+type seededSource struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+	now func() time.Time
+}
+
+// NewSeededSource returns a source whose random numbers are determined by the
+// seed, it is safe for concurrent use. The clock is time.Now when now is nil
+func NewSeededSource(seed uint64, now func() time.Time) Source {
+	if now == nil {
+		now = time.Now
+	}
+	return &seededSource{rnd: rand.New(rand.NewPCG(seed, seed)), now: now}
+}
+
+func (s *seededSource) IntN(n int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rnd.IntN(n)
+}
+
+func (s *seededSource) Now() time.Time {
+	return s.now()
+}
@@ -0,0 +1,59 @@
+package balancer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSeededSource(t *testing.T) {
+	draw := func(seed uint64) []int {
+		src := NewSeededSource(seed, nil)
+		numbers := make([]int, 0, 10)
+		for i := 0; i < 10; i++ {
+			numbers = append(numbers, src.IntN(1000))
+		}
+		return numbers
+	}
+
+	if first, second := draw(1), draw(1); fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Errorf("Expected the same numbers from the same seed, got %v and %v", first, second)
+	}
+	if first, other := draw(1), draw(2); fmt.Sprint(first) == fmt.Sprint(other) {
+		t.Errorf("Expected different numbers from different seeds, got %v", first)
+	}
+}
+
+func TestLeastConnections_SeededSource(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	picks := func() []string {
+		balancer := NewLeastConnectionsBalancer()
+		balancer.SetSource(NewSeededSource(7, func() time.Time { return now }))
+		for i := 0; i < 128; i++ {
+			balancer.Add(fmt.Sprintf("http://server%d:8080", i))
+		}
+
+		// Done keeps every count at zero, so the random shards decide
+		servers := make([]string, 0, 20)
+		for i := 0; i < 20; i++ {
+			server, err := balancer.Next(context.Background())
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			balancer.Done(server)
+			servers = append(servers, server)
+		}
+
+		for server, stats := range balancer.Stats() {
+			if stats.Selections > 0 && !stats.LastSelected.Equal(now) {
+				t.Errorf("Expected %s last selected at %v, got %v", server, now, stats.LastSelected)
+			}
+		}
+		return servers
+	}
+
+	if first, second := picks(), picks(); fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Errorf("Expected the same selections from the same seed, got %v and %v", first, second)
+	}
+}
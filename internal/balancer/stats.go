@@ -0,0 +1,78 @@
+package balancer
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ServerStats are the selections of a server by a balancer and the errors of
+// the requests sent to it
+type ServerStats struct {
+	Selections   uint64
+	Errors       uint64
+	LastSelected time.Time // Zero when the server was never selected
+}
+
+// serverCounters are updated atomically, so balancers record selections
+// without extending their critical sections
+type serverCounters struct {
+	selections   atomic.Uint64
+	errors       atomic.Uint64
+	lastSelected atomic.Int64 // Unix nanoseconds
+}
+
+func (c *serverCounters) selected(now time.Time) {
+	c.selections.Add(1)
+	c.lastSelected.Store(now.UnixNano())
+}
+
+func (c *serverCounters) stats() ServerStats {
+	stats := ServerStats{
+		Selections: c.selections.Load(),
+		Errors:     c.errors.Load(),
+	}
+	if last := c.lastSelected.Load(); last != 0 {
+		stats.LastSelected = time.Unix(0, last)
+	}
+	return stats
+}
+
+// counterSet holds the counters of the servers of a balancer, callers
+// serialize access to it
+type counterSet map[string]*serverCounters
+
+// get returns the counters of a server, creating them on first use
+func (s counterSet) get(server string) *serverCounters {
+	c, ok := s[server]
+	if !ok {
+		c = &serverCounters{}
+		s[server] = c
+	}
+	return c
+}
+
+// retain drops the counters of servers no longer balanced
+func (s counterSet) retain(servers []string) {
+	keep := make(map[string]struct{}, len(servers))
+	for _, server := range servers {
+		keep[server] = struct{}{}
+	}
+	for server := range s {
+		if _, ok := keep[server]; !ok {
+			delete(s, server)
+		}
+	}
+}
+
+// stats returns the statistics of the servers, zero for servers never selected
+func (s counterSet) stats(servers []string) map[string]ServerStats {
+	stats := make(map[string]ServerStats, len(servers))
+	for _, server := range servers {
+		if c, ok := s[server]; ok {
+			stats[server] = c.stats()
+		} else {
+			stats[server] = ServerStats{}
+		}
+	}
+	return stats
+}
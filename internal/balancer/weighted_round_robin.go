@@ -2,7 +2,6 @@ package balancer
 
 import (
 	"context"
-	"errors"
 	"nexus/internal/config"
 	"sync"
 )
@@ -20,6 +19,8 @@ type WeightedRoundRobinBalancer struct {
 	index         int
 	current       int // current weight
 	defaultWeight int // Default weight
+	counters      counterSet
+	source        Source
 }
 
 // NewWeightedRoundRobinBalancer creates a new weighted round-robin load balancer
@@ -29,6 +30,8 @@ func NewWeightedRoundRobinBalancer() *WeightedRoundRobinBalancer {
 		index:         0,
 		current:       0,
 		defaultWeight: 1, // Default weight is 1
+		counters:      make(counterSet),
+		source:        DefaultSource,
 	}
 }
 
@@ -38,13 +41,14 @@ func (b *WeightedRoundRobinBalancer) Next(ctx context.Context) (string, error) {
 	defer b.mu.Unlock()
 
 	if len(b.servers) == 0 {
-		return "", errors.New("no servers available")
+		return "", ErrNoServers
 	}
 
 	for {
 		server := b.servers[b.index]
 		if b.current < server.Weight {
 			b.current++
+			b.counters.get(server.Server).selected(b.source.Now())
 
 			traceBackend(ctx, server.Server, b.index)
 
@@ -89,6 +93,7 @@ func (b *WeightedRoundRobinBalancer) Remove(server string) {
 			if b.index >= len(b.servers) {
 				b.index = 0
 			}
+			b.counters.retain(b.addresses())
 			break
 		}
 	}
@@ -108,6 +113,41 @@ func (b *WeightedRoundRobinBalancer) UpdateServers(servers []config.ServerConfig
 	}
 	b.current = 0
 	b.index = 0
+	b.counters.retain(b.addresses())
+}
+
+// Failed records an error of a request sent to a server returned by Next
+func (b *WeightedRoundRobinBalancer) Failed(server string) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if c, ok := b.counters[server]; ok {
+		c.errors.Add(1)
+	}
+}
+
+// Stats returns the selection statistics of the servers
+func (b *WeightedRoundRobinBalancer) Stats() map[string]ServerStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.counters.stats(b.addresses())
+}
+
+// SetSource sets the clock of the selection statistics
+func (b *WeightedRoundRobinBalancer) SetSource(src Source) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.source = src
+}
+
+func (b *WeightedRoundRobinBalancer) addresses() []string {
+	addresses := make([]string, 0, len(b.servers))
+	for _, server := range b.servers {
+		addresses = append(addresses, server.Server)
+	}
+	return addresses
 }
 
 func (b *WeightedRoundRobinBalancer) GetServers() []WeightedServer {
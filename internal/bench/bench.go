@@ -0,0 +1,264 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Options configures a synthetic load run
+type Options struct {
+	Target      string        // Base URL of the proxy
+	Method      string        // Request method (default GET)
+	Paths       []string      // Request paths, used in turn (default /)
+	Headers     http.Header   // Headers added to every request
+	Connections int           // Concurrent clients (default 1)
+	RPS         int           // Request rate over all clients, 0 means as fast as possible
+	Duration    time.Duration // Run length, 0 means until Requests are sent
+	Requests    int           // Total requests, 0 means until Duration elapses
+	Timeout     time.Duration // Request timeout (default 10s)
+}
+
+// Result summarizes a load run
+type Result struct {
+	Total          int
+	Success        int
+	Failed         int
+	Duration       time.Duration
+	Min            time.Duration
+	Max            time.Duration
+	Avg            time.Duration
+	P50            time.Duration
+	P90            time.Duration
+	P95            time.Duration
+	P99            time.Duration
+	RequestsPerSec float64
+	SuccessPerSec  float64
+	SuccessRate    float64
+	StatusCodes    map[int]int // Responses by status code, 0 counts transport errors
+}
+
+// Run sends requests until the duration elapses, the request count is reached
+// or ctx is done. Responses other than 2xx and 3xx count as failures
+func Run(ctx context.Context, opts Options) (Result, error) {
+	if opts.Target == "" {
+		return Result{}, errors.New("target is required")
+	}
+	if opts.Duration <= 0 && opts.Requests <= 0 {
+		return Result{}, errors.New("duration or requests is required")
+	}
+	if opts.Connections <= 0 {
+		opts.Connections = 1
+	}
+	if opts.Method == "" {
+		opts.Method = http.MethodGet
+	}
+	if len(opts.Paths) == 0 {
+		opts.Paths = []string{"/"}
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	target := strings.TrimSuffix(opts.Target, "/")
+
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	// Tokens pace the clients when a rate is set
+	var tokens chan struct{}
+	if opts.RPS > 0 {
+		tokens = make(chan struct{}, opts.Connections)
+		go pace(ctx, tokens, opts.RPS)
+	}
+
+	client := &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &http.Transport{
+			MaxIdleConns:        opts.Connections,
+			MaxIdleConnsPerHost: opts.Connections,
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		sent      atomic.Int64
+		latencies []time.Duration
+		statuses  = make(map[int]int)
+	)
+	start := time.Now()
+	for i := 0; i < opts.Connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				n := sent.Add(1)
+				if opts.Requests > 0 && n > int64(opts.Requests) {
+					return
+				}
+				if tokens != nil {
+					select {
+					case <-tokens:
+					case <-ctx.Done():
+						return
+					}
+				}
+				if ctx.Err() != nil {
+					return
+				}
+
+				path := opts.Paths[int(n-1)%len(opts.Paths)]
+				reqStart := time.Now()
+				status := send(ctx, client, opts.Method, target+path, opts.Headers)
+				latency := time.Since(reqStart)
+				// Requests interrupted by the end of the run are not counted
+				if status == 0 && ctx.Err() != nil {
+					return
+				}
+
+				mu.Lock()
+				latencies = append(latencies, latency)
+				statuses[status]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	success := 0
+	for status, count := range statuses {
+		if status >= 200 && status < 400 {
+			success += count
+		}
+	}
+	result := Summarize(len(latencies), success, len(latencies)-success, time.Since(start), latencies)
+	result.StatusCodes = statuses
+
+	return result, nil
+}
+
+// pace adds rps tokens per second until ctx is done
+func pace(ctx context.Context, tokens chan<- struct{}, rps int) {
+	ticker := time.NewTicker(time.Second / time.Duration(rps))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			select {
+			case tokens <- struct{}{}:
+			default: // Clients are behind, the rate is not made up later
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// send makes one request and returns its status code, 0 on transport errors
+func send(ctx context.Context, client *http.Client, method, url string, header http.Header) int {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return 0
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0
+	}
+	defer resp.Body.Close()
+
+	// Read the body so the connection is reused
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return 0
+	}
+
+	return resp.StatusCode
+}
+
+// Summarize computes the statistics of a run from its request latencies
+func Summarize(total, success, failed int, duration time.Duration, latencies []time.Duration) Result {
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+
+	result := Result{
+		Total:    total,
+		Success:  success,
+		Failed:   failed,
+		Duration: duration,
+		P50:      Percentile(latencies, 0.50),
+		P90:      Percentile(latencies, 0.90),
+		P95:      Percentile(latencies, 0.95),
+		P99:      Percentile(latencies, 0.99),
+	}
+	if len(latencies) > 0 {
+		result.Min = latencies[0]
+		result.Max = latencies[len(latencies)-1]
+
+		var sum time.Duration
+		for _, l := range latencies {
+			sum += l
+		}
+		result.Avg = sum / time.Duration(len(latencies))
+	}
+	if duration > 0 {
+		result.RequestsPerSec = float64(total) / duration.Seconds()
+		result.SuccessPerSec = float64(success) / duration.Seconds()
+	}
+	if total > 0 {
+		result.SuccessRate = float64(success) / float64(total)
+	}
+
+	return result
+}
+
+// Percentile returns the nearest-rank percentile of sorted latencies
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(float64(len(sorted))*p)) - 1
+	if i < 0 {
+		i = 0
+	}
+
+	return sorted[i]
+}
+
+// Print writes a human readable report of the result
+func (r Result) Print(w io.Writer) {
+	fmt.Fprintf(w, "Requests:      %d (%d succeeded, %d failed, %.2f%% success)\n", r.Total, r.Success, r.Failed, r.SuccessRate*100)
+	fmt.Fprintf(w, "Duration:      %v\n", r.Duration.Round(time.Millisecond))
+	fmt.Fprintf(w, "Throughput:    %.2f req/s (%.2f successful req/s)\n", r.RequestsPerSec, r.SuccessPerSec)
+	fmt.Fprintf(w, "Latency:       min %v, avg %v, max %v\n", r.Min, r.Avg, r.Max)
+	fmt.Fprintf(w, "Percentiles:   p50 %v, p90 %v, p95 %v, p99 %v\n", r.P50, r.P90, r.P95, r.P99)
+
+	codes := make([]int, 0, len(r.StatusCodes))
+	for code := range r.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		name := http.StatusText(code)
+		if code == 0 {
+			name = "transport error"
+		}
+		fmt.Fprintf(w, "Status %3d:    %d (%s)\n", code, r.StatusCodes[code], name)
+	}
+}
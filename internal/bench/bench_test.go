@@ -0,0 +1,187 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	var mu sync.Mutex
+	paths := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Path]++
+		mu.Unlock()
+		assert.Equal(t, "bench", r.Header.Get("X-Test"))
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	result, err := Run(context.Background(), Options{
+		Target:      server.URL + "/",
+		Paths:       []string{"/a", "/missing"},
+		Headers:     http.Header{"X-Test": {"bench"}},
+		Connections: 4,
+		Requests:    20,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 20, result.Total)
+	assert.Equal(t, 10, result.Success)
+	assert.Equal(t, 10, result.Failed)
+	assert.Equal(t, map[int]int{http.StatusOK: 10, http.StatusNotFound: 10}, result.StatusCodes)
+	assert.Equal(t, map[string]int{"/a": 10, "/missing": 10}, paths)
+	assert.LessOrEqual(t, result.Min, result.P50)
+	assert.LessOrEqual(t, result.P99, result.Max)
+}
+
+func TestRun_Rate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	result, err := Run(context.Background(), Options{
+		Target:      server.URL,
+		Connections: 2,
+		RPS:         50,
+		Duration:    200 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	// About 10 requests fit in the duration at 50 requests per second
+	assert.InDelta(t, 10, result.Total, 4)
+	assert.Equal(t, result.Total, result.Success)
+}
+
+func TestRun_Connections(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			current := peak.Load()
+			if n <= current || peak.CompareAndSwap(current, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	result, err := Run(context.Background(), Options{
+		Target:      server.URL,
+		Connections: 8,
+		Requests:    64,
+	})
+	require.NoError(t, err)
+
+	// The clients send concurrently, never more requests than configured
+	assert.Equal(t, 64, result.Total)
+	assert.Equal(t, 64, result.Success)
+	assert.LessOrEqual(t, peak.Load(), int32(8))
+	assert.Greater(t, peak.Load(), int32(1))
+	assert.GreaterOrEqual(t, result.Min, 5*time.Millisecond)
+}
+
+func TestRun_TransportErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	result, err := Run(context.Background(), Options{
+		Target:   server.URL,
+		Requests: 3,
+		Timeout:  time.Second,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, result.Total)
+	assert.Equal(t, 3, result.Failed)
+	assert.Equal(t, map[int]int{0: 3}, result.StatusCodes)
+	assert.Zero(t, result.SuccessRate)
+}
+
+func TestRun_Canceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// Runs without a request count end with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		result, err := Run(ctx, Options{Target: server.URL, Duration: time.Hour})
+		assert.NoError(t, err)
+		assert.Positive(t, result.Total)
+		assert.Equal(t, result.Total, result.Success)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after the context ended")
+	}
+}
+
+func TestRun_InvalidOptions(t *testing.T) {
+	_, err := Run(context.Background(), Options{Requests: 1})
+	assert.Error(t, err)
+	_, err = Run(context.Background(), Options{Target: "http://localhost"})
+	assert.Error(t, err)
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{10, 20, 30, 40}
+	tests := []struct {
+		p        float64
+		expected time.Duration
+	}{
+		{0, 10},
+		{0.25, 10},
+		{0.5, 20},
+		{0.51, 30},
+		{0.99, 40},
+		{1, 40},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, Percentile(sorted, tt.p), "p%v", tt.p)
+	}
+	assert.Zero(t, Percentile(nil, 0.5))
+}
+
+func TestSummarize(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	result := Summarize(100, 90, 10, 2*time.Second, latencies)
+	assert.Equal(t, time.Millisecond, result.Min)
+	assert.Equal(t, 100*time.Millisecond, result.Max)
+	assert.Equal(t, 50500*time.Microsecond, result.Avg)
+	assert.Equal(t, 50*time.Millisecond, result.P50)
+	assert.Equal(t, 95*time.Millisecond, result.P95)
+	assert.Equal(t, 99*time.Millisecond, result.P99)
+	assert.Equal(t, 50.0, result.RequestsPerSec)
+	assert.Equal(t, 45.0, result.SuccessPerSec)
+	assert.Equal(t, 0.9, result.SuccessRate)
+
+	var out bytes.Buffer
+	result.StatusCodes = map[int]int{0: 10, http.StatusOK: 90}
+	result.Print(&out)
+	assert.Contains(t, out.String(), "p50 50ms, p90 90ms, p95 95ms, p99 99ms")
+	assert.Contains(t, out.String(), "Status   0:    10 (transport error)")
+
+	assert.Zero(t, Summarize(0, 0, 0, 0, nil))
+}
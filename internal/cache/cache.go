@@ -0,0 +1,202 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxSize is the memory used by cached bodies when no size is set
+const DefaultMaxSize = 64 << 20
+
+// Entry is a cached response
+type Entry struct {
+	Status  int
+	Header  http.Header
+	Body    []byte
+	Tags    []string
+	Stored  time.Time
+	Expires time.Time
+}
+
+// size approximates the memory held by the entry
+func (e *Entry) size() int64 {
+	size := int64(len(e.Body))
+	for name, values := range e.Header {
+		size += int64(len(name))
+		for _, value := range values {
+			size += int64(len(value))
+		}
+	}
+	return size
+}
+
+// item is a cached response with the URL it was stored for, variants of a URL
+// are stored under different keys
+type item struct {
+	key   string
+	host  string
+	uri   string
+	entry *Entry
+}
+
+// Cache is a bounded LRU of responses that can be purged by URL, prefix or tag
+type Cache struct {
+	mu      sync.Mutex
+	maxSize int64
+	size    int64
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+	now     func() time.Time
+}
+
+// New creates a cache holding up to maxSize bytes
+func New(maxSize int64) *Cache {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+
+	return &Cache{
+		maxSize: maxSize,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// Key identifies a variant of a URL
+func Key(host, uri, variant string) string {
+	return host + uri + "\x00" + variant
+}
+
+// SetMaxSize changes the size of the cache, evicting entries when it shrinks
+func (c *Cache) SetMaxSize(maxSize int64) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.maxSize = maxSize
+	c.evict()
+}
+
+// Get returns the fresh entry of a key, expired entries are dropped
+func (c *Cache) Get(key string) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	it := elem.Value.(*item)
+	if !c.now().Before(it.entry.Expires) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+
+	return it.entry, true
+}
+
+// Set stores the response of a URL variant, entries larger than the cache are
+// not stored
+func (c *Cache) Set(key, host, uri string, entry *Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	if entry.size() > c.maxSize {
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&item{key: key, host: host, uri: uri, entry: entry})
+	c.size += entry.size()
+	c.evict()
+}
+
+// Purge removes every variant of a URL, an empty host matches all hosts. The
+// number of removed entries is returned
+func (c *Cache) Purge(host, uri string) int {
+	return c.purge(func(it *item) bool {
+		return (host == "" || it.host == host) && it.uri == uri
+	})
+}
+
+// PurgePrefix removes the URLs starting with a path prefix, an empty host
+// matches all hosts
+func (c *Cache) PurgePrefix(host, prefix string) int {
+	return c.purge(func(it *item) bool {
+		return (host == "" || it.host == host) && strings.HasPrefix(it.uri, prefix)
+	})
+}
+
+// PurgeTag removes the responses tagged with tag
+func (c *Cache) PurgeTag(tag string) int {
+	return c.purge(func(it *item) bool {
+		for _, t := range it.entry.Tags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// PurgeAll removes every entry
+func (c *Cache) PurgeAll() int {
+	return c.purge(func(*item) bool { return true })
+}
+
+// Len returns the number of cached entries
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+// Size returns the memory used by the cached entries
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.size
+}
+
+func (c *Cache) purge(match func(*item) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	purged := 0
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		if match(elem.Value.(*item)) {
+			c.remove(elem)
+			purged++
+		}
+		elem = next
+	}
+
+	return purged
+}
+
+func (c *Cache) remove(elem *list.Element) {
+	it := elem.Value.(*item)
+	c.order.Remove(elem)
+	delete(c.entries, it.key)
+	c.size -= it.entry.size()
+}
+
+// evict drops the least recently used entries until the cache fits
+func (c *Cache) evict() {
+	for c.size > c.maxSize {
+		c.remove(c.order.Back())
+	}
+}
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newEntry(body string, ttl time.Duration, tags ...string) *Entry {
+	now := time.Now()
+	return &Entry{
+		Status:  http.StatusOK,
+		Header:  http.Header{},
+		Body:    []byte(body),
+		Tags:    tags,
+		Stored:  now,
+		Expires: now.Add(ttl),
+	}
+}
+
+func TestCache_GetSet(t *testing.T) {
+	c := New(0)
+
+	c.Set(Key("a.example.com", "/x", "gzip"), "a.example.com", "/x", newEntry("gzipped", time.Minute))
+	c.Set(Key("a.example.com", "/x", ""), "a.example.com", "/x", newEntry("plain", time.Minute))
+
+	entry, ok := c.Get(Key("a.example.com", "/x", ""))
+	assert.True(t, ok)
+	assert.Equal(t, "plain", string(entry.Body))
+	_, ok = c.Get(Key("b.example.com", "/x", ""))
+	assert.False(t, ok)
+
+	// Expired entries are dropped
+	c.Set(Key("a.example.com", "/old", ""), "a.example.com", "/old", newEntry("old", -time.Second))
+	_, ok = c.Get(Key("a.example.com", "/old", ""))
+	assert.False(t, ok)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestCache_Evict(t *testing.T) {
+	c := New(10)
+
+	c.Set("a", "h", "/a", newEntry("aaaa", time.Minute))
+	c.Set("b", "h", "/b", newEntry("bbbb", time.Minute))
+	c.Get("a")
+	c.Set("c", "h", "/c", newEntry("cccc", time.Minute))
+
+	_, ok := c.Get("b")
+	assert.False(t, ok, "least recently used entry is evicted")
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, int64(8), c.Size())
+
+	c.Set("big", "h", "/big", newEntry("larger than the cache", time.Minute))
+	_, ok = c.Get("big")
+	assert.False(t, ok)
+
+	c.SetMaxSize(4)
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestCache_Purge(t *testing.T) {
+	c := New(0)
+	fill := func() {
+		c.PurgeAll()
+		c.Set(Key("a.example.com", "/static/app.js", ""), "a.example.com", "/static/app.js", newEntry("1", time.Minute, "assets"))
+		c.Set(Key("a.example.com", "/static/app.js", "gzip"), "a.example.com", "/static/app.js", newEntry("2", time.Minute, "assets"))
+		c.Set(Key("a.example.com", "/products/42", ""), "a.example.com", "/products/42", newEntry("3", time.Minute, "product-42", "products"))
+		c.Set(Key("b.example.com", "/static/app.js", ""), "b.example.com", "/static/app.js", newEntry("4", time.Minute))
+	}
+
+	tests := []struct {
+		name     string
+		purge    func() int
+		expected int
+	}{
+		{name: "url", purge: func() int { return c.Purge("a.example.com", "/static/app.js") }, expected: 2},
+		{name: "url on every host", purge: func() int { return c.Purge("", "/static/app.js") }, expected: 3},
+		{name: "unknown url", purge: func() int { return c.Purge("a.example.com", "/static") }, expected: 0},
+		{name: "prefix", purge: func() int { return c.PurgePrefix("a.example.com", "/static/") }, expected: 2},
+		{name: "prefix on every host", purge: func() int { return c.PurgePrefix("", "/") }, expected: 4},
+		{name: "tag", purge: func() int { return c.PurgeTag("product-42") }, expected: 1},
+		{name: "shared tag", purge: func() int { return c.PurgeTag("assets") }, expected: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fill()
+			assert.Equal(t, tt.expected, tt.purge())
+			assert.Equal(t, 4-tt.expected, c.Len())
+		})
+	}
+	assert.Equal(t, int64(2), c.Size())
+}
@@ -0,0 +1,350 @@
+package certstore
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+)
+
+// ErrUnknownServerName is returned when no certificate serves a server name
+var ErrUnknownServerName = errors.New("no certificate for server name")
+
+// Store holds TLS certificates loaded from multiple files and selects them by SNI
+type Store struct {
+	mu       sync.RWMutex
+	entries  []*entry
+	names    map[string]*entry // exact and wildcard server names
+	stopChan chan struct{}
+}
+
+// entry is one certificate/key file pair
+type entry struct {
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+	leaf     *x509.Certificate
+	modTime  time.Time // latest modification of the files
+	secrets  uint64    // generation of the secrets the pair was loaded with
+}
+
+// CertificateInfo describes a loaded certificate
+type CertificateInfo struct {
+	CertFile    string    `json:"cert_file"`
+	KeyFile     string    `json:"key_file"`
+	Subject     string    `json:"subject"`
+	ServerNames []string  `json:"server_names"`
+	NotAfter    time.Time `json:"not_after"`
+}
+
+// New loads every configured certificate pair
+func New(certs []config.CertificateConfig) (*Store, error) {
+	s := &Store{
+		stopChan: make(chan struct{}),
+	}
+
+	for _, c := range certs {
+		e := &entry{certFile: c.CertFile, keyFile: c.KeyFile}
+		if err := e.load(); err != nil {
+			return nil, err
+		}
+		s.entries = append(s.entries, e)
+	}
+	s.index()
+
+	return s, nil
+}
+
+// load reads the certificate pair from disk or the referenced secrets
+func (e *entry) load() error {
+	generation := config.CredentialGeneration()
+	var modTime time.Time
+	for _, file := range []string{e.certFile, e.keyFile} {
+		if config.IsSecretRef(file) {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+
+	certPEM, err := readPEM(e.certFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate %s: %w", e.certFile, err)
+	}
+	keyPEM, err := readPEM(e.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate %s: %w", e.certFile, err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate %s: %w", e.certFile, err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse certificate %s: %w", e.certFile, err)
+	}
+	cert.Leaf = leaf
+
+	e.cert = &cert
+	e.leaf = leaf
+	e.modTime = modTime
+	e.secrets = generation
+	return nil
+}
+
+// readPEM returns the contents of a certificate or key file, or of the secret
+// it references
+func readPEM(file string) ([]byte, error) {
+	if config.IsSecretRef(file) {
+		value, err := config.ResolveCredential(file)
+		return []byte(value), err
+	}
+	return os.ReadFile(file)
+}
+
+// modified reports whether the files or the secrets of the pair changed since
+// it was loaded
+func (e *entry) modified() bool {
+	if (config.IsSecretRef(e.certFile) || config.IsSecretRef(e.keyFile)) && config.CredentialGeneration() != e.secrets {
+		return true
+	}
+	for _, file := range []string{e.certFile, e.keyFile} {
+		if config.IsSecretRef(file) {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return false
+		}
+		if info.ModTime().After(e.modTime) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// serverNames returns the names a certificate is valid for
+func (e *entry) serverNames() []string {
+	names := make([]string, 0, len(e.leaf.DNSNames)+1)
+	for _, name := range e.leaf.DNSNames {
+		names = append(names, strings.ToLower(name))
+	}
+	if len(names) == 0 && e.leaf.Subject.CommonName != "" {
+		names = append(names, strings.ToLower(e.leaf.Subject.CommonName))
+	}
+
+	return names
+}
+
+// index rebuilds the server name lookup table, callers must hold the write lock
+// or own the store exclusively
+func (s *Store) index() {
+	names := make(map[string]*entry)
+	for _, e := range s.entries {
+		for _, name := range e.serverNames() {
+			// The first configured certificate wins for duplicate names
+			if _, exists := names[name]; !exists {
+				names[name] = e
+			}
+		}
+	}
+	s.names = names
+}
+
+// GetCertificate selects a certificate for the TLS handshake, suitable for tls.Config
+func (s *Store) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if e := s.lookup(hello.ServerName); e != nil {
+		return e.cert, nil
+	}
+	if len(s.entries) == 0 {
+		return nil, errors.New("no certificates available")
+	}
+
+	// Fall back to the first certificate for clients without SNI
+	return s.entries[0].cert, nil
+}
+
+// lookup finds the certificate for a server name, exact names before wildcards
+func (s *Store) lookup(serverName string) *entry {
+	name := strings.ToLower(strings.TrimSuffix(serverName, "."))
+	if name == "" {
+		return nil
+	}
+	if e, ok := s.names[name]; ok {
+		return e
+	}
+	if i := strings.Index(name, "."); i > 0 {
+		if e, ok := s.names["*"+name[i:]]; ok {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// Reload reloads every certificate pair, keeping the previous ones on failure
+func (s *Store) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for _, e := range s.entries {
+		if err := s.reloadEntry(e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	s.index()
+
+	return errors.Join(errs...)
+}
+
+// ReloadServerName reloads the certificate pair serving the given SNI name
+func (s *Store) ReloadServerName(serverName string) (CertificateInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.lookup(serverName)
+	if e == nil {
+		return CertificateInfo{}, fmt.Errorf("%w %s", ErrUnknownServerName, serverName)
+	}
+	if err := s.reloadEntry(e); err != nil {
+		return CertificateInfo{}, err
+	}
+	s.index()
+
+	return e.info(), nil
+}
+
+// reloadEntry swaps in a freshly loaded pair only if it loads successfully
+func (s *Store) reloadEntry(e *entry) error {
+	fresh := &entry{certFile: e.certFile, keyFile: e.keyFile}
+	if err := fresh.load(); err != nil {
+		return err
+	}
+
+	e.cert, e.leaf, e.modTime, e.secrets = fresh.cert, fresh.leaf, fresh.modTime, fresh.secrets
+	lg.GetInstance().Info("Reloaded certificate %s, expires at %s", e.certFile, e.leaf.NotAfter.Format(time.RFC3339))
+	return nil
+}
+
+// Certificates returns information about every loaded certificate
+func (s *Store) Certificates() []CertificateInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	infos := make([]CertificateInfo, 0, len(s.entries))
+	for _, e := range s.entries {
+		infos = append(infos, e.info())
+	}
+
+	return infos
+}
+
+func (e *entry) info() CertificateInfo {
+	names := e.serverNames()
+	sort.Strings(names)
+
+	return CertificateInfo{
+		CertFile:    e.certFile,
+		KeyFile:     e.keyFile,
+		Subject:     e.leaf.Subject.String(),
+		ServerNames: names,
+		NotAfter:    e.leaf.NotAfter,
+	}
+}
+
+// Watch polls the certificate files and reloads pairs whose files changed
+func (s *Store) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.checkForUpdate()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// Stop terminates the file watcher
+func (s *Store) Stop() {
+	close(s.stopChan)
+}
+
+// checkForUpdate reloads certificates whose files or secrets were modified
+func (s *Store) checkForUpdate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := false
+	for _, e := range s.entries {
+		if !e.modified() {
+			continue
+		}
+
+		if err := s.reloadEntry(e); err != nil {
+			lg.GetInstance().Error("Failed to reload certificate %s: %v", e.certFile, err)
+			continue
+		}
+		changed = true
+	}
+
+	if changed {
+		s.index()
+	}
+}
+
+// SetMeter sets the meter reporting the expiry timestamp of every certificate
+func (s *Store) SetMeter(meter otelmetric.Meter) {
+	if err := s.registerMetrics(meter); err != nil {
+		lg.GetInstance().Error("Failed to register certificate metrics: %v", err)
+	}
+}
+
+// registerMetrics exports the expiry timestamp of every certificate
+func (s *Store) registerMetrics(meter otelmetric.Meter) error {
+	expiry, err := meter.Int64ObservableGauge(
+		"nexus.tls.certificate.expiry",
+		otelmetric.WithDescription("Certificate expiry time as a unix timestamp"),
+		otelmetric.WithUnit("s"),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o otelmetric.Observer) error {
+		for _, info := range s.Certificates() {
+			o.ObserveInt64(expiry, info.NotAfter.Unix(), otelmetric.WithAttributes(
+				attribute.String("cert.file", info.CertFile),
+				attribute.String("cert.subject", info.Subject),
+				attribute.StringSlice("cert.server_names", info.ServerNames),
+			))
+		}
+		return nil
+	}, expiry)
+
+	return err
+}
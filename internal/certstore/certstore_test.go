@@ -0,0 +1,206 @@
+package certstore
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// writeCert writes a self-signed certificate pair for the given names
+func writeCert(t *testing.T, dir, name string, notAfter time.Time, dnsNames ...string) config.CertificateConfig {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	cert := config.CertificateConfig{
+		CertFile: filepath.Join(dir, name+".crt"),
+		KeyFile:  filepath.Join(dir, name+".key"),
+	}
+	require.NoError(t, os.WriteFile(cert.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(cert.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return cert
+}
+
+func serverName(t *testing.T, s *Store, sni string) string {
+	t.Helper()
+
+	cert, err := s.GetCertificate(&tls.ClientHelloInfo{ServerName: sni})
+	require.NoError(t, err)
+	return cert.Leaf.DNSNames[0]
+}
+
+func TestStore_GetCertificate(t *testing.T) {
+	dir := t.TempDir()
+	expiry := time.Now().Add(24 * time.Hour)
+
+	s, err := New([]config.CertificateConfig{
+		writeCert(t, dir, "default", expiry, "default.example.com"),
+		writeCert(t, dir, "api", expiry, "api.example.com"),
+		writeCert(t, dir, "wildcard", expiry, "*.apps.example.com"),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "api.example.com", serverName(t, s, "api.example.com"))
+	assert.Equal(t, "api.example.com", serverName(t, s, "API.example.com"))
+	assert.Equal(t, "*.apps.example.com", serverName(t, s, "shop.apps.example.com"))
+	assert.Equal(t, "default.example.com", serverName(t, s, "unknown.test"))
+	assert.Equal(t, "default.example.com", serverName(t, s, ""))
+}
+
+func TestStore_ReloadServerName(t *testing.T) {
+	dir := t.TempDir()
+	oldExpiry := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	newExpiry := time.Now().Add(90 * 24 * time.Hour).Truncate(time.Second)
+
+	s, err := New([]config.CertificateConfig{
+		writeCert(t, dir, "web", oldExpiry, "www.example.com"),
+		writeCert(t, dir, "api", oldExpiry, "api.example.com"),
+	})
+	require.NoError(t, err)
+
+	// Rotate both pairs on disk but only reload one of them
+	writeCert(t, dir, "web", newExpiry, "www.example.com")
+	writeCert(t, dir, "api", newExpiry, "api.example.com")
+
+	info, err := s.ReloadServerName("api.example.com")
+	require.NoError(t, err)
+	assert.True(t, info.NotAfter.Equal(newExpiry))
+
+	certs := s.Certificates()
+	require.Len(t, certs, 2)
+	assert.True(t, certs[0].NotAfter.Equal(oldExpiry))
+	assert.True(t, certs[1].NotAfter.Equal(newExpiry))
+
+	_, err = s.ReloadServerName("missing.example.com")
+	assert.ErrorIs(t, err, ErrUnknownServerName)
+}
+
+func TestStore_ReloadKeepsCertificateOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	expiry := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	cert := writeCert(t, dir, "web", expiry, "www.example.com")
+	s, err := New([]config.CertificateConfig{cert})
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(cert.CertFile, []byte("garbage"), 0o600))
+	assert.Error(t, s.Reload())
+
+	assert.Equal(t, "www.example.com", serverName(t, s, "www.example.com"))
+	assert.True(t, s.Certificates()[0].NotAfter.Equal(expiry))
+}
+
+func TestStore_WatchReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	oldExpiry := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	newExpiry := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+
+	cert := writeCert(t, dir, "web", oldExpiry, "www.example.com")
+	s, err := New([]config.CertificateConfig{cert})
+	require.NoError(t, err)
+
+	writeCert(t, dir, "web", newExpiry, "www.example.com")
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(cert.CertFile, future, future))
+
+	s.checkForUpdate()
+	assert.True(t, s.Certificates()[0].NotAfter.Equal(newExpiry))
+}
+
+func TestNew_InvalidCertificate(t *testing.T) {
+	_, err := New([]config.CertificateConfig{{CertFile: "/nonexistent.crt", KeyFile: "/nonexistent.key"}})
+	assert.Error(t, err)
+}
+
+// fileResolver serves secret references from files, like a provider would
+type fileResolver struct {
+	files      map[string]string // file by reference
+	generation uint64
+}
+
+func (r *fileResolver) Resolve(ref string) (string, error) {
+	return config.ReadCredentialFile(r.files[ref])
+}
+
+func (r *fileResolver) Generation() uint64 {
+	return r.generation
+}
+
+func TestStore_SecretReferences(t *testing.T) {
+	dir := t.TempDir()
+	files := writeCert(t, dir, "secret", time.Now().Add(24*time.Hour), "secret.example.com")
+	resolver := &fileResolver{files: map[string]string{
+		"secret://fake/tls#cert": files.CertFile,
+		"secret://fake/tls#key":  files.KeyFile,
+	}}
+	config.SetSecretResolver(resolver)
+	defer config.SetSecretResolver(nil)
+
+	s, err := New([]config.CertificateConfig{{CertFile: "secret://fake/tls#cert", KeyFile: "secret://fake/tls#key"}})
+	require.NoError(t, err)
+	assert.Equal(t, "secret.example.com", serverName(t, s, "secret.example.com"))
+
+	// Rotated secrets are picked up once the resolver refreshed them
+	writeCert(t, dir, "secret", time.Now().Add(24*time.Hour), "rotated.example.com")
+	s.checkForUpdate()
+	assert.Equal(t, "secret.example.com", serverName(t, s, "unknown.test"))
+
+	resolver.generation++
+	s.checkForUpdate()
+	assert.Equal(t, "rotated.example.com", serverName(t, s, "rotated.example.com"))
+}
+
+func TestStore_SetMeter(t *testing.T) {
+	dir := t.TempDir()
+	expiry := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	cert := writeCert(t, dir, "web", expiry, "www.example.com")
+	s, err := New([]config.CertificateConfig{cert})
+	require.NoError(t, err)
+
+	reader := sdkmetric.NewManualReader()
+	s.SetMeter(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+	metric := rm.ScopeMetrics[0].Metrics[0]
+	assert.Equal(t, "nexus.tls.certificate.expiry", metric.Name)
+	gauge, ok := metric.Data.(metricdata.Gauge[int64])
+	require.True(t, ok)
+	require.Len(t, gauge.DataPoints, 1)
+	assert.Equal(t, expiry.Unix(), gauge.DataPoints[0].Value)
+	file, _ := gauge.DataPoints[0].Attributes.Value("cert.file")
+	assert.Equal(t, cert.CertFile, file.AsString())
+}
@@ -0,0 +1,240 @@
+package certstore
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+)
+
+// ClientAuth verifies client certificates against a CA bundle and revocation
+// lists, both are reloaded when their files change
+type ClientAuth struct {
+	cfg      config.ClientAuthConfig
+	mu       sync.RWMutex
+	roots    *x509.CertPool
+	revoked  map[string]struct{} // raw issuer + serial number
+	modTime  time.Time
+	stopChan chan struct{}
+}
+
+// NewClientAuth loads the CA bundle and revocation lists
+func NewClientAuth(cfg config.ClientAuthConfig) (*ClientAuth, error) {
+	a := &ClientAuth{
+		cfg:      cfg,
+		stopChan: make(chan struct{}),
+	}
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Configure sets up client certificate verification on a TLS config.
+// Certificates are verified in VerifyConnection, which also runs for resumed
+// sessions, so a revoked certificate can't resume an earlier session
+func (a *ClientAuth) Configure(tlsConfig *tls.Config) {
+	tlsConfig.ClientAuth = tls.RequestClientCert
+	if a.cfg.Mode == "require" {
+		tlsConfig.ClientAuth = tls.RequireAnyClientCert
+	}
+	tlsConfig.VerifyConnection = a.verifyConnection
+}
+
+// verifyConnection accepts connections without a client certificate, which
+// the handshake already rejects when one is required, and connections whose
+// certificate chains to a configured CA and isn't revoked
+func (a *ClientAuth) verifyConnection(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	a.mu.RLock()
+	roots, revoked := a.roots, a.revoked
+	a.mu.RUnlock()
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	leaf := state.PeerCertificates[0]
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return fmt.Errorf("invalid client certificate: %w", err)
+	}
+
+	// The root of the chain is trusted as configured
+	chain := chains[0]
+	for _, cert := range chain[:len(chain)-1] {
+		if _, ok := revoked[revocationKey(cert.RawIssuer, cert.SerialNumber.String())]; ok {
+			return fmt.Errorf("client certificate %s is revoked", cert.Subject)
+		}
+	}
+
+	return nil
+}
+
+func revocationKey(rawIssuer []byte, serial string) string {
+	return string(rawIssuer) + "/" + serial
+}
+
+// Reload reads the CA bundle and revocation lists again, keeping the previous
+// ones on failure
+func (a *ClientAuth) Reload() error {
+	modTime, err := a.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	cas, err := readCertificates(a.cfg.CAFile)
+	if err != nil {
+		return err
+	}
+	roots := x509.NewCertPool()
+	for _, ca := range cas {
+		roots.AddCert(ca)
+	}
+
+	revoked := make(map[string]struct{})
+	for _, file := range a.cfg.CRLFiles {
+		if err := readRevocationList(file, cas, revoked); err != nil {
+			return err
+		}
+	}
+
+	a.mu.Lock()
+	a.roots, a.revoked, a.modTime = roots, revoked, modTime
+	a.mu.Unlock()
+
+	return nil
+}
+
+// latestModTime returns the latest modification of the CA and CRL files
+func (a *ClientAuth) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range append([]string{a.cfg.CAFile}, a.cfg.CRLFiles...) {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+// readCertificates parses every certificate of a PEM bundle
+func readCertificates(file string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CA certificate in %s: %w", file, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no CA certificates in %s", file)
+	}
+
+	return certs, nil
+}
+
+// readRevocationList adds the serial numbers revoked by a PEM or DER encoded
+// list, which must be signed by one of the CAs
+func readRevocationList(file string, cas []*x509.Certificate, revoked map[string]struct{}) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+
+	crl, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse revocation list %s: %w", file, err)
+	}
+	signed := false
+	for _, ca := range cas {
+		if crl.CheckSignatureFrom(ca) == nil {
+			signed = true
+			break
+		}
+	}
+	if !signed {
+		return fmt.Errorf("revocation list %s is not signed by a configured CA", file)
+	}
+
+	for _, entry := range crl.RevokedCertificateEntries {
+		revoked[revocationKey(crl.RawIssuer, entry.SerialNumber.String())] = struct{}{}
+	}
+
+	return nil
+}
+
+// Watch polls the CA and revocation list files and reloads them on changes
+func (a *ClientAuth) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.checkForUpdate()
+		case <-a.stopChan:
+			return
+		}
+	}
+}
+
+// Stop terminates the file watcher
+func (a *ClientAuth) Stop() {
+	close(a.stopChan)
+}
+
+// checkForUpdate reloads the files when one of them was modified
+func (a *ClientAuth) checkForUpdate() {
+	modTime, err := a.latestModTime()
+	if err != nil {
+		return
+	}
+	a.mu.RLock()
+	changed := modTime.After(a.modTime)
+	a.mu.RUnlock()
+	if !changed {
+		return
+	}
+
+	if err := a.Reload(); err != nil {
+		lg.GetInstance().Error("Failed to reload client CA or revocation lists: %v", err)
+		return
+	}
+	lg.GetInstance().Info("Reloaded client CA %s and %d revocation lists", a.cfg.CAFile, len(a.cfg.CRLFiles))
+}
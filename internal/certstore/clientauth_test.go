@@ -0,0 +1,173 @@
+package certstore
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA issues client certificates and revocation lists
+type testCA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+	file string
+}
+
+func newTestCA(t *testing.T, dir, name string) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	file := filepath.Join(dir, name+".pem")
+	require.NoError(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+
+	return &testCA{cert: cert, key: key, file: file}
+}
+
+// issue returns a client certificate and its key
+func (ca *testCA) issue(t *testing.T, commonName string, serial int64) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// writeCRL writes a PEM revocation list revoking the serial numbers
+func (ca *testCA) writeCRL(t *testing.T, file string, number int64, serials ...int64) {
+	t.Helper()
+
+	template := &x509.RevocationList{
+		Number:     big.NewInt(number),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: time.Now().Add(time.Hour),
+	}
+	for _, serial := range serials {
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: time.Now().Add(-time.Minute),
+		})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, template, ca.cert, ca.key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0o600))
+}
+
+func peer(cert tls.Certificate) tls.ConnectionState {
+	return tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert.Leaf}}
+}
+
+func TestClientAuth_Verify(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "client-ca")
+	other := newTestCA(t, dir, "other-ca")
+	crl := filepath.Join(dir, "client-ca.crl")
+	ca.writeCRL(t, crl, 1, 3)
+
+	auth, err := NewClientAuth(config.ClientAuthConfig{CAFile: ca.file, CRLFiles: []string{crl}})
+	require.NoError(t, err)
+
+	assert.NoError(t, auth.verifyConnection(tls.ConnectionState{}))
+	assert.NoError(t, auth.verifyConnection(peer(ca.issue(t, "billing", 2))))
+	assert.ErrorContains(t, auth.verifyConnection(peer(ca.issue(t, "revoked", 3))), "revoked")
+	assert.ErrorContains(t, auth.verifyConnection(peer(other.issue(t, "billing", 2))), "invalid client certificate")
+
+	// Revocation lists are picked up when their file changes
+	ca.writeCRL(t, crl, 2, 2, 3)
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(crl, future, future))
+	auth.checkForUpdate()
+	assert.ErrorContains(t, auth.verifyConnection(peer(ca.issue(t, "billing", 2))), "revoked")
+}
+
+func TestNewClientAuth_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "client-ca")
+	other := newTestCA(t, dir, "other-ca")
+	crl := filepath.Join(dir, "other.crl")
+	other.writeCRL(t, crl, 1)
+
+	_, err := NewClientAuth(config.ClientAuthConfig{CAFile: ca.file, CRLFiles: []string{crl}})
+	assert.ErrorContains(t, err, "not signed by a configured CA")
+
+	empty := filepath.Join(dir, "empty.pem")
+	require.NoError(t, os.WriteFile(empty, nil, 0o600))
+	_, err = NewClientAuth(config.ClientAuthConfig{CAFile: empty})
+	assert.ErrorContains(t, err, "no CA certificates")
+}
+
+func TestClientAuth_Handshake(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "client-ca")
+	auth, err := NewClientAuth(config.ClientAuthConfig{Mode: "require", CAFile: ca.file})
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{}
+	auth.Configure(server.TLS)
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	get := func(certs ...tls.Certificate) (*http.Response, error) {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs},
+		}}
+		return client.Get(server.URL)
+	}
+
+	resp, err := get(ca.issue(t, "billing", 2))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err = get()
+	assert.Error(t, err)
+}
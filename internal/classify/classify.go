@@ -0,0 +1,125 @@
+package classify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"nexus/internal/config"
+)
+
+// Classifier tags requests with the classification rules
+type Classifier struct {
+	rules []*rule
+}
+
+// rule is a compiled classification rule
+type rule struct {
+	pathPrefix string
+	methods    map[string]bool
+	headers    map[string]*regexp.Regexp
+	userAgent  *regexp.Regexp
+	tags       map[string]string
+}
+
+// New compiles the classification rules, nil when there are none
+func New(rules []*config.ClassificationRule) (*Classifier, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	c := &Classifier{}
+	for i, cfg := range rules {
+		r := &rule{pathPrefix: cfg.PathPrefix, tags: cfg.Tags}
+		if len(cfg.Methods) > 0 {
+			r.methods = make(map[string]bool, len(cfg.Methods))
+			for _, method := range cfg.Methods {
+				r.methods[strings.ToUpper(method)] = true
+			}
+		}
+		for header, pattern := range cfg.Headers {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("classification rule %d: invalid header %s pattern: %w", i, header, err)
+			}
+			if r.headers == nil {
+				r.headers = make(map[string]*regexp.Regexp, len(cfg.Headers))
+			}
+			r.headers[http.CanonicalHeaderKey(header)] = re
+		}
+		if cfg.UserAgent != "" {
+			re, err := regexp.Compile(cfg.UserAgent)
+			if err != nil {
+				return nil, fmt.Errorf("classification rule %d: invalid user_agent pattern: %w", i, err)
+			}
+			r.userAgent = re
+		}
+		c.rules = append(c.rules, r)
+	}
+
+	return c, nil
+}
+
+// Classify returns the tags of the rules matching req, nil when none does
+func (c *Classifier) Classify(req *http.Request) map[string]string {
+	if c == nil {
+		return nil
+	}
+
+	var tags map[string]string
+	for _, r := range c.rules {
+		if !r.match(req) {
+			continue
+		}
+		if tags == nil {
+			tags = make(map[string]string, len(r.tags))
+		}
+		for name, value := range r.tags {
+			tags[name] = value
+		}
+	}
+	return tags
+}
+
+func (r *rule) match(req *http.Request) bool {
+	if r.pathPrefix != "" && !strings.HasPrefix(req.URL.Path, r.pathPrefix) {
+		return false
+	}
+	if r.methods != nil && !r.methods[req.Method] {
+		return false
+	}
+	for header, re := range r.headers {
+		values, ok := req.Header[header]
+		if !ok || !matchAny(re, values) {
+			return false
+		}
+	}
+	if r.userAgent != nil && !r.userAgent.MatchString(req.UserAgent()) {
+		return false
+	}
+	return true
+}
+
+func matchAny(re *regexp.Regexp, values []string) bool {
+	for _, value := range values {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the tags of a request
+func NewContext(ctx context.Context, tags map[string]string) context.Context {
+	return context.WithValue(ctx, contextKey{}, tags)
+}
+
+// FromContext returns the tags stored in the context
+func FromContext(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(contextKey{}).(map[string]string)
+	return tags
+}
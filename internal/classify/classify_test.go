@@ -0,0 +1,52 @@
+package classify
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifier_Classify(t *testing.T) {
+	c, err := New([]*config.ClassificationRule{
+		{Tags: map[string]string{"class": "desktop"}},
+		{UserAgent: "(?i)android|iphone", Tags: map[string]string{"class": "mobile"}},
+		{UserAgent: "(?i)bot|crawler", Tags: map[string]string{"bot": "true"}},
+		{PathPrefix: "/api", Methods: []string{"post"}, Headers: map[string]string{"x-client": "^partner-"}, Tags: map[string]string{"client": "partner"}},
+	})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64)")
+	assert.Equal(t, map[string]string{"class": "desktop"}, c.Classify(req))
+
+	// Later rules override earlier values and add their tags
+	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone) Googlebot")
+	assert.Equal(t, map[string]string{"class": "mobile", "bot": "true"}, c.Classify(req))
+
+	// Every condition of a rule must match
+	req = httptest.NewRequest("POST", "/api/orders", nil)
+	req.Header.Set("X-Client", "partner-acme")
+	assert.Equal(t, "partner", c.Classify(req)["client"])
+	req.Header.Set("X-Client", "acme")
+	assert.NotContains(t, c.Classify(req), "client")
+	req = httptest.NewRequest("GET", "/api/orders", nil)
+	req.Header.Set("X-Client", "partner-acme")
+	assert.NotContains(t, c.Classify(req), "client")
+
+	// Without rules
+	c, err = New(nil)
+	require.NoError(t, err)
+	assert.Nil(t, c.Classify(req))
+}
+
+func TestContext(t *testing.T) {
+	assert.Nil(t, FromContext(context.Background()))
+
+	ctx := NewContext(context.Background(), map[string]string{"class": "mobile"})
+	assert.Equal(t, "mobile", FromContext(ctx)["class"])
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+
+	"nexus/internal/audit"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SetAuditLog sets the log recording config reloads, nil stops recording
+func (cw *ConfigWatcher) SetAuditLog(log *audit.Log) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	cw.audit = log
+}
+
+// auditReload records a reload attempt of data, err is the reason it was rejected
+func (cw *ConfigWatcher) auditReload(data []byte, err error) {
+	if cw.audit == nil {
+		return
+	}
+
+	entry := audit.Entry{Actor: "config file " + cw.filePath, Action: "config.reload"}
+	if cw.applied != nil {
+		entry.OldDigest = audit.Digest(cw.applied)
+	}
+	if data != nil {
+		entry.NewDigest = audit.Digest(data)
+		if cw.applied != nil {
+			entry.Changed = changedSections(cw.applied, data)
+		}
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	cw.audit.Record(entry)
+}
+
+// changedSections returns the top-level keys whose values differ between two
+// config files. JSON files are read as YAML
+func changedSections(old, new []byte) []string {
+	var before, after map[string]interface{}
+	if yaml.Unmarshal(old, &before) != nil || yaml.Unmarshal(new, &after) != nil {
+		return nil
+	}
+
+	changed := make([]string, 0)
+	for key, value := range after {
+		if !reflect.DeepEqual(before[key], value) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
@@ -20,16 +20,21 @@ func NewConfig() *Config {
 
 // LoadFromFile loads configuration from a file
 func (c *Config) LoadFromFile(path string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
 
 	// Decide whether to use YAML or JSON based on the file extension
-	switch filepath.Ext(path) {
+	return c.LoadFromBytes(data, filepath.Ext(path))
+}
+
+// LoadFromBytes loads configuration in the format of a file extension
+func (c *Config) LoadFromBytes(data []byte, ext string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch ext {
 	case ".yaml", ".yml":
 		return yaml.Unmarshal(data, c)
 	case ".json":
@@ -97,6 +102,182 @@ func (c *Config) GetTelemetryConfig() TelemetryConfig {
 	return c.Telemetry
 }
 
+// GetDedupConfig gets the request deduplication configuration
+func (c *Config) GetDedupConfig() DedupConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Dedup
+}
+
+// GetAdminConfig gets the admin API configuration
+func (c *Config) GetAdminConfig() AdminConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Admin
+}
+
+// GetStateConfig gets the runtime state persistence configuration
+func (c *Config) GetStateConfig() StateConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.State
+}
+
+// GetTLSConfig gets the TLS listener configuration
+func (c *Config) GetTLSConfig() TLSConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.TLS
+}
+
+// GetRateLimitStoreConfig gets the rate limit storage configuration
+func (c *Config) GetRateLimitStoreConfig() RateLimitStoreConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.RateLimit
+}
+
+// GetGeoIPConfig gets the GeoIP database configuration
+func (c *Config) GetGeoIPConfig() GeoIPConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.GeoIP
+}
+
+// GetRouteCacheConfig gets the route match cache configuration
+func (c *Config) GetRouteCacheConfig() RouteCacheConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.RouteCache
+}
+
+// GetCacheConfig gets the response cache configuration
+func (c *Config) GetCacheConfig() CacheConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Cache
+}
+
+// GetLoadShedConfig gets the load shedding configuration
+func (c *Config) GetLoadShedConfig() LoadShedConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.LoadShed
+}
+
+// GetRecordConfig gets the traffic recording configuration
+func (c *Config) GetRecordConfig() RecordConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Record
+}
+
+// GetUpstreamConfig gets the upstream connection configuration
+func (c *Config) GetUpstreamConfig() UpstreamConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Upstream
+}
+
+// GetPathConfig gets the path normalization configuration
+func (c *Config) GetPathConfig() PathConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Paths
+}
+
+// GetClassificationRules gets the request tagging rules
+func (c *Config) GetClassificationRules() []*ClassificationRule {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Classify
+}
+
+// GetPassthroughConfig gets the TLS passthrough configuration
+func (c *Config) GetPassthroughConfig() PassthroughConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Passthrough
+}
+
+// GetLeaderElectionConfig gets the leader election configuration
+func (c *Config) GetLeaderElectionConfig() LeaderElectionConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Election
+}
+
+// GetSignatureConfig gets the proxy signature configuration
+func (c *Config) GetSignatureConfig() SignatureConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Signature
+}
+
+// GetSmugglingProtectionConfig gets the smuggling protection configuration
+func (c *Config) GetSmugglingProtectionConfig() SmugglingProtectionConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Smuggling
+}
+
+// GetSnapshotConfig gets the config snapshots configuration
+func (c *Config) GetSnapshotConfig() SnapshotConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Snapshots
+}
+
+// GetRealIPConfig gets the client IP extraction configuration
+func (c *Config) GetRealIPConfig() RealIPConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.RealIP
+}
+
+// GetAuditConfig gets the audit log configuration
+func (c *Config) GetAuditConfig() AuditConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Audit
+}
+
+// GetMiddlewareConfig gets the listener middleware configuration
+func (c *Config) GetMiddlewareConfig() []*MiddlewareConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Middleware
+}
+
+// GetSecretsConfig gets the secret providers configuration
+func (c *Config) GetSecretsConfig() SecretsConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Secrets
+}
+
 // GetRouteConfig gets the route configuration
 func (c *Config) GetRouteConfig() []*RouteConfig {
 	c.mu.RLock()
@@ -141,30 +322,75 @@ func (cw *ConfigWatcher) checkForUpdate() {
 		return
 	}
 
-	if fileInfo.ModTime().After(cw.lastMod) {
+	// Files replaced by a copy may carry an older modification time, and
+	// coarse timestamps of some Windows filesystems miss quick rewrites
+	if !fileInfo.ModTime().Equal(cw.lastMod) || fileInfo.Size() != cw.lastSize {
 		cw.lastMod = fileInfo.ModTime()
-		if err := Validate(cw.filePath); err != nil {
-			logger := lg.GetInstance()
-			logger.Error("update config error - type: %T, detail: %v", err, err)
-
-			switch e := err.(type) {
-			case *os.PathError:
-				logger.Error("config file access error - operation[%s] path[%s]", e.Op, e.Path)
-			default:
-				logger.Error("config error - %v", err)
-			}
-			return
-		}
+		cw.lastSize = fileInfo.Size()
+		cw.reload()
+	}
+}
 
-		cfg := NewConfig()
-		if err := cfg.LoadFromFile(cw.filePath); err != nil {
-			return
-		}
+// Reload loads and applies the config file now, whether it changed or not,
+// e.g. after files or secrets it references changed
+func (cw *ConfigWatcher) Reload() {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if fileInfo, err := os.Stat(cw.filePath); err == nil {
+		cw.lastMod = fileInfo.ModTime()
+		cw.lastSize = fileInfo.Size()
+	}
+	cw.reload()
+}
 
-		for _, watcher := range cw.watchers {
-			watcher(cfg)
+// reload loads, validates and applies the config file, recording the outcome
+func (cw *ConfigWatcher) reload() error {
+	logger := lg.GetInstance()
+	if cw.metrics != nil {
+		cw.metrics.attempt()
+	}
+	fail := func(reason string, err error) {
+		logger.Error("update config error - type: %T, detail: %v", err, err)
+		switch e := err.(type) {
+		case *os.PathError:
+			logger.Error("config file access error - operation[%s] path[%s]", e.Op, e.Path)
+		default:
+			logger.Error("config error - %v", err)
+		}
+		if cw.metrics != nil {
+			cw.metrics.failure(reason)
 		}
 	}
+
+	data, err := os.ReadFile(cw.filePath)
+	if err != nil {
+		fail(ReloadFailureRead, err)
+		cw.auditReload(nil, err)
+		return err
+	}
+	cfg := NewConfig()
+	if err := cfg.LoadFromBytes(data, filepath.Ext(cw.filePath)); err != nil {
+		fail(ReloadFailureParse, err)
+		cw.auditReload(data, err)
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		fail(ReloadFailureInvalid, err)
+		cw.auditReload(data, err)
+		return err
+	}
+
+	for _, watcher := range cw.watchers {
+		watcher(cfg)
+	}
+	if cw.metrics != nil {
+		cw.metrics.success(data)
+	}
+	cw.auditReload(data, nil)
+	cw.applied = data
+	cw.saveSnapshot(data)
+	return nil
 }
 
 // UnmarshalYAML Custom UnmarshalYAML
@@ -186,12 +412,39 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		services[svc.Name] = svc
 	}
 
+	grouped, err := expandRouteGroups(raw.RouteGroups)
+	if err != nil {
+		return err
+	}
+
 	c.ListenAddr = raw.ListenAddr
 	c.LogLevel = raw.LogLevel
 	c.Telemetry = raw.Telemetry
 	c.Services = services
-	c.Routes = raw.Routes
+	c.Routes = append(raw.Routes, grouped...)
 	c.HealthCheck = raw.HealthCheck
+	c.Dedup = raw.Dedup
+	c.Upstream = raw.Upstream
+	c.Audit = raw.Audit
+	c.Paths = raw.Paths
+	c.Smuggling = raw.Smuggling
+	c.Signature = raw.Signature
+	c.Election = raw.Election
+	c.Passthrough = raw.Passthrough
+	c.Classify = raw.Classify
+	c.Snapshots = raw.Snapshots
+	c.RealIP = raw.RealIP
+	c.Admin = raw.Admin
+	c.State = raw.State
+	c.TLS = raw.TLS
+	c.RateLimit = raw.RateLimit
+	c.GeoIP = raw.GeoIP
+	c.RouteCache = raw.RouteCache
+	c.Secrets = raw.Secrets
+	c.Cache = raw.Cache
+	c.LoadShed = raw.LoadShed
+	c.Record = raw.Record
+	c.Middleware = raw.Middleware
 
 	return nil
 }
@@ -215,12 +468,39 @@ func (c *Config) UnmarshalJSON(data []byte) error {
 		services[svc.Name] = svc
 	}
 
+	grouped, err := expandRouteGroups(raw.RouteGroups)
+	if err != nil {
+		return err
+	}
+
 	c.ListenAddr = raw.ListenAddr
 	c.LogLevel = raw.LogLevel
 	c.Telemetry = raw.Telemetry
 	c.Services = services
-	c.Routes = raw.Routes
+	c.Routes = append(raw.Routes, grouped...)
 	c.HealthCheck = raw.HealthCheck
+	c.Dedup = raw.Dedup
+	c.Upstream = raw.Upstream
+	c.Audit = raw.Audit
+	c.Paths = raw.Paths
+	c.Smuggling = raw.Smuggling
+	c.Signature = raw.Signature
+	c.Election = raw.Election
+	c.Passthrough = raw.Passthrough
+	c.Classify = raw.Classify
+	c.Snapshots = raw.Snapshots
+	c.RealIP = raw.RealIP
+	c.Admin = raw.Admin
+	c.State = raw.State
+	c.TLS = raw.TLS
+	c.RateLimit = raw.RateLimit
+	c.GeoIP = raw.GeoIP
+	c.RouteCache = raw.RouteCache
+	c.Secrets = raw.Secrets
+	c.Cache = raw.Cache
+	c.LoadShed = raw.LoadShed
+	c.Record = raw.Record
+	c.Middleware = raw.Middleware
 
 	return nil
 }
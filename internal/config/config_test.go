@@ -1,10 +1,12 @@
 package config
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"sync"
@@ -12,8 +14,14 @@ import (
 	"testing"
 	"time"
 
+	"nexus/internal/audit"
+	"nexus/internal/snapshot"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 )
 
 func TestConfigLoad_ValidConfig(t *testing.T) {
@@ -164,6 +172,224 @@ log_level: "debug"
 	}
 }
 
+func TestConfigWatcher_ReloadMetrics(t *testing.T) {
+	configFile := createTempConfigFile(t, `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+health_check:
+  interval: 10s
+  timeout: 2s
+`)
+	watcher := NewConfigWatcher(configFile)
+	reader := sdkmetric.NewManualReader()
+	watcher.SetMeter(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"))
+	var applied int
+	watcher.Watch(func(cfg *Config) { applied++ })
+
+	// Each write gets a later modification time so the watcher picks it up
+	modTime := time.Now()
+	write := func(content string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(configFile, []byte(content), 0644))
+		modTime = modTime.Add(time.Second)
+		require.NoError(t, os.Chtimes(configFile, modTime, modTime))
+		watcher.checkForUpdate()
+	}
+
+	watcher.checkForUpdate()
+	write("listen_addr: [")
+	write(`listen_addr: "invalid"`)
+	require.Equal(t, 1, applied)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	sums := make(map[string]int64)
+	var hash attribute.Set
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		switch data := m.Data.(type) {
+		case metricdata.Sum[int64]:
+			for _, point := range data.DataPoints {
+				key := m.Name
+				if reason, ok := point.Attributes.Value("reason"); ok {
+					key += "/" + reason.AsString()
+				}
+				sums[key] += point.Value
+			}
+		case metricdata.Gauge[int64]:
+			if m.Name == "nexus.config.hash" {
+				require.Len(t, data.DataPoints, 1)
+				hash = data.DataPoints[0].Attributes
+			}
+		}
+	}
+	assert.Equal(t, map[string]int64{
+		"nexus.config.reload.attempts":         3,
+		"nexus.config.reload.successes":        1,
+		"nexus.config.reload.failures/parse":   1,
+		"nexus.config.reload.failures/invalid": 1,
+	}, sums)
+	value, ok := hash.Value("config.hash")
+	require.True(t, ok)
+	assert.Len(t, value.AsString(), 12)
+}
+
+func TestConfigWatcher_Reload(t *testing.T) {
+	configFile := createTempConfigFile(t, `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+health_check:
+  interval: 10s
+  timeout: 2s
+`)
+	watcher := NewConfigWatcher(configFile)
+	var applied int
+	watcher.Watch(func(cfg *Config) { applied++ })
+
+	// Unchanged files are only applied again when forced
+	watcher.checkForUpdate()
+	watcher.checkForUpdate()
+	require.Equal(t, 1, applied)
+	watcher.Reload()
+	assert.Equal(t, 2, applied)
+	watcher.checkForUpdate()
+	assert.Equal(t, 2, applied)
+
+	// Copies with an older modification time and rewrites within the
+	// timestamp resolution are picked up
+	info, err := os.Stat(configFile)
+	require.NoError(t, err)
+	older := info.ModTime().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(configFile, older, older))
+	watcher.checkForUpdate()
+	assert.Equal(t, 3, applied)
+	data, err := os.ReadFile(configFile)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(configFile, append(data, "log_level: debug\n"...), 0644))
+	require.NoError(t, os.Chtimes(configFile, older, older))
+	watcher.checkForUpdate()
+	assert.Equal(t, 4, applied)
+}
+
+func TestConfigWatcher_AuditLog(t *testing.T) {
+	base := `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+health_check:
+  interval: 10s
+  timeout: 2s
+`
+	configFile := createTempConfigFile(t, base)
+	log, err := audit.Open(filepath.Join(t.TempDir(), "audit.log"))
+	require.NoError(t, err)
+	defer log.Close()
+
+	watcher := NewConfigWatcher(configFile)
+	watcher.SetAuditLog(log)
+
+	modTime := time.Now()
+	write := func(content string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(configFile, []byte(content), 0644))
+		modTime = modTime.Add(time.Second)
+		require.NoError(t, os.Chtimes(configFile, modTime, modTime))
+		watcher.checkForUpdate()
+	}
+
+	watcher.checkForUpdate()
+	write(base + "log_level: debug\n")
+	write(`listen_addr: "invalid"`)
+
+	entries, err := log.Query(audit.Query{})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	for _, e := range entries {
+		assert.Equal(t, "config file "+configFile, e.Actor)
+		assert.Equal(t, "config.reload", e.Action)
+	}
+	assert.Empty(t, entries[0].OldDigest)
+	assert.Equal(t, entries[0].NewDigest, entries[1].OldDigest)
+	assert.Equal(t, []string{"log_level"}, entries[1].Changed)
+	assert.Empty(t, entries[1].Error)
+
+	// Rejected configs are compared with the applied one
+	assert.Equal(t, entries[1].NewDigest, entries[2].OldDigest)
+	assert.Equal(t, []string{"health_check", "listen_addr", "log_level", "services"}, entries[2].Changed)
+	assert.NotEmpty(t, entries[2].Error)
+}
+
+func TestConfigWatcher_Rollback(t *testing.T) {
+	base := `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+health_check:
+  interval: 10s
+  timeout: 2s
+`
+	configFile := createTempConfigFile(t, base)
+	watcher := NewConfigWatcher(configFile)
+	_, err := watcher.Rollback("any")
+	assert.ErrorIs(t, err, ErrSnapshotsDisabled)
+
+	watcher.SetSnapshots(NewSnapshotStore(SnapshotConfig{Dir: filepath.Join(t.TempDir(), "snapshots")}, configFile))
+	var levels []string
+	watcher.Watch(func(cfg *Config) { levels = append(levels, cfg.GetLogLevel()) })
+
+	modTime := time.Now()
+	write := func(content string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(configFile, []byte(content), 0644))
+		modTime = modTime.Add(time.Second)
+		require.NoError(t, os.Chtimes(configFile, modTime, modTime))
+		watcher.checkForUpdate()
+	}
+
+	watcher.checkForUpdate()
+	write(base + "log_level: debug\n")
+	// Rejected configs are not kept
+	write(`listen_addr: "invalid"`)
+
+	snapshots, err := watcher.Snapshots()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	require.Equal(t, []string{"", "debug"}, levels)
+
+	name, err := watcher.Rollback(snapshots[1].Name[:20])
+	require.NoError(t, err)
+	assert.Equal(t, snapshots[1].Name, name)
+	assert.Equal(t, []string{"", "debug", ""}, levels)
+	data, err := os.ReadFile(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, base, string(data))
+
+	// The file is not reloaded again, and the rollback is the latest snapshot
+	watcher.checkForUpdate()
+	assert.Len(t, levels, 3)
+	snapshots, err = watcher.Snapshots()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 3)
+	assert.Equal(t, snapshots[2].Digest, snapshots[0].Digest)
+
+	_, err = watcher.Rollback("missing")
+	assert.ErrorIs(t, err, snapshot.ErrNotFound)
+}
+
 func TestConfigLoad_InValidConfig(t *testing.T) {
 	t.Parallel()
 
@@ -232,7 +458,7 @@ health_check:
 			expectedErr: "server address cannot be empty",
 		},
 		{
-			name: "InvalidHealthCheckInterval",
+			name: "NegativeDedupMaxBodySize",
 			config: `
 listen_addr: ":8080"
 services:
@@ -240,15 +466,17 @@ services:
     balancer_type: "round_robin"
     servers:
       - address: "http://backend1:8080"
-      - address: "http://backend2:8080"
+    dedup:
+      enabled: true
+      max_body_size: -1
 health_check:
-  interval: 0s
+  interval: 10s
   timeout: 2s
 `,
-			expectedErr: "health check interval must be positive",
+			expectedErr: "dedup max_body_size cannot be negative",
 		},
 		{
-			name: "InvalidHealthCheckTimeout",
+			name: "NegativeCacheMaxSize",
 			config: `
 listen_addr: ":8080"
 services:
@@ -256,15 +484,13 @@ services:
     balancer_type: "round_robin"
     servers:
       - address: "http://backend1:8080"
-      - address: "http://backend2:8080"
-health_check:
-  interval: 10s
-  timeout: 0s
+cache:
+  max_size: -1
 `,
-			expectedErr: "health check timeout must be positive",
+			expectedErr: "cache max_size cannot be negative",
 		},
 		{
-			name: "TimeoutGreaterThanInterval",
+			name: "NegativeUpstreamLeakTimeout",
 			config: `
 listen_addr: ":8080"
 services:
@@ -272,15 +498,13 @@ services:
     balancer_type: "round_robin"
     servers:
       - address: "http://backend1:8080"
-      - address: "http://backend2:8080"
-health_check:
-  interval: 5s
-  timeout: 10s
+upstream:
+  leak_timeout: -1s
 `,
-			expectedErr: "health check timeout must be less than interval",
+			expectedErr: "upstream leak_timeout cannot be negative",
 		},
 		{
-			name: "InvalidLogLevel",
+			name: "NegativeUpstreamHTTP2Streams",
 			config: `
 listen_addr: ":8080"
 services:
@@ -288,185 +512,1323 @@ services:
     balancer_type: "round_robin"
     servers:
       - address: "http://backend1:8080"
-      - address: "http://backend2:8080"
-health_check:
-  interval: 10s
-  timeout: 2s
-log_level: "invalid_level"
+upstream:
+  http2:
+    max_concurrent_streams: -1
 `,
-			expectedErr: "invalid log level",
+			expectedErr: "upstream http2 max_concurrent_streams cannot be negative",
 		},
 		{
-			name: "InvalidWeightedRoundRobin",
+			name: "UpstreamDNSMinTTLAboveMaxTTL",
 			config: `
 listen_addr: ":8080"
 services:
-  - name: "api-service"
-    balancer_type: "weighted_round_robin"
+  - name: "web-service"
+    balancer_type: "round_robin"
     servers:
-      - address: "http://api1:8080"
-        weight: 0
-health_check:
-  interval: 10s
-  timeout: 2s
+      - address: "http://backend1:8080"
+upstream:
+  dns:
+    min_ttl: 10m
+    max_ttl: 1m
 `,
-			expectedErr: "invalid weight for server",
+			expectedErr: "upstream dns min_ttl cannot exceed max_ttl",
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			configFile := createTempConfigFile(t, tt.config)
-
-			err := Validate(configFile)
-			if err == nil {
-				t.Fatal("Expected error but got nil")
-			}
-
-			if !strings.Contains(err.Error(), tt.expectedErr) {
-				t.Errorf("Expected error to contain %q, got %q", tt.expectedErr, err.Error())
-			}
-		})
-	}
-}
-
-func TestUpdateListenAddr(t *testing.T) {
-	t.Parallel()
-
-	cfg := NewConfig()
-
-	// Initial value test
-	if cfg.GetListenAddr() != "" {
-		t.Errorf("Expected empty listen address, got %s", cfg.GetListenAddr())
-	}
-
-	// Valid config test
-	expectedAddr := ":8081"
-	cfg.UpdateListenAddr(expectedAddr)
-	if actual := cfg.GetListenAddr(); actual != expectedAddr {
-		t.Errorf("Expected %s, got %s", expectedAddr, actual)
-	}
-
-	// Concurrent update test
-	var wg sync.WaitGroup
-	total := 100
-	for i := 0; i < total; i++ {
-		wg.Add(1)
-		go func(addr string) {
-			defer wg.Done()
-			cfg.UpdateListenAddr(addr)
-			_ = cfg.GetListenAddr()
-		}(fmt.Sprintf(":%d", 8080+i))
-	}
-	wg.Wait()
-
-	// Verify final result
-	var updateCount int
-	for i := 0; i < total; i++ {
-		if cfg.GetListenAddr() == fmt.Sprintf(":%d", 8080+i) {
-			updateCount++
-		}
-	}
-	if updateCount == 0 {
-		t.Error("No successful updates detected")
-	}
-}
-
-func TestUpdateBalancerType(t *testing.T) {
-	t.Parallel()
-
-	cfg := NewConfig()
-	cfg.Services = make(map[string]*ServiceConfig)
-	cfg.Services["web-service"] = &ServiceConfig{} // 初始化服务配置
-
-	// Initial value test
-	if cfg.GetBalancerType("web-service") != "" {
-		t.Errorf("Expected empty balancer type, got %s", cfg.GetBalancerType("web-service"))
-	}
-
-	// Valid config test
-	t.Run("ValidTypes", func(t *testing.T) {
-		testCases := []struct {
-			input    string
-			expected string
-		}{
-			{"round_robin", "round_robin"},
-			{"weighted_round_robin", "weighted_round_robin"},
-			{"least_connections", "least_connections"},
-		}
-
-		for _, tc := range testCases {
-			t.Run(tc.input, func(t *testing.T) {
-				if err := cfg.UpdateBalancerType("web-service", tc.input); err != nil {
-					t.Fatalf("Unexpected error: %v", err)
-				}
-				if actual := cfg.GetBalancerType("web-service"); actual != tc.expected {
-					t.Errorf("Expected %s, got %s", tc.expected, actual)
-				}
-			})
-		}
-	})
-
-	// Invalid type test
-	t.Run("InvalidType", func(t *testing.T) {
-		err := cfg.UpdateBalancerType("web-service", "invalid_type")
-		if err == nil {
-			t.Fatal("Expected error but got nil")
-		}
-		expectedErr := "invalid balancer type"
-		if !strings.Contains(err.Error(), expectedErr) {
-			t.Errorf("Expected error to contain %q, got %q", expectedErr, err.Error())
-		}
-	})
-
-	// Concurrent update test
-	t.Run("ConcurrentUpdates", func(t *testing.T) {
-		var wg sync.WaitGroup
-		types := []string{"round_robin", "weighted_round_robin", "least_connections"}
-		total := 100
-
-		// Reset config to ensure test independence
-		cfg = NewConfig()
-		cfg.Services = make(map[string]*ServiceConfig)
-		cfg.Services["web-service"] = &ServiceConfig{} // Initialize service config
-
-		for i := 0; i < total; i++ {
-			wg.Add(1)
-			go func(index int) {
-				defer wg.Done()
-				bType := types[index%3]
-				cfg.UpdateBalancerType("web-service", bType)
-			}(i)
-		}
-
-		wg.Wait()
-
-		// Verify final result
-		var updateCount int
-		for i := 0; i < total; i++ {
-			if cfg.GetBalancerType("web-service") == types[i%3] {
-				updateCount++
-			}
-		}
-		if updateCount == 0 {
-			t.Error("No successful updates detected")
-		}
-	})
-}
-
-func TestUpdateServers(t *testing.T) {
-	t.Parallel()
-
-	cfg := NewConfig()
-	cfg.Services = make(map[string]*ServiceConfig)
-	cfg.Services["web-service"] = &ServiceConfig{
-		BalancerType: "round_robin",
-	} // Initialize service config
-
-	// Initial value test
-	if len(cfg.GetServers("web-service")) != 0 {
-		t.Errorf("Expected empty servers, got %v", cfg.GetServers("web-service"))
-	}
+		{
+			name: "InvalidClassificationTagName",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+classification:
+  - name: "mobile"
+    user_agent: "(?i)android|iphone"
+    tags:
+      device-class: "mobile"
+`,
+			expectedErr: `classification rule mobile: invalid tag name: "device-class"`,
+		},
+		{
+			name: "RouteTagWithoutClassification",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "auth-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+classification:
+  - user_agent: "(?i)bot"
+    tags:
+      bot: "true"
+routes:
+  - name: "mobile_route"
+    match:
+      path: "/api"
+      tags:
+        class: "mobile"
+    service: "auth-service"
+`,
+			expectedErr: "route mobile_route: tag class is not set by any classification rule",
+		},
+		{
+			name: "FallbackServiceNotFound",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "auth-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+routes:
+  - name: "auth_route"
+    match:
+      path: "/api"
+    service: "auth-service"
+    fallback_service: "static-service"
+`,
+			expectedErr: "route auth_route: fallback service static-service not found",
+		},
+		{
+			name: "UnsupportedTrailingSlashPolicy",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+path_normalization:
+  merge_slashes: true
+  trailing_slash: "add"
+`,
+			expectedErr: "unsupported path_normalization trailing_slash: add",
+		},
+		{
+			name: "UnsupportedEncodedSlashesPolicy",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+path_normalization:
+  encoded_slashes: "escape"
+`,
+			expectedErr: "unsupported path_normalization encoded_slashes: escape",
+		},
+		{
+			name: "InvalidViaName",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+proxy_signature:
+  via: true
+  via_name: "nexus gateway"
+`,
+			expectedErr: `invalid proxy_signature via_name: "nexus gateway"`,
+		},
+		{
+			name: "WarmupPathWithoutSlash",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+    warmup:
+      paths: ["health"]
+`,
+			expectedErr: "service web-service: warmup path must start with /: health",
+		},
+		{
+			name: "AdminAuthUnknownRole",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+admin:
+  enabled: true
+  listen_addr: ":9090"
+  auth:
+    tokens:
+      - token: "secret"
+        role: "superuser"
+`,
+			expectedErr: "unsupported admin role: superuser",
+		},
+		{
+			name: "AdminAuthClientCertsWithoutTLS",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+admin:
+  enabled: true
+  listen_addr: ":9090"
+  auth:
+    client_certs:
+      - common_name: "ops"
+        role: "write"
+`,
+			expectedErr: "admin auth client_certs require admin tls client_auth",
+		},
+		{
+			name: "UnknownMiddlewareType",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+middleware:
+  - type: request_id
+  - type: gzip
+`,
+			expectedErr: "unsupported middleware type: gzip",
+		},
+		{
+			name: "SyslogSinkWithoutAddress",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+middleware:
+  - type: access_log
+    sink: syslog
+    syslog:
+      facility: local3
+`,
+			expectedErr: "syslog access log sink requires an address",
+		},
+		{
+			name: "UnsupportedSyslogFacility",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+middleware:
+  - type: access_log
+    sink: syslog
+    syslog:
+      address: "127.0.0.1:514"
+      facility: local9
+`,
+			expectedErr: "unsupported syslog facility: local9",
+		},
+		{
+			name: "InvalidHTTPSinkURL",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+middleware:
+  - type: access_log
+    sink: http
+    http:
+      url: "collector:4318"
+`,
+			expectedErr: `invalid access log sink url: "collector:4318"`,
+		},
+		{
+			name: "RecordWithoutFile",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+record:
+  enabled: true
+`,
+			expectedErr: "record file is required",
+		},
+		{
+			name: "InvalidServerLabelName",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    labels:
+      tier: "gold"
+    servers:
+      - address: "http://backend1:8080"
+        labels:
+          "availability zone": "a"
+`,
+			expectedErr: "service web-service: server http://backend1:8080: invalid label name: \"availability zone\"",
+		},
+		{
+			name: "InvalidServiceSigningAlgorithm",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+    signing:
+      type: hmac
+      secret: "s3cret"
+      algorithm: md5
+`,
+			expectedErr: "service web-service: unsupported hmac signing algorithm: md5",
+		},
+		{
+			name: "InvalidServiceHealthCheckStatus",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+    health_check:
+      path: "/ready"
+      expected_statuses: [200, 999]
+`,
+			expectedErr: "service web-service: invalid health check expected status: 999",
+		},
+		{
+			name: "GRPCHealthCheckWithPath",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+    health_check:
+      type: grpc
+      path: "/ready"
+`,
+			expectedErr: "service web-service: grpc health checks cannot set path or expected_statuses",
+		},
+		{
+			name: "InvalidHealthCheckInfoPath",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+    health_check:
+      info_path: "version"
+`,
+			expectedErr: "service web-service: health check info_path must start with /",
+		},
+		{
+			name: "GRPCHealthCheckWithInfoPath",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+    health_check:
+      type: grpc
+      info_path: "/version"
+`,
+			expectedErr: "service web-service: grpc health checks cannot set info_path",
+		},
+		{
+			name: "RelativeServerHealthPath",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+        health_path: "status"
+`,
+			expectedErr: "service web-service: health_path of server http://backend1:8080 must start with /",
+		},
+		{
+			name: "GRPCHealthCheckWithServerHealthPath",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+        health_path: "/status"
+    health_check:
+      type: grpc
+`,
+			expectedErr: "service web-service: grpc health checks cannot set server health_path",
+		},
+		{
+			name: "InvalidDiscoveryURL",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    discovery:
+      url: "/.well-known/nexus/instances"
+`,
+			expectedErr: `service web-service: invalid discovery url: "/.well-known/nexus/instances"`,
+		},
+		{
+			name: "RegistrationTTLExceedsMax",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    registration:
+      ttl: 10m
+      max_ttl: 1m
+`,
+			expectedErr: "service web-service: registration ttl cannot exceed max_ttl",
+		},
+		{
+			name: "HealthCheckTLSWithoutKey",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "https://backend1:8443"
+health_check:
+  enabled: true
+  interval: 10s
+  timeout: 2s
+  tls:
+    cert_file: "/etc/nexus/probe.pem"
+`,
+			expectedErr: "health check tls requires both cert_file and key_file",
+		},
+		{
+			name: "RouteWithUnknownService",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+routes:
+  - name: "api"
+    match:
+      path: "/api/*"
+    service: "api-service"
+`,
+			expectedErr: "route api: service api-service not found",
+		},
+		{
+			name: "SplitWithUnknownService",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+routes:
+  - name: "canary"
+    match:
+      path: "/*"
+    split:
+      - service: "web-service"
+        weight: 90
+      - service: "web-v2"
+        weight: 10
+`,
+			expectedErr: "route canary: split service web-v2 not found",
+		},
+		{
+			name: "SplitSelectorMatchesNoServer",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    labels:
+      zone: "a"
+    servers:
+      - address: "http://backend1:8080"
+        labels:
+          version: "v1"
+routes:
+  - name: "canary"
+    match:
+      path: "/*"
+    split:
+      - service: "web-service"
+        weight: 90
+        selector: "version=v1,zone=a"
+      - service: "web-service"
+        weight: 10
+        selector: "version=v2"
+`,
+			expectedErr: `route canary: split selector "version=v2" matches no server of service web-service`,
+		},
+		{
+			name: "DuplicateRouteName",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+routes:
+  - name: "web"
+    match:
+      path: "/*"
+    service: "web-service"
+  - name: "web"
+    match:
+      path: "/static/*"
+    service: "web-service"
+`,
+			expectedErr: "duplicate route name: web",
+		},
+		{
+			name: "OverlappingRoutes",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+  - name: "web-v2"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend2:8080"
+routes:
+  - name: "web"
+    match:
+      path: "/*"
+      headers:
+        X-Version: "2"
+    service: "web-service"
+  - name: "web-new"
+    match:
+      path: "/*"
+      headers:
+        X-Version: "2"
+    service: "web-v2"
+`,
+			expectedErr: "route web-new: same match conditions as route web with a different service",
+		},
+		{
+			name: "LoadSheddingWithoutThreshold",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+load_shedding:
+  enabled: true
+  policies:
+    - pressure: 1
+      percent: 50
+`,
+			expectedErr: "load_shedding requires at least one threshold",
+		},
+		{
+			name: "LoadSheddingInvalidPercent",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+load_shedding:
+  enabled: true
+  cpu: 0.8
+  policies:
+    - pressure: 1
+      percent: 150
+`,
+			expectedErr: "load_shedding policy percent must be between 0 and 100",
+		},
+		{
+			name: "AffinityShortSecret",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+    affinity:
+      secrets: ["short"]
+health_check:
+  interval: 10s
+  timeout: 2s
+`,
+			expectedErr: "affinity secrets must be at least 16 bytes",
+		},
+		{
+			name: "ConcurrencyZeroLimit",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+    concurrency:
+      max_concurrent: 0
+health_check:
+  interval: 10s
+  timeout: 2s
+`,
+			expectedErr: "concurrency max_concurrent must be positive",
+		},
+		{
+			name: "InvalidUpstreamAuthType",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+    upstream_auth:
+      type: "digest"
+health_check:
+  interval: 10s
+  timeout: 2s
+`,
+			expectedErr: "unsupported upstream auth type: digest",
+		},
+		{
+			name: "UnresolvedUpstreamAuthCredential",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+    upstream_auth:
+      type: "bearer"
+      token: "env://NEXUS_TEST_UNSET_UPSTREAM_TOKEN"
+health_check:
+  interval: 10s
+  timeout: 2s
+`,
+			expectedErr: "environment variable NEXUS_TEST_UNSET_UPSTREAM_TOKEN is not set",
+		},
+		{
+			name: "SecretReferenceWithoutProvider",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+    affinity:
+      secrets: ["secret://vault/kv/web#affinity"]
+health_check:
+  interval: 10s
+  timeout: 2s
+`,
+			expectedErr: "no secret provider configured for secret://vault/kv/web#affinity",
+		},
+		{
+			name: "InvalidVaultKVVersion",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+secrets:
+  vault:
+    address: "https://vault.internal:8200"
+    kv_version: 3
+health_check:
+  interval: 10s
+  timeout: 2s
+`,
+			expectedErr: "unsupported vault kv_version: 3",
+		},
+		{
+			name: "VaultTokenSecretReference",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+secrets:
+  vault:
+    address: "https://vault.internal:8200"
+    token: "secret://vault/kv/nexus#token"
+health_check:
+  interval: 10s
+  timeout: 2s
+`,
+			expectedErr: "vault token cannot be a secret reference",
+		},
+		{
+			name: "ClientCertWithoutClientAuth",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+routes:
+  - name: "internal"
+    match:
+      path: "/internal/*"
+    service: "web-service"
+    client_cert:
+      common_names: ["billing"]
+health_check:
+  interval: 10s
+  timeout: 2s
+`,
+			expectedErr: "route internal: client_cert requires tls client_auth",
+		},
+		{
+			name: "InvalidClientAuthMode",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+tls:
+  enabled: true
+  listen_addr: ":8443"
+  certificates:
+    - cert_file: "/etc/nexus/server.crt"
+      key_file: "/etc/nexus/server.key"
+  client_auth:
+    mode: "always"
+    ca_file: "/etc/nexus/clients.pem"
+health_check:
+  interval: 10s
+  timeout: 2s
+`,
+			expectedErr: "unsupported tls client_auth mode: always",
+		},
+		{
+			name: "StatsDTagsWithoutDatadog",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+telemetry:
+  statsd:
+    enabled: true
+    tags: ["env:prod"]
+health_check:
+  interval: 10s
+  timeout: 2s
+`,
+			expectedErr: "statsd tags require the datadog format",
+		},
+		{
+			name: "UnsupportedOpenTelemetryLogsLevel",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+telemetry:
+  opentelemetry:
+    logs:
+      enabled: true
+      level: "trace"
+`,
+			expectedErr: "unsupported opentelemetry logs level: trace",
+		},
+		{
+			name: "OTelSinkWithoutLogs",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+middleware:
+  - type: access_log
+    sink: otel
+`,
+			expectedErr: "otel access log sink requires opentelemetry logs",
+		},
+		{
+			name: "InvalidHealthPushFormat",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+health_check:
+  interval: 10s
+  timeout: 2s
+  push:
+    url: "https://hooks.example.com/health"
+    format: "xml"
+`,
+			expectedErr: "unsupported health push format: xml",
+		},
+		{
+			name: "ShortConsulLeaderTTL",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+leader_election:
+  store: "consul"
+  ttl: 5s
+  consul:
+    address: "http://127.0.0.1:8500"
+`,
+			expectedErr: "leader election ttl must be at least 10s with consul",
+		},
+		{
+			name: "UnsupportedLeaderElectionStore",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+leader_election:
+  store: "etcd"
+`,
+			expectedErr: "unsupported leader election store: etcd",
+		},
+		{
+			name: "PassthroughUnknownService",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+passthrough:
+  listen_addr: ":8443"
+  routes:
+    - sni: ["*.secure.example.com"]
+      service: "vault"
+`,
+			expectedErr: `passthrough route 0: service "vault" not found`,
+		},
+		{
+			name: "PassthroughWithoutRoutes",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+passthrough:
+  listen_addr: ":8443"
+`,
+			expectedErr: "passthrough requires routes or a default_service",
+		},
+		{
+			name: "NegativeSnapshotKeep",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+snapshots:
+  dir: /var/lib/nexus/snapshots
+  keep: -1
+`,
+			expectedErr: "snapshots keep cannot be negative",
+		},
+		{
+			name: "InvalidRealIPTrustedProxy",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+real_ip:
+  trusted_proxies: ["10.0.0.0/33"]
+`,
+			expectedErr: `invalid real_ip trusted proxy: "10.0.0.0/33"`,
+		},
+		{
+			name: "UnsupportedRealIPHeader",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+real_ip:
+  trusted_proxies: ["10.0.0.0/8"]
+  headers: ["X-Forwarded-For", "X-Client-IP"]
+`,
+			expectedErr: `unsupported real_ip header: "X-Client-IP"`,
+		},
+		{
+			name: "RealIPHeadersWithoutTrustedProxies",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+real_ip:
+  headers: ["X-Real-IP"]
+`,
+			expectedErr: "real_ip headers require trusted_proxies",
+		},
+		{
+			name: "InvalidMaintenanceCron",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+        maintenance:
+          - cron: "0 25 * * *"
+            duration: 1h
+health_check:
+  interval: 10s
+  timeout: 2s
+`,
+			expectedErr: "maintenance window: invalid cron expression",
+		},
+		{
+			name: "MaintenanceWindowEndBeforeStart",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+        maintenance:
+          - start: 2025-03-14T04:00:00Z
+            end: 2025-03-14T02:00:00Z
+health_check:
+  interval: 10s
+  timeout: 2s
+`,
+			expectedErr: "maintenance window end must be after start",
+		},
+		{
+			name: "AdminWithoutListenAddr",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+health_check:
+  interval: 10s
+  timeout: 2s
+admin:
+  enabled: true
+`,
+			expectedErr: "admin listen address cannot be empty",
+		},
+		{
+			name: "TLSWithoutCertificates",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+health_check:
+  interval: 10s
+  timeout: 2s
+tls:
+  enabled: true
+  listen_addr: ":8443"
+`,
+			expectedErr: "tls requires at least one certificate",
+		},
+		{
+			name: "TLSCertificateWithoutKey",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+health_check:
+  interval: 10s
+  timeout: 2s
+tls:
+  enabled: true
+  listen_addr: ":8443"
+  certificates:
+    - cert_file: "/etc/nexus/example.crt"
+`,
+			expectedErr: "tls certificate requires cert_file and key_file",
+		},
+		{
+			name: "RedisRateLimitWithoutAddress",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+health_check:
+  interval: 10s
+  timeout: 2s
+rate_limit:
+  store: "redis"
+`,
+			expectedErr: "redis address cannot be empty",
+		},
+		{
+			name: "UnsupportedSharedHealthStore",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+health_check:
+  interval: 10s
+  timeout: 2s
+  shared:
+    store: "gossip"
+`,
+			expectedErr: "unsupported shared health store: gossip",
+		},
+		{
+			name: "GeoMatchWithoutDatabase",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+routes:
+  - name: "eu_route"
+    match:
+      path: "/shop"
+      countries: ["DE"]
+    service: "web-service"
+health_check:
+  interval: 10s
+  timeout: 2s
+`,
+			expectedErr: "countries and continents require a geoip database",
+		},
+		{
+			name: "InvalidHealthCheckInterval",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+      - address: "http://backend2:8080"
+health_check:
+  interval: 0s
+  timeout: 2s
+`,
+			expectedErr: "health check interval must be positive",
+		},
+		{
+			name: "InvalidHealthCheckTimeout",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+      - address: "http://backend2:8080"
+health_check:
+  interval: 10s
+  timeout: 0s
+`,
+			expectedErr: "health check timeout must be positive",
+		},
+		{
+			name: "TimeoutGreaterThanInterval",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+      - address: "http://backend2:8080"
+health_check:
+  interval: 5s
+  timeout: 10s
+`,
+			expectedErr: "health check timeout must be less than interval",
+		},
+		{
+			name: "InvalidLogLevel",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "web-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+      - address: "http://backend2:8080"
+health_check:
+  interval: 10s
+  timeout: 2s
+log_level: "invalid_level"
+`,
+			expectedErr: "invalid log level",
+		},
+		{
+			name: "InvalidWeightedRoundRobin",
+			config: `
+listen_addr: ":8080"
+services:
+  - name: "api-service"
+    balancer_type: "weighted_round_robin"
+    servers:
+      - address: "http://api1:8080"
+        weight: 0
+health_check:
+  interval: 10s
+  timeout: 2s
+`,
+			expectedErr: "invalid weight for server",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := createTempConfigFile(t, tt.config)
+
+			err := Validate(configFile)
+			if err == nil {
+				t.Fatal("Expected error but got nil")
+			}
+
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error to contain %q, got %q", tt.expectedErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestUpdateListenAddr(t *testing.T) {
+	t.Parallel()
+
+	cfg := NewConfig()
+
+	// Initial value test
+	if cfg.GetListenAddr() != "" {
+		t.Errorf("Expected empty listen address, got %s", cfg.GetListenAddr())
+	}
+
+	// Valid config test
+	expectedAddr := ":8081"
+	cfg.UpdateListenAddr(expectedAddr)
+	if actual := cfg.GetListenAddr(); actual != expectedAddr {
+		t.Errorf("Expected %s, got %s", expectedAddr, actual)
+	}
+
+	// Concurrent update test
+	var wg sync.WaitGroup
+	total := 100
+	for i := 0; i < total; i++ {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			cfg.UpdateListenAddr(addr)
+			_ = cfg.GetListenAddr()
+		}(fmt.Sprintf(":%d", 8080+i))
+	}
+	wg.Wait()
+
+	// Verify final result
+	var updateCount int
+	for i := 0; i < total; i++ {
+		if cfg.GetListenAddr() == fmt.Sprintf(":%d", 8080+i) {
+			updateCount++
+		}
+	}
+	if updateCount == 0 {
+		t.Error("No successful updates detected")
+	}
+}
+
+func TestUpdateBalancerType(t *testing.T) {
+	t.Parallel()
+
+	cfg := NewConfig()
+	cfg.Services = make(map[string]*ServiceConfig)
+	cfg.Services["web-service"] = &ServiceConfig{} // 初始化服务配置
+
+	// Initial value test
+	if cfg.GetBalancerType("web-service") != "" {
+		t.Errorf("Expected empty balancer type, got %s", cfg.GetBalancerType("web-service"))
+	}
+
+	// Valid config test
+	t.Run("ValidTypes", func(t *testing.T) {
+		testCases := []struct {
+			input    string
+			expected string
+		}{
+			{"round_robin", "round_robin"},
+			{"weighted_round_robin", "weighted_round_robin"},
+			{"least_connections", "least_connections"},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.input, func(t *testing.T) {
+				if err := cfg.UpdateBalancerType("web-service", tc.input); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if actual := cfg.GetBalancerType("web-service"); actual != tc.expected {
+					t.Errorf("Expected %s, got %s", tc.expected, actual)
+				}
+			})
+		}
+	})
+
+	// Invalid type test
+	t.Run("InvalidType", func(t *testing.T) {
+		err := cfg.UpdateBalancerType("web-service", "invalid_type")
+		if err == nil {
+			t.Fatal("Expected error but got nil")
+		}
+		expectedErr := "invalid balancer type"
+		if !strings.Contains(err.Error(), expectedErr) {
+			t.Errorf("Expected error to contain %q, got %q", expectedErr, err.Error())
+		}
+	})
+
+	t.Run("UnknownService", func(t *testing.T) {
+		err := cfg.UpdateBalancerType("missing", "round_robin")
+		if !errors.Is(err, ErrServiceNotFound) || err.Error() != "service missing not found" {
+			t.Errorf("Expected service missing not found, got %v", err)
+		}
+	})
+
+	// Concurrent update test
+	t.Run("ConcurrentUpdates", func(t *testing.T) {
+		var wg sync.WaitGroup
+		types := []string{"round_robin", "weighted_round_robin", "least_connections"}
+		total := 100
+
+		// Reset config to ensure test independence
+		cfg = NewConfig()
+		cfg.Services = make(map[string]*ServiceConfig)
+		cfg.Services["web-service"] = &ServiceConfig{} // Initialize service config
+
+		for i := 0; i < total; i++ {
+			wg.Add(1)
+			go func(index int) {
+				defer wg.Done()
+				bType := types[index%3]
+				cfg.UpdateBalancerType("web-service", bType)
+			}(i)
+		}
+
+		wg.Wait()
+
+		// Verify final result
+		var updateCount int
+		for i := 0; i < total; i++ {
+			if cfg.GetBalancerType("web-service") == types[i%3] {
+				updateCount++
+			}
+		}
+		if updateCount == 0 {
+			t.Error("No successful updates detected")
+		}
+	})
+}
+
+func TestUpdateServers(t *testing.T) {
+	t.Parallel()
+
+	cfg := NewConfig()
+	cfg.Services = make(map[string]*ServiceConfig)
+	cfg.Services["web-service"] = &ServiceConfig{
+		BalancerType: "round_robin",
+	} // Initialize service config
+
+	// Initial value test
+	if len(cfg.GetServers("web-service")) != 0 {
+		t.Errorf("Expected empty servers, got %v", cfg.GetServers("web-service"))
+	}
 
 	// Valid config test
 	t.Run("ValidServers", func(t *testing.T) {
@@ -753,247 +2115,1182 @@ func TestTelemetryConfig(t *testing.T) {
 		expected TelemetryConfig
 	}{
 		{
-			name: "default_opentelemetry",
+			name: "default_opentelemetry",
+			config: `
+telemetry:
+  opentelemetry:
+    enabled: true
+    endpoint: "localhost:4317"
+    service_name: "nexus-service"
+    metrics:
+      interval: 30s`,
+			expected: TelemetryConfig{
+				OpenTelemetry: OpenTelemetryConfig{
+					Enabled:     true,
+					Endpoint:    "localhost:4317",
+					ServiceName: "nexus-service",
+					Metrics: MetricConfig{
+						Interval: 30 * time.Second,
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewConfig()
+			tmpFile := createTempConfigFile(t, tc.config)
+			require.NoError(t, cfg.LoadFromFile(tmpFile))
+
+			// 验证配置解析
+			telemetryCfg := cfg.GetTelemetryConfig()
+			assert.Equal(t, tc.expected.OpenTelemetry.Enabled, telemetryCfg.OpenTelemetry.Enabled)
+			assert.Equal(t, tc.expected.OpenTelemetry.Endpoint, telemetryCfg.OpenTelemetry.Endpoint)
+			assert.Equal(t, tc.expected.OpenTelemetry.ServiceName, telemetryCfg.OpenTelemetry.ServiceName)
+			assert.Equal(t, tc.expected.OpenTelemetry.Metrics.Interval, telemetryCfg.OpenTelemetry.Metrics.Interval)
+		})
+	}
+
+	// Test concurrent access
+	t.Run("concurrent_access", func(t *testing.T) {
+		cfg := NewConfig()
+		tmpFile := createTempConfigFile(t, testCases[0].config)
+		require.NoError(t, cfg.LoadFromFile(tmpFile))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				telemetryCfg := cfg.GetTelemetryConfig()
+				assert.True(t, telemetryCfg.OpenTelemetry.Enabled)
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+// Route config test
+func TestRouteConfig(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		config      string
+		expectedErr string
+	}{
+		{
+			name: "valid_route_with_service",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "user_route"
+    match:
+      path: "/api/v1/users/**"
+    service: "user-service"
+`,
+			expectedErr: "",
+		},
+		{
+			name: "valid_route_with_split",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "canary_route"
+    match:
+      path: "/api/*/checkout"
+    split:
+      - service: "checkout-v1"
+        weight: 70
+      - service: "checkout-v2"
+        weight: 30
+`,
+			expectedErr: "",
+		},
+		{
+			name: "valid_route_with_experiment",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "checkout_route"
+    match:
+      path: "/checkout"
+    experiment:
+      key: "header:X-User-Id"
+      variants:
+        - name: "control"
+          service: "checkout-v1"
+          weight: 50
+        - name: "treatment"
+          service: "checkout-v2"
+          weight: 50
+`,
+			expectedErr: "",
+		},
+		{
+			name: "invalid_route_experiment_with_service",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "checkout_route"
+    match:
+      path: "/checkout"
+    service: "checkout-v1"
+    experiment:
+      variants:
+        - name: "control"
+          service: "checkout-v1"
+          weight: 1
+`,
+			expectedErr: "route checkout_route: experiment cannot be combined with service or split",
+		},
+		{
+			name: "invalid_route_experiment_duplicate_variant",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "checkout_route"
+    match:
+      path: "/checkout"
+    experiment:
+      variants:
+        - name: "control"
+          service: "checkout-v1"
+          weight: 1
+        - name: "control"
+          service: "checkout-v2"
+          weight: 1
+`,
+			expectedErr: "route checkout_route: duplicate experiment variant: control",
+		},
+		{
+			name: "invalid_route_experiment_key",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "checkout_route"
+    match:
+      path: "/checkout"
+    experiment:
+      key: "query:user"
+      variants:
+        - name: "control"
+          service: "checkout-v1"
+          weight: 1
+`,
+			expectedErr: "route checkout_route: unsupported experiment key: query:user",
+		},
+		{
+			name: "valid_route_with_fault",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "chaos_route"
+    match:
+      path: "/api"
+    service: "api"
+    fault:
+      header: "X-Chaos"
+      delay:
+        duration: 200ms
+        jitter: 50ms
+        percentage: 10
+      abort:
+        status: 503
+        percentage: 5
+`,
+			expectedErr: "",
+		},
+		{
+			name: "invalid_route_fault_percentage",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "chaos_route"
+    match:
+      path: "/api"
+    service: "api"
+    fault:
+      abort:
+        status: 503
+        percentage: 150
+`,
+			expectedErr: "route chaos_route: fault abort percentage must be between 0 and 100",
+		},
+		{
+			name: "invalid_route_fault_empty",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "chaos_route"
+    match:
+      path: "/api"
+    service: "api"
+    fault:
+      header: "X-Chaos"
+`,
+			expectedErr: "route chaos_route: fault requires delay, abort or corrupt",
+		},
+		{
+			name: "valid_route_with_bandwidth",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "download_route"
+    match:
+      path: "/downloads/**"
+    service: "files"
+    bandwidth:
+      response: 1048576
+      request: 65536
+      key: "header:X-Api-Key"
+`,
+			expectedErr: "",
+		},
+		{
+			name: "invalid_route_bandwidth_key",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "download_route"
+    match:
+      path: "/downloads/**"
+    service: "files"
+    bandwidth:
+      response: 1048576
+      key: "cookie:session"
+`,
+			expectedErr: "route download_route: unsupported bandwidth key: cookie:session",
+		},
+		{
+			name: "valid_route_with_slo",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "api_route"
+    match:
+      path: "/api/**"
+    service: "api"
+    slo:
+      objective: 99.9
+      latency: 300ms
+      window: 30m
+      alert_burn_rate: 14.4
+`,
+			expectedErr: "",
+		},
+		{
+			name: "invalid_route_slo_objective",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "api_route"
+    match:
+      path: "/api/**"
+    service: "api"
+    slo:
+      objective: 100
+      latency: 300ms
+`,
+			expectedErr: "route api_route: slo objective must be between 0 and 100",
+		},
+		{
+			name: "invalid_route_slo_window",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "api_route"
+    match:
+      path: "/api/**"
+    service: "api"
+    slo:
+      objective: 99
+      latency: 300ms
+      window: 30s
+`,
+			expectedErr: "route api_route: slo window must be at least 1m",
+		},
+		{
+			name: "valid_route_with_deprecation",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "v1_route"
+    match:
+      path: "/v1/**"
+    service: "api"
+    deprecation:
+      since: 2025-01-01T00:00:00Z
+      sunset: 2025-06-30T00:00:00Z
+      link: "https://docs.example.com/migrate-v2"
+      gone: true
+      log_key: "header:X-Client-Id"
+`,
+			expectedErr: "",
+		},
+		{
+			name: "invalid_route_deprecation_gone_without_sunset",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "v1_route"
+    match:
+      path: "/v1/**"
+    service: "api"
+    deprecation:
+      gone: true
+`,
+			expectedErr: "route v1_route: deprecation gone requires a sunset",
+		},
+		{
+			name: "invalid_route_deprecation_sunset_before_since",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "v1_route"
+    match:
+      path: "/v1/**"
+    service: "api"
+    deprecation:
+      since: 2025-06-30T00:00:00Z
+      sunset: 2025-01-01T00:00:00Z
+`,
+			expectedErr: "route v1_route: deprecation sunset cannot be before since",
+		},
+		{
+			name: "invalid_route_deprecation_log_key",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "v1_route"
+    match:
+      path: "/v1/**"
+    service: "api"
+    deprecation:
+      log_key: "route"
+`,
+			expectedErr: "route v1_route: unsupported deprecation log_key: route",
+		},
+		{
+			name: "valid_route_with_sanitize",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "upload_route"
+    match:
+      path: "/upload"
+    service: "files"
+    allowed_content_types: ["application/json", "image/*"]
+    sanitize:
+      mode: "strip"
+      max_header_size: 4096
+`,
+			expectedErr: "",
+		},
+		{
+			name: "invalid_route_allowed_content_type",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "upload_route"
+    match:
+      path: "/upload"
+    service: "files"
+    allowed_content_types: ["json"]
+`,
+			expectedErr: `route upload_route: invalid allowed content type: "json"`,
+		},
+		{
+			name: "invalid_route_sanitize_mode",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "upload_route"
+    match:
+      path: "/upload"
+    service: "files"
+    sanitize:
+      mode: "fix"
+`,
+			expectedErr: "route upload_route: unsupported sanitize mode: fix",
+		},
+		{
+			name: "invalid_route_missing_name",
+			config: `
+listen_addr: ":8080"
+routes:
+  - match:
+      path: "/api/v1/users/**"
+    service: "user-service"
+`,
+			expectedErr: "route name cannot be empty",
+		},
+		{
+			name: "invalid_route_missing_match",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "user_route"
+    service: "user-service"
+`,
+			expectedErr: "match condition cannot be empty",
+		},
+		{
+			name: "invalid_route_missing_service_and_split",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "user_route"
+    match:
+      path: "/api/v1/users/**"
+`,
+			expectedErr: "must specify either service or split",
+		},
+		{
+			name: "invalid_route_split_weights",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "canary_route"
+    match:
+      path: "/api/*/checkout"
+    split:
+      - service: "checkout-v1"
+        weight: 60
+      - service: "checkout-v2"
+        weight: 30
+`,
+			expectedErr: "split weights must sum to 100",
+		},
+		{
+			name: "invalid_route_empty_split_service",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "canary_route"
+    match:
+      path: "/api/*/checkout"
+    split:
+      - service: ""
+        weight: 50
+      - service: "checkout-v2"
+        weight: 50
+`,
+			expectedErr: "split service cannot be empty",
+		},
+		{
+			name: "invalid_route_negative_split_weight",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "canary_route"
+    match:
+      path: "/api/*/checkout"
+    split:
+      - service: "checkout-v1"
+        weight: -10
+      - service: "checkout-v2"
+        weight: 110
+`,
+			expectedErr: "split weight must be positive",
+		},
+		{
+			name: "valid_route_with_header_match",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "header_route"
+    match:
+      headers:
+        X-Service-Group: "v2"
+    service: "user-service"
+`,
+			expectedErr: "",
+		},
+		{
+			name: "valid_route_with_method_match",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "method_route"
+    match:
+      method: "GET"
+    service: "user-service"
+`,
+			expectedErr: "",
+		},
+		{
+			name: "valid_route_with_host_match",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "host_route"
+    match:
+      host: "example.com"
+    service: "user-service"
+`,
+			expectedErr: "",
+		},
+		{
+			name: "valid_route_with_multiple_match_conditions",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "complex_route"
+    match:
+      path: "/api/v1/users/**"
+      method: "POST"
+      host: "api.example.com"
+      headers:
+        X-Service-Group: "v2"
+    service: "user-service"
+`,
+			expectedErr: "",
+		},
+		{
+			name: "invalid_route_with_zero_split_weight",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "canary_route"
+    match:
+      path: "/api/*/checkout"
+    split:
+      - service: "checkout-v1"
+        weight: 0
+      - service: "checkout-v2"
+        weight: 100
+`,
+			expectedErr: "split weight must be positive",
+		},
+		{
+			name: "valid_route_with_hedge",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "hedge_route"
+    match:
+      path: "/api/search"
+    service: "search-service"
+    hedge:
+      delay: 50ms
+      max_hedges: 2
+      budget: 10
+`,
+			expectedErr: "",
+		},
+		{
+			name: "invalid_route_hedge_delay",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "hedge_route"
+    match:
+      path: "/api/search"
+    service: "search-service"
+    hedge:
+      max_hedges: 1
+`,
+			expectedErr: "hedge delay must be positive",
+		},
+		{
+			name: "invalid_route_hedge_budget",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "hedge_route"
+    match:
+      path: "/api/search"
+    service: "search-service"
+    hedge:
+      delay: 50ms
+      max_hedges: 1
+      budget: 150
+`,
+			expectedErr: "hedge budget must be between 0 and 100",
+		},
+		{
+			name: "valid_route_with_expect",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "expect_route"
+    match:
+      path: "/api/users"
+    service: "user-service"
+    expect:
+      status_codes: [200, 204]
+      content_type: "application/json"
+      json_fields: ["data.id"]
+      max_retries: 2
+`,
+			expectedErr: "",
+		},
+		{
+			name: "invalid_route_expect_status",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "expect_route"
+    match:
+      path: "/api/users"
+    service: "user-service"
+    expect:
+      status_codes: [700]
+`,
+			expectedErr: "invalid expected status code: 700",
+		},
+		{
+			name: "invalid_route_expect_json_field",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "expect_route"
+    match:
+      path: "/api/users"
+    service: "user-service"
+    expect:
+      json_fields: ["data."]
+`,
+			expectedErr: "invalid expected json field",
+		},
+		{
+			name: "valid_route_with_location_rewrite",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "app_route"
+    match:
+      path: "/app/*"
+    service: "app-service"
+    location_rewrite:
+      scheme: "https"
+      host: "www.example.com"
+`,
+			expectedErr: "",
+		},
+		{
+			name: "invalid_route_location_rewrite_scheme",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "app_route"
+    match:
+      path: "/app/*"
+    service: "app-service"
+    location_rewrite:
+      scheme: "ftp"
+`,
+			expectedErr: "unsupported location rewrite scheme: ftp",
+		},
+		{
+			name: "invalid_route_location_rewrite_host",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "app_route"
+    match:
+      path: "/app/*"
+    service: "app-service"
+    location_rewrite:
+      host: "https://www.example.com"
+`,
+			expectedErr: "location rewrite host must be a host name",
+		},
+		{
+			name: "valid_route_with_sub_filter",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "app_route"
+    match:
+      path: "/app/*"
+    service: "app-service"
+    sub_filter:
+      replacements:
+        - from: "http://app.internal:8080"
+          to: "https://www.example.com"
+        - pattern: "internal-([a-z]+)\\.svc"
+          to: "$1.example.com"
+      content_types: ["text/html", "application/json"]
+      max_body_size: 4096
+`,
+			expectedErr: "",
+		},
+		{
+			name: "invalid_route_sub_filter_replacement",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "app_route"
+    match:
+      path: "/app/*"
+    service: "app-service"
+    sub_filter:
+      replacements:
+        - from: "a"
+          pattern: "b"
+`,
+			expectedErr: "sub filter replacement requires exactly one of from or pattern",
+		},
+		{
+			name: "invalid_route_sub_filter_pattern",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "app_route"
+    match:
+      path: "/app/*"
+    service: "app-service"
+    sub_filter:
+      replacements:
+        - pattern: "internal-("
+`,
+			expectedErr: "invalid sub filter pattern",
+		},
+		{
+			name: "valid_route_with_oidc",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "app_route"
+    match:
+      path: "/*"
+    service: "app-service"
+    oidc:
+      issuer: "https://login.example.com/realms/main"
+      client_id: "nexus"
+      client_secret: "client-secret"
+      cookie_secret: "0123456789abcdef0123456789abcdef"
+      redirect_url: "https://app.example.com/oauth2/callback"
+      logout_path: "/logout"
+      claim_headers:
+        X-Forwarded-User: "preferred_username"
+        X-Forwarded-Groups: "groups"
+`,
+			expectedErr: "",
+		},
+		{
+			name: "invalid_route_oidc_cookie_secret",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "app_route"
+    match:
+      path: "/*"
+    service: "app-service"
+    oidc:
+      issuer: "https://login.example.com"
+      client_id: "nexus"
+      cookie_secret: "short"
+`,
+			expectedErr: "oidc cookie_secret must be at least 16 bytes",
+		},
+		{
+			name: "invalid_route_oidc_issuer",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "app_route"
+    match:
+      path: "/*"
+    service: "app-service"
+    oidc:
+      issuer: "login.example.com"
+      client_id: "nexus"
+      cookie_secret: "0123456789abcdef"
+`,
+			expectedErr: "invalid oidc issuer",
+		},
+		{
+			name: "valid_route_with_cache",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "static_route"
+    match:
+      path: "/static/*"
+    service: "static-service"
+    cache:
+      ttl: 5m
+`,
+		},
+		{
+			name: "invalid_route_cache_ttl",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "static_route"
+    match:
+      path: "/static/*"
+    service: "static-service"
+    cache:
+      ttl: -1s
+`,
+			expectedErr: "cache ttl cannot be negative",
+		},
+		{
+			name: "valid_route_with_upstream_compression",
 			config: `
-telemetry:
-  opentelemetry:
-    enabled: true
-    endpoint: "localhost:4317"
-    service_name: "nexus-service"
-    metrics:
-      interval: 30s`,
-			expected: TelemetryConfig{
-				OpenTelemetry: OpenTelemetryConfig{
-					Enabled:     true,
-					Endpoint:    "localhost:4317",
-					ServiceName: "nexus-service",
-					Metrics: MetricConfig{
-						Interval: 30 * time.Second,
-					},
-				},
-			},
+listen_addr: ":8080"
+routes:
+  - name: "api_route"
+    match:
+      path: "/api/*"
+    service: "api-service"
+    upstream_compression:
+      request: true
+      content_types: ["application/json", "text/*"]
+      decode_responses: true
+`,
 		},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			cfg := NewConfig()
-			tmpFile := createTempConfigFile(t, tc.config)
-			require.NoError(t, cfg.LoadFromFile(tmpFile))
-
-			// 验证配置解析
-			telemetryCfg := cfg.GetTelemetryConfig()
-			assert.Equal(t, tc.expected.OpenTelemetry.Enabled, telemetryCfg.OpenTelemetry.Enabled)
-			assert.Equal(t, tc.expected.OpenTelemetry.Endpoint, telemetryCfg.OpenTelemetry.Endpoint)
-			assert.Equal(t, tc.expected.OpenTelemetry.ServiceName, telemetryCfg.OpenTelemetry.ServiceName)
-			assert.Equal(t, tc.expected.OpenTelemetry.Metrics.Interval, telemetryCfg.OpenTelemetry.Metrics.Interval)
-		})
-	}
-
-	// Test concurrent access
-	t.Run("concurrent_access", func(t *testing.T) {
-		cfg := NewConfig()
-		tmpFile := createTempConfigFile(t, testCases[0].config)
-		require.NoError(t, cfg.LoadFromFile(tmpFile))
-
-		var wg sync.WaitGroup
-		for i := 0; i < 10; i++ {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				telemetryCfg := cfg.GetTelemetryConfig()
-				assert.True(t, telemetryCfg.OpenTelemetry.Enabled)
-			}()
-		}
-		wg.Wait()
-	})
-}
-
-// Route config test
-func TestRouteConfig(t *testing.T) {
-	t.Parallel()
-
-	testCases := []struct {
-		name        string
-		config      string
-		expectedErr string
-	}{
 		{
-			name: "valid_route_with_service",
+			name: "invalid_upstream_compression_content_type",
 			config: `
 listen_addr: ":8080"
 routes:
-  - name: "user_route"
+  - name: "api_route"
     match:
-      path: "/api/v1/users/**"
-    service: "user-service"
+      path: "/api/*"
+    service: "api-service"
+    upstream_compression:
+      request: true
+      content_types: ["json"]
 `,
-			expectedErr: "",
+			expectedErr: "invalid upstream compression content type",
 		},
 		{
-			name: "valid_route_with_split",
+			name: "valid_route_with_buffering",
 			config: `
 listen_addr: ":8080"
 routes:
-  - name: "canary_route"
+  - name: "download_route"
     match:
-      path: "/api/*/checkout"
-    split:
-      - service: "checkout-v1"
-        weight: 70
-      - service: "checkout-v2"
-        weight: 30
+      path: "/files/*"
+    service: "api-service"
+    buffering:
+      response: true
+      max_buffer_size: 4194304
+      flush_interval: -1ms
+      buffer_size: 65536
 `,
-			expectedErr: "",
 		},
 		{
-			name: "invalid_route_missing_name",
+			name: "invalid_buffering_max_buffer_size",
 			config: `
 listen_addr: ":8080"
 routes:
-  - match:
-      path: "/api/v1/users/**"
-    service: "user-service"
+  - name: "download_route"
+    match:
+      path: "/files/*"
+    service: "api-service"
+    buffering:
+      response: true
+      max_buffer_size: -1
 `,
-			expectedErr: "route name cannot be empty",
+			expectedErr: "buffering max_buffer_size cannot be negative",
 		},
 		{
-			name: "invalid_route_missing_match",
+			name: "invalid_error_page_status_code",
 			config: `
 listen_addr: ":8080"
 routes:
-  - name: "user_route"
-    service: "user-service"
+  - name: "web_route"
+    match:
+      path: "/*"
+    service: "web-service"
+    error_pages:
+      - status_codes: [302]
+        file: "/var/www/error.html"
 `,
-			expectedErr: "match condition cannot be empty",
+			expectedErr: "invalid error page status code: 302",
 		},
 		{
-			name: "invalid_route_missing_service_and_split",
+			name: "error_page_without_file_or_template",
 			config: `
 listen_addr: ":8080"
 routes:
-  - name: "user_route"
+  - name: "web_route"
     match:
-      path: "/api/v1/users/**"
+      path: "/*"
+    service: "web-service"
+    error_pages:
+      - status_codes: [502, 503]
 `,
-			expectedErr: "must specify either service or split",
+			expectedErr: "error page requires exactly one of file or template",
 		},
 		{
-			name: "invalid_route_split_weights",
+			name: "valid_route_with_priority",
 			config: `
 listen_addr: ":8080"
 routes:
-  - name: "canary_route"
+  - name: "reports_route"
     match:
-      path: "/api/*/checkout"
-    split:
-      - service: "checkout-v1"
-        weight: 60
-      - service: "checkout-v2"
-        weight: 30
+      path: "/reports/*"
+    service: "reports-service"
+    priority: "best-effort"
 `,
-			expectedErr: "split weights must sum to 100",
 		},
 		{
-			name: "invalid_route_empty_split_service",
+			name: "invalid_route_priority",
 			config: `
 listen_addr: ":8080"
 routes:
-  - name: "canary_route"
+  - name: "reports_route"
     match:
-      path: "/api/*/checkout"
-    split:
-      - service: ""
-        weight: 50
-      - service: "checkout-v2"
-        weight: 50
+      path: "/reports/*"
+    service: "reports-service"
+    priority: "low"
 `,
-			expectedErr: "split service cannot be empty",
+			expectedErr: "unsupported priority: low",
 		},
 		{
-			name: "invalid_route_negative_split_weight",
+			name: "valid_route_with_rate_limit",
 			config: `
 listen_addr: ":8080"
 routes:
-  - name: "canary_route"
+  - name: "limited_route"
     match:
-      path: "/api/*/checkout"
-    split:
-      - service: "checkout-v1"
-        weight: -10
-      - service: "checkout-v2"
-        weight: 110
+      path: "/api/login"
+    service: "auth-service"
+    rate_limit:
+      requests: 10
+      window: 1m
+      key: "header:X-Api-Key"
 `,
-			expectedErr: "split weight must be positive",
+			expectedErr: "",
 		},
 		{
-			name: "valid_route_with_header_match",
+			name: "invalid_route_rate_limit_window",
 			config: `
 listen_addr: ":8080"
 routes:
-  - name: "header_route"
+  - name: "limited_route"
     match:
-      headers:
-        X-Service-Group: "v2"
-    service: "user-service"
+      path: "/api/login"
+    service: "auth-service"
+    rate_limit:
+      requests: 10
+`,
+			expectedErr: "rate limit window must be positive",
+		},
+		{
+			name: "invalid_route_rate_limit_key",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "limited_route"
+    match:
+      path: "/api/login"
+    service: "auth-service"
+    rate_limit:
+      requests: 10
+      window: 1m
+      key: "cookie"
+`,
+			expectedErr: "unsupported rate limit key: cookie",
+		},
+		{
+			name: "invalid_route_rate_limit_tarpit_factor",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "limited_route"
+    match:
+      path: "/api/login"
+    service: "auth-service"
+    rate_limit:
+      requests: 10
+      window: 1m
+      tarpit:
+        factor: 0.5
+`,
+			expectedErr: "rate limit tarpit factor must be at least 1",
+		},
+		{
+			name: "invalid_route_fallback_status_codes_without_service",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "site_route"
+    match:
+      path: "/"
+    service: "site-service"
+    fallback_status_codes: [503]
+`,
+			expectedErr: "route site_route: fallback_status_codes require a fallback_service",
+		},
+		{
+			name: "invalid_route_forwarding_expect_continue",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "upload_route"
+    match:
+      path: "/upload"
+    service: "upload-service"
+    forwarding:
+      expect_continue: "ignore"
+`,
+			expectedErr: "route upload_route: unsupported expect_continue: ignore",
+		},
+		{
+			name: "valid_route_with_body_match",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "rpc_route"
+    match:
+      path: "/rpc"
+      body:
+        json_field: "method"
+        value: "orders.create"
+        max_size: 8192
+    service: "order-service"
 `,
 			expectedErr: "",
 		},
 		{
-			name: "valid_route_with_method_match",
+			name: "invalid_route_body_match_conditions",
 			config: `
 listen_addr: ":8080"
 routes:
-  - name: "method_route"
+  - name: "rpc_route"
     match:
-      method: "GET"
-    service: "user-service"
+      path: "/rpc"
+      body:
+        json_field: "method"
+        pattern: "orders"
+    service: "order-service"
+`,
+			expectedErr: "body match requires exactly one of json_field, soap_action or pattern",
+		},
+		{
+			name: "invalid_route_body_match_size",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "rpc_route"
+    match:
+      path: "/rpc"
+      body:
+        soap_action: "SubmitPayment"
+        max_size: 10485760
+    service: "order-service"
+`,
+			expectedErr: "body match max_size must be between 0 and 1048576",
+		},
+		{
+			name: "valid_route_with_locale_match",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "eu_route"
+    match:
+      path: "/shop"
+      languages: ["de", "fr-CH"]
+      continents: ["EU"]
+    service: "eu-service"
 `,
 			expectedErr: "",
 		},
 		{
-			name: "valid_route_with_host_match",
+			name: "invalid_route_continent",
 			config: `
 listen_addr: ":8080"
 routes:
-  - name: "host_route"
+  - name: "eu_route"
     match:
-      host: "example.com"
-    service: "user-service"
+      path: "/shop"
+      continents: ["Europe"]
+    service: "eu-service"
+`,
+			expectedErr: "invalid match continent",
+		},
+		{
+			name: "valid_route_with_time_match",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "business_hours"
+    match:
+      path: "/support"
+      time:
+        weekdays: ["mon-fri"]
+        hours: "09:00-17:00"
+        timezone: "America/New_York"
+    service: "agents"
 `,
 			expectedErr: "",
 		},
 		{
-			name: "valid_route_with_multiple_match_conditions",
+			name: "invalid_route_time_match_hours",
 			config: `
 listen_addr: ":8080"
 routes:
-  - name: "complex_route"
+  - name: "business_hours"
     match:
-      path: "/api/v1/users/**"
-      method: "POST"
-      host: "api.example.com"
-      headers:
-        X-Service-Group: "v2"
-    service: "user-service"
+      path: "/support"
+      time:
+        hours: "9-17"
+    service: "agents"
+`,
+			expectedErr: "time match: invalid time of day",
+		},
+		{
+			name: "invalid_route_time_match_cron_duration",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "nightly"
+    match:
+      path: "/support"
+      time:
+        cron: "0 2 * * *"
+    service: "batch"
+`,
+			expectedErr: "time match duration must be positive",
+		},
+		{
+			name: "valid_route_tls_match",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "tenant_grpc"
+    match:
+      tls:
+        sni: ["*.tenants.example.com"]
+        alpn: ["h2"]
+        client_organizations: ["Acme"]
+    service: "grpc"
 `,
 			expectedErr: "",
 		},
 		{
-			name: "invalid_route_with_zero_split_weight",
+			name: "invalid_route_tls_match_sni",
 			config: `
 listen_addr: ":8080"
 routes:
-  - name: "canary_route"
+  - name: "tenant"
     match:
-      path: "/api/*/checkout"
-    split:
-      - service: "checkout-v1"
-        weight: 0
-      - service: "checkout-v2"
-        weight: 100
+      tls:
+        sni: ["tenant.*.example.com"]
+    service: "tenants"
 `,
-			expectedErr: "split weight must be positive",
+			expectedErr: `invalid tls match sni: "tenant.*.example.com"`,
+		},
+		{
+			name: "empty_route_tls_match",
+			config: `
+listen_addr: ":8080"
+routes:
+  - name: "tenant"
+    match:
+      tls: {}
+    service: "tenants"
+`,
+			expectedErr: "tls match requires sni, alpn, client_common_names or client_organizations",
 		},
 	}
 
@@ -1072,6 +3369,78 @@ routes:
 	})
 }
 
+func TestRouteGroups(t *testing.T) {
+	cfg := NewConfig()
+	err := cfg.LoadFromBytes([]byte(`
+listen_addr: ":8080"
+services:
+  - name: "api-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend1:8080"
+  - name: "admin-service"
+    balancer_type: "round_robin"
+    servers:
+      - address: "http://backend2:8080"
+routes:
+  - name: "web"
+    match:
+      path: "/*"
+    service: "api-service"
+health_check:
+  interval: 10s
+  timeout: 2s
+route_groups:
+  - name: "api"
+    match:
+      host: "api.example.com"
+      path_prefix: "/v1/"
+      headers:
+        X-Tenant: "acme"
+    service: "api-service"
+    defaults:
+      rate_limit:
+        requests: 100
+        window: 1m
+      priority: "critical"
+    routes:
+      - name: "users"
+        match:
+          path: "/users/*"
+      - name: "admin"
+        match:
+          host: "admin.example.com"
+          path: "admin"
+          headers:
+            X-Tenant: "internal"
+        service: "admin-service"
+        priority: "best-effort"
+`), ".yaml")
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	require.Len(t, cfg.Routes, 3)
+	users, admin := cfg.Routes[1], cfg.Routes[2]
+	assert.Equal(t, RouteMatch{Path: "/v1/users/*", Host: "api.example.com", Headers: map[string]string{"X-Tenant": "acme"}}, users.Match)
+	assert.Equal(t, "api-service", users.Service)
+	assert.Equal(t, 100, users.RateLimit.Requests)
+	assert.Equal(t, PriorityCritical, users.Priority)
+
+	// Member settings take precedence over the group
+	assert.Equal(t, RouteMatch{Path: "/v1/admin", Host: "admin.example.com", Headers: map[string]string{"X-Tenant": "internal"}}, admin.Match)
+	assert.Equal(t, "admin-service", admin.Service)
+	assert.Equal(t, 100, admin.RateLimit.Requests)
+	assert.Equal(t, PriorityBestEffort, admin.Priority)
+
+	err = NewConfig().LoadFromBytes([]byte(`
+route_groups:
+  - name: "api"
+    defaults:
+      service: "api-service"
+`), ".yaml")
+	assert.EqualError(t, err, "route group api: defaults cannot set name, match, service, split or experiment")
+}
+
 // Test JSON config parsing
 func TestUnmarshalJSON(t *testing.T) {
 	t.Parallel()
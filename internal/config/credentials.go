@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// SecretResolver resolves and caches file:// and secret:// references, see
+// SetSecretResolver
+type SecretResolver interface {
+	Resolve(ref string) (string, error)
+	// Generation changes whenever a refresh changed a cached secret
+	Generation() uint64
+}
+
+var (
+	resolverMu     sync.RWMutex
+	secretResolver SecretResolver
+)
+
+// SetSecretResolver installs the resolver of secret:// references, which also
+// takes over file:// references so their contents are refreshed with the other
+// secrets. nil removes it
+func SetSecretResolver(resolver SecretResolver) {
+	resolverMu.Lock()
+	defer resolverMu.Unlock()
+
+	secretResolver = resolver
+}
+
+func getSecretResolver() SecretResolver {
+	resolverMu.RLock()
+	defer resolverMu.RUnlock()
+
+	return secretResolver
+}
+
+// IsSecretRef reports whether a value is a secret://provider/path#key reference
+func IsSecretRef(value string) bool {
+	return strings.HasPrefix(value, "secret://")
+}
+
+// ResolveCredential returns the value of a credential, which is a literal or
+// a reference: env://NAME reads an environment variable, file:///path the
+// contents of a file without trailing line breaks and secret://provider/path#key
+// a key of a secret held by the installed SecretResolver
+func ResolveCredential(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "env://"):
+		name := strings.TrimPrefix(value, "env://")
+		resolved, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return resolved, nil
+	case strings.HasPrefix(value, "file://"):
+		if resolver := getSecretResolver(); resolver != nil {
+			return resolver.Resolve(value)
+		}
+		return ReadCredentialFile(strings.TrimPrefix(value, "file://"))
+	case IsSecretRef(value):
+		resolver := getSecretResolver()
+		if resolver == nil {
+			return "", fmt.Errorf("no secret provider configured for %s", value)
+		}
+		return resolver.Resolve(value)
+	default:
+		return value, nil
+	}
+}
+
+// ReadCredentialFile returns the contents of a credential file without
+// trailing line breaks
+func ReadCredentialFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// CredentialGeneration changes whenever resolved references may have changed,
+// so values derived from credentials can be cached until then
+func CredentialGeneration() uint64 {
+	if resolver := getSecretResolver(); resolver != nil {
+		return resolver.Generation()
+	}
+	return 0
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveCredential(t *testing.T) {
+	t.Setenv("NEXUS_TEST_CREDENTIAL", "from-env")
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		value    string
+		expected string
+		wantErr  bool
+	}{
+		{value: "literal", expected: "literal"},
+		{value: "env://NEXUS_TEST_CREDENTIAL", expected: "from-env"},
+		{value: "file://" + path, expected: "from-file"},
+		{value: "env://NEXUS_TEST_CREDENTIAL_UNSET", wantErr: true},
+		{value: "file://" + path + ".missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			resolved, err := ResolveCredential(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %q", resolved)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if resolved != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, resolved)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrServiceNotFound is matched by the errors of references to services the
+// config doesn't define, see ServiceNotFoundError
+var ErrServiceNotFound = errors.New("service not found")
+
+// ServiceNotFoundError reports a reference to an undefined service. Errors of
+// routes wrap it with the route name, e.g. "route api: service api-service
+// not found"
+type ServiceNotFoundError struct {
+	Service string
+
+	message string // Replaces the default message where an older one is kept
+}
+
+func (e *ServiceNotFoundError) Error() string {
+	if e.message != "" {
+		return e.message
+	}
+	return fmt.Sprintf("service %s not found", e.Service)
+}
+
+// Is makes the error match ErrServiceNotFound
+func (e *ServiceNotFoundError) Is(target error) bool {
+	return target == ErrServiceNotFound
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// expandRouteGroups returns the member routes of the groups with the group
+// matchers, service and defaults applied
+func expandRouteGroups(groups []*RouteGroupConfig) ([]*RouteConfig, error) {
+	var routes []*RouteConfig
+	for _, group := range groups {
+		if group.Name == "" {
+			return nil, errors.New("route group name is required")
+		}
+		defaults := group.Defaults
+		if defaults.Name != "" || defaults.Service != "" || len(defaults.Split) > 0 || defaults.Experiment != nil || !isEmptyMatch(defaults.Match) {
+			return nil, fmt.Errorf("route group %s: defaults cannot set name, match, service, split or experiment", group.Name)
+		}
+		if group.Match.PathPrefix != "" && !strings.HasPrefix(group.Match.PathPrefix, "/") {
+			return nil, fmt.Errorf("route group %s: path_prefix must start with /", group.Name)
+		}
+
+		for _, member := range group.Routes {
+			if member == nil {
+				continue
+			}
+			route := *member
+			applyGroupMatch(&route.Match, group.Match)
+			if route.Service == "" && len(route.Split) == 0 && route.Experiment == nil {
+				route.Service = group.Service
+			}
+			applyRouteDefaults(&route, &defaults)
+			routes = append(routes, &route)
+		}
+	}
+
+	return routes, nil
+}
+
+// applyGroupMatch adds the group conditions to a member route match
+func applyGroupMatch(match *RouteMatch, group RouteGroupMatch) {
+	if match.Host == "" {
+		match.Host = group.Host
+	}
+	if group.PathPrefix != "" {
+		prefix := strings.TrimSuffix(group.PathPrefix, "/")
+		if match.Path == "" {
+			match.Path = group.PathPrefix
+		} else {
+			match.Path = prefix + "/" + strings.TrimPrefix(match.Path, "/")
+		}
+	}
+	if len(group.Headers) > 0 {
+		headers := make(map[string]string, len(group.Headers)+len(match.Headers))
+		for name, value := range group.Headers {
+			headers[name] = value
+		}
+		for name, value := range match.Headers {
+			headers[name] = value
+		}
+		match.Headers = headers
+	}
+}
+
+// applyRouteDefaults sets the middleware settings a route doesn't set itself
+func applyRouteDefaults(route *RouteConfig, defaults *RouteConfig) {
+	if route.FallbackService == "" {
+		route.FallbackService = defaults.FallbackService
+		route.FallbackStatusCodes = defaults.FallbackStatusCodes
+	}
+	if route.Hedge == nil {
+		route.Hedge = defaults.Hedge
+	}
+	if route.Expect == nil {
+		route.Expect = defaults.Expect
+	}
+	if route.LocationRewrite == nil {
+		route.LocationRewrite = defaults.LocationRewrite
+	}
+	if route.SubFilter == nil {
+		route.SubFilter = defaults.SubFilter
+	}
+	if route.ErrorPages == nil {
+		route.ErrorPages = defaults.ErrorPages
+	}
+	if route.UpstreamCompression == nil {
+		route.UpstreamCompression = defaults.UpstreamCompression
+	}
+	if route.Buffering == nil {
+		route.Buffering = defaults.Buffering
+	}
+	if route.Forwarding == nil {
+		route.Forwarding = defaults.Forwarding
+	}
+	if route.RateLimit == nil {
+		route.RateLimit = defaults.RateLimit
+	}
+	if route.AllowedContentTypes == nil {
+		route.AllowedContentTypes = defaults.AllowedContentTypes
+	}
+	if route.Sanitize == nil {
+		route.Sanitize = defaults.Sanitize
+	}
+	if route.Bandwidth == nil {
+		route.Bandwidth = defaults.Bandwidth
+	}
+	if route.ClientCert == nil {
+		route.ClientCert = defaults.ClientCert
+	}
+	if route.OIDC == nil {
+		route.OIDC = defaults.OIDC
+	}
+	if route.Cache == nil {
+		route.Cache = defaults.Cache
+	}
+	if route.Fault == nil {
+		route.Fault = defaults.Fault
+	}
+	if route.SLO == nil {
+		route.SLO = defaults.SLO
+	}
+	if route.Deprecation == nil {
+		route.Deprecation = defaults.Deprecation
+	}
+	if route.Priority == "" {
+		route.Priority = defaults.Priority
+	}
+}
+
+func isEmptyMatch(match RouteMatch) bool {
+	return match.Path == "" && match.Host == "" && match.Method == "" && len(match.Headers) == 0 &&
+		match.Body == nil && len(match.Languages) == 0 && len(match.Countries) == 0 &&
+		len(match.Continents) == 0 && match.Time == nil && match.TLS == nil && len(match.Tags) == 0
+}
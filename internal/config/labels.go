@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ServerLabels returns the service labels merged with those of one of its
+// servers, which take precedence. The address may omit the path of the
+// configured one. Nil is returned when neither has labels
+func (s *ServiceConfig) ServerLabels(address string) map[string]string {
+	var server map[string]string
+	for _, srv := range s.Servers {
+		if srv.Address == address || strings.HasPrefix(srv.Address, address+"/") {
+			server = srv.Labels
+			break
+		}
+	}
+	if len(server) == 0 {
+		return s.Labels
+	}
+	if len(s.Labels) == 0 {
+		return server
+	}
+
+	labels := make(map[string]string, len(s.Labels)+len(server))
+	for name, value := range s.Labels {
+		labels[name] = value
+	}
+	for name, value := range server {
+		labels[name] = value
+	}
+
+	return labels
+}
+
+// ParseSelector parses a comma separated list of name=value label conditions
+func ParseSelector(selector string) (map[string]string, error) {
+	conditions := make(map[string]string)
+	for _, part := range strings.Split(selector, ",") {
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid selector %q, expected name=value", selector)
+		}
+		conditions[name] = strings.TrimSpace(value)
+	}
+
+	return conditions, nil
+}
+
+// MatchLabels reports whether labels satisfy every condition of a selector
+func MatchLabels(labels, selector map[string]string) bool {
+	for name, value := range selector {
+		if labels[name] != value {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	lg "nexus/internal/logger"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+)
+
+// Reasons a config reload fails
+const (
+	ReloadFailureRead    = "read"    // The file can't be read
+	ReloadFailureParse   = "parse"   // The file isn't valid YAML or JSON
+	ReloadFailureInvalid = "invalid" // The config fails validation
+)
+
+// reloadMetrics records config reload outcomes and the hash of the applied config
+type reloadMetrics struct {
+	attempts  otelmetric.Int64Counter
+	successes otelmetric.Int64Counter
+	failures  otelmetric.Int64Counter
+
+	mu          sync.Mutex
+	lastAttempt time.Time
+	lastSuccess time.Time
+	hash        [sha256.Size]byte
+}
+
+func newReloadMetrics(meter otelmetric.Meter) (*reloadMetrics, error) {
+	m := &reloadMetrics{}
+	var err error
+	if m.attempts, err = meter.Int64Counter("nexus.config.reload.attempts",
+		otelmetric.WithDescription("Config reloads attempted after the file changed")); err != nil {
+		return nil, err
+	}
+	if m.successes, err = meter.Int64Counter("nexus.config.reload.successes",
+		otelmetric.WithDescription("Config reloads applied")); err != nil {
+		return nil, err
+	}
+	if m.failures, err = meter.Int64Counter("nexus.config.reload.failures",
+		otelmetric.WithDescription("Config reloads rejected, by reason")); err != nil {
+		return nil, err
+	}
+
+	lastAttempt, err := meter.Int64ObservableGauge("nexus.config.reload.last_attempt",
+		otelmetric.WithDescription("Time of the last config reload attempt as a unix timestamp"),
+		otelmetric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	lastSuccess, err := meter.Int64ObservableGauge("nexus.config.reload.last_success",
+		otelmetric.WithDescription("Time of the last applied config reload as a unix timestamp"),
+		otelmetric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	// The value is the first 48 bits of the SHA-256 so it survives float
+	// exporters, the config.hash attribute carries the hex prefix
+	hash, err := meter.Int64ObservableGauge("nexus.config.hash",
+		otelmetric.WithDescription("Hash of the applied config file, differs between instances running different configs"))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o otelmetric.Observer) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if !m.lastAttempt.IsZero() {
+			o.ObserveInt64(lastAttempt, m.lastAttempt.Unix())
+		}
+		if !m.lastSuccess.IsZero() {
+			o.ObserveInt64(lastSuccess, m.lastSuccess.Unix())
+			value := int64(binary.BigEndian.Uint64(m.hash[:8]) >> 16)
+			o.ObserveInt64(hash, value, otelmetric.WithAttributes(
+				attribute.String("config.hash", hex.EncodeToString(m.hash[:6])),
+			))
+		}
+		return nil
+	}, lastAttempt, lastSuccess, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *reloadMetrics) attempt() {
+	m.attempts.Add(context.Background(), 1)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastAttempt = time.Now()
+}
+
+func (m *reloadMetrics) failure(reason string) {
+	m.failures.Add(context.Background(), 1, otelmetric.WithAttributes(attribute.String("reason", reason)))
+}
+
+func (m *reloadMetrics) success(data []byte) {
+	m.successes.Add(context.Background(), 1)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastSuccess = time.Now()
+	m.hash = sha256.Sum256(data)
+}
+
+// SetMeter sets the meter recording reload attempts, successes, failures and
+// the hash of the applied config
+func (cw *ConfigWatcher) SetMeter(meter otelmetric.Meter) {
+	metrics, err := newReloadMetrics(meter)
+	if err != nil {
+		lg.GetInstance().Error("Failed to register config reload metrics: %v", err)
+		return
+	}
+
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	cw.metrics = metrics
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	lg "nexus/internal/logger"
+	"nexus/internal/snapshot"
+)
+
+// ErrSnapshotsDisabled is returned by rollbacks when no snapshot store is set
+var ErrSnapshotsDisabled = errors.New("config snapshots are not enabled")
+
+// NewSnapshotStore returns the store of the snapshots of the config file at
+// path, nil when they are disabled
+func NewSnapshotStore(cfg SnapshotConfig, path string) *snapshot.Store {
+	if cfg.Dir == "" {
+		return nil
+	}
+	return snapshot.New(cfg.Dir, cfg.Keep, filepath.Ext(path))
+}
+
+// SetSnapshots sets the store keeping the config files applied, nil stops
+// taking snapshots
+func (cw *ConfigWatcher) SetSnapshots(store *snapshot.Store) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	cw.snapshots = store
+	if store != nil && cw.applied != nil {
+		cw.saveSnapshot(cw.applied)
+	}
+}
+
+// Snapshots returns the snapshots of the config file, latest first
+func (cw *ConfigWatcher) Snapshots() ([]snapshot.Snapshot, error) {
+	cw.mu.RLock()
+	store := cw.snapshots
+	cw.mu.RUnlock()
+
+	if store == nil {
+		return nil, ErrSnapshotsDisabled
+	}
+	return store.List()
+}
+
+// Rollback replaces the config file with a snapshot and applies it, it
+// returns the name of the snapshot restored. The snapshot becomes the latest
+// one once applied
+func (cw *ConfigWatcher) Rollback(name string) (string, error) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if cw.snapshots == nil {
+		return "", ErrSnapshotsDisabled
+	}
+	name, err := RestoreSnapshot(cw.snapshots, name, cw.filePath)
+	if err != nil {
+		return "", err
+	}
+	if fileInfo, err := os.Stat(cw.filePath); err == nil {
+		cw.lastMod = fileInfo.ModTime()
+		cw.lastSize = fileInfo.Size()
+	}
+	return name, cw.reload()
+}
+
+// RestoreSnapshot validates the snapshot named name, or the only one starting
+// with it, and atomically replaces the config file at path with it. It
+// returns the name of the snapshot restored
+func RestoreSnapshot(store *snapshot.Store, name, path string) (string, error) {
+	name, data, err := store.Read(name)
+	if err != nil {
+		return "", err
+	}
+	cfg := NewConfig()
+	if err := cfg.LoadFromBytes(data, filepath.Ext(path)); err != nil {
+		return "", err
+	}
+	if err := cfg.Validate(); err != nil {
+		return "", err
+	}
+
+	return name, snapshot.WriteFile(path, data)
+}
+
+// saveSnapshot keeps data as the latest snapshot, the caller must hold cw.mu
+func (cw *ConfigWatcher) saveSnapshot(data []byte) {
+	if cw.snapshots == nil {
+		return
+	}
+	if _, err := cw.snapshots.Save(data); err != nil {
+		lg.GetInstance().Warn("Failed to save config snapshot in %s: %v", cw.snapshots.Dir(), err)
+	}
+}
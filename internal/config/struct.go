@@ -3,6 +3,9 @@ package config
 import (
 	"sync"
 	"time"
+
+	"nexus/internal/audit"
+	"nexus/internal/snapshot"
 )
 
 // Route config structure
@@ -11,6 +14,167 @@ type RouteConfig struct {
 	Match   RouteMatch    `yaml:"match" json:"match"`
 	Service string        `yaml:"service" json:"service"`
 	Split   []*RouteSplit `yaml:"split" json:"split"`
+	Hedge   *HedgeConfig  `yaml:"hedge" json:"hedge"`
+	Expect  *ExpectConfig `yaml:"expect" json:"expect"`
+
+	Experiment *ExperimentConfig `yaml:"experiment" json:"experiment"` // Assigns users to variant services instead of service or split
+
+	// Service taking over requests when the route's service has no healthy
+	// backends, or answers them with one of fallback_status_codes after retries
+	FallbackService     string `yaml:"fallback_service" json:"fallback_service"`
+	FallbackStatusCodes []int  `yaml:"fallback_status_codes" json:"fallback_status_codes"`
+
+	LocationRewrite *LocationRewriteConfig `yaml:"location_rewrite" json:"location_rewrite"`
+	SubFilter       *SubFilterConfig       `yaml:"sub_filter" json:"sub_filter"`
+	ErrorPages      []*ErrorPageConfig     `yaml:"error_pages" json:"error_pages"`
+
+	UpstreamCompression *UpstreamCompressionConfig `yaml:"upstream_compression" json:"upstream_compression"`
+	Buffering           *BufferingConfig           `yaml:"buffering" json:"buffering"`
+	Forwarding          *ForwardingConfig          `yaml:"forwarding" json:"forwarding"`
+
+	AllowedContentTypes []string        `yaml:"allowed_content_types" json:"allowed_content_types"` // Request body media types, others get 415
+	Sanitize            *SanitizeConfig `yaml:"sanitize" json:"sanitize"`
+
+	RateLimit  *RateLimitConfig  `yaml:"rate_limit" json:"rate_limit"`
+	Bandwidth  *BandwidthConfig  `yaml:"bandwidth" json:"bandwidth"`
+	ClientCert *ClientCertConfig `yaml:"client_cert" json:"client_cert"`
+	OIDC       *OIDCConfig       `yaml:"oidc" json:"oidc"`
+
+	Cache *ResponseCacheConfig `yaml:"cache" json:"cache"`
+
+	Fault *FaultConfig `yaml:"fault" json:"fault"` // Chaos testing, the admin API can override it at runtime
+
+	SLO *SLOConfig `yaml:"slo" json:"slo"` // Latency objective tracked over a rolling window
+
+	Deprecation *DeprecationConfig `yaml:"deprecation" json:"deprecation"` // Announces the retirement of the route to its callers
+
+	// Priority class during overload: critical, default or best-effort
+	Priority string `yaml:"priority" json:"priority"`
+}
+
+// RouteGroupConfig routes sharing matchers, a default service and middleware
+// settings. Member routes are expanded into the route list when the config is
+// loaded, inheriting what they don't set themselves
+type RouteGroupConfig struct {
+	Name     string          `yaml:"name" json:"name"`
+	Match    RouteGroupMatch `yaml:"match" json:"match"`
+	Service  string          `yaml:"service" json:"service"`   // Service of the member routes without service or split
+	Defaults RouteConfig     `yaml:"defaults" json:"defaults"` // Middleware settings of member routes that don't set them
+	Routes   []*RouteConfig  `yaml:"routes" json:"routes"`
+}
+
+// RouteGroupMatch conditions shared by the routes of a group
+type RouteGroupMatch struct {
+	Host       string            `yaml:"host" json:"host"`               // Host of member routes without one
+	PathPrefix string            `yaml:"path_prefix" json:"path_prefix"` // Prepended to member route paths
+	Headers    map[string]string `yaml:"headers" json:"headers"`         // Added to member route headers, which take precedence
+}
+
+// Route priority classes, best-effort traffic is dropped first during overload
+const (
+	PriorityCritical   = "critical"
+	PriorityDefault    = "default"
+	PriorityBestEffort = "best-effort"
+)
+
+// UpstreamCompressionConfig gzips request bodies for backends that accept
+// compressed requests and decodes backend responses in an encoding the
+// client doesn't accept
+type UpstreamCompressionConfig struct {
+	Request         bool     `yaml:"request" json:"request"`                   // Gzip request bodies sent to the backend
+	MinSize         int64    `yaml:"min_size" json:"min_size"`                 // Smallest request body compressed (bytes, default 1KB)
+	ContentTypes    []string `yaml:"content_types" json:"content_types"`       // Compressed request media types, "text/*" matches a family (default: any)
+	DecodeResponses bool     `yaml:"decode_responses" json:"decode_responses"` // Decompress gzip and deflate responses the client doesn't accept
+}
+
+// BufferingConfig controls how responses pass through a route. Responses are
+// streamed by default, buffering reads them from the backend before sending
+// them, so backends are freed from slow clients
+type BufferingConfig struct {
+	Response      bool          `yaml:"response" json:"response"`               // Buffer responses up to max_buffer_size, larger ones are streamed
+	MaxBufferSize int64         `yaml:"max_buffer_size" json:"max_buffer_size"` // Largest response held in memory (bytes, default 1MB)
+	FlushInterval time.Duration `yaml:"flush_interval" json:"flush_interval"`   // Flushes streamed responses periodically, negative flushes every write (default: when the write buffer fills)
+	BufferSize    int           `yaml:"buffer_size" json:"buffer_size"`         // Copy buffer of streamed responses (bytes, default 32KB)
+}
+
+// ForwardingConfig controls the informational responses and trailers passed
+// between clients and backends, all of them are forwarded by default
+type ForwardingConfig struct {
+	ExpectContinue string `yaml:"expect_continue" json:"expect_continue"`   // forward (default) sends request bodies once the backend answers 100 Continue, proxy answers it and sends them right away
+	DropEarlyHints bool   `yaml:"drop_early_hints" json:"drop_early_hints"` // Don't pass 103 Early Hints of the backend on to clients
+	DropTrailers   bool   `yaml:"drop_trailers" json:"drop_trailers"`       // Remove the trailers of backend responses
+}
+
+// Handling of requests sent with Expect: 100-continue
+const (
+	ExpectContinueForward = "forward"
+	ExpectContinueProxy   = "proxy"
+)
+
+// FaultConfig injects faults into the requests of a route to test how clients
+// cope with slow or failing backends. Each fault applies to its percentage of
+// requests independently
+type FaultConfig struct {
+	Header  string              `yaml:"header" json:"header,omitempty"` // Only requests carrying this header are affected
+	Delay   *FaultDelayConfig   `yaml:"delay" json:"delay,omitempty"`
+	Abort   *FaultAbortConfig   `yaml:"abort" json:"abort,omitempty"`
+	Corrupt *FaultCorruptConfig `yaml:"corrupt" json:"corrupt,omitempty"`
+}
+
+// FaultDelayConfig delays requests before they are forwarded
+type FaultDelayConfig struct {
+	Duration   time.Duration `yaml:"duration" json:"duration"`
+	Jitter     time.Duration `yaml:"jitter" json:"jitter"`         // Random extra delay up to jitter
+	Percentage float64       `yaml:"percentage" json:"percentage"` // Delayed requests (default 100)
+}
+
+// FaultAbortConfig answers requests with a status instead of forwarding them
+type FaultAbortConfig struct {
+	Status     int     `yaml:"status" json:"status"`
+	Body       string  `yaml:"body" json:"body"`
+	Percentage float64 `yaml:"percentage" json:"percentage"` // Aborted requests (default 100)
+}
+
+// Fault corruption modes
+const (
+	FaultCorruptGarble   = "garble"   // Flip bytes of the response body
+	FaultCorruptTruncate = "truncate" // Cut the response body and the connection
+)
+
+// FaultCorruptConfig damages response bodies
+type FaultCorruptConfig struct {
+	Mode       string  `yaml:"mode" json:"mode"`             // garble or truncate (default: garble)
+	Percentage float64 `yaml:"percentage" json:"percentage"` // Corrupted responses (default 100)
+}
+
+// ResponseCacheConfig caches the GET responses of a route that the backend
+// allows shared caches to store
+type ResponseCacheConfig struct {
+	TTL       time.Duration `yaml:"ttl" json:"ttl"`             // Freshness of responses without max-age or Expires (default 1m)
+	Preflight bool          `yaml:"preflight" json:"preflight"` // Also cache CORS preflight responses, for their Access-Control-Max-Age
+	Cookies   bool          `yaml:"cookies" json:"cookies"`     // Also serve requests carrying cookies, for responses that don't depend on them
+}
+
+// OIDCConfig OpenID Connect login for browser-facing routes. Requests without
+// a session are sent through the authorization code flow of the provider and
+// the identity claims are forwarded to the backend as headers
+type OIDCConfig struct {
+	Issuer       string            `yaml:"issuer" json:"issuer"`
+	ClientID     string            `yaml:"client_id" json:"client_id"`
+	ClientSecret string            `yaml:"client_secret" json:"client_secret"` // Secret or reference, see ResolveCredential
+	RedirectURL  string            `yaml:"redirect_url" json:"redirect_url"`   // Callback URL or path on the request host, must be matched by the route (default /oauth2/callback)
+	LogoutPath   string            `yaml:"logout_path" json:"logout_path"`     // Clears the session, disabled when empty
+	Scopes       []string          `yaml:"scopes" json:"scopes"`               // Requested scopes (default openid, profile, email)
+	Cookie       string            `yaml:"cookie" json:"cookie"`               // Session cookie name, defaults to nexus_oidc_<route>
+	CookieSecret string            `yaml:"cookie_secret" json:"cookie_secret"` // Encrypts session cookies, or a reference to it
+	SessionTTL   time.Duration     `yaml:"session_ttl" json:"session_ttl"`     // Session lifetime (default 1h)
+	ClaimHeaders map[string]string `yaml:"claim_headers" json:"claim_headers"` // Forwarded claims by header name (default X-Forwarded-User: sub, X-Forwarded-Email: email)
+}
+
+// ClientCertConfig restricts a route to clients that presented a certificate
+// verified by the TLS listener, see TLSConfig.ClientAuth
+type ClientCertConfig struct {
+	CommonNames []string `yaml:"common_names" json:"common_names"` // Allowed subject common names (default: any verified certificate)
 }
 
 // Route match condition
@@ -19,29 +183,437 @@ type RouteMatch struct {
 	Headers map[string]string `yaml:"headers" json:"headers"`
 	Method  string            `yaml:"method" json:"method"`
 	Host    string            `yaml:"host" json:"host"`
+	Body    *BodyMatch        `yaml:"body" json:"body"`
+
+	Languages  []string `yaml:"languages" json:"languages"`   // Preferred Accept-Language, "de" also matches "de-AT"
+	Countries  []string `yaml:"countries" json:"countries"`   // ISO country codes resolved by GeoIP
+	Continents []string `yaml:"continents" json:"continents"` // Continent codes resolved by GeoIP, e.g. EU
+
+	Time *TimeMatch        `yaml:"time" json:"time"`
+	TLS  *TLSMatch         `yaml:"tls" json:"tls"`   // Only matches requests received over TLS
+	Tags map[string]string `yaml:"tags" json:"tags"` // Tags set by the classification rules
+}
+
+// TLS connection match condition, a request matches a list when it matches
+// any of its values
+type TLSMatch struct {
+	SNI                 []string `yaml:"sni" json:"sni"`                                   // Server names sent by the client, "*.example.com" matches its subdomains
+	ALPN                []string `yaml:"alpn" json:"alpn"`                                 // Negotiated application protocols, e.g. h2 or http/1.1
+	ClientCommonNames   []string `yaml:"client_common_names" json:"client_common_names"`   // Client certificate subject common names, requires tls.client_auth
+	ClientOrganizations []string `yaml:"client_organizations" json:"client_organizations"` // Client certificate subject organizations, requires tls.client_auth
+}
+
+// ClassificationRule tags the requests matching all its conditions, a rule
+// without conditions tags every request. Tags of later rules override the
+// values set by earlier ones
+type ClassificationRule struct {
+	Name       string            `yaml:"name" json:"name"`
+	PathPrefix string            `yaml:"path_prefix" json:"path_prefix"`
+	Methods    []string          `yaml:"methods" json:"methods"`
+	Headers    map[string]string `yaml:"headers" json:"headers"`       // Regular expressions matched against present headers
+	UserAgent  string            `yaml:"user_agent" json:"user_agent"` // Regular expression matched against User-Agent, e.g. "(?i)bot|crawler"
+	Tags       map[string]string `yaml:"tags" json:"tags"`             // e.g. class: mobile
+}
+
+// Time window match condition, either a cron start with a duration or weekday/hour ranges
+type TimeMatch struct {
+	Cron     string        `yaml:"cron" json:"cron"`
+	Duration time.Duration `yaml:"duration" json:"duration"`
+	Weekdays []string      `yaml:"weekdays" json:"weekdays"` // e.g. ["mon-fri"] or ["sat", "sun"]
+	Hours    string        `yaml:"hours" json:"hours"`       // e.g. "09:00-17:00", may wrap past midnight
+	Timezone string        `yaml:"timezone" json:"timezone"` // Location the window is evaluated in, defaults to UTC
+}
+
+// Request body match condition, one of json_field, soap_action or pattern
+type BodyMatch struct {
+	MaxSize    int64  `yaml:"max_size" json:"max_size"`       // Body bytes inspected (default 4KB)
+	JSONField  string `yaml:"json_field" json:"json_field"`   // Dotted JSON field path
+	Value      string `yaml:"value" json:"value"`             // Expected json_field value, empty only requires presence
+	SOAPAction string `yaml:"soap_action" json:"soap_action"` // SOAPAction header or first element of the SOAP Body
+	Pattern    string `yaml:"pattern" json:"pattern"`         // Regular expression matched against the body prefix
 }
 
 // Traffic split configuration
 type RouteSplit struct {
-	Service string `yaml:"service" json:"service"`
-	Weight  int    `yaml:"weight" json:"weight"`
+	Service  string `yaml:"service" json:"service"`
+	Weight   int    `yaml:"weight" json:"weight"`
+	Selector string `yaml:"selector" json:"selector"` // Only balance over the servers with these labels, e.g. "version=v2,zone=a"
+}
+
+// ExperimentConfig A/B experiment of a route. Users are assigned to a variant
+// by the hash of their key, so they keep seeing the same variant
+type ExperimentConfig struct {
+	Name           string               `yaml:"name" json:"name"`                       // Reported in metrics (default: route name)
+	Key            string               `yaml:"key" json:"key"`                         // cookie:<name> (default: cookie:nexus_exp_<name>) or header:<name>
+	CookieTTL      time.Duration        `yaml:"cookie_ttl" json:"cookie_ttl"`           // Lifetime of the cookies assigning new users (default 30 days)
+	ResponseHeader string               `yaml:"response_header" json:"response_header"` // Carries the variant name (default: X-Experiment-Variant)
+	Variants       []*ExperimentVariant `yaml:"variants" json:"variants"`
+}
+
+// ExperimentVariant variant of an experiment and the service serving it
+type ExperimentVariant struct {
+	Name     string `yaml:"name" json:"name"`
+	Service  string `yaml:"service" json:"service"`
+	Weight   int    `yaml:"weight" json:"weight"`     // Share of users relative to the other variants
+	Selector string `yaml:"selector" json:"selector"` // Only balance over the servers with these labels, like RouteSplit
+}
+
+// Request hedging configuration
+type HedgeConfig struct {
+	Delay     time.Duration `yaml:"delay" json:"delay"`           // Wait before issuing the next hedged request
+	MaxHedges int           `yaml:"max_hedges" json:"max_hedges"` // Maximum number of extra requests per client request
+	Budget    float64       `yaml:"budget" json:"budget"`         // Maximum percentage of requests that may be hedged (0 means unlimited)
+}
+
+// Upstream response expectation, violations are retried on another backend
+type ExpectConfig struct {
+	StatusCodes []int    `yaml:"status_codes" json:"status_codes"`   // Accepted status codes (empty means any)
+	ContentType string   `yaml:"content_type" json:"content_type"`   // Required media type, e.g. application/json
+	JSONFields  []string `yaml:"json_fields" json:"json_fields"`     // Dotted field paths that must be present
+	MaxRetries  int      `yaml:"max_retries" json:"max_retries"`     // Retries on other backends for idempotent requests
+	MaxBodySize int64    `yaml:"max_body_size" json:"max_body_size"` // Largest body inspected for json_fields (bytes)
+}
+
+// Rewrite of backend redirects to the public address of the proxy
+type LocationRewriteConfig struct {
+	Scheme string `yaml:"scheme" json:"scheme"` // Public scheme (default: scheme of the client request)
+	Host   string `yaml:"host" json:"host"`     // Public host (default: Host of the client request)
+}
+
+// ErrorPageConfig replaces upstream error responses with a branded page,
+// like nginx error_page. Exactly one of file or template is set
+type ErrorPageConfig struct {
+	StatusCodes []int  `yaml:"status_codes" json:"status_codes"` // Intercepted upstream statuses, e.g. 502, 503 and 504
+	File        string `yaml:"file" json:"file"`                 // Static page sent as is
+	Template    string `yaml:"template" json:"template"`         // html/template file rendered with .Status, .StatusText, .Method, .Host and .Path
+	ContentType string `yaml:"content_type" json:"content_type"` // Default: text/html; charset=utf-8
+	Status      int    `yaml:"status" json:"status"`             // Status sent to the client, the upstream status when 0
+}
+
+// Response body rewriting of text responses, like nginx sub_filter
+type SubFilterConfig struct {
+	Replacements []SubFilterReplacement `yaml:"replacements" json:"replacements"`
+	ContentTypes []string               `yaml:"content_types" json:"content_types"` // Rewritten media types, "text/*" matches a type family (default: text/html)
+	MaxBodySize  int64                  `yaml:"max_body_size" json:"max_body_size"` // Bytes rewritten per response, the rest is passed on unchanged (default: 1MB)
+}
+
+// A replacement of a sub filter, either a literal string or a pattern
+type SubFilterReplacement struct {
+	From    string `yaml:"from" json:"from"`       // Literal string
+	Pattern string `yaml:"pattern" json:"pattern"` // Regular expression, matched within a line
+	To      string `yaml:"to" json:"to"`           // Replacement, $1 expands a group of pattern
+}
+
+// Route rate limit, counted in fixed windows per key
+type RateLimitConfig struct {
+	Requests int           `yaml:"requests" json:"requests"` // Requests allowed per window
+	Window   time.Duration `yaml:"window" json:"window"`
+	Key      string        `yaml:"key" json:"key"`       // ip (default), route or header:<name>
+	Tarpit   *TarpitConfig `yaml:"tarpit" json:"tarpit"` // Delay the rejection of clients far over the limit
+}
+
+// TarpitConfig holds the rejections of clients far over their rate limit
+// before answering, so scrapers get fewer requests through their connections
+type TarpitConfig struct {
+	Factor        float64       `yaml:"factor" json:"factor"`                 // Tarpit clients with more than factor times the allowed requests in the window (default 2)
+	Delay         time.Duration `yaml:"delay" json:"delay"`                   // Time rejections are held (default 10s)
+	MaxConcurrent int           `yaml:"max_concurrent" json:"max_concurrent"` // Rejections held at once per route, later ones are answered right away (default 100)
+}
+
+// Header sanitization modes
+const (
+	SanitizeReject = "reject" // Answer requests with problematic headers with an error
+	SanitizeStrip  = "strip"  // Remove or normalize the problematic headers and forward
+)
+
+// SanitizeConfig checks request headers before they are forwarded: oversized
+// values, values with control or non-ASCII characters and repeated headers
+// that backends and intermediaries may read differently
+type SanitizeConfig struct {
+	Mode          string `yaml:"mode" json:"mode"`                       // reject (default) or strip
+	MaxHeaderSize int    `yaml:"max_header_size" json:"max_header_size"` // Largest header value (bytes, default 8KB)
+}
+
+// BandwidthConfig limits the transfer rate of request and response bodies of
+// a route, a zero rate doesn't limit its direction
+type BandwidthConfig struct {
+	Response int64  `yaml:"response" json:"response"` // Response body bytes per second
+	Request  int64  `yaml:"request" json:"request"`   // Request body bytes per second
+	Burst    int64  `yaml:"burst" json:"burst"`       // Bytes sent at once before throttling (default: one second of the rate)
+	Key      string `yaml:"key" json:"key"`           // request (default), ip, route or header:<name>, requests of a key share the rate
+}
+
+// SLOConfig is the latency objective of a route, e.g. 99% of the requests
+// answered within 300ms. Requests answered with 5xx or aborted are never
+// good. The burn rate is the rate the error budget is spent at relative to
+// the window, 1 spends it exactly over the window
+type SLOConfig struct {
+	Objective float64       `yaml:"objective" json:"objective"` // Percentage of good requests, e.g. 99.9
+	Latency   time.Duration `yaml:"latency" json:"latency"`     // Good requests are answered within it
+	Window    time.Duration `yaml:"window" json:"window"`       // Rolling window of the compliance (default 1h)
+
+	// Burn rate logging a warning when exceeded over the window and its last
+	// twelfth, e.g. 14.4 spends 2% of a 30 day budget in an hour. 0 disables alerts
+	AlertBurnRate float64 `yaml:"alert_burn_rate" json:"alert_burn_rate"`
+}
+
+// DeprecationConfig marks a route deprecated with Deprecation (RFC 9745) and
+// Sunset (RFC 8594) headers, and logs the callers still using it
+type DeprecationConfig struct {
+	Since  time.Time `yaml:"since" json:"since"`   // Deprecation date, the header is "true" when unset
+	Sunset time.Time `yaml:"sunset" json:"sunset"` // Retirement date, no Sunset header when unset
+	Link   string    `yaml:"link" json:"link"`     // Migration guide, sent as a Link with rel="deprecation"
+	Gone   bool      `yaml:"gone" json:"gone"`     // Answer 410 from the sunset on instead of forwarding
+
+	LogKey      string        `yaml:"log_key" json:"log_key"`           // ip (default), header:<name> or tag:<name> identifying logged callers
+	LogInterval time.Duration `yaml:"log_interval" json:"log_interval"` // A caller is logged once per interval (default 1h)
 }
 
 // Intermediate temporary structure
 type rawConfig struct {
-	ListenAddr  string            `yaml:"listen_addr" json:"listen_addr"`
-	LogLevel    string            `yaml:"log_level" json:"log_level"`
-	Telemetry   TelemetryConfig   `yaml:"telemetry" json:"telemetry"`
-	Services    []*ServiceConfig  `yaml:"services" json:"services"`
-	Routes      []*RouteConfig    `yaml:"routes" json:"routes"`
-	HealthCheck HealthCheckConfig `yaml:"health_check" json:"health_check"`
+	ListenAddr  string                    `yaml:"listen_addr" json:"listen_addr"`
+	LogLevel    string                    `yaml:"log_level" json:"log_level"`
+	Telemetry   TelemetryConfig           `yaml:"telemetry" json:"telemetry"`
+	Services    []*ServiceConfig          `yaml:"services" json:"services"`
+	Routes      []*RouteConfig            `yaml:"routes" json:"routes"`
+	HealthCheck HealthCheckConfig         `yaml:"health_check" json:"health_check"`
+	Dedup       DedupConfig               `yaml:"dedup" json:"dedup"`
+	Admin       AdminConfig               `yaml:"admin" json:"admin"`
+	State       StateConfig               `yaml:"state" json:"state"`
+	TLS         TLSConfig                 `yaml:"tls" json:"tls"`
+	RateLimit   RateLimitStoreConfig      `yaml:"rate_limit" json:"rate_limit"`
+	GeoIP       GeoIPConfig               `yaml:"geoip" json:"geoip"`
+	RouteCache  RouteCacheConfig          `yaml:"route_cache" json:"route_cache"`
+	Secrets     SecretsConfig             `yaml:"secrets" json:"secrets"`
+	Cache       CacheConfig               `yaml:"cache" json:"cache"`
+	LoadShed    LoadShedConfig            `yaml:"load_shedding" json:"load_shedding"`
+	Record      RecordConfig              `yaml:"record" json:"record"`
+	Middleware  []*MiddlewareConfig       `yaml:"middleware" json:"middleware"`
+	RouteGroups []*RouteGroupConfig       `yaml:"route_groups" json:"route_groups"`
+	Upstream    UpstreamConfig            `yaml:"upstream" json:"upstream"`
+	Audit       AuditConfig               `yaml:"audit" json:"audit"`
+	Paths       PathConfig                `yaml:"path_normalization" json:"path_normalization"`
+	Smuggling   SmugglingProtectionConfig `yaml:"smuggling_protection" json:"smuggling_protection"`
+	Signature   SignatureConfig           `yaml:"proxy_signature" json:"proxy_signature"`
+	Election    LeaderElectionConfig      `yaml:"leader_election" json:"leader_election"`
+	Passthrough PassthroughConfig         `yaml:"passthrough" json:"passthrough"`
+	Classify    []*ClassificationRule     `yaml:"classification" json:"classification"`
+	Snapshots   SnapshotConfig            `yaml:"snapshots" json:"snapshots"`
+	RealIP      RealIPConfig              `yaml:"real_ip" json:"real_ip"`
 }
 
 // Service config structure
 type ServiceConfig struct {
-	Name         string         `yaml:"name" json:"name"`
-	BalancerType string         `yaml:"balancer_type" json:"balancer_type"`
-	Servers      []ServerConfig `yaml:"servers" json:"servers"`
+	Name         string                    `yaml:"name" json:"name"`
+	BalancerType string                    `yaml:"balancer_type" json:"balancer_type"`
+	Servers      []ServerConfig            `yaml:"servers" json:"servers"`
+	Dedup        *DedupConfig              `yaml:"dedup" json:"dedup"` // Overrides the global dedup config
+	Affinity     *AffinityConfig           `yaml:"affinity" json:"affinity"`
+	Concurrency  *ConcurrencyConfig        `yaml:"concurrency" json:"concurrency"`
+	UpstreamAuth *UpstreamAuthConfig       `yaml:"upstream_auth" json:"upstream_auth"`
+	HealthCheck  *ServiceHealthCheckConfig `yaml:"health_check" json:"health_check"` // Overrides the global health check
+	Signing      *RequestSigningConfig     `yaml:"signing" json:"signing"`
+	Labels       map[string]string         `yaml:"labels" json:"labels"` // Metadata attached to telemetry and logs, e.g. tier
+	Warmup       *WarmupConfig             `yaml:"warmup" json:"warmup"`
+	Discovery    *DiscoveryConfig          `yaml:"discovery" json:"discovery"`       // Servers listed by the service itself, added to the configured ones
+	Registration *RegistrationConfig       `yaml:"registration" json:"registration"` // Servers registering themselves through the admin API
+}
+
+// RegistrationConfig lets backends register themselves with a service,
+// registrations expire unless renewed within their TTL
+type RegistrationConfig struct {
+	TTL    time.Duration `yaml:"ttl" json:"ttl"`         // TTL of registrations without one (default 30s)
+	MaxTTL time.Duration `yaml:"max_ttl" json:"max_ttl"` // Longest TTL a registration may ask for (default 5m)
+}
+
+// DiscoveryConfig URL polled for the instance list of a service, answering
+// {"instances": [{"address": "http://10.0.0.5:8080", "weight": 1, "labels": {}}]}
+type DiscoveryConfig struct {
+	URL      string        `yaml:"url" json:"url"`
+	Interval time.Duration `yaml:"interval" json:"interval"` // Poll interval (default 30s)
+	Timeout  time.Duration `yaml:"timeout" json:"timeout"`   // Per-poll timeout (default 5s)
+}
+
+// WarmupConfig requests sent to servers added by a reload before they enter
+// rotation, e.g. to prime JIT compilers and caches
+type WarmupConfig struct {
+	Paths       []string          `yaml:"paths" json:"paths"`             // Requested with GET in turn
+	Requests    int               `yaml:"requests" json:"requests"`       // Requests per path (default 1)
+	Concurrency int               `yaml:"concurrency" json:"concurrency"` // Requests in flight per server (default 1)
+	Timeout     time.Duration     `yaml:"timeout" json:"timeout"`         // Per-request timeout (default 5s)
+	Headers     map[string]string `yaml:"headers" json:"headers"`
+}
+
+// RequestSigningConfig signs requests forwarded to the service, after the
+// upstream credentials were added. Secrets may be references, see ResolveCredential
+type RequestSigningConfig struct {
+	Type string `yaml:"type" json:"type"` // sigv4 or hmac
+
+	// sigv4: AWS Signature Version 4, e.g. for S3-compatible storage
+	Region          string `yaml:"region" json:"region"`
+	Service         string `yaml:"service" json:"service"` // Signing name, e.g. s3 or execute-api
+	AccessKeyID     string `yaml:"access_key_id" json:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key" json:"secret_access_key"`
+	SessionToken    string `yaml:"session_token" json:"session_token"`       // Temporary credentials (optional)
+	UnsignedPayload bool   `yaml:"unsigned_payload" json:"unsigned_payload"` // Don't buffer and hash request bodies
+
+	// hmac: hex HMAC of the method, path and query, timestamp, body hash and signed headers
+	Secret          string   `yaml:"secret" json:"secret"`
+	Algorithm       string   `yaml:"algorithm" json:"algorithm"`               // sha256 (default) or sha512
+	Header          string   `yaml:"header" json:"header"`                     // Signature header (default: X-Signature)
+	TimestampHeader string   `yaml:"timestamp_header" json:"timestamp_header"` // Unix time header (default: X-Timestamp)
+	SignedHeaders   []string `yaml:"signed_headers" json:"signed_headers"`     // Header values appended to the string to sign
+}
+
+// ServiceHealthCheckConfig health check settings of a service's servers, unset
+// fields fall back to the global health_check block
+type ServiceHealthCheckConfig struct {
+	Path             string        `yaml:"path" json:"path"`
+	Interval         time.Duration `yaml:"interval" json:"interval"`
+	Timeout          time.Duration `yaml:"timeout" json:"timeout"`
+	ExpectedStatuses []int         `yaml:"expected_statuses" json:"expected_statuses"` // Defaults to 200
+	Type             string        `yaml:"type" json:"type"`                           // http (default) or grpc
+	GRPCService      string        `yaml:"grpc_service" json:"grpc_service"`           // Service name of grpc.health.v1 checks, empty checks the whole server
+	InfoPath         string        `yaml:"info_path" json:"info_path"`                 // Requested after healthy probes for the version the server runs, see healthcheck.ParseVersion
+}
+
+// Health check types
+const (
+	HealthCheckHTTP = "http"
+	HealthCheckGRPC = "grpc"
+)
+
+// UpstreamAuthConfig credentials injected into requests forwarded to the
+// service. Values may be env://NAME, file:///path or secret://provider/path#key
+// references, see ResolveCredential
+type UpstreamAuthConfig struct {
+	Type     string `yaml:"type" json:"type"`         // basic, bearer or header
+	Username string `yaml:"username" json:"username"` // basic
+	Password string `yaml:"password" json:"password"` // basic
+	Token    string `yaml:"token" json:"token"`       // bearer
+	Header   string `yaml:"header" json:"header"`     // header: header name
+	Value    string `yaml:"value" json:"value"`       // header: header value
+}
+
+// ConcurrencyConfig limits concurrent upstream requests, waiting requests are
+// served round-robin per client so one client can't monopolize the service
+type ConcurrencyConfig struct {
+	MaxConcurrent int            `yaml:"max_concurrent" json:"max_concurrent"`
+	MaxQueue      int            `yaml:"max_queue" json:"max_queue"`         // Requests waiting for a slot, rejected with 503 beyond
+	QueueTimeout  time.Duration  `yaml:"queue_timeout" json:"queue_timeout"` // Longest wait for a slot (default 10s)
+	Key           string         `yaml:"key" json:"key"`                     // Client key: ip (default) or header:<name>
+	Weights       map[string]int `yaml:"weights" json:"weights"`             // Slots per round-robin turn by client key, default 1
+}
+
+// AffinityConfig sticky session configuration, the chosen backend is kept in a
+// signed cookie so every replica can honor it without shared state
+type AffinityConfig struct {
+	Cookie  string        `yaml:"cookie" json:"cookie"`   // Cookie name, defaults to nexus_affinity_<service>
+	Secrets []string      `yaml:"secrets" json:"secrets"` // HMAC keys or references to them, the first signs and all verify to allow rotation
+	TTL     time.Duration `yaml:"ttl" json:"ttl"`         // Affinity lifetime, 0 means a session cookie
+	Secure  bool          `yaml:"secure" json:"secure"`
+}
+
+// DedupConfig request deduplication configuration
+type DedupConfig struct {
+	Enabled     bool  `yaml:"enabled" json:"enabled"`
+	MaxBodySize int64 `yaml:"max_body_size" json:"max_body_size"` // Largest response shared with waiting requests (bytes)
+}
+
+// UpstreamConfig upstream connection configuration
+type UpstreamConfig struct {
+	LeakTimeout time.Duration        `yaml:"leak_timeout" json:"leak_timeout"` // Age of open response bodies reported as leaked (default 5m)
+	HTTP2       *UpstreamHTTP2Config `yaml:"http2" json:"http2"`               // Multiplex requests over HTTP/2, HTTP/1.1 is used when nil
+	DNS         *UpstreamDNSConfig   `yaml:"dns" json:"dns"`                   // Cache the addresses of backend hosts, every connection looks them up when nil
+}
+
+// UpstreamDNSConfig caches the addresses backend hosts resolve to, so bursts
+// of new connections share one lookup and a failing resolver doesn't take
+// down backends it resolved before
+type UpstreamDNSConfig struct {
+	MinTTL      time.Duration `yaml:"min_ttl" json:"min_ttl"`           // Shortest time addresses are kept, raising lower record TTLs (default 1s)
+	MaxTTL      time.Duration `yaml:"max_ttl" json:"max_ttl"`           // Longest time addresses are kept, also used for hosts file entries (default 5m)
+	NegativeTTL time.Duration `yaml:"negative_ttl" json:"negative_ttl"` // Time failed lookups are kept before asking the resolver again (default 5s)
+	StaleTTL    time.Duration `yaml:"stale_ttl" json:"stale_ttl"`       // Time expired addresses are still used while lookups fail (default 5m)
+}
+
+// UpstreamHTTP2Config multiplexes the requests to each server over a few
+// HTTP/2 connections, h2c with prior knowledge for http:// servers, which
+// must all support HTTP/2
+type UpstreamHTTP2Config struct {
+	MaxConcurrentStreams int           `yaml:"max_concurrent_streams" json:"max_concurrent_streams"` // Requests in flight per connection before another one is opened (default 100)
+	MaxConnections       int           `yaml:"max_connections" json:"max_connections"`               // Connections per server, further requests wait for a stream (default: unlimited)
+	ReadIdleTimeout      time.Duration `yaml:"read_idle_timeout" json:"read_idle_timeout"`           // Ping connections that received no frame for this long, closing them without reply (default: disabled)
+}
+
+// Trailing slash policies
+const (
+	TrailingSlashKeep     = "keep"     // /a and /a/ are different paths
+	TrailingSlashStrip    = "strip"    // /a/ is matched and forwarded as /a
+	TrailingSlashRedirect = "redirect" // /a/ is redirected to /a
+)
+
+// PathConfig normalizes request paths before routes are matched and the
+// normalized path is forwarded, so equivalent paths reach the same route
+type PathConfig struct {
+	MergeSlashes       bool   `yaml:"merge_slashes" json:"merge_slashes"`               // //a///b becomes /a/b
+	ResolveDotSegments bool   `yaml:"resolve_dot_segments" json:"resolve_dot_segments"` // /a/./b/../c becomes /a/c, paths above the root are rejected
+	TrailingSlash      string `yaml:"trailing_slash" json:"trailing_slash"`             // keep (default), strip or redirect
+	DecodeUnreserved   bool   `yaml:"decode_unreserved" json:"decode_unreserved"`       // %7E becomes ~ in forwarded paths, other escapes are uppercased
+	EncodedSlashes     string `yaml:"encoded_slashes" json:"encoded_slashes"`           // keep (default), decode %2F into slashes, or reject %2F and %5C
+}
+
+// Encoded slash policies
+const (
+	EncodedSlashesKeep   = "keep"
+	EncodedSlashesDecode = "decode"
+	EncodedSlashesReject = "reject"
+)
+
+// SmugglingProtectionConfig rejects requests that servers along the way may
+// frame or read differently
+type SmugglingProtectionConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+}
+
+// SignatureConfig controls the headers identifying the proxy and its backends
+type SignatureConfig struct {
+	Server        string `yaml:"server" json:"server"`                     // keep (default), strip, or the value replacing the backend one
+	Via           bool   `yaml:"via" json:"via"`                           // Add nexus to the Via header of requests and responses
+	ViaName       string `yaml:"via_name" json:"via_name"`                 // Pseudonym in Via headers (default: nexus/<version>)
+	StripHopByHop bool   `yaml:"strip_hop_by_hop" json:"strip_hop_by_hop"` // Remove hop-by-hop headers before route matching and from every response
+}
+
+// Server header policies, other values replace the header
+const (
+	ServerHeaderKeep  = "keep"
+	ServerHeaderStrip = "strip"
+)
+
+// AuditConfig audit log configuration
+type AuditConfig struct {
+	File string `yaml:"file" json:"file"` // Append-only JSON lines file, auditing is disabled when empty
+}
+
+// SnapshotConfig keeps a copy of the config file after each successful reload
+type SnapshotConfig struct {
+	Dir  string `yaml:"dir" json:"dir"`   // Directory of the snapshots, they are disabled when empty
+	Keep int    `yaml:"keep" json:"keep"` // Number of snapshots kept (default: 10)
+}
+
+// Real IP headers, in the default order of precedence
+const (
+	RealIPHeaderForwarded    = "Forwarded"
+	RealIPHeaderRealIP       = "X-Real-IP"
+	RealIPHeaderForwardedFor = "X-Forwarded-For"
+	RealIPHeaderCloudflare   = "CF-Connecting-IP"
+)
+
+// RealIPConfig takes the client IP of requests from the headers set by
+// trusted proxies. Requests from other peers keep their connection address
+type RealIPConfig struct {
+	TrustedProxies []string `yaml:"trusted_proxies" json:"trusted_proxies"` // CIDRs or addresses, the real IP is disabled when empty
+	Headers        []string `yaml:"headers" json:"headers"`                 // Headers tried in order (default: Forwarded, X-Real-IP, X-Forwarded-For, CF-Connecting-IP)
 }
 
 // Config struct contains all configuration items
@@ -64,12 +636,313 @@ type Config struct {
 	Routes []*RouteConfig `yaml:"routes" json:"routes"`
 
 	HealthCheck HealthCheckConfig `yaml:"health_check" json:"health_check"`
+
+	// Request deduplication configuration
+	Dedup DedupConfig `yaml:"dedup" json:"dedup"`
+
+	// Admin API configuration
+	Admin AdminConfig `yaml:"admin" json:"admin"`
+
+	// Runtime state persistence configuration
+	State StateConfig `yaml:"state" json:"state"`
+
+	// TLS listener configuration
+	TLS TLSConfig `yaml:"tls" json:"tls"`
+
+	// Rate limit storage configuration
+	RateLimit RateLimitStoreConfig `yaml:"rate_limit" json:"rate_limit"`
+
+	// GeoIP database configuration
+	GeoIP GeoIPConfig `yaml:"geoip" json:"geoip"`
+
+	// Route match cache configuration
+	RouteCache RouteCacheConfig `yaml:"route_cache" json:"route_cache"`
+
+	// Response cache configuration
+	Cache CacheConfig `yaml:"cache" json:"cache"`
+
+	// Load shedding under resource pressure configuration
+	LoadShed LoadShedConfig `yaml:"load_shedding" json:"load_shedding"`
+
+	// Secret providers configuration
+	Secrets SecretsConfig `yaml:"secrets" json:"secrets"`
+
+	// Traffic recording configuration
+	Record RecordConfig `yaml:"record" json:"record"`
+
+	// Middleware applied to every request of the listeners
+	Middleware []*MiddlewareConfig `yaml:"middleware" json:"middleware"`
+
+	// Upstream connection configuration
+	Upstream UpstreamConfig `yaml:"upstream" json:"upstream"`
+
+	// Audit log of admin API changes and config reloads
+	Audit AuditConfig `yaml:"audit" json:"audit"`
+
+	// Path normalization applied before routes are matched
+	Paths PathConfig `yaml:"path_normalization" json:"path_normalization"`
+
+	// Rejection of ambiguous requests at the proxy boundary
+	Smuggling SmugglingProtectionConfig `yaml:"smuggling_protection" json:"smuggling_protection"`
+
+	// Headers identifying the proxy
+	Signature SignatureConfig `yaml:"proxy_signature" json:"proxy_signature"`
+
+	// Election of the replica performing the shared work of a fleet
+	Election LeaderElectionConfig `yaml:"leader_election" json:"leader_election"`
+
+	// TLS passthrough listener routing connections by SNI
+	Passthrough PassthroughConfig `yaml:"passthrough" json:"passthrough"`
+
+	// Request tagging rules, applied in order before routes are matched
+	Classify []*ClassificationRule `yaml:"classification" json:"classification"`
+
+	// Copies of the last config files applied, for rollbacks
+	Snapshots SnapshotConfig `yaml:"snapshots" json:"snapshots"`
+
+	// Client IP of requests relayed by trusted proxies
+	RealIP RealIPConfig `yaml:"real_ip" json:"real_ip"`
 }
 
 // ServerConfig represents a server with its weight
 type ServerConfig struct {
-	Address string `yaml:"address" json:"address"`
-	Weight  int    `yaml:"weight" json:"weight"`
+	Address     string              `yaml:"address" json:"address"`
+	Weight      int                 `yaml:"weight" json:"weight"`
+	Maintenance []MaintenanceWindow `yaml:"maintenance" json:"maintenance"`
+	Labels      map[string]string   `yaml:"labels" json:"labels"`           // Metadata such as zone or version, overrides service labels
+	HealthPath  string              `yaml:"health_path" json:"health_path"` // Health check path of this server, overrides the service and global paths
+}
+
+// MaintenanceWindow scheduled period during which a server is drained,
+// either a fixed RFC3339 interval or a recurring cron start with a duration
+type MaintenanceWindow struct {
+	Start    time.Time     `yaml:"start" json:"start"`
+	End      time.Time     `yaml:"end" json:"end"`
+	Cron     string        `yaml:"cron" json:"cron"`
+	Duration time.Duration `yaml:"duration" json:"duration"`
+	Timezone string        `yaml:"timezone" json:"timezone"` // Location used to evaluate cron, defaults to UTC
+}
+
+// StateConfig runtime state persistence configuration
+type StateConfig struct {
+	Path string `yaml:"path" json:"path"` // State file, persistence is disabled when empty
+}
+
+// GeoIPConfig client location lookup configuration
+type GeoIPConfig struct {
+	Database string `yaml:"database" json:"database"` // MaxMind country or city database, lookups are disabled when empty
+}
+
+// RouteCacheConfig routing decision cache configuration
+type RouteCacheConfig struct {
+	Size int `yaml:"size" json:"size"` // Cached method, host and path decisions, disabled when 0
+}
+
+// CacheConfig response cache configuration, shared by the routes with a cache
+type CacheConfig struct {
+	MaxSize     int64  `yaml:"max_size" json:"max_size"`           // Memory used by cached responses (bytes, default 64MB)
+	MaxBodySize int64  `yaml:"max_body_size" json:"max_body_size"` // Largest response body stored (bytes, default 1MB)
+	TagHeader   string `yaml:"tag_header" json:"tag_header"`       // Response header listing the tags used to purge, removed before responding
+}
+
+// LoadShedConfig rejects part of the traffic while the process is under
+// resource pressure, before it becomes unresponsive. Pressure is the highest
+// ratio of a sampled resource to its threshold, 1 means a threshold is reached
+type LoadShedConfig struct {
+	Enabled    bool             `yaml:"enabled" json:"enabled"`
+	Interval   time.Duration    `yaml:"interval" json:"interval"`     // Resource sampling interval (default 1s)
+	CPU        float64          `yaml:"cpu" json:"cpu"`               // CPU utilization of the usable cores, between 0 and 1
+	Memory     int64            `yaml:"memory" json:"memory"`         // Heap in use (bytes)
+	Goroutines int              `yaml:"goroutines" json:"goroutines"` // Number of goroutines
+	GCPause    time.Duration    `yaml:"gc_pause" json:"gc_pause"`     // Longest GC pause since the previous sample
+	Policies   []LoadShedPolicy `yaml:"policies" json:"policies"`
+}
+
+// LoadShedPolicy rejects a percentage of the requests of some routes with 503
+// from a pressure level on, the strictest matching policy applies
+type LoadShedPolicy struct {
+	Pressure float64  `yaml:"pressure" json:"pressure"` // Pressure from which the policy applies
+	Percent  float64  `yaml:"percent" json:"percent"`   // Share of the requests rejected, between 0 and 100
+	Routes   []string `yaml:"routes" json:"routes"`     // Shed routes, every route when empty
+}
+
+// RecordConfig records matched requests to a file, one JSON object per line,
+// so they can be sent to another backend with nexus replay
+type RecordConfig struct {
+	Enabled       bool     `yaml:"enabled" json:"enabled"`
+	File          string   `yaml:"file" json:"file"`                     // File the requests are appended to
+	Routes        []string `yaml:"routes" json:"routes"`                 // Recorded routes, every route when empty
+	MaxBodySize   int64    `yaml:"max_body_size" json:"max_body_size"`   // Largest request body recorded (bytes, default 64KB)
+	RedactHeaders []string `yaml:"redact_headers" json:"redact_headers"` // Headers whose values are not recorded
+}
+
+// MiddlewareConfig a middleware applied to every request of the listeners
+// before it is routed, middlewares run in the configured order
+type MiddlewareConfig struct {
+	Type      string   `yaml:"type" json:"type"`           // request_id, access_log or headers
+	Listeners []string `yaml:"listeners" json:"listeners"` // http and https (default: both)
+
+	// request_id: header carrying the request ID (default X-Request-Id)
+	Header string `yaml:"header" json:"header"`
+
+	// access_log: text (default, combined log format) or json lines,
+	// appended to the file or written to stdout, or sent to a syslog or
+	// HTTP sink
+	Format string            `yaml:"format" json:"format"`
+	File   string            `yaml:"file" json:"file"`
+	Sink   string            `yaml:"sink" json:"sink"` // file (default with file), stdout (default without), syslog, http or otel
+	Syslog *SyslogSinkConfig `yaml:"syslog" json:"syslog"`
+	HTTP   *HTTPSinkConfig   `yaml:"http" json:"http"`
+
+	// headers: set on requests and responses, an empty value removes the header
+	RequestHeaders  map[string]string `yaml:"request_headers" json:"request_headers"`
+	ResponseHeaders map[string]string `yaml:"response_headers" json:"response_headers"`
+}
+
+// Middleware types
+const (
+	MiddlewareRequestID = "request_id"
+	MiddlewareAccessLog = "access_log"
+	MiddlewareHeaders   = "headers"
+)
+
+// Access log sinks
+const (
+	AccessLogSinkFile   = "file"
+	AccessLogSinkStdout = "stdout"
+	AccessLogSinkSyslog = "syslog"
+	AccessLogSinkHTTP   = "http"
+	AccessLogSinkOTel   = "otel"
+)
+
+// SyslogFacilities are the syslog facility codes by name
+var SyslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// SyslogSinkConfig RFC 5424 syslog server receiving access log lines
+type SyslogSinkConfig struct {
+	Network  string `yaml:"network" json:"network"`   // udp (default), tcp or unix
+	Address  string `yaml:"address" json:"address"`   // host:port, or the socket path with unix
+	Facility string `yaml:"facility" json:"facility"` // e.g. daemon or local0 (default local0)
+	AppName  string `yaml:"app_name" json:"app_name"` // APP-NAME of the messages (default nexus)
+}
+
+// HTTPSinkConfig HTTP endpoint receiving batches of access log entries
+type HTTPSinkConfig struct {
+	URL           string            `yaml:"url" json:"url"`
+	Format        string            `yaml:"format" json:"format"`                 // json (default, one entry per line) or otlp (OTLP/HTTP JSON logs)
+	Headers       map[string]string `yaml:"headers" json:"headers"`               // Values may be references, see ResolveCredential
+	BatchSize     int               `yaml:"batch_size" json:"batch_size"`         // Entries per request (default 100)
+	FlushInterval time.Duration     `yaml:"flush_interval" json:"flush_interval"` // Partial batches are sent at least this often (default 1s)
+	Timeout       time.Duration     `yaml:"timeout" json:"timeout"`               // Request timeout (default 5s)
+	MaxRetries    int               `yaml:"max_retries" json:"max_retries"`       // Retries of failed batches before they are dropped (default 3)
+	QueueSize     int               `yaml:"queue_size" json:"queue_size"`         // Entries waiting to be sent, newer ones are dropped when full (default 10000)
+}
+
+// SecretsConfig providers of secret://provider/path#key references. Resolved
+// secrets are cached and refreshed in the background
+type SecretsConfig struct {
+	RefreshInterval time.Duration `yaml:"refresh_interval" json:"refresh_interval"` // How often cached secrets are read again (default 5m)
+	Vault           *VaultConfig  `yaml:"vault" json:"vault"`                       // Referenced as secret://vault/<mount>/<path>#<key>
+}
+
+// VaultConfig HashiCorp Vault KV secrets engine configuration
+type VaultConfig struct {
+	Address   string        `yaml:"address" json:"address"`       // Defaults to VAULT_ADDR
+	Token     string        `yaml:"token" json:"token"`           // Token or env:// / file:// reference, defaults to VAULT_TOKEN
+	Namespace string        `yaml:"namespace" json:"namespace"`   // Enterprise namespace
+	KVVersion int           `yaml:"kv_version" json:"kv_version"` // Version of the KV engine, 1 or 2 (default 2)
+	Timeout   time.Duration `yaml:"timeout" json:"timeout"`       // Per request timeout (default 5s)
+}
+
+// RateLimitStoreConfig rate limit counter storage configuration
+type RateLimitStoreConfig struct {
+	Store string      `yaml:"store" json:"store"` // local (default) or redis
+	Redis RedisConfig `yaml:"redis" json:"redis"`
+}
+
+// RedisConfig Redis connection configuration
+type RedisConfig struct {
+	Address   string        `yaml:"address" json:"address"`
+	Password  string        `yaml:"password" json:"password"` // Password or reference, see ResolveCredential
+	DB        int           `yaml:"db" json:"db"`
+	Timeout   time.Duration `yaml:"timeout" json:"timeout"`       // Per command timeout before falling back to local limits
+	KeyPrefix string        `yaml:"key_prefix" json:"key_prefix"` // Prefix of the counter keys
+}
+
+// TLSConfig TLS listener configuration
+type TLSConfig struct {
+	Enabled        bool                `yaml:"enabled" json:"enabled"`
+	ListenAddr     string              `yaml:"listen_addr" json:"listen_addr"`
+	Certificates   []CertificateConfig `yaml:"certificates" json:"certificates"`       // Selected by SNI, the first one is the default
+	ReloadInterval time.Duration       `yaml:"reload_interval" json:"reload_interval"` // How often certificate files are checked for changes
+	ClientAuth     *ClientAuthConfig   `yaml:"client_auth" json:"client_auth"`
+}
+
+// ClientAuthConfig client certificate verification on the TLS listener.
+// Presented certificates must chain to the CA bundle and not be revoked
+type ClientAuthConfig struct {
+	Mode     string   `yaml:"mode" json:"mode"`           // optional (default): verify certificates that are presented, require: reject handshakes without one
+	CAFile   string   `yaml:"ca_file" json:"ca_file"`     // PEM bundle of the CAs issuing client certificates
+	CRLFiles []string `yaml:"crl_files" json:"crl_files"` // PEM or DER revocation lists signed by a CA of the bundle
+}
+
+// CertificateConfig certificate and private key file pair, either may be a
+// secret:// reference to the PEM contents instead
+type CertificateConfig struct {
+	CertFile string `yaml:"cert_file" json:"cert_file"`
+	KeyFile  string `yaml:"key_file" json:"key_file"`
+}
+
+// AdminConfig admin API configuration
+type AdminConfig struct {
+	Enabled    bool             `yaml:"enabled" json:"enabled"`
+	ListenAddr string           `yaml:"listen_addr" json:"listen_addr"`
+	TLS        *AdminTLSConfig  `yaml:"tls" json:"tls"`   // Serve the admin API over TLS, required by client certificate auth
+	Auth       *AdminAuthConfig `yaml:"auth" json:"auth"` // Require credentials, the admin API is open to anyone reaching the port without it
+}
+
+// AdminTLSConfig TLS settings of the admin listener
+type AdminTLSConfig struct {
+	Certificate CertificateConfig `yaml:"certificate" json:"certificate"`
+	ClientAuth  *ClientAuthConfig `yaml:"client_auth" json:"client_auth"` // Verify client certificates for AdminAuthConfig.ClientCerts
+}
+
+// Admin API roles, read can only call endpoints that don't change anything
+const (
+	AdminRoleRead  = "read"
+	AdminRoleWrite = "write"
+)
+
+// AdminAuthConfig credentials accepted by the admin API, each granting a role
+// (default read). Requests matching none of them are rejected
+type AdminAuthConfig struct {
+	Tokens      []AdminTokenConfig      `yaml:"tokens" json:"tokens"`             // Authorization: Bearer tokens
+	Users       []AdminUserConfig       `yaml:"users" json:"users"`               // Basic auth users
+	ClientCerts []AdminClientCertConfig `yaml:"client_certs" json:"client_certs"` // Client certificates verified by the admin TLS listener
+}
+
+// AdminTokenConfig bearer token of the admin API
+type AdminTokenConfig struct {
+	Name  string `yaml:"name" json:"name"`   // Identifies the token in logs
+	Token string `yaml:"token" json:"token"` // Token, or a reference to it
+	Role  string `yaml:"role" json:"role"`
+}
+
+// AdminUserConfig basic auth user of the admin API
+type AdminUserConfig struct {
+	Username string `yaml:"username" json:"username"`
+	Password string `yaml:"password" json:"password"` // Password, or a reference to it
+	Role     string `yaml:"role" json:"role"`
+}
+
+// AdminClientCertConfig role of the client certificates with a subject common name
+type AdminClientCertConfig struct {
+	CommonName string `yaml:"common_name" json:"common_name"`
+	Role       string `yaml:"role" json:"role"`
 }
 
 // HealthCheckConfig health check configuration
@@ -78,11 +951,85 @@ type HealthCheckConfig struct {
 	Interval time.Duration `yaml:"interval" json:"interval"`
 	Timeout  time.Duration `yaml:"timeout" json:"timeout"`
 	Path     string        `yaml:"path" json:"path"`
+	// Mark new servers healthy until the first interval elapses instead of
+	// probing them before they receive traffic
+	AssumeHealthy bool `yaml:"assume_healthy" json:"assume_healthy"`
+
+	Shared SharedHealthConfig   `yaml:"shared" json:"shared"`
+	Push   HealthPushConfig     `yaml:"push" json:"push"`
+	TLS    HealthCheckTLSConfig `yaml:"tls" json:"tls"`
+}
+
+// HealthCheckTLSConfig TLS settings of probes against https servers
+type HealthCheckTLSConfig struct {
+	CAFile             string `yaml:"ca_file" json:"ca_file"`     // PEM bundle verifying the servers, system roots when empty
+	CertFile           string `yaml:"cert_file" json:"cert_file"` // Client certificate for servers requiring mTLS
+	KeyFile            string `yaml:"key_file" json:"key_file"`
+	ServerName         string `yaml:"server_name" json:"server_name"` // Overrides the SNI and verified name
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" json:"insecure_skip_verify"`
+}
+
+// HealthPushConfig health summaries pushed to a webhook on state changes
+type HealthPushConfig struct {
+	URL         string        `yaml:"url" json:"url"`                   // Webhook URL, disabled when empty
+	Format      string        `yaml:"format" json:"format"`             // json (default) or slack
+	MinInterval time.Duration `yaml:"min_interval" json:"min_interval"` // Shortest time between two pushes (default 1m)
+	Timeout     time.Duration `yaml:"timeout" json:"timeout"`           // Webhook request timeout (default 5s)
+}
+
+// LeaderElectionConfig elects one replica of a fleet to perform the shared
+// work, such as pushing health summaries
+type LeaderElectionConfig struct {
+	Store  string        `yaml:"store" json:"store"` // consul or redis, disabled when empty
+	Key    string        `yaml:"key" json:"key"`     // Lock key (default: nexus/leader)
+	TTL    time.Duration `yaml:"ttl" json:"ttl"`     // Lease of the leader, renewed every third of it (default 15s)
+	Consul ConsulConfig  `yaml:"consul" json:"consul"`
+	Redis  RedisConfig   `yaml:"redis" json:"redis"`
+}
+
+// PassthroughConfig TLS passthrough listener. Only the SNI of the ClientHello
+// is inspected, the connection is forwarded as is to a server of the matched
+// service which terminates TLS itself
+type PassthroughConfig struct {
+	ListenAddr     string              `yaml:"listen_addr" json:"listen_addr"`         // Disabled when empty
+	Routes         []*PassthroughRoute `yaml:"routes" json:"routes"`                   // Matched in order
+	DefaultService string              `yaml:"default_service" json:"default_service"` // Service of connections no route matches, they are closed when empty
+	HelloTimeout   time.Duration       `yaml:"hello_timeout" json:"hello_timeout"`     // Time clients have to send the ClientHello (default 10s)
+	DialTimeout    time.Duration       `yaml:"dial_timeout" json:"dial_timeout"`       // Backend connect timeout (default 5s)
+}
+
+// PassthroughRoute sends the connections of some server names to a service
+type PassthroughRoute struct {
+	SNI     []string `yaml:"sni" json:"sni"` // Server names, "*.example.com" matches its subdomains
+	Service string   `yaml:"service" json:"service"`
+}
+
+// ConsulConfig Consul agent connection settings
+type ConsulConfig struct {
+	Address string `yaml:"address" json:"address"` // Agent URL, e.g. http://127.0.0.1:8500
+	Token   string `yaml:"token" json:"token"`     // ACL token or reference, see ResolveCredential
+}
+
+// SharedHealthConfig health state shared between replicas
+type SharedHealthConfig struct {
+	Store string      `yaml:"store" json:"store"` // redis, disabled when empty
+	Redis RedisConfig `yaml:"redis" json:"redis"`
 }
 
 // TelemetryConfig telemetry configuration
 type TelemetryConfig struct {
 	OpenTelemetry OpenTelemetryConfig `yaml:"opentelemetry" json:"opentelemetry"`
+	StatsD        StatsDConfig        `yaml:"statsd" json:"statsd"`
+}
+
+// StatsDConfig statsd metrics configuration
+type StatsDConfig struct {
+	Enabled       bool          `yaml:"enabled" json:"enabled"`
+	Address       string        `yaml:"address" json:"address"`               // UDP host:port (default 127.0.0.1:8125)
+	Prefix        string        `yaml:"prefix" json:"prefix"`                 // Metric name prefix (default "nexus.")
+	Datadog       bool          `yaml:"datadog" json:"datadog"`               // Send DogStatsD tags
+	Tags          []string      `yaml:"tags" json:"tags"`                     // Tags added to every metric, requires datadog
+	FlushInterval time.Duration `yaml:"flush_interval" json:"flush_interval"` // Default 1s
 }
 
 // OpenTelemetryConfig OpenTelemetry configuration
@@ -91,6 +1038,14 @@ type OpenTelemetryConfig struct {
 	Endpoint    string       `yaml:"endpoint" json:"endpoint"`
 	ServiceName string       `yaml:"service_name" json:"service_name"`
 	Metrics     MetricConfig `yaml:"metrics" json:"metrics"`
+	Logs        LogsConfig   `yaml:"logs" json:"logs"`
+}
+
+// LogsConfig export of the application logs as OpenTelemetry logs, access
+// logs are exported by access_log middlewares with the otel sink
+type LogsConfig struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	Level   string `yaml:"level" json:"level"` // Lowest level exported: debug, info (default), warn or error
 }
 
 // MetricConfig metric configuration
@@ -100,8 +1055,13 @@ type MetricConfig struct {
 
 // ConfigWatcher struct for file monitoring
 type ConfigWatcher struct {
-	mu       sync.RWMutex
-	filePath string
-	lastMod  time.Time
-	watchers []func(*Config)
+	mu        sync.RWMutex
+	filePath  string
+	lastMod   time.Time
+	lastSize  int64
+	watchers  []func(*Config)
+	metrics   *reloadMetrics
+	audit     *audit.Log
+	applied   []byte // Contents of the applied config file
+	snapshots *snapshot.Store
 }
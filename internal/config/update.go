@@ -1,7 +1,6 @@
 package config
 
 import (
-	"fmt"
 	"time"
 )
 
@@ -28,7 +27,7 @@ func (c *Config) UpdateBalancerType(serviceName string, bType string) error {
 
 	sConfig, ok := c.Services[serviceName]
 	if !ok {
-		return fmt.Errorf("service %s not found", serviceName)
+		return &ServiceNotFoundError{Service: serviceName}
 	}
 
 	sConfig.BalancerType = bType
@@ -42,7 +41,7 @@ func (c *Config) UpdateServers(serviceName string, servers []ServerConfig) error
 
 	sConfig, ok := c.Services[serviceName]
 	if !ok {
-		return fmt.Errorf("service %s not found", serviceName)
+		return &ServiceNotFoundError{Service: serviceName}
 	}
 
 	if err := validateServers(servers, sConfig.BalancerType); err != nil {
@@ -3,7 +3,21 @@ package config
 import (
 	"errors"
 	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+
+	lg "nexus/internal/logger"
+	"nexus/internal/schedule"
 )
 
 // Validate Validate config file
@@ -14,6 +28,11 @@ func Validate(filePath string) error {
 		return err
 	}
 
+	return c.Validate()
+}
+
+// Validate Validate a loaded config
+func (c *Config) Validate() error {
 	// Use validation functions instead of original logic
 	if err := validateListenAddr(c.ListenAddr); err != nil {
 		return err
@@ -30,16 +49,206 @@ func Validate(filePath string) error {
 		if err := validateBalancerType(svc.BalancerType); err != nil {
 			return fmt.Errorf("service %s: %w", svc.Name, err)
 		}
-		if err := validateServers(svc.Servers, svc.BalancerType); err != nil {
+		// Discovered services may start without servers
+		if len(svc.Servers) > 0 || (svc.Discovery == nil && svc.Registration == nil) {
+			if err := validateServers(svc.Servers, svc.BalancerType); err != nil {
+				return fmt.Errorf("service %s: %w", svc.Name, err)
+			}
+		}
+		if svc.Discovery != nil {
+			if err := validateDiscovery(svc.Discovery); err != nil {
+				return fmt.Errorf("service %s: %w", svc.Name, err)
+			}
+		}
+		if svc.Registration != nil {
+			if err := validateRegistration(svc.Registration); err != nil {
+				return fmt.Errorf("service %s: %w", svc.Name, err)
+			}
+		}
+		if svc.Dedup != nil {
+			if err := validateDedup(*svc.Dedup); err != nil {
+				return fmt.Errorf("service %s: %w", svc.Name, err)
+			}
+		}
+		if svc.Affinity != nil {
+			if err := validateAffinity(svc.Affinity); err != nil {
+				return fmt.Errorf("service %s: %w", svc.Name, err)
+			}
+		}
+		if svc.Concurrency != nil {
+			if err := validateConcurrency(svc.Concurrency); err != nil {
+				return fmt.Errorf("service %s: %w", svc.Name, err)
+			}
+		}
+		if svc.UpstreamAuth != nil {
+			if err := validateUpstreamAuth(svc.UpstreamAuth); err != nil {
+				return fmt.Errorf("service %s: %w", svc.Name, err)
+			}
+		}
+		if svc.HealthCheck != nil {
+			if err := validateServiceHealthCheck(svc.HealthCheck); err != nil {
+				return fmt.Errorf("service %s: %w", svc.Name, err)
+			}
+			if svc.HealthCheck.Type == HealthCheckGRPC && slices.ContainsFunc(svc.Servers, func(s ServerConfig) bool { return s.HealthPath != "" }) {
+				return fmt.Errorf("service %s: grpc health checks cannot set server health_path", svc.Name)
+			}
+		}
+		if svc.Signing != nil {
+			if err := validateRequestSigning(svc.Signing); err != nil {
+				return fmt.Errorf("service %s: %w", svc.Name, err)
+			}
+		}
+		if svc.Warmup != nil {
+			if err := validateWarmup(svc.Warmup); err != nil {
+				return fmt.Errorf("service %s: %w", svc.Name, err)
+			}
+		}
+		if err := validateLabels(svc.Labels); err != nil {
 			return fmt.Errorf("service %s: %w", svc.Name, err)
 		}
+		for _, server := range svc.Servers {
+			if err := validateLabels(server.Labels); err != nil {
+				return fmt.Errorf("service %s: server %s: %w", svc.Name, server.Address, err)
+			}
+		}
+	}
+	if err := validateDedup(c.Dedup); err != nil {
+		return err
+	}
+	if err := validateAdmin(c.Admin); err != nil {
+		return err
+	}
+	if err := validateTLS(c.TLS); err != nil {
+		return err
+	}
+	if err := validateRateLimitStore(c.RateLimit); err != nil {
+		return err
+	}
+	if err := validateSecrets(c.Secrets); err != nil {
+		return err
+	}
+	if err := validateStatsD(c.Telemetry.StatsD); err != nil {
+		return err
+	}
+	switch c.Telemetry.OpenTelemetry.Logs.Level {
+	case "", "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("unsupported opentelemetry logs level: %s", c.Telemetry.OpenTelemetry.Logs.Level)
+	}
+	if c.RouteCache.Size < 0 {
+		return errors.New("route cache size cannot be negative")
+	}
+	if err := validateCache(c.Cache); err != nil {
+		return err
+	}
+	if err := validateLoadShed(c.LoadShed); err != nil {
+		return err
+	}
+	if err := validateRecord(c.Record); err != nil {
+		return err
+	}
+	if c.Upstream.LeakTimeout < 0 {
+		return errors.New("upstream leak_timeout cannot be negative")
+	}
+	if h2 := c.Upstream.HTTP2; h2 != nil {
+		switch {
+		case h2.MaxConcurrentStreams < 0:
+			return errors.New("upstream http2 max_concurrent_streams cannot be negative")
+		case h2.MaxConnections < 0:
+			return errors.New("upstream http2 max_connections cannot be negative")
+		case h2.ReadIdleTimeout < 0:
+			return errors.New("upstream http2 read_idle_timeout cannot be negative")
+		}
+	}
+	if dns := c.Upstream.DNS; dns != nil {
+		switch {
+		case dns.MinTTL < 0 || dns.MaxTTL < 0 || dns.NegativeTTL < 0 || dns.StaleTTL < 0:
+			return errors.New("upstream dns ttls cannot be negative")
+		case dns.MaxTTL > 0 && dns.MinTTL > dns.MaxTTL:
+			return errors.New("upstream dns min_ttl cannot exceed max_ttl")
+		}
+	}
+	switch c.Paths.TrailingSlash {
+	case "", TrailingSlashKeep, TrailingSlashStrip, TrailingSlashRedirect:
+	default:
+		return fmt.Errorf("unsupported path_normalization trailing_slash: %s", c.Paths.TrailingSlash)
+	}
+	switch c.Paths.EncodedSlashes {
+	case "", EncodedSlashesKeep, EncodedSlashesDecode, EncodedSlashesReject:
+	default:
+		return fmt.Errorf("unsupported path_normalization encoded_slashes: %s", c.Paths.EncodedSlashes)
+	}
+	if strings.ContainsAny(c.Signature.Server, "\r\n\x00") {
+		return fmt.Errorf("invalid proxy_signature server: %q", c.Signature.Server)
+	}
+	if strings.ContainsAny(c.Signature.ViaName, " \t\r\n,()") {
+		return fmt.Errorf("invalid proxy_signature via_name: %q", c.Signature.ViaName)
+	}
+	for _, middleware := range c.Middleware {
+		if err := validateMiddleware(middleware); err != nil {
+			return err
+		}
+		if middleware.Sink == AccessLogSinkOTel && !(c.Telemetry.OpenTelemetry.Enabled && c.Telemetry.OpenTelemetry.Logs.Enabled) {
+			return errors.New("otel access log sink requires opentelemetry logs")
+		}
+	}
+
+	if err := validateClassification(c.Classify); err != nil {
+		return err
 	}
 
 	// Validate route config
+	if err := ValidateRouteOverlap(c.Routes); err != nil {
+		return err
+	}
 	for _, route := range c.Routes {
 		if err := validateRoute(route); err != nil {
 			return fmt.Errorf("route %s: %w", route.Name, err)
 		}
+		if err := ValidateRouteServices(route, c.Services); err != nil {
+			return err
+		}
+		geoMatch := len(route.Match.Countries) > 0 || len(route.Match.Continents) > 0
+		if geoMatch && c.GeoIP.Database == "" {
+			return fmt.Errorf("route %s: countries and continents require a geoip database", route.Name)
+		}
+		if route.ClientCert != nil && (!c.TLS.Enabled || c.TLS.ClientAuth == nil) {
+			return fmt.Errorf("route %s: client_cert requires tls client_auth", route.Name)
+		}
+		tags := make([]string, 0, len(route.Match.Tags)+1)
+		for name := range route.Match.Tags {
+			tags = append(tags, name)
+		}
+		if route.RateLimit != nil && strings.HasPrefix(route.RateLimit.Key, "tag:") {
+			tags = append(tags, strings.TrimPrefix(route.RateLimit.Key, "tag:"))
+		}
+		for _, name := range tags {
+			if !classificationSets(c.Classify, name) {
+				return fmt.Errorf("route %s: tag %s is not set by any classification rule", route.Name, name)
+			}
+		}
+	}
+
+	if err := validateSharedHealth(c.HealthCheck.Shared); err != nil {
+		return err
+	}
+	if err := validateHealthPush(c.HealthCheck.Push); err != nil {
+		return err
+	}
+	if err := validateLeaderElection(c.Election); err != nil {
+		return err
+	}
+	if err := validatePassthrough(c.Passthrough, c); err != nil {
+		return err
+	}
+	if c.Snapshots.Keep < 0 {
+		return errors.New("snapshots keep cannot be negative")
+	}
+	if err := validateRealIP(c.RealIP); err != nil {
+		return err
+	}
+	if (c.HealthCheck.TLS.CertFile == "") != (c.HealthCheck.TLS.KeyFile == "") {
+		return errors.New("health check tls requires both cert_file and key_file")
 	}
 
 	return validateHealthCheck(c.HealthCheck.Interval, c.HealthCheck.Timeout)
@@ -98,51 +307,1511 @@ func validateServers(servers []ServerConfig, balancerType string) error {
 		if balancerType == "weighted_round_robin" && server.Weight <= 0 {
 			return fmt.Errorf("invalid weight for server %s: %d", server.Address, server.Weight)
 		}
+		if server.HealthPath != "" && !strings.HasPrefix(server.HealthPath, "/") {
+			return fmt.Errorf("health_path of server %s must start with /", server.Address)
+		}
+		for _, window := range server.Maintenance {
+			if err := validateMaintenanceWindow(window); err != nil {
+				return fmt.Errorf("server %s: %w", server.Address, err)
+			}
+		}
 	}
 
 	return nil
 }
 
-// validateHealthCheck Validate health check config
-func validateHealthCheck(interval, timeout time.Duration) error {
-	if interval <= 0 {
-		return errors.New("health check interval must be positive")
+// ValidateServers Validate a server list, e.g. one discovered at runtime
+func ValidateServers(servers []ServerConfig, balancerType string) error {
+	return validateServers(servers, balancerType)
+}
+
+// validateDiscovery Validate service discovery config
+func validateDiscovery(discovery *DiscoveryConfig) error {
+	u, err := url.Parse(discovery.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid discovery url: %q", discovery.URL)
 	}
-	if timeout <= 0 {
-		return errors.New("health check timeout must be positive")
+	if discovery.Interval < 0 {
+		return errors.New("discovery interval cannot be negative")
 	}
-	if timeout >= interval {
-		return errors.New("health check timeout must be less than interval")
+	if discovery.Timeout < 0 {
+		return errors.New("discovery timeout cannot be negative")
+	}
+	if discovery.Interval > 0 && discovery.Timeout >= discovery.Interval {
+		return errors.New("discovery timeout must be less than interval")
 	}
 
 	return nil
 }
 
-// validateRoute Validate route config
-func validateRoute(route *RouteConfig) error {
-	if route.Name == "" {
-		return errors.New("route name cannot be empty")
+// validateRegistration Validate self-registration config
+func validateRegistration(registration *RegistrationConfig) error {
+	if registration.TTL < 0 {
+		return errors.New("registration ttl cannot be negative")
 	}
-	if route.Match.Path == "" && route.Match.Method == "" && route.Match.Host == "" && len(route.Match.Headers) == 0 {
-		return fmt.Errorf("route %s: match condition cannot be empty", route.Name)
+	if registration.MaxTTL < 0 {
+		return errors.New("registration max_ttl cannot be negative")
 	}
-	if route.Service == "" && len(route.Split) == 0 {
-		return fmt.Errorf("route %s: must specify either service or split", route.Name)
+	if registration.MaxTTL > 0 && registration.TTL > registration.MaxTTL {
+		return errors.New("registration ttl cannot exceed max_ttl")
 	}
-	if len(route.Split) > 0 {
-		totalWeight := 0
-		for _, split := range route.Split {
-			if split.Service == "" {
-				return fmt.Errorf("route %s: split service cannot be empty", route.Name)
+
+	return nil
+}
+
+// validateExpect Validate upstream response expectation
+func validateExpect(expect *ExpectConfig) error {
+	for _, code := range expect.StatusCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid expected status code: %d", code)
+		}
+	}
+	for _, field := range expect.JSONFields {
+		if field == "" || strings.HasPrefix(field, ".") || strings.HasSuffix(field, ".") {
+			return fmt.Errorf("invalid expected json field: %q", field)
+		}
+	}
+	if expect.MaxRetries < 0 {
+		return errors.New("expect max_retries cannot be negative")
+	}
+	if expect.MaxBodySize < 0 {
+		return errors.New("expect max_body_size cannot be negative")
+	}
+
+	return nil
+}
+
+// validateLocationRewrite Validate backend redirect rewrite config
+func validateLocationRewrite(rewrite *LocationRewriteConfig) error {
+	switch rewrite.Scheme {
+	case "", "http", "https":
+	default:
+		return fmt.Errorf("unsupported location rewrite scheme: %s", rewrite.Scheme)
+	}
+	if strings.ContainsAny(rewrite.Host, "/?#") {
+		return fmt.Errorf("location rewrite host must be a host name: %s", rewrite.Host)
+	}
+
+	return nil
+}
+
+// validateSubFilter Validate response body rewriting config
+// validateErrorPage Validate error page config, the page must load
+func validateErrorPage(page *ErrorPageConfig) error {
+	if len(page.StatusCodes) == 0 {
+		return errors.New("error page requires at least one status code")
+	}
+	for _, status := range page.StatusCodes {
+		if status < 400 || status > 599 {
+			return fmt.Errorf("invalid error page status code: %d", status)
+		}
+	}
+	if page.Status != 0 && (page.Status < 200 || page.Status > 599) {
+		return fmt.Errorf("invalid error page status: %d", page.Status)
+	}
+	if (page.File == "") == (page.Template == "") {
+		return errors.New("error page requires exactly one of file or template")
+	}
+	if page.File != "" {
+		if _, err := os.ReadFile(page.File); err != nil {
+			return fmt.Errorf("error page: %w", err)
+		}
+		return nil
+	}
+	if _, err := template.ParseFiles(page.Template); err != nil {
+		return fmt.Errorf("error page: %w", err)
+	}
+
+	return nil
+}
+
+func validateSubFilter(filter *SubFilterConfig) error {
+	if len(filter.Replacements) == 0 {
+		return errors.New("sub filter requires at least one replacement")
+	}
+	for _, replacement := range filter.Replacements {
+		if (replacement.From == "") == (replacement.Pattern == "") {
+			return errors.New("sub filter replacement requires exactly one of from or pattern")
+		}
+		if strings.Contains(replacement.From, "\n") {
+			return fmt.Errorf("sub filter from cannot contain a line break: %q", replacement.From)
+		}
+		if replacement.Pattern != "" {
+			if _, err := regexp.Compile(replacement.Pattern); err != nil {
+				return fmt.Errorf("invalid sub filter pattern %q: %w", replacement.Pattern, err)
 			}
-			if split.Weight <= 0 {
-				return fmt.Errorf("route %s: split weight must be positive", route.Name)
+		}
+	}
+	for _, contentType := range filter.ContentTypes {
+		if !strings.Contains(contentType, "/") {
+			return fmt.Errorf("invalid sub filter content type: %q", contentType)
+		}
+	}
+	if filter.MaxBodySize < 0 {
+		return errors.New("sub filter max_body_size cannot be negative")
+	}
+
+	return nil
+}
+
+// validateBuffering Validate route buffering config
+func validateBuffering(buffering *BufferingConfig) error {
+	if buffering.MaxBufferSize < 0 {
+		return errors.New("buffering max_buffer_size cannot be negative")
+	}
+	if buffering.BufferSize < 0 {
+		return errors.New("buffering buffer_size cannot be negative")
+	}
+
+	return nil
+}
+
+// validateUpstreamCompression Validate upstream compression config
+func validateUpstreamCompression(compression *UpstreamCompressionConfig) error {
+	if !compression.Request && !compression.DecodeResponses {
+		return errors.New("upstream compression requires request or decode_responses")
+	}
+	if compression.MinSize < 0 {
+		return errors.New("upstream compression min_size cannot be negative")
+	}
+	for _, contentType := range compression.ContentTypes {
+		if !strings.Contains(contentType, "/") {
+			return fmt.Errorf("invalid upstream compression content type: %q", contentType)
+		}
+	}
+
+	return nil
+}
+
+// validateDedup Validate request deduplication config
+func validateDedup(dedup DedupConfig) error {
+	if dedup.MaxBodySize < 0 {
+		return errors.New("dedup max_body_size cannot be negative")
+	}
+
+	return nil
+}
+
+// validateCache Validate response cache config
+func validateCache(cache CacheConfig) error {
+	if cache.MaxSize < 0 {
+		return errors.New("cache max_size cannot be negative")
+	}
+	if cache.MaxBodySize < 0 {
+		return errors.New("cache max_body_size cannot be negative")
+	}
+
+	return nil
+}
+
+// validateLoadShed Validate load shedding config
+func validateLoadShed(shed LoadShedConfig) error {
+	if !shed.Enabled {
+		return nil
+	}
+	if shed.Interval < 0 {
+		return errors.New("load_shedding interval cannot be negative")
+	}
+	if shed.CPU < 0 || shed.CPU > 1 {
+		return errors.New("load_shedding cpu must be between 0 and 1")
+	}
+	if shed.Memory < 0 || shed.Goroutines < 0 || shed.GCPause < 0 {
+		return errors.New("load_shedding thresholds cannot be negative")
+	}
+	if shed.CPU == 0 && shed.Memory == 0 && shed.Goroutines == 0 && shed.GCPause == 0 {
+		return errors.New("load_shedding requires at least one threshold")
+	}
+	if len(shed.Policies) == 0 {
+		return errors.New("load_shedding requires at least one policy")
+	}
+	for _, policy := range shed.Policies {
+		if policy.Pressure <= 0 {
+			return errors.New("load_shedding policy pressure must be positive")
+		}
+		if policy.Percent <= 0 || policy.Percent > 100 {
+			return errors.New("load_shedding policy percent must be between 0 and 100")
+		}
+	}
+
+	return nil
+}
+
+// validateRecord Validate traffic recording config
+func validateRecord(record RecordConfig) error {
+	if !record.Enabled {
+		return nil
+	}
+	if record.File == "" {
+		return errors.New("record file is required")
+	}
+	if record.MaxBodySize < 0 {
+		return errors.New("record max_body_size cannot be negative")
+	}
+
+	return nil
+}
+
+// validateMiddleware Validate listener middleware config
+func validateMiddleware(middleware *MiddlewareConfig) error {
+	for _, listener := range middleware.Listeners {
+		if listener != "http" && listener != "https" {
+			return fmt.Errorf("middleware %s: unsupported listener: %s", middleware.Type, listener)
+		}
+	}
+
+	switch middleware.Type {
+	case MiddlewareRequestID:
+	case MiddlewareAccessLog:
+		if middleware.Format != "" && middleware.Format != "text" && middleware.Format != "json" {
+			return fmt.Errorf("unsupported access log format: %s", middleware.Format)
+		}
+		if err := validateAccessLogSink(middleware); err != nil {
+			return err
+		}
+	case MiddlewareHeaders:
+		if len(middleware.RequestHeaders) == 0 && len(middleware.ResponseHeaders) == 0 {
+			return errors.New("headers middleware requires request_headers or response_headers")
+		}
+	default:
+		return fmt.Errorf("unsupported middleware type: %s", middleware.Type)
+	}
+
+	return nil
+}
+
+// validateAccessLogSink Validate the sink of an access log middleware
+func validateAccessLogSink(middleware *MiddlewareConfig) error {
+	switch middleware.Sink {
+	case "", AccessLogSinkStdout, AccessLogSinkOTel:
+	case AccessLogSinkFile:
+		if middleware.File == "" {
+			return errors.New("file access log sink requires a file")
+		}
+	case AccessLogSinkSyslog:
+		syslog := middleware.Syslog
+		if syslog == nil || syslog.Address == "" {
+			return errors.New("syslog access log sink requires an address")
+		}
+		if syslog.Network != "" && syslog.Network != "udp" && syslog.Network != "tcp" && syslog.Network != "unix" {
+			return fmt.Errorf("unsupported syslog network: %s", syslog.Network)
+		}
+		if _, ok := SyslogFacilities[syslog.Facility]; syslog.Facility != "" && !ok {
+			return fmt.Errorf("unsupported syslog facility: %s", syslog.Facility)
+		}
+	case AccessLogSinkHTTP:
+		sink := middleware.HTTP
+		if sink == nil {
+			return errors.New("http access log sink requires a url")
+		}
+		if u, err := url.Parse(sink.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid access log sink url: %q", sink.URL)
+		}
+		if sink.Format != "" && sink.Format != "json" && sink.Format != "otlp" {
+			return fmt.Errorf("unsupported access log sink format: %s", sink.Format)
+		}
+		if sink.BatchSize < 0 || sink.FlushInterval < 0 || sink.Timeout < 0 || sink.MaxRetries < 0 || sink.QueueSize < 0 {
+			return errors.New("access log sink batch_size, flush_interval, timeout, max_retries and queue_size cannot be negative")
+		}
+	default:
+		return fmt.Errorf("unsupported access log sink: %s", middleware.Sink)
+	}
+
+	return nil
+}
+
+// validateLabels Validate service and server labels, names are used in
+// telemetry attribute keys
+func validateLabels(labels map[string]string) error {
+	for name := range labels {
+		if name == "" {
+			return errors.New("label name cannot be empty")
+		}
+		for _, c := range name {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.') {
+				return fmt.Errorf("invalid label name: %q", name)
 			}
-			totalWeight += split.Weight
 		}
-		if totalWeight != 100 {
-			return fmt.Errorf("route %s: split weights must sum to 100", route.Name)
+	}
+
+	return nil
+}
+
+// validateAffinity Validate session affinity config
+func validateAffinity(affinity *AffinityConfig) error {
+	if len(affinity.Secrets) == 0 {
+		return errors.New("affinity requires at least one secret")
+	}
+	for _, ref := range affinity.Secrets {
+		secret, err := ResolveCredential(ref)
+		if err != nil {
+			return fmt.Errorf("affinity secret: %w", err)
+		}
+		if len(secret) < 16 {
+			return errors.New("affinity secrets must be at least 16 bytes")
+		}
+	}
+	if affinity.TTL < 0 {
+		return errors.New("affinity ttl cannot be negative")
+	}
+
+	return nil
+}
+
+// validateUpstreamAuth Validate upstream credentials, references must resolve
+func validateUpstreamAuth(auth *UpstreamAuthConfig) error {
+	var values []string
+	switch auth.Type {
+	case "basic":
+		if auth.Username == "" {
+			return errors.New("upstream auth username cannot be empty")
+		}
+		values = []string{auth.Username, auth.Password}
+	case "bearer":
+		if auth.Token == "" {
+			return errors.New("upstream auth token cannot be empty")
+		}
+		values = []string{auth.Token}
+	case "header":
+		if auth.Header == "" || auth.Value == "" {
+			return errors.New("upstream auth header and value cannot be empty")
+		}
+		values = []string{auth.Value}
+	default:
+		return fmt.Errorf("unsupported upstream auth type: %s", auth.Type)
+	}
+
+	for _, value := range values {
+		if _, err := ResolveCredential(value); err != nil {
+			return fmt.Errorf("upstream auth credential: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// validateRequestSigning Validate outbound request signing config, secrets must resolve
+func validateRequestSigning(signing *RequestSigningConfig) error {
+	var secrets []string
+	switch signing.Type {
+	case "sigv4":
+		if signing.Region == "" || signing.Service == "" {
+			return errors.New("sigv4 signing requires region and service")
 		}
+		if signing.AccessKeyID == "" || signing.SecretAccessKey == "" {
+			return errors.New("sigv4 signing requires access_key_id and secret_access_key")
+		}
+		secrets = []string{signing.AccessKeyID, signing.SecretAccessKey, signing.SessionToken}
+	case "hmac":
+		if signing.Secret == "" {
+			return errors.New("hmac signing secret cannot be empty")
+		}
+		switch signing.Algorithm {
+		case "", "sha256", "sha512":
+		default:
+			return fmt.Errorf("unsupported hmac signing algorithm: %s", signing.Algorithm)
+		}
+		secrets = []string{signing.Secret}
+	default:
+		return fmt.Errorf("unsupported signing type: %s", signing.Type)
+	}
+
+	for _, secret := range secrets {
+		if _, err := ResolveCredential(secret); err != nil {
+			return fmt.Errorf("signing credential: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// validateConcurrency Validate concurrency limit config
+func validateConcurrency(concurrency *ConcurrencyConfig) error {
+	if concurrency.MaxConcurrent <= 0 {
+		return errors.New("concurrency max_concurrent must be positive")
+	}
+	if concurrency.MaxQueue < 0 {
+		return errors.New("concurrency max_queue cannot be negative")
+	}
+	if concurrency.QueueTimeout < 0 {
+		return errors.New("concurrency queue_timeout cannot be negative")
+	}
+	if concurrency.Key != "" && concurrency.Key != "ip" && !strings.HasPrefix(concurrency.Key, "header:") {
+		return fmt.Errorf("unsupported concurrency key: %s", concurrency.Key)
+	}
+	for client, weight := range concurrency.Weights {
+		if weight <= 0 {
+			return fmt.Errorf("concurrency weight for %s must be positive", client)
+		}
+	}
+
+	return nil
+}
+
+// validateServiceHealthCheck Validate per-service health check config
+func validateServiceHealthCheck(check *ServiceHealthCheckConfig) error {
+	if check.Path != "" && !strings.HasPrefix(check.Path, "/") {
+		return errors.New("health check path must start with /")
+	}
+	if check.InfoPath != "" && !strings.HasPrefix(check.InfoPath, "/") {
+		return errors.New("health check info_path must start with /")
+	}
+	if check.Interval < 0 {
+		return errors.New("health check interval cannot be negative")
+	}
+	if check.Timeout < 0 {
+		return errors.New("health check timeout cannot be negative")
+	}
+	for _, status := range check.ExpectedStatuses {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("invalid health check expected status: %d", status)
+		}
+	}
+	switch check.Type {
+	case "", HealthCheckHTTP:
+		if check.GRPCService != "" {
+			return errors.New("health check grpc_service requires type grpc")
+		}
+	case HealthCheckGRPC:
+		if check.Path != "" || len(check.ExpectedStatuses) > 0 {
+			return errors.New("grpc health checks cannot set path or expected_statuses")
+		}
+		if check.InfoPath != "" {
+			return errors.New("grpc health checks cannot set info_path")
+		}
+	default:
+		return fmt.Errorf("unsupported health check type: %s", check.Type)
+	}
+
+	return nil
+}
+
+// validateExperiment Validate A/B experiment config
+func validateExperiment(exp *ExperimentConfig) error {
+	if exp.Key != "" {
+		source, name, _ := strings.Cut(exp.Key, ":")
+		if (source != "cookie" && source != "header") || name == "" {
+			return fmt.Errorf("unsupported experiment key: %s", exp.Key)
+		}
+	}
+	if exp.CookieTTL < 0 {
+		return errors.New("experiment cookie_ttl cannot be negative")
+	}
+	if len(exp.Variants) == 0 {
+		return errors.New("experiment requires at least one variant")
+	}
+	names := make(map[string]bool, len(exp.Variants))
+	for _, variant := range exp.Variants {
+		if variant.Name == "" {
+			return errors.New("experiment variant name cannot be empty")
+		}
+		if names[variant.Name] {
+			return fmt.Errorf("duplicate experiment variant: %s", variant.Name)
+		}
+		names[variant.Name] = true
+		if variant.Service == "" {
+			return fmt.Errorf("experiment variant %s service cannot be empty", variant.Name)
+		}
+		if variant.Weight <= 0 {
+			return fmt.Errorf("experiment variant %s weight must be positive", variant.Name)
+		}
+	}
+
+	return nil
+}
+
+// validateWarmup Validate warm-up requests config
+func validateWarmup(warmup *WarmupConfig) error {
+	if len(warmup.Paths) == 0 {
+		return errors.New("warmup requires at least one path")
+	}
+	for _, path := range warmup.Paths {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("warmup path must start with /: %s", path)
+		}
+	}
+	if warmup.Requests < 0 {
+		return errors.New("warmup requests cannot be negative")
+	}
+	if warmup.Concurrency < 0 {
+		return errors.New("warmup concurrency cannot be negative")
+	}
+	if warmup.Timeout < 0 {
+		return errors.New("warmup timeout cannot be negative")
+	}
+
+	return nil
+}
+
+// validateMaintenanceWindow Validate maintenance window config
+func validateMaintenanceWindow(window MaintenanceWindow) error {
+	if window.Cron != "" {
+		if _, err := schedule.ParseCron(window.Cron); err != nil {
+			return fmt.Errorf("maintenance window: %w", err)
+		}
+		if window.Duration <= 0 {
+			return errors.New("maintenance window duration must be positive")
+		}
+		if window.Timezone != "" {
+			if _, err := time.LoadLocation(window.Timezone); err != nil {
+				return fmt.Errorf("maintenance window: invalid timezone: %s", window.Timezone)
+			}
+		}
+		return nil
+	}
+
+	if window.Start.IsZero() || window.End.IsZero() {
+		return errors.New("maintenance window requires either cron or start and end")
+	}
+	if !window.End.After(window.Start) {
+		return errors.New("maintenance window end must be after start")
+	}
+
+	return nil
+}
+
+// validateAdmin Validate admin API config
+func validateAdmin(admin AdminConfig) error {
+	if admin.Enabled && admin.ListenAddr == "" {
+		return errors.New("admin listen address cannot be empty")
+	}
+	if admin.TLS != nil {
+		if admin.TLS.Certificate.CertFile == "" || admin.TLS.Certificate.KeyFile == "" {
+			return errors.New("admin tls certificate requires cert_file and key_file")
+		}
+		if admin.TLS.ClientAuth != nil {
+			if err := validateClientAuth(admin.TLS.ClientAuth); err != nil {
+				return fmt.Errorf("admin: %w", err)
+			}
+		}
+	}
+	if admin.Auth != nil {
+		if err := validateAdminAuth(admin); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateAdminAuth Validate admin API credentials
+func validateAdminAuth(admin AdminConfig) error {
+	auth := admin.Auth
+	if len(auth.Tokens) == 0 && len(auth.Users) == 0 && len(auth.ClientCerts) == 0 {
+		return errors.New("admin auth requires at least one token, user or client certificate")
+	}
+	for _, token := range auth.Tokens {
+		if token.Token == "" {
+			return errors.New("admin auth token cannot be empty")
+		}
+		if err := validateAdminRole(token.Role); err != nil {
+			return err
+		}
+	}
+	for _, user := range auth.Users {
+		if user.Username == "" || user.Password == "" {
+			return errors.New("admin auth user requires a username and password")
+		}
+		if err := validateAdminRole(user.Role); err != nil {
+			return err
+		}
+	}
+	if len(auth.ClientCerts) > 0 && (admin.TLS == nil || admin.TLS.ClientAuth == nil) {
+		return errors.New("admin auth client_certs require admin tls client_auth")
+	}
+	for _, cert := range auth.ClientCerts {
+		if cert.CommonName == "" {
+			return errors.New("admin auth client certificate common_name cannot be empty")
+		}
+		if err := validateAdminRole(cert.Role); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateAdminRole(role string) error {
+	switch role {
+	case "", AdminRoleRead, AdminRoleWrite:
+		return nil
+	default:
+		return fmt.Errorf("unsupported admin role: %s", role)
+	}
+}
+
+// validateRateLimitStore Validate rate limit storage config
+func validateRateLimitStore(store RateLimitStoreConfig) error {
+	switch store.Store {
+	case "", "local":
+		return nil
+	case "redis":
+		if store.Redis.Address == "" {
+			return errors.New("redis address cannot be empty")
+		}
+		if store.Redis.Timeout < 0 {
+			return errors.New("redis timeout cannot be negative")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported rate limit store: %s", store.Store)
+	}
+}
+
+// validateSecrets Validate secret providers config
+func validateSecrets(secrets SecretsConfig) error {
+	if secrets.RefreshInterval < 0 {
+		return errors.New("secrets refresh interval cannot be negative")
+	}
+	if vault := secrets.Vault; vault != nil {
+		if vault.Address != "" {
+			if u, err := url.Parse(vault.Address); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("invalid vault address: %s", vault.Address)
+			}
+		}
+		if IsSecretRef(vault.Token) {
+			return errors.New("vault token cannot be a secret reference")
+		}
+		if vault.KVVersion != 0 && vault.KVVersion != 1 && vault.KVVersion != 2 {
+			return fmt.Errorf("unsupported vault kv_version: %d", vault.KVVersion)
+		}
+		if vault.Timeout < 0 {
+			return errors.New("vault timeout cannot be negative")
+		}
+	}
+
+	return nil
+}
+
+// validateTLS Validate TLS listener config
+func validateTLS(tls TLSConfig) error {
+	if !tls.Enabled {
+		return nil
+	}
+	if tls.ListenAddr == "" {
+		return errors.New("tls listen address cannot be empty")
+	}
+	if len(tls.Certificates) == 0 {
+		return errors.New("tls requires at least one certificate")
+	}
+	for _, cert := range tls.Certificates {
+		if cert.CertFile == "" || cert.KeyFile == "" {
+			return errors.New("tls certificate requires cert_file and key_file")
+		}
+	}
+	if tls.ReloadInterval < 0 {
+		return errors.New("tls reload interval cannot be negative")
+	}
+	if tls.ClientAuth != nil {
+		if err := validateClientAuth(tls.ClientAuth); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateClientAuth Validate TLS client certificate verification config
+func validateClientAuth(auth *ClientAuthConfig) error {
+	switch auth.Mode {
+	case "", "optional", "require":
+	default:
+		return fmt.Errorf("unsupported tls client_auth mode: %s", auth.Mode)
+	}
+	if auth.CAFile == "" {
+		return errors.New("tls client_auth requires a ca_file")
+	}
+	for _, crl := range auth.CRLFiles {
+		if crl == "" {
+			return errors.New("tls client_auth crl_files cannot contain empty paths")
+		}
+	}
+
+	return nil
+}
+
+// validateStatsD Validate statsd metrics config
+func validateStatsD(statsd StatsDConfig) error {
+	if !statsd.Enabled {
+		return nil
+	}
+	if statsd.Address != "" {
+		if _, _, err := net.SplitHostPort(statsd.Address); err != nil {
+			return fmt.Errorf("invalid statsd address: %w", err)
+		}
+	}
+	if statsd.FlushInterval < 0 {
+		return errors.New("statsd flush interval cannot be negative")
+	}
+	if len(statsd.Tags) > 0 && !statsd.Datadog {
+		return errors.New("statsd tags require the datadog format")
+	}
+
+	return nil
+}
+
+// validateSharedHealth Validate shared health state config
+func validateSharedHealth(shared SharedHealthConfig) error {
+	switch shared.Store {
+	case "":
+		return nil
+	case "redis":
+		if shared.Redis.Address == "" {
+			return errors.New("shared health redis address cannot be empty")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported shared health store: %s", shared.Store)
+	}
+}
+
+// validateLeaderElection Validate leader election config
+func validateLeaderElection(election LeaderElectionConfig) error {
+	if election.TTL < 0 {
+		return errors.New("leader election ttl cannot be negative")
+	}
+	switch election.Store {
+	case "":
+		return nil
+	case "consul":
+		if u, err := url.Parse(election.Consul.Address); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid leader election consul address: %q", election.Consul.Address)
+		}
+		// Consul rejects session TTLs below 10s
+		if election.TTL > 0 && election.TTL < 10*time.Second {
+			return errors.New("leader election ttl must be at least 10s with consul")
+		}
+		return nil
+	case "redis":
+		if election.Redis.Address == "" {
+			return errors.New("leader election redis address cannot be empty")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported leader election store: %s", election.Store)
+	}
+}
+
+// validatePassthrough Validate TLS passthrough listener config
+func validatePassthrough(passthrough PassthroughConfig, c *Config) error {
+	if passthrough.ListenAddr == "" {
+		if len(passthrough.Routes) > 0 || passthrough.DefaultService != "" {
+			return errors.New("passthrough routes require a listen_addr")
+		}
+		return nil
+	}
+	if passthrough.ListenAddr == c.ListenAddr || (c.TLS.Enabled && passthrough.ListenAddr == c.TLS.ListenAddr) {
+		return fmt.Errorf("passthrough listen_addr %s is used by another listener", passthrough.ListenAddr)
+	}
+	if passthrough.HelloTimeout < 0 || passthrough.DialTimeout < 0 {
+		return errors.New("passthrough timeouts cannot be negative")
+	}
+	if len(passthrough.Routes) == 0 && passthrough.DefaultService == "" {
+		return errors.New("passthrough requires routes or a default_service")
+	}
+	for i, route := range passthrough.Routes {
+		if len(route.SNI) == 0 {
+			return fmt.Errorf("passthrough route %d: sni cannot be empty", i)
+		}
+		for _, name := range route.SNI {
+			if name == "" || strings.Contains(strings.TrimPrefix(name, "*."), "*") {
+				return fmt.Errorf("passthrough route %d: invalid sni: %q", i, name)
+			}
+		}
+		if _, ok := c.Services[route.Service]; !ok {
+			return &ServiceNotFoundError{Service: route.Service, message: fmt.Sprintf("passthrough route %d: service %q not found", i, route.Service)}
+		}
+	}
+	if _, ok := c.Services[passthrough.DefaultService]; passthrough.DefaultService != "" && !ok {
+		return &ServiceNotFoundError{Service: passthrough.DefaultService, message: fmt.Sprintf("passthrough default_service %q not found", passthrough.DefaultService)}
+	}
+
+	return nil
+}
+
+// validateHealthPush Validate health push reporter config
+func validateHealthPush(push HealthPushConfig) error {
+	if push.URL == "" {
+		return nil
+	}
+	if u, err := url.Parse(push.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid health push url: %s", push.URL)
+	}
+	switch push.Format {
+	case "", "json", "slack":
+	default:
+		return fmt.Errorf("unsupported health push format: %s", push.Format)
+	}
+	if push.MinInterval < 0 || push.Timeout < 0 {
+		return errors.New("health push min_interval and timeout cannot be negative")
+	}
+
+	return nil
+}
+
+// validateHealthCheck Validate health check config
+func validateHealthCheck(interval, timeout time.Duration) error {
+	if interval <= 0 {
+		return errors.New("health check interval must be positive")
+	}
+	if timeout <= 0 {
+		return errors.New("health check timeout must be positive")
+	}
+	if timeout >= interval {
+		return errors.New("health check timeout must be less than interval")
+	}
+
+	return nil
+}
+
+// ValidateRoute Validate a single route config, e.g. one registered at
+// runtime. Its errors name the route already
+func ValidateRoute(route *RouteConfig) error {
+	return validateRoute(route)
+}
+
+// ValidateRouteOverlap rejects duplicate route names and identical match
+// conditions sending requests to different services, only the first of such
+// routes would ever match. Identical routes with the same target are a warning
+func ValidateRouteOverlap(routes []*RouteConfig) error {
+	names := make(map[string]bool, len(routes))
+	for i, route := range routes {
+		if route.Name != "" && names[route.Name] {
+			return fmt.Errorf("duplicate route name: %s", route.Name)
+		}
+		names[route.Name] = true
+
+		for _, previous := range routes[:i] {
+			if !reflect.DeepEqual(previous.Match, route.Match) {
+				continue
+			}
+			if previous.Service != route.Service || !reflect.DeepEqual(previous.Split, route.Split) || !reflect.DeepEqual(previous.Experiment, route.Experiment) {
+				return fmt.Errorf("route %s: same match conditions as route %s with a different service", route.Name, previous.Name)
+			}
+			lg.GetInstance().Warn("Route %s has the same match conditions and service as route %s and never matches", route.Name, previous.Name)
+		}
+	}
+
+	return nil
+}
+
+// ValidateRouteServices checks that the service, split, experiment and
+// fallback services of a route are defined
+func ValidateRouteServices(route *RouteConfig, services map[string]*ServiceConfig) error {
+	if len(route.Split) == 0 && route.Experiment == nil {
+		if _, ok := services[route.Service]; !ok {
+			return fmt.Errorf("route %s: %w", route.Name, &ServiceNotFoundError{Service: route.Service})
+		}
+	}
+	if route.FallbackService != "" {
+		if _, ok := services[route.FallbackService]; !ok {
+			return fmt.Errorf("route %s: fallback %w", route.Name, &ServiceNotFoundError{Service: route.FallbackService})
+		}
+	}
+	for _, split := range route.Split {
+		svc, ok := services[split.Service]
+		if !ok {
+			return fmt.Errorf("route %s: split %w", route.Name, &ServiceNotFoundError{Service: split.Service})
+		}
+		if err := validateSelectorMatches(svc, split.Selector); err != nil {
+			return fmt.Errorf("route %s: split %w", route.Name, err)
+		}
+	}
+	if route.Experiment != nil {
+		for _, variant := range route.Experiment.Variants {
+			svc, ok := services[variant.Service]
+			if !ok {
+				return fmt.Errorf("route %s: experiment variant %s %w", route.Name, variant.Name, &ServiceNotFoundError{Service: variant.Service})
+			}
+			if err := validateSelectorMatches(svc, variant.Selector); err != nil {
+				return fmt.Errorf("route %s: experiment variant %s %w", route.Name, variant.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateSelectorMatches checks that a selector matches a server of a service
+func validateSelectorMatches(svc *ServiceConfig, rawSelector string) error {
+	if rawSelector == "" {
+		return nil
+	}
+	selector, err := ParseSelector(rawSelector)
+	if err != nil {
+		return err
+	}
+	// Discovered and registered servers are only known at runtime
+	if svc.Discovery != nil || svc.Registration != nil {
+		return nil
+	}
+	for _, server := range svc.Servers {
+		if MatchLabels(svc.ServerLabels(server.Address), selector) {
+			return nil
+		}
+	}
+	return fmt.Errorf("selector %q matches no server of service %s", rawSelector, svc.Name)
+}
+
+// validateRoute Validate route config
+func validateRoute(route *RouteConfig) error {
+	if route.Name == "" {
+		return errors.New("route name cannot be empty")
+	}
+	if route.Match.Path == "" && route.Match.Method == "" && route.Match.Host == "" && len(route.Match.Headers) == 0 && route.Match.Body == nil &&
+		len(route.Match.Languages) == 0 && len(route.Match.Countries) == 0 && len(route.Match.Continents) == 0 &&
+		route.Match.Time == nil && route.Match.TLS == nil && len(route.Match.Tags) == 0 {
+		return fmt.Errorf("route %s: match condition cannot be empty", route.Name)
+	}
+	switch route.Priority {
+	case "", PriorityCritical, PriorityDefault, PriorityBestEffort:
+	default:
+		return fmt.Errorf("route %s: unsupported priority: %s", route.Name, route.Priority)
+	}
+	if route.Experiment != nil {
+		if route.Service != "" || len(route.Split) > 0 {
+			return fmt.Errorf("route %s: experiment cannot be combined with service or split", route.Name)
+		}
+		if err := validateExperiment(route.Experiment); err != nil {
+			return fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	} else if route.Service == "" && len(route.Split) == 0 {
+		return fmt.Errorf("route %s: must specify either service or split", route.Name)
+	}
+	if route.FallbackService != "" && route.FallbackService == route.Service {
+		return fmt.Errorf("route %s: fallback service cannot be the route service", route.Name)
+	}
+	if len(route.FallbackStatusCodes) > 0 && route.FallbackService == "" {
+		return fmt.Errorf("route %s: fallback_status_codes require a fallback_service", route.Name)
+	}
+	for _, code := range route.FallbackStatusCodes {
+		if code < 400 || code > 599 {
+			return fmt.Errorf("route %s: invalid fallback status code: %d", route.Name, code)
+		}
+	}
+	if len(route.Split) > 0 {
+		totalWeight := 0
+		for _, split := range route.Split {
+			if split.Service == "" {
+				return fmt.Errorf("route %s: split service cannot be empty", route.Name)
+			}
+			if split.Weight <= 0 {
+				return fmt.Errorf("route %s: split weight must be positive", route.Name)
+			}
+			totalWeight += split.Weight
+		}
+		if totalWeight != 100 {
+			return fmt.Errorf("route %s: split weights must sum to 100", route.Name)
+		}
+	}
+	if err := validateLocaleMatch(route.Match); err != nil {
+		return fmt.Errorf("route %s: %w", route.Name, err)
+	}
+	if route.Match.Time != nil {
+		if err := validateTimeMatch(route.Match.Time); err != nil {
+			return fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	}
+	if route.Match.TLS != nil {
+		if err := validateTLSMatch(route.Match.TLS); err != nil {
+			return fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	}
+	if route.Match.Body != nil {
+		if err := validateBodyMatch(route.Match.Body); err != nil {
+			return fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	}
+	if route.Hedge != nil {
+		if err := validateHedge(route.Hedge); err != nil {
+			return fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	}
+	if route.Expect != nil {
+		if err := validateExpect(route.Expect); err != nil {
+			return fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	}
+	if route.LocationRewrite != nil {
+		if err := validateLocationRewrite(route.LocationRewrite); err != nil {
+			return fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	}
+	if route.SubFilter != nil {
+		if err := validateSubFilter(route.SubFilter); err != nil {
+			return fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	}
+	for _, page := range route.ErrorPages {
+		if err := validateErrorPage(page); err != nil {
+			return fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	}
+	if route.UpstreamCompression != nil {
+		if err := validateUpstreamCompression(route.UpstreamCompression); err != nil {
+			return fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	}
+	if route.Forwarding != nil {
+		switch route.Forwarding.ExpectContinue {
+		case "", ExpectContinueForward, ExpectContinueProxy:
+		default:
+			return fmt.Errorf("route %s: unsupported expect_continue: %s", route.Name, route.Forwarding.ExpectContinue)
+		}
+	}
+	if route.Buffering != nil {
+		if err := validateBuffering(route.Buffering); err != nil {
+			return fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	}
+	if route.RateLimit != nil {
+		if err := validateRateLimit(route.RateLimit); err != nil {
+			return fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	}
+	for _, contentType := range route.AllowedContentTypes {
+		if !strings.Contains(contentType, "/") {
+			return fmt.Errorf("route %s: invalid allowed content type: %q", route.Name, contentType)
+		}
+	}
+	if route.Sanitize != nil {
+		if err := validateSanitize(route.Sanitize); err != nil {
+			return fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	}
+	if route.Bandwidth != nil {
+		if err := validateBandwidth(route.Bandwidth); err != nil {
+			return fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	}
+	if route.OIDC != nil {
+		if err := validateOIDC(route.OIDC); err != nil {
+			return fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	}
+	if route.Fault != nil {
+		if err := ValidateFault(route.Fault); err != nil {
+			return fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	}
+	if route.Cache != nil && route.Cache.TTL < 0 {
+		return fmt.Errorf("route %s: cache ttl cannot be negative", route.Name)
+	}
+	if route.SLO != nil {
+		if err := validateSLO(route.SLO); err != nil {
+			return fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	}
+	if route.Deprecation != nil {
+		if err := validateDeprecation(route.Deprecation); err != nil {
+			return fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	}
+	if route.ClientCert != nil {
+		for _, name := range route.ClientCert.CommonNames {
+			if name == "" {
+				return fmt.Errorf("route %s: client_cert common_names cannot be empty", route.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateFault Validate a fault injection config, e.g. one set at runtime
+func ValidateFault(fault *FaultConfig) error {
+	if fault.Delay == nil && fault.Abort == nil && fault.Corrupt == nil {
+		return errors.New("fault requires delay, abort or corrupt")
+	}
+	if fault.Delay != nil {
+		if fault.Delay.Duration <= 0 {
+			return errors.New("fault delay duration must be positive")
+		}
+		if fault.Delay.Jitter < 0 {
+			return errors.New("fault delay jitter cannot be negative")
+		}
+		if err := validateFaultPercentage("delay", fault.Delay.Percentage); err != nil {
+			return err
+		}
+	}
+	if fault.Abort != nil {
+		if fault.Abort.Status < 200 || fault.Abort.Status > 599 {
+			return fmt.Errorf("invalid fault abort status: %d", fault.Abort.Status)
+		}
+		if err := validateFaultPercentage("abort", fault.Abort.Percentage); err != nil {
+			return err
+		}
+	}
+	if fault.Corrupt != nil {
+		switch fault.Corrupt.Mode {
+		case "", FaultCorruptGarble, FaultCorruptTruncate:
+		default:
+			return fmt.Errorf("unsupported fault corrupt mode: %s", fault.Corrupt.Mode)
+		}
+		if err := validateFaultPercentage("corrupt", fault.Corrupt.Percentage); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateFaultPercentage(fault string, percentage float64) error {
+	if percentage < 0 || percentage > 100 {
+		return fmt.Errorf("fault %s percentage must be between 0 and 100", fault)
+	}
+	return nil
+}
+
+// validateOIDC Validate OpenID Connect login config, secrets must resolve
+func validateOIDC(oidc *OIDCConfig) error {
+	if u, err := url.Parse(oidc.Issuer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid oidc issuer: %s", oidc.Issuer)
+	}
+	if oidc.ClientID == "" {
+		return errors.New("oidc client_id cannot be empty")
+	}
+	if _, err := ResolveCredential(oidc.ClientSecret); err != nil {
+		return fmt.Errorf("oidc client secret: %w", err)
+	}
+	secret, err := ResolveCredential(oidc.CookieSecret)
+	if err != nil {
+		return fmt.Errorf("oidc cookie secret: %w", err)
+	}
+	if len(secret) < 16 {
+		return errors.New("oidc cookie_secret must be at least 16 bytes")
+	}
+	if oidc.RedirectURL != "" {
+		u, err := url.Parse(oidc.RedirectURL)
+		if err != nil || !strings.HasPrefix(u.Path, "/") || (u.IsAbs() && u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid oidc redirect_url: %s", oidc.RedirectURL)
+		}
+	}
+	if oidc.LogoutPath != "" && !strings.HasPrefix(oidc.LogoutPath, "/") {
+		return errors.New("oidc logout_path must start with /")
+	}
+	if oidc.SessionTTL < 0 {
+		return errors.New("oidc session_ttl cannot be negative")
+	}
+	for header, claim := range oidc.ClaimHeaders {
+		if header == "" || claim == "" {
+			return errors.New("oidc claim_headers cannot contain empty headers or claims")
+		}
+	}
+
+	return nil
+}
+
+// validateRateLimit Validate route rate limit config
+func validateRateLimit(rateLimit *RateLimitConfig) error {
+	if rateLimit.Requests <= 0 {
+		return errors.New("rate limit requests must be positive")
+	}
+	if rateLimit.Window <= 0 {
+		return errors.New("rate limit window must be positive")
+	}
+	switch {
+	case rateLimit.Key == "", rateLimit.Key == "ip", rateLimit.Key == "route":
+	case strings.HasPrefix(rateLimit.Key, "header:") && len(rateLimit.Key) > len("header:"):
+	case strings.HasPrefix(rateLimit.Key, "tag:") && len(rateLimit.Key) > len("tag:"):
+	default:
+		return fmt.Errorf("unsupported rate limit key: %s", rateLimit.Key)
+	}
+	if tarpit := rateLimit.Tarpit; tarpit != nil {
+		switch {
+		case tarpit.Factor != 0 && tarpit.Factor < 1:
+			return errors.New("rate limit tarpit factor must be at least 1")
+		case tarpit.Delay < 0:
+			return errors.New("rate limit tarpit delay cannot be negative")
+		case tarpit.MaxConcurrent < 0:
+			return errors.New("rate limit tarpit max_concurrent cannot be negative")
+		}
+	}
+
+	return nil
+}
+
+// validateSanitize Validate request header sanitization config
+func validateSanitize(sanitize *SanitizeConfig) error {
+	switch sanitize.Mode {
+	case "", SanitizeReject, SanitizeStrip:
+	default:
+		return fmt.Errorf("unsupported sanitize mode: %s", sanitize.Mode)
+	}
+	if sanitize.MaxHeaderSize < 0 {
+		return errors.New("sanitize max_header_size cannot be negative")
+	}
+
+	return nil
+}
+
+// validateBandwidth Validate route bandwidth limit config
+func validateBandwidth(bandwidth *BandwidthConfig) error {
+	if bandwidth.Response < 0 || bandwidth.Request < 0 {
+		return errors.New("bandwidth rates cannot be negative")
+	}
+	if bandwidth.Response == 0 && bandwidth.Request == 0 {
+		return errors.New("bandwidth requires a response or request rate")
+	}
+	if bandwidth.Burst < 0 {
+		return errors.New("bandwidth burst cannot be negative")
+	}
+	switch {
+	case bandwidth.Key == "", bandwidth.Key == "request", bandwidth.Key == "ip", bandwidth.Key == "route":
+	case strings.HasPrefix(bandwidth.Key, "header:") && len(bandwidth.Key) > len("header:"):
+	default:
+		return fmt.Errorf("unsupported bandwidth key: %s", bandwidth.Key)
+	}
+
+	return nil
+}
+
+// validateSLO Validate the latency objective of a route
+func validateSLO(slo *SLOConfig) error {
+	if slo.Objective <= 0 || slo.Objective >= 100 {
+		return errors.New("slo objective must be between 0 and 100")
+	}
+	if slo.Latency <= 0 {
+		return errors.New("slo latency must be positive")
+	}
+	if slo.Window < 0 {
+		return errors.New("slo window cannot be negative")
+	}
+	if slo.Window > 0 && slo.Window < time.Minute {
+		return errors.New("slo window must be at least 1m")
+	}
+	if slo.AlertBurnRate < 0 {
+		return errors.New("slo alert_burn_rate cannot be negative")
+	}
+
+	return nil
+}
+
+// validateDeprecation Validate route deprecation config
+func validateDeprecation(deprecation *DeprecationConfig) error {
+	if !deprecation.Since.IsZero() && !deprecation.Sunset.IsZero() && deprecation.Sunset.Before(deprecation.Since) {
+		return errors.New("deprecation sunset cannot be before since")
+	}
+	if deprecation.Gone && deprecation.Sunset.IsZero() {
+		return errors.New("deprecation gone requires a sunset")
+	}
+	if deprecation.Link != "" {
+		if _, err := url.Parse(deprecation.Link); err != nil || strings.ContainsAny(deprecation.Link, "<> ") {
+			return fmt.Errorf("invalid deprecation link: %q", deprecation.Link)
+		}
+	}
+	switch {
+	case deprecation.LogKey == "", deprecation.LogKey == "ip":
+	case strings.HasPrefix(deprecation.LogKey, "header:") && len(deprecation.LogKey) > len("header:"):
+	case strings.HasPrefix(deprecation.LogKey, "tag:") && len(deprecation.LogKey) > len("tag:"):
+	default:
+		return fmt.Errorf("unsupported deprecation log_key: %s", deprecation.LogKey)
+	}
+	if deprecation.LogInterval < 0 {
+		return errors.New("deprecation log_interval cannot be negative")
+	}
+
+	return nil
+}
+
+// validateTimeMatch Validate time window match config
+func validateTimeMatch(match *TimeMatch) error {
+	if match.Cron != "" {
+		if len(match.Weekdays) > 0 || match.Hours != "" {
+			return errors.New("time match cannot combine cron with weekdays or hours")
+		}
+		if _, err := schedule.ParseCron(match.Cron); err != nil {
+			return fmt.Errorf("time match: %w", err)
+		}
+		if match.Duration <= 0 {
+			return errors.New("time match duration must be positive")
+		}
+	} else {
+		if len(match.Weekdays) == 0 && match.Hours == "" {
+			return errors.New("time match requires cron, weekdays or hours")
+		}
+		if _, err := schedule.ParseWindow(match.Weekdays, match.Hours); err != nil {
+			return fmt.Errorf("time match: %w", err)
+		}
+	}
+	if match.Timezone != "" {
+		if _, err := time.LoadLocation(match.Timezone); err != nil {
+			return fmt.Errorf("time match: invalid timezone: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// tagNamePattern restricts tag names to what metric backends accept as labels
+var tagNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ParseTrustedProxy parses a trusted proxy of the real IP config, a CIDR or
+// a single address
+func ParseTrustedProxy(entry string) (netip.Prefix, error) {
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// validateRealIP Validate the trusted proxies and headers of the real IP
+func validateRealIP(cfg RealIPConfig) error {
+	for _, entry := range cfg.TrustedProxies {
+		if _, err := ParseTrustedProxy(entry); err != nil {
+			return fmt.Errorf("invalid real_ip trusted proxy: %q", entry)
+		}
+	}
+	if len(cfg.Headers) > 0 && len(cfg.TrustedProxies) == 0 {
+		return errors.New("real_ip headers require trusted_proxies")
+	}
+	seen := make(map[string]bool, len(cfg.Headers))
+	for _, header := range cfg.Headers {
+		name := http.CanonicalHeaderKey(header)
+		switch name {
+		case http.CanonicalHeaderKey(RealIPHeaderForwarded), http.CanonicalHeaderKey(RealIPHeaderRealIP),
+			http.CanonicalHeaderKey(RealIPHeaderForwardedFor), http.CanonicalHeaderKey(RealIPHeaderCloudflare):
+		default:
+			return fmt.Errorf("unsupported real_ip header: %q", header)
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate real_ip header: %q", header)
+		}
+		seen[name] = true
+	}
+
+	return nil
+}
+
+// validateClassification Validate request tagging rules
+func validateClassification(rules []*ClassificationRule) error {
+	for i, rule := range rules {
+		name := rule.Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		if len(rule.Tags) == 0 {
+			return fmt.Errorf("classification rule %s: tags cannot be empty", name)
+		}
+		for tag := range rule.Tags {
+			if !tagNamePattern.MatchString(tag) {
+				return fmt.Errorf("classification rule %s: invalid tag name: %q", name, tag)
+			}
+		}
+		if rule.PathPrefix != "" && !strings.HasPrefix(rule.PathPrefix, "/") {
+			return fmt.Errorf("classification rule %s: path_prefix must start with /", name)
+		}
+		for header, pattern := range rule.Headers {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("classification rule %s: invalid header %s pattern: %w", name, header, err)
+			}
+		}
+		if _, err := regexp.Compile(rule.UserAgent); err != nil {
+			return fmt.Errorf("classification rule %s: invalid user_agent pattern: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// classificationSets reports whether a rule sets the tag name
+func classificationSets(rules []*ClassificationRule, name string) bool {
+	return slices.ContainsFunc(rules, func(rule *ClassificationRule) bool {
+		_, ok := rule.Tags[name]
+		return ok
+	})
+}
+
+// validateTLSMatch Validate TLS connection match config
+func validateTLSMatch(match *TLSMatch) error {
+	if len(match.SNI) == 0 && len(match.ALPN) == 0 && len(match.ClientCommonNames) == 0 && len(match.ClientOrganizations) == 0 {
+		return errors.New("tls match requires sni, alpn, client_common_names or client_organizations")
+	}
+	for _, name := range match.SNI {
+		if name == "" || strings.Contains(strings.TrimPrefix(name, "*."), "*") {
+			return fmt.Errorf("invalid tls match sni: %q", name)
+		}
+	}
+	for _, protocol := range match.ALPN {
+		if protocol == "" {
+			return errors.New("tls match alpn cannot be empty")
+		}
+	}
+
+	return nil
+}
+
+// continentCodes are the continent codes used by GeoIP databases
+var continentCodes = map[string]bool{"AF": true, "AN": true, "AS": true, "EU": true, "NA": true, "OC": true, "SA": true}
+
+// validateLocaleMatch Validate language, country and continent match config
+func validateLocaleMatch(match RouteMatch) error {
+	for _, lang := range match.Languages {
+		if lang == "" || strings.ContainsAny(lang, " ,;*") {
+			return fmt.Errorf("invalid match language: %q", lang)
+		}
+	}
+	for _, country := range match.Countries {
+		if len(country) != 2 {
+			return fmt.Errorf("invalid match country: %q", country)
+		}
+	}
+	for _, continent := range match.Continents {
+		if !continentCodes[strings.ToUpper(continent)] {
+			return fmt.Errorf("invalid match continent: %q", continent)
+		}
+	}
+
+	return nil
+}
+
+// maxBodyMatchSize bounds the body prefix buffered for content-based routing
+const maxBodyMatchSize = 1 << 20
+
+// validateBodyMatch Validate body match config
+func validateBodyMatch(body *BodyMatch) error {
+	conditions := 0
+	for _, c := range []string{body.JSONField, body.SOAPAction, body.Pattern} {
+		if c != "" {
+			conditions++
+		}
+	}
+	if conditions != 1 {
+		return errors.New("body match requires exactly one of json_field, soap_action or pattern")
+	}
+	if body.Value != "" && body.JSONField == "" {
+		return errors.New("body match value requires json_field")
+	}
+	if body.MaxSize < 0 || body.MaxSize > maxBodyMatchSize {
+		return fmt.Errorf("body match max_size must be between 0 and %d", maxBodyMatchSize)
+	}
+	if body.Pattern != "" {
+		if _, err := regexp.Compile(body.Pattern); err != nil {
+			return fmt.Errorf("invalid body match pattern: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// validateHedge Validate hedge config
+func validateHedge(hedge *HedgeConfig) error {
+	if hedge.Delay <= 0 {
+		return errors.New("hedge delay must be positive")
+	}
+	if hedge.MaxHedges <= 0 {
+		return errors.New("hedge max_hedges must be positive")
+	}
+	if hedge.Budget < 0 || hedge.Budget > 100 {
+		return errors.New("hedge budget must be between 0 and 100")
 	}
 
 	return nil
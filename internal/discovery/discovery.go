@@ -0,0 +1,312 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+	"nexus/internal/route"
+)
+
+const (
+	defaultInterval = 30 * time.Second
+	defaultTimeout  = 5 * time.Second
+	maxDocumentSize = 1 << 20
+)
+
+// Instance is one server listed by a discovery URL
+type Instance struct {
+	Address string            `json:"address"`
+	Weight  int               `json:"weight,omitempty"` // Defaults to 1
+	Labels  map[string]string `json:"labels,omitempty"`
+}
+
+// Document is the body discovery URLs answer with
+type Document struct {
+	Instances []Instance `json:"instances"`
+}
+
+// Discovery polls the discovery URLs of services and adds the instances they
+// list, and the servers registered with Register, to the configured servers.
+// A failed poll keeps the last instances, so an unavailable discovery URL
+// never empties a service
+type Discovery struct {
+	router route.Router
+	client *http.Client
+
+	mu         sync.Mutex
+	pollers    map[string]*poller                  // service -> running poller
+	static     map[string]*config.ServiceConfig    // service -> config as loaded
+	applied    map[string]*config.ServiceConfig    // service -> config with the discovered servers
+	instances  map[string][]config.ServerConfig    // service -> last discovered servers
+	registered map[string]map[string]*registration // service -> address -> registration
+	handlers   []func()
+}
+
+type poller struct {
+	cfg  config.DiscoveryConfig
+	stop chan struct{}
+}
+
+// New creates a discovery of the router's services, started by Sync
+func New(router route.Router) *Discovery {
+	return &Discovery{
+		router:     router,
+		client:     &http.Client{},
+		pollers:    make(map[string]*poller),
+		static:     make(map[string]*config.ServiceConfig),
+		applied:    make(map[string]*config.ServiceConfig),
+		instances:  make(map[string][]config.ServerConfig),
+		registered: make(map[string]map[string]*registration),
+	}
+}
+
+// OnChange subscribes handler to changes of the discovered servers, e.g. to
+// health check them. Handlers run on the polling goroutine
+func (d *Discovery) OnChange(handler func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.handlers = append(d.handlers, handler)
+}
+
+// Sync starts, restarts or stops the pollers to match the service configs,
+// and adds the discovered servers back to services replaced by a reload
+func (d *Discovery) Sync() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	services := d.router.Services()
+	for name, p := range d.pollers {
+		if _, ok := services[name]; !ok {
+			close(p.stop)
+			delete(d.pollers, name)
+		}
+	}
+	for name := range d.static {
+		if _, ok := services[name]; !ok {
+			delete(d.static, name)
+			delete(d.applied, name)
+			delete(d.instances, name)
+			d.dropRegistrations(name)
+		}
+	}
+
+	for name, svc := range services {
+		cfg := svc.Config()
+		if cfg != d.applied[name] {
+			d.static[name] = cfg
+		}
+		static := d.static[name]
+		// Reloads replace the servers, the dynamic ones are added back
+		changed := cfg != d.applied[name] && (len(d.instances[name]) > 0 || len(d.registered[name]) > 0)
+
+		p, running := d.pollers[name]
+		switch {
+		case static.Discovery == nil && running:
+			close(p.stop)
+			delete(d.pollers, name)
+			delete(d.instances, name)
+			changed = true
+		case static.Discovery != nil && (!running || p.cfg != *static.Discovery):
+			if running {
+				close(p.stop)
+			}
+			p = &poller{cfg: *static.Discovery, stop: make(chan struct{})}
+			d.pollers[name] = p
+			go d.run(name, p)
+		}
+		if static.Registration == nil && len(d.registered[name]) > 0 {
+			d.dropRegistrations(name)
+			changed = true
+		}
+		if changed {
+			d.apply(name)
+		}
+	}
+}
+
+// Stop stops all pollers and registration expiries, the discovered and
+// registered servers stay in their services
+func (d *Discovery) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for name, p := range d.pollers {
+		close(p.stop)
+		delete(d.pollers, name)
+	}
+	for _, registrations := range d.registered {
+		for _, reg := range registrations {
+			reg.timer.Stop()
+		}
+	}
+}
+
+// Instances returns the last discovered servers of each service
+func (d *Discovery) Instances() map[string][]config.ServerConfig {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	instances := make(map[string][]config.ServerConfig, len(d.instances))
+	for name, servers := range d.instances {
+		instances[name] = servers
+	}
+	return instances
+}
+
+// run polls a discovery URL right away and then every interval until stopped
+func (d *Discovery) run(name string, p *poller) {
+	interval := p.cfg.Interval
+	if interval == 0 {
+		interval = defaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		d.poll(name, p)
+		select {
+		case <-ticker.C:
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+func (d *Discovery) poll(name string, p *poller) {
+	timeout := p.cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	servers, err := d.fetch(ctx, name, p.cfg.URL)
+	if err != nil {
+		lg.GetInstance().Warn("Discovery of service %s failed, keeping the last instances: %v", name, err)
+		return
+	}
+	d.update(name, p, servers)
+}
+
+// fetch reads the instance list from a discovery URL
+func (d *Discovery) fetch(ctx context.Context, name, url string) ([]config.ServerConfig, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var doc Document
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDocumentSize)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid discovery document: %w", err)
+	}
+	servers := make([]config.ServerConfig, 0, len(doc.Instances))
+	seen := make(map[string]bool, len(doc.Instances))
+	for _, instance := range doc.Instances {
+		if seen[instance.Address] {
+			continue
+		}
+		seen[instance.Address] = true
+		weight := instance.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		servers = append(servers, config.ServerConfig{Address: instance.Address, Weight: weight, Labels: instance.Labels})
+	}
+	if len(servers) > 0 {
+		svc, ok := d.router.Service(name)
+		if !ok {
+			return nil, &config.ServiceNotFoundError{Service: name}
+		}
+		if err := config.ValidateServers(servers, svc.Config().BalancerType); err != nil {
+			return nil, err
+		}
+	}
+
+	return servers, nil
+}
+
+// update applies the servers of a poll when they changed
+func (d *Discovery) update(name string, p *poller, servers []config.ServerConfig) {
+	d.mu.Lock()
+	previous, known := d.instances[name]
+	// Polls of a stopped poller may still complete
+	if d.pollers[name] != p || (known && reflect.DeepEqual(previous, servers)) {
+		d.mu.Unlock()
+		return
+	}
+	d.instances[name] = servers
+	changed := d.apply(name)
+	handlers := d.handlers
+	d.mu.Unlock()
+
+	if changed {
+		lg.GetInstance().Info("Discovered %d instances of service %s", len(servers), name)
+		for _, handler := range handlers {
+			handler()
+		}
+	}
+}
+
+// apply updates a service with its configured and discovered servers, the
+// caller must hold d.mu
+func (d *Discovery) apply(name string) bool {
+	svc, ok := d.router.Service(name)
+	if !ok {
+		return false
+	}
+	// A reload replaced the config since the last update
+	if cfg := svc.Config(); cfg != d.applied[name] {
+		d.static[name] = cfg
+	}
+	static := d.static[name]
+
+	merged := *static
+	merged.Servers = append([]config.ServerConfig(nil), static.Servers...)
+	for _, server := range d.instances[name] {
+		if !listsServer(merged.Servers, server.Address) {
+			merged.Servers = append(merged.Servers, server)
+		}
+	}
+	for _, server := range d.registeredServers(name) {
+		if !listsServer(merged.Servers, server.Address) {
+			merged.Servers = append(merged.Servers, server)
+		}
+	}
+	if err := svc.Update(&merged); err != nil {
+		lg.GetInstance().Error("Failed to update discovered servers of service %s: %v", name, err)
+		return false
+	}
+	d.applied[name] = &merged
+
+	return true
+}
+
+// listsServer reports whether servers has one with address, configured
+// servers take precedence over discovered and registered ones
+func listsServer(servers []config.ServerConfig, address string) bool {
+	for _, server := range servers {
+		if server.Address == address {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func addresses(router route.Router, name string) []string {
+	var list []string
+	for _, server := range router.Services()[name].Config().Servers {
+		list = append(list, server.Address)
+	}
+	return list
+}
+
+func TestDiscovery(t *testing.T) {
+	var mu sync.Mutex
+	status := http.StatusOK
+	doc := Document{Instances: []Instance{
+		{Address: "http://10.0.0.5:8080", Labels: map[string]string{"zone": "a"}},
+		{Address: "http://static:8080"},
+	}}
+	discoveryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(doc)
+	}))
+	defer discoveryServer.Close()
+
+	services := func() map[string]*config.ServiceConfig {
+		return map[string]*config.ServiceConfig{"api": {
+			Name:         "api",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: "http://static:8080"}},
+			Discovery:    &config.DiscoveryConfig{URL: discoveryServer.URL, Interval: 20 * time.Millisecond},
+		}}
+	}
+	router := route.NewRouter(nil, services())
+	d := New(router)
+	changes := make(chan struct{}, 16)
+	d.OnChange(func() { changes <- struct{}{} })
+	d.Sync()
+	defer d.Stop()
+
+	<-changes
+	assert.Equal(t, []string{"http://static:8080", "http://10.0.0.5:8080"}, addresses(router, "api"))
+	assert.Equal(t, map[string]string{"zone": "a"}, router.Services()["api"].Config().ServerLabels("http://10.0.0.5:8080"))
+
+	// A failed poll keeps the last instances
+	mu.Lock()
+	status = http.StatusInternalServerError
+	mu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, []string{"http://static:8080", "http://10.0.0.5:8080"}, addresses(router, "api"))
+
+	// A reload restores the configured servers, the discovered ones are added back
+	require.NoError(t, router.Update(nil, services()))
+	d.Sync()
+	assert.Equal(t, []string{"http://static:8080", "http://10.0.0.5:8080"}, addresses(router, "api"))
+
+	mu.Lock()
+	status = http.StatusOK
+	doc.Instances = []Instance{{Address: "http://10.0.0.6:8080"}}
+	mu.Unlock()
+	<-changes
+	assert.Equal(t, []string{"http://static:8080", "http://10.0.0.6:8080"}, addresses(router, "api"))
+	assert.Equal(t, map[string][]config.ServerConfig{"api": {{Address: "http://10.0.0.6:8080", Weight: 1}}}, d.Instances())
+}
+
+func TestDiscovery_InvalidDocument(t *testing.T) {
+	discoveryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"instances": [{"address": ""}]}`))
+	}))
+	defer discoveryServer.Close()
+
+	router := route.NewRouter(nil, map[string]*config.ServiceConfig{"api": {
+		Name:         "api",
+		BalancerType: "round_robin",
+		Discovery:    &config.DiscoveryConfig{URL: discoveryServer.URL},
+	}})
+	d := New(router)
+
+	_, err := d.fetch(context.Background(), "api", discoveryServer.URL)
+	assert.EqualError(t, err, "server address cannot be empty")
+	assert.Empty(t, d.Instances())
+}
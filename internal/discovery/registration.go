@@ -0,0 +1,220 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+)
+
+const (
+	defaultRegistrationTTL    = 30 * time.Second
+	defaultRegistrationMaxTTL = 5 * time.Minute
+)
+
+var (
+	// ErrServiceNotFound is matched by the errors of registrations with
+	// unknown services, it is config.ErrServiceNotFound
+	ErrServiceNotFound = config.ErrServiceNotFound
+	// ErrRegistrationDisabled is returned for services without a registration config
+	ErrRegistrationDisabled = errors.New("service doesn't accept registrations")
+	// ErrRegistrationNotFound is returned when deregistering an unknown server
+	ErrRegistrationNotFound = errors.New("registration not found")
+)
+
+// Registration is a server registered with a service
+type Registration struct {
+	Service string            `json:"service"`
+	Address string            `json:"address"`
+	Weight  int               `json:"weight"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	TTL     string            `json:"ttl"`
+	Expires time.Time         `json:"expires"`
+}
+
+type registration struct {
+	Registration
+	timer *time.Timer
+}
+
+// Register adds a server to a service until its TTL elapses, registering it
+// again renews the TTL and updates its weight and labels. A zero TTL uses
+// the TTL of the service
+func (d *Discovery) Register(service string, instance Instance, ttl time.Duration) (Registration, error) {
+	svc, ok := d.router.Service(service)
+	if !ok {
+		return Registration{}, &config.ServiceNotFoundError{Service: service}
+	}
+	cfg := svc.Config()
+	if cfg.Registration == nil {
+		return Registration{}, fmt.Errorf("%w: %s", ErrRegistrationDisabled, service)
+	}
+	if ttl == 0 {
+		ttl = cfg.Registration.TTL
+		if ttl == 0 {
+			ttl = defaultRegistrationTTL
+		}
+	}
+	maxTTL := cfg.Registration.MaxTTL
+	if maxTTL == 0 {
+		maxTTL = max(defaultRegistrationMaxTTL, cfg.Registration.TTL)
+	}
+	if ttl < 0 {
+		return Registration{}, errors.New("ttl cannot be negative")
+	}
+	if ttl > maxTTL {
+		return Registration{}, fmt.Errorf("ttl cannot exceed %s", maxTTL)
+	}
+	if instance.Weight == 0 {
+		instance.Weight = 1
+	}
+	server := config.ServerConfig{Address: instance.Address, Weight: instance.Weight, Labels: instance.Labels}
+	if err := config.ValidateServers([]config.ServerConfig{server}, cfg.BalancerType); err != nil {
+		return Registration{}, err
+	}
+
+	d.mu.Lock()
+	registrations, ok := d.registered[service]
+	if !ok {
+		registrations = make(map[string]*registration)
+		d.registered[service] = registrations
+	}
+	previous, renewed := registrations[instance.Address]
+	changed := !renewed || previous.Weight != instance.Weight || !equalLabels(previous.Labels, instance.Labels)
+	if renewed {
+		previous.timer.Stop()
+	}
+	// A renewal replaces the registration, so the timer of the previous one
+	// can't remove it after firing concurrently with the renewal
+	reg := &registration{Registration: Registration{
+		Service: service,
+		Address: instance.Address,
+		Weight:  instance.Weight,
+		Labels:  instance.Labels,
+		TTL:     ttl.String(),
+		Expires: time.Now().Add(ttl),
+	}}
+	registrations[instance.Address] = reg
+	reg.timer = time.AfterFunc(ttl, func() { d.expire(service, reg) })
+	if changed {
+		changed = d.apply(service)
+	}
+	result, handlers := reg.Registration, d.handlers
+	d.mu.Unlock()
+
+	if changed {
+		if !renewed {
+			lg.GetInstance().Info("[%s] Registered with service %s for %s", instance.Address, service, ttl)
+		}
+		for _, handler := range handlers {
+			handler()
+		}
+	}
+	return result, nil
+}
+
+// Deregister removes a registered server from a service
+func (d *Discovery) Deregister(service, address string) error {
+	d.mu.Lock()
+	reg, ok := d.registered[service][address]
+	if !ok {
+		d.mu.Unlock()
+		return fmt.Errorf("%w: %s in service %s", ErrRegistrationNotFound, address, service)
+	}
+	reg.timer.Stop()
+	changed := d.remove(service, address)
+	handlers := d.handlers
+	d.mu.Unlock()
+
+	lg.GetInstance().Info("[%s] Deregistered from service %s", address, service)
+	if changed {
+		for _, handler := range handlers {
+			handler()
+		}
+	}
+	return nil
+}
+
+// Registrations lists the registered servers by service and address
+func (d *Discovery) Registrations() []Registration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	list := make([]Registration, 0)
+	for _, registrations := range d.registered {
+		for _, reg := range registrations {
+			list = append(list, reg.Registration)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Service != list[j].Service {
+			return list[i].Service < list[j].Service
+		}
+		return list[i].Address < list[j].Address
+	})
+	return list
+}
+
+// expire removes a registration whose TTL elapsed without a renewal
+func (d *Discovery) expire(service string, reg *registration) {
+	d.mu.Lock()
+	// Renewed or removed meanwhile
+	if d.registered[service][reg.Address] != reg {
+		d.mu.Unlock()
+		return
+	}
+	changed := d.remove(service, reg.Address)
+	handlers := d.handlers
+	d.mu.Unlock()
+
+	lg.GetInstance().Warn("[%s] Registration with service %s expired", reg.Address, service)
+	if changed {
+		for _, handler := range handlers {
+			handler()
+		}
+	}
+}
+
+// remove drops a registration and updates the service, the caller must hold d.mu
+func (d *Discovery) remove(service, address string) bool {
+	delete(d.registered[service], address)
+	if len(d.registered[service]) == 0 {
+		delete(d.registered, service)
+	}
+	return d.apply(service)
+}
+
+// dropRegistrations forgets the registrations of a service, the caller must
+// hold d.mu
+func (d *Discovery) dropRegistrations(service string) {
+	for _, reg := range d.registered[service] {
+		reg.timer.Stop()
+	}
+	delete(d.registered, service)
+}
+
+// registeredServers returns the servers registered with a service ordered by
+// address, the caller must hold d.mu
+func (d *Discovery) registeredServers(service string) []config.ServerConfig {
+	servers := make([]config.ServerConfig, 0, len(d.registered[service]))
+	for _, reg := range d.registered[service] {
+		servers = append(servers, config.ServerConfig{Address: reg.Address, Weight: reg.Weight, Labels: reg.Labels})
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].Address < servers[j].Address })
+	return servers
+}
+
+func equalLabels(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscovery_Register(t *testing.T) {
+	services := func() map[string]*config.ServiceConfig {
+		return map[string]*config.ServiceConfig{"api": {
+			Name:         "api",
+			BalancerType: "weighted_round_robin",
+			Servers:      []config.ServerConfig{{Address: "http://static:8080", Weight: 1}},
+			Registration: &config.RegistrationConfig{TTL: time.Minute},
+		}}
+	}
+	router := route.NewRouter(nil, services())
+	d := New(router)
+	defer d.Stop()
+	changes := make(chan struct{}, 16)
+	d.OnChange(func() { changes <- struct{}{} })
+	d.Sync()
+
+	reg, err := d.Register("api", Instance{Address: "http://10.0.0.5:8080", Weight: 3}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "1m0s", reg.TTL)
+	<-changes
+	assert.Equal(t, []string{"http://static:8080", "http://10.0.0.5:8080"}, addresses(router, "api"))
+
+	// Renewals with the same settings leave the servers alone
+	_, err = d.Register("api", Instance{Address: "http://10.0.0.5:8080", Weight: 3}, 0)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	// Registrations survive reloads
+	require.NoError(t, router.Update(nil, services()))
+	d.Sync()
+	assert.Equal(t, []string{"http://static:8080", "http://10.0.0.5:8080"}, addresses(router, "api"))
+
+	// Registrations without renewal expire
+	_, err = d.Register("api", Instance{Address: "http://10.0.0.6:8080", Weight: 1}, 20*time.Millisecond)
+	require.NoError(t, err)
+	<-changes
+	<-changes
+	assert.Equal(t, []string{"http://static:8080", "http://10.0.0.5:8080"}, addresses(router, "api"))
+
+	require.NoError(t, d.Deregister("api", "http://10.0.0.5:8080"))
+	assert.Equal(t, []string{"http://static:8080"}, addresses(router, "api"))
+	assert.ErrorIs(t, d.Deregister("api", "http://10.0.0.5:8080"), ErrRegistrationNotFound)
+	assert.Empty(t, d.Registrations())
+}
+
+func TestDiscovery_RenewAtExpiry(t *testing.T) {
+	router := route.NewRouter(nil, map[string]*config.ServiceConfig{"api": {
+		Name:         "api",
+		BalancerType: "round_robin",
+		Registration: &config.RegistrationConfig{TTL: time.Minute},
+	}})
+	d := New(router)
+	defer d.Stop()
+	d.Sync()
+
+	_, err := d.Register("api", Instance{Address: "http://10.0.0.5:8080"}, 0)
+	require.NoError(t, err)
+	d.mu.Lock()
+	expired := d.registered["api"]["http://10.0.0.5:8080"]
+	d.mu.Unlock()
+
+	// The TTL runs out while the renewal holds the lock: the timer already
+	// fired and its expiry runs once the renewal is done
+	_, err = d.Register("api", Instance{Address: "http://10.0.0.5:8080"}, 0)
+	require.NoError(t, err)
+	d.expire("api", expired)
+
+	require.Len(t, d.Registrations(), 1)
+	assert.Equal(t, []string{"http://10.0.0.5:8080"}, addresses(router, "api"))
+}
+
+func TestDiscovery_RegisterInvalid(t *testing.T) {
+	router := route.NewRouter(nil, map[string]*config.ServiceConfig{
+		"api": {Name: "api", BalancerType: "round_robin", Registration: &config.RegistrationConfig{MaxTTL: time.Minute}},
+		"web": {Name: "web", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: "http://web1"}}},
+	})
+	d := New(router)
+
+	_, err := d.Register("db", Instance{Address: "http://db1"}, 0)
+	assert.ErrorIs(t, err, config.ErrServiceNotFound)
+	var notFound *config.ServiceNotFoundError
+	if assert.ErrorAs(t, err, &notFound) {
+		assert.Equal(t, "db", notFound.Service)
+	}
+	_, err = d.Register("web", Instance{Address: "http://web2"}, 0)
+	assert.ErrorIs(t, err, ErrRegistrationDisabled)
+	_, err = d.Register("api", Instance{Address: "http://10.0.0.5"}, time.Hour)
+	assert.EqualError(t, err, "ttl cannot exceed 1m0s")
+	_, err = d.Register("api", Instance{}, 0)
+	assert.EqualError(t, err, "server address cannot be empty")
+}
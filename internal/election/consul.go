@@ -0,0 +1,135 @@
+package election
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"nexus/internal/config"
+)
+
+// maxLockDelay is the longest lock delay Consul accepts
+const maxLockDelay = 60 * time.Second
+
+// ConsulLock is a lock on a Consul key held through a session. Consul drops
+// the session when it isn't renewed within its TTL and then keeps the key
+// locked for the lock delay, so a stalled leader has stepped down before the
+// next one is elected
+type ConsulLock struct {
+	address string
+	token   string // ACL token or reference
+	key     string
+	id      string
+	client  *http.Client
+
+	mu      sync.Mutex
+	session string
+}
+
+// NewConsulLock creates a Consul backed lock
+func NewConsulLock(cfg config.ConsulConfig, key, id string) *ConsulLock {
+	return &ConsulLock{
+		address: strings.TrimSuffix(cfg.Address, "/"),
+		token:   cfg.Token,
+		key:     strings.TrimPrefix(key, "/"),
+		id:      id,
+		client:  &http.Client{},
+	}
+}
+
+// Acquire implements the Lock interface, creating a session first when the
+// previous one expired
+func (l *ConsulLock) Acquire(ctx context.Context, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.session != "" {
+		status, _, err := l.do(ctx, "/v1/session/renew/"+l.session, nil)
+		if err != nil {
+			return false, err
+		}
+		if status == http.StatusNotFound {
+			l.session = ""
+		}
+	}
+	if l.session == "" {
+		body, _ := json.Marshal(map[string]string{
+			"Name":      "nexus-" + l.id,
+			"TTL":       fmt.Sprintf("%ds", int(ttl.Seconds())),
+			"Behavior":  "release",
+			"LockDelay": fmt.Sprintf("%ds", int(min(ttl, maxLockDelay).Seconds())),
+		})
+		status, reply, err := l.do(ctx, "/v1/session/create", body)
+		if err != nil {
+			return false, err
+		}
+		if status != http.StatusOK {
+			return false, fmt.Errorf("consul session create: unexpected status code %d", status)
+		}
+		var session struct{ ID string }
+		if err := json.Unmarshal(reply, &session); err != nil || session.ID == "" {
+			return false, fmt.Errorf("consul session create: invalid reply %q", reply)
+		}
+		l.session = session.ID
+	}
+
+	status, reply, err := l.do(ctx, "/v1/kv/"+l.key+"?acquire="+url.QueryEscape(l.session), []byte(l.id))
+	if err != nil {
+		return false, err
+	}
+	if status != http.StatusOK {
+		return false, fmt.Errorf("consul lock acquire: unexpected status code %d", status)
+	}
+	return strings.TrimSpace(string(reply)) == "true", nil
+}
+
+// Release implements the Lock interface, destroying the session also
+// releases the key
+func (l *ConsulLock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.session == "" {
+		return nil
+	}
+	status, _, err := l.do(ctx, "/v1/session/destroy/"+l.session, nil)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("consul session destroy: unexpected status code %d", status)
+	}
+	l.session = ""
+	return nil
+}
+
+// do sends a PUT request to the Consul HTTP API
+func (l *ConsulLock) do(ctx context.Context, path string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, l.address+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	if l.token != "" {
+		// Resolved per request so rotated tokens are picked up
+		token, err := config.ResolveCredential(l.token)
+		if err != nil {
+			return 0, nil, fmt.Errorf("consul token: %w", err)
+		}
+		req.Header.Set("X-Consul-Token", token)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	reply, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, reply, err
+}
@@ -0,0 +1,190 @@
+package election
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+)
+
+const (
+	defaultKey = "nexus/leader"
+	defaultTTL = 15 * time.Second
+)
+
+// Lock is a lease on a key held by one replica at a time
+type Lock interface {
+	// Acquire takes the lock for ttl, or renews it when this replica holds
+	// it, reporting whether this replica holds it afterwards
+	Acquire(ctx context.Context, ttl time.Duration) (bool, error)
+	// Release gives the lock up when this replica holds it
+	Release(ctx context.Context) error
+}
+
+// Elector campaigns for the leadership of a fleet of replicas. A replica
+// only counts itself leader until its lease could have expired, so it steps
+// down before another replica can take over even when the store is
+// unreachable
+type Elector struct {
+	lock     Lock
+	ttl      time.Duration
+	id       string
+	stopChan chan struct{}
+	done     chan struct{}
+
+	mu       sync.RWMutex
+	until    time.Time // End of the lease held, zero when not leader
+	leader   bool      // Leadership reported to the handlers
+	handlers []func(leader bool)
+}
+
+// New creates the elector described by the config, nil when election is
+// disabled
+func New(cfg config.LeaderElectionConfig) *Elector {
+	key := cfg.Key
+	if key == "" {
+		key = defaultKey
+	}
+	ttl := cfg.TTL
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	replica := hex.EncodeToString(id)
+
+	switch cfg.Store {
+	case "consul":
+		return NewElector(NewConsulLock(cfg.Consul, key, replica), ttl, replica)
+	case "redis":
+		return NewElector(NewRedisLock(cfg.Redis, key, replica), ttl, replica)
+	default:
+		return nil
+	}
+}
+
+// NewElector creates an elector campaigning with lock, id identifies the
+// replica in logs and the admin API
+func NewElector(lock Lock, ttl time.Duration, id string) *Elector {
+	return &Elector{
+		lock:     lock,
+		ttl:      ttl,
+		id:       id,
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// ID returns the identity of this replica
+func (e *Elector) ID() string {
+	return e.id
+}
+
+// IsLeader reports whether this replica holds an unexpired lease, a nil
+// elector always leads so single replicas do the shared work
+func (e *Elector) IsLeader() bool {
+	if e == nil {
+		return true
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return time.Now().Before(e.until)
+}
+
+// OnChange subscribes handler to leadership changes, handlers run on the
+// campaign goroutine and must not block
+func (e *Elector) OnChange(handler func(leader bool)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.handlers = append(e.handlers, handler)
+}
+
+// Start campaigns until stopped, renewing the lease every third of its TTL
+func (e *Elector) Start() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		e.campaign()
+		select {
+		case <-ticker.C:
+		case <-e.stopChan:
+			e.setUntil(time.Time{})
+			ctx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+			defer cancel()
+			if err := e.lock.Release(ctx); err != nil {
+				lg.GetInstance().Warn("Failed to release leadership: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// Stop stops campaigning and releases the lease, so other replicas don't
+// wait for it to expire
+func (e *Elector) Stop() {
+	close(e.stopChan)
+	<-e.done
+}
+
+// campaign acquires or renews the lease
+func (e *Elector) campaign() {
+	// The lease is counted from before the request, the store expires it later
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+	defer cancel()
+
+	held, err := e.lock.Acquire(ctx, e.ttl)
+	if err != nil {
+		// The lease held may still be valid, it lapses on its own
+		lg.GetInstance().Warn("Leader election failed: %v", err)
+		e.setUntil(e.leaseEnd())
+		return
+	}
+	if held {
+		// A tenth of the TTL covers clock drift between replica and store
+		e.setUntil(start.Add(e.ttl - e.ttl/10))
+	} else {
+		e.setUntil(time.Time{})
+	}
+}
+
+func (e *Elector) leaseEnd() time.Time {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.until
+}
+
+// setUntil sets the end of the lease and notifies leadership changes
+func (e *Elector) setUntil(until time.Time) {
+	e.mu.Lock()
+	e.until = until
+	leader := time.Now().Before(until)
+	changed := leader != e.leader
+	e.leader = leader
+	handlers := e.handlers
+	e.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if leader {
+		lg.GetInstance().Info("Replica %s became leader", e.id)
+	} else {
+		lg.GetInstance().Info("Replica %s is no longer leader", e.id)
+	}
+	for _, handler := range handlers {
+		handler(leader)
+	}
+}
@@ -0,0 +1,198 @@
+package election
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/redis/redistest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLock answers acquisitions with the configured result
+type fakeLock struct {
+	mu       sync.Mutex
+	held     bool
+	err      error
+	released bool
+}
+
+func (l *fakeLock) set(held bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.held, l.err = held, err
+}
+
+func (l *fakeLock) Acquire(ctx context.Context, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.held, l.err
+}
+
+func (l *fakeLock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.released = true
+	return nil
+}
+
+func TestNew_Disabled(t *testing.T) {
+	assert.Nil(t, New(config.LeaderElectionConfig{}))
+	// Replicas without election do the shared work
+	var e *Elector
+	assert.True(t, e.IsLeader())
+}
+
+func TestElector_Campaign(t *testing.T) {
+	lock := &fakeLock{}
+	e := NewElector(lock, time.Second, "replica1")
+	var changes []bool
+	e.OnChange(func(leader bool) { changes = append(changes, leader) })
+
+	e.campaign()
+	assert.False(t, e.IsLeader())
+
+	lock.set(true, nil)
+	e.campaign()
+	assert.True(t, e.IsLeader())
+	e.campaign()
+
+	// Another replica took the lock
+	lock.set(false, nil)
+	e.campaign()
+	assert.False(t, e.IsLeader())
+	assert.Equal(t, []bool{true, false}, changes)
+}
+
+func TestElector_LeaseLapsesWhenStoreIsUnreachable(t *testing.T) {
+	lock := &fakeLock{held: true}
+	e := NewElector(lock, 100*time.Millisecond, "replica1")
+	e.campaign()
+	require.True(t, e.IsLeader())
+
+	// The lease held stays valid until it could have expired in the store
+	lock.set(false, errors.New("connection refused"))
+	e.campaign()
+	assert.True(t, e.IsLeader())
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, e.IsLeader())
+}
+
+func TestElector_StopReleasesLock(t *testing.T) {
+	lock := &fakeLock{held: true}
+	e := NewElector(lock, time.Second, "replica1")
+	go e.Start()
+	require.Eventually(t, e.IsLeader, time.Second, 5*time.Millisecond)
+
+	e.Stop()
+	assert.False(t, e.IsLeader())
+	assert.True(t, lock.released)
+}
+
+func TestRedisLock(t *testing.T) {
+	owner := ""
+	server := redistest.NewServer(t, func(args []string) string {
+		switch args[0] {
+		case "SET":
+			if owner != "" {
+				return "$-1\r\n"
+			}
+			owner = args[2]
+			return "+OK\r\n"
+		case "EVAL":
+			// EVAL script numkeys key id [ttl]
+			if owner != args[4] {
+				return ":0\r\n"
+			}
+			if len(args) == 5 {
+				owner = ""
+			}
+			return ":1\r\n"
+		}
+		return "-ERR unknown command\r\n"
+	})
+	cfg := config.RedisConfig{Address: server.Addr(), Timeout: time.Second, KeyPrefix: "test:"}
+	first := NewRedisLock(cfg, "leader", "replica1")
+	second := NewRedisLock(cfg, "leader", "replica2")
+	ctx := context.Background()
+
+	held, err := first.Acquire(ctx, time.Second)
+	require.NoError(t, err)
+	assert.True(t, held)
+	held, err = second.Acquire(ctx, time.Second)
+	require.NoError(t, err)
+	assert.False(t, held)
+
+	// Renewals check the owner
+	held, err = first.Acquire(ctx, time.Second)
+	require.NoError(t, err)
+	assert.True(t, held)
+
+	require.NoError(t, first.Release(ctx))
+	held, err = second.Acquire(ctx, time.Second)
+	require.NoError(t, err)
+	assert.True(t, held)
+	assert.Equal(t, []string{"SET", "SET", "EVAL", "SET", "EVAL", "EVAL", "SET"}, server.Commands())
+}
+
+func TestConsulLock(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	holder := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("X-Consul-Token"))
+		switch {
+		case r.URL.Path == "/v1/session/create":
+			w.Write([]byte(`{"ID": "session1"}`))
+		case r.URL.Path == "/v1/session/renew/session1":
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/v1/kv/nexus/leader":
+			body, _ := io.ReadAll(r.Body)
+			assert.Equal(t, "replica1", string(body))
+			if holder == "" {
+				holder = r.URL.Query().Get("acquire")
+			}
+			if holder == r.URL.Query().Get("acquire") {
+				w.Write([]byte("true"))
+			} else {
+				w.Write([]byte("false"))
+			}
+		case r.URL.Path == "/v1/session/destroy/session1":
+			holder = ""
+			w.Write([]byte("true"))
+		}
+	}))
+	defer server.Close()
+
+	lock := NewConsulLock(config.ConsulConfig{Address: server.URL, Token: "secret"}, "/nexus/leader", "replica1")
+	ctx := context.Background()
+	held, err := lock.Acquire(ctx, 15*time.Second)
+	require.NoError(t, err)
+	assert.True(t, held)
+
+	// An expired session is created again
+	held, err = lock.Acquire(ctx, 15*time.Second)
+	require.NoError(t, err)
+	assert.True(t, held)
+
+	require.NoError(t, lock.Release(ctx))
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		"/v1/session/create", "/v1/kv/nexus/leader",
+		"/v1/session/renew/session1", "/v1/session/create", "/v1/kv/nexus/leader",
+		"/v1/session/destroy/session1",
+	}, requests)
+	assert.Empty(t, holder)
+}
@@ -0,0 +1,57 @@
+package election
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/redis"
+)
+
+// Only the holder renews or deletes the key, checked atomically by Redis
+const (
+	renewScript   = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) end return 0`
+	releaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`
+)
+
+// RedisLock is a lock on a Redis key holding the replica id with an expiry
+type RedisLock struct {
+	client *redis.Client
+	key    string
+	id     string
+}
+
+// NewRedisLock creates a Redis backed lock, the key prefix of the config is
+// prepended to key
+func NewRedisLock(cfg config.RedisConfig, key, id string) *RedisLock {
+	return &RedisLock{client: redis.NewClient(cfg), key: cfg.KeyPrefix + key, id: id}
+}
+
+// Acquire implements the Lock interface
+func (l *RedisLock) Acquire(ctx context.Context, ttl time.Duration) (bool, error) {
+	ms := milliseconds(ttl)
+	reply, err := l.client.Do(ctx, "SET", l.key, l.id, "NX", "PX", ms)
+	if err != nil {
+		return false, err
+	}
+	if reply == "OK" {
+		return true, nil
+	}
+
+	reply, err = l.client.Do(ctx, "EVAL", renewScript, "1", l.key, l.id, ms)
+	if err != nil {
+		return false, err
+	}
+	return reply == int64(1), nil
+}
+
+// Release implements the Lock interface
+func (l *RedisLock) Release(ctx context.Context) error {
+	_, err := l.client.Do(ctx, "EVAL", releaseScript, "1", l.key, l.id)
+	return err
+}
+
+func milliseconds(d time.Duration) string {
+	return strconv.FormatInt(d.Milliseconds(), 10)
+}
@@ -0,0 +1,81 @@
+package fault
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"nexus/internal/config"
+)
+
+// Injector holds the faults set at runtime, which replace the configured
+// fault of their route until they are cleared. They outlive config reloads
+type Injector struct {
+	mu        sync.RWMutex
+	overrides map[string]*config.FaultConfig
+}
+
+// NewInjector creates an injector without runtime faults
+func NewInjector() *Injector {
+	return &Injector{overrides: make(map[string]*config.FaultConfig)}
+}
+
+// Set sets the runtime fault of a route
+func (i *Injector) Set(route string, fault *config.FaultConfig) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.overrides[route] = fault
+}
+
+// Clear removes the runtime fault of a route, reporting whether it had one
+func (i *Injector) Clear(route string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	_, ok := i.overrides[route]
+	delete(i.overrides, route)
+	return ok
+}
+
+// Overrides returns a snapshot of the runtime faults by route
+func (i *Injector) Overrides() map[string]*config.FaultConfig {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	overrides := make(map[string]*config.FaultConfig, len(i.overrides))
+	for route, fault := range i.overrides {
+		overrides[route] = fault
+	}
+	return overrides
+}
+
+// Fault returns the fault applied to a route, the runtime one before the
+// configured one. A nil injector only applies configured faults
+func (i *Injector) Fault(route *config.RouteConfig) *config.FaultConfig {
+	if i != nil {
+		i.mu.RLock()
+		fault, ok := i.overrides[route.Name]
+		i.mu.RUnlock()
+		if ok {
+			return fault
+		}
+	}
+	return route.Fault
+}
+
+// Hit reports whether a request falls within percentage, 0 meaning every request
+func Hit(percentage float64) bool {
+	if percentage == 0 || percentage >= 100 {
+		return true
+	}
+	return rand.Float64()*100 < percentage
+}
+
+// Delay returns the duration of an injected delay, with its random jitter
+func Delay(delay *config.FaultDelayConfig) time.Duration {
+	if delay.Jitter <= 0 {
+		return delay.Duration
+	}
+	return delay.Duration + rand.N(delay.Jitter)
+}
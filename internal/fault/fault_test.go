@@ -0,0 +1,49 @@
+package fault
+
+import (
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInjector(t *testing.T) {
+	configured := &config.FaultConfig{Abort: &config.FaultAbortConfig{Status: 503}}
+	route := &config.RouteConfig{Name: "api", Fault: configured}
+
+	var none *Injector
+	assert.Same(t, configured, none.Fault(route))
+
+	injector := NewInjector()
+	assert.Same(t, configured, injector.Fault(route))
+
+	runtime := &config.FaultConfig{Delay: &config.FaultDelayConfig{Duration: time.Second}}
+	injector.Set("api", runtime)
+	assert.Same(t, runtime, injector.Fault(route))
+	assert.Equal(t, map[string]*config.FaultConfig{"api": runtime}, injector.Overrides())
+
+	assert.True(t, injector.Clear("api"))
+	assert.False(t, injector.Clear("api"))
+	assert.Same(t, configured, injector.Fault(route))
+}
+
+func TestHit(t *testing.T) {
+	hits := 0
+	for i := 0; i < 10000; i++ {
+		if Hit(25) {
+			hits++
+		}
+	}
+	assert.InDelta(t, 2500, hits, 250)
+	assert.True(t, Hit(0))
+	assert.True(t, Hit(100))
+
+	delay := &config.FaultDelayConfig{Duration: time.Second, Jitter: time.Second}
+	for i := 0; i < 100; i++ {
+		d := Delay(delay)
+		assert.GreaterOrEqual(t, d, time.Second)
+		assert.Less(t, d, 2*time.Second)
+	}
+}
@@ -0,0 +1,44 @@
+package geo
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"nexus/internal/realip"
+)
+
+// Location is the resolved origin of a client
+type Location struct {
+	Country   string // ISO 3166-1 alpha-2 code, e.g. DE
+	Continent string // Continent code, e.g. EU
+}
+
+// Resolver looks up the location of an IP address
+type Resolver interface {
+	Lookup(ip net.IP) (Location, error)
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the client location
+func NewContext(ctx context.Context, loc Location) context.Context {
+	return context.WithValue(ctx, contextKey{}, loc)
+}
+
+// FromContext returns the client location stored in the context
+func FromContext(ctx context.Context) (Location, bool) {
+	loc, ok := ctx.Value(contextKey{}).(Location)
+	return loc, ok
+}
+
+// ClientIP returns the IP address of the client, relayed by trusted proxies
+// when the real IP is configured, see realip.ClientIP
+func ClientIP(r *http.Request) net.IP {
+	ip := realip.ClientIP(r)
+	if !ip.IsValid() {
+		return nil
+	}
+
+	return net.IP(ip.AsSlice())
+}
@@ -0,0 +1,39 @@
+package geo
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	ctx := NewContext(context.Background(), Location{Country: "DE", Continent: "EU"})
+	loc, ok := FromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "DE", loc.Country)
+	assert.Equal(t, "EU", loc.Continent)
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+
+	req.RemoteAddr = "192.0.2.1:1234"
+	assert.True(t, net.ParseIP("192.0.2.1").Equal(ClientIP(req)))
+
+	req.RemoteAddr = "[2001:db8::1]:443"
+	assert.True(t, net.ParseIP("2001:db8::1").Equal(ClientIP(req)))
+
+	req.RemoteAddr = "invalid"
+	assert.Nil(t, ClientIP(req))
+}
+
+func TestOpenMaxMind_MissingDatabase(t *testing.T) {
+	_, err := OpenMaxMind("/nonexistent/GeoLite2-Country.mmdb")
+	assert.Error(t, err)
+}
@@ -0,0 +1,50 @@
+package geo
+
+import (
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// MaxMindResolver resolves locations from a MaxMind GeoIP2/GeoLite2 country or city database
+type MaxMindResolver struct {
+	db *maxminddb.Reader
+}
+
+// maxMindRecord is the subset of the GeoIP2 schema used for routing
+type maxMindRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Continent struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"continent"`
+}
+
+// OpenMaxMind opens a MaxMind database file
+func OpenMaxMind(path string) (*MaxMindResolver, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MaxMindResolver{db: db}, nil
+}
+
+// Lookup implements the Resolver interface
+func (m *MaxMindResolver) Lookup(ip net.IP) (Location, error) {
+	var record maxMindRecord
+	if err := m.db.Lookup(ip, &record); err != nil {
+		return Location{}, err
+	}
+
+	return Location{
+		Country:   record.Country.ISOCode,
+		Continent: record.Continent.Code,
+	}, nil
+}
+
+// Close releases the database
+func (m *MaxMindResolver) Close() error {
+	return m.db.Close()
+}
@@ -0,0 +1,75 @@
+package healthcheck
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"nexus/internal/config"
+)
+
+// Probe connections are reused between intervals, a few per server suffice
+const (
+	probeDialTimeout      = 5 * time.Second
+	probeKeepAlive        = 30 * time.Second
+	probeTLSTimeout       = 5 * time.Second
+	probeIdleConnTimeout  = 90 * time.Second
+	probeMaxIdlePerHost   = 2
+	probeMaxResponseBytes = 64 << 10
+)
+
+// newClient returns the HTTP client used for probes, with its own connection
+// pool and the certificates of the health check TLS config
+func newClient(cfg config.HealthCheckTLSConfig) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   probeDialTimeout,
+		KeepAlive: probeKeepAlive,
+	}
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: probeTLSTimeout,
+		IdleConnTimeout:     probeIdleConnTimeout,
+		MaxIdleConnsPerHost: probeMaxIdlePerHost,
+		ForceAttemptHTTP2:   true,
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+func newTLSConfig(cfg config.HealthCheckTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in health check ca_file")
+		}
+		tlsConfig.RootCAs = roots
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,75 @@
+package healthcheck
+
+import (
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+)
+
+func TestHealthChecker_TLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	checker := NewHealthChecker(true, time.Hour, healthCheckTimeout, "/health")
+	checker.AddServer(ts.URL)
+
+	// The test certificate isn't trusted by the system roots
+	checker.checkAllServers()
+	if checker.IsHealthy(ts.URL) {
+		t.Error("Expected probe against an untrusted certificate to fail")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := checker.SetTLSConfig(config.HealthCheckTLSConfig{CAFile: caFile}); err != nil {
+		t.Fatalf("SetTLSConfig failed: %v", err)
+	}
+	checker.checkAllServers()
+	if !checker.IsHealthy(ts.URL) {
+		t.Error("Expected probe to trust the configured CA")
+	}
+
+	if err := checker.SetTLSConfig(config.HealthCheckTLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("Expected an error for a missing ca_file")
+	}
+}
+
+func TestHealthChecker_ReusesConnections(t *testing.T) {
+	var conns atomic.Int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	checker := NewHealthChecker(true, time.Hour, healthCheckTimeout, "/health")
+	checker.AddServer(ts.URL)
+	for i := 0; i < 3; i++ {
+		checker.checkAllServers()
+	}
+
+	if !checker.IsHealthy(ts.URL) {
+		t.Error("Expected server to be healthy")
+	}
+	if got := conns.Load(); got != 1 {
+		t.Errorf("Expected probes to share 1 connection, got %d", got)
+	}
+}
@@ -0,0 +1,97 @@
+package healthcheck
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// grpcProber probes servers with the grpc.health.v1 protocol, keeping one
+// connection per server between intervals
+type grpcProber struct {
+	tls *tls.Config
+
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+func newGRPCProber(tlsConfig *tls.Config) *grpcProber {
+	return &grpcProber{tls: tlsConfig, conns: make(map[string]*grpc.ClientConn)}
+}
+
+// check asks a server for the status of a health service, the empty name
+// stands for the whole server. Only SERVING is healthy
+func (g *grpcProber) check(ctx context.Context, address, service string) error {
+	conn, err := g.conn(address)
+	if err != nil {
+		return err
+	}
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		return err
+	}
+	if status := resp.GetStatus(); status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("grpc health status: %s", status)
+	}
+	return nil
+}
+
+// conn returns the connection to a server, http addresses use plaintext
+// HTTP/2 and https addresses the health check TLS config
+func (g *grpcProber) conn(address string) (*grpc.ClientConn, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if conn, ok := g.conns[address]; ok {
+		return conn, nil
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid grpc health check address: %s", address)
+	}
+	creds := insecure.NewCredentials()
+	if u.Scheme == "https" {
+		creds = credentials.NewTLS(g.tls.Clone())
+	}
+	conn, err := grpc.NewClient(u.Host, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	g.conns[address] = conn
+
+	return conn, nil
+}
+
+// close closes the connection to a server no longer probed
+func (g *grpcProber) close(address string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if conn, ok := g.conns[address]; ok {
+		conn.Close()
+		delete(g.conns, address)
+	}
+}
+
+// closeAll closes the connections to all servers
+func (g *grpcProber) closeAll() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for address, conn := range g.conns {
+		conn.Close()
+		delete(g.conns, address)
+	}
+}
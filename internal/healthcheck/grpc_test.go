@@ -0,0 +1,66 @@
+package healthcheck
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHealthChecker_GRPC(t *testing.T) {
+	t.Parallel()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	status := health.NewServer()
+	healthpb.RegisterHealthServer(server, status)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	address := "http://" + lis.Addr().String()
+	status.SetServingStatus("orders.v1.Orders", healthpb.HealthCheckResponse_SERVING)
+	status.SetServingStatus("billing.v1.Billing", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	checker := NewHealthChecker(true, time.Hour, healthCheckTimeout, "/health")
+	checker.AddServer(address)
+
+	// The whole server is SERVING by default
+	checker.SetServerConfig(address, &config.ServiceHealthCheckConfig{Type: config.HealthCheckGRPC})
+	checker.checkAllServers()
+	if !checker.IsHealthy(address) {
+		t.Error("Expected server to be healthy")
+	}
+
+	checker.SetServerConfig(address, &config.ServiceHealthCheckConfig{Type: config.HealthCheckGRPC, GRPCService: "orders.v1.Orders"})
+	checker.checkAllServers()
+	if !checker.IsHealthy(address) {
+		t.Error("Expected serving health service to be healthy")
+	}
+
+	checker.SetServerConfig(address, &config.ServiceHealthCheckConfig{Type: config.HealthCheckGRPC, GRPCService: "billing.v1.Billing"})
+	checker.checkAllServers()
+	if checker.IsHealthy(address) {
+		t.Error("Expected not serving health service to be unhealthy")
+	}
+
+	checker.SetServerConfig(address, &config.ServiceHealthCheckConfig{Type: config.HealthCheckGRPC, GRPCService: "unknown"})
+	status.SetServingStatus("billing.v1.Billing", healthpb.HealthCheckResponse_SERVING)
+	checker.checkAllServers()
+	if checker.IsHealthy(address) {
+		t.Error("Expected unknown health service to be unhealthy")
+	}
+
+	// Probes of removed servers close their connection
+	checker.RemoveServer(address)
+	if len(checker.grpc.conns) != 0 {
+		t.Errorf("Expected no gRPC connections left, got %d", len(checker.grpc.conns))
+	}
+}
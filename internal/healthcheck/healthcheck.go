@@ -3,10 +3,13 @@ package healthcheck
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"slices"
 	"sync"
 	"time"
 
+	"nexus/internal/config"
 	lg "nexus/internal/logger"
 
 	"go.opentelemetry.io/otel"
@@ -23,12 +26,44 @@ type HealthChecker struct {
 	timeout  time.Duration
 	stopChan chan struct{}
 	path     string
+	shared   SharedState
+	reporter *Reporter
+	client   *http.Client
+	grpc     *grpcProber
+	tls      config.HealthCheckTLSConfig
+	handlers []StatusHandler
+
+	assumeHealthy bool // Mark added servers healthy instead of probing them first
+	running       bool
 }
 
+// StatusHandler is called when a server turns healthy or unhealthy
+type StatusHandler func(server string, healthy bool)
+
 type serverInfo struct {
-	address string
-	id      string
-	healthy bool
+	address   string
+	id        string
+	healthy   bool
+	paused    bool
+	check     *config.ServiceHealthCheckConfig // Per-service override, nil uses the global settings
+	path      string                           // Per-server path, overrides the service and global paths
+	nextCheck time.Time
+	history   history
+
+	version    string // Reported by the info endpoint
+	infoFailed bool   // The last info request failed
+}
+
+// checkSettings are the effective probe settings of a server
+type checkSettings struct {
+	path     string
+	interval time.Duration
+	timeout  time.Duration
+	statuses []int
+
+	grpc        bool   // Probe with grpc.health.v1 instead of HTTP
+	grpcService string // Health service name of gRPC probes
+	infoPath    string // Requested after healthy probes for the server version
 }
 
 // NewHealthChecker creates a new health checker
@@ -37,7 +72,13 @@ func NewHealthChecker(enabled bool, interval, timeout time.Duration, path string
 		return nil
 	}
 
+	// The default TLS settings load no files and can't fail
+	client, _ := newClient(config.HealthCheckTLSConfig{})
+	tlsConfig, _ := newTLSConfig(config.HealthCheckTLSConfig{})
+
 	return &HealthChecker{
+		client:   client,
+		grpc:     newGRPCProber(tlsConfig),
 		servers:  make(map[string]*serverInfo),
 		interval: interval,
 		timeout:  timeout,
@@ -46,23 +87,139 @@ func NewHealthChecker(enabled bool, interval, timeout time.Duration, path string
 	}
 }
 
-// AddServer adds a server to be health checked
+// AddServer adds a server to be health checked. Unless healthy is assumed the
+// server stays ineligible until its first probe, which runs right away when
+// the checker is started
 func (h *HealthChecker) AddServer(address string) {
+	h.mu.Lock()
+	info := &serverInfo{
+		address: address,
+		healthy: h.assumeHealthy,
+	}
+	h.servers[address] = info
+	probe := h.running && !h.assumeHealthy
+	if probe {
+		info.nextCheck = time.Now().Add(h.settings(info).interval)
+	}
+	h.mu.Unlock()
+
+	if probe {
+		go h.checkServer(info)
+	}
+}
+
+// SetAssumeHealthy marks servers added afterwards healthy until their first
+// scheduled probe instead of probing them immediately
+func (h *HealthChecker) SetAssumeHealthy(assume bool) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	h.servers[address] = &serverInfo{
-		address: address,
-		healthy: true,
+	h.assumeHealthy = assume
+}
+
+// SetServerConfig overrides the global path, interval, timeout, expected
+// statuses, probe type and info path for a server, nil restores the global settings. A server shared by
+// several services uses the last override set
+func (h *HealthChecker) SetServerConfig(address string, check *config.ServiceHealthCheckConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if info, exists := h.servers[address]; exists {
+		info.check = check
+		info.nextCheck = time.Time{}
+		if check == nil || check.InfoPath == "" {
+			info.version, info.infoFailed = "", false
+		}
 	}
 }
 
+// SetServerPath overrides the health check path of a server, for backends
+// whose instances don't all expose health at the same path. An empty path
+// restores the service or global path
+func (h *HealthChecker) SetServerPath(address, path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if info, exists := h.servers[address]; exists {
+		info.path = path
+	}
+}
+
+// settings resolves the probe settings of a server, the caller must hold h.mu
+func (h *HealthChecker) settings(s *serverInfo) checkSettings {
+	settings := checkSettings{
+		path:     h.path,
+		interval: h.interval,
+		timeout:  h.timeout,
+		statuses: []int{http.StatusOK},
+	}
+	if s.path != "" {
+		settings.path = s.path
+	}
+	if s.check == nil {
+		return settings
+	}
+	if s.check.Path != "" && s.path == "" {
+		settings.path = s.check.Path
+	}
+	if s.check.Interval > 0 {
+		settings.interval = s.check.Interval
+	}
+	if s.check.Timeout > 0 {
+		settings.timeout = s.check.Timeout
+	}
+	if len(s.check.ExpectedStatuses) > 0 {
+		settings.statuses = s.check.ExpectedStatuses
+	}
+	if s.check.Type == config.HealthCheckGRPC {
+		settings.grpc = true
+		settings.grpcService = s.check.GRPCService
+	}
+	settings.infoPath = s.check.InfoPath
+
+	return settings
+}
+
+// UpdateServers syncs the monitored servers with addresses, e.g. after a
+// config reload. New servers are added as by AddServer, servers no longer
+// listed are removed and the rest keep their state
+func (h *HealthChecker) UpdateServers(addresses []string) {
+	keep := make(map[string]bool, len(addresses))
+	var added []string
+	h.mu.Lock()
+	for _, address := range addresses {
+		keep[address] = true
+		if _, exists := h.servers[address]; !exists && !slices.Contains(added, address) {
+			added = append(added, address)
+		}
+	}
+	for address := range h.servers {
+		if !keep[address] {
+			delete(h.servers, address)
+			h.grpc.close(address)
+			serverLogger(address).Info("Removed from health checking")
+		}
+	}
+	h.mu.Unlock()
+
+	for _, address := range added {
+		h.AddServer(address)
+		serverLogger(address).Info("Added to health checking")
+	}
+}
+
+// serverLogger returns the logger of the logs about a server
+func serverLogger(address string) *lg.Logger {
+	return lg.GetInstance().With(lg.F("backend", address))
+}
+
 // RemoveServer removes a server from health checking
 func (h *HealthChecker) RemoveServer(server string) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	delete(h.servers, server)
+	h.grpc.close(server)
 }
 
 // IsHealthy checks if a server is healthy
@@ -73,99 +230,344 @@ func (h *HealthChecker) IsHealthy(server string) bool {
 	return h.servers[server] != nil && h.servers[server].healthy
 }
 
-// Start begins the health checking process
+// Start begins the health checking process, ticking at the shortest interval
+// of all servers and probing each one once its own interval has elapsed. It
+// returns at once when already running and restarts a stopped checker
 func (h *HealthChecker) Start() {
-	ticker := time.NewTicker(h.interval)
+	h.mu.Lock()
+	if h.running {
+		h.mu.Unlock()
+		return
+	}
+	select {
+	case <-h.stopChan:
+		h.stopChan = make(chan struct{})
+	default:
+	}
+	h.running = true
+	stopChan := h.stopChan
+	initial := !h.assumeHealthy
+	h.mu.Unlock()
+
+	// Probe servers added before the start instead of waiting a whole interval
+	if initial {
+		h.checkDueServers(time.Now(), 0)
+	}
+
+	tick := h.tickInterval()
+	ticker := time.NewTicker(tick)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
-			h.checkAllServers()
-		case <-h.stopChan:
+		case now := <-ticker.C:
+			h.checkDueServers(now, tick)
+			if next := h.tickInterval(); next != tick {
+				tick = next
+				ticker.Reset(tick)
+			}
+		case <-stopChan:
 			return
 		}
 	}
 }
 
-// Stop terminates the health checking process
+// tickInterval returns the shortest check interval of all servers
+func (h *HealthChecker) tickInterval() time.Duration {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	tick := h.interval
+	for _, s := range h.servers {
+		if s.check != nil && s.check.Interval > 0 && s.check.Interval < tick {
+			tick = s.check.Interval
+		}
+	}
+
+	return tick
+}
+
+// Stop terminates the health checking process, stopping twice is a no-op
 func (h *HealthChecker) Stop() {
-	close(h.stopChan)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	select {
+	case <-h.stopChan:
+	default:
+		close(h.stopChan)
+	}
+	h.running = false
 }
 
 // checkAllServers checks the health status of all servers
 func (h *HealthChecker) checkAllServers() {
-	var wg sync.WaitGroup
 	h.mu.RLock()
 	servers := make([]*serverInfo, 0, len(h.servers))
 	for _, s := range h.servers {
-		servers = append(servers, s)
+		if !s.paused {
+			servers = append(servers, s)
+		}
 	}
 	h.mu.RUnlock()
 
+	h.checkServers(servers)
+}
+
+// checkDueServers checks the servers whose interval has elapsed at now, half
+// a tick of slack keeps ticker jitter from skipping a whole interval
+func (h *HealthChecker) checkDueServers(now time.Time, tick time.Duration) {
+	h.mu.Lock()
+	servers := make([]*serverInfo, 0, len(h.servers))
+	for _, s := range h.servers {
+		if s.paused || now.Add(tick/2).Before(s.nextCheck) {
+			continue
+		}
+		s.nextCheck = now.Add(h.settings(s).interval)
+		servers = append(servers, s)
+	}
+	h.mu.Unlock()
+
+	h.checkServers(servers)
+}
+
+func (h *HealthChecker) checkServers(servers []*serverInfo) {
+	var wg sync.WaitGroup
 	for _, s := range servers {
 		wg.Add(1)
 		go func(s *serverInfo) {
 			defer wg.Done()
-			ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
-			defer cancel()
-
-			// Create tracing span
-			ctx, span := otel.Tracer("nexus.healthcheck").Start(ctx, "HealthCheck",
-				trace.WithAttributes(
-					attribute.String("service.address", s.address),
-				))
-			defer span.End()
-
-			startTime := time.Now()
-			err := h.httpCheck(ctx, s.address)
-			duration := time.Since(startTime)
-
-			// Record check result
-			span.SetAttributes(
-				attribute.Bool("check.healthy", err == nil),
-				attribute.Int64("check.duration_ms", duration.Milliseconds()),
-			)
-
-			if err != nil {
-				span.RecordError(err)
-				span.SetStatus(codes.Error, err.Error())
-				lg.GetInstance().Error("[%s] Health check failed - Duration: %v Error: %v",
-					s.address, duration.Round(time.Millisecond), err)
-			}
-
-			h.UpdateServerStatus(s.address, err == nil)
+			h.checkServer(s)
 		}(s)
 	}
 	wg.Wait()
 }
 
-func (h *HealthChecker) httpCheck(ctx context.Context, address string) error {
-	req, err := http.NewRequestWithContext(ctx, "GET", address+h.path, nil)
+// checkServer probes a server, or adopts the result of the replica that
+// claimed the probe when health state is shared
+func (h *HealthChecker) checkServer(s *serverInfo) {
+	h.mu.RLock()
+	shared, settings := h.shared, h.settings(s)
+	h.mu.RUnlock()
+
+	if shared != nil {
+		if healthy, ok := h.sharedResult(shared, s.address, settings.interval, settings.timeout); ok {
+			h.UpdateServerStatus(s.address, healthy)
+			h.refreshInfo(s.address, healthy, settings)
+			return
+		}
+	}
+
+	healthy := h.probe(s, settings)
+	h.UpdateServerStatus(s.address, healthy)
+	h.refreshInfo(s.address, healthy, settings)
+
+	if shared != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), settings.timeout)
+		defer cancel()
+		// Results outlive a few intervals so a crashed prober doesn't erase them at once
+		if err := shared.Publish(ctx, s.address, healthy, 3*settings.interval); err != nil {
+			serverLogger(s.address).Warn("Failed to share health check result: %v", err)
+		}
+	}
+}
+
+// sharedResult returns the shared result when another replica owns this
+// interval's probe, ok is false when this replica should probe itself
+func (h *HealthChecker) sharedResult(shared SharedState, address string, interval, timeout time.Duration) (bool, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// The claim expires just before the next tick so probing rotates between replicas
+	claimed, err := shared.Acquire(ctx, address, interval*9/10)
+	if err != nil {
+		serverLogger(address).Warn("Shared health state unavailable, probing locally: %v", err)
+		return false, false
+	}
+	if claimed {
+		return false, false
+	}
+
+	healthy, ok, err := shared.Lookup(ctx, address)
+	if err != nil {
+		serverLogger(address).Warn("Shared health state unavailable, probing locally: %v", err)
+		return false, false
+	}
+
+	return healthy, ok
+}
+
+// probe runs a traced health check against a server
+func (h *HealthChecker) probe(s *serverInfo, settings checkSettings) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), settings.timeout)
+	defer cancel()
+
+	// Create tracing span
+	ctx, span := otel.Tracer("nexus.healthcheck").Start(ctx, "HealthCheck",
+		trace.WithAttributes(
+			attribute.String("service.address", s.address),
+		))
+	defer span.End()
+
+	startTime := time.Now()
+	var err error
+	if settings.grpc {
+		err = h.grpcCheck(ctx, s.address, settings.grpcService)
+	} else {
+		err = h.httpCheck(ctx, s.address, settings.path, settings.statuses)
+	}
+	duration := time.Since(startTime)
+
+	// Record check result
+	result := ProbeResult{
+		Time:      startTime,
+		LatencyMs: float64(duration) / float64(time.Millisecond),
+		Healthy:   err == nil,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	h.recordProbe(s.address, result)
+	span.SetAttributes(
+		attribute.Bool("check.healthy", err == nil),
+		attribute.Int64("check.duration_ms", duration.Milliseconds()),
+	)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		serverLogger(s.address).With(lg.F("duration", duration.Round(time.Millisecond))).Error("Health check failed: %v", err)
+	}
+
+	return err == nil
+}
+
+func (h *HealthChecker) httpCheck(ctx context.Context, address, path string, statuses []int) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", address+path, nil)
 	if err != nil {
 		return err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	h.mu.RLock()
+	client := h.client
+	h.mu.RUnlock()
+
+	resp, err := client.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
+	// Drain the body so the connection is reused by the next probe
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, probeMaxResponseBytes))
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("non-normal status code: %d", resp.StatusCode)
+	for _, status := range statuses {
+		if resp.StatusCode == status {
+			return nil
+		}
 	}
-	return nil
+	return fmt.Errorf("non-normal status code: %d", resp.StatusCode)
+}
+
+func (h *HealthChecker) grpcCheck(ctx context.Context, address, service string) error {
+	h.mu.RLock()
+	prober := h.grpc
+	h.mu.RUnlock()
+
+	return prober.check(ctx, address, service)
 }
 
 // UpdateServerStatus updates the server's health status
 func (h *HealthChecker) UpdateServerStatus(server string, healthy bool) {
+	h.mu.Lock()
+	info, exists := h.servers[server]
+	changed := exists && info.healthy != healthy
+	if exists {
+		info.healthy = healthy
+	}
+	handlers := h.handlers
+	if changed && h.reporter != nil {
+		h.reporter.Notify()
+	}
+	h.mu.Unlock()
+
+	if changed {
+		for _, handler := range handlers {
+			handler(server, healthy)
+		}
+	}
+}
+
+// OnStatusChange subscribes handler to health transitions of all servers.
+// Handlers run on the probing goroutine outside the checker's lock and must
+// not block
+func (h *HealthChecker) OnStatusChange(handler StatusHandler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.handlers = append(h.handlers, handler)
+}
+
+// SetPaused suspends or resumes probing of a server, e.g. during maintenance
+func (h *HealthChecker) SetPaused(server string, paused bool) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	if info, exists := h.servers[server]; exists {
-		info.healthy = healthy
+		info.paused = paused
+	}
+}
+
+// IsPaused checks if probing of a server is suspended
+func (h *HealthChecker) IsPaused(server string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.servers[server] != nil && h.servers[server].paused
+}
+
+// SetSharedState shares probes and results with other replicas, nil disables sharing
+func (h *HealthChecker) SetSharedState(shared SharedState) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.shared = shared
+}
+
+// SetTLSConfig sets the CA bundle, client certificate and verification
+// settings of probes against https servers
+func (h *HealthChecker) SetTLSConfig(cfg config.HealthCheckTLSConfig) error {
+	h.mu.RLock()
+	unchanged := h.tls == cfg
+	h.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	client, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return err
 	}
+
+	h.mu.Lock()
+	old, oldGRPC := h.client, h.grpc
+	h.client, h.grpc, h.tls = client, newGRPCProber(tlsConfig), cfg
+	h.mu.Unlock()
+
+	old.CloseIdleConnections()
+	oldGRPC.closeAll()
+	return nil
+}
+
+// SetReporter sets the reporter notified when a server changes state
+func (h *HealthChecker) SetReporter(reporter *Reporter) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.reporter = reporter
 }
 
 // UpdateInterval updates the health checking interval
@@ -3,9 +3,12 @@ package healthcheck
 import (
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"nexus/internal/config"
+
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/sdk/trace"
@@ -113,6 +116,96 @@ func TestHealthChecker_RemoveServer(t *testing.T) {
 	}
 }
 
+func TestHealthChecker_UpdateServers(t *testing.T) {
+	checker := NewHealthChecker(true, time.Hour, healthCheckTimeout, "/health")
+	checker.SetAssumeHealthy(true)
+	checker.AddServer("http://server1:8080")
+	checker.AddServer("http://server2:8080")
+	checker.UpdateServerStatus("http://server1:8080", false)
+
+	checker.UpdateServers([]string{"http://server1:8080", "http://server3:8080", "http://server3:8080"})
+
+	checker.mu.RLock()
+	defer checker.mu.RUnlock()
+	if len(checker.servers) != 2 {
+		t.Fatalf("Expected 2 servers, got %d", len(checker.servers))
+	}
+	if _, exists := checker.servers["http://server2:8080"]; exists {
+		t.Error("Server no longer configured should be removed")
+	}
+	if info := checker.servers["http://server1:8080"]; info == nil || info.healthy {
+		t.Error("Existing server should keep its state")
+	}
+	if info := checker.servers["http://server3:8080"]; info == nil || !info.healthy {
+		t.Error("New server should be added")
+	}
+}
+
+func TestHealthChecker_Restart(t *testing.T) {
+	t.Parallel()
+
+	var probes atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	checker := NewHealthChecker(true, time.Hour, healthCheckTimeout, "/health")
+	checker.AddServer(ts.URL)
+
+	checker.Stop()
+	checker.Stop()
+
+	// A stopped checker starts again and probes on start
+	go checker.Start()
+	defer checker.Stop()
+	waitHealthy(t, checker, ts.URL, true)
+
+	// Starting a running checker is a no-op
+	done := make(chan struct{})
+	go func() {
+		checker.Start()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Start should return when already running")
+	}
+	if got := probes.Load(); got != 1 {
+		t.Errorf("Expected 1 probe, got %d", got)
+	}
+}
+
+func TestHealthChecker_OnStatusChange(t *testing.T) {
+	checker := NewHealthChecker(true, time.Hour, healthCheckTimeout, "/health")
+	checker.SetAssumeHealthy(true)
+	checker.AddServer("http://server1:8080")
+
+	var changes []bool
+	checker.OnStatusChange(func(server string, healthy bool) {
+		if server != "http://server1:8080" {
+			t.Errorf("Unexpected server %s", server)
+		}
+		// Handlers run outside the lock and may query the checker
+		if checker.IsHealthy(server) != healthy {
+			t.Error("Handler should see the new status")
+		}
+		changes = append(changes, healthy)
+	})
+
+	checker.UpdateServerStatus("http://server1:8080", true)
+	checker.UpdateServerStatus("http://server1:8080", false)
+	checker.UpdateServerStatus("http://server1:8080", false)
+	checker.UpdateServerStatus("http://server1:8080", true)
+	checker.UpdateServerStatus("http://unknown:8080", false)
+
+	if len(changes) != 2 || changes[0] || !changes[1] {
+		t.Errorf("Expected transitions [false true], got %v", changes)
+	}
+}
+
 func TestHealthChecker_UpdateConfig(t *testing.T) {
 	healthChecker := NewHealthChecker(true, 10*time.Second, 1*time.Second, "/health")
 	healthChecker.AddServer("http://server1:8080")
@@ -130,6 +223,159 @@ func TestHealthChecker_UpdateConfig(t *testing.T) {
 	}
 }
 
+func TestHealthChecker_InitialProbe(t *testing.T) {
+	t.Parallel()
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer dead.Close()
+
+	// An hour long interval leaves only the initial probes to run
+	checker := NewHealthChecker(true, time.Hour, healthCheckTimeout, "/health")
+	checker.AddServer(healthy.URL)
+	if checker.IsHealthy(healthy.URL) {
+		t.Error("Expected server to be ineligible before its first probe")
+	}
+	go checker.Start()
+	defer checker.Stop()
+	waitHealthy(t, checker, healthy.URL, true)
+
+	// Servers added while running are probed right away
+	checker.AddServer(dead.URL)
+	waitHealthy(t, checker, dead.URL, false)
+	checker.AddServer(healthy.URL + "/")
+	waitHealthy(t, checker, healthy.URL+"/", true)
+}
+
+func TestHealthChecker_AssumeHealthy(t *testing.T) {
+	t.Parallel()
+
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer dead.Close()
+
+	checker := NewHealthChecker(true, time.Hour, healthCheckTimeout, "/health")
+	checker.SetAssumeHealthy(true)
+	checker.AddServer(dead.URL)
+	go checker.Start()
+	defer checker.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+	if !checker.IsHealthy(dead.URL) {
+		t.Error("Expected server to stay healthy until the first interval elapses")
+	}
+}
+
+func waitHealthy(t *testing.T, checker *HealthChecker, address string, want bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for checker.IsHealthy(address) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s to be healthy=%v", address, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHealthChecker_ServerConfig(t *testing.T) {
+	t.Parallel()
+
+	var probes atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		if r.URL.Path == "/ready" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	checker := NewHealthChecker(true, 10*time.Millisecond, healthCheckTimeout, "/health")
+	checker.AddServer(ts.URL)
+
+	// 204 is not accepted by default
+	checker.SetServerConfig(ts.URL, &config.ServiceHealthCheckConfig{Path: "/ready"})
+	checker.checkAllServers()
+	if checker.IsHealthy(ts.URL) {
+		t.Error("Expected 204 to fail the default expected status")
+	}
+
+	checker.SetServerConfig(ts.URL, &config.ServiceHealthCheckConfig{
+		Path:             "/ready",
+		Interval:         time.Hour,
+		ExpectedStatuses: []int{http.StatusNoContent},
+	})
+	checker.checkAllServers()
+	if !checker.IsHealthy(ts.URL) {
+		t.Error("Expected server to be healthy with the overridden path and statuses")
+	}
+
+	// The server is only due again once its own interval has elapsed
+	now := time.Now()
+	probes.Store(0)
+	checker.checkDueServers(now, 10*time.Millisecond)
+	checker.checkDueServers(now.Add(time.Minute), 10*time.Millisecond)
+	if got := probes.Load(); got != 1 {
+		t.Errorf("Expected 1 probe within the overridden interval, got %d", got)
+	}
+	checker.checkDueServers(now.Add(time.Hour), 10*time.Millisecond)
+	if got := probes.Load(); got != 2 {
+		t.Errorf("Expected 2 probes after the overridden interval, got %d", got)
+	}
+
+	// Clearing the override restores the global settings
+	checker.SetServerConfig(ts.URL, nil)
+	checker.checkAllServers()
+	if !checker.IsHealthy(ts.URL) {
+		t.Error("Expected server to be healthy with the global path")
+	}
+	if got := checker.tickInterval(); got != 10*time.Millisecond {
+		t.Errorf("Expected tick interval 10ms, got %v", got)
+	}
+}
+
+func TestHealthChecker_ServerPath(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/legacy/status" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	checker := NewHealthChecker(true, 10*time.Millisecond, healthCheckTimeout, "/health")
+	checker.AddServer(ts.URL)
+	checker.SetServerConfig(ts.URL, &config.ServiceHealthCheckConfig{Path: "/ready"})
+	checker.checkAllServers()
+	if checker.IsHealthy(ts.URL) {
+		t.Error("Expected server to be unhealthy with the service path")
+	}
+
+	// The server path wins over the service path
+	checker.SetServerPath(ts.URL, "/legacy/status")
+	checker.checkAllServers()
+	if !checker.IsHealthy(ts.URL) {
+		t.Error("Expected server to be healthy with its own path")
+	}
+
+	checker.SetServerPath(ts.URL, "")
+	checker.checkAllServers()
+	if checker.IsHealthy(ts.URL) {
+		t.Error("Expected server to be unhealthy once its path is cleared")
+	}
+}
+
 func TestHealthCheckTracing(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,72 @@
+package healthcheck
+
+import "time"
+
+// historySize is the number of probe results kept per server
+const historySize = 32
+
+// ProbeResult is the outcome of one health probe
+type ProbeResult struct {
+	Time      time.Time `json:"time"`
+	LatencyMs float64   `json:"latency_ms"`
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// history is a ring buffer of the latest probe results of a server
+type history struct {
+	results [historySize]ProbeResult
+	next    int
+	count   int
+}
+
+func (h *history) add(result ProbeResult) {
+	h.results[h.next] = result
+	h.next = (h.next + 1) % historySize
+	if h.count < historySize {
+		h.count++
+	}
+}
+
+// list returns the results, most recent first
+func (h *history) list() []ProbeResult {
+	results := make([]ProbeResult, 0, h.count)
+	for i := 1; i <= h.count; i++ {
+		results = append(results, h.results[(h.next-i+historySize)%historySize])
+	}
+	return results
+}
+
+// History returns the latest probe results of a server run by this instance,
+// most recent first. Results adopted from other replicas are not included
+func (h *HealthChecker) History(server string) []ProbeResult {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	info, exists := h.servers[server]
+	if !exists {
+		return nil
+	}
+	return info.history.list()
+}
+
+// Servers returns the addresses of the monitored servers
+func (h *HealthChecker) Servers() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	servers := make([]string, 0, len(h.servers))
+	for address := range h.servers {
+		servers = append(servers, address)
+	}
+	return servers
+}
+
+func (h *HealthChecker) recordProbe(server string, result ProbeResult) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if info, exists := h.servers[server]; exists {
+		info.history.add(result)
+	}
+}
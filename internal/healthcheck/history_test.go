@@ -0,0 +1,30 @@
+package healthcheck
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	var h history
+	if got := h.list(); len(got) != 0 {
+		t.Fatalf("Expected empty history, got %v", got)
+	}
+
+	start := time.Now()
+	for i := 0; i < historySize+5; i++ {
+		h.add(ProbeResult{Time: start.Add(time.Duration(i) * time.Second), Healthy: i%2 == 0})
+	}
+
+	results := h.list()
+	if len(results) != historySize {
+		t.Fatalf("Expected %d results, got %d", historySize, len(results))
+	}
+	// Most recent first, the oldest results were overwritten
+	if want := start.Add(time.Duration(historySize+4) * time.Second); !results[0].Time.Equal(want) {
+		t.Errorf("Expected latest result at %v, got %v", want, results[0].Time)
+	}
+	if want := start.Add(5 * time.Second); !results[historySize-1].Time.Equal(want) {
+		t.Errorf("Expected oldest result at %v, got %v", want, results[historySize-1].Time)
+	}
+}
@@ -0,0 +1,110 @@
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxVersionLength bounds the versions kept per server, longer ones are cut
+const maxVersionLength = 128
+
+// ParseVersion extracts the version from the body of an info endpoint: the
+// version field of a JSON object, or else the first line of the body
+func ParseVersion(body []byte) (string, error) {
+	body = bytes.TrimSpace(body)
+	var version string
+	if bytes.HasPrefix(body, []byte("{")) {
+		var info struct {
+			Version string `json:"version"`
+		}
+		if err := json.Unmarshal(body, &info); err != nil {
+			return "", fmt.Errorf("invalid info response: %w", err)
+		}
+		version = strings.TrimSpace(info.Version)
+	} else {
+		line, _, _ := bytes.Cut(body, []byte("\n"))
+		version = strings.TrimSpace(string(line))
+	}
+
+	if version == "" {
+		return "", errors.New("info response has no version")
+	}
+	if len(version) > maxVersionLength {
+		version = version[:maxVersionLength]
+	}
+	return version, nil
+}
+
+// Version returns the version last reported by the info endpoint of a
+// server, empty when the server has no info path or didn't report one
+func (h *HealthChecker) Version(server string) string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if info := h.servers[server]; info != nil {
+		return info.version
+	}
+	return ""
+}
+
+// refreshInfo asks a healthy server for its version. Unhealthy servers keep
+// the version they reported last, a failed request clears it
+func (h *HealthChecker) refreshInfo(address string, healthy bool, settings checkSettings) {
+	if !healthy || settings.infoPath == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), settings.timeout)
+	defer cancel()
+	version, err := h.fetchVersion(ctx, address, settings.infoPath)
+
+	h.mu.Lock()
+	info, exists := h.servers[address]
+	var previous string
+	var failed bool
+	if exists {
+		previous, failed = info.version, info.infoFailed
+		info.version, info.infoFailed = version, err != nil
+	}
+	h.mu.Unlock()
+
+	switch {
+	case !exists:
+	case err != nil && !failed:
+		serverLogger(address).Warn("Failed to fetch version from %s: %v", settings.infoPath, err)
+	case err == nil && previous != "" && version != previous:
+		serverLogger(address).Info("Version changed from %s to %s", previous, version)
+	}
+}
+
+func (h *HealthChecker) fetchVersion(ctx context.Context, address, path string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", address+path, nil)
+	if err != nil {
+		return "", err
+	}
+
+	h.mu.RLock()
+	client := h.client
+	h.mu.RUnlock()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, probeMaxResponseBytes))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("non-normal status code: %d", resp.StatusCode)
+	}
+
+	return ParseVersion(body)
+}
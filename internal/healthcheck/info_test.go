@@ -0,0 +1,107 @@
+package healthcheck
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+)
+
+func TestParseVersion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{name: "JSON", body: `{"version": "1.4.2", "commit": "abc123"}`, want: "1.4.2"},
+		{name: "PlainText", body: "v2.0.0-rc1\nbuilt 2024-05-01\n", want: "v2.0.0-rc1"},
+		{name: "TooLong", body: strings.Repeat("a", 200), want: strings.Repeat("a", maxVersionLength)},
+		{name: "JSONWithoutVersion", body: `{"commit": "abc123"}`, wantErr: true},
+		{name: "InvalidJSON", body: `{"version":`, wantErr: true},
+		{name: "Empty", body: " \n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseVersion([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected version %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHealthChecker_Version(t *testing.T) {
+	t.Parallel()
+
+	var version atomic.Value
+	version.Store("1.0.0")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+		case "/info":
+			if v := version.Load().(string); v != "" {
+				w.Write([]byte(`{"version": "` + v + `"}`))
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	checker := NewHealthChecker(true, time.Hour, healthCheckTimeout, "/health")
+	checker.AddServer(ts.URL)
+	checker.checkAllServers()
+	if got := checker.Version(ts.URL); got != "" {
+		t.Errorf("Expected no version without info path, got %q", got)
+	}
+
+	checker.SetServerConfig(ts.URL, &config.ServiceHealthCheckConfig{InfoPath: "/info"})
+	checker.checkAllServers()
+	if got := checker.Version(ts.URL); got != "1.0.0" {
+		t.Errorf("Expected version 1.0.0, got %q", got)
+	}
+
+	// A rolling deploy replaces the build
+	version.Store("1.1.0")
+	checker.checkAllServers()
+	if got := checker.Version(ts.URL); got != "1.1.0" {
+		t.Errorf("Expected version 1.1.0, got %q", got)
+	}
+
+	// A failing info endpoint clears the version without failing the probe
+	version.Store("")
+	checker.checkAllServers()
+	if got := checker.Version(ts.URL); got != "" {
+		t.Errorf("Expected version to be cleared, got %q", got)
+	}
+	if !checker.IsHealthy(ts.URL) {
+		t.Error("Expected the info endpoint not to affect health")
+	}
+
+	// Unhealthy servers keep the version they reported last
+	version.Store("1.2.0")
+	checker.checkAllServers()
+	checker.SetServerConfig(ts.URL, &config.ServiceHealthCheckConfig{Path: "/down", InfoPath: "/info"})
+	checker.checkAllServers()
+	if got := checker.Version(ts.URL); got != "1.2.0" || checker.IsHealthy(ts.URL) {
+		t.Errorf("Expected unhealthy server with version 1.2.0, got %q", got)
+	}
+
+	checker.SetServerConfig(ts.URL, nil)
+	if got := checker.Version(ts.URL); got != "" {
+		t.Errorf("Expected version to be cleared with the info path, got %q", got)
+	}
+}
@@ -0,0 +1,236 @@
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+)
+
+const (
+	defaultPushMinInterval = time.Minute
+	defaultPushTimeout     = 5 * time.Second
+)
+
+// ServiceHealth is the health summary of one service
+type ServiceHealth struct {
+	Service   string   `json:"service"`
+	Healthy   int      `json:"healthy"`
+	Total     int      `json:"total"`
+	Unhealthy []string `json:"unhealthy,omitempty"`
+}
+
+// HealthReport is the body pushed to the webhook in the json format
+type HealthReport struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Services  []ServiceHealth `json:"services"`
+}
+
+// Reporter pushes health summaries to a webhook when a server changes state.
+// Changes inside min_interval of the last push are coalesced into one push
+// carrying the latest state, so a flapping backend can't cause an alert storm
+type Reporter struct {
+	cfg      config.HealthPushConfig
+	checker  *HealthChecker
+	services func() map[string][]string // service name -> server addresses
+	client   *http.Client
+	notify   chan struct{}
+	stopChan chan struct{}
+
+	mu       sync.Mutex
+	leader   Leader
+	lastPush time.Time
+	last     []ServiceHealth
+}
+
+// Leader reports whether this replica does the shared work of a fleet
+type Leader interface {
+	IsLeader() bool
+}
+
+// NewReporter creates a push reporter, nil when no webhook is configured
+func NewReporter(cfg config.HealthPushConfig, checker *HealthChecker, services func() map[string][]string) *Reporter {
+	if cfg.URL == "" || checker == nil {
+		return nil
+	}
+	if cfg.MinInterval <= 0 {
+		cfg.MinInterval = defaultPushMinInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultPushTimeout
+	}
+
+	return &Reporter{
+		cfg:      cfg,
+		checker:  checker,
+		services: services,
+		client:   &http.Client{Timeout: cfg.Timeout},
+		notify:   make(chan struct{}, 1),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// SetLeader restricts pushes to the leader of the fleet, other replicas
+// skip them. Call Notify when this replica becomes leader to push the current
+// state
+func (r *Reporter) SetLeader(leader Leader) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.leader = leader
+}
+
+// Notify signals a state change, it never blocks
+func (r *Reporter) Notify() {
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the push loop
+func (r *Reporter) Start() {
+	var timer *time.Timer
+	var timerC <-chan time.Time
+	for {
+		select {
+		case <-r.notify:
+			if timer != nil {
+				// A push is already scheduled and will carry this change
+				continue
+			}
+			r.mu.Lock()
+			wait := r.cfg.MinInterval - time.Since(r.lastPush)
+			r.mu.Unlock()
+			if wait <= 0 {
+				r.push()
+				continue
+			}
+			timer = time.NewTimer(wait)
+			timerC = timer.C
+		case <-timerC:
+			timer, timerC = nil, nil
+			r.push()
+		case <-r.stopChan:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+	}
+}
+
+// Stop terminates the push loop
+func (r *Reporter) Stop() {
+	close(r.stopChan)
+}
+
+// Summary returns the current health of every service
+func (r *Reporter) Summary() []ServiceHealth {
+	services := r.services()
+	summary := make([]ServiceHealth, 0, len(services))
+	for name, servers := range services {
+		health := ServiceHealth{Service: name, Total: len(servers)}
+		for _, server := range servers {
+			if r.checker.IsHealthy(server) {
+				health.Healthy++
+			} else {
+				health.Unhealthy = append(health.Unhealthy, server)
+			}
+		}
+		sort.Strings(health.Unhealthy)
+		summary = append(summary, health)
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		return summary[i].Service < summary[j].Service
+	})
+
+	return summary
+}
+
+// push sends the summary unless it is the one pushed last
+func (r *Reporter) push() {
+	r.mu.Lock()
+	if r.leader != nil && !r.leader.IsLeader() {
+		// The leader pushes meanwhile, a new leadership starts with a push
+		r.last = nil
+		r.mu.Unlock()
+		return
+	}
+	r.mu.Unlock()
+
+	summary := r.Summary()
+
+	r.mu.Lock()
+	unchanged := reflect.DeepEqual(summary, r.last)
+	r.mu.Unlock()
+	if unchanged {
+		return
+	}
+
+	body, err := r.payload(summary)
+	if err != nil {
+		lg.GetInstance().Error("Failed to encode health report: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		lg.GetInstance().Error("Failed to create health report request: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// The attempt counts against min_interval even when it fails
+	r.mu.Lock()
+	r.lastPush = time.Now()
+	r.mu.Unlock()
+
+	// Failed pushes are retried after min_interval
+	resp, err := r.client.Do(req)
+	if err != nil {
+		lg.GetInstance().Warn("Failed to push health report to %s: %v", r.cfg.URL, err)
+		r.Notify()
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		lg.GetInstance().Warn("Health report webhook %s returned status %d", r.cfg.URL, resp.StatusCode)
+		r.Notify()
+		return
+	}
+
+	r.mu.Lock()
+	r.last = summary
+	r.mu.Unlock()
+}
+
+// payload encodes the summary in the configured format
+func (r *Reporter) payload(summary []ServiceHealth) ([]byte, error) {
+	if r.cfg.Format != "slack" {
+		return json.Marshal(HealthReport{Timestamp: time.Now().UTC(), Services: summary})
+	}
+
+	lines := make([]string, 0, len(summary)+1)
+	lines = append(lines, "Nexus backend health changed:")
+	for _, health := range summary {
+		line := fmt.Sprintf("• %s: %d/%d healthy", health.Service, health.Healthy, health.Total)
+		if len(health.Unhealthy) > 0 {
+			line += " (down: " + strings.Join(health.Unhealthy, ", ") + ")"
+		}
+		lines = append(lines, line)
+	}
+
+	return json.Marshal(map[string]string{"text": strings.Join(lines, "\n")})
+}
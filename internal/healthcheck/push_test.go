@@ -0,0 +1,155 @@
+package healthcheck
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhook records the bodies pushed to it
+type webhook struct {
+	mu     sync.Mutex
+	bodies [][]byte
+	status int
+}
+
+func (h *webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.bodies = append(h.bodies, body)
+	if h.status != 0 {
+		w.WriteHeader(h.status)
+	}
+}
+
+func (h *webhook) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.bodies)
+}
+
+func (h *webhook) report(t *testing.T, i int) HealthReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var report HealthReport
+	require.NoError(t, json.Unmarshal(h.bodies[i], &report))
+	return report
+}
+
+func newPushTest(t *testing.T, hook *webhook, format string) (*HealthChecker, *Reporter) {
+	server := httptest.NewServer(hook)
+	t.Cleanup(server.Close)
+
+	checker := NewHealthChecker(true, time.Hour, time.Second, "/health")
+	checker.SetAssumeHealthy(true)
+	checker.AddServer("http://backend1")
+	checker.AddServer("http://backend2")
+	reporter := NewReporter(config.HealthPushConfig{
+		URL:         server.URL,
+		Format:      format,
+		MinInterval: 200 * time.Millisecond,
+	}, checker, func() map[string][]string {
+		return map[string][]string{"api": {"http://backend1", "http://backend2"}}
+	})
+	checker.SetReporter(reporter)
+	go reporter.Start()
+	t.Cleanup(reporter.Stop)
+
+	return checker, reporter
+}
+
+func TestReporter_Disabled(t *testing.T) {
+	assert.Nil(t, NewReporter(config.HealthPushConfig{}, NewHealthChecker(true, time.Second, time.Second, "/"), nil))
+}
+
+func TestReporter_CoalescesChanges(t *testing.T) {
+	hook := &webhook{}
+	checker, _ := newPushTest(t, hook, "json")
+
+	// The first change is pushed right away
+	checker.UpdateServerStatus("http://backend1", false)
+	require.Eventually(t, func() bool { return hook.count() == 1 }, time.Second, 5*time.Millisecond)
+	report := hook.report(t, 0)
+	require.Len(t, report.Services, 1)
+	assert.Equal(t, ServiceHealth{Service: "api", Healthy: 1, Total: 2, Unhealthy: []string{"http://backend1"}}, report.Services[0])
+
+	// Flapping within min_interval results in a single push of the latest state
+	checker.UpdateServerStatus("http://backend1", true)
+	checker.UpdateServerStatus("http://backend2", false)
+	checker.UpdateServerStatus("http://backend1", false)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, hook.count())
+
+	require.Eventually(t, func() bool { return hook.count() == 2 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 0, hook.report(t, 1).Services[0].Healthy)
+
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, 2, hook.count())
+}
+
+func TestReporter_SkipsUnchangedSummary(t *testing.T) {
+	hook := &webhook{}
+	checker, _ := newPushTest(t, hook, "json")
+
+	checker.UpdateServerStatus("http://backend1", false)
+	require.Eventually(t, func() bool { return hook.count() == 1 }, time.Second, 5*time.Millisecond)
+
+	// Back and forth ends in the state already pushed
+	checker.UpdateServerStatus("http://backend1", true)
+	checker.UpdateServerStatus("http://backend1", false)
+	time.Sleep(400 * time.Millisecond)
+	assert.Equal(t, 1, hook.count())
+}
+
+func TestReporter_RetriesFailedPush(t *testing.T) {
+	hook := &webhook{status: http.StatusInternalServerError}
+	checker, _ := newPushTest(t, hook, "json")
+
+	checker.UpdateServerStatus("http://backend1", false)
+	require.Eventually(t, func() bool { return hook.count() == 2 }, time.Second, 5*time.Millisecond)
+}
+
+func TestReporter_SlackFormat(t *testing.T) {
+	hook := &webhook{}
+	checker, _ := newPushTest(t, hook, "slack")
+
+	checker.UpdateServerStatus("http://backend2", false)
+	require.Eventually(t, func() bool { return hook.count() == 1 }, time.Second, 5*time.Millisecond)
+
+	var message map[string]string
+	require.NoError(t, json.Unmarshal(hook.bodies[0], &message))
+	assert.Equal(t, "Nexus backend health changed:\n• api: 1/2 healthy (down: http://backend2)", message["text"])
+}
+
+// leadership is a switchable fleet leadership
+type leadership struct{ leader atomic.Bool }
+
+func (l *leadership) IsLeader() bool { return l.leader.Load() }
+
+func TestReporter_OnlyLeaderPushes(t *testing.T) {
+	hook := &webhook{}
+	checker, reporter := newPushTest(t, hook, "json")
+	leader := &leadership{}
+	reporter.SetLeader(leader)
+
+	checker.UpdateServerStatus("http://backend1", false)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 0, hook.count())
+
+	// A new leader pushes the current state
+	leader.leader.Store(true)
+	reporter.Notify()
+	require.Eventually(t, func() bool { return hook.count() == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 1, hook.report(t, 0).Services[0].Healthy)
+}
@@ -0,0 +1,62 @@
+package healthcheck
+
+import (
+	"nexus/internal/config"
+	"nexus/internal/route"
+)
+
+// DrainReason marks servers drained after failing health checks
+const DrainReason = "unhealthy"
+
+// Rotation takes servers failing health checks out of the services listing
+// them and returns them once they pass again
+type Rotation struct {
+	router  route.Router
+	checker *HealthChecker
+}
+
+// NewRotation subscribes to the checker's transitions and applies the current
+// health of all servers
+func NewRotation(router route.Router, checker *HealthChecker) *Rotation {
+	r := &Rotation{router: router, checker: checker}
+	checker.OnStatusChange(r.apply)
+	r.Sync()
+
+	return r
+}
+
+// Sync applies the current health of all configured servers, e.g. after a
+// reload added services or servers
+func (r *Rotation) Sync() {
+	for _, svc := range r.router.Services() {
+		for _, server := range svc.Config().Servers {
+			r.apply(server.Address, r.checker.IsHealthy(server.Address))
+		}
+	}
+}
+
+func (r *Rotation) apply(server string, healthy bool) {
+	for name, svc := range r.router.Services() {
+		if !listsServer(svc.Config().Servers, server) {
+			continue
+		}
+		var err error
+		if healthy {
+			err = svc.Undrain(server, DrainReason)
+		} else {
+			err = svc.Drain(server, DrainReason)
+		}
+		if err != nil {
+			serverLogger(server).Warn("Failed to update rotation of service %s: %v", name, err)
+		}
+	}
+}
+
+func listsServer(servers []config.ServerConfig, address string) bool {
+	for _, server := range servers {
+		if server.Address == address {
+			return true
+		}
+	}
+	return false
+}
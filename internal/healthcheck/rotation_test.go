@@ -0,0 +1,41 @@
+package healthcheck
+
+import (
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotation(t *testing.T) {
+	router := route.NewRouter(nil, map[string]*config.ServiceConfig{
+		"api": {Name: "api", BalancerType: "round_robin", Servers: []config.ServerConfig{
+			{Address: "http://backend1"},
+			{Address: "http://backend2"},
+		}},
+		"web": {Name: "web", BalancerType: "round_robin", Servers: []config.ServerConfig{
+			{Address: "http://backend1"},
+		}},
+	})
+	checker := NewHealthChecker(true, time.Hour, time.Second, "/health")
+	checker.AddServer("http://backend1")
+	checker.AddServer("http://backend2")
+	checker.UpdateServerStatus("http://backend2", true)
+
+	// Servers not probed yet start out of rotation
+	NewRotation(router, checker)
+	services := router.Services()
+	assert.Equal(t, map[string][]string{"http://backend1": {DrainReason}}, services["api"].Drained())
+	assert.Equal(t, map[string][]string{"http://backend1": {DrainReason}}, services["web"].Drained())
+
+	checker.UpdateServerStatus("http://backend1", true)
+	assert.Empty(t, services["api"].Drained())
+	assert.Empty(t, services["web"].Drained())
+
+	checker.UpdateServerStatus("http://backend2", false)
+	assert.Equal(t, map[string][]string{"http://backend2": {DrainReason}}, services["api"].Drained())
+	assert.Empty(t, services["web"].Drained())
+}
@@ -0,0 +1,106 @@
+package healthcheck
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/redis"
+)
+
+// SharedState distributes probes and their results between nexus replicas,
+// so each backend is probed by one replica per interval
+type SharedState interface {
+	// Acquire claims the probe of a server for ttl, it returns false when
+	// another replica already holds the claim
+	Acquire(ctx context.Context, server string, ttl time.Duration) (bool, error)
+	// Publish shares a probe result for ttl
+	Publish(ctx context.Context, server string, healthy bool, ttl time.Duration) error
+	// Lookup returns the latest shared result, ok is false when none is known
+	Lookup(ctx context.Context, server string) (healthy bool, ok bool, err error)
+}
+
+const defaultSharedPrefix = "nexus:health:"
+
+// RedisSharedState shares health state through Redis keys with expiry
+type RedisSharedState struct {
+	client *redis.Client
+	prefix string
+	id     string
+}
+
+// NewSharedState creates the shared state described by the config, nil when sharing is disabled
+func NewSharedState(cfg config.SharedHealthConfig) SharedState {
+	switch cfg.Store {
+	case "redis":
+		return NewRedisSharedState(cfg.Redis)
+	default:
+		return nil
+	}
+}
+
+// NewRedisSharedState creates a Redis backed shared health state
+func NewRedisSharedState(cfg config.RedisConfig) *RedisSharedState {
+	prefix := cfg.KeyPrefix
+	if prefix == "" {
+		prefix = defaultSharedPrefix
+	}
+
+	// Identifies this replica as the holder of probe claims
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	return &RedisSharedState{
+		client: redis.NewClient(cfg),
+		prefix: prefix,
+		id:     hex.EncodeToString(id),
+	}
+}
+
+// Acquire implements the SharedState interface
+func (s *RedisSharedState) Acquire(ctx context.Context, server string, ttl time.Duration) (bool, error) {
+	reply, err := s.client.Do(ctx, "SET", s.prefix+"claim:"+server, s.id, "NX", "PX", milliseconds(ttl))
+	if err != nil {
+		return false, err
+	}
+
+	// SET NX replies OK when the claim was taken and nil otherwise
+	return reply == "OK", nil
+}
+
+// Publish implements the SharedState interface
+func (s *RedisSharedState) Publish(ctx context.Context, server string, healthy bool, ttl time.Duration) error {
+	_, err := s.client.Do(ctx, "SET", s.prefix+"status:"+server, strconv.FormatBool(healthy), "PX", milliseconds(ttl))
+	return err
+}
+
+// Lookup implements the SharedState interface
+func (s *RedisSharedState) Lookup(ctx context.Context, server string) (bool, bool, error) {
+	reply, err := s.client.Do(ctx, "GET", s.prefix+"status:"+server)
+	if err != nil || reply == nil {
+		return false, false, err
+	}
+
+	value, ok := reply.(string)
+	if !ok {
+		return false, false, fmt.Errorf("unexpected redis reply: %v", reply)
+	}
+	healthy, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, false, err
+	}
+
+	return healthy, true, nil
+}
+
+func milliseconds(d time.Duration) string {
+	ms := d.Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	return strconv.FormatInt(ms, 10)
+}
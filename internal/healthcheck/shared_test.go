@@ -0,0 +1,156 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/redis/redistest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySharedState is an in-process SharedState, claims never expire
+type memorySharedState struct {
+	mu      sync.Mutex
+	claims  map[string]bool
+	results map[string]bool
+	err     error
+}
+
+func newMemorySharedState() *memorySharedState {
+	return &memorySharedState{claims: make(map[string]bool), results: make(map[string]bool)}
+}
+
+func (m *memorySharedState) Acquire(ctx context.Context, server string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return false, m.err
+	}
+	if m.claims[server] {
+		return false, nil
+	}
+	m.claims[server] = true
+	return true, nil
+}
+
+func (m *memorySharedState) Publish(ctx context.Context, server string, healthy bool, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.results[server] = healthy
+	return m.err
+}
+
+func (m *memorySharedState) Lookup(ctx context.Context, server string) (bool, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	healthy, ok := m.results[server]
+	return healthy, ok, m.err
+}
+
+func TestHealthChecker_SharedState(t *testing.T) {
+	var probes int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&probes, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer backend.Close()
+
+	shared := newMemorySharedState()
+	replicas := make([]*HealthChecker, 3)
+	for i := range replicas {
+		replicas[i] = NewHealthChecker(true, time.Second, time.Second, "/health")
+		replicas[i].SetSharedState(shared)
+		replicas[i].AddServer(backend.URL)
+	}
+
+	for _, hc := range replicas {
+		hc.checkAllServers()
+	}
+
+	// Only the replica holding the claim probes, the others adopt its result
+	assert.Equal(t, int32(1), atomic.LoadInt32(&probes))
+	for _, hc := range replicas {
+		assert.False(t, hc.IsHealthy(backend.URL))
+	}
+}
+
+func TestHealthChecker_SharedStateUnavailable(t *testing.T) {
+	var probes int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&probes, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	shared := newMemorySharedState()
+	shared.err = errors.New("connection refused")
+	for i := 0; i < 2; i++ {
+		hc := NewHealthChecker(true, time.Second, time.Second, "/health")
+		hc.SetSharedState(shared)
+		hc.AddServer(backend.URL)
+		hc.checkAllServers()
+		assert.True(t, hc.IsHealthy(backend.URL))
+	}
+
+	// Every replica falls back to probing on its own
+	assert.Equal(t, int32(2), atomic.LoadInt32(&probes))
+}
+
+func TestRedisSharedState(t *testing.T) {
+	values := make(map[string]string)
+	server := redistest.NewServer(t, func(args []string) string {
+		switch args[0] {
+		case "SET":
+			if len(args) > 3 && strings.EqualFold(args[3], "NX") {
+				if _, exists := values[args[1]]; exists {
+					return "$-1\r\n"
+				}
+			}
+			values[args[1]] = args[2]
+			return "+OK\r\n"
+		case "GET":
+			if v, ok := values[args[1]]; ok {
+				return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+			}
+			return "$-1\r\n"
+		default:
+			return "-ERR unknown command\r\n"
+		}
+	})
+
+	first := NewRedisSharedState(config.RedisConfig{Address: server.Addr()})
+	second := NewRedisSharedState(config.RedisConfig{Address: server.Addr()})
+	ctx := context.Background()
+
+	claimed, err := first.Acquire(ctx, "http://backend1", time.Second)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = second.Acquire(ctx, "http://backend1", time.Second)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	_, ok, err := second.Lookup(ctx, "http://backend1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, first.Publish(ctx, "http://backend1", true, 3*time.Second))
+	healthy, ok, err := second.Lookup(ctx, "http://backend1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, healthy)
+	assert.Equal(t, first.id, values[defaultSharedPrefix+"claim:http://backend1"])
+}
@@ -0,0 +1,165 @@
+package healthcheck
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+	"nexus/internal/service"
+)
+
+// WarmupDrainReason marks servers kept out of rotation until their warm-up
+// requests completed
+const WarmupDrainReason = "warming_up"
+
+// defaultWarmupTimeout bounds warm-up requests when the service sets no timeout
+const defaultWarmupTimeout = 5 * time.Second
+
+// Warmup sends the warm-up requests of their service to servers added by a
+// reload, they enter rotation once all requests completed, failed or not.
+// Warm-up doesn't replace health checks, failing servers are drained by them
+type Warmup struct {
+	router route.Router
+	client *http.Client
+
+	mu    sync.Mutex
+	known map[string]map[string]bool // service -> servers already in rotation or warming up
+	wg    sync.WaitGroup
+}
+
+// NewWarmup creates a warm-up of the servers added after the current ones,
+// requests use the health check TLS config
+func NewWarmup(router route.Router, cfg config.HealthCheckTLSConfig) (*Warmup, error) {
+	client, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Warmup{router: router, client: client, known: make(map[string]map[string]bool)}
+	for name, svc := range router.Services() {
+		w.known[name] = serverSet(svc.Config().Servers)
+	}
+
+	return w, nil
+}
+
+// Sync starts warming up the servers added since the last sync, e.g. after a
+// reload. Services configured without warm-up add their servers right away
+func (w *Warmup) Sync() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	known := make(map[string]map[string]bool)
+	for name, svc := range w.router.Services() {
+		cfg := svc.Config()
+		known[name] = serverSet(cfg.Servers)
+		if cfg.Warmup == nil {
+			continue
+		}
+		for _, server := range cfg.Servers {
+			if w.known[name][server.Address] {
+				continue
+			}
+			if err := svc.Drain(server.Address, WarmupDrainReason); err != nil {
+				serverLogger(server.Address).Warn("Failed to drain server of service %s for warm-up: %v", name, err)
+				continue
+			}
+			w.wg.Add(1)
+			go func(svc service.Service, address string, cfg config.WarmupConfig) {
+				defer w.wg.Done()
+				w.warm(svc, address, cfg)
+			}(svc, server.Address, *cfg.Warmup)
+		}
+	}
+	w.known = known
+}
+
+// Wait waits for the started warm-ups to complete
+func (w *Warmup) Wait() {
+	w.wg.Wait()
+}
+
+// warm sends the warm-up requests to a server, then returns it to rotation
+func (w *Warmup) warm(svc service.Service, address string, cfg config.WarmupConfig) {
+	requests := max(cfg.Requests, 1)
+	concurrency := max(cfg.Concurrency, 1)
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultWarmupTimeout
+	}
+
+	start := time.Now()
+	var failed atomic.Int64
+	paths := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				if !w.request(address, path, cfg.Headers, timeout) {
+					failed.Add(1)
+				}
+			}
+		}()
+	}
+	for i := 0; i < requests; i++ {
+		for _, path := range cfg.Paths {
+			paths <- path
+		}
+	}
+	close(paths)
+	wg.Wait()
+
+	total := requests * len(cfg.Paths)
+	serverLogger(address).Info("Warmed up server of service %s with %d requests in %s, %d failed",
+		svc.Name(), total, time.Since(start).Round(time.Millisecond), failed.Load())
+	// Servers removed by another reload meanwhile are not found
+	if err := svc.Undrain(address, WarmupDrainReason); err != nil {
+		serverLogger(address).Debug("Warmed up server left service %s: %v", svc.Name(), err)
+	}
+}
+
+// request sends one warm-up request, reporting whether the server answered
+// without a server error
+func (w *Warmup) request(address, path string, headers map[string]string, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(address, "/")+path, nil)
+	if err != nil {
+		serverLogger(address).Debug("Invalid warm-up request %s: %v", path, err)
+		return false
+	}
+	for name, value := range headers {
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		serverLogger(address).Debug("Warm-up request %s failed: %v", path, err)
+		return false
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, probeMaxResponseBytes))
+
+	return resp.StatusCode < http.StatusInternalServerError
+}
+
+func serverSet(servers []config.ServerConfig) map[string]bool {
+	set := make(map[string]bool, len(servers))
+	for _, server := range servers {
+		set[server.Address] = true
+	}
+	return set
+}
@@ -0,0 +1,63 @@
+package healthcheck
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmup(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	warming := make(chan struct{}, 16)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		assert.Equal(t, "warmup", r.Header.Get("X-Purpose"))
+		warming <- struct{}{}
+		<-release
+	}))
+	defer backend.Close()
+
+	services := func(servers ...string) map[string]*config.ServiceConfig {
+		svc := &config.ServiceConfig{
+			Name:         "api",
+			BalancerType: "round_robin",
+			Warmup: &config.WarmupConfig{
+				Paths:       []string{"/", "/search"},
+				Requests:    2,
+				Concurrency: 2,
+				Headers:     map[string]string{"X-Purpose": "warmup"},
+			},
+		}
+		for _, server := range servers {
+			svc.Servers = append(svc.Servers, config.ServerConfig{Address: server})
+		}
+		return map[string]*config.ServiceConfig{"api": svc}
+	}
+	router := route.NewRouter(nil, services("http://existing"))
+	warmup, err := NewWarmup(router, config.HealthCheckTLSConfig{})
+	require.NoError(t, err)
+
+	// Servers present at the start are not warmed up
+	warmup.Sync()
+	assert.Empty(t, router.Services()["api"].Drained())
+
+	require.NoError(t, router.Update(nil, services("http://existing", backend.URL)))
+	warmup.Sync()
+	<-warming
+	assert.Equal(t, map[string][]string{backend.URL: {WarmupDrainReason}}, router.Services()["api"].Drained())
+
+	// Syncing again doesn't warm up the server twice
+	warmup.Sync()
+	close(release)
+	warmup.Wait()
+	assert.Equal(t, int32(4), requests.Load())
+	assert.Empty(t, router.Services()["api"].Drained())
+}
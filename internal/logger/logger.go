@@ -1,21 +1,47 @@
 package logger
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 )
 
-// Logger struct encapsulates logging functionality
+// Logger struct encapsulates logging functionality. Loggers derived with With
+// share the level, output and hook of their parent
 type Logger struct {
+	*core
+	fields []Field // Written after the message of every log
+}
+
+// core is the state shared by a logger and the loggers derived from it
+type core struct {
 	mu       sync.RWMutex
 	logger   *log.Logger
 	level    LogLevel
 	exitFunc func(int) // Add exit function field
+	hook     func(level LogLevel, msg string, fields []Field)
+}
+
+// Field is a key and value attached to the logs of a logger, such as the ID
+// or the route of a request
+type Field struct {
+	Key   string
+	Value interface{}
 }
 
+// F returns a field
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// contextKey is the context key of request-scoped loggers
+type contextKey struct{}
+
 // LogLevel defines the type for log levels
 type LogLevel int
 
@@ -63,11 +89,59 @@ func (l *Logger) ToLogLevel(level string) LogLevel {
 
 // newLogger creates a new logger instance
 func newLogger(level LogLevel) *Logger {
-	return &Logger{
+	return &Logger{core: &core{
 		logger:   log.New(os.Stdout, "", log.LstdFlags|log.Lshortfile),
 		level:    level,
 		exitFunc: os.Exit, // Default to os.Exit
+	}}
+}
+
+// With returns a logger adding fields to the ones of l, a field replaces the
+// one of l with the same key
+func (l *Logger) With(fields ...Field) *Logger {
+	if len(fields) == 0 {
+		return l
+	}
+
+	merged := make([]Field, len(l.fields), len(l.fields)+len(fields))
+	copy(merged, l.fields)
+	for _, field := range fields {
+		replaced := false
+		for i := range merged {
+			if merged[i].Key == field.Key {
+				merged[i], replaced = field, true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, field)
+		}
 	}
+	return &Logger{core: l.core, fields: merged}
+}
+
+// Fields returns the fields of the logger
+func (l *Logger) Fields() []Field {
+	return l.fields
+}
+
+// NewContext returns a copy of ctx carrying l, see FromContext
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, the global logger when it
+// carries none
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
+	}
+	return GetInstance()
+}
+
+// WithFields returns a copy of ctx carrying its logger with fields added
+func WithFields(ctx context.Context, fields ...Field) context.Context {
+	return NewContext(ctx, FromContext(ctx).With(fields...))
 }
 
 // SetLevel sets the logging level
@@ -94,13 +168,46 @@ func (l *Logger) SetExitFunc(f func(int)) {
 	l.exitFunc = f
 }
 
+// SetHook sets a function receiving the message and fields of every log
+// written, e.g. to export it. It runs on the logging goroutine and must not
+// block
+func (l *Logger) SetHook(hook func(level LogLevel, msg string, fields []Field)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.hook = hook
+}
+
+// output writes a log with its level tag and fields and passes it to the hook
+func (l *Logger) output(level LogLevel, tag, format string, v []interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	l.logger.Output(3, tag+msg+formatFields(l.fields))
+	if l.hook != nil {
+		l.hook(level, msg, l.fields)
+	}
+}
+
+// formatFields writes fields as key=value pairs, quoting the values with
+// spaces, quotes or equal signs
+func formatFields(fields []Field) string {
+	var b strings.Builder
+	for _, field := range fields {
+		value := fmt.Sprint(field.Value)
+		if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+			value = strconv.Quote(value)
+		}
+		b.WriteString(" " + field.Key + "=" + value)
+	}
+	return b.String()
+}
+
 // Debug outputs debug level logs
 func (l *Logger) Debug(format string, v ...interface{}) {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
 
 	if l.level <= LevelDebug {
-		l.logger.Printf("[DEBUG] "+format, v...)
+		l.output(LevelDebug, "[DEBUG] ", format, v)
 	}
 }
 
@@ -110,7 +217,7 @@ func (l *Logger) Info(format string, v ...interface{}) {
 	defer l.mu.RUnlock()
 
 	if l.level <= LevelInfo {
-		l.logger.Printf("[INFO] "+format, v...)
+		l.output(LevelInfo, "[INFO] ", format, v)
 	}
 }
 
@@ -120,7 +227,7 @@ func (l *Logger) Warn(format string, v ...interface{}) {
 	defer l.mu.RUnlock()
 
 	if l.level <= LevelWarn {
-		l.logger.Printf("[WARN] "+format, v...)
+		l.output(LevelWarn, "[WARN] ", format, v)
 	}
 }
 
@@ -130,7 +237,7 @@ func (l *Logger) Error(format string, v ...interface{}) {
 	defer l.mu.RUnlock()
 
 	if l.level <= LevelError {
-		l.logger.Printf("[ERROR] "+format, v...)
+		l.output(LevelError, "[ERROR] ", format, v)
 	}
 }
 
@@ -140,7 +247,7 @@ func (l *Logger) Fatal(format string, v ...interface{}) {
 	defer l.mu.RUnlock()
 
 	if l.level <= LevelFatal {
-		l.logger.Printf("[FATAL] "+format, v...)
+		l.output(LevelFatal, "[FATAL] ", format, v)
 		l.exitFunc(1) // Use custom exit function
 	}
 }
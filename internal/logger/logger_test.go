@@ -2,6 +2,7 @@ package logger
 
 import (
 	"bytes"
+	"context"
 	"testing"
 )
 
@@ -158,3 +159,48 @@ func TestLogger_UpdateLevel(t *testing.T) {
 		t.Errorf("Expected log level Debug, got %v", logger.Level())
 	}
 }
+
+func TestLogger_With(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(LevelInfo)
+	logger.SetOutput(&buf)
+	var hooked []Field
+	logger.SetHook(func(level LogLevel, msg string, fields []Field) { hooked = fields })
+
+	request := logger.With(F("request_id", "abc"), F("route", "api"))
+	backend := request.With(F("backend", "http://10.0.0.1:8080"), F("route", "api v2"))
+	backend.Warn("upstream failed: %s", "timeout")
+
+	expected := `[WARN] upstream failed: timeout request_id=abc route="api v2" backend=http://10.0.0.1:8080`
+	if !bytes.Contains(buf.Bytes(), []byte(expected)) {
+		t.Errorf("Expected log message %q, got %q", expected, buf.String())
+	}
+	if len(hooked) != 3 || hooked[1] != F("route", "api v2") {
+		t.Errorf("Expected the hook to receive the fields, got %v", hooked)
+	}
+	if len(request.Fields()) != 2 || request.Fields()[1] != F("route", "api") {
+		t.Errorf("Expected derived loggers to leave their parent unchanged, got %v", request.Fields())
+	}
+
+	// Derived loggers share the level of their parent
+	buf.Reset()
+	logger.SetLevel(LevelError)
+	backend.Warn(testLogMessage)
+	if buf.Len() > 0 {
+		t.Errorf("Expected the warning to be filtered, got %q", buf.String())
+	}
+}
+
+func TestLogger_Context(t *testing.T) {
+	ctx := context.Background()
+	if FromContext(ctx) != GetInstance() {
+		t.Error("Expected the global logger for contexts without one")
+	}
+
+	ctx = WithFields(ctx, F("request_id", "abc"))
+	ctx = WithFields(ctx, F("route", "api"))
+	fields := FromContext(ctx).Fields()
+	if len(fields) != 2 || fields[0] != F("request_id", "abc") || fields[1] != F("route", "api") {
+		t.Errorf("Expected the fields of the context, got %v", fields)
+	}
+}
@@ -0,0 +1,182 @@
+package maintenance
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/healthcheck"
+	lg "nexus/internal/logger"
+	"nexus/internal/route"
+	"nexus/internal/schedule"
+)
+
+// DrainReason marks servers drained by a maintenance window
+const DrainReason = "maintenance"
+
+// Scheduler drains servers during their maintenance windows and re-enables them afterwards
+type Scheduler struct {
+	mu            sync.RWMutex
+	router        route.Router
+	healthChecker *healthcheck.HealthChecker
+	interval      time.Duration
+	now           func() time.Time
+	active        map[backendKey]bool
+	stopChan      chan struct{}
+}
+
+type backendKey struct {
+	service string
+	address string
+}
+
+// Status describes the maintenance state of a server
+type Status struct {
+	Service string                     `json:"service"`
+	Address string                     `json:"address"`
+	Active  bool                       `json:"active"`
+	Windows []config.MaintenanceWindow `json:"windows"`
+}
+
+// NewScheduler creates a maintenance scheduler, healthChecker may be nil
+func NewScheduler(router route.Router, healthChecker *healthcheck.HealthChecker, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		router:        router,
+		healthChecker: healthChecker,
+		interval:      interval,
+		now:           time.Now,
+		active:        make(map[backendKey]bool),
+		stopChan:      make(chan struct{}),
+	}
+}
+
+// Start begins evaluating maintenance windows
+func (s *Scheduler) Start() {
+	s.Check()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Check()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// Stop terminates the scheduler
+func (s *Scheduler) Stop() {
+	close(s.stopChan)
+}
+
+// Check applies the current maintenance state to every configured server
+func (s *Scheduler) Check() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	seen := make(map[backendKey]bool)
+	logger := lg.GetInstance()
+
+	for name, svc := range s.router.Services() {
+		for _, server := range svc.Config().Servers {
+			key := backendKey{service: name, address: server.Address}
+			seen[key] = true
+
+			active := InWindow(server.Maintenance, now)
+			if active == s.active[key] {
+				continue
+			}
+
+			if active {
+				if err := svc.Drain(server.Address, DrainReason); err != nil {
+					logger.Error("[%s] Failed to start maintenance: %v", server.Address, err)
+					continue
+				}
+				logger.Info("[%s] Maintenance window started, server drained from service %s", server.Address, name)
+			} else {
+				if err := svc.Undrain(server.Address, DrainReason); err != nil {
+					logger.Error("[%s] Failed to end maintenance: %v", server.Address, err)
+					continue
+				}
+				logger.Info("[%s] Maintenance window ended, server re-enabled in service %s", server.Address, name)
+			}
+
+			if s.healthChecker != nil {
+				s.healthChecker.SetPaused(server.Address, active)
+			}
+			s.active[key] = active
+		}
+	}
+
+	// Drop state of servers removed by config reloads
+	for key := range s.active {
+		if !seen[key] {
+			delete(s.active, key)
+		}
+	}
+}
+
+// Statuses returns the maintenance state of every server that declares windows
+func (s *Scheduler) Statuses() []Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	statuses := make([]Status, 0)
+	for name, svc := range s.router.Services() {
+		for _, server := range svc.Config().Servers {
+			if len(server.Maintenance) == 0 {
+				continue
+			}
+			statuses = append(statuses, Status{
+				Service: name,
+				Address: server.Address,
+				Active:  s.active[backendKey{service: name, address: server.Address}],
+				Windows: server.Maintenance,
+			})
+		}
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Service != statuses[j].Service {
+			return statuses[i].Service < statuses[j].Service
+		}
+		return statuses[i].Address < statuses[j].Address
+	})
+
+	return statuses
+}
+
+// InWindow reports whether t falls within any of the maintenance windows
+func InWindow(windows []config.MaintenanceWindow, t time.Time) bool {
+	for _, w := range windows {
+		if windowActive(w, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func windowActive(w config.MaintenanceWindow, t time.Time) bool {
+	if w.Cron == "" {
+		return !t.Before(w.Start) && t.Before(w.End)
+	}
+
+	cron, err := schedule.ParseCron(w.Cron)
+	if err != nil {
+		return false
+	}
+	loc := time.UTC
+	if w.Timezone != "" {
+		if l, err := time.LoadLocation(w.Timezone); err == nil {
+			loc = l
+		}
+	}
+
+	return cron.ActiveWithin(t.In(loc), w.Duration)
+}
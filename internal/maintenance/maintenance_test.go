@@ -0,0 +1,109 @@
+package maintenance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/healthcheck"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInWindow(t *testing.T) {
+	start := time.Date(2025, 3, 14, 2, 0, 0, 0, time.UTC)
+	fixed := config.MaintenanceWindow{Start: start, End: start.Add(time.Hour)}
+	nightly := config.MaintenanceWindow{Cron: "0 3 * * *", Duration: 30 * time.Minute, Timezone: "Asia/Shanghai"}
+
+	tests := []struct {
+		name   string
+		window config.MaintenanceWindow
+		at     time.Time
+		active bool
+	}{
+		{"FixedBefore", fixed, start.Add(-time.Second), false},
+		{"FixedStart", fixed, start, true},
+		{"FixedEnd", fixed, start.Add(time.Hour), false},
+		{"CronInside", nightly, time.Date(2025, 3, 13, 19, 15, 0, 0, time.UTC), true},
+		{"CronOutside", nightly, time.Date(2025, 3, 13, 3, 15, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.active, InWindow([]config.MaintenanceWindow{tt.window}, tt.at))
+		})
+	}
+}
+
+func TestScheduler_Check(t *testing.T) {
+	start := time.Date(2025, 3, 14, 2, 0, 0, 0, time.UTC)
+	router := route.NewRouter(nil, map[string]*config.ServiceConfig{
+		"api": {
+			Name:         "api",
+			BalancerType: "round_robin",
+			Servers: []config.ServerConfig{
+				{
+					Address:     "http://backend1",
+					Maintenance: []config.MaintenanceWindow{{Start: start, End: start.Add(time.Hour)}},
+				},
+				{Address: "http://backend2"},
+			},
+		},
+	})
+	checker := healthcheck.NewHealthChecker(true, time.Second, 100*time.Millisecond, "/health")
+	checker.AddServer("http://backend1")
+
+	scheduler := NewScheduler(router, checker, time.Minute)
+	svc := router.Services()["api"]
+
+	// Inside the window the server is drained and its health checks paused
+	scheduler.now = func() time.Time { return start.Add(time.Minute) }
+	scheduler.Check()
+	assert.Equal(t, []string{DrainReason}, svc.Drained()["http://backend1"])
+	assert.True(t, checker.IsPaused("http://backend1"))
+	for i := 0; i < 3; i++ {
+		addr, err := svc.NextServer(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "http://backend2", addr)
+	}
+
+	statuses := scheduler.Statuses()
+	assert.Len(t, statuses, 1)
+	assert.True(t, statuses[0].Active)
+
+	// After the window the server returns to rotation
+	scheduler.now = func() time.Time { return start.Add(2 * time.Hour) }
+	scheduler.Check()
+	assert.Empty(t, svc.Drained())
+	assert.False(t, checker.IsPaused("http://backend1"))
+	assert.False(t, scheduler.Statuses()[0].Active)
+}
+
+func TestScheduler_KeepsManualDrain(t *testing.T) {
+	start := time.Date(2025, 3, 14, 2, 0, 0, 0, time.UTC)
+	router := route.NewRouter(nil, map[string]*config.ServiceConfig{
+		"api": {
+			Name:         "api",
+			BalancerType: "round_robin",
+			Servers: []config.ServerConfig{
+				{
+					Address:     "http://backend1",
+					Maintenance: []config.MaintenanceWindow{{Start: start, End: start.Add(time.Hour)}},
+				},
+			},
+		},
+	})
+	svc := router.Services()["api"]
+	assert.NoError(t, svc.Drain("http://backend1", "manual"))
+
+	scheduler := NewScheduler(router, nil, time.Minute)
+	scheduler.now = func() time.Time { return start }
+	scheduler.Check()
+	scheduler.now = func() time.Time { return start.Add(2 * time.Hour) }
+	scheduler.Check()
+
+	// The end of a maintenance window must not undo an operator drain
+	assert.Equal(t, []string{"manual"}, svc.Drained()["http://backend1"])
+}
@@ -0,0 +1,333 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+)
+
+const (
+	defaultRedirectPath = "/oauth2/callback"
+	defaultSessionTTL   = time.Hour
+	// flowTTL bounds the time between the redirect to the provider and the
+	// callback
+	flowTTL         = 10 * time.Minute
+	providerTimeout = 10 * time.Second
+)
+
+// defaultClaimHeaders are the forwarded claims by header name
+var defaultClaimHeaders = map[string]string{
+	"X-Forwarded-User":  "sub",
+	"X-Forwarded-Email": "email",
+}
+
+// session is the content of the session cookie, only the forwarded claims are
+// kept so the cookie stays small
+type session struct {
+	Headers map[string]string `json:"h"`
+	Expiry  int64             `json:"e"`
+}
+
+// flow is the state of a login in progress, kept in a short lived cookie
+type flow struct {
+	State    string `json:"s"`
+	Nonce    string `json:"n"`
+	Verifier string `json:"v"`
+	ReturnTo string `json:"r"`
+	Expiry   int64  `json:"e"`
+}
+
+// Authenticator protects a route with the OpenID Connect authorization code
+// flow, using PKCE and client side encrypted session cookies
+type Authenticator struct {
+	cfg          *config.OIDCConfig
+	provider     *Provider
+	clientSecret string
+	sealer       *sealer
+	cookie       string
+	redirect     *url.URL
+	scopes       string
+	sessionTTL   time.Duration
+	claimHeaders map[string]string
+	now          func() time.Time
+}
+
+// NewAuthenticator creates the authenticator of a route, the provider is
+// discovered on the first login
+func NewAuthenticator(route string, cfg *config.OIDCConfig) (*Authenticator, error) {
+	clientSecret, err := config.ResolveCredential(cfg.ClientSecret)
+	if err != nil {
+		return nil, fmt.Errorf("oidc client secret: %w", err)
+	}
+	cookieSecret, err := config.ResolveCredential(cfg.CookieSecret)
+	if err != nil {
+		return nil, fmt.Errorf("oidc cookie secret: %w", err)
+	}
+	sealer, err := newSealer(cookieSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	redirectURL := cfg.RedirectURL
+	if redirectURL == "" {
+		redirectURL = defaultRedirectPath
+	}
+	redirect, err := url.Parse(redirectURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid oidc redirect_url: %w", err)
+	}
+
+	a := &Authenticator{
+		cfg:          cfg,
+		provider:     NewProvider(cfg.Issuer, &http.Client{Timeout: providerTimeout}),
+		clientSecret: clientSecret,
+		sealer:       sealer,
+		cookie:       cfg.Cookie,
+		redirect:     redirect,
+		scopes:       strings.Join(cfg.Scopes, " "),
+		sessionTTL:   cfg.SessionTTL,
+		claimHeaders: cfg.ClaimHeaders,
+		now:          time.Now,
+	}
+	if a.cookie == "" {
+		a.cookie = "nexus_oidc_" + route
+	}
+	if a.scopes == "" {
+		a.scopes = "openid profile email"
+	}
+	if a.sessionTTL == 0 {
+		a.sessionTTL = defaultSessionTTL
+	}
+	if len(a.claimHeaders) == 0 {
+		a.claimHeaders = defaultClaimHeaders
+	}
+
+	return a, nil
+}
+
+// Authenticate returns the request carrying the identity claims of its
+// session as headers. Otherwise the request is answered, callbacks and
+// logouts are handled and requests without a valid session are sent to the
+// provider, and false is returned
+func (a *Authenticator) Authenticate(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	switch {
+	case r.URL.Path == a.redirect.Path:
+		a.callback(w, r)
+		return nil, false
+	case a.cfg.LogoutPath != "" && r.URL.Path == a.cfg.LogoutPath:
+		a.logout(w, r)
+		return nil, false
+	}
+
+	// Clients must not be able to assert an identity themselves
+	for header := range a.claimHeaders {
+		r.Header.Del(header)
+	}
+	if s, ok := a.session(r); ok {
+		for header, value := range s.Headers {
+			if value != "" {
+				r.Header.Set(header, value)
+			}
+		}
+		return r, true
+	}
+
+	// Only navigations can follow the redirect to the provider
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return nil, false
+	}
+	a.login(w, r)
+
+	return nil, false
+}
+
+// session returns the valid session of a request
+func (a *Authenticator) session(r *http.Request) (*session, bool) {
+	cookie, err := r.Cookie(a.cookie)
+	if err != nil {
+		return nil, false
+	}
+	var s session
+	if err := a.sealer.open(a.cookie, cookie.Value, &s); err != nil || a.now().Unix() >= s.Expiry {
+		return nil, false
+	}
+
+	return &s, true
+}
+
+// login redirects to the authorization endpoint of the provider
+func (a *Authenticator) login(w http.ResponseWriter, r *http.Request) {
+	metadata, err := a.provider.Metadata(r.Context())
+	if err != nil {
+		lg.GetInstance().Error("OIDC login failed: %v", err)
+		http.Error(w, "Identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+
+	f := flow{
+		State:    randomString(),
+		Nonce:    randomString(),
+		Verifier: randomString(),
+		ReturnTo: r.URL.RequestURI(),
+		Expiry:   a.now().Add(flowTTL).Unix(),
+	}
+	value, err := a.sealer.seal(a.flowCookie(), f)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, a.newCookie(r, a.flowCookie(), value, flowTTL))
+
+	challenge := sha256.Sum256([]byte(f.Verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {a.cfg.ClientID},
+		"redirect_uri":          {a.redirectURI(r)},
+		"scope":                 {a.scopes},
+		"state":                 {f.State},
+		"nonce":                 {f.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	http.Redirect(w, r, metadata.AuthorizationEndpoint+separator+query.Encode(), http.StatusFound)
+}
+
+// callback redeems the authorization code and starts the session
+func (a *Authenticator) callback(w http.ResponseWriter, r *http.Request) {
+	var f flow
+	cookie, err := r.Cookie(a.flowCookie())
+	if err != nil || a.sealer.open(a.flowCookie(), cookie.Value, &f) != nil || a.now().Unix() >= f.Expiry {
+		http.Error(w, "Login expired, please try again", http.StatusBadRequest)
+		return
+	}
+	// The flow cookie is single use
+	http.SetCookie(w, a.newCookie(r, a.flowCookie(), "", -1))
+
+	query := r.URL.Query()
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(f.State)) != 1 {
+		http.Error(w, "Invalid login state", http.StatusBadRequest)
+		return
+	}
+	if providerErr := query.Get("error"); providerErr != "" {
+		lg.GetInstance().Warn("OIDC login rejected by the provider: %s %s", providerErr, query.Get("error_description"))
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+
+	raw, err := a.provider.Exchange(r.Context(), ExchangeRequest{
+		Code:         query.Get("code"),
+		RedirectURI:  a.redirectURI(r),
+		ClientID:     a.cfg.ClientID,
+		ClientSecret: a.clientSecret,
+		CodeVerifier: f.Verifier,
+	})
+	if err != nil {
+		lg.GetInstance().Error("OIDC code exchange failed: %v", err)
+		http.Error(w, "Identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+	claims, err := a.provider.VerifyIDToken(r.Context(), raw, a.cfg.ClientID, f.Nonce, a.now())
+	if err != nil {
+		lg.GetInstance().Warn("OIDC id token rejected: %v", err)
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+
+	s := session{
+		Headers: make(map[string]string, len(a.claimHeaders)),
+		Expiry:  a.now().Add(a.sessionTTL).Unix(),
+	}
+	for header, claim := range a.claimHeaders {
+		s.Headers[header] = claims.String(claim)
+	}
+	value, err := a.sealer.seal(a.cookie, s)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, a.newCookie(r, a.cookie, value, a.sessionTTL))
+
+	http.Redirect(w, r, safeReturnTo(f.ReturnTo), http.StatusFound)
+}
+
+// logout clears the session and continues at the provider's end session
+// endpoint when it has one
+func (a *Authenticator) logout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, a.newCookie(r, a.cookie, "", -1))
+
+	target := "/"
+	if metadata, err := a.provider.Metadata(r.Context()); err == nil && metadata.EndSessionEndpoint != "" {
+		target = metadata.EndSessionEndpoint
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+func (a *Authenticator) flowCookie() string {
+	return a.cookie + "_flow"
+}
+
+// redirectURI is the callback URL registered with the provider, a
+// redirect_url path is served on the host of the request
+func (a *Authenticator) redirectURI(r *http.Request) string {
+	if a.redirect.IsAbs() {
+		return a.redirect.String()
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + a.redirect.RequestURI()
+}
+
+// newCookie builds a cookie of the authenticator, a negative ttl deletes it
+func (a *Authenticator) newCookie(r *http.Request, name, value string, ttl time.Duration) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil || a.redirect.Scheme == "https",
+		// Lax keeps the cookies on the top level redirect back from the provider
+		SameSite: http.SameSiteLaxMode,
+	}
+	if ttl < 0 {
+		cookie.MaxAge = -1
+	} else {
+		cookie.MaxAge = int(ttl.Seconds())
+		cookie.Expires = a.now().Add(ttl)
+	}
+
+	return cookie
+}
+
+// safeReturnTo only returns to paths on the same host, so the flow can't be
+// used as an open redirect
+func safeReturnTo(returnTo string) string {
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") || strings.HasPrefix(returnTo, "/\\") {
+		return "/"
+	}
+	return returnTo
+}
+
+// randomString returns 256 random bits, base64url encoded
+func randomString() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
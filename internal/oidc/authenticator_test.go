@@ -0,0 +1,150 @@
+package oidc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// browser keeps the cookies set by the authenticator like a browser would
+type browser struct {
+	t       *testing.T
+	auth    *Authenticator
+	cookies map[string]*http.Cookie
+}
+
+func (b *browser) do(method, target string, header http.Header) (*httptest.ResponseRecorder, *http.Request, bool) {
+	req := httptest.NewRequest(method, target, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	for _, cookie := range b.cookies {
+		req.AddCookie(cookie)
+	}
+
+	w := httptest.NewRecorder()
+	forwarded, ok := b.auth.Authenticate(w, req)
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			delete(b.cookies, cookie.Name)
+		} else {
+			b.cookies[cookie.Name] = cookie
+		}
+	}
+
+	return w, forwarded, ok
+}
+
+func newTestAuthenticator(t *testing.T, idp *fakeIdP) *browser {
+	t.Helper()
+
+	auth, err := NewAuthenticator("app", &config.OIDCConfig{
+		Issuer:       idp.server.URL,
+		ClientID:     "nexus",
+		ClientSecret: "client-secret",
+		CookieSecret: "0123456789abcdef0123456789abcdef",
+		LogoutPath:   "/logout",
+	})
+	require.NoError(t, err)
+
+	return &browser{t: t, auth: auth, cookies: make(map[string]*http.Cookie)}
+}
+
+// login follows the authorization code flow for a request without session
+func (b *browser) login(idp *fakeIdP, target string, claims map[string]interface{}) *httptest.ResponseRecorder {
+	b.t.Helper()
+
+	w, _, ok := b.do(http.MethodGet, target, nil)
+	require.False(b.t, ok)
+	require.Equal(b.t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(b.t, err)
+	require.Equal(b.t, idp.server.URL+"/authorize", location.Scheme+"://"+location.Host+location.Path)
+
+	query := location.Query()
+	assert.Equal(b.t, "nexus", query.Get("client_id"))
+	assert.Equal(b.t, "http://app.example.com/oauth2/callback", query.Get("redirect_uri"))
+	assert.Equal(b.t, "S256", query.Get("code_challenge_method"))
+
+	idp.mu.Lock()
+	idp.codes["code-1"] = authorization{nonce: query.Get("nonce"), challenge: query.Get("code_challenge"), claims: claims}
+	idp.mu.Unlock()
+
+	callback := "http://app.example.com/oauth2/callback?code=code-1&state=" + url.QueryEscape(query.Get("state"))
+	w, _, ok = b.do(http.MethodGet, callback, nil)
+	require.False(b.t, ok)
+
+	return w
+}
+
+func TestAuthenticator_Flow(t *testing.T) {
+	idp := newFakeIdP(t)
+	b := newTestAuthenticator(t, idp)
+
+	w := b.login(idp, "http://app.example.com/dashboard?tab=1", nil)
+	require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+	assert.Equal(t, "/dashboard?tab=1", w.Header().Get("Location"))
+	require.Contains(t, b.cookies, "nexus_oidc_app")
+	assert.NotContains(t, b.cookies, "nexus_oidc_app_flow")
+
+	// Identity headers sent by the client are replaced by the session claims
+	spoofed := http.Header{"X-Forwarded-User": {"admin"}, "X-Forwarded-Email": {"admin@example.com"}}
+	_, forwarded, ok := b.do(http.MethodGet, "http://app.example.com/dashboard", spoofed)
+	require.True(t, ok)
+	assert.Equal(t, "user-1", forwarded.Header.Get("X-Forwarded-User"))
+	assert.Equal(t, "user@example.com", forwarded.Header.Get("X-Forwarded-Email"))
+
+	// Sessions expire
+	b.auth.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	w, _, ok = b.do(http.MethodGet, "http://app.example.com/dashboard", nil)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusFound, w.Code)
+	b.auth.now = time.Now
+
+	w, _, ok = b.do(http.MethodGet, "http://app.example.com/logout", nil)
+	assert.False(t, ok)
+	assert.Equal(t, idp.server.URL+"/logout", w.Header().Get("Location"))
+	assert.NotContains(t, b.cookies, "nexus_oidc_app")
+}
+
+func TestAuthenticator_Rejects(t *testing.T) {
+	idp := newFakeIdP(t)
+	b := newTestAuthenticator(t, idp)
+
+	// Spoofed identities don't pass without a session
+	_, _, ok := b.do(http.MethodGet, "http://app.example.com/", http.Header{"X-Forwarded-User": {"admin"}})
+	assert.False(t, ok)
+
+	w, _, ok := b.do(http.MethodPost, "http://app.example.com/api", nil)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// A callback without a login in progress
+	b.cookies = make(map[string]*http.Cookie)
+	w, _, _ = b.do(http.MethodGet, "http://app.example.com/oauth2/callback?code=code-1&state=x", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// A callback with another state
+	b.do(http.MethodGet, "http://app.example.com/", nil)
+	w, _, _ = b.do(http.MethodGet, "http://app.example.com/oauth2/callback?code=code-1&state=x", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// An ID token for another audience
+	w = b.login(idp, "http://app.example.com/", map[string]interface{}{"aud": "other"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotContains(t, b.cookies, "nexus_oidc_app")
+}
+
+func TestSafeReturnTo(t *testing.T) {
+	assert.Equal(t, "/app?x=1", safeReturnTo("/app?x=1"))
+	assert.Equal(t, "/", safeReturnTo("//evil.example.com/"))
+	assert.Equal(t, "/", safeReturnTo("/\\evil.example.com/"))
+	assert.Equal(t, "/", safeReturnTo("https://evil.example.com/"))
+}
@@ -0,0 +1,239 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIdP is an identity provider issuing RS256 signed ID tokens
+type fakeIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu         sync.Mutex
+	codes      map[string]authorization // pending authorization codes
+	keyFetches int
+}
+
+// authorization is an authorization request approved by the fake provider
+type authorization struct {
+	nonce     string
+	challenge string
+	claims    map[string]interface{}
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	idp := &fakeIdP{key: key, codes: make(map[string]authorization)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Metadata{
+			Issuer:                idp.server.URL,
+			AuthorizationEndpoint: idp.server.URL + "/authorize",
+			TokenEndpoint:         idp.server.URL + "/token",
+			JWKSURI:               idp.server.URL + "/jwks",
+			EndSessionEndpoint:    idp.server.URL + "/logout",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		idp.keyFetches++
+		idp.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "nexus" || pass != "client-secret" || r.PostFormValue("grant_type") != "authorization_code" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		idp.mu.Lock()
+		auth, ok := idp.codes[r.PostFormValue("code")]
+		delete(idp.codes, r.PostFormValue("code"))
+		idp.mu.Unlock()
+
+		verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != auth.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		claims := idp.claims(auth.nonce)
+		for name, value := range auth.claims {
+			claims[name] = value
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.sign(t, "k1", claims)})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+// claims returns valid ID token claims for the nexus client
+func (idp *fakeIdP) claims(nonce string) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":   idp.server.URL,
+		"aud":   "nexus",
+		"sub":   "user-1",
+		"email": "user@example.com",
+		"exp":   time.Now().Add(5 * time.Minute).Unix(),
+		"nonce": nonce,
+	}
+}
+
+func (idp *fakeIdP) sign(t *testing.T, kid string, claims map[string]interface{}) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": kid})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestProvider_VerifyIDToken(t *testing.T) {
+	idp := newFakeIdP(t)
+	provider := NewProvider(idp.server.URL, idp.server.Client())
+	ctx := context.Background()
+	now := time.Now()
+
+	claims, err := provider.VerifyIDToken(ctx, idp.sign(t, "k1", idp.claims("n1")), "nexus", "n1", now)
+	require.NoError(t, err)
+	assert.Equal(t, "user@example.com", claims.String("email"))
+
+	audiences := idp.claims("n1")
+	audiences["aud"] = []interface{}{"other", "nexus"}
+	_, err = provider.VerifyIDToken(ctx, idp.sign(t, "k1", audiences), "nexus", "n1", now)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		token    func() string
+		expected string
+	}{
+		{
+			name:     "nonce",
+			token:    func() string { return idp.sign(t, "k1", idp.claims("other")) },
+			expected: "nonce does not match",
+		},
+		{
+			name: "audience",
+			token: func() string {
+				claims := idp.claims("n1")
+				claims["aud"] = "other"
+				return idp.sign(t, "k1", claims)
+			},
+			expected: "not issued for this client",
+		},
+		{
+			name: "issuer",
+			token: func() string {
+				claims := idp.claims("n1")
+				claims["iss"] = "https://evil.example.com"
+				return idp.sign(t, "k1", claims)
+			},
+			expected: "issuer",
+		},
+		{
+			name: "expired",
+			token: func() string {
+				claims := idp.claims("n1")
+				claims["exp"] = now.Add(-time.Hour).Unix()
+				return idp.sign(t, "k1", claims)
+			},
+			expected: "expired",
+		},
+		{
+			name: "signature",
+			token: func() string {
+				token := idp.sign(t, "k1", idp.claims("n1"))
+				return token[:len(token)-4] + "AAAA"
+			},
+			expected: "invalid id token signature",
+		},
+		{
+			name: "unsigned",
+			token: func() string {
+				header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"k1"}`))
+				payload, _ := json.Marshal(idp.claims("n1"))
+				return header + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+			},
+			expected: "unsupported id token algorithm",
+		},
+		{
+			name:     "unknown key",
+			token:    func() string { return idp.sign(t, "k2", idp.claims("n1")) },
+			expected: "unknown signing key",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := provider.VerifyIDToken(ctx, tt.token(), "nexus", "n1", now)
+			assert.ErrorContains(t, err, tt.expected)
+		})
+	}
+
+	// Unknown key IDs don't refetch the key set more than once per minute
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	assert.Equal(t, 1, idp.keyFetches)
+}
+
+func TestJSONWebKey_EC(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	jwk := jsonWebKey{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(key.X.Bytes()),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.Bytes()),
+	}
+	public, err := jwk.publicKey()
+	require.NoError(t, err)
+
+	signed := "header.payload"
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	assert.NoError(t, verifySignature("ES256", public, signed, signature))
+	assert.Error(t, verifySignature("RS256", public, signed, signature))
+	assert.Error(t, verifySignature("ES256", public, signed+"x", signature))
+}
@@ -0,0 +1,308 @@
+package passthrough
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	lb "nexus/internal/balancer"
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+	"nexus/internal/route"
+)
+
+const (
+	defaultHelloTimeout = 10 * time.Second
+	defaultDialTimeout  = 5 * time.Second
+)
+
+// errHelloRead stops the handshake once the ClientHello was parsed
+var errHelloRead = errors.New("client hello read")
+
+// Server forwards TLS connections to the service their SNI matches without
+// terminating TLS. The ClientHello is parsed from a copy of the bytes read,
+// which are replayed to the backend before the rest of the connection
+type Server struct {
+	router route.Router
+
+	mu     sync.RWMutex
+	cfg    config.PassthroughConfig
+	routes []*config.PassthroughRoute // SNI patterns lowercased
+
+	connsMu   sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	wg        sync.WaitGroup
+	closed    bool
+}
+
+// New creates a passthrough server selecting backends from the services
+// of router
+func New(cfg config.PassthroughConfig, router route.Router) *Server {
+	s := &Server{
+		router:    router,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+	s.SetConfig(cfg)
+	return s
+}
+
+// SetConfig replaces the routes and timeouts, e.g. after a config reload.
+// Connections already forwarded are not affected
+func (s *Server) SetConfig(cfg config.PassthroughConfig) {
+	routes := make([]*config.PassthroughRoute, 0, len(cfg.Routes))
+	for _, r := range cfg.Routes {
+		names := make([]string, 0, len(r.SNI))
+		for _, name := range r.SNI {
+			names = append(names, strings.ToLower(name))
+		}
+		routes = append(routes, &config.PassthroughRoute{SNI: names, Service: r.Service})
+	}
+	if cfg.HelloTimeout == 0 {
+		cfg.HelloTimeout = defaultHelloTimeout
+	}
+	if cfg.DialTimeout == 0 {
+		cfg.DialTimeout = defaultDialTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cfg = cfg
+	s.routes = routes
+}
+
+// ListenAndServe listens on the configured address and serves connections
+func (s *Server) ListenAndServe() error {
+	s.mu.RLock()
+	addr := s.cfg.ListenAddr
+	s.mu.RUnlock()
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve accepts connections on ln until the server is shut down, it then
+// returns net.ErrClosed
+func (s *Server) Serve(ln net.Listener) error {
+	s.connsMu.Lock()
+	if s.closed {
+		s.connsMu.Unlock()
+		ln.Close()
+		return net.ErrClosed
+	}
+	s.listeners[ln] = struct{}{}
+	s.connsMu.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.connsMu.Lock()
+			closed := s.closed
+			s.connsMu.Unlock()
+			if closed {
+				return net.ErrClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		if !s.track(conn) {
+			conn.Close()
+			return net.ErrClosed
+		}
+		go func() {
+			defer s.untrack(conn)
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) track(conn net.Conn) bool {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.connsMu.Lock()
+	delete(s.conns, conn)
+	s.connsMu.Unlock()
+	s.wg.Done()
+}
+
+// Shutdown stops accepting connections and waits for the forwarded ones to
+// end, those still open when ctx is done are closed
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.connsMu.Lock()
+	s.closed = true
+	for ln := range s.listeners {
+		ln.Close()
+	}
+	s.connsMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.connsMu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.connsMu.Unlock()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// handle reads the ClientHello and forwards the connection to a server of
+// the matched service
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	s.mu.RLock()
+	cfg := s.cfg
+	s.mu.RUnlock()
+
+	conn.SetReadDeadline(time.Now().Add(cfg.HelloTimeout))
+	serverName, hello, err := readServerName(conn)
+	if err != nil {
+		lg.GetInstance().Debug("[%s] Passthrough: no ClientHello: %v", conn.RemoteAddr(), err)
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	name := s.match(serverName)
+	svc, ok := s.router.Service(name)
+	if !ok {
+		lg.GetInstance().Debug("[%s] Passthrough: no service for server name %q", conn.RemoteAddr(), serverName)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DialTimeout)
+	defer cancel()
+	server, err := svc.NextServer(ctx)
+	if err != nil {
+		lg.GetInstance().Warn("[%s] Passthrough: no server for %q: %v", conn.RemoteAddr(), serverName, err)
+		return
+	}
+	if tracker, ok := svc.Balancer().(lb.Tracker); ok {
+		defer tracker.Done(server)
+	}
+
+	var dialer net.Dialer
+	backend, err := dialer.DialContext(ctx, "tcp", backendAddr(server))
+	if err != nil {
+		lg.GetInstance().Warn("[%s] Passthrough: failed to connect to %s: %v", conn.RemoteAddr(), server, err)
+		return
+	}
+	defer backend.Close()
+
+	if _, err := backend.Write(hello); err != nil {
+		return
+	}
+	pipe(conn, backend)
+}
+
+// match returns the service of the first route matching serverName
+func (s *Server) match(serverName string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	serverName = strings.ToLower(serverName)
+	for _, r := range s.routes {
+		if route.MatchServerName(r.SNI, serverName) {
+			return r.Service
+		}
+	}
+	return s.cfg.DefaultService
+}
+
+// readServerName parses the ClientHello from conn, returning the requested
+// server name and the bytes read so far
+func readServerName(conn net.Conn) (string, []byte, error) {
+	var read bytes.Buffer
+	peek := &peekConn{Conn: conn, reader: io.TeeReader(conn, &read)}
+
+	var serverName string
+	var parsed bool
+	err := tls.Server(peek, &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName, parsed = hello.ServerName, true
+			return nil, errHelloRead
+		},
+	}).Handshake()
+	if !parsed {
+		return "", nil, fmt.Errorf("invalid ClientHello: %w", err)
+	}
+
+	return serverName, read.Bytes(), nil
+}
+
+// peekConn lets the TLS stack read the connection without ever writing to
+// it, alerts sent on errors are discarded
+type peekConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *peekConn) Read(b []byte) (int, error)  { return c.reader.Read(b) }
+func (c *peekConn) Write(b []byte) (int, error) { return len(b), nil }
+func (c *peekConn) Close() error                { return nil }
+
+// pipe copies both directions until both sides are done, half-closing the
+// write side of each connection once its peer finished sending
+func pipe(client, backend net.Conn) {
+	done := make(chan struct{}, 2)
+	copyHalf := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		} else {
+			dst.Close()
+		}
+		done <- struct{}{}
+	}
+	go copyHalf(backend, client)
+	go copyHalf(client, backend)
+	<-done
+	<-done
+}
+
+// backendAddr returns the host and port of a server address, which may be
+// a URL such as https://10.0.0.1:8443. The port defaults to 443
+func backendAddr(server string) string {
+	host := server
+	if u, err := url.Parse(server); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		return net.JoinHostPort(strings.Trim(host, "[]"), "443")
+	}
+	return host
+}
@@ -0,0 +1,105 @@
+package passthrough
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPassthroughTest forwards *.secure.example.com to a TLS backend
+// answering with the server name it terminated
+func newPassthroughTest(t *testing.T, cfg config.PassthroughConfig) (*Server, string) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.ServerName))
+	}))
+	t.Cleanup(backend.Close)
+
+	services := map[string]*config.ServiceConfig{
+		"secure": {Name: "secure", BalancerType: "least_connections", Servers: []config.ServerConfig{{Address: backend.URL}}},
+	}
+	cfg.Routes = append(cfg.Routes, &config.PassthroughRoute{SNI: []string{"*.Secure.example.com"}, Service: "secure"})
+	s := New(cfg, route.NewRouter(nil, services))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(ln)
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+
+	return s, ln.Addr().String()
+}
+
+// get requests / through the passthrough listener with serverName as SNI
+func get(addr, serverName string) (string, error) {
+	client := &http.Client{Transport: &http.Transport{
+		DialContext:     func(ctx context.Context, _, _ string) (net.Conn, error) { return net.Dial("tcp", addr) },
+		TLSClientConfig: &tls.Config{ServerName: serverName, InsecureSkipVerify: true},
+	}}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Get("https://" + serverName + "/")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestServer_ForwardsBySNI(t *testing.T) {
+	_, addr := newPassthroughTest(t, config.PassthroughConfig{})
+
+	// The backend terminates TLS and sees the SNI of the client
+	body, err := get(addr, "api.secure.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "api.secure.example.com", body)
+
+	// No route and no default service
+	_, err = get(addr, "other.example.com")
+	assert.Error(t, err)
+}
+
+func TestServer_DefaultService(t *testing.T) {
+	_, addr := newPassthroughTest(t, config.PassthroughConfig{DefaultService: "secure"})
+
+	body, err := get(addr, "other.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "other.example.com", body)
+}
+
+func TestServer_ClosesConnectionsWithoutClientHello(t *testing.T) {
+	_, addr := newPassthroughTest(t, config.PassthroughConfig{HelloTimeout: 50 * time.Millisecond})
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: api.secure.example.com\r\n\r\n"))
+
+	// Nothing is sent back, not even a TLS alert
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(make([]byte, 64))
+	assert.Zero(t, n)
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestBackendAddr(t *testing.T) {
+	tests := map[string]string{
+		"https://10.0.0.1:8443": "10.0.0.1:8443",
+		"https://backend":       "backend:443",
+		"backend:9443":          "backend:9443",
+		"http://[::1]":          "[::1]:443",
+	}
+	for server, expected := range tests {
+		assert.Equal(t, expected, backendAddr(server), server)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package pressure
+
+// cpuClock is not supported on this platform, CPU thresholds are ignored
+type cpuClock struct{}
+
+func newCPUClock() cpuClock {
+	return cpuClock{}
+}
+
+func (c *cpuClock) utilization() float64 {
+	return -1
+}
@@ -0,0 +1,45 @@
+//go:build unix
+
+package pressure
+
+import (
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// cpuClock measures the CPU time used by the process between two samples
+type cpuClock struct {
+	wall time.Time
+	used time.Duration
+}
+
+func newCPUClock() cpuClock {
+	return cpuClock{wall: time.Now(), used: cpuTime()}
+}
+
+// utilization returns the share of the usable cores used since the last call
+func (c *cpuClock) utilization() float64 {
+	now, used := time.Now(), cpuTime()
+	if used < 0 {
+		return -1
+	}
+	wall := now.Sub(c.wall)
+	delta := used - c.used
+	c.wall, c.used = now, used
+	if wall <= 0 {
+		return 0
+	}
+
+	return float64(delta) / float64(wall) / float64(runtime.GOMAXPROCS(0))
+}
+
+// cpuTime returns the user and system time of the process, -1 on failure
+func cpuTime() time.Duration {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return -1
+	}
+
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
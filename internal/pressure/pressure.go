@@ -0,0 +1,163 @@
+package pressure
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"time"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+)
+
+// DefaultInterval is the sampling interval when none is configured
+const DefaultInterval = time.Second
+
+// Sample is a snapshot of the process resource usage
+type Sample struct {
+	Time       time.Time     `json:"time"`
+	CPU        float64       `json:"cpu"`        // Utilization of the usable cores since the previous sample, -1 when unsupported
+	HeapBytes  uint64        `json:"heap_bytes"` // Heap in use
+	Goroutines int           `json:"goroutines"`
+	GCPause    time.Duration `json:"gc_pause"` // Longest GC pause since the previous sample
+	Pressure   float64       `json:"pressure"` // Highest ratio of a resource to its threshold
+}
+
+// Monitor samples the process resources and computes the resource pressure
+type Monitor struct {
+	mu       sync.RWMutex
+	cfg      config.LoadShedConfig
+	sample   Sample
+	cpu      cpuClock
+	numGC    uint32
+	stopChan chan struct{}
+}
+
+// NewMonitor creates a pressure monitor
+func NewMonitor(cfg config.LoadShedConfig) *Monitor {
+	return &Monitor{
+		cfg:      cfg,
+		cpu:      newCPUClock(),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// SetConfig updates the thresholds, the interval is read when the monitor starts
+func (m *Monitor) SetConfig(cfg config.LoadShedConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cfg = cfg
+	m.sample.Pressure = Pressure(cfg, m.sample)
+}
+
+// Start begins sampling the process resources
+func (m *Monitor) Start() {
+	m.Check()
+
+	m.mu.RLock()
+	interval := m.cfg.Interval
+	m.mu.RUnlock()
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.Check()
+		case <-m.stopChan:
+			return
+		}
+	}
+}
+
+// Stop terminates the monitor
+func (m *Monitor) Stop() {
+	close(m.stopChan)
+}
+
+// Check takes a new sample and updates the pressure
+func (m *Monitor) Check() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous := m.sample.Pressure
+	m.sample = Sample{
+		Time:       time.Now(),
+		CPU:        m.cpu.utilization(),
+		HeapBytes:  stats.HeapInuse,
+		Goroutines: runtime.NumGoroutine(),
+		GCPause:    maxPause(&stats, m.numGC),
+	}
+	m.numGC = stats.NumGC
+	m.sample.Pressure = Pressure(m.cfg, m.sample)
+
+	if previous < 1 && m.sample.Pressure >= 1 {
+		lg.GetInstance().Warn("Resource pressure %.2f: cpu %.2f, heap %d bytes, %d goroutines, gc pause %v",
+			m.sample.Pressure, m.sample.CPU, m.sample.HeapBytes, m.sample.Goroutines, m.sample.GCPause)
+	} else if previous >= 1 && m.sample.Pressure < 1 {
+		lg.GetInstance().Info("Resource pressure back to %.2f", m.sample.Pressure)
+	}
+}
+
+// Sample returns the latest sample
+func (m *Monitor) Sample() Sample {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.sample
+}
+
+// Pressure returns the latest resource pressure
+func (m *Monitor) Pressure() float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.sample.Pressure
+}
+
+// Pressure returns the highest ratio of a sampled resource to its threshold,
+// resources without a threshold are ignored
+func Pressure(cfg config.LoadShedConfig, sample Sample) float64 {
+	var pressure float64
+	if cfg.CPU > 0 && sample.CPU >= 0 {
+		pressure = math.Max(pressure, sample.CPU/cfg.CPU)
+	}
+	if cfg.Memory > 0 {
+		pressure = math.Max(pressure, float64(sample.HeapBytes)/float64(cfg.Memory))
+	}
+	if cfg.Goroutines > 0 {
+		pressure = math.Max(pressure, float64(sample.Goroutines)/float64(cfg.Goroutines))
+	}
+	if cfg.GCPause > 0 {
+		pressure = math.Max(pressure, float64(sample.GCPause)/float64(cfg.GCPause))
+	}
+
+	return pressure
+}
+
+// maxPause returns the longest GC pause of the cycles completed after numGC
+func maxPause(stats *runtime.MemStats, numGC uint32) time.Duration {
+	cycles := stats.NumGC - numGC
+	if cycles > uint32(len(stats.PauseNs)) {
+		cycles = uint32(len(stats.PauseNs))
+	}
+
+	var longest uint64
+	for i := uint32(0); i < cycles; i++ {
+		// PauseNs is a ring buffer, the latest pause is at (NumGC+255)%256
+		pause := stats.PauseNs[(stats.NumGC-i+255)%uint32(len(stats.PauseNs))]
+		if pause > longest {
+			longest = pause
+		}
+	}
+
+	return time.Duration(longest)
+}
@@ -0,0 +1,53 @@
+package pressure
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPressure(t *testing.T) {
+	sample := Sample{CPU: 0.4, HeapBytes: 300, Goroutines: 50, GCPause: 5 * time.Millisecond}
+
+	tests := []struct {
+		name     string
+		cfg      config.LoadShedConfig
+		expected float64
+	}{
+		{name: "no thresholds", cfg: config.LoadShedConfig{}, expected: 0},
+		{name: "cpu", cfg: config.LoadShedConfig{CPU: 0.8}, expected: 0.5},
+		{name: "highest ratio", cfg: config.LoadShedConfig{CPU: 0.8, Memory: 200, Goroutines: 100}, expected: 1.5},
+		{name: "gc pause", cfg: config.LoadShedConfig{GCPause: 10 * time.Millisecond}, expected: 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.expected, Pressure(tt.cfg, sample), 1e-9)
+		})
+	}
+
+	// Unsupported CPU measurements are ignored
+	assert.Equal(t, 0.0, Pressure(config.LoadShedConfig{CPU: 0.5}, Sample{CPU: -1}))
+}
+
+func TestMonitor_Check(t *testing.T) {
+	m := NewMonitor(config.LoadShedConfig{Goroutines: 1})
+	runtime.GC()
+	m.Check()
+
+	sample := m.Sample()
+	assert.Positive(t, sample.Goroutines)
+	assert.Positive(t, sample.HeapBytes)
+	assert.Positive(t, sample.GCPause)
+	assert.Equal(t, float64(sample.Goroutines), m.Pressure())
+
+	// Pauses are only reported once
+	m.Check()
+	assert.Zero(t, m.Sample().GCPause)
+
+	m.SetConfig(config.LoadShedConfig{Goroutines: 1 << 20})
+	assert.Less(t, m.Pressure(), 1.0)
+}
@@ -0,0 +1,506 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+	"nexus/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultSyslogFacility = "local0"
+	defaultSyslogAppName  = "nexus"
+	syslogDialTimeout     = 5 * time.Second
+	syslogQueueSize       = 10000
+
+	defaultSinkBatchSize     = 100
+	defaultSinkFlushInterval = time.Second
+	defaultSinkTimeout       = 5 * time.Second
+	defaultSinkMaxRetries    = 3
+	defaultSinkQueueSize     = 10000
+	sinkRetryBackoff         = 200 * time.Millisecond
+)
+
+// accessLogSink receives the access log of a middleware, line is the entry
+// in the configured format
+type accessLogSink interface {
+	write(entry *accessLogEntry, line []byte)
+}
+
+// closableSink is a sink holding a connection and queued entries, closed
+// once no middleware uses it
+type closableSink interface {
+	accessLogSink
+	close()
+}
+
+// accessLogOutput returns the sink of an access log middleware, sinks stay
+// open across reloads so lines of in-flight requests are not lost
+func (p *Proxy) accessLogOutput(cfg *config.MiddlewareConfig) (accessLogSink, string, error) {
+	switch cfg.Sink {
+	case config.AccessLogSinkSyslog:
+		key := sinkKey(config.AccessLogSinkSyslog, cfg.Syslog)
+		if value, ok := p.accessLogs.Load(key); ok {
+			return value.(accessLogSink), key, nil
+		}
+		value, loaded := p.accessLogs.LoadOrStore(key, newSyslogSink(*cfg.Syslog))
+		if !loaded {
+			go value.(*syslogSink).run()
+		}
+		return value.(accessLogSink), key, nil
+	case config.AccessLogSinkHTTP:
+		key := sinkKey(config.AccessLogSinkHTTP, cfg.HTTP)
+		if value, ok := p.accessLogs.Load(key); ok {
+			return value.(accessLogSink), key, nil
+		}
+		value, loaded := p.accessLogs.LoadOrStore(key, newHTTPSink(*cfg.HTTP))
+		if !loaded {
+			go value.(*httpSink).run()
+		}
+		return value.(accessLogSink), key, nil
+	case config.AccessLogSinkOTel:
+		return otelSink{proxy: p}, config.AccessLogSinkOTel, nil
+	case config.AccessLogSinkStdout:
+		out, err := p.accessLogFile("")
+		return out, "", err
+	default:
+		out, err := p.accessLogFile(cfg.File)
+		return out, cfg.File, err
+	}
+}
+
+// sinkKey identifies a network sink by its config, changing the config
+// opens a new sink
+func sinkKey(sink string, cfg any) string {
+	data, _ := json.Marshal(cfg)
+	return sink + " " + string(data)
+}
+
+// closeUnusedSinks closes the network sinks that no middleware uses anymore,
+// after their queued entries were sent
+func (p *Proxy) closeUnusedSinks(used map[string]bool) {
+	p.accessLogs.Range(func(key, value any) bool {
+		if sink, ok := value.(closableSink); ok && !used[key.(string)] {
+			p.accessLogs.Delete(key)
+			sink.close()
+		}
+		return true
+	})
+}
+
+// CloseLogs sends the entries queued by the network access log sinks and
+// closes them, e.g. on shutdown
+func (p *Proxy) CloseLogs() {
+	p.closeUnusedSinks(nil)
+}
+
+// SetLogExporter sets the exporter of the access logs with the otel sink
+func (p *Proxy) SetLogExporter(exporter *telemetry.LogExporter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.logExporter = exporter
+}
+
+// otelSink exports access logs as OpenTelemetry logs correlated with the
+// request span, through the log exporter of the proxy
+type otelSink struct {
+	proxy *Proxy
+}
+
+func (s otelSink) write(entry *accessLogEntry, line []byte) {
+	s.proxy.mu.RLock()
+	exporter := s.proxy.logExporter
+	s.proxy.mu.RUnlock()
+
+	if exporter != nil {
+		exporter.Emit(context.Background(), accessLogRecord(entry, string(bytes.TrimRight(line, "\n"))))
+	}
+}
+
+// syslogSink sends access log lines as RFC 5424 messages. Lines are queued
+// so a slow syslog server doesn't hold up responses, they are dropped while
+// the queue is full
+type syslogSink struct {
+	network  string
+	address  string
+	priority int // Facility, the severity is added per message
+	appName  string
+	hostname string
+	queue    chan []byte
+	done     chan struct{}
+	stopped  chan struct{}
+	once     sync.Once
+	dropped  atomic.Int64
+}
+
+func newSyslogSink(cfg config.SyslogSinkConfig) *syslogSink {
+	network := cfg.Network
+	if network == "" {
+		network = "udp"
+	} else if network == "unix" {
+		// Local syslog daemons listen on datagram sockets such as /dev/log
+		network = "unixgram"
+	}
+	facility := cfg.Facility
+	if facility == "" {
+		facility = defaultSyslogFacility
+	}
+	appName := cfg.AppName
+	if appName == "" {
+		appName = defaultSyslogAppName
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	return &syslogSink{
+		network:  network,
+		address:  cfg.Address,
+		priority: config.SyslogFacilities[facility] * 8,
+		appName:  appName,
+		hostname: hostname,
+		queue:    make(chan []byte, syslogQueueSize),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+func (s *syslogSink) write(entry *accessLogEntry, line []byte) {
+	// Server errors are warnings (4), the other requests informational (6)
+	severity := 6
+	if entry.Status >= http.StatusInternalServerError {
+		severity = 4
+	}
+	msg := fmt.Sprintf("<%d>1 %s %s %s %d access - %s", s.priority+severity,
+		entry.Time.UTC().Format("2006-01-02T15:04:05.000000Z"), s.hostname, s.appName, os.Getpid(),
+		bytes.TrimRight(line, "\n"))
+
+	select {
+	case s.queue <- []byte(msg):
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// run sends the queued messages until the sink is closed, reconnecting
+// after failures
+func (s *syslogSink) run() {
+	defer close(s.stopped)
+
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	failing := false
+	send := func(msg []byte) {
+		if s.network == "tcp" {
+			// RFC 6587 octet counting, messages may contain line breaks
+			msg = append([]byte(fmt.Sprintf("%d ", len(msg))), msg...)
+		}
+		// A connection broken since the last message is replaced once
+		for attempt := 0; attempt < 2; attempt++ {
+			if conn == nil {
+				c, err := net.DialTimeout(s.network, s.address, syslogDialTimeout)
+				if err != nil {
+					s.fail(&failing, err)
+					return
+				}
+				conn = c
+			}
+			conn.SetWriteDeadline(time.Now().Add(syslogDialTimeout))
+			_, err := conn.Write(msg)
+			if err == nil {
+				if failing {
+					lg.GetInstance().Info("Sending access logs to syslog %s again", s.address)
+					failing = false
+				}
+				return
+			}
+			conn.Close()
+			conn = nil
+			if attempt == 1 {
+				s.fail(&failing, err)
+			}
+		}
+	}
+
+	for {
+		select {
+		case msg := <-s.queue:
+			send(msg)
+		case <-s.done:
+			for {
+				select {
+				case msg := <-s.queue:
+					send(msg)
+				default:
+					return
+				}
+			}
+		}
+		if dropped := s.dropped.Swap(0); dropped > 0 {
+			lg.GetInstance().Warn("Dropped %d access log lines, the syslog %s queue is full", dropped, s.address)
+		}
+	}
+}
+
+// fail drops a message, the first failure of a series is logged
+func (s *syslogSink) fail(failing *bool, err error) {
+	if !*failing {
+		lg.GetInstance().Warn("Failed to send access logs to syslog %s: %v", s.address, err)
+		*failing = true
+	}
+}
+
+func (s *syslogSink) close() {
+	s.once.Do(func() { close(s.done) })
+	<-s.stopped
+}
+
+// queuedEntry is an access log entry waiting in an HTTP sink
+type queuedEntry struct {
+	entry accessLogEntry
+	line  string
+}
+
+// httpSink sends batches of access log entries to an HTTP endpoint, as JSON
+// lines or as OTLP logs. Failed batches are retried with backoff, entries are
+// dropped while the queue is full
+type httpSink struct {
+	cfg     config.HTTPSinkConfig
+	client  *http.Client
+	queue   chan queuedEntry
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+	dropped atomic.Int64
+}
+
+func newHTTPSink(cfg config.HTTPSinkConfig) *httpSink {
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = defaultSinkBatchSize
+	}
+	if cfg.FlushInterval == 0 {
+		cfg.FlushInterval = defaultSinkFlushInterval
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultSinkTimeout
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = defaultSinkMaxRetries
+	}
+	if cfg.QueueSize == 0 {
+		cfg.QueueSize = defaultSinkQueueSize
+	}
+
+	return &httpSink{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		queue:   make(chan queuedEntry, cfg.QueueSize),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (s *httpSink) write(entry *accessLogEntry, line []byte) {
+	select {
+	case s.queue <- queuedEntry{entry: *entry, line: string(bytes.TrimRight(line, "\n"))}:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// run sends full batches right away and partial ones every flush interval,
+// until the sink is closed
+func (s *httpSink) run() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]queuedEntry, 0, s.cfg.BatchSize)
+	flush := func() {
+		if dropped := s.dropped.Swap(0); dropped > 0 {
+			lg.GetInstance().Warn("Dropped %d access log entries, the %s queue is full", dropped, s.cfg.URL)
+		}
+		if len(batch) == 0 {
+			return
+		}
+		s.send(batch)
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case entry := <-s.queue:
+			batch = append(batch, entry)
+			if len(batch) >= s.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.done:
+			for {
+				select {
+				case entry := <-s.queue:
+					batch = append(batch, entry)
+					if len(batch) >= s.cfg.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send posts a batch, retrying failed requests and server errors
+func (s *httpSink) send(batch []queuedEntry) {
+	body, contentType, err := s.payload(batch)
+	if err != nil {
+		lg.GetInstance().Error("Failed to encode access log batch: %v", err)
+		return
+	}
+
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(body, contentType)
+		if err == nil {
+			return
+		}
+		if !retry || attempt >= s.cfg.MaxRetries {
+			lg.GetInstance().Warn("Dropped %d access log entries after sending them to %s failed: %v", len(batch), s.cfg.URL, err)
+			return
+		}
+		// Exponential backoff with jitter, so replicas don't retry in step
+		backoff := sinkRetryBackoff << attempt
+		time.Sleep(backoff/2 + rand.N(backoff/2))
+	}
+}
+
+// post sends one request, reporting whether a failure is worth a retry
+func (s *httpSink) post(body []byte, contentType string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for name, value := range s.cfg.Headers {
+		// Resolved per request so rotated credentials are picked up
+		resolved, err := config.ResolveCredential(value)
+		if err != nil {
+			return false, fmt.Errorf("header %s: %w", name, err)
+		}
+		req.Header.Set(name, resolved)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+}
+
+// payload encodes a batch as JSON lines or as an OTLP logs export request
+func (s *httpSink) payload(batch []queuedEntry) ([]byte, string, error) {
+	if s.cfg.Format != "otlp" {
+		var buf bytes.Buffer
+		for _, queued := range batch {
+			line, err := json.Marshal(queued.entry)
+			if err != nil {
+				return nil, "", err
+			}
+			buf.Write(line)
+			buf.WriteByte('\n')
+		}
+		return buf.Bytes(), "application/x-ndjson", nil
+	}
+
+	records := make([]*logspb.LogRecord, 0, len(batch))
+	for _, queued := range batch {
+		records = append(records, accessLogRecord(&queued.entry, queued.line).Proto())
+	}
+	data, err := proto.Marshal(telemetry.ExportRequest(accessLogResource, "nexus/access", records))
+	return data, "application/x-protobuf", err
+}
+
+// accessLogResource describes nexus in the OTLP access logs of HTTP sinks
+var accessLogResource = resource.NewSchemaless(attribute.String("service.name", defaultSyslogAppName))
+
+// accessLogRecord is the log record of an access log entry, the line is its
+// body and the fields are attributes
+func accessLogRecord(e *accessLogEntry, line string) telemetry.LogRecord {
+	severity := lg.LevelInfo
+	if e.Status >= http.StatusInternalServerError {
+		severity = lg.LevelWarn
+	}
+	attributes := []attribute.KeyValue{
+		attribute.String("client.address", e.Remote),
+		attribute.String("http.request.method", e.Method),
+		attribute.String("server.address", e.Host),
+		attribute.String("url.original", e.URI),
+		attribute.String("network.protocol.name", e.Proto),
+		attribute.Int("http.response.status_code", e.Status),
+		attribute.Int64("http.response.body.size", e.Bytes),
+		attribute.Float64("nexus.latency_ms", e.LatencyMs),
+	}
+	for key, value := range map[string]string{
+		"user_agent.original":         e.UserAgent,
+		"http.request.header.referer": e.Referer,
+		"nexus.request_id":            e.RequestID,
+		"nexus.route":                 e.Route,
+		"nexus.service":               e.Service,
+		"nexus.backend":               e.Backend,
+	} {
+		if value != "" {
+			attributes = append(attributes, attribute.String(key, value))
+		}
+	}
+	for name, value := range e.Labels {
+		attributes = append(attributes, attribute.String(labelPrefix+name, value))
+	}
+	attributes = append(attributes, tagAttributes(e.Tags)...)
+	slices.SortFunc(attributes, func(a, b attribute.KeyValue) int { return strings.Compare(string(a.Key), string(b.Key)) })
+
+	record := telemetry.LogRecord{Time: e.Time, Severity: severity, Body: line, Attributes: attributes}
+	record.TraceID, _ = trace.TraceIDFromHex(e.TraceID)
+	record.SpanID, _ = trace.SpanIDFromHex(e.SpanID)
+	return record
+}
+
+func (s *httpSink) close() {
+	s.once.Do(func() { close(s.done) })
+	<-s.stopped
+}
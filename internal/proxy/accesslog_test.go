@@ -0,0 +1,234 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// newAccessLogTest returns a proxy forwarding /api to a backend answering
+// status
+func newAccessLogTest(t *testing.T, status int) *Proxy {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(backend.Close)
+
+	routes := []*config.RouteConfig{{Name: "api", Match: config.RouteMatch{Path: "/api"}, Service: "api-service"}}
+	services := map[string]*config.ServiceConfig{
+		"api-service": {
+			Name:         "api-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+	}
+	p := NewProxy(route.NewRouter(routes, services))
+	t.Cleanup(p.CloseLogs)
+	return p
+}
+
+// collector records the batches posted to it, failing the first ones
+type collector struct {
+	mu          sync.Mutex
+	failures    int
+	bodies      [][]byte
+	contentType string
+	auth        string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failures > 0 {
+		c.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	c.bodies = append(c.bodies, body)
+	c.contentType = r.Header.Get("Content-Type")
+	c.auth = r.Header.Get("Authorization")
+}
+
+func (c *collector) batches() [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([][]byte(nil), c.bodies...)
+}
+
+func TestAccessLog_SyslogSink(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	p := newAccessLogTest(t, http.StatusBadGateway)
+	require.NoError(t, p.SetMiddleware([]*config.MiddlewareConfig{{
+		Type:   config.MiddlewareAccessLog,
+		Sink:   config.AccessLogSinkSyslog,
+		Syslog: &config.SyslogSinkConfig{Address: conn.LocalAddr().String(), Facility: "daemon", AppName: "edge"},
+	}}))
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api", nil))
+
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	msg := string(buf[:n])
+	// daemon (3) * 8 + warning (4), server errors are warnings
+	assert.True(t, strings.HasPrefix(msg, "<28>1 "), msg)
+	fields := strings.SplitN(msg, " ", 8)
+	require.Len(t, fields, 8)
+	assert.Equal(t, "edge", fields[3])
+	assert.Equal(t, "access", fields[5])
+	assert.Equal(t, "-", fields[6])
+	assert.Contains(t, fields[7], `"GET /api HTTP/1.1" 502`)
+	assert.False(t, strings.HasSuffix(msg, "\n"))
+}
+
+func TestAccessLog_SyslogSinkTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	frames := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for i := 0; i < 2; i++ {
+			var length int
+			if _, err := fmt.Fscanf(reader, "%d ", &length); err != nil {
+				return
+			}
+			frame := make([]byte, length)
+			if _, err := io.ReadFull(reader, frame); err != nil {
+				return
+			}
+			frames <- string(frame)
+		}
+	}()
+
+	p := newAccessLogTest(t, http.StatusOK)
+	require.NoError(t, p.SetMiddleware([]*config.MiddlewareConfig{{
+		Type:   config.MiddlewareAccessLog,
+		Format: "json",
+		Sink:   config.AccessLogSinkSyslog,
+		Syslog: &config.SyslogSinkConfig{Network: "tcp", Address: ln.Addr().String()},
+	}}))
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api", nil))
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api", nil))
+
+	// Octet counted frames of local0 (16) * 8 + informational (6)
+	for _, method := range []string{"GET", "POST"} {
+		select {
+		case frame := <-frames:
+			assert.True(t, strings.HasPrefix(frame, "<134>1 "), frame)
+			assert.Contains(t, frame, `"method":"`+method+`"`)
+		case <-time.After(time.Second):
+			t.Fatal("no syslog frame received")
+		}
+	}
+}
+
+func TestAccessLog_HTTPSinkBatchesAndRetries(t *testing.T) {
+	sink := &collector{failures: 1}
+	server := httptest.NewServer(sink)
+	defer server.Close()
+	t.Setenv("SINK_TOKEN", "secret")
+
+	p := newAccessLogTest(t, http.StatusOK)
+	require.NoError(t, p.SetMiddleware([]*config.MiddlewareConfig{{
+		Type: config.MiddlewareAccessLog,
+		Sink: config.AccessLogSinkHTTP,
+		HTTP: &config.HTTPSinkConfig{
+			URL:           server.URL,
+			Headers:       map[string]string{"Authorization": "env://SINK_TOKEN"},
+			BatchSize:     2,
+			FlushInterval: time.Hour,
+		},
+	}}))
+	for i := 0; i < 3; i++ {
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api", nil))
+	}
+
+	// The full batch is sent once the collector recovers
+	require.Eventually(t, func() bool { return len(sink.batches()) == 1 }, 2*time.Second, 5*time.Millisecond)
+	lines := strings.Split(strings.TrimSpace(string(sink.batches()[0])), "\n")
+	require.Len(t, lines, 2)
+	var entry accessLogEntry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "/api", entry.URI)
+	assert.Equal(t, "api", entry.Route)
+	assert.Equal(t, "application/x-ndjson", sink.contentType)
+	assert.Equal(t, "secret", sink.auth)
+
+	// The partial batch is sent when the sink is no longer used
+	require.NoError(t, p.SetMiddleware(nil))
+	require.Len(t, sink.batches(), 2)
+	assert.Equal(t, 1, strings.Count(string(sink.batches()[1]), "\n"))
+}
+
+func TestAccessLog_HTTPSinkOTLP(t *testing.T) {
+	sink := &collector{}
+	server := httptest.NewServer(sink)
+	defer server.Close()
+
+	p := newAccessLogTest(t, http.StatusServiceUnavailable)
+	spans := tracetest.NewInMemoryExporter()
+	p.tracer = sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans)).Tracer("test")
+	require.NoError(t, p.SetMiddleware([]*config.MiddlewareConfig{{
+		Type: config.MiddlewareAccessLog,
+		Sink: config.AccessLogSinkHTTP,
+		HTTP: &config.HTTPSinkConfig{URL: server.URL, Format: "otlp"},
+	}}))
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api", nil))
+	p.CloseLogs()
+	var span trace.SpanContext
+	for _, stub := range spans.GetSpans() {
+		if stub.Name == "Proxy.Request" {
+			span = stub.SpanContext
+		}
+	}
+	require.True(t, span.IsValid())
+
+	require.Len(t, sink.batches(), 1)
+	assert.Equal(t, "application/x-protobuf", sink.contentType)
+	var req collogspb.ExportLogsServiceRequest
+	require.NoError(t, proto.Unmarshal(sink.batches()[0], &req))
+	require.Len(t, req.ResourceLogs, 1)
+	records := req.ResourceLogs[0].ScopeLogs[0].LogRecords
+	require.Len(t, records, 1)
+	assert.Equal(t, "WARN", records[0].SeverityText)
+	// Correlated with the request span
+	traceID, spanID := span.TraceID(), span.SpanID()
+	assert.Equal(t, traceID[:], records[0].TraceId)
+	assert.Equal(t, spanID[:], records[0].SpanId)
+	assert.Contains(t, records[0].Body.GetStringValue(), `"GET /api HTTP/1.1" 503`)
+	attributes := make(map[string]string)
+	for _, kv := range records[0].Attributes {
+		attributes[kv.Key] = kv.Value.String()
+	}
+	assert.Contains(t, attributes["http.response.status_code"], "503")
+	assert.Contains(t, attributes["nexus.route"], "api")
+}
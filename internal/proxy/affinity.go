@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/service"
+)
+
+// affinityCookieName returns the cookie carrying the affinity of a service
+func affinityCookieName(svcConfig *config.ServiceConfig) string {
+	if svcConfig.Affinity.Cookie != "" {
+		return svcConfig.Affinity.Cookie
+	}
+	return "nexus_affinity_" + svcConfig.Name
+}
+
+// affinityServerID is an opaque, stable identifier of a backend so cookies
+// don't expose upstream addresses
+func affinityServerID(address string) string {
+	sum := sha256.Sum256([]byte(address))
+	return hex.EncodeToString(sum[:8])
+}
+
+// affinitySignature signs a cookie payload for a service
+func affinitySignature(secret, serviceName, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(serviceName))
+	mac.Write([]byte{0})
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// affinityServer returns the backend pinned by a valid affinity cookie, if it
+// is still configured and in rotation
+func affinityServer(svc service.Service, r *http.Request, now time.Time) (string, bool) {
+	svcConfig := svc.Config()
+	cookie, err := r.Cookie(affinityCookieName(svcConfig))
+	if err != nil {
+		return "", false
+	}
+
+	// Cookie format: <server id>.<expiry unix seconds>.<signature>
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 {
+		return "", false
+	}
+	payload := parts[0] + "." + parts[1]
+
+	valid := false
+	for _, ref := range svcConfig.Affinity.Secrets {
+		// References were resolved when the config was validated
+		secret, err := config.ResolveCredential(ref)
+		if err != nil {
+			continue
+		}
+		expected := affinitySignature(secret, svcConfig.Name, payload)
+		if hmac.Equal([]byte(expected), []byte(parts[2])) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return "", false
+	}
+
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || (expiry != 0 && now.Unix() >= expiry) {
+		return "", false
+	}
+
+	drained := svc.Drained()
+	for _, server := range svcConfig.Servers {
+		if affinityServerID(server.Address) != parts[0] {
+			continue
+		}
+		if _, ok := drained[server.Address]; ok {
+			return "", false
+		}
+		return server.Address, true
+	}
+
+	return "", false
+}
+
+// affinityCookie builds the signed cookie pinning a client to a backend, nil
+// when the signing secret can't be resolved
+func affinityCookie(svcConfig *config.ServiceConfig, address string, now time.Time) *http.Cookie {
+	affinity := svcConfig.Affinity
+	secret, err := config.ResolveCredential(affinity.Secrets[0])
+	if err != nil {
+		return nil
+	}
+	cookie := &http.Cookie{
+		Name:     affinityCookieName(svcConfig),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   affinity.Secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	var expiry int64
+	if affinity.TTL > 0 {
+		expiresAt := now.Add(affinity.TTL)
+		expiry = expiresAt.Unix()
+		cookie.Expires = expiresAt
+		cookie.MaxAge = int(affinity.TTL.Seconds())
+	}
+
+	payload := affinityServerID(address) + "." + strconv.FormatInt(expiry, 10)
+	cookie.Value = payload + "." + affinitySignature(secret, svcConfig.Name, payload)
+
+	return cookie
+}
@@ -0,0 +1,169 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+	"nexus/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAffinitySecret = "0123456789abcdef"
+
+func newAffinityTestProxy(t *testing.T, secrets ...string) (*Proxy, route.Router, []string) {
+	t.Helper()
+
+	var addresses []string
+	var servers []config.ServerConfig
+	for _, name := range []string{"a", "b"} {
+		name := name
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Backend", name)
+		}))
+		t.Cleanup(backend.Close)
+		addresses = append(addresses, backend.URL)
+		servers = append(servers, config.ServerConfig{Address: backend.URL})
+	}
+
+	router := route.NewRouter([]*config.RouteConfig{
+		{Name: "app-route", Match: config.RouteMatch{Path: "/app"}, Service: "app"},
+	}, map[string]*config.ServiceConfig{
+		"app": {
+			Name:         "app",
+			BalancerType: "round_robin",
+			Servers:      servers,
+			Affinity:     &config.AffinityConfig{Secrets: secrets, TTL: time.Hour},
+		},
+	})
+
+	return NewProxy(router), router, addresses
+}
+
+func doAffinityRequest(p *Proxy, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/app", nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+	return rec
+}
+
+func responseCookie(t *testing.T, rec *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	return cookies[0]
+}
+
+func TestAffinity_StickyCookie(t *testing.T) {
+	p, _, _ := newAffinityTestProxy(t, testAffinitySecret)
+
+	rec := doAffinityRequest(p, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	cookie := responseCookie(t, rec)
+	assert.Equal(t, "nexus_affinity_app", cookie.Name)
+	assert.True(t, cookie.HttpOnly)
+	backend := rec.Header().Get("X-Backend")
+
+	// Round robin would alternate, the cookie keeps the client on one backend
+	for i := 0; i < 4; i++ {
+		rec = doAffinityRequest(p, cookie)
+		assert.Equal(t, backend, rec.Header().Get("X-Backend"))
+		assert.Empty(t, rec.Result().Cookies())
+	}
+}
+
+func TestAffinity_SharedAcrossReplicas(t *testing.T) {
+	p, router, _ := newAffinityTestProxy(t, testAffinitySecret)
+	cookie := responseCookie(t, doAffinityRequest(p, nil))
+
+	// A second proxy with the same secret honors cookies it did not issue
+	replica := NewProxy(router)
+	first := doAffinityRequest(p, cookie).Header().Get("X-Backend")
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, first, doAffinityRequest(replica, cookie).Header().Get("X-Backend"))
+	}
+}
+
+func TestAffinity_SecretRotation(t *testing.T) {
+	const newSecret = "fedcba9876543210"
+	servers := []config.ServerConfig{{Address: "http://backend1"}, {Address: "http://backend2"}}
+	oldConfig := &config.ServiceConfig{Name: "app", Servers: servers, Affinity: &config.AffinityConfig{Secrets: []string{testAffinitySecret}}}
+	rotated := service.NewService(&config.ServiceConfig{
+		Name:     "app",
+		Servers:  servers,
+		Affinity: &config.AffinityConfig{Secrets: []string{newSecret, testAffinitySecret}},
+	})
+	now := time.Now()
+
+	// Cookies signed with the previous secret keep working during rotation
+	req := httptest.NewRequest(http.MethodGet, "/app", nil)
+	req.AddCookie(affinityCookie(oldConfig, "http://backend2", now))
+	target, ok := affinityServer(rotated, req, now)
+	assert.True(t, ok)
+	assert.Equal(t, "http://backend2", target)
+
+	// New cookies are signed with the first secret only
+	fresh := affinityCookie(rotated.Config(), "http://backend2", now)
+	assert.NotEqual(t, affinityCookie(oldConfig, "http://backend2", now).Value, fresh.Value)
+	req = httptest.NewRequest(http.MethodGet, "/app", nil)
+	req.AddCookie(fresh)
+	_, ok = affinityServer(service.NewService(oldConfig), req, now)
+	assert.False(t, ok)
+}
+
+func TestAffinity_InvalidCookieIsReplaced(t *testing.T) {
+	p, _, _ := newAffinityTestProxy(t, testAffinitySecret)
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"Malformed", "garbage"},
+		{"BadSignature", affinityServerID("http://unknown") + ".0.c2lnbmF0dXJl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doAffinityRequest(p, &http.Cookie{Name: "nexus_affinity_app", Value: tt.value})
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.NotEqual(t, tt.value, responseCookie(t, rec).Value)
+		})
+	}
+}
+
+func TestAffinity_DrainedServerIsReassigned(t *testing.T) {
+	p, router, addresses := newAffinityTestProxy(t, testAffinitySecret)
+
+	svcConfig := router.Services()["app"].Config()
+	cookie := affinityCookie(svcConfig, addresses[0], time.Now())
+	assert.Equal(t, "a", doAffinityRequest(p, cookie).Header().Get("X-Backend"))
+
+	require.NoError(t, router.Services()["app"].Drain(addresses[0], "manual"))
+	rec := doAffinityRequest(p, cookie)
+	assert.Equal(t, "b", rec.Header().Get("X-Backend"))
+	assert.NotEqual(t, cookie.Value, responseCookie(t, rec).Value)
+}
+
+func TestAffinity_ExpiredCookie(t *testing.T) {
+	_, router, addresses := newAffinityTestProxy(t, testAffinitySecret)
+	svc := router.Services()["app"]
+
+	issued := time.Now().Add(-2 * time.Hour)
+	cookie := affinityCookie(svc.Config(), addresses[0], issued)
+
+	req := httptest.NewRequest(http.MethodGet, "/app", nil)
+	req.AddCookie(cookie)
+	_, ok := affinityServer(svc, req, issued.Add(time.Minute))
+	assert.True(t, ok)
+	_, ok = affinityServer(svc, req, time.Now())
+	assert.False(t, ok)
+}
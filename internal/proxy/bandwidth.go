@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"nexus/internal/config"
+)
+
+// bandwidthIdleTimeout is how long shared buckets are kept without traffic
+const bandwidthIdleTimeout = time.Minute
+
+// tokenBucket paces transfers at rate bytes per second, bursts up to burst
+// bytes pass without waiting
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst int64) *tokenBucket {
+	if burst <= 0 {
+		burst = rate
+	}
+	return &tokenBucket{rate: float64(rate), burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// wait takes n bytes from the bucket, waiting until they are available or ctx
+// is done. Concurrent users of a shared bucket queue behind each other
+func (b *tokenBucket) wait(ctx context.Context, n int) error {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens -= float64(n)
+	deficit := -b.tokens
+	b.mu.Unlock()
+
+	if deficit <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(time.Duration(deficit / b.rate * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// chunk returns the largest transfer taken from the bucket at once, so large
+// writes are paced instead of sent in one burst after a long wait
+func (b *tokenBucket) chunk() int {
+	return max(int(b.burst), 1)
+}
+
+// idle reports whether the bucket had no traffic for d and is full again
+func (b *tokenBucket) idle(now time.Time, d time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return now.Sub(b.last) > d && b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
+
+// bandwidthBuckets holds the buckets shared by the requests of a key
+type bandwidthBuckets struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+func newBandwidthBuckets() *bandwidthBuckets {
+	return &bandwidthBuckets{buckets: make(map[string]*tokenBucket), lastPrune: time.Now()}
+}
+
+// get returns the bucket of a key, creating it with rate and burst. Buckets
+// of a changed rate are replaced, idle buckets are dropped now and then
+func (s *bandwidthBuckets) get(key string, rate, burst int64) *tokenBucket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastPrune) > bandwidthIdleTimeout {
+		for k, b := range s.buckets {
+			if b.idle(now, bandwidthIdleTimeout) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastPrune = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok || b.rate != float64(rate) || (burst > 0 && b.burst != float64(burst)) {
+		b = newTokenBucket(rate, burst)
+		s.buckets[key] = b
+	}
+	return b
+}
+
+// bandwidthBucket returns the bucket pacing one direction of a request,
+// requests only share buckets with a key other than request
+func (p *Proxy) bandwidthBucket(r *http.Request, route *config.RouteConfig, direction string, rate int64) *tokenBucket {
+	cfg := route.Bandwidth
+	if cfg.Key == "" || cfg.Key == "request" {
+		return newTokenBucket(rate, cfg.Burst)
+	}
+	key := route.Name + ":" + direction + ":" + rateLimitKey(cfg.Key, r)
+	return p.bandwidth.get(key, rate, cfg.Burst)
+}
+
+// bandwidthMiddleware throttles the request and response bodies of routes
+// with a bandwidth limit
+func (p *Proxy) bandwidthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match := p.match(r)
+		if match.route == nil || match.route.Bandwidth == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cfg := match.route.Bandwidth
+		if cfg.Request > 0 && r.Body != nil && r.Body != http.NoBody {
+			r.Body = &throttledBody{ReadCloser: r.Body, ctx: r.Context(), bucket: p.bandwidthBucket(r, match.route, "request", cfg.Request)}
+		}
+		if cfg.Response > 0 {
+			w = &throttledWriter{ResponseWriter: w, ctx: r.Context(), bucket: p.bandwidthBucket(r, match.route, "response", cfg.Response)}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// throttledBody paces the reads of a request body
+type throttledBody struct {
+	io.ReadCloser
+	ctx    context.Context
+	bucket *tokenBucket
+}
+
+func (b *throttledBody) Read(p []byte) (int, error) {
+	if chunk := b.bucket.chunk(); len(p) > chunk {
+		p = p[:chunk]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		if werr := b.bucket.wait(b.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// throttledWriter paces the writes of a response body
+type throttledWriter struct {
+	http.ResponseWriter
+	ctx    context.Context
+	bucket *tokenBucket
+}
+
+func (w *throttledWriter) Write(b []byte) (int, error) {
+	written := 0
+	chunk := w.bucket.chunk()
+	for len(b) > 0 {
+		n := min(len(b), chunk)
+		if err := w.bucket.wait(w.ctx, n); err != nil {
+			return written, err
+		}
+		n, err := w.ResponseWriter.Write(b[:n])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+		// Paced bytes must leave now, not when the write buffer fills
+		if len(b) > 0 {
+			http.NewResponseController(w.ResponseWriter).Flush()
+		}
+	}
+	return written, nil
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *throttledWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_Bandwidth(t *testing.T) {
+	body := strings.Repeat("x", 30000)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(io.Discard, r.Body)
+		if n > 0 {
+			return
+		}
+		io.WriteString(w, body)
+	}))
+	defer backend.Close()
+
+	limit := &config.BandwidthConfig{Response: 100000, Request: 100000, Burst: 10000}
+	shared := &config.BandwidthConfig{Response: 100000, Burst: 10000, Key: "route"}
+	routes := []*config.RouteConfig{
+		{Name: "limited", Match: config.RouteMatch{Path: "/limited"}, Service: "api", Bandwidth: limit},
+		{Name: "shared", Match: config.RouteMatch{Path: "/shared"}, Service: "api", Bandwidth: shared},
+		{Name: "plain", Match: config.RouteMatch{Path: "/plain"}, Service: "api"},
+	}
+	services := map[string]*config.ServiceConfig{
+		"api": {Name: "api", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: backend.URL}}},
+	}
+	p := NewProxy(route.NewRouter(routes, services))
+	front := httptest.NewServer(p)
+	defer front.Close()
+
+	timed := func(method, path string, reqBody io.Reader) (time.Duration, int) {
+		req, err := http.NewRequest(method, front.URL+path, reqBody)
+		require.NoError(t, err)
+		start := time.Now()
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return time.Since(start), len(data)
+	}
+
+	// 20KB beyond the burst at 100KB/s
+	elapsed, n := timed(http.MethodGet, "/limited", nil)
+	assert.Equal(t, len(body), n)
+	assert.GreaterOrEqual(t, elapsed, 150*time.Millisecond)
+
+	elapsed, _ = timed(http.MethodPost, "/limited", bytes.NewReader([]byte(body)))
+	assert.GreaterOrEqual(t, elapsed, 150*time.Millisecond)
+
+	elapsed, n = timed(http.MethodGet, "/plain", nil)
+	assert.Equal(t, len(body), n)
+	assert.Less(t, elapsed, 150*time.Millisecond)
+
+	// Requests of a shared key start where the previous one left the bucket
+	timed(http.MethodGet, "/shared", nil)
+	elapsed, _ = timed(http.MethodGet, "/shared", nil)
+	assert.GreaterOrEqual(t, elapsed, 250*time.Millisecond)
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(1000, 0)
+	assert.Equal(t, 1000, b.chunk())
+	require.NoError(t, b.wait(context.Background(), 1000))
+
+	// Waits give up with their context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, b.wait(ctx, 1000), context.DeadlineExceeded)
+
+	buckets := newBandwidthBuckets()
+	first := buckets.get("a", 1000, 0)
+	assert.Same(t, first, buckets.get("a", 1000, 0))
+	assert.NotSame(t, first, buckets.get("a", 2000, 0))
+}
@@ -0,0 +1,161 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"sync/atomic"
+
+	"nexus/internal/config"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+)
+
+// defaultMaxBufferSize is the largest response buffered when max_buffer_size is not set
+const defaultMaxBufferSize = 1 << 20
+
+// bufferUsage tracks the response bytes a route holds in memory
+type bufferUsage struct {
+	current atomic.Int64
+	peak    atomic.Int64
+}
+
+func (u *bufferUsage) add(n int64) {
+	current := u.current.Add(n)
+	for {
+		peak := u.peak.Load()
+		if current <= peak || u.peak.CompareAndSwap(peak, current) {
+			return
+		}
+	}
+}
+
+// bufferUsage returns the buffer usage of a route
+func (p *Proxy) bufferUsage(routeName string) *bufferUsage {
+	usage, _ := p.bufferUsages.LoadOrStore(routeName, &bufferUsage{})
+	return usage.(*bufferUsage)
+}
+
+// routeReverseProxy returns the reverse proxy forwarding the requests of a
+// route, routes with their own flush interval or copy buffer get a copy of
+// the shared one
+func (p *Proxy) routeReverseProxy(route *config.RouteConfig) *httputil.ReverseProxy {
+	if route == nil || route.Buffering == nil || (route.Buffering.FlushInterval == 0 && route.Buffering.BufferSize == 0) {
+		return p.reverseProxy
+	}
+
+	rp := *p.reverseProxy
+	rp.FlushInterval = route.Buffering.FlushInterval
+	if route.Buffering.BufferSize > 0 {
+		rp.BufferPool = newBufferPool(route.Buffering.BufferSize)
+	}
+	return &rp
+}
+
+// bufferingTransport reads responses from the backend before they are sent,
+// so the backend connection is released without waiting for the client.
+// Responses larger than the buffer are sent from the buffer and then streamed
+type bufferingTransport struct {
+	next    http.RoundTripper
+	maxSize int64
+	usage   *bufferUsage
+}
+
+func newBufferingTransport(next http.RoundTripper, cfg *config.BufferingConfig, usage *bufferUsage) *bufferingTransport {
+	maxSize := cfg.MaxBufferSize
+	if maxSize == 0 {
+		maxSize = defaultMaxBufferSize
+	}
+
+	return &bufferingTransport{next: next, maxSize: maxSize, usage: usage}
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *bufferingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.Body == nil || resp.Body == http.NoBody || resp.StatusCode == http.StatusSwitchingProtocols {
+		return resp, err
+	}
+	// Known to be too large, or a stream of events
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.ContentLength > t.maxSize || mediaType == "text/event-stream" {
+		return resp, nil
+	}
+
+	var buf bytes.Buffer
+	if resp.ContentLength > 0 {
+		buf.Grow(int(resp.ContentLength))
+	}
+	n, err := buf.ReadFrom(io.LimitReader(resp.Body, t.maxSize+1))
+	t.usage.add(int64(buf.Cap()))
+	body := &bufferedBody{Reader: bytes.NewReader(buf.Bytes()), usage: t.usage, size: int64(buf.Cap())}
+	if err != nil {
+		body.Close()
+		resp.Body.Close()
+		return nil, err
+	}
+	if n > t.maxSize {
+		// Larger than the buffer, the rest is streamed
+		body.Reader = io.MultiReader(body.Reader, resp.Body)
+		body.upstream = resp.Body
+	} else {
+		resp.Body.Close()
+	}
+	resp.Body = body
+
+	return resp, nil
+}
+
+// bufferedBody releases the buffer of a response once it is closed
+type bufferedBody struct {
+	io.Reader
+	upstream io.Closer // Rest of responses larger than the buffer
+	usage    *bufferUsage
+	size     int64
+	closed   bool
+}
+
+func (b *bufferedBody) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	b.usage.add(-b.size)
+	if b.upstream != nil {
+		return b.upstream.Close()
+	}
+	return nil
+}
+
+// registerBufferMetrics reports the buffer usage of the routes as
+// OpenTelemetry metrics
+func (p *Proxy) registerBufferMetrics(meter otelmetric.Meter) error {
+	usage, err := meter.Int64ObservableGauge("nexus.proxy.buffer.usage",
+		otelmetric.WithDescription("Response bytes buffered by a route"),
+		otelmetric.WithUnit("By"))
+	if err != nil {
+		return err
+	}
+	peak, err := meter.Int64ObservableGauge("nexus.proxy.buffer.peak",
+		otelmetric.WithDescription("Highest number of response bytes buffered by a route at once"),
+		otelmetric.WithUnit("By"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o otelmetric.Observer) error {
+		p.bufferUsages.Range(func(key, value any) bool {
+			route := otelmetric.WithAttributes(attribute.String("route", key.(string)))
+			o.ObserveInt64(usage, value.(*bufferUsage).current.Load(), route)
+			o.ObserveInt64(peak, value.(*bufferUsage).peak.Load(), route)
+			return true
+		})
+		return nil
+	}, usage, peak)
+
+	return err
+}
@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// roundTripperFunc adapts a function to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// closeTracker records whether a response body was closed
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestBufferingTransport(t *testing.T) {
+	upstream := &closeTracker{Reader: strings.NewReader("buffered")}
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: upstream, ContentLength: -1}, nil
+	})
+	usage := &bufferUsage{}
+	rt := newBufferingTransport(next, &config.BufferingConfig{Response: true, MaxBufferSize: 16}, usage)
+
+	// The backend response is read and released before it is sent
+	resp, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+	assert.True(t, upstream.closed)
+	assert.Positive(t, usage.current.Load())
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "buffered", string(body))
+	require.NoError(t, resp.Body.Close())
+	assert.Zero(t, usage.current.Load())
+	assert.GreaterOrEqual(t, usage.peak.Load(), int64(len("buffered")))
+
+	// Larger responses are streamed past the buffer
+	upstream = &closeTracker{Reader: strings.NewReader(strings.Repeat("x", 40))}
+	resp, err = rt.RoundTrip(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+	assert.False(t, upstream.closed)
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("x", 40), string(body))
+	require.NoError(t, resp.Body.Close())
+	assert.True(t, upstream.closed)
+	assert.Zero(t, usage.current.Load())
+
+	// Responses known to be too large are not buffered
+	upstream = &closeTracker{Reader: strings.NewReader(strings.Repeat("x", 40))}
+	next = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: upstream, ContentLength: 40}, nil
+	})
+	rt = newBufferingTransport(next, &config.BufferingConfig{Response: true, MaxBufferSize: 16}, usage)
+	resp, err = rt.RoundTrip(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+	assert.Same(t, upstream, resp.Body)
+}
+
+func TestProxy_Buffering(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("chunk"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(" done"))
+	}))
+	defer backend.Close()
+
+	routes := []*config.RouteConfig{
+		{
+			Name:      "download",
+			Match:     config.RouteMatch{Path: "/download"},
+			Service:   "api-service",
+			Buffering: &config.BufferingConfig{FlushInterval: -1, BufferSize: 1024},
+		},
+		{
+			Name:      "buffered",
+			Match:     config.RouteMatch{Path: "/buffered"},
+			Service:   "api-service",
+			Buffering: &config.BufferingConfig{Response: true},
+		},
+		{Name: "api", Match: config.RouteMatch{Path: "/api"}, Service: "api-service"},
+	}
+	services := map[string]*config.ServiceConfig{
+		"api-service": {
+			Name:         "api-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+	}
+	p := NewProxy(route.NewRouter(routes, services))
+
+	download := p.routeChain(p.match(httptest.NewRequest(http.MethodGet, "/download", nil))).reverseProxy
+	assert.NotSame(t, p.reverseProxy, download)
+	assert.Equal(t, time.Duration(-1), download.FlushInterval)
+	assert.Len(t, download.BufferPool.Get(), 1024)
+	assert.Same(t, p.reverseProxy, p.routeChain(p.match(httptest.NewRequest(http.MethodGet, "/api", nil))).reverseProxy)
+
+	for _, path := range []string{"/download", "/buffered", "/api"} {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.Equal(t, "chunk done", w.Body.String(), path)
+	}
+	assert.Zero(t, p.bufferUsage("buffered").current.Load())
+	assert.Positive(t, p.bufferUsage("buffered").peak.Load())
+}
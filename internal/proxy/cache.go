@@ -0,0 +1,333 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"nexus/internal/cache"
+	"nexus/internal/config"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// defaultCacheTTL is the freshness of responses without max-age or Expires
+	defaultCacheTTL = time.Minute
+	// defaultCacheMaxBodySize is the largest body stored when max_body_size is not set
+	defaultCacheMaxBodySize = 1 << 20
+)
+
+// cacheableStatus are the status codes stored by the response cache
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// SetCacheConfig sets the response cache config
+func (p *Proxy) SetCacheConfig(cfg config.CacheConfig) {
+	p.mu.Lock()
+	p.cacheConfig = cfg
+	p.mu.Unlock()
+
+	p.cache.SetMaxSize(cfg.MaxSize)
+}
+
+// Cache returns the response cache shared by the routes with a cache config
+func (p *Proxy) Cache() *cache.Cache {
+	return p.cache
+}
+
+// cacheMiddleware serves GET and HEAD requests of routes with a cache config
+// from the response cache and stores the cacheable responses of GET requests.
+// The headers of HEAD responses, and of GET responses too large to store,
+// are kept to answer later HEAD requests. Requests with credentials bypass
+// the cache, cookies only unless the route opts in
+func (p *Proxy) cacheMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match := p.match(r)
+		if match.route == nil || match.route.Cache == nil || r.Header.Get("Authorization") != "" ||
+			(!match.route.Cache.Cookies && r.Header.Get("Cookie") != "") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if isPreflight(r) && match.route.Cache.Preflight {
+			p.servePreflight(w, r, next, match.route.Cache)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		uri := r.URL.RequestURI()
+		encoding := r.Header.Get("Accept-Encoding")
+		key := cache.Key(r.Host, uri, encoding)
+		headKey := cache.Key(r.Host, uri, http.MethodHead+"\x00"+encoding)
+		if entry, ok := p.cache.Get(key); ok {
+			serveCached(w, r, entry)
+			return
+		}
+		if r.Method == http.MethodHead {
+			if entry, ok := p.cache.Get(headKey); ok {
+				serveCached(w, r, entry)
+				return
+			}
+		}
+		w.Header().Set("X-Cache", "MISS")
+
+		rec := p.cacheRecorder(w)
+		next.ServeHTTP(rec, r)
+		now := time.Now()
+		if r.Method == http.MethodHead {
+			if entry := rec.entry(match.route.Cache, now); entry != nil {
+				p.cache.Set(headKey, r.Host, uri, entry)
+			}
+			return
+		}
+		if entry := rec.entry(match.route.Cache, now); entry != nil {
+			p.cache.Set(key, r.Host, uri, entry)
+		} else if entry := rec.headEntry(match.route.Cache, now); entry != nil {
+			p.cache.Set(headKey, r.Host, uri, entry)
+		}
+	})
+}
+
+// cacheRecorder returns a recorder storing bodies up to the configured size
+func (p *Proxy) cacheRecorder(w http.ResponseWriter) *cacheRecorder {
+	p.mu.RLock()
+	cfg := p.cacheConfig
+	p.mu.RUnlock()
+	limit := cfg.MaxBodySize
+	if limit == 0 {
+		limit = defaultCacheMaxBodySize
+	}
+
+	return &cacheRecorder{ResponseWriter: w, limit: limit, tagHeader: cfg.TagHeader}
+}
+
+// isPreflight reports whether r is a CORS preflight request
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// servePreflight answers CORS preflight requests from the cache, responses
+// are stored per origin and requested method and headers
+func (p *Proxy) servePreflight(w http.ResponseWriter, r *http.Request, next http.Handler, cfg *config.ResponseCacheConfig) {
+	uri := r.URL.RequestURI()
+	key := cache.Key(r.Host, uri, strings.Join([]string{
+		http.MethodOptions,
+		r.Header.Get("Origin"),
+		r.Header.Get("Access-Control-Request-Method"),
+		strings.ToLower(r.Header.Get("Access-Control-Request-Headers")),
+	}, "\x00"))
+	if entry, ok := p.cache.Get(key); ok {
+		serveCached(w, r, entry)
+		return
+	}
+	w.Header().Set("X-Cache", "MISS")
+
+	rec := p.cacheRecorder(w)
+	next.ServeHTTP(rec, r)
+	if entry := rec.preflightEntry(cfg, time.Now()); entry != nil {
+		p.cache.Set(key, r.Host, uri, entry)
+	}
+}
+
+// serveCached replays a cached response, without body for HEAD requests
+func serveCached(w http.ResponseWriter, r *http.Request, entry *cache.Entry) {
+	for k, v := range entry.Header {
+		w.Header()[k] = append([]string(nil), v...)
+	}
+	w.Header().Set("Age", strconv.Itoa(int(time.Since(entry.Stored).Seconds())))
+	w.Header().Set("X-Cache", "HIT")
+	w.WriteHeader(entry.Status)
+	if r.Method != http.MethodHead {
+		w.Write(entry.Body)
+	}
+}
+
+// cacheRecorder forwards a response and keeps a copy to store it
+type cacheRecorder struct {
+	http.ResponseWriter
+	limit     int64
+	tagHeader string
+
+	status   int
+	header   http.Header
+	tags     []string
+	body     bytes.Buffer
+	overflow bool
+	written  int64
+}
+
+func (c *cacheRecorder) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+		// Tags are only meant for purging, clients don't see them
+		if c.tagHeader != "" {
+			for _, value := range c.Header().Values(c.tagHeader) {
+				c.tags = append(c.tags, strings.FieldsFunc(value, func(r rune) bool {
+					return r == ',' || unicode.IsSpace(r)
+				})...)
+			}
+			c.Header().Del(c.tagHeader)
+		}
+		c.header = c.Header().Clone()
+		c.header.Del("X-Cache")
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *cacheRecorder) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	c.written += int64(len(b))
+	if !c.overflow {
+		if int64(c.body.Len()+len(b)) > c.limit {
+			c.overflow = true
+			c.body.Reset()
+		} else {
+			c.body.Write(b)
+		}
+	}
+
+	return c.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (c *cacheRecorder) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// entry returns the recorded response when shared caches may store it
+func (c *cacheRecorder) entry(cfg *config.ResponseCacheConfig, now time.Time) *cache.Entry {
+	if c.overflow {
+		return nil
+	}
+	return c.store(cfg, now, bytes.Clone(c.body.Bytes()))
+}
+
+// headEntry returns the headers of a response whose body was too large to
+// store, they still answer HEAD requests
+func (c *cacheRecorder) headEntry(cfg *config.ResponseCacheConfig, now time.Time) *cache.Entry {
+	if !c.overflow {
+		return nil
+	}
+	entry := c.store(cfg, now, nil)
+	if entry != nil && entry.Header.Get("Content-Length") == "" {
+		entry.Header.Set("Content-Length", strconv.FormatInt(c.written, 10))
+	}
+	return entry
+}
+
+// preflightEntry returns a successful preflight response, fresh for its
+// Access-Control-Max-Age or the route TTL without one
+func (c *cacheRecorder) preflightEntry(cfg *config.ResponseCacheConfig, now time.Time) *cache.Entry {
+	if c.overflow || (c.status != http.StatusOK && c.status != http.StatusNoContent) || len(c.header.Values("Set-Cookie")) > 0 {
+		return nil
+	}
+	ttl := cfg.TTL
+	if ttl == 0 {
+		ttl = defaultCacheTTL
+	}
+	if maxAge := c.header.Get("Access-Control-Max-Age"); maxAge != "" {
+		seconds, err := strconv.Atoi(maxAge)
+		if err != nil {
+			return nil
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+	if fresh, ok := freshness(c.header, now); ttl <= 0 || (ok && fresh < 0) {
+		return nil
+	}
+
+	return &cache.Entry{
+		Status:  c.status,
+		Header:  c.header,
+		Body:    bytes.Clone(c.body.Bytes()),
+		Tags:    c.tags,
+		Stored:  now,
+		Expires: now.Add(ttl),
+	}
+}
+
+// store returns the response with body when shared caches may store it
+func (c *cacheRecorder) store(cfg *config.ResponseCacheConfig, now time.Time, body []byte) *cache.Entry {
+	if c.status == 0 || !cacheableStatus[c.status] || len(c.header.Values("Set-Cookie")) > 0 {
+		return nil
+	}
+	for _, vary := range c.header.Values("Vary") {
+		for _, name := range strings.Split(vary, ",") {
+			if name = strings.TrimSpace(name); name != "" && !strings.EqualFold(name, "Accept-Encoding") {
+				return nil
+			}
+		}
+	}
+
+	ttl, ok := freshness(c.header, now)
+	if !ok {
+		ttl = cfg.TTL
+		if ttl == 0 {
+			ttl = defaultCacheTTL
+		}
+	}
+	if ttl <= 0 {
+		return nil
+	}
+
+	return &cache.Entry{
+		Status:  c.status,
+		Header:  c.header,
+		Body:    body,
+		Tags:    c.tags,
+		Stored:  now,
+		Expires: now.Add(ttl),
+	}
+}
+
+// freshness returns how long a shared cache may store a response according to
+// its Cache-Control and Expires headers, a negative duration forbids it
+func freshness(header http.Header, now time.Time) (time.Duration, bool) {
+	var maxAge, sMaxAge time.Duration = -1, -1
+	for _, directive := range strings.Split(strings.Join(header.Values("Cache-Control"), ","), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store", "no-cache", "private":
+			return -1, true
+		case "max-age", "s-maxage":
+			seconds, err := strconv.Atoi(strings.Trim(value, `"`))
+			if err != nil {
+				return -1, true
+			}
+			if strings.EqualFold(name, "s-maxage") {
+				sMaxAge = time.Duration(seconds) * time.Second
+			} else {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+
+	switch {
+	case sMaxAge >= 0:
+		return sMaxAge, true
+	case maxAge >= 0:
+		return maxAge, true
+	}
+	if expires := header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return -1, true
+		}
+		return t.Sub(now), true
+	}
+
+	return 0, false
+}
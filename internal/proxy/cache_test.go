@@ -0,0 +1,285 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxy_Cache(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/static/app.js":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+			w.Header().Set("Cache-Tag", "assets, app")
+		case "/static/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "/static/cookie":
+			w.Header().Set("Set-Cookie", "session=1")
+		case "/static/vary":
+			w.Header().Set("Vary", "User-Agent")
+		case "/static/big":
+			w.Write([]byte(strings.Repeat("x", 64)))
+			return
+		case "/static/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte("body of " + r.URL.Path))
+	}))
+	defer backend.Close()
+
+	router := route.NewRouter([]*config.RouteConfig{
+		{
+			Name:    "static",
+			Match:   config.RouteMatch{Path: "/static/*"},
+			Service: "app-service",
+			Cache:   &config.ResponseCacheConfig{TTL: time.Minute},
+		},
+		{
+			Name:    "api",
+			Match:   config.RouteMatch{Path: "/api/*"},
+			Service: "app-service",
+		},
+	}, map[string]*config.ServiceConfig{
+		"app-service": {
+			Name:         "app-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+	})
+	p := NewProxy(router)
+	p.SetCacheConfig(config.CacheConfig{MaxBodySize: 28, TagHeader: "Cache-Tag"})
+
+	get := func(method, path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get(http.MethodGet, "/static/app.js", nil)
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	assert.Empty(t, w.Header().Get("Cache-Tag"))
+	w = get(http.MethodGet, "/static/app.js", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	assert.Equal(t, "0", w.Header().Get("Age"))
+	assert.Equal(t, "body of /static/app.js", w.Body.String())
+	assert.Empty(t, w.Header().Get("Cache-Tag"))
+
+	// HEAD requests are answered from the GET response
+	w = get(http.MethodHead, "/static/app.js", nil)
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	assert.Empty(t, w.Body.String())
+
+	// Variants by encoding and requests with credentials go to the backend
+	get(http.MethodGet, "/static/app.js", http.Header{"Accept-Encoding": {"gzip"}})
+	get(http.MethodGet, "/static/app.js", http.Header{"Authorization": {"Bearer token"}})
+	w = get(http.MethodGet, "/static/app.js", http.Header{"Cookie": {"session=1"}})
+	assert.Empty(t, w.Header().Get("X-Cache"))
+	assert.Equal(t, 4, requests["/static/app.js"])
+
+	// Purged entries are fetched again
+	assert.Equal(t, 2, p.Cache().PurgeTag("app"))
+	get(http.MethodGet, "/static/app.js", nil)
+	assert.Equal(t, 5, requests["/static/app.js"])
+
+	for _, path := range []string{"/static/default", "/static/private", "/static/cookie", "/static/vary", "/static/big", "/static/error", "/api/users"} {
+		get(http.MethodGet, path, nil)
+		w := get(http.MethodGet, path, nil)
+		if path == "/static/default" {
+			assert.Equal(t, "HIT", w.Header().Get("X-Cache"), path)
+			assert.Equal(t, 1, requests[path], path)
+		} else {
+			assert.NotEqual(t, "HIT", w.Header().Get("X-Cache"), path)
+			assert.Equal(t, 2, requests[path], path)
+		}
+	}
+}
+
+func TestProxy_CacheCookies(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		w.Write([]byte("body of " + r.URL.Path))
+	}))
+	defer backend.Close()
+
+	router := route.NewRouter([]*config.RouteConfig{
+		{
+			Name:    "assets",
+			Match:   config.RouteMatch{Path: "/assets/*"},
+			Service: "app-service",
+			Cache:   &config.ResponseCacheConfig{Cookies: true},
+		},
+		{
+			Name:    "pages",
+			Match:   config.RouteMatch{Path: "/pages/*"},
+			Service: "app-service",
+			Cache:   &config.ResponseCacheConfig{},
+		},
+	}, map[string]*config.ServiceConfig{
+		"app-service": {
+			Name:         "app-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+	})
+	p := NewProxy(router)
+
+	get := func(path, cookie string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if cookie != "" {
+			req.Header.Set("Cookie", cookie)
+		}
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, req)
+		return w
+	}
+
+	// Requests with cookies are neither stored nor answered from the cache
+	get("/pages/home", "session=alice")
+	get("/pages/home", "session=bob")
+	assert.Equal(t, 2, requests["/pages/home"])
+	get("/pages/home", "")
+	w := get("/pages/home", "session=alice")
+	assert.Empty(t, w.Header().Get("X-Cache"))
+	assert.Equal(t, 4, requests["/pages/home"])
+
+	// Unless the route opts in
+	get("/assets/app.js", "_ga=1")
+	w = get("/assets/app.js", "_ga=2")
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	assert.Equal(t, "body of /assets/app.js", w.Body.String())
+	assert.Equal(t, 1, requests["/assets/app.js"])
+}
+
+func TestProxy_CacheHeadAndPreflight(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Method+" "+r.URL.Path]++
+		mu.Unlock()
+
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", r.Header.Get("Origin"))
+			w.Header().Set("Access-Control-Allow-Methods", "GET, PUT")
+			if r.URL.Path != "/static/nocache" {
+				w.Header().Set("Access-Control-Max-Age", "600")
+			} else {
+				w.Header().Set("Access-Control-Max-Age", "0")
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		if r.Method == http.MethodGet {
+			w.Write([]byte(strings.Repeat("x", 64)))
+		}
+	}))
+	defer backend.Close()
+
+	router := route.NewRouter([]*config.RouteConfig{{
+		Name:    "static",
+		Match:   config.RouteMatch{Path: "/static/*"},
+		Service: "app-service",
+		Cache:   &config.ResponseCacheConfig{Preflight: true},
+	}}, map[string]*config.ServiceConfig{
+		"app-service": {Name: "app-service", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: backend.URL}}},
+	})
+	p := NewProxy(router)
+	p.SetCacheConfig(config.CacheConfig{MaxBodySize: 16})
+
+	do := func(method, path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, req)
+		return w
+	}
+
+	// The body is too large to store, its headers still answer HEAD requests
+	do(http.MethodGet, "/static/big", nil)
+	w := do(http.MethodHead, "/static/big", nil)
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	assert.Equal(t, "64", w.Header().Get("Content-Length"))
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	assert.Equal(t, "MISS", do(http.MethodGet, "/static/big", nil).Header().Get("X-Cache"))
+	assert.Equal(t, 2, requests["GET /static/big"])
+	assert.Zero(t, requests["HEAD /static/big"])
+
+	// HEAD responses answer the next HEAD requests but not GET requests
+	do(http.MethodHead, "/static/head", nil)
+	assert.Equal(t, "HIT", do(http.MethodHead, "/static/head", nil).Header().Get("X-Cache"))
+	assert.Equal(t, "MISS", do(http.MethodGet, "/static/head", nil).Header().Get("X-Cache"))
+	assert.Equal(t, 1, requests["HEAD /static/head"])
+
+	// Preflights are cached per origin and requested method
+	preflight := func(path, origin, method string) *httptest.ResponseRecorder {
+		return do(http.MethodOptions, path, http.Header{"Origin": {origin}, "Access-Control-Request-Method": {method}})
+	}
+	preflight("/static/app", "https://a.example.com", "PUT")
+	w = preflight("/static/app", "https://a.example.com", "PUT")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	assert.Equal(t, "https://a.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	preflight("/static/app", "https://b.example.com", "PUT")
+	preflight("/static/app", "https://a.example.com", "DELETE")
+	assert.Equal(t, 3, requests["OPTIONS /static/app"])
+
+	// A zero max age forbids caching
+	preflight("/static/nocache", "https://a.example.com", "PUT")
+	preflight("/static/nocache", "https://a.example.com", "PUT")
+	assert.Equal(t, 2, requests["OPTIONS /static/nocache"])
+}
+
+func TestFreshness(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		expected time.Duration
+		explicit bool
+	}{
+		{name: "none", header: http.Header{}, expected: 0, explicit: false},
+		{name: "max-age", header: http.Header{"Cache-Control": {"max-age=30"}}, expected: 30 * time.Second, explicit: true},
+		{name: "s-maxage wins", header: http.Header{"Cache-Control": {"max-age=30, s-maxage=90"}}, expected: 90 * time.Second, explicit: true},
+		{name: "no-store", header: http.Header{"Cache-Control": {"no-store"}}, expected: -1, explicit: true},
+		{name: "no-cache", header: http.Header{"Cache-Control": {"max-age=30", "no-cache"}}, expected: -1, explicit: true},
+		{name: "expires", header: http.Header{"Expires": {now.Add(time.Hour).UTC().Format(http.TimeFormat)}}, expected: time.Hour, explicit: true},
+		{name: "invalid expires", header: http.Header{"Expires": {"0"}}, expected: -1, explicit: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ttl, explicit := freshness(tt.header, now)
+			assert.Equal(t, tt.explicit, explicit)
+			assert.InDelta(t, tt.expected, ttl, float64(time.Second))
+		})
+	}
+}
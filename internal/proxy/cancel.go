@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	lg "nexus/internal/logger"
+	"nexus/internal/stats"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+)
+
+// clientCanceled reports whether the client went away before the response
+// ended. The upstream request shares the request context, so it is canceled
+// along with it
+func clientCanceled(r *http.Request) bool {
+	return errors.Is(r.Context().Err(), context.Canceled)
+}
+
+// handleClientCanceled answers requests the client abandoned, they are not
+// upstream errors and bypass the error handler. The status is only seen by
+// logs and metrics
+func (p *Proxy) handleClientCanceled(w http.ResponseWriter, r *http.Request, err error) {
+	info, _ := ErrorInfoFromRequest(r)
+	requestLogger(r).With(lg.F("attempts", info.Attempts)).Info("Client canceled request: %v", err)
+	w.WriteHeader(stats.StatusClientClosedRequest)
+}
+
+// clientCancellations counts the requests abandoned by clients per route,
+// service and backend server
+type clientCancellations struct {
+	counter otelmetric.Int64Counter
+}
+
+func newClientCancellations(meter otelmetric.Meter) *clientCancellations {
+	counter, err := meter.Int64Counter(
+		"nexus.http.client_canceled",
+		otelmetric.WithDescription("Requests abandoned by the client before the response ended, not counted as upstream errors"),
+	)
+	if err != nil {
+		lg.GetInstance().Error("Failed to register client cancellation metrics: %v", err)
+		return nil
+	}
+
+	return &clientCancellations{counter: counter}
+}
+
+func (c *clientCancellations) record(ctx context.Context, route, service, server string, labels []attribute.KeyValue) {
+	c.counter.Add(ctx, 1, otelmetric.WithAttributes(append([]attribute.KeyValue{
+		attribute.String("route", route),
+		attribute.String("service", service),
+		attribute.String("backend.address", server),
+	}, labels...)...))
+}
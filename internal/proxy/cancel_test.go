@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nexus/internal/balancer"
+	"nexus/internal/config"
+	"nexus/internal/route"
+	"nexus/internal/stats"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_ClientCanceled(t *testing.T) {
+	received := make(chan struct{})
+	canceled := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		<-r.Context().Done()
+		close(canceled)
+	}))
+	defer backend.Close()
+
+	routes := []*config.RouteConfig{{Name: "api", Match: config.RouteMatch{Path: "/api"}, Service: "api-service"}}
+	services := map[string]*config.ServiceConfig{
+		"api-service": {
+			Name:         "api-service",
+			BalancerType: "least_connections",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+	}
+	router := route.NewRouter(routes, services)
+	p := NewProxy(router)
+	collector := stats.NewCollector()
+	p.SetStats(collector)
+	var handled bool
+	p.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+		handled = true
+	})
+	front := httptest.NewServer(p)
+	defer front.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, front.URL+"/api", nil)
+	require.NoError(t, err)
+	go func() {
+		<-received
+		cancel()
+	}()
+	_, err = http.DefaultClient.Do(req)
+	require.ErrorIs(t, err, context.Canceled)
+
+	// The upstream request is canceled with the client request
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("upstream request was not canceled")
+	}
+
+	// The server is released and the request counted as canceled, not as an error
+	lc := router.Services()["api-service"].Balancer().(*balancer.LeastConnectionsBalancer)
+	assert.Eventually(t, func() bool {
+		return collector.Snapshot().Frontends["http"].Current == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []balancer.LeastConnectionsServer{{Server: backend.URL}}, lc.GetServers())
+	snapshot := collector.Snapshot()
+	assert.Equal(t, int64(1), snapshot.Servers["api-service"][backend.URL].Canceled)
+	assert.Equal(t, int64(1), snapshot.Servers["api-service"][backend.URL].Responses[3])
+	assert.Equal(t, int64(0), snapshot.Backends["api-service"].Errors)
+	assert.False(t, handled)
+}
+
+func TestProxy_LeastConnectionsRelease(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	routes := []*config.RouteConfig{{Name: "api", Match: config.RouteMatch{Path: "/api"}, Service: "api-service"}}
+	services := map[string]*config.ServiceConfig{
+		"api-service": {
+			Name:         "api-service",
+			BalancerType: "least_connections",
+			Servers:      []config.ServerConfig{{Address: backend.URL}, {Address: "http://127.0.0.1:1"}},
+		},
+	}
+	router := route.NewRouter(routes, services)
+	p := NewProxy(router)
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	lc := router.Services()["api-service"].Balancer().(*balancer.LeastConnectionsBalancer)
+	for _, server := range lc.GetServers() {
+		assert.Zero(t, server.ConnCount, server.Server)
+	}
+}
@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"net/http"
+	"sort"
+
+	"nexus/internal/classify"
+	"nexus/internal/config"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// tagPrefix prefixes request tags in telemetry attribute keys
+const tagPrefix = "tag."
+
+// SetClassification sets the rules tagging requests before they are routed
+func (p *Proxy) SetClassification(rules []*config.ClassificationRule) error {
+	classifier, err := classify.New(rules)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.classifier = classifier
+	return nil
+}
+
+// classify tags r with the classification rules, so routes, rate limits,
+// logs and metrics can use the tags
+func (p *Proxy) classify(r *http.Request) (*http.Request, map[string]string) {
+	p.mu.RLock()
+	classifier := p.classifier
+	p.mu.RUnlock()
+
+	tags := classifier.Classify(r)
+	if tags == nil {
+		return r, nil
+	}
+	return r.WithContext(classify.NewContext(r.Context(), tags)), tags
+}
+
+// tagAttributes returns request tags as telemetry attributes, sorted by name
+func tagAttributes(tags map[string]string) []attribute.KeyValue {
+	if len(tags) == 0 {
+		return nil
+	}
+	attrs := make([]attribute.KeyValue, 0, len(tags))
+	for name, value := range tags {
+		attrs = append(attrs, attribute.String(tagPrefix+name, value))
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].Key < attrs[j].Key
+	})
+
+	return attrs
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_Classification(t *testing.T) {
+	backend := func(name string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	mobile, web := backend("mobile"), backend("web")
+
+	routes := []*config.RouteConfig{
+		{
+			Name:      "mobile",
+			Match:     config.RouteMatch{Path: "/app", Tags: map[string]string{"class": "mobile"}},
+			Service:   "mobile-service",
+			RateLimit: &config.RateLimitConfig{Requests: 2, Window: time.Minute, Key: "tag:bot"},
+		},
+		{Name: "web", Match: config.RouteMatch{Path: "/app"}, Service: "web-service"},
+	}
+	services := map[string]*config.ServiceConfig{
+		"mobile-service": {Name: "mobile-service", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: mobile.URL}}},
+		"web-service":    {Name: "web-service", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: web.URL}}},
+	}
+	logFile := filepath.Join(t.TempDir(), "access.log")
+	p := NewProxy(route.NewRouter(routes, services))
+	require.NoError(t, p.SetMiddleware([]*config.MiddlewareConfig{{Type: config.MiddlewareAccessLog, Format: "json", File: logFile}}))
+	require.NoError(t, p.SetClassification([]*config.ClassificationRule{
+		{Tags: map[string]string{"bot": "false"}},
+		{UserAgent: "(?i)android|iphone", Tags: map[string]string{"class": "mobile"}},
+		{UserAgent: "(?i)bot", Tags: map[string]string{"bot": "true"}},
+	}))
+
+	do := func(userAgent, remote string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/app", nil)
+		r.Header.Set("User-Agent", userAgent)
+		r.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, r)
+		return w
+	}
+
+	// Tagged requests are routed on their tags
+	w := do("Mozilla/5.0 (Linux; Android 14)", "192.0.2.1:1000")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "mobile", w.Body.String())
+	assert.Equal(t, "web", do("Mozilla/5.0 (X11; Linux x86_64)", "192.0.2.2:1000").Body.String())
+
+	// Clients with the same tag value share a rate limit
+	assert.Equal(t, http.StatusOK, do("Mozilla/5.0 (iPhone) bot", "192.0.2.3:1000").Code)
+	assert.Equal(t, http.StatusOK, do("Mozilla/5.0 (Android) bot", "192.0.2.4:1000").Code)
+	assert.Equal(t, http.StatusTooManyRequests, do("Mozilla/5.0 (iPhone) bot", "192.0.2.5:1000").Code)
+	assert.Equal(t, http.StatusOK, do("Mozilla/5.0 (iPhone)", "192.0.2.5:1000").Code)
+
+	p.CloseLogs()
+	data, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 6)
+	var mobileEntry, webEntry accessLogEntry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &mobileEntry))
+	assert.Equal(t, map[string]string{"class": "mobile", "bot": "false"}, mobileEntry.Tags)
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &webEntry))
+	assert.Equal(t, map[string]string{"bot": "false"}, webEntry.Tags)
+}
@@ -0,0 +1,43 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"slices"
+
+	"nexus/internal/config"
+)
+
+// clientCertMiddleware rejects requests to routes restricted to client
+// certificates when the connection didn't present an allowed one. Presented
+// certificates are verified by the TLS listener during the handshake, so any
+// peer certificate of the connection is trusted here
+func (p *Proxy) clientCertMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match := p.match(r)
+		if match.route == nil || match.route.ClientCert == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			http.Error(w, "Client certificate required", http.StatusForbidden)
+			return
+		}
+		if !clientCertAllowed(match.route.ClientCert, r.TLS) {
+			http.Error(w, "Client certificate not allowed", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientCertAllowed reports whether the leaf certificate of the connection
+// satisfies the route restriction
+func clientCertAllowed(cfg *config.ClientCertConfig, state *tls.ConnectionState) bool {
+	if len(cfg.CommonNames) == 0 {
+		return true
+	}
+	return slices.Contains(cfg.CommonNames, state.PeerCertificates[0].Subject.CommonName)
+}
@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxy_ClientCert(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	router := route.NewRouter([]*config.RouteConfig{
+		{
+			Name:    "public",
+			Match:   config.RouteMatch{Path: "/public/*"},
+			Service: "app-service",
+		},
+		{
+			Name:       "internal",
+			Match:      config.RouteMatch{Path: "/internal/*"},
+			Service:    "app-service",
+			ClientCert: &config.ClientCertConfig{},
+		},
+		{
+			Name:       "billing",
+			Match:      config.RouteMatch{Path: "/billing/*"},
+			Service:    "app-service",
+			ClientCert: &config.ClientCertConfig{CommonNames: []string{"billing"}},
+		},
+	}, map[string]*config.ServiceConfig{
+		"app-service": {
+			Name:         "app-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+	})
+	p := NewProxy(router)
+
+	withCert := func(commonName string) *tls.ConnectionState {
+		return &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: commonName}}}}
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		tls      *tls.ConnectionState
+		expected int
+	}{
+		{name: "public route without certificate", path: "/public/a", expected: http.StatusOK},
+		{name: "plain http", path: "/internal/a", expected: http.StatusForbidden},
+		{name: "tls without certificate", path: "/internal/a", tls: &tls.ConnectionState{}, expected: http.StatusForbidden},
+		{name: "any certificate", path: "/internal/a", tls: withCert("reports"), expected: http.StatusOK},
+		{name: "allowed common name", path: "/billing/a", tls: withCert("billing"), expected: http.StatusOK},
+		{name: "other common name", path: "/billing/a", tls: withCert("reports"), expected: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.TLS = tt.tls
+
+			w := httptest.NewRecorder()
+			p.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
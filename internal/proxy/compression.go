@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"nexus/internal/config"
+)
+
+// defaultCompressionMinSize is the smallest request body compressed when min_size is not set
+const defaultCompressionMinSize = 1024
+
+// compressionTransport gzips request bodies for the backend and decodes
+// responses whose encoding the client didn't accept. It sits above signing
+// so signatures cover the compressed body, and below response validation and
+// rewriting so they see the decoded body
+type compressionTransport struct {
+	next http.RoundTripper
+	cfg  *config.UpstreamCompressionConfig
+}
+
+func newCompressionTransport(next http.RoundTripper, cfg *config.UpstreamCompressionConfig) *compressionTransport {
+	return &compressionTransport{
+		next: next,
+		cfg:  cfg,
+	}
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *compressionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.cfg.Request && t.compresses(req) {
+		compressed, err := compressBody(req)
+		if err != nil {
+			return nil, err
+		}
+		req = compressed
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || !t.cfg.DecodeResponses {
+		return resp, err
+	}
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if (encoding != "gzip" && encoding != "deflate") || acceptsEncoding(req.Header.Values("Accept-Encoding"), encoding) {
+		return resp, nil
+	}
+	if err := decodeBody(resp, encoding); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// compresses reports whether the request body is compressed
+func (t *compressionTransport) compresses(req *http.Request) bool {
+	if req.Body == nil || req.Body == http.NoBody || req.Header.Get("Content-Encoding") != "" {
+		return false
+	}
+	minSize := t.cfg.MinSize
+	if minSize == 0 {
+		minSize = defaultCompressionMinSize
+	}
+	// Bodies of unknown length are compressed
+	if req.ContentLength >= 0 && req.ContentLength < minSize {
+		return false
+	}
+	if len(t.cfg.ContentTypes) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return err == nil && matchContentType(mediaType, t.cfg.ContentTypes)
+}
+
+// compressBody returns a copy of the request with a gzipped body, compressed
+// in memory so retried attempts can send it again
+func compressBody(req *http.Request) (*http.Request, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := io.Copy(zw, req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	body := buf.Bytes()
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Del("Content-Length")
+
+	return req, nil
+}
+
+// decodeBody replaces an encoded response body with its decoded stream
+func decodeBody(resp *http.Response, encoding string) error {
+	var decoded io.ReadCloser
+	if encoding == "gzip" {
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return err
+		}
+		decoded = zr
+	} else {
+		decoded = flate.NewReader(resp.Body)
+	}
+
+	resp.Body = &decodedBody{ReadCloser: decoded, raw: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+
+	return nil
+}
+
+// decodedBody closes the decoder and the encoded body
+type decodedBody struct {
+	io.ReadCloser
+	raw io.ReadCloser
+}
+
+func (b *decodedBody) Close() error {
+	b.ReadCloser.Close()
+	return b.raw.Close()
+}
+
+// acceptsEncoding reports whether Accept-Encoding values allow an encoding,
+// either by name or through * with a non-zero quality
+func acceptsEncoding(values []string, encoding string) bool {
+	wildcard := false
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			name, params, _ := strings.Cut(part, ";")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != encoding && name != "*" {
+				continue
+			}
+			accepted := true
+			if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				if quality, err := strconv.ParseFloat(q, 64); err == nil && quality == 0 {
+					accepted = false
+				}
+			}
+			if name == encoding {
+				return accepted
+			}
+			wildcard = accepted
+		}
+	}
+
+	return wildcard
+}
@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipped(t *testing.T, data string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestProxy_UpstreamCompression(t *testing.T) {
+	var encoding, body string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		reader := io.Reader(r.Body)
+		if encoding == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			require.NoError(t, err)
+			reader = zr
+		}
+		b, _ := io.ReadAll(reader)
+		body = string(b)
+
+		// The backend ignores the encodings accepted by the client
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(gzipped(t, "response"))
+	}))
+	defer backend.Close()
+
+	routes := []*config.RouteConfig{{
+		Name:    "api",
+		Match:   config.RouteMatch{Path: "/api"},
+		Service: "api-service",
+		UpstreamCompression: &config.UpstreamCompressionConfig{
+			Request:         true,
+			MinSize:         8,
+			ContentTypes:    []string{"application/json"},
+			DecodeResponses: true,
+		},
+	}}
+	services := map[string]*config.ServiceConfig{
+		"api-service": {
+			Name:         "api-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+	}
+	p := NewProxy(route.NewRouter(routes, services))
+	serve := func(contentType, requestBody, acceptEncoding string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api", strings.NewReader(requestBody))
+		r.Header.Set("Content-Type", contentType)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w
+	}
+
+	// Large enough JSON bodies are compressed, the response is decoded for a
+	// client that only accepts brotli
+	w := serve("application/json", `{"name":"nexus"}`, "br")
+	assert.Equal(t, "gzip", encoding)
+	assert.Equal(t, `{"name":"nexus"}`, body)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "response", w.Body.String())
+
+	// Small bodies and other types are sent as is, accepted encodings too
+	w = serve("application/json", `{}`, "gzip, br")
+	assert.Empty(t, encoding)
+	assert.Equal(t, `{}`, body)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, gzipped(t, "response"), w.Body.Bytes())
+
+	serve("text/plain", "plain text body", "gzip;q=0")
+	assert.Empty(t, encoding)
+	assert.Equal(t, "plain text body", body)
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		values   []string
+		encoding string
+		want     bool
+	}{
+		{nil, "gzip", false},
+		{[]string{"gzip"}, "gzip", true},
+		{[]string{"br", "GZIP;q=0.5"}, "gzip", true},
+		{[]string{"gzip;q=0"}, "gzip", false},
+		{[]string{"*"}, "deflate", true},
+		{[]string{"*;q=0"}, "deflate", false},
+		{[]string{"*, gzip;q=0"}, "gzip", false},
+		{[]string{"identity"}, "gzip", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, acceptsEncoding(tt.values, tt.encoding), "%v %s", tt.values, tt.encoding)
+	}
+}
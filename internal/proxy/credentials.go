@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"nexus/internal/config"
+	"nexus/internal/service"
+)
+
+// upstreamCredentials is the header resolved from an upstream auth config
+type upstreamCredentials struct {
+	cfg        *config.UpstreamAuthConfig
+	generation uint64
+	name       string
+	value      string
+}
+
+// credentialsTransport injects the service's upstream credentials, replacing
+// whatever the client sent in the same header. The service config is read per
+// request since it is updated in place on reload
+type credentialsTransport struct {
+	next     http.RoundTripper
+	service  service.Service
+	resolved atomic.Pointer[upstreamCredentials]
+}
+
+func newCredentialsTransport(next http.RoundTripper, svc service.Service) *credentialsTransport {
+	return &credentialsTransport{
+		next:    next,
+		service: svc,
+	}
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *credentialsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	svcConfig := t.service.Config()
+	if svcConfig == nil || svcConfig.UpstreamAuth == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	creds, err := t.credentials(svcConfig.UpstreamAuth)
+	if err != nil {
+		return nil, fmt.Errorf("upstream credentials of service %s: %w", svcConfig.Name, err)
+	}
+
+	// A RoundTripper must not modify the request it was given
+	req = req.Clone(req.Context())
+	req.Header.Set(creds.name, creds.value)
+
+	return t.next.RoundTrip(req)
+}
+
+// credentials returns the resolved header, references are resolved once per
+// config instance and again after refreshed secrets changed
+func (t *credentialsTransport) credentials(cfg *config.UpstreamAuthConfig) (*upstreamCredentials, error) {
+	generation := config.CredentialGeneration()
+	if creds := t.resolved.Load(); creds != nil && creds.cfg == cfg && creds.generation == generation {
+		return creds, nil
+	}
+
+	creds := &upstreamCredentials{cfg: cfg, generation: generation}
+	switch cfg.Type {
+	case "basic":
+		username, err := config.ResolveCredential(cfg.Username)
+		if err != nil {
+			return nil, err
+		}
+		password, err := config.ResolveCredential(cfg.Password)
+		if err != nil {
+			return nil, err
+		}
+		creds.name = "Authorization"
+		creds.value = "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+	case "bearer":
+		token, err := config.ResolveCredential(cfg.Token)
+		if err != nil {
+			return nil, err
+		}
+		creds.name = "Authorization"
+		creds.value = "Bearer " + token
+	case "header":
+		value, err := config.ResolveCredential(cfg.Value)
+		if err != nil {
+			return nil, err
+		}
+		creds.name = cfg.Header
+		creds.value = value
+	default:
+		return nil, fmt.Errorf("unsupported upstream auth type: %s", cfg.Type)
+	}
+	t.resolved.Store(creds)
+
+	return creds, nil
+}
@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_UpstreamCredentials(t *testing.T) {
+	var authorization, apiKey string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		apiKey = r.Header.Get("X-Api-Key")
+	}))
+	defer backend.Close()
+
+	t.Setenv("NEXUS_TEST_UPSTREAM_PASSWORD", "s3cret")
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("token-1\n"), 0o600))
+
+	routes := []*config.RouteConfig{{Name: "api", Match: config.RouteMatch{Path: "/api"}, Service: "api-service"}}
+	services := func(auth *config.UpstreamAuthConfig) map[string]*config.ServiceConfig {
+		return map[string]*config.ServiceConfig{
+			"api-service": {
+				Name:         "api-service",
+				BalancerType: "round_robin",
+				Servers:      []config.ServerConfig{{Address: backend.URL}},
+				UpstreamAuth: auth,
+			},
+		}
+	}
+	router := route.NewRouter(routes, services(&config.UpstreamAuthConfig{
+		Type:     "basic",
+		Username: "nexus",
+		Password: "env://NEXUS_TEST_UPSTREAM_PASSWORD",
+	}))
+	p := NewProxy(router)
+	serve := func() {
+		r := httptest.NewRequest(http.MethodGet, "/api", nil)
+		r.Header.Set("Authorization", "Bearer client-token")
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	// The client's Authorization header is replaced
+	serve()
+	assert.Equal(t, "Basic bmV4dXM6czNjcmV0", authorization)
+
+	// Reloads re-read referenced files
+	require.NoError(t, router.Update(routes, services(&config.UpstreamAuthConfig{Type: "bearer", Token: "file://" + tokenFile})))
+	serve()
+	assert.Equal(t, "Bearer token-1", authorization)
+
+	require.NoError(t, os.WriteFile(tokenFile, []byte("token-2\n"), 0o600))
+	require.NoError(t, router.Update(routes, services(&config.UpstreamAuthConfig{Type: "bearer", Token: "file://" + tokenFile})))
+	serve()
+	assert.Equal(t, "Bearer token-2", authorization)
+
+	require.NoError(t, router.Update(routes, services(&config.UpstreamAuthConfig{Type: "header", Header: "X-Api-Key", Value: "key-1"})))
+	serve()
+	assert.Equal(t, "key-1", apiKey)
+	assert.Equal(t, "Bearer client-token", authorization)
+}
+
+func TestProxy_UpstreamCredentialsUnresolved(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request should not reach the backend")
+	}))
+	defer backend.Close()
+
+	router := route.NewRouter([]*config.RouteConfig{
+		{Name: "api", Match: config.RouteMatch{Path: "/api"}, Service: "api-service"},
+	}, map[string]*config.ServiceConfig{
+		"api-service": {
+			Name:         "api-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+			UpstreamAuth: &config.UpstreamAuthConfig{Type: "bearer", Token: "env://NEXUS_TEST_UPSTREAM_UNSET"},
+		},
+	})
+	p := NewProxy(router)
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+}
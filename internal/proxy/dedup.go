@@ -0,0 +1,209 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"nexus/internal/config"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultDedupMaxBodySize is the largest response shared when max_body_size is not set
+const defaultDedupMaxBodySize = 1 << 20
+
+// dedupKeyHeaders are the request headers requests sharing a response agree on
+var dedupKeyHeaders = []string{"Authorization", "Cookie", "Accept", "Accept-Encoding"}
+
+// dedupGroup collapses concurrent identical requests into one upstream call
+type dedupGroup struct {
+	mu    sync.Mutex
+	calls map[string]*dedupCall
+}
+
+// dedupCall is the in-flight upstream call that waiting requests share
+type dedupCall struct {
+	done   chan struct{}
+	status int
+	header http.Header
+	body   bytes.Buffer
+	shared bool // false when the response can't or mustn't be replayed
+}
+
+func newDedupGroup() *dedupGroup {
+	return &dedupGroup{
+		calls: make(map[string]*dedupCall),
+	}
+}
+
+// SetDedupConfig sets the global request deduplication config
+func (p *Proxy) SetDedupConfig(cfg config.DedupConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.dedup = cfg
+}
+
+// dedupConfig returns the effective dedup config, the service level overrides the global one
+func (p *Proxy) dedupConfig(match *matchResult) config.DedupConfig {
+	if match.service != nil {
+		if svcCfg := match.service.Config(); svcCfg != nil && svcCfg.Dedup != nil {
+			return *svcCfg.Dedup
+		}
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.dedup
+}
+
+// dedupMiddleware coalesces identical concurrent GET requests
+func (p *Proxy) dedupMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || (r.Body != nil && r.Body != http.NoBody) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		match := p.match(r)
+		cfg := p.dedupConfig(match)
+		if !cfg.Enabled || match.service == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := dedupKey(match, r)
+		p.dedupCalls.mu.Lock()
+		if call, ok := p.dedupCalls.calls[key]; ok {
+			p.dedupCalls.mu.Unlock()
+			p.waitDedup(w, r, next, call)
+			return
+		}
+		call := &dedupCall{done: make(chan struct{})}
+		p.dedupCalls.calls[key] = call
+		p.dedupCalls.mu.Unlock()
+
+		limit := cfg.MaxBodySize
+		if limit == 0 {
+			limit = defaultDedupMaxBodySize
+		}
+		rec := &dedupRecorder{ResponseWriter: w, call: call, limit: limit}
+
+		defer func() {
+			p.dedupCalls.mu.Lock()
+			delete(p.dedupCalls.calls, key)
+			p.dedupCalls.mu.Unlock()
+			close(call.done)
+		}()
+
+		next.ServeHTTP(rec, r)
+		if !rec.overflow {
+			if call.status == 0 {
+				call.status = http.StatusOK
+				call.header = w.Header().Clone()
+			}
+			call.shared = shareable(call.header)
+		}
+	})
+}
+
+// waitDedup waits for the leader request and replays its response
+func (p *Proxy) waitDedup(w http.ResponseWriter, r *http.Request, next http.Handler, call *dedupCall) {
+	select {
+	case <-call.done:
+	case <-r.Context().Done():
+		return
+	}
+
+	if !call.shared {
+		// The leader response was too large, aborted or personal, forward on our own
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	trace.SpanFromContext(r.Context()).AddEvent("Deduplicated request",
+		trace.WithAttributes(attribute.Int("http.status_code", call.status)))
+
+	for k, v := range call.header {
+		w.Header()[k] = append([]string(nil), v...)
+	}
+	w.WriteHeader(call.status)
+	w.Write(call.body.Bytes())
+}
+
+// dedupKey identifies requests that can share one upstream response
+func dedupKey(match *matchResult, r *http.Request) string {
+	routeName := ""
+	if match.route != nil {
+		routeName = match.route.Name
+	}
+
+	parts := []string{routeName, match.service.Name(), r.Host, r.URL.RequestURI()}
+	for _, name := range dedupKeyHeaders {
+		parts = append(parts, r.Header.Get(name))
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// shareable reports whether a response may be replayed to other requests:
+// it sets no cookie, isn't private or no-store, and only varies on headers
+// of the dedup key
+func shareable(header http.Header) bool {
+	if len(header.Values("Set-Cookie")) > 0 {
+		return false
+	}
+	for _, directive := range strings.Split(strings.Join(header.Values("Cache-Control"), ","), ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if strings.EqualFold(name, "private") || strings.EqualFold(name, "no-store") {
+			return false
+		}
+	}
+	for _, name := range strings.Split(strings.Join(header.Values("Vary"), ","), ",") {
+		name = strings.TrimSpace(name)
+		if name != "" && !slices.ContainsFunc(dedupKeyHeaders, func(key string) bool { return strings.EqualFold(key, name) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// dedupRecorder forwards the leader response and keeps a copy for waiting requests
+type dedupRecorder struct {
+	http.ResponseWriter
+	call     *dedupCall
+	limit    int64
+	overflow bool
+}
+
+func (d *dedupRecorder) WriteHeader(status int) {
+	if d.call.status == 0 {
+		d.call.status = status
+		d.call.header = d.Header().Clone()
+	}
+	d.ResponseWriter.WriteHeader(status)
+}
+
+func (d *dedupRecorder) Write(b []byte) (int, error) {
+	if d.call.status == 0 {
+		d.WriteHeader(http.StatusOK)
+	}
+	if !d.overflow {
+		if int64(d.call.body.Len()+len(b)) > d.limit {
+			d.overflow = true
+			d.call.body.Reset()
+		} else {
+			d.call.body.Write(b)
+		}
+	}
+
+	return d.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (d *dedupRecorder) Unwrap() http.ResponseWriter {
+	return d.ResponseWriter
+}
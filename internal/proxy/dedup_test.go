@@ -0,0 +1,180 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+)
+
+func newDedupTestProxy(backendURL string, svcDedup *config.DedupConfig) *Proxy {
+	router := route.NewRouter([]*config.RouteConfig{
+		{
+			Name:    "dedup-route",
+			Match:   config.RouteMatch{Path: "/items"},
+			Service: "dedup-service",
+		},
+	}, map[string]*config.ServiceConfig{
+		"dedup-service": {
+			Name:         "dedup-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backendURL}},
+			Dedup:        svcDedup,
+		},
+	})
+
+	return NewProxy(router)
+}
+
+func runConcurrentGets(p *Proxy, n int) []*httptest.ResponseRecorder {
+	var wg sync.WaitGroup
+	recorders := make([]*httptest.ResponseRecorder, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			recorders[i] = httptest.NewRecorder()
+			p.ServeHTTP(recorders[i], httptest.NewRequest(http.MethodGet, "/items?page=1", nil))
+		}(i)
+	}
+	wg.Wait()
+
+	return recorders
+}
+
+func TestDedup(t *testing.T) {
+	var calls int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(100 * time.Millisecond)
+		w.Header().Set("X-Backend", "dedup")
+		w.Write([]byte(testResponseBody))
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name        string
+		global      config.DedupConfig
+		svcDedup    *config.DedupConfig
+		expectCalls int32
+	}{
+		{
+			name:        "GlobalEnabled",
+			global:      config.DedupConfig{Enabled: true},
+			expectCalls: 1,
+		},
+		{
+			name:        "Disabled",
+			global:      config.DedupConfig{},
+			expectCalls: 5,
+		},
+		{
+			name:        "ServiceOverridesGlobal",
+			global:      config.DedupConfig{Enabled: true},
+			svcDedup:    &config.DedupConfig{Enabled: false},
+			expectCalls: 5,
+		},
+		{
+			name:        "ResponseTooLargeToShare",
+			global:      config.DedupConfig{Enabled: true, MaxBodySize: 4},
+			expectCalls: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+
+			p := newDedupTestProxy(backend.URL, tt.svcDedup)
+			p.SetDedupConfig(tt.global)
+
+			for _, rec := range runConcurrentGets(p, 5) {
+				if rec.Code != http.StatusOK {
+					t.Errorf("Expected status 200, got %d", rec.Code)
+				}
+				if rec.Body.String() != testResponseBody {
+					t.Errorf("Expected body %q, got %q", testResponseBody, rec.Body.String())
+				}
+				if rec.Header().Get("X-Backend") != "dedup" {
+					t.Errorf("Expected upstream header to be replayed")
+				}
+			}
+
+			// Waiters on an oversized leader response forward after it completes
+			if got := atomic.LoadInt32(&calls); got != tt.expectCalls {
+				t.Errorf("Expected %d upstream calls, got %d", tt.expectCalls, got)
+			}
+		})
+	}
+}
+
+func TestDedup_PersonalResponses(t *testing.T) {
+	tests := []struct {
+		name   string
+		header map[string]string
+	}{
+		{name: "SetCookie", header: map[string]string{"Set-Cookie": "session=abc"}},
+		{name: "Private", header: map[string]string{"Cache-Control": "max-age=60, private"}},
+		{name: "NoStore", header: map[string]string{"Cache-Control": "no-store"}},
+		{name: "VaryOutsideKey", header: map[string]string{"Vary": "Accept-Encoding, X-Tenant"}},
+		{name: "VaryAll", header: map[string]string{"Vary": "*"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(100 * time.Millisecond)
+				for k, v := range tt.header {
+					w.Header().Set(k, v)
+				}
+				w.Write([]byte(testResponseBody))
+			}))
+			defer backend.Close()
+
+			p := newDedupTestProxy(backend.URL, nil)
+			p.SetDedupConfig(config.DedupConfig{Enabled: true})
+			for _, rec := range runConcurrentGets(p, 5) {
+				if rec.Code != http.StatusOK {
+					t.Errorf("Expected status 200, got %d", rec.Code)
+				}
+			}
+
+			// Every waiter forwards its own request instead of sharing the leader's
+			if got := atomic.LoadInt32(&calls); got != 5 {
+				t.Errorf("Expected 5 upstream calls, got %d", got)
+			}
+		})
+	}
+}
+
+func TestShareable(t *testing.T) {
+	header := http.Header{}
+	header.Set("Cache-Control", "public, max-age=60")
+	header.Set("Vary", "accept-encoding, Accept")
+	if !shareable(header) {
+		t.Error("Expected a public response varying on key headers to be shared")
+	}
+}
+
+func TestDedupKey_SeparatesCredentials(t *testing.T) {
+	match := &matchResult{
+		route:   &config.RouteConfig{Name: "r"},
+		service: &MockService{},
+	}
+
+	r1 := httptest.NewRequest(http.MethodGet, "/items", nil)
+	r1.Header.Set("Authorization", "Bearer a")
+	r2 := httptest.NewRequest(http.MethodGet, "/items", nil)
+	r2.Header.Set("Authorization", "Bearer b")
+
+	if dedupKey(match, r1) == dedupKey(match, r2) {
+		t.Error("Requests with different credentials must not share a dedup key")
+	}
+}
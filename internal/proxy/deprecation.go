@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+)
+
+const (
+	// defaultDeprecationLogInterval is the time between two logs about the
+	// same caller of a deprecated route when log_interval is not set
+	defaultDeprecationLogInterval = time.Hour
+	// maxDeprecationCallers bounds the callers remembered per route
+	maxDeprecationCallers = 10000
+)
+
+// deprecationMiddleware announces the retirement of deprecated routes with
+// Deprecation, Sunset and Link headers and logs their callers. Routes gone
+// past their sunset are answered with 410 instead of being forwarded
+func (p *Proxy) deprecationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match := p.match(r)
+		if match.route == nil || match.route.Deprecation == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cfg := match.route.Deprecation
+		now := time.Now()
+		setDeprecationHeaders(w.Header(), cfg)
+		gone := cfg.Gone && !now.Before(cfg.Sunset)
+		if caller := rateLimitKey(cfg.LogKey, r); p.callerLog(match.route.Name).seen(caller, now, cfg.LogInterval) {
+			logger := requestLogger(r).With(lg.F("caller", caller))
+			if gone {
+				logger.Warn("Gone route called")
+			} else {
+				logger.Info("Deprecated route called")
+			}
+		}
+
+		if gone {
+			http.Error(w, "Gone", http.StatusGone)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setDeprecationHeaders sets the response headers announcing a deprecation
+func setDeprecationHeaders(header http.Header, cfg *config.DeprecationConfig) {
+	if cfg.Since.IsZero() {
+		header.Set("Deprecation", "true")
+	} else {
+		header.Set("Deprecation", "@"+strconv.FormatInt(cfg.Since.Unix(), 10))
+	}
+	if !cfg.Sunset.IsZero() {
+		header.Set("Sunset", cfg.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if cfg.Link != "" {
+		header.Add("Link", "<"+cfg.Link+`>; rel="deprecation"`)
+	}
+}
+
+// callerLog remembers when the callers of a deprecated route were last logged
+type callerLog struct {
+	mu      sync.Mutex
+	callers map[string]time.Time
+}
+
+// callerLog returns the caller log of a route
+func (p *Proxy) callerLog(routeName string) *callerLog {
+	if value, ok := p.deprecationCallers.Load(routeName); ok {
+		return value.(*callerLog)
+	}
+	value, _ := p.deprecationCallers.LoadOrStore(routeName, &callerLog{callers: make(map[string]time.Time)})
+	return value.(*callerLog)
+}
+
+// seen records a call and reports whether the caller is due to be logged.
+// Once the log is full, callers not logged within the interval are forgotten
+func (l *callerLog) seen(caller string, now time.Time, interval time.Duration) bool {
+	if interval <= 0 {
+		interval = defaultDeprecationLogInterval
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if last, ok := l.callers[caller]; ok && now.Sub(last) < interval {
+		return false
+	}
+	if len(l.callers) >= maxDeprecationCallers {
+		for key, last := range l.callers {
+			if now.Sub(last) >= interval {
+				delete(l.callers, key)
+			}
+		}
+		if len(l.callers) >= maxDeprecationCallers {
+			clear(l.callers)
+		}
+	}
+	l.callers[caller] = now
+	return true
+}
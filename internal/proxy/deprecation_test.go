@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeprecation(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testResponseBody))
+	}))
+	defer backend.Close()
+
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Now().Add(24 * time.Hour)
+	router := route.NewRouter([]*config.RouteConfig{
+		{
+			Name:    "v1",
+			Match:   config.RouteMatch{Path: "/v1/*"},
+			Service: "api",
+			Deprecation: &config.DeprecationConfig{
+				Since:  since,
+				Sunset: sunset,
+				Link:   "https://docs.example.com/migrate-v2",
+				Gone:   true,
+			},
+		},
+		{
+			Name:    "v0",
+			Match:   config.RouteMatch{Path: "/v0/*"},
+			Service: "api",
+			Deprecation: &config.DeprecationConfig{
+				Sunset: time.Now().Add(-time.Hour),
+				Gone:   true,
+			},
+		},
+		{Name: "v2", Match: config.RouteMatch{Path: "/v2/*"}, Service: "api"},
+	}, map[string]*config.ServiceConfig{
+		"api": {Name: "api", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: backend.URL}}},
+	})
+	p := NewProxy(router)
+
+	do := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	// Forwarded until the sunset, with the announcement
+	rec := do("/v1/users")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, testResponseBody, rec.Body.String())
+	assert.Equal(t, "@"+strconv.FormatInt(since.Unix(), 10), rec.Header().Get("Deprecation"))
+	assert.Equal(t, sunset.UTC().Format(http.TimeFormat), rec.Header().Get("Sunset"))
+	assert.Equal(t, `<https://docs.example.com/migrate-v2>; rel="deprecation"`, rec.Header().Get("Link"))
+
+	// Answered with 410 past the sunset
+	rec = do("/v0/users")
+	assert.Equal(t, http.StatusGone, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get("Deprecation"))
+	assert.NotEmpty(t, rec.Header().Get("Sunset"))
+
+	rec = do("/v2/users")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Deprecation"))
+	assert.Empty(t, rec.Header().Get("Sunset"))
+}
+
+func TestCallerLog(t *testing.T) {
+	log := &callerLog{callers: make(map[string]time.Time)}
+	now := time.Now()
+
+	assert.True(t, log.seen("ip:192.0.2.1", now, time.Minute))
+	assert.False(t, log.seen("ip:192.0.2.1", now.Add(30*time.Second), time.Minute))
+	assert.True(t, log.seen("ip:192.0.2.2", now.Add(30*time.Second), time.Minute))
+	assert.True(t, log.seen("ip:192.0.2.1", now.Add(time.Minute), time.Minute))
+
+	// A full log forgets the callers not logged within the interval
+	log = &callerLog{callers: make(map[string]time.Time)}
+	log.seen("ip:192.0.2.1", now.Add(90*time.Second), time.Minute)
+	for i := range maxDeprecationCallers - 1 {
+		log.seen("header:"+strconv.Itoa(i), now, time.Minute)
+	}
+	require.Len(t, log.callers, maxDeprecationCallers)
+	assert.True(t, log.seen("header:new", now.Add(2*time.Minute), time.Minute))
+	assert.Len(t, log.callers, 2)
+}
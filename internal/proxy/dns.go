@@ -0,0 +1,295 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptrace"
+	"reflect"
+	"sync"
+	"time"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Defaults of the upstream DNS cache
+const (
+	defaultDNSMinTTL      = time.Second
+	defaultDNSMaxTTL      = 5 * time.Minute
+	defaultDNSNegativeTTL = 5 * time.Second
+	defaultDNSStaleTTL    = 5 * time.Minute
+)
+
+// dnsTTLKey is the context key of the TTL collected for a lookup
+type dnsTTLKey struct{}
+
+// dnsCache resolves backend hosts for the upstream transports, keeping their
+// addresses for the TTL of the records. Concurrent connections to a host share
+// one lookup. TTLs are read from the responses of the Go resolver, hosts file
+// entries are kept for max_ttl
+type dnsCache struct {
+	resolver *net.Resolver
+	dial     func(ctx context.Context, network, address string) (net.Conn, error) // Reaches the name servers
+	now      func() time.Time
+
+	mu      sync.Mutex
+	cfg     *config.UpstreamDNSConfig // Disabled when nil
+	entries map[string]*dnsEntry
+	lookups map[string]*dnsLookup
+}
+
+// dnsEntry is the outcome of the last lookup of a host
+type dnsEntry struct {
+	addrs   []net.IPAddr
+	err     error     // Failed lookup, cached for negative_ttl
+	expires time.Time // When the host is looked up again
+	stale   time.Time // Until when addrs are used while lookups fail
+}
+
+// dnsLookup is a lookup in progress
+type dnsLookup struct {
+	done  chan struct{}
+	addrs []net.IPAddr
+	err   error
+}
+
+func newDNSCache() *dnsCache {
+	c := &dnsCache{
+		dial:    (&net.Dialer{}).DialContext,
+		now:     time.Now,
+		entries: make(map[string]*dnsEntry),
+		lookups: make(map[string]*dnsLookup),
+	}
+	c.resolver = &net.Resolver{PreferGo: true, Dial: c.dialNameServer}
+	return c
+}
+
+// setConfig enables the cache, or disables it with nil. The cached addresses
+// are dropped when the settings change
+func (c *dnsCache) setConfig(cfg *config.UpstreamDNSConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if reflect.DeepEqual(c.cfg, cfg) {
+		return
+	}
+	c.cfg = cfg
+	c.entries = make(map[string]*dnsEntry)
+	c.lookups = make(map[string]*dnsLookup)
+}
+
+// settings returns the cache config with its defaults, nil when disabled.
+// The caller must hold c.mu
+func (c *dnsCache) settings() *config.UpstreamDNSConfig {
+	if c.cfg == nil {
+		return nil
+	}
+	cfg := *c.cfg
+	if cfg.MaxTTL == 0 {
+		cfg.MaxTTL = max(defaultDNSMaxTTL, cfg.MinTTL)
+	}
+	if cfg.MinTTL == 0 {
+		cfg.MinTTL = min(defaultDNSMinTTL, cfg.MaxTTL)
+	}
+	if cfg.NegativeTTL == 0 {
+		cfg.NegativeTTL = defaultDNSNegativeTTL
+	}
+	if cfg.StaleTTL == 0 {
+		cfg.StaleTTL = defaultDNSStaleTTL
+	}
+	return &cfg
+}
+
+// dialer wraps dial so that host names are resolved through the cache while
+// it is enabled. The addresses of a host are tried in order
+func (c *dnsCache) dialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil || !c.enabled() {
+			return dial(ctx, network, addr)
+		}
+
+		addrs, err := c.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range addrs {
+			var conn net.Conn
+			conn, err = dial(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil || ctx.Err() != nil {
+				return conn, err
+			}
+		}
+		return nil, err
+	}
+}
+
+func (c *dnsCache) enabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.cfg != nil
+}
+
+// lookup returns the cached addresses of host, looking it up once they
+// expired. The lookup keeps going when ctx is canceled, for the other
+// connections waiting for it and the cache. Only waiting for a lookup is
+// reported to the client trace of ctx
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]net.IPAddr, error) {
+	c.mu.Lock()
+	if entry := c.entries[host]; entry != nil && c.now().Before(entry.expires) {
+		c.mu.Unlock()
+		return entry.addrs, entry.err
+	}
+	lookup := c.lookups[host]
+	if lookup == nil {
+		lookup = &dnsLookup{done: make(chan struct{})}
+		c.lookups[host] = lookup
+		go c.resolve(host, lookup)
+	}
+	c.mu.Unlock()
+
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+	}
+	var addrs []net.IPAddr
+	var err error
+	select {
+	case <-lookup.done:
+		addrs, err = lookup.addrs, lookup.err
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if trace != nil && trace.DNSDone != nil {
+		trace.DNSDone(httptrace.DNSDoneInfo{Addrs: addrs, Err: err})
+	}
+	return addrs, err
+}
+
+// resolve looks host up and caches the outcome. Addresses that expired are
+// still used while the resolver fails, until their stale_ttl, but not once
+// the host no longer exists
+func (c *dnsCache) resolve(host string, lookup *dnsLookup) {
+	ttl := &dnsTTL{}
+	addrs, err := c.resolver.LookupIPAddr(context.WithValue(context.Background(), dnsTTLKey{}, ttl), host)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer close(lookup.done)
+
+	cfg := c.settings()
+	if cfg == nil || c.lookups[host] != lookup {
+		// Disabled or reconfigured meanwhile
+		lookup.addrs, lookup.err = addrs, err
+		return
+	}
+	delete(c.lookups, host)
+
+	now := c.now()
+	previous := c.entries[host]
+	var notFound *net.DNSError
+	switch {
+	case err == nil:
+		expires := now.Add(min(max(ttl.get(cfg.MaxTTL), cfg.MinTTL), cfg.MaxTTL))
+		c.entries[host] = &dnsEntry{addrs: addrs, expires: expires, stale: expires.Add(cfg.StaleTTL)}
+	case previous != nil && previous.addrs != nil && now.Before(previous.stale) && !(errors.As(err, &notFound) && notFound.IsNotFound):
+		lg.GetInstance().Warn("DNS lookup of %s failed, using its previous addresses until %s: %v", host, previous.stale.Format(time.RFC3339), err)
+		c.entries[host] = &dnsEntry{addrs: previous.addrs, expires: now.Add(cfg.NegativeTTL), stale: previous.stale}
+		addrs, err = previous.addrs, nil
+	default:
+		c.entries[host] = &dnsEntry{err: err, expires: now.Add(cfg.NegativeTTL)}
+	}
+	lookup.addrs, lookup.err = addrs, err
+}
+
+// dialNameServer connects to a name server, reading the TTLs of the answers
+// for lookups of the cache
+func (c *dnsCache) dialNameServer(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := c.dial(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	ttl, ok := ctx.Value(dnsTTLKey{}).(*dnsTTL)
+	if !ok {
+		return conn, nil
+	}
+	// The resolver reads one message per packet from packet connections
+	if udp, ok := conn.(*net.UDPConn); ok {
+		return &dnsPacketConn{UDPConn: udp, ttl: ttl}, nil
+	}
+	return &dnsStreamConn{Conn: conn, ttl: ttl}, nil
+}
+
+// dnsTTL is the shortest TTL of the answers received for a lookup
+type dnsTTL struct {
+	mu  sync.Mutex
+	ttl time.Duration
+	ok  bool
+}
+
+// observe records the TTLs of a DNS response
+func (t *dnsTTL) observe(msg []byte) {
+	var m dnsmessage.Message
+	if err := m.Unpack(msg); err != nil || !m.Response {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, answer := range m.Answers {
+		ttl := time.Duration(answer.Header.TTL) * time.Second
+		if !t.ok || ttl < t.ttl {
+			t.ttl, t.ok = ttl, true
+		}
+	}
+}
+
+// get returns the TTL, or fallback when no answer was received
+func (t *dnsTTL) get(fallback time.Duration) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.ok {
+		return fallback
+	}
+	return t.ttl
+}
+
+// dnsPacketConn reads DNS responses from UDP packets
+type dnsPacketConn struct {
+	*net.UDPConn
+	ttl *dnsTTL
+}
+
+func (c *dnsPacketConn) Read(b []byte) (int, error) {
+	n, err := c.UDPConn.Read(b)
+	if n > 0 {
+		c.ttl.observe(b[:n])
+	}
+	return n, err
+}
+
+// dnsStreamConn reads length prefixed DNS responses from TCP connections
+type dnsStreamConn struct {
+	net.Conn
+	ttl *dnsTTL
+	buf []byte
+}
+
+func (c *dnsStreamConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.buf = append(c.buf, b[:n]...)
+	for len(c.buf) >= 2 {
+		length := int(c.buf[0])<<8 | int(c.buf[1])
+		if len(c.buf) < 2+length {
+			break
+		}
+		c.ttl.observe(c.buf[2 : 2+length])
+		c.buf = c.buf[2+length:]
+	}
+	return n, err
+}
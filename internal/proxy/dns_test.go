@@ -0,0 +1,254 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// nameServer answers A queries of hosts with 127.0.0.1, and NXDOMAIN for the
+// hosts starting with missing
+type nameServer struct {
+	conn *net.UDPConn
+	ttl  uint32
+
+	mu      sync.Mutex
+	failing bool
+	queries map[string]int // A queries per host
+}
+
+func newNameServer(t *testing.T, ttl uint32) *nameServer {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	s := &nameServer{conn: conn, ttl: ttl, queries: make(map[string]int)}
+	go s.serve()
+	return s
+}
+
+func (s *nameServer) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		var query dnsmessage.Message
+		if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) != 1 {
+			continue
+		}
+		question := query.Questions[0]
+		name := strings.TrimSuffix(question.Name.String(), ".")
+
+		s.mu.Lock()
+		if question.Type == dnsmessage.TypeA {
+			s.queries[name]++
+		}
+		failing := s.failing
+		s.mu.Unlock()
+
+		resp := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: query.ID, Response: true, RecursionAvailable: true},
+			Questions: query.Questions,
+		}
+		switch {
+		case failing:
+			resp.RCode = dnsmessage.RCodeServerFailure
+		case strings.HasPrefix(name, "missing"):
+			resp.RCode = dnsmessage.RCodeNameError
+		case question.Type == dnsmessage.TypeA:
+			resp.Answers = []dnsmessage.Resource{{
+				Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: s.ttl},
+				Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+			}}
+		}
+		packed, err := resp.Pack()
+		if err == nil {
+			s.conn.WriteToUDP(packed, addr)
+		}
+	}
+}
+
+func (s *nameServer) setFailing(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = failing
+}
+
+func (s *nameServer) count(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queries[name]
+}
+
+// newTestDNSCache returns a cache asking s, with a clock moved by the
+// returned function
+func newTestDNSCache(s *nameServer, cfg *config.UpstreamDNSConfig) (*dnsCache, func(time.Duration)) {
+	c := newDNSCache()
+	c.dial = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, s.conn.LocalAddr().String())
+	}
+	var mu sync.Mutex
+	now := time.Now()
+	c.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	c.setConfig(cfg)
+	return c, func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+}
+
+func TestDNSCache_RecordTTL(t *testing.T) {
+	s := newNameServer(t, 30)
+	c, advance := newTestDNSCache(s, &config.UpstreamDNSConfig{MaxTTL: time.Minute})
+	ctx := context.Background()
+
+	// Concurrent lookups share one query
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			addrs, err := c.lookup(ctx, "backend.test")
+			assert.NoError(t, err)
+			assert.Len(t, addrs, 1)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, s.count("backend.test"))
+
+	advance(29 * time.Second)
+	_, err := c.lookup(ctx, "backend.test")
+	require.NoError(t, err)
+	assert.Equal(t, 1, s.count("backend.test"))
+
+	// Looked up again once the record TTL passed
+	advance(2 * time.Second)
+	addrs, err := c.lookup(ctx, "backend.test")
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", addrs[0].IP.String())
+	assert.Equal(t, 2, s.count("backend.test"))
+}
+
+func TestDNSCache_MaxTTL(t *testing.T) {
+	s := newNameServer(t, 3600)
+	c, advance := newTestDNSCache(s, &config.UpstreamDNSConfig{MaxTTL: 10 * time.Second})
+	ctx := context.Background()
+
+	_, err := c.lookup(ctx, "backend.test")
+	require.NoError(t, err)
+	advance(11 * time.Second)
+	_, err = c.lookup(ctx, "backend.test")
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.count("backend.test"))
+}
+
+func TestDNSCache_NegativeTTL(t *testing.T) {
+	s := newNameServer(t, 30)
+	c, advance := newTestDNSCache(s, &config.UpstreamDNSConfig{NegativeTTL: 10 * time.Second})
+	ctx := context.Background()
+
+	_, err := c.lookup(ctx, "missing.test")
+	var dnsErr *net.DNSError
+	require.ErrorAs(t, err, &dnsErr)
+	assert.True(t, dnsErr.IsNotFound)
+	queries := s.count("missing.test")
+
+	_, err = c.lookup(ctx, "missing.test")
+	require.Error(t, err)
+	assert.Equal(t, queries, s.count("missing.test"))
+
+	advance(11 * time.Second)
+	_, err = c.lookup(ctx, "missing.test")
+	require.Error(t, err)
+	assert.Greater(t, s.count("missing.test"), queries)
+}
+
+func TestDNSCache_StaleWhileResolverFails(t *testing.T) {
+	s := newNameServer(t, 30)
+	c, advance := newTestDNSCache(s, &config.UpstreamDNSConfig{NegativeTTL: 5 * time.Second, StaleTTL: time.Minute})
+	ctx := context.Background()
+
+	_, err := c.lookup(ctx, "backend.test")
+	require.NoError(t, err)
+	s.setFailing(true)
+
+	// The expired addresses are used, and the resolver asked again after negative_ttl
+	advance(31 * time.Second)
+	addrs, err := c.lookup(ctx, "backend.test")
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", addrs[0].IP.String())
+	queries := s.count("backend.test")
+	_, err = c.lookup(ctx, "backend.test")
+	require.NoError(t, err)
+	assert.Equal(t, queries, s.count("backend.test"))
+
+	advance(6 * time.Second)
+	_, err = c.lookup(ctx, "backend.test")
+	require.NoError(t, err)
+	assert.Greater(t, s.count("backend.test"), queries)
+
+	// Until stale_ttl passed
+	advance(time.Minute)
+	_, err = c.lookup(ctx, "backend.test")
+	require.Error(t, err)
+
+	// The resolver recovered
+	s.setFailing(false)
+	advance(6 * time.Second)
+	_, err = c.lookup(ctx, "backend.test")
+	require.NoError(t, err)
+}
+
+func TestProxy_UpstreamDNSCache(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+	_, port, _ := net.SplitHostPort(backend.Listener.Addr().String())
+
+	routes := []*config.RouteConfig{{Name: "api", Match: config.RouteMatch{Path: "/api"}, Service: "api-service"}}
+	services := map[string]*config.ServiceConfig{
+		"api-service": {
+			Name:         "api-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: "http://backend.test:" + port}},
+		},
+	}
+	p := NewProxy(route.NewRouter(routes, services))
+	s := newNameServer(t, 30)
+	p.dns.dial = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, s.conn.LocalAddr().String())
+	}
+	p.SetUpstreamConfig(config.UpstreamConfig{DNS: &config.UpstreamDNSConfig{}})
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		body, _ := io.ReadAll(w.Body)
+		assert.Equal(t, "ok", string(body))
+		// New connections use the cached addresses
+		p.transport.(interface{ CloseIdleConnections() }).CloseIdleConnections()
+	}
+	assert.Equal(t, 1, s.count("backend.test"))
+}
@@ -0,0 +1,163 @@
+package proxy
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+)
+
+const defaultErrorPageContentType = "text/html; charset=utf-8"
+
+// errorPage is a loaded error page configuration
+type errorPage struct {
+	body        []byte             // Static page
+	template    *template.Template // Rendered per response when set
+	contentType string
+	status      int
+}
+
+// errorPageData is passed to error page templates
+type errorPageData struct {
+	Status     int
+	StatusText string
+	Method     string
+	Host       string
+	Path       string
+}
+
+// errorPageTransport replaces upstream responses with an intercepted status
+// by the route's error page
+type errorPageTransport struct {
+	next  http.RoundTripper
+	pages map[int]*errorPage // upstream status -> page
+}
+
+// newErrorPageTransport loads the pages of a route, pages that fail to load
+// are skipped so the upstream response is passed on
+func newErrorPageTransport(next http.RoundTripper, routeName string, cfgs []*config.ErrorPageConfig) *errorPageTransport {
+	t := &errorPageTransport{next: next, pages: make(map[int]*errorPage)}
+	for _, cfg := range cfgs {
+		page, err := loadErrorPage(cfg)
+		if err != nil {
+			lg.GetInstance().Error("[%s] Failed to load error page: %v", routeName, err)
+			continue
+		}
+		for _, status := range cfg.StatusCodes {
+			if _, exists := t.pages[status]; !exists {
+				t.pages[status] = page
+			}
+		}
+	}
+
+	return t
+}
+
+func loadErrorPage(cfg *config.ErrorPageConfig) (*errorPage, error) {
+	page := &errorPage{contentType: cfg.ContentType, status: cfg.Status}
+	if page.contentType == "" {
+		page.contentType = defaultErrorPageContentType
+	}
+	if cfg.Template != "" {
+		tmpl, err := template.ParseFiles(cfg.Template)
+		if err != nil {
+			return nil, err
+		}
+		page.template = tmpl
+		return page, nil
+	}
+
+	body, err := os.ReadFile(cfg.File)
+	if err != nil {
+		return nil, err
+	}
+	page.body = body
+	return page, nil
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *errorPageTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	page, ok := t.pages[resp.StatusCode]
+	if !ok {
+		return resp, nil
+	}
+
+	status, body, err := page.render(req, resp.StatusCode)
+	if err != nil {
+		requestLogger(req).Error("Failed to render error page for %s: %v", req.URL.Path, err)
+		return resp, nil
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, copyBufferSize))
+	resp.Body.Close()
+
+	header := make(http.Header)
+	header.Set("Content-Type", page.contentType)
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	header.Set("Cache-Control", "no-store")
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		header.Set("Retry-After", retryAfter)
+	}
+
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         resp.Proto,
+		ProtoMajor:    resp.ProtoMajor,
+		ProtoMinor:    resp.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       resp.Request,
+	}, nil
+}
+
+// serve answers a request failing before any upstream response with the page
+// of status, it reports whether a page was sent
+func (t *errorPageTransport) serve(w http.ResponseWriter, r *http.Request, upstreamStatus int) bool {
+	page, ok := t.pages[upstreamStatus]
+	if !ok {
+		return false
+	}
+	status, body, err := page.render(r, upstreamStatus)
+	if err != nil {
+		requestLogger(r).Error("Failed to render error page for %s: %v", r.URL.Path, err)
+		return false
+	}
+
+	w.Header().Set("Content-Type", page.contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(body)
+	return true
+}
+
+// render returns the status and body sent for an upstream status
+func (p *errorPage) render(req *http.Request, upstreamStatus int) (int, []byte, error) {
+	status := upstreamStatus
+	if p.status != 0 {
+		status = p.status
+	}
+	if p.template == nil {
+		return status, p.body, nil
+	}
+
+	var buf bytes.Buffer
+	err := p.template.Execute(&buf, errorPageData{
+		Status:     status,
+		StatusText: http.StatusText(status),
+		Method:     req.Method,
+		Host:       req.Host,
+		Path:       req.URL.Path,
+	})
+	return status, buf.Bytes(), err
+}
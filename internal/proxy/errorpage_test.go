@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_ErrorPages(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/unavailable":
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("upstream details"))
+		case "/timeout":
+			w.WriteHeader(http.StatusGatewayTimeout)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not found"))
+		default:
+			w.Write([]byte(testResponseBody))
+		}
+	}))
+	defer backend.Close()
+
+	dir := t.TempDir()
+	templateFile := filepath.Join(dir, "error.html")
+	require.NoError(t, os.WriteFile(templateFile, []byte(`<h1>{{.Status}} {{.StatusText}}</h1><p>{{.Path}}</p>`), 0o600))
+	staticFile := filepath.Join(dir, "maintenance.json")
+	require.NoError(t, os.WriteFile(staticFile, []byte(`{"error":"maintenance"}`), 0o600))
+
+	services := map[string]*config.ServiceConfig{
+		"pages-service": {
+			Name:         "pages-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+		"down-service": {
+			Name:         "down-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: "http://127.0.0.1:1"}},
+		},
+	}
+	pages := []*config.ErrorPageConfig{
+		{StatusCodes: []int{502, 503}, Template: templateFile},
+		{StatusCodes: []int{504}, File: staticFile, ContentType: "application/json", Status: http.StatusServiceUnavailable},
+	}
+	router := route.NewRouter([]*config.RouteConfig{
+		{Name: "down", Match: config.RouteMatch{Path: "/down"}, Service: "down-service", ErrorPages: pages},
+		{Name: "pages", Match: config.RouteMatch{Path: "/*"}, Service: "pages-service", ErrorPages: pages},
+	}, services)
+	p := NewProxy(router)
+
+	tests := []struct {
+		name        string
+		path        string
+		status      int
+		contentType string
+		body        string
+		retryAfter  string
+	}{
+		{"TemplateKeepsStatus", "/unavailable", http.StatusServiceUnavailable, "text/html; charset=utf-8", "<h1>503 Service Unavailable</h1><p>/unavailable</p>", "30"},
+		{"StaticFileOverridesStatus", "/timeout", http.StatusServiceUnavailable, "application/json", `{"error":"maintenance"}`, ""},
+		{"NotIntercepted", "/missing", http.StatusNotFound, "", "not found", ""},
+		{"Success", "/ok", http.StatusOK, "", testResponseBody, ""},
+		{"UnreachableBackend", "/down", http.StatusBadGateway, "text/html; charset=utf-8", "<h1>502 Bad Gateway</h1><p>/down</p>", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.body, w.Body.String())
+			if tt.contentType != "" {
+				assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+				assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+			}
+			assert.Equal(t, tt.retryAfter, w.Header().Get("Retry-After"))
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+
+	"nexus/internal/balancer"
+	lg "nexus/internal/logger"
+	"nexus/internal/service"
+	"nexus/internal/stats"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrorInfo describes where a request failed, so error handlers can render
+// meaningful error pages and logs, see ErrorInfoFromRequest
+type ErrorInfo struct {
+	Route    string // Matched route, empty when no route matched
+	Service  string // Target service, empty when no route matched
+	Backend  string // Last backend the request was sent to, empty when none was selected
+	Attempts int    // Upstream requests made, including retries and hedges
+
+	// Labels of the service merged with those of the backend
+	Labels map[string]string
+}
+
+// ErrorHandler answers requests that could not be proxied, the error is
+// returned by routing, backend selection or the upstream. Requests no route
+// matches get route.ErrNoRoute, and services without servers to select
+// balancer.ErrNoServers
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// attemptLog records the upstream requests of a proxied request, hedged
+// requests are recorded concurrently
+type attemptLog struct {
+	mu       sync.Mutex
+	backend  string
+	attempts int
+	servers  []selectedServer // Selected by the balancers, released when the request ends
+}
+
+// selectedServer is a server selected for a request and the service whose
+// balancer selected it
+type selectedServer struct {
+	service service.Service
+	address string
+}
+
+func (a *attemptLog) selected(backend string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.backend = backend
+}
+
+// nextServer selects a server of the service with its balancer, the servers
+// selected for a request, its retries and hedges are released when it ends
+func nextServer(req *http.Request, svc service.Service) (string, error) {
+	target, err := svc.NextServer(req.Context())
+	if err != nil {
+		return "", err
+	}
+	if match, ok := req.Context().Value(matchContextKey{}).(*matchResult); ok {
+		match.attempts.mu.Lock()
+		match.attempts.servers = append(match.attempts.servers, selectedServer{service: svc, address: target})
+		match.attempts.mu.Unlock()
+	}
+
+	return target, nil
+}
+
+// release tells the balancers tracking requests in flight that the request
+// sent to the servers they selected ended
+func (a *attemptLog) release() {
+	a.mu.Lock()
+	servers := a.servers
+	a.servers = nil
+	a.mu.Unlock()
+
+	for _, server := range servers {
+		if tracker, ok := server.service.Balancer().(balancer.Tracker); ok {
+			tracker.Done(server.address)
+		}
+	}
+}
+
+func (a *attemptLog) attempted(backend string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.backend = backend
+	a.attempts++
+}
+
+// attemptTransport counts and traces the requests sent upstream, it wraps the
+// base transport so retries and hedges of every route are included
+type attemptTransport struct {
+	next  http.RoundTripper
+	proxy *Proxy
+	pool  *stats.Pool // Counts the connections of next, nil for custom transports
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t attemptTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backend := req.URL.Scheme + "://" + req.URL.Host
+	var labels []attribute.KeyValue
+	if match, ok := req.Context().Value(matchContextKey{}).(*matchResult); ok {
+		match.attempts.attempted(backend)
+		if labels = labelAttributes(match.labels(backend)); labels != nil {
+			trace.SpanFromContext(req.Context()).SetAttributes(labels...)
+		}
+	}
+	var pooled *pooledRequest
+	if t.pool != nil {
+		pooled = &pooledRequest{}
+		req = req.WithContext(context.WithValue(req.Context(), poolServerKey{}, backend))
+	}
+	if clientTrace := t.proxy.createClientTrace(req.Context(), backend, labels, pooled); clientTrace != nil {
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), clientTrace))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	// Requests canceled by the client or a faster hedge didn't fail upstream
+	if err != nil && req.Context().Err() == nil || err == nil && resp.StatusCode >= http.StatusInternalServerError {
+		if match, ok := req.Context().Value(matchContextKey{}).(*matchResult); ok && match.service != nil {
+			match.service.Balancer().Failed(backend)
+		}
+	}
+	if pooled != nil {
+		var routeName string
+		if match, ok := req.Context().Value(matchContextKey{}).(*matchResult); ok && match.route != nil {
+			routeName = match.route.Name
+		}
+		t.proxy.trackResponse(resp, err, pooled, backend, routeName)
+	}
+	return resp, err
+}
+
+// ErrorInfoFromRequest returns the route, service and upstream attempts of a
+// request handled by a Proxy, e.g. in its error handler. It reports false for
+// requests the proxy hasn't seen
+func ErrorInfoFromRequest(r *http.Request) (ErrorInfo, bool) {
+	var info ErrorInfo
+	match, ok := r.Context().Value(matchContextKey{}).(*matchResult)
+	if !ok {
+		return info, false
+	}
+	if match.route != nil {
+		info.Route = match.route.Name
+	}
+	if match.service != nil {
+		info.Service = match.service.Name()
+	}
+	match.attempts.mu.Lock()
+	info.Backend = match.attempts.backend
+	info.Attempts = match.attempts.attempts
+	match.attempts.mu.Unlock()
+	info.Labels = match.labels(info.Backend)
+
+	return info, true
+}
+
+// requestLogger returns the logger of the logs about a request, adding its
+// route, service and backend once known to the fields of its context
+func requestLogger(r *http.Request) *lg.Logger {
+	logger := lg.FromContext(r.Context())
+	match, ok := r.Context().Value(matchContextKey{}).(*matchResult)
+	if !ok {
+		return logger
+	}
+
+	fields := make([]lg.Field, 0, 3)
+	if match.route != nil {
+		fields = append(fields, lg.F("route", match.route.Name))
+	}
+	if match.service != nil {
+		fields = append(fields, lg.F("service", match.service.Name()))
+	}
+	match.attempts.mu.Lock()
+	backend := match.attempts.backend
+	match.attempts.mu.Unlock()
+	if backend != "" {
+		fields = append(fields, lg.F("backend", backend))
+	}
+	return logger.With(fields...)
+}
+
+// SetErrorHandler sets a custom error handler function
+func (p *Proxy) SetErrorHandler(handler ErrorHandler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.errorHandler = handler
+}
+
+// handleError handles errors during the proxy process, except for requests
+// the client canceled
+func (p *Proxy) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	if clientCanceled(r) {
+		p.handleClientCanceled(w, r, err)
+		return
+	}
+	p.mu.RLock()
+	handler := p.errorHandler
+	p.mu.RUnlock()
+
+	if handler != nil {
+		handler(w, r.WithContext(lg.NewContext(r.Context(), requestLogger(r))), err)
+	} else {
+		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+	}
+}
+
+// handleUpstreamError handles errors of the reverse proxy, without a custom
+// handler they are answered like httputil.ReverseProxy does. Requests the
+// client canceled are not handled as errors
+func (p *Proxy) handleUpstreamError(w http.ResponseWriter, r *http.Request, err error) {
+	if clientCanceled(r) {
+		p.handleClientCanceled(w, r, err)
+		return
+	}
+	p.mu.RLock()
+	handler := p.errorHandler
+	p.mu.RUnlock()
+
+	if handler != nil {
+		handler(w, r.WithContext(lg.NewContext(r.Context(), requestLogger(r))), err)
+		return
+	}
+	requestLogger(r).Error("http: proxy error: %v", err)
+	if match, ok := r.Context().Value(matchContextKey{}).(*matchResult); ok {
+		if pages, ok := match.transport.(*errorPageTransport); ok && pages.serve(w, r, http.StatusBadGateway) {
+			return
+		}
+	}
+	w.WriteHeader(http.StatusBadGateway)
+}
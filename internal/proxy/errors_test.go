@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nexus/internal/balancer"
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_ErrorInfo(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		servers  []string
+		expected ErrorInfo
+		err      error // Sentinel the error matches
+	}{
+		{
+			name:     "no route",
+			path:     "/other",
+			servers:  []string{failing.URL},
+			expected: ErrorInfo{},
+			err:      route.ErrNoRoute,
+		},
+		{
+			name:     "no servers",
+			path:     "/api",
+			expected: ErrorInfo{Route: "expect-route", Service: "expect-service"},
+			err:      balancer.ErrNoServers,
+		},
+		{
+			name:     "retried on every backend",
+			path:     "/api",
+			servers:  []string{failing.URL, failing.URL},
+			expected: ErrorInfo{Route: "expect-route", Service: "expect-service", Backend: failing.URL, Attempts: 2},
+		},
+		{
+			name:     "unreachable backend",
+			path:     "/api",
+			servers:  []string{closed.URL},
+			expected: ErrorInfo{Route: "expect-route", Service: "expect-service", Backend: closed.URL, Attempts: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newExpectTestProxy(&config.ExpectConfig{StatusCodes: []int{http.StatusOK}, MaxRetries: 1}, tt.servers...)
+
+			var handled []ErrorInfo
+			p.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+				assert.Error(t, err)
+				info, ok := ErrorInfoFromRequest(r)
+				assert.True(t, ok)
+				if tt.err != nil {
+					assert.ErrorIs(t, err, tt.err)
+				}
+				handled = append(handled, info)
+				w.WriteHeader(http.StatusTeapot)
+			})
+
+			w := httptest.NewRecorder()
+			p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, http.StatusTeapot, w.Code)
+			require.Len(t, handled, 1)
+			assert.Equal(t, tt.expected, handled[0])
+		})
+	}
+
+	// Requests the proxy hasn't seen carry no error info
+	_, ok := ErrorInfoFromRequest(httptest.NewRequest(http.MethodGet, "/api", nil))
+	assert.False(t, ok)
+}
+
+func TestProxy_UpstreamErrorWithoutHandler(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	p := newExpectTestProxy(&config.ExpectConfig{}, closed.URL)
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api", nil))
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+}
+
+func TestProxy_BalancerErrors(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ok.Close()
+
+	p := newExpectTestProxy(nil, failing.URL, ok.URL)
+	for i := 0; i < 4; i++ {
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api", nil))
+	}
+
+	stats := p.router.Services()["expect-service"].Balancer().Stats()
+	assert.Equal(t, uint64(2), stats[failing.URL].Selections)
+	assert.Equal(t, uint64(2), stats[failing.URL].Errors)
+	assert.Equal(t, uint64(2), stats[ok.URL].Selections)
+	assert.Zero(t, stats[ok.URL].Errors)
+}
@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"nexus/internal/config"
+	"nexus/internal/service"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultExpectMaxBodySize is the largest body inspected when max_body_size is not set
+const defaultExpectMaxBodySize = 1 << 20
+
+// expectTransport rejects upstream responses violating the route expectation
+// and retries the request on another backend
+type expectTransport struct {
+	next    http.RoundTripper
+	service service.Service
+	cfg     *config.ExpectConfig
+}
+
+func newExpectTransport(next http.RoundTripper, svc service.Service, cfg *config.ExpectConfig) *expectTransport {
+	return &expectTransport{
+		next:    next,
+		service: svc,
+		cfg:     cfg,
+	}
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *expectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retries := 0
+	if isReplayable(req) {
+		retries = t.cfg.MaxRetries
+	}
+
+	current := req
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(current)
+		if err != nil {
+			return nil, err
+		}
+
+		violation := t.check(resp)
+		if violation == nil {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		trace.SpanFromContext(req.Context()).AddEvent("Upstream response rejected",
+			trace.WithAttributes(
+				attribute.String("backend.address", current.URL.Host),
+				attribute.String("reason", violation.Error()),
+				attribute.Int("attempt", attempt+1),
+			))
+
+		if attempt >= retries {
+			return nil, fmt.Errorf("upstream response validation failed: %w", violation)
+		}
+
+		target, err := nextServer(req, t.service)
+		if err != nil {
+			return nil, err
+		}
+		if current, err = retarget(req, target); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// check validates status, content type and JSON fields of the response
+func (t *expectTransport) check(resp *http.Response) error {
+	if len(t.cfg.StatusCodes) > 0 && !containsStatus(t.cfg.StatusCodes, resp.StatusCode) {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	if t.cfg.ContentType != "" {
+		mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if err != nil || !strings.EqualFold(mediaType, t.cfg.ContentType) {
+			return fmt.Errorf("unexpected content type: %q", resp.Header.Get("Content-Type"))
+		}
+	}
+
+	if len(t.cfg.JSONFields) == 0 {
+		return nil
+	}
+
+	limit := t.cfg.MaxBodySize
+	if limit == 0 {
+		limit = defaultExpectMaxBodySize
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	// Put the inspected bytes back so the client still receives the full body
+	resp.Body = &replayBody{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+
+	if int64(len(body)) > limit {
+		return fmt.Errorf("response body exceeds %d bytes", limit)
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return fmt.Errorf("invalid json body: %w", err)
+	}
+	for _, field := range t.cfg.JSONFields {
+		if !hasJSONField(doc, field) {
+			return fmt.Errorf("missing json field: %s", field)
+		}
+	}
+
+	return nil
+}
+
+// hasJSONField reports whether a dotted path exists in a decoded JSON document
+func hasJSONField(doc interface{}, path string) bool {
+	current := doc
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if current, ok = obj[key]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsStatus(codes []int, code int) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// replayBody serves already consumed bytes before the rest of the original body
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+)
+
+func newExpectTestProxy(expect *config.ExpectConfig, servers ...string) *Proxy {
+	serverConfigs := make([]config.ServerConfig, 0, len(servers))
+	for _, s := range servers {
+		serverConfigs = append(serverConfigs, config.ServerConfig{Address: s})
+	}
+
+	router := route.NewRouter([]*config.RouteConfig{
+		{
+			Name:    "expect-route",
+			Match:   config.RouteMatch{Path: "/api"},
+			Service: "expect-service",
+			Expect:  expect,
+		},
+	}, map[string]*config.ServiceConfig{
+		"expect-service": {
+			Name:         "expect-service",
+			BalancerType: "round_robin",
+			Servers:      serverConfigs,
+		},
+	})
+
+	return NewProxy(router)
+}
+
+func TestExpect_FailoverOnBadPayload(t *testing.T) {
+	var badCalls int32
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&badCalls, 1)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html>maintenance</html>"))
+	}))
+	defer bad.Close()
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(`{"data":{"id":1}}`))
+	}))
+	defer good.Close()
+
+	p := newExpectTestProxy(&config.ExpectConfig{
+		StatusCodes: []int{http.StatusOK},
+		ContentType: "application/json",
+		JSONFields:  []string{"data.id"},
+		MaxRetries:  1,
+	}, bad.URL, good.URL)
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if got := w.Body.String(); got != `{"data":{"id":1}}` {
+		t.Errorf("Expected full body from healthy backend, got %q", got)
+	}
+	if atomic.LoadInt32(&badCalls) != 1 {
+		t.Errorf("Expected the bad backend to be tried once, got %d", badCalls)
+	}
+}
+
+func TestExpect_ViolationWithoutRetries(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer backend.Close()
+
+	p := newExpectTestProxy(&config.ExpectConfig{StatusCodes: []int{http.StatusOK}, MaxRetries: 3}, backend.URL)
+
+	// POST requests are never retried, the violation becomes a gateway error
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api", strings.NewReader("{}")))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("Expected status 502, got %d", w.Code)
+	}
+}
+
+func TestExpectTransport_Check(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         *config.ExpectConfig
+		status      int
+		contentType string
+		body        string
+		expectErr   string
+	}{
+		{
+			name:   "AnyStatusAllowed",
+			cfg:    &config.ExpectConfig{},
+			status: http.StatusTeapot,
+		},
+		{
+			name:      "UnexpectedStatus",
+			cfg:       &config.ExpectConfig{StatusCodes: []int{200, 204}},
+			status:    http.StatusBadGateway,
+			expectErr: "unexpected status code",
+		},
+		{
+			name:        "UnexpectedContentType",
+			cfg:         &config.ExpectConfig{ContentType: "application/json"},
+			status:      http.StatusOK,
+			contentType: "text/plain",
+			expectErr:   "unexpected content type",
+		},
+		{
+			name:      "InvalidJSON",
+			cfg:       &config.ExpectConfig{JSONFields: []string{"id"}},
+			status:    http.StatusOK,
+			body:      "not json",
+			expectErr: "invalid json body",
+		},
+		{
+			name:      "MissingNestedField",
+			cfg:       &config.ExpectConfig{JSONFields: []string{"meta.version"}},
+			status:    http.StatusOK,
+			body:      `{"meta":{}}`,
+			expectErr: "missing json field: meta.version",
+		},
+		{
+			name:      "BodyTooLarge",
+			cfg:       &config.ExpectConfig{JSONFields: []string{"id"}, MaxBodySize: 4},
+			status:    http.StatusOK,
+			body:      `{"id":12345}`,
+			expectErr: "response body exceeds 4 bytes",
+		},
+		{
+			name:   "FieldsPresent",
+			cfg:    &config.ExpectConfig{JSONFields: []string{"id", "meta.version"}},
+			status: http.StatusOK,
+			body:   `{"id":1,"meta":{"version":"v1"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: tt.status,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}
+			if tt.contentType != "" {
+				resp.Header.Set("Content-Type", tt.contentType)
+			}
+
+			err := newExpectTransport(nil, nil, tt.cfg).check(resp)
+			if tt.expectErr == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				if string(body) != tt.body {
+					t.Errorf("Expected body %q to be preserved, got %q", tt.body, body)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	lg "nexus/internal/logger"
+	"nexus/internal/route"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultExperimentCookieTTL is the lifetime of assignment cookies when the
+// experiment sets none
+const defaultExperimentCookieTTL = 30 * 24 * time.Hour
+
+// assignExperiment assigns requests of experiment routes to a variant. Users
+// without a key get a new one, kept in a cookie for cookie keyed experiments,
+// and the request is matched again so the router picks the same variant
+func (p *Proxy) assignExperiment(w http.ResponseWriter, r *http.Request, match *matchResult) (*http.Request, *matchResult) {
+	if match.route == nil || match.route.Experiment == nil {
+		return r, match
+	}
+
+	key := route.ExperimentKey(r, match.route)
+	if key == "" {
+		key = newRequestID()
+		r = r.WithContext(route.WithExperimentKey(r.Context(), key))
+		if source, name := route.ExperimentKeySource(match.route); source == "cookie" {
+			ttl := match.route.Experiment.CookieTTL
+			if ttl == 0 {
+				ttl = defaultExperimentCookieTTL
+			}
+			http.SetCookie(w, &http.Cookie{
+				Name:     name,
+				Value:    key,
+				Path:     "/",
+				MaxAge:   int(ttl / time.Second),
+				HttpOnly: true,
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		routeConfig, svc := p.router.MatchRoute(r)
+		match = &matchResult{route: routeConfig, service: svc}
+		if routeConfig == nil || routeConfig.Experiment == nil {
+			// The routes changed meanwhile
+			return r, match
+		}
+	}
+	match.variant = route.AssignVariant(match.route, key)
+
+	return r, match
+}
+
+// experimentMiddleware tags responses of experiment routes with their
+// variant and reports requests, errors and latency per variant
+func (p *Proxy) experimentMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match := p.match(r)
+		if match.variant == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		name := route.ExperimentName(match.route)
+		header := match.route.Experiment.ResponseHeader
+		if header == "" {
+			header = route.DefaultExperimentHeader
+		}
+		w.Header().Set(header, match.variant.Name)
+		trace.SpanFromContext(r.Context()).SetAttributes(
+			attribute.String("experiment.name", name),
+			attribute.String("experiment.variant", match.variant.Name),
+		)
+
+		p.mu.RLock()
+		metrics := p.experiments
+		p.mu.RUnlock()
+		if metrics == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		recorder := &statsRecorder{ResponseWriter: w}
+		defer func() {
+			metrics.record(r.Context(), match.route.Name, name, match.variant.Name, recorder.status, time.Since(start))
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// experimentMetrics records requests and latency per experiment variant
+type experimentMetrics struct {
+	requests otelmetric.Int64Counter
+	duration otelmetric.Float64Histogram
+}
+
+func newExperimentMetrics(meter otelmetric.Meter) *experimentMetrics {
+	requests, err := meter.Int64Counter(
+		"nexus.experiment.requests",
+		otelmetric.WithDescription("Requests of experiment routes per variant"),
+	)
+	if err != nil {
+		lg.GetInstance().Error("Failed to register experiment request metrics: %v", err)
+		return nil
+	}
+	duration, err := meter.Float64Histogram(
+		"nexus.experiment.duration",
+		otelmetric.WithDescription("Latency of experiment route requests per variant"),
+		otelmetric.WithUnit("ms"),
+	)
+	if err != nil {
+		lg.GetInstance().Error("Failed to register experiment latency metrics: %v", err)
+		return nil
+	}
+
+	return &experimentMetrics{requests: requests, duration: duration}
+}
+
+// record reports a request, responses with server errors and requests
+// aborted before a response count as errors
+func (m *experimentMetrics) record(ctx context.Context, routeName, experiment, variant string, status int, elapsed time.Duration) {
+	attrs := []attribute.KeyValue{
+		attribute.String("route", routeName),
+		attribute.String("experiment", experiment),
+		attribute.String("variant", variant),
+	}
+	m.duration.Record(ctx, float64(elapsed)/float64(time.Millisecond), otelmetric.WithAttributes(attrs...))
+	m.requests.Add(ctx, 1, otelmetric.WithAttributes(append(attrs,
+		attribute.String("status", strconv.Itoa(status)),
+		attribute.Bool("error", status == 0 || status >= http.StatusInternalServerError),
+	)...))
+}
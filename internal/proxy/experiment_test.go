@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestProxy_Experiment(t *testing.T) {
+	newBackend := func(name string, status int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Backend", name)
+			w.WriteHeader(status)
+		}))
+	}
+	control := newBackend("control", http.StatusOK)
+	defer control.Close()
+	treatment := newBackend("treatment", http.StatusInternalServerError)
+	defer treatment.Close()
+
+	routes := []*config.RouteConfig{
+		{Name: "checkout", Match: config.RouteMatch{Path: "/checkout"}, Experiment: &config.ExperimentConfig{
+			Variants: []*config.ExperimentVariant{
+				{Name: "control", Service: "control", Weight: 1},
+				{Name: "treatment", Service: "treatment", Weight: 1},
+			},
+		}},
+		{Name: "search", Match: config.RouteMatch{Path: "/search"}, Experiment: &config.ExperimentConfig{
+			Name:           "ranking",
+			Key:            "header:X-User",
+			ResponseHeader: "X-Ranking",
+			Variants: []*config.ExperimentVariant{
+				{Name: "a", Service: "control", Weight: 1},
+				{Name: "b", Service: "treatment", Weight: 1},
+			},
+		}},
+	}
+	services := map[string]*config.ServiceConfig{
+		"control":   {Name: "control", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: control.URL}}},
+		"treatment": {Name: "treatment", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: treatment.URL}}},
+	}
+	p := NewProxy(route.NewRouter(routes, services))
+	reader := sdkmetric.NewManualReader()
+	p.SetMeter(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"))
+
+	// New users get a cookie keeping them in the variant they were assigned
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/checkout", nil))
+	variant := w.Header().Get("X-Experiment-Variant")
+	require.Contains(t, []string{"control", "treatment"}, variant)
+	assert.Equal(t, variant, w.Header().Get("X-Backend"))
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "nexus_exp_checkout", cookies[0].Name)
+	assert.Equal(t, 30*24*3600, cookies[0].MaxAge)
+
+	for i := 0; i < 5; i++ {
+		w = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/checkout", nil)
+		req.AddCookie(cookies[0])
+		p.ServeHTTP(w, req)
+		assert.Equal(t, variant, w.Header().Get("X-Experiment-Variant"))
+		assert.Equal(t, variant, w.Header().Get("X-Backend"))
+		assert.Empty(t, w.Result().Cookies())
+	}
+
+	// Header keyed experiments don't set cookies
+	want := route.AssignVariant(routes[1], "user-1").Name
+	for i := 0; i < 3; i++ {
+		w = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/search", nil)
+		req.Header.Set("X-User", "user-1")
+		p.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Header().Get("X-Ranking"))
+		assert.Empty(t, w.Result().Cookies())
+	}
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	counts := make(map[string]int64)
+	errors := make(map[string]bool)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		if m.Name != "nexus.experiment.requests" {
+			continue
+		}
+		for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+			experiment, _ := dp.Attributes.Value(attribute.Key("experiment"))
+			v, _ := dp.Attributes.Value(attribute.Key("variant"))
+			failed, _ := dp.Attributes.Value(attribute.Key("error"))
+			key := experiment.AsString() + "/" + v.AsString()
+			counts[key] += dp.Value
+			errors[key] = failed.AsBool()
+		}
+	}
+	assert.Equal(t, map[string]int64{"checkout/" + variant: 6, "ranking/" + want: 3}, counts)
+	assert.Equal(t, variant == "treatment", errors["checkout/"+variant])
+}
@@ -0,0 +1,294 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"nexus/internal/config"
+)
+
+var (
+	errQueueFull    = errors.New("concurrency queue is full")
+	errQueueTimeout = errors.New("timed out waiting in concurrency queue")
+)
+
+// defaultQueueTimeout bounds the wait for a slot when queue_timeout is not set
+const defaultQueueTimeout = 10 * time.Second
+
+// fairLimiter limits concurrent requests and, once saturated, hands free slots
+// to waiting clients in weighted round-robin order so one client can't
+// monopolize the backend capacity. Waiters of a higher priority class are
+// served first and displace best-effort waiters from a full queue
+type fairLimiter struct {
+	mu      sync.Mutex
+	cfg     *config.ConcurrencyConfig
+	active  int
+	queued  int
+	classes [numPriorities]fairQueue
+}
+
+// fairQueue holds the waiters of one priority class
+type fairQueue struct {
+	queues   map[string][]*fairWaiter
+	ring     []string // clients with waiting requests, in service order
+	next     int
+	turnUsed int // slots given to ring[next] in its current turn
+	queued   int
+}
+
+type fairWaiter struct {
+	ready     chan struct{}
+	granted   bool
+	displaced bool // rejected to make room for a higher priority waiter
+	priority  priority
+}
+
+func newFairLimiter(cfg *config.ConcurrencyConfig) *fairLimiter {
+	l := &fairLimiter{cfg: cfg}
+	for i := range l.classes {
+		l.classes[i].queues = make(map[string][]*fairWaiter)
+	}
+
+	return l
+}
+
+// setConfig applies reloaded limits, queued requests keep waiting
+func (l *fairLimiter) setConfig(cfg *config.ConcurrencyConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cfg = cfg
+	for l.active < cfg.MaxConcurrent && l.queued > 0 {
+		l.active++
+		l.grantNext()
+	}
+}
+
+// acquire waits for a slot, the caller must release it when done
+func (l *fairLimiter) acquire(ctx context.Context, client string, prio priority) error {
+	l.mu.Lock()
+	if l.active < l.cfg.MaxConcurrent && l.queued == 0 {
+		l.active++
+		l.mu.Unlock()
+		return nil
+	}
+	if l.queued >= l.cfg.MaxQueue && !l.displace(prio) {
+		l.mu.Unlock()
+		return errQueueFull
+	}
+
+	w := &fairWaiter{ready: make(chan struct{}), priority: prio}
+	q := &l.classes[prio]
+	if len(q.queues[client]) == 0 {
+		q.ring = append(q.ring, client)
+	}
+	q.queues[client] = append(q.queues[client], w)
+	q.queued++
+	l.queued++
+	timeout := l.cfg.QueueTimeout
+	l.mu.Unlock()
+
+	if timeout <= 0 {
+		timeout = defaultQueueTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-w.ready:
+		if w.displaced {
+			return errQueueFull
+		}
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timer.C:
+		err = errQueueTimeout
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w.granted {
+		// The slot was handed over while giving up, pass it on
+		l.releaseLocked()
+		return err
+	}
+	if w.displaced {
+		return err
+	}
+	l.removeWaiter(client, w)
+
+	return err
+}
+
+// displace rejects the latest waiter of the lowest class below prio to make
+// room in a full queue, it reports whether a waiter was displaced
+func (l *fairLimiter) displace(prio priority) bool {
+	for class := priority(numPriorities - 1); class > prio; class-- {
+		q := &l.classes[class]
+		if q.queued == 0 {
+			continue
+		}
+		client := q.ring[len(q.ring)-1]
+		queue := q.queues[client]
+		w := queue[len(queue)-1]
+		l.removeWaiter(client, w)
+		w.displaced = true
+		close(w.ready)
+		return true
+	}
+
+	return false
+}
+
+// release frees a slot, handing it to the next waiting client if any
+func (l *fairLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.releaseLocked()
+}
+
+func (l *fairLimiter) releaseLocked() {
+	if l.queued > 0 && l.active <= l.cfg.MaxConcurrent {
+		l.grantNext()
+		return
+	}
+	l.active--
+}
+
+// grantNext wakes the next waiter of the highest priority class in weighted
+// round-robin order, the slot count stays the same
+func (l *fairLimiter) grantNext() {
+	q := &l.classes[0]
+	for i := range l.classes {
+		if l.classes[i].queued > 0 {
+			q = &l.classes[i]
+			break
+		}
+	}
+
+	client := q.ring[q.next]
+	queue := q.queues[client]
+	w := queue[0]
+	q.queues[client] = queue[1:]
+	q.queued--
+	l.queued--
+
+	w.granted = true
+	close(w.ready)
+
+	q.turnUsed++
+	if len(q.queues[client]) == 0 {
+		delete(q.queues, client)
+		q.removeFromRing(q.next)
+		return
+	}
+	if q.turnUsed >= l.weight(client) {
+		q.advance()
+	}
+}
+
+// weight returns the slots per turn of a client, weights are keyed by the IP
+// address or header value without the key type prefix
+func (l *fairLimiter) weight(client string) int {
+	if i := strings.IndexByte(client, ':'); i >= 0 {
+		client = client[i+1:]
+	}
+	if w := l.cfg.Weights[client]; w > 0 {
+		return w
+	}
+	return 1
+}
+
+func (q *fairQueue) advance() {
+	q.turnUsed = 0
+	q.next++
+	if q.next >= len(q.ring) {
+		q.next = 0
+	}
+}
+
+// removeFromRing drops a client from the ring, the following client starts a new turn
+func (q *fairQueue) removeFromRing(i int) {
+	q.ring = append(q.ring[:i], q.ring[i+1:]...)
+	if i < q.next {
+		q.next--
+	} else if i == q.next {
+		q.turnUsed = 0
+	}
+	if q.next >= len(q.ring) {
+		q.next = 0
+	}
+}
+
+func (l *fairLimiter) removeWaiter(client string, w *fairWaiter) {
+	q := &l.classes[w.priority]
+	queue := q.queues[client]
+	for i, queued := range queue {
+		if queued == w {
+			q.queues[client] = append(queue[:i], queue[i+1:]...)
+			q.queued--
+			l.queued--
+			break
+		}
+	}
+	if len(q.queues[client]) > 0 {
+		return
+	}
+
+	delete(q.queues, client)
+	for i, c := range q.ring {
+		if c == client {
+			q.removeFromRing(i)
+			break
+		}
+	}
+}
+
+// concurrencyLimiter returns the shared limiter of a service
+func (p *Proxy) concurrencyLimiter(service string, cfg *config.ConcurrencyConfig) *fairLimiter {
+	value, loaded := p.concurrencyLimiters.LoadOrStore(service, newFairLimiter(cfg))
+	limiter := value.(*fairLimiter)
+	if loaded {
+		limiter.mu.Lock()
+		changed := limiter.cfg != cfg
+		limiter.mu.Unlock()
+		if changed {
+			limiter.setConfig(cfg)
+		}
+	}
+
+	return limiter
+}
+
+// concurrencyMiddleware applies the concurrency limit of the matched service
+func (p *Proxy) concurrencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match := p.match(r)
+		if match.service == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		svcConfig := match.service.Config()
+		if svcConfig == nil || svcConfig.Concurrency == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		limiter := p.concurrencyLimiter(svcConfig.Name, svcConfig.Concurrency)
+		if err := limiter.acquire(r.Context(), rateLimitKey(svcConfig.Concurrency.Key, r), routePriority(match.route)); err != nil {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Service overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		defer limiter.release()
+
+		next.ServeHTTP(w, r)
+	})
+}
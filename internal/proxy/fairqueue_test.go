@@ -0,0 +1,208 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// enqueue starts a waiting acquire and blocks until it is queued
+func enqueue(t *testing.T, l *fairLimiter, client string, prio priority, granted chan<- string) {
+	l.mu.Lock()
+	want := l.queued + 1
+	l.mu.Unlock()
+
+	go func() {
+		if err := l.acquire(context.Background(), client, prio); err == nil {
+			granted <- client
+		}
+	}()
+
+	require.Eventually(t, func() bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.queued == want
+	}, time.Second, time.Millisecond)
+}
+
+// drain releases one slot at a time and records which client got it
+func drain(t *testing.T, l *fairLimiter, granted <-chan string, n int) []string {
+	var order []string
+	for i := 0; i < n; i++ {
+		l.release()
+		select {
+		case client := <-granted:
+			order = append(order, client)
+		case <-time.After(time.Second):
+			t.Fatalf("no slot granted after %d releases", i+1)
+		}
+	}
+	return order
+}
+
+func TestFairLimiter_RoundRobin(t *testing.T) {
+	tests := []struct {
+		name     string
+		weights  map[string]int
+		queued   []string
+		expected []string
+	}{
+		{
+			name:     "OneSlotPerClient",
+			queued:   []string{"ip:a", "ip:a", "ip:a", "ip:b", "ip:c"},
+			expected: []string{"ip:a", "ip:b", "ip:c", "ip:a", "ip:a"},
+		},
+		{
+			name:     "WeightedClient",
+			weights:  map[string]int{"a": 2},
+			queued:   []string{"ip:a", "ip:a", "ip:a", "ip:a", "ip:b", "ip:b"},
+			expected: []string{"ip:a", "ip:a", "ip:b", "ip:a", "ip:a", "ip:b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newFairLimiter(&config.ConcurrencyConfig{MaxConcurrent: 1, MaxQueue: 10, Weights: tt.weights})
+			require.NoError(t, l.acquire(context.Background(), "ip:a", priorityDefault))
+
+			granted := make(chan string, len(tt.queued))
+			for _, client := range tt.queued {
+				enqueue(t, l, client, priorityDefault, granted)
+			}
+
+			assert.Equal(t, tt.expected, drain(t, l, granted, len(tt.queued)))
+		})
+	}
+}
+
+func TestFairLimiter_Rejects(t *testing.T) {
+	t.Run("QueueFull", func(t *testing.T) {
+		l := newFairLimiter(&config.ConcurrencyConfig{MaxConcurrent: 1, MaxQueue: 1})
+		require.NoError(t, l.acquire(context.Background(), "ip:a", priorityDefault))
+		enqueue(t, l, "ip:a", priorityDefault, make(chan string, 1))
+
+		assert.ErrorIs(t, l.acquire(context.Background(), "ip:b", priorityDefault), errQueueFull)
+	})
+
+	t.Run("QueueTimeout", func(t *testing.T) {
+		l := newFairLimiter(&config.ConcurrencyConfig{MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: 20 * time.Millisecond})
+		require.NoError(t, l.acquire(context.Background(), "ip:a", priorityDefault))
+
+		assert.ErrorIs(t, l.acquire(context.Background(), "ip:b", priorityDefault), errQueueTimeout)
+		assert.Equal(t, 0, l.queued)
+		assert.Empty(t, l.classes[priorityDefault].ring)
+	})
+
+	t.Run("ContextCanceled", func(t *testing.T) {
+		l := newFairLimiter(&config.ConcurrencyConfig{MaxConcurrent: 1, MaxQueue: 1})
+		require.NoError(t, l.acquire(context.Background(), "ip:a", priorityDefault))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, l.acquire(ctx, "ip:b", priorityDefault), context.DeadlineExceeded)
+
+		// The slot is free again once the holder releases it
+		l.release()
+		assert.NoError(t, l.acquire(context.Background(), "ip:b", priorityDefault))
+	})
+}
+
+func TestFairLimiter_Priority(t *testing.T) {
+	l := newFairLimiter(&config.ConcurrencyConfig{MaxConcurrent: 1, MaxQueue: 3})
+	require.NoError(t, l.acquire(context.Background(), "ip:a", priorityDefault))
+
+	granted := make(chan string, 4)
+	enqueue(t, l, "ip:batch", priorityBestEffort, granted)
+	enqueue(t, l, "ip:web", priorityDefault, granted)
+	enqueue(t, l, "ip:batch", priorityBestEffort, granted)
+
+	// Best-effort requests are rejected by a full queue, higher classes
+	// displace the latest best-effort waiter
+	assert.ErrorIs(t, l.acquire(context.Background(), "ip:web", priorityBestEffort), errQueueFull)
+	go func() {
+		if err := l.acquire(context.Background(), "ip:payments", priorityCritical); err == nil {
+			granted <- "ip:payments"
+		}
+	}()
+	require.Eventually(t, func() bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.classes[priorityCritical].queued == 1
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 1, l.classes[priorityBestEffort].queued)
+
+	assert.Equal(t, []string{"ip:payments", "ip:web", "ip:batch"}, drain(t, l, granted, 3))
+}
+
+func TestFairLimiter_SetConfig(t *testing.T) {
+	l := newFairLimiter(&config.ConcurrencyConfig{MaxConcurrent: 1, MaxQueue: 10})
+	require.NoError(t, l.acquire(context.Background(), "ip:a", priorityDefault))
+
+	granted := make(chan string, 2)
+	enqueue(t, l, "ip:a", priorityDefault, granted)
+	enqueue(t, l, "ip:b", priorityDefault, granted)
+
+	// Raising the limit grants waiting requests right away
+	l.setConfig(&config.ConcurrencyConfig{MaxConcurrent: 3, MaxQueue: 10})
+	assert.ElementsMatch(t, []string{"ip:a", "ip:b"}, []string{<-granted, <-granted})
+	assert.Equal(t, 3, l.active)
+}
+
+func TestProxy_ConcurrencyLimit(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte(testResponseBody))
+	}))
+	defer backend.Close()
+
+	router := route.NewRouter([]*config.RouteConfig{
+		{
+			Name:    "limited-route",
+			Match:   config.RouteMatch{Path: "/"},
+			Service: "limited-service",
+		},
+	}, map[string]*config.ServiceConfig{
+		"limited-service": {
+			Name:         "limited-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+			Concurrency:  &config.ConcurrencyConfig{MaxConcurrent: 1},
+		},
+	})
+	p := NewProxy(router)
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		first <- w
+	}()
+	require.Eventually(t, func() bool {
+		value, ok := p.concurrencyLimiters.Load("limited-service")
+		if !ok {
+			return false
+		}
+		l := value.(*fairLimiter)
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.active == 1
+	}, time.Second, time.Millisecond)
+
+	// Without a queue the second request is shed while the first is in flight
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	close(release)
+	assert.Equal(t, http.StatusOK, (<-first).Code)
+}
@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"nexus/internal/config"
+	"nexus/internal/service"
+	"nexus/internal/stats"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fallbackTransport sends the requests the service of a route failed to its
+// fallback service, when the service couldn't be reached or answered with one
+// of the fallback statuses. Only idempotent requests are sent again, requests
+// finding no available server are sent to the fallback by handleRequest
+type fallbackTransport struct {
+	next     http.RoundTripper
+	proxy    *Proxy
+	upstream http.RoundTripper
+	route    *config.RouteConfig
+
+	mu        sync.Mutex
+	service   service.Service   // Fallback service transport was built for
+	transport http.RoundTripper // Route features for the fallback service
+}
+
+func newFallbackTransport(next http.RoundTripper, p *Proxy, upstream http.RoundTripper, route *config.RouteConfig) *fallbackTransport {
+	return &fallbackTransport{
+		next:     next,
+		proxy:    p,
+		upstream: upstream,
+		route:    route,
+	}
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *fallbackTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	reason := t.failure(req, resp, err)
+	if reason == "" {
+		return resp, err
+	}
+	match, ok := req.Context().Value(matchContextKey{}).(*matchResult)
+	if !ok || match.url == nil {
+		return resp, err
+	}
+	fallback := t.proxy.fallbackService(match)
+	if fallback == nil || fallback == match.service {
+		return resp, err
+	}
+
+	// The requests sent to the service are over
+	match.attempts.release()
+	target, selectErr := nextServer(req, fallback)
+	if selectErr != nil {
+		return resp, err
+	}
+	targetURL, parseErr := t.proxy.targetURL(target)
+	if parseErr != nil {
+		return resp, err
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+	traceFallback(req.Context(), match.service, fallback, reason)
+
+	match.service = fallback
+	match.target = targetURL
+	match.attempts.selected(target)
+	if r := stats.FromContext(req.Context()); r != nil {
+		r.SetServer(target)
+	}
+	out := req.Clone(req.Context())
+	u := *match.url
+	out.URL = &u
+	rewriteRequestURL(out, targetURL)
+
+	return t.fallbackTransport(fallback).RoundTrip(out)
+}
+
+// failure returns why the service failed a request, empty when it didn't or
+// the request can't be sent again
+func (t *fallbackTransport) failure(req *http.Request, resp *http.Response, err error) string {
+	if !isReplayable(req) || req.Context().Err() != nil {
+		return ""
+	}
+	if err != nil {
+		return err.Error()
+	}
+	if containsStatus(t.route.FallbackStatusCodes, resp.StatusCode) {
+		return fmt.Sprintf("status code %d", resp.StatusCode)
+	}
+	return ""
+}
+
+// fallbackTransport returns the route features for the fallback service,
+// rebuilt when the service was replaced
+func (t *fallbackTransport) fallbackTransport(svc service.Service) http.RoundTripper {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.service != svc {
+		t.service = svc
+		t.transport = t.proxy.buildServiceTransport(t.upstream, t.route, svc)
+	}
+	return t.transport
+}
+
+// fallbackService returns the fallback service of the matched route, nil
+// when it has none
+func (p *Proxy) fallbackService(match *matchResult) service.Service {
+	if match.route == nil || match.route.FallbackService == "" {
+		return nil
+	}
+	svc, _ := p.router.Service(match.route.FallbackService)
+	return svc
+}
+
+// traceFallback records on the request span that a request was sent to the
+// fallback service
+func traceFallback(ctx context.Context, from, to service.Service, reason string) {
+	trace.SpanFromContext(ctx).AddEvent("Fallback service",
+		trace.WithAttributes(
+			attribute.String("service", from.Name()),
+			attribute.String("fallback_service", to.Name()),
+			attribute.String("reason", reason),
+		))
+}
@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"nexus/internal/balancer"
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFallbackTest returns a proxy sending /api to primary, falling back to
+// a server answering with the path it got below /static
+func newFallbackTest(t *testing.T, primary []config.ServerConfig, statuses []int) *Proxy {
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fallback " + r.URL.Path))
+	}))
+	t.Cleanup(fallback.Close)
+
+	routes := []*config.RouteConfig{{
+		Name:                "api",
+		Match:               config.RouteMatch{Path: "/api/*"},
+		Service:             "api-service",
+		FallbackService:     "static-service",
+		FallbackStatusCodes: statuses,
+	}}
+	services := map[string]*config.ServiceConfig{
+		"api-service":    {Name: "api-service", BalancerType: "round_robin", Servers: primary},
+		"static-service": {Name: "static-service", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: fallback.URL + "/static"}}},
+	}
+	return NewProxy(route.NewRouter(routes, services))
+}
+
+func TestFallback_NoServers(t *testing.T) {
+	p := newFallbackTest(t, nil, nil)
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader("{}")))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "fallback /static/api/orders", w.Body.String())
+}
+
+func TestFallback_Unreachable(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	p := newFallbackTest(t, []config.ServerConfig{{Address: down.URL}}, nil)
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders?page=2", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "fallback /static/api/orders", w.Body.String())
+}
+
+func TestFallback_StatusCodes(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	p := newFallbackTest(t, []config.ServerConfig{{Address: primary.URL}}, []int{http.StatusServiceUnavailable})
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "fallback /static/api/orders", w.Body.String())
+
+	// Other statuses are passed on
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/broken", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	// Requests with side effects aren't sent twice
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader("{}")))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestFallback_ReleasesFallbackServers(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fallback"))
+	}))
+	defer fallback.Close()
+
+	routes := []*config.RouteConfig{{
+		Name:                "api",
+		Match:               config.RouteMatch{Path: "/api/*"},
+		Service:             "api-service",
+		FallbackService:     "static-service",
+		FallbackStatusCodes: []int{http.StatusServiceUnavailable},
+	}}
+	services := map[string]*config.ServiceConfig{
+		"api-service":    {Name: "api-service", BalancerType: "least_connections", Servers: []config.ServerConfig{{Address: primary.URL}}},
+		"static-service": {Name: "static-service", BalancerType: "least_connections", Servers: []config.ServerConfig{{Address: fallback.URL}}},
+	}
+	router := route.NewRouter(routes, services)
+	p := NewProxy(router)
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	// Each balancer gets back the servers it selected
+	for _, name := range []string{"api-service", "static-service"} {
+		svc, ok := router.Service(name)
+		require.True(t, ok)
+		for _, server := range svc.Balancer().(*balancer.LeastConnectionsBalancer).GetServers() {
+			assert.Equal(t, 0, server.ConnCount, "%s %s", name, server.Server)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/fault"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SetFaultInjector sets the injector holding the faults set at runtime,
+// without one only configured faults are injected
+func (p *Proxy) SetFaultInjector(injector *fault.Injector) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.faults = injector
+}
+
+// faultMiddleware delays, aborts or corrupts the requests of routes with a
+// fault, in that order
+func (p *Proxy) faultMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match := p.match(r)
+		if match.route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		p.mu.RLock()
+		injector := p.faults
+		p.mu.RUnlock()
+		f := injector.Fault(match.route)
+		if f == nil || (f.Header != "" && r.Header.Get(f.Header) == "") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		span := trace.SpanFromContext(r.Context())
+		if f.Delay != nil && fault.Hit(f.Delay.Percentage) {
+			delay := fault.Delay(f.Delay)
+			span.AddEvent("fault.delay", trace.WithAttributes(attribute.String("duration", delay.String())))
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+		if f.Abort != nil && fault.Hit(f.Abort.Percentage) {
+			span.AddEvent("fault.abort", trace.WithAttributes(attribute.Int("status", f.Abort.Status)))
+			w.WriteHeader(f.Abort.Status)
+			if f.Abort.Body != "" {
+				w.Write([]byte(f.Abort.Body))
+			}
+			return
+		}
+		if f.Corrupt != nil && fault.Hit(f.Corrupt.Percentage) {
+			span.AddEvent("fault.corrupt", trace.WithAttributes(attribute.String("mode", f.Corrupt.Mode)))
+			w = &corruptWriter{ResponseWriter: w, truncate: f.Corrupt.Mode == config.FaultCorruptTruncate}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corruptWriter flips a byte of every body write, or sends half of the first
+// one and then aborts the response
+type corruptWriter struct {
+	http.ResponseWriter
+	truncate bool
+}
+
+func (c *corruptWriter) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return c.ResponseWriter.Write(b)
+	}
+	if c.truncate {
+		c.ResponseWriter.Write(b[:len(b)/2])
+		http.NewResponseController(c.ResponseWriter).Flush()
+		panic(http.ErrAbortHandler)
+	}
+
+	garbled := append([]byte(nil), b...)
+	garbled[rand.IntN(len(garbled))] ^= 0xff
+	return c.ResponseWriter.Write(garbled)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (c *corruptWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/fault"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_Fault(t *testing.T) {
+	body := "0123456789abcdef"
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	defer backend.Close()
+
+	routes := []*config.RouteConfig{
+		{Name: "slow", Match: config.RouteMatch{Path: "/slow"}, Service: "api", Fault: &config.FaultConfig{
+			Delay: &config.FaultDelayConfig{Duration: 50 * time.Millisecond},
+		}},
+		{Name: "abort", Match: config.RouteMatch{Path: "/abort"}, Service: "api", Fault: &config.FaultConfig{
+			Header: "X-Chaos",
+			Abort:  &config.FaultAbortConfig{Status: http.StatusServiceUnavailable, Body: "injected"},
+		}},
+		{Name: "garble", Match: config.RouteMatch{Path: "/garble"}, Service: "api", Fault: &config.FaultConfig{
+			Corrupt: &config.FaultCorruptConfig{},
+		}},
+		{Name: "truncate", Match: config.RouteMatch{Path: "/truncate"}, Service: "api", Fault: &config.FaultConfig{
+			Corrupt: &config.FaultCorruptConfig{Mode: config.FaultCorruptTruncate},
+		}},
+		{Name: "plain", Match: config.RouteMatch{Path: "/plain"}, Service: "api"},
+	}
+	services := map[string]*config.ServiceConfig{
+		"api": {Name: "api", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: backend.URL}}},
+	}
+	p := NewProxy(route.NewRouter(routes, services))
+	injector := fault.NewInjector()
+	p.SetFaultInjector(injector)
+	front := httptest.NewServer(p)
+	defer front.Close()
+
+	get := func(path string, header http.Header) (*http.Response, string, error) {
+		req, err := http.NewRequest(http.MethodGet, front.URL+path, nil)
+		require.NoError(t, err)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		return resp, string(data), err
+	}
+
+	start := time.Now()
+	resp, data, err := get("/slow", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, body, data)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	// Only requests with the fault header are aborted
+	resp, data, err = get("/abort", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, data, err = get("/abort", http.Header{"X-Chaos": {"1"}})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "injected", data)
+
+	resp, data, err = get("/garble", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, data, len(body))
+	assert.NotEqual(t, body, data)
+
+	_, data, err = get("/truncate", nil)
+	assert.Error(t, err)
+	assert.Less(t, len(data), len(body))
+
+	// Runtime faults replace the configured ones until they are cleared
+	injector.Set("plain", &config.FaultConfig{Abort: &config.FaultAbortConfig{Status: http.StatusTeapot}})
+	injector.Set("slow", &config.FaultConfig{Abort: &config.FaultAbortConfig{Status: http.StatusBadGateway}})
+	resp, _, err = get("/plain", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusTeapot, resp.StatusCode)
+	resp, _, err = get("/slow", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+
+	assert.True(t, injector.Clear("plain"))
+	resp, data, err = get("/plain", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, body, data)
+}
@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+
+	"nexus/internal/config"
+	"nexus/internal/service"
+)
+
+// routeChain is a cached upstream transport chain of one route
+type routeChain struct {
+	route      *config.RouteConfig
+	service    service.Service
+	generation uint64 // transport generation the chain was built on
+	transport  http.RoundTripper
+
+	reverseProxy *httputil.ReverseProxy
+}
+
+// newReverseProxy creates the reverse proxy shared by all requests. The target
+// and transport of each request are read from its match result, so no
+// per-request proxy has to be allocated
+func newReverseProxy() *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			match := req.Context().Value(matchContextKey{}).(*matchResult)
+			rewriteRequestURL(req, match.target)
+		},
+		Transport:  forwardTransport{},
+		BufferPool: newBufferPool(copyBufferSize),
+	}
+}
+
+// copyBufferSize matches the buffer httputil.ReverseProxy allocates by default
+const copyBufferSize = 32 * 1024
+
+// bufferPool recycles the buffers used to copy response bodies
+type bufferPool struct {
+	pool sync.Pool
+}
+
+func newBufferPool(size int) *bufferPool {
+	return &bufferPool{pool: sync.Pool{New: func() any {
+		buf := make([]byte, size)
+		return &buf
+	}}}
+}
+
+// Get implements the httputil.BufferPool interface
+func (b *bufferPool) Get() []byte {
+	return *b.pool.Get().(*[]byte)
+}
+
+// Put implements the httputil.BufferPool interface
+func (b *bufferPool) Put(buf []byte) {
+	b.pool.Put(&buf)
+}
+
+// forwardTransport sends a request through the transport chosen in handleRequest
+type forwardTransport struct{}
+
+// RoundTrip implements the http.RoundTripper interface
+func (forwardTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	match := req.Context().Value(matchContextKey{}).(*matchResult)
+	resp, err := match.transport.RoundTrip(req)
+	if err == nil && len(resp.Trailer) > 0 {
+		// HTTP/2 backends send a length along with trailers, the response
+		// must be chunked for HTTP/1.1 clients to get them
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+	}
+	return resp, err
+}
+
+// targetURL returns the parsed address of a backend server
+func (p *Proxy) targetURL(address string) (*url.URL, error) {
+	if target, ok := p.targets.Load(address); ok {
+		return target.(*url.URL), nil
+	}
+
+	target, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	p.targets.Store(address, target)
+
+	return target, nil
+}
+
+// rewriteRequestURL points the request at the target the same way
+// httputil.NewSingleHostReverseProxy does
+func rewriteRequestURL(req *http.Request, target *url.URL) {
+	targetQuery := target.RawQuery
+	req.URL.Scheme = target.Scheme
+	req.URL.Host = target.Host
+	req.URL.Path, req.URL.RawPath = joinURLPath(target, req.URL)
+	if targetQuery == "" || req.URL.RawQuery == "" {
+		req.URL.RawQuery = targetQuery + req.URL.RawQuery
+	} else {
+		req.URL.RawQuery = targetQuery + "&" + req.URL.RawQuery
+	}
+}
+
+func joinURLPath(a, b *url.URL) (path, rawpath string) {
+	if a.RawPath == "" && b.RawPath == "" {
+		return singleJoiningSlash(a.Path, b.Path), ""
+	}
+
+	apath := a.EscapedPath()
+	bpath := b.EscapedPath()
+	aslash := strings.HasSuffix(apath, "/")
+	bslash := strings.HasPrefix(bpath, "/")
+
+	switch {
+	case aslash && bslash:
+		return a.Path + b.Path[1:], apath + bpath[1:]
+	case !aslash && !bslash:
+		return a.Path + "/" + b.Path, apath + "/" + bpath
+	}
+	return a.Path + b.Path, apath + bpath
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
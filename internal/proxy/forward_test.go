@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// maxForwardAllocs is the allocation budget of one proxied request with a stub
+// transport. Most of it is spent by httputil.ReverseProxy cloning the request
+// and by otelhttp instrumenting it
+const maxForwardAllocs = 70
+
+// stubTransport answers every request without touching the network
+type stubTransport struct {
+	body string
+	last *http.Request
+}
+
+func (t *stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.last = req
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(t.body)),
+		Request:    req,
+	}, nil
+}
+
+func newForwardTest(routes []*config.RouteConfig) (*Proxy, *stubTransport) {
+	router := route.NewRouter(routes, map[string]*config.ServiceConfig{
+		"forward-service": {
+			Name:         "forward-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: "http://backend.internal/base?v=1"}},
+		},
+	})
+	transport := &stubTransport{body: testResponseBody}
+	p := NewProxy(router)
+	p.SetTransport(transport)
+
+	return p, transport
+}
+
+func TestProxy_ForwardRewritesURL(t *testing.T) {
+	p, transport := newForwardTest([]*config.RouteConfig{
+		{Name: "forward", Match: config.RouteMatch{Path: "/items/*"}, Service: "forward-service"},
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "http://proxy.example.com/items/1?q=a", nil)
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, testResponseBody, w.Body.String())
+	require.NotNil(t, transport.last)
+	assert.Equal(t, "http://backend.internal/base/items/1?v=1&q=a", transport.last.URL.String())
+	assert.Equal(t, "proxy.example.com", transport.last.Host)
+}
+
+func TestProxy_ForwardTransportCache(t *testing.T) {
+	p, transport := newForwardTest([]*config.RouteConfig{
+		{Name: "forward", Match: config.RouteMatch{Path: "/items"}, Service: "forward-service"},
+	})
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items", nil))
+	require.NotNil(t, transport.last)
+
+	// A new transport replaces the cached chains
+	replacement := &stubTransport{body: "replaced"}
+	p.SetTransport(replacement)
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+	assert.Equal(t, "replaced", w.Body.String())
+
+	// So does a changed route definition
+	first := p.routeTransport(p.match(httptest.NewRequest(http.MethodGet, "/items", nil)))
+	assert.Same(t, first, p.routeTransport(p.match(httptest.NewRequest(http.MethodGet, "/items", nil))))
+	require.NoError(t, p.router.Update([]*config.RouteConfig{
+		{Name: "forward", Match: config.RouteMatch{Path: "/items"}, Service: "forward-service", Expect: &config.ExpectConfig{StatusCodes: []int{200}}},
+	}, map[string]*config.ServiceConfig{
+		"forward-service": {
+			Name:         "forward-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: "http://backend.internal"}},
+		},
+	}))
+	rt := p.routeTransport(p.match(httptest.NewRequest(http.MethodGet, "/items", nil)))
+	_, ok := rt.(*expectTransport)
+	assert.True(t, ok)
+}
+
+func TestProxy_ForwardAllocs(t *testing.T) {
+	p, _ := newForwardTest([]*config.RouteConfig{
+		{Name: "forward", Match: config.RouteMatch{Path: "/items"}, Service: "forward-service"},
+	})
+	r := httptest.NewRequest(http.MethodGet, "/items", nil)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		p.ServeHTTP(httptest.NewRecorder(), r)
+	})
+	assert.LessOrEqual(t, allocs, float64(maxForwardAllocs))
+}
+
+func BenchmarkProxy_Forward(b *testing.B) {
+	p, _ := newForwardTest([]*config.RouteConfig{
+		{Name: "forward", Match: config.RouteMatch{Path: "/items"}, Service: "forward-service"},
+	})
+	r := httptest.NewRequest(http.MethodGet, "/items", nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	b.StopTimer()
+
+	if allocs := testing.AllocsPerRun(100, func() { p.ServeHTTP(httptest.NewRecorder(), r) }); allocs > maxForwardAllocs {
+		b.Fatalf("forwarding allocates %.0f times per request, budget is %d", allocs, maxForwardAllocs)
+	}
+}
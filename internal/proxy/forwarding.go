@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"nexus/internal/config"
+)
+
+// forwardingTransport applies the forwarding settings of a route to the
+// exchanges with its backends
+type forwardingTransport struct {
+	next http.RoundTripper
+	cfg  *config.ForwardingConfig
+}
+
+func newForwardingTransport(next http.RoundTripper, cfg *config.ForwardingConfig) *forwardingTransport {
+	return &forwardingTransport{next: next, cfg: cfg}
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *forwardingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.cfg.ExpectContinue == config.ExpectContinueProxy && expectsContinue(req) {
+		// The client is answered 100 Continue once the body is read
+		req = req.Clone(req.Context())
+		req.Header.Del("Expect")
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || !t.cfg.DropTrailers {
+		return resp, err
+	}
+	resp.Trailer = nil
+	if resp.Body != nil && resp.Body != http.NoBody {
+		resp.Body = &trailerlessBody{ReadCloser: resp.Body, resp: resp}
+	}
+	return resp, nil
+}
+
+// expectsContinue reports whether the client waits for 100 Continue before
+// sending the body of req
+func expectsContinue(req *http.Request) bool {
+	return req.Body != nil && req.Body != http.NoBody && strings.EqualFold(req.Header.Get("Expect"), "100-continue")
+}
+
+// trailerlessBody drops the trailers the transport sets when the body of
+// resp is read to the end, including the ones the backend didn't declare
+type trailerlessBody struct {
+	io.ReadCloser
+	resp *http.Response
+}
+
+func (b *trailerlessBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.resp.Trailer = nil
+	}
+	return n, err
+}
+
+// earlyHintsWriter drops the 103 Early Hints relayed by the reverse proxy
+type earlyHintsWriter struct {
+	http.ResponseWriter
+}
+
+func (w *earlyHintsWriter) WriteHeader(code int) {
+	if code == http.StatusEarlyHints {
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *earlyHintsWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// forwardingWriter returns the writer of the responses of a route
+func forwardingWriter(w http.ResponseWriter, route *config.RouteConfig) http.ResponseWriter {
+	if route != nil && route.Forwarding != nil && route.Forwarding.DropEarlyHints {
+		return &earlyHintsWriter{ResponseWriter: w}
+	}
+	return w
+}
@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// forwardingBackend sends early hints and trailers on GET, refuses posts to
+// /reject without reading them and echoes the others
+type forwardingBackend struct {
+	mu     sync.Mutex
+	expect []string // Expect headers received
+}
+
+func (b *forwardingBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		b.mu.Lock()
+		b.expect = append(b.expect, r.Header.Get("Expect"))
+		b.mu.Unlock()
+		if r.URL.Path == "/reject" {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		io.Copy(w, r.Body)
+		return
+	}
+
+	w.Header().Set("Link", "</app.css>; rel=preload; as=style")
+	w.WriteHeader(http.StatusEarlyHints)
+	w.Header().Set("Trailer", "Grpc-Status")
+	w.Write([]byte("hello"))
+	w.Header().Set("Grpc-Status", "0")
+}
+
+func (b *forwardingBackend) expectHeaders() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.expect...)
+}
+
+// newForwardingTest returns the URL of a proxy server forwarding to a
+// forwardingBackend, over HTTP/2 when h2 is set
+func newForwardingTest(t *testing.T, cfg *config.ForwardingConfig, h2 bool) (string, *forwardingBackend) {
+	backend := &forwardingBackend{}
+	upstream := httptest.NewServer(h2c.NewHandler(backend, &http2.Server{}))
+	t.Cleanup(upstream.Close)
+
+	routes := []*config.RouteConfig{{Name: "api", Match: config.RouteMatch{Path: "/*"}, Service: "api-service", Forwarding: cfg}}
+	services := map[string]*config.ServiceConfig{
+		"api-service": {Name: "api-service", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: upstream.URL}}},
+	}
+	p := NewProxy(route.NewRouter(routes, services))
+	if h2 {
+		p.SetUpstreamConfig(config.UpstreamConfig{HTTP2: &config.UpstreamHTTP2Config{}})
+	}
+	front := httptest.NewServer(p)
+	t.Cleanup(front.Close)
+	return front.URL, backend
+}
+
+// forwardedResponse is what a client received for a request
+type forwardedResponse struct {
+	interim   []int    // Informational statuses, 100 Continue included
+	links     []string // Link headers of the early hints
+	status    int
+	body      string
+	trailer   http.Header
+	continued bool // The body was sent after 100 Continue
+}
+
+func forwardRequest(t *testing.T, method, url, body string) *forwardedResponse {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	require.NoError(t, err)
+	if body != "" {
+		req.Header.Set("Expect", "100-continue")
+	}
+	got := &forwardedResponse{}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			got.interim = append(got.interim, code)
+			got.links = append(got.links, header.Values("Link")...)
+			return nil
+		},
+		Got100Continue: func() { got.continued = true },
+	}))
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	got.status, got.body, got.trailer = resp.StatusCode, string(data), resp.Trailer
+	return got
+}
+
+func TestForwarding_EarlyHintsAndTrailers(t *testing.T) {
+	for name, h2 := range map[string]bool{"http1": false, "http2": true} {
+		t.Run(name, func(t *testing.T) {
+			url, _ := newForwardingTest(t, nil, h2)
+
+			got := forwardRequest(t, http.MethodGet, url+"/page", "")
+			assert.Equal(t, http.StatusOK, got.status)
+			assert.Equal(t, []int{http.StatusEarlyHints}, got.interim)
+			assert.Equal(t, []string{"</app.css>; rel=preload; as=style"}, got.links)
+			assert.Equal(t, "hello", got.body)
+			assert.Equal(t, "0", got.trailer.Get("Grpc-Status"))
+		})
+	}
+}
+
+func TestForwarding_ExpectContinue(t *testing.T) {
+	for name, h2 := range map[string]bool{"http1": false, "http2": true} {
+		t.Run(name, func(t *testing.T) {
+			url, backend := newForwardingTest(t, nil, h2)
+
+			// The backend refuses the body before the client sends it
+			got := forwardRequest(t, http.MethodPost, url+"/reject", "payload")
+			assert.Equal(t, http.StatusRequestEntityTooLarge, got.status)
+			assert.False(t, got.continued)
+
+			got = forwardRequest(t, http.MethodPost, url+"/echo", "payload")
+			assert.Equal(t, http.StatusOK, got.status)
+			assert.True(t, got.continued)
+			assert.Equal(t, "payload", got.body)
+			if !h2 {
+				// HTTP/2 servers answer the expectation instead of passing it on
+				assert.Equal(t, []string{"100-continue", "100-continue"}, backend.expectHeaders())
+			}
+		})
+	}
+}
+
+func TestForwarding_ExpectContinueProxy(t *testing.T) {
+	url, backend := newForwardingTest(t, &config.ForwardingConfig{ExpectContinue: config.ExpectContinueProxy}, false)
+
+	got := forwardRequest(t, http.MethodPost, url+"/echo", "payload")
+	assert.Equal(t, http.StatusOK, got.status)
+	assert.True(t, got.continued)
+	assert.Equal(t, "payload", got.body)
+	assert.Equal(t, []string{""}, backend.expectHeaders())
+}
+
+func TestForwarding_Drop(t *testing.T) {
+	for name, h2 := range map[string]bool{"http1": false, "http2": true} {
+		t.Run(name, func(t *testing.T) {
+			url, _ := newForwardingTest(t, &config.ForwardingConfig{DropEarlyHints: true, DropTrailers: true}, h2)
+
+			got := forwardRequest(t, http.MethodGet, url+"/page", "")
+			assert.Equal(t, http.StatusOK, got.status)
+			assert.Empty(t, got.interim)
+			assert.Equal(t, "hello", got.body)
+			assert.Empty(t, got.trailer)
+		})
+	}
+}
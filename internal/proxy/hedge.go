@@ -0,0 +1,189 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"nexus/internal/config"
+	"nexus/internal/service"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// hedgeBudget limits the share of requests that may be hedged on a route
+type hedgeBudget struct {
+	requests atomic.Int64
+	hedges   atomic.Int64
+}
+
+// allow reports whether one more hedged request fits in the budget percentage
+func (b *hedgeBudget) allow(percent float64) bool {
+	if percent <= 0 {
+		return true
+	}
+
+	return float64(b.hedges.Load()+1) <= float64(b.requests.Load())*percent/100
+}
+
+// hedgingTransport issues extra requests to other backends when the first one is slow
+type hedgingTransport struct {
+	next    http.RoundTripper
+	service service.Service
+	cfg     *config.HedgeConfig
+	budget  *hedgeBudget
+}
+
+type hedgeResult struct {
+	index int
+	resp  *http.Response
+	err   error
+}
+
+func newHedgingTransport(next http.RoundTripper, svc service.Service, cfg *config.HedgeConfig, budget *hedgeBudget) *hedgingTransport {
+	return &hedgingTransport{
+		next:    next,
+		service: svc,
+		cfg:     cfg,
+		budget:  budget,
+	}
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *hedgingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isReplayable(req) {
+		return t.next.RoundTrip(req)
+	}
+	t.budget.requests.Add(1)
+
+	results := make(chan hedgeResult, t.cfg.MaxHedges+1)
+	cancels := make([]context.CancelFunc, 0, t.cfg.MaxHedges+1)
+	launch := func(r *http.Request) {
+		ctx, cancel := context.WithCancel(r.Context())
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := t.next.RoundTrip(r.WithContext(ctx))
+			results <- hedgeResult{index: index, resp: resp, err: err}
+		}()
+	}
+
+	launch(req)
+	attempts, pending := 1, 1
+
+	timer := time.NewTimer(t.cfg.Delay)
+	defer timer.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				// Cancel the losers and release their responses in the background
+				for i, cancel := range cancels {
+					if i != res.index {
+						cancel()
+					}
+				}
+				go drainHedgeResults(results, pending)
+				res.resp.Body = &cancelOnClose{ReadCloser: res.resp.Body, cancel: cancels[res.index]}
+				return res.resp, nil
+			}
+			lastErr = res.err
+			cancels[res.index]()
+			if pending == 0 {
+				// Every in-flight request failed, hedge immediately if allowed
+				if attempts > t.cfg.MaxHedges || !t.hedge(req, launch) {
+					return nil, lastErr
+				}
+				attempts++
+				pending++
+			}
+		case <-timer.C:
+			if attempts <= t.cfg.MaxHedges && t.hedge(req, launch) {
+				attempts++
+				pending++
+				timer.Reset(t.cfg.Delay)
+			}
+		case <-req.Context().Done():
+			for _, cancel := range cancels {
+				cancel()
+			}
+			go drainHedgeResults(results, pending)
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// hedge launches one more request against the next backend of the service
+func (t *hedgingTransport) hedge(req *http.Request, launch func(*http.Request)) bool {
+	if !t.budget.allow(t.cfg.Budget) {
+		return false
+	}
+
+	target, err := nextServer(req, t.service)
+	if err != nil {
+		return false
+	}
+	hedgeReq, err := retarget(req, target)
+	if err != nil {
+		return false
+	}
+	t.budget.hedges.Add(1)
+
+	trace.SpanFromContext(req.Context()).AddEvent("Hedged request",
+		trace.WithAttributes(attribute.String("backend.address", target)))
+
+	launch(hedgeReq)
+	return true
+}
+
+// retarget clones an outgoing request for another backend of the same service
+func retarget(req *http.Request, target string) (*http.Request, error) {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+
+	out := req.Clone(req.Context())
+	out.URL.Scheme = targetURL.Scheme
+	out.URL.Host = targetURL.Host
+	return out, nil
+}
+
+// isReplayable reports whether a request is idempotent and safe to send more than once
+func isReplayable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+	default:
+		return false
+	}
+
+	return req.Body == nil || req.Body == http.NoBody
+}
+
+// drainHedgeResults closes responses of requests that lost the race
+func drainHedgeResults(results <-chan hedgeResult, pending int) {
+	for i := 0; i < pending; i++ {
+		res := <-results
+		if res.resp != nil {
+			res.resp.Body.Close()
+		}
+	}
+}
+
+// cancelOnClose releases the request context once the response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+)
+
+func newHedgeTestProxy(hedge *config.HedgeConfig, servers ...string) *Proxy {
+	serverConfigs := make([]config.ServerConfig, 0, len(servers))
+	for _, s := range servers {
+		serverConfigs = append(serverConfigs, config.ServerConfig{Address: s})
+	}
+
+	router := route.NewRouter([]*config.RouteConfig{
+		{
+			Name:    "hedge-route",
+			Match:   config.RouteMatch{Path: "/"},
+			Service: "hedge-service",
+			Hedge:   hedge,
+		},
+	}, map[string]*config.ServiceConfig{
+		"hedge-service": {
+			Name:         "hedge-service",
+			BalancerType: "round_robin",
+			Servers:      serverConfigs,
+		},
+	})
+
+	return NewProxy(router)
+}
+
+func TestHedging_FastBackendWins(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+			return
+		}
+		w.Write([]byte("slow"))
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fast"))
+	}))
+	defer fast.Close()
+
+	p := newHedgeTestProxy(&config.HedgeConfig{Delay: 20 * time.Millisecond, MaxHedges: 1}, slow.URL, fast.URL)
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := w.Body.String(); got != "fast" {
+		t.Errorf("Expected hedged response from fast backend, got %q", got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Hedged request took too long: %v", elapsed)
+	}
+}
+
+func TestHedging_NonIdempotentNotHedged(t *testing.T) {
+	var calls int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("ok"))
+	})
+	backend1 := httptest.NewServer(handler)
+	defer backend1.Close()
+	backend2 := httptest.NewServer(handler)
+	defer backend2.Close()
+
+	p := newHedgeTestProxy(&config.HedgeConfig{Delay: 5 * time.Millisecond, MaxHedges: 2}, backend1.URL, backend2.URL)
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("payload")))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("Expected exactly 1 upstream call for POST, got %d", got)
+	}
+}
+
+func TestHedging_BudgetLimitsHedges(t *testing.T) {
+	var calls int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(30 * time.Millisecond)
+		w.Write([]byte("ok"))
+	})
+	backend1 := httptest.NewServer(handler)
+	defer backend1.Close()
+	backend2 := httptest.NewServer(handler)
+	defer backend2.Close()
+
+	// A 50% budget allows at most one hedge for every two requests
+	p := newHedgeTestProxy(&config.HedgeConfig{Delay: 5 * time.Millisecond, MaxHedges: 1, Budget: 50}, backend1.URL, backend2.URL)
+
+	for i := 0; i < 4; i++ {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+	}
+
+	budget := p.hedgeBudget("hedge-route")
+	if requests := budget.requests.Load(); requests != 4 {
+		t.Errorf("Expected 4 budgeted requests, got %d", requests)
+	}
+	if hedges := budget.hedges.Load(); hedges > 2 {
+		t.Errorf("Expected at most 2 hedges within budget, got %d", hedges)
+	}
+}
+
+func TestHedging_FailedAttemptHedgesImmediately(t *testing.T) {
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("good"))
+	}))
+	defer good.Close()
+
+	// The first backend refuses connections
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	deadURL := dead.URL
+	dead.Close()
+
+	p := newHedgeTestProxy(&config.HedgeConfig{Delay: time.Second, MaxHedges: 1}, deadURL, good.URL)
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := w.Body.String(); got != "good" {
+		t.Errorf("Expected response from healthy backend, got %q", got)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("Expected immediate hedge after failure, took %v", elapsed)
+	}
+}
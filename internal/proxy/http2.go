@@ -0,0 +1,351 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/stats"
+
+	"golang.org/x/net/http2"
+)
+
+// defaultHTTP2Streams is the number of requests in flight on an upstream
+// HTTP/2 connection before another one is opened
+const defaultHTTP2Streams = 100
+
+// http2Transport multiplexes upstream requests over HTTP/2 connections, h2c
+// with prior knowledge for http:// servers. Each server gets as many
+// connections as its requests in flight need, up to max_connections, after
+// which requests wait for a stream. Upgrade requests can't be carried by
+// HTTP/2 streams and use the HTTP/1 transport
+type http2Transport struct {
+	http1   *http.Transport
+	h2      *http2.Transport
+	pool    *http2ConnPool
+	streams int // Per connection
+	slots   int // Per server, 0 is unlimited
+
+	mu      sync.Mutex
+	servers map[string]chan struct{} // Host -> semaphore of the requests in flight
+}
+
+// newUpstreamTransport returns the transport of upstream requests, counting
+// its connections in pool and resolving hosts through dns
+func newUpstreamTransport(pool *stats.Pool, dns *dnsCache, cfg *config.UpstreamHTTP2Config) http.RoundTripper {
+	http1 := newPooledTransport(pool, dns)
+	if cfg == nil {
+		return http1
+	}
+
+	streams := cfg.MaxConcurrentStreams
+	if streams == 0 {
+		streams = defaultHTTP2Streams
+	}
+	h2 := &http2.Transport{
+		AllowHTTP:       true,
+		IdleConnTimeout: http1.IdleConnTimeout,
+		ReadIdleTimeout: cfg.ReadIdleTimeout,
+	}
+	connPool := &http2ConnPool{
+		transport:      h2,
+		dial:           http1.DialContext,
+		streams:        streams,
+		maxConnections: cfg.MaxConnections,
+		conns:          make(map[string][]*http2.ClientConn),
+		dials:          make(map[string]*http2Dial),
+		dialing:        make(map[string]int),
+	}
+	h2.ConnPool = connPool
+
+	return &http2Transport{
+		http1:   http1,
+		h2:      h2,
+		pool:    connPool,
+		streams: streams,
+		slots:   streams * cfg.MaxConnections,
+		servers: make(map[string]chan struct{}),
+	}
+}
+
+func (t *http2Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Upgrade") != "" {
+		return t.http1.RoundTrip(req)
+	}
+
+	if expectsContinue(req) && t.http1.ExpectContinueTimeout > 0 {
+		body := newContinueBody(req.Body, t.http1.ExpectContinueTimeout)
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{Got100Continue: body.send}))
+		req.Body = body
+	}
+
+	release, err := t.acquire(req.Context(), req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.h2.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &streamBody{ReadCloser: resp.Body, release: release}
+
+	return resp, nil
+}
+
+// acquire waits for a stream of a server, the returned function frees it
+func (t *http2Transport) acquire(ctx context.Context, host string) (func(), error) {
+	if t.slots == 0 {
+		return func() {}, nil
+	}
+
+	t.mu.Lock()
+	slots, ok := t.servers[host]
+	if !ok {
+		slots = make(chan struct{}, t.slots)
+		t.servers[host] = slots
+	}
+	t.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		var once sync.Once
+		return func() { once.Do(func() { <-slots }) }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// CloseIdleConnections closes the idle connections of both protocols
+func (t *http2Transport) CloseIdleConnections() {
+	t.http1.CloseIdleConnections()
+	t.pool.closeIdle()
+}
+
+// continueBody holds back the body of a request sent with Expect:
+// 100-continue until the backend answers 100 Continue or the timeout passes,
+// as http.Transport does. http2.Transport sends it right away, and reading it
+// has the server answer 100 Continue to the client before the backend could
+// refuse the request
+type continueBody struct {
+	io.ReadCloser
+	ready chan struct{}
+	once  sync.Once
+	timer *time.Timer
+}
+
+func newContinueBody(body io.ReadCloser, timeout time.Duration) *continueBody {
+	b := &continueBody{ReadCloser: body, ready: make(chan struct{})}
+	b.timer = time.AfterFunc(timeout, b.send)
+	return b
+}
+
+// send releases the body
+func (b *continueBody) send() {
+	b.once.Do(func() { close(b.ready) })
+}
+
+func (b *continueBody) Read(p []byte) (int, error) {
+	<-b.ready
+	return b.ReadCloser.Read(p)
+}
+
+func (b *continueBody) Close() error {
+	b.timer.Stop()
+	b.send()
+	return b.ReadCloser.Close()
+}
+
+// streamBody frees the stream of a response once its body is closed
+type streamBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *streamBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// http2ConnPool hands out the least busy connection to a server with a free
+// stream, and dials another one when they are all busy. Requests arriving
+// while a connection is dialed wait for it as long as it has free streams
+type http2ConnPool struct {
+	transport      *http2.Transport
+	dial           func(ctx context.Context, network, addr string) (net.Conn, error)
+	streams        int
+	maxConnections int
+
+	mu      sync.Mutex
+	conns   map[string][]*http2.ClientConn
+	dials   map[string]*http2Dial // Latest dial in progress per server
+	dialing map[string]int        // Dials in progress per server
+}
+
+// http2Dial is a connection being dialed, with the requests waiting for it
+type http2Dial struct {
+	done    chan struct{}
+	err     error
+	waiters int
+}
+
+func (p *http2ConnPool) GetClientConn(req *http.Request, addr string) (*http2.ClientConn, error) {
+	for {
+		p.mu.Lock()
+		if cc := p.reserveLocked(addr, false); cc != nil {
+			p.mu.Unlock()
+			return cc, nil
+		}
+		full := p.maxConnections > 0 && len(p.conns[addr])+p.dialing[addr] >= p.maxConnections
+		if dial := p.dials[addr]; dial != nil && (dial.waiters < p.streams || full) {
+			dial.waiters++
+			p.mu.Unlock()
+			select {
+			case <-dial.done:
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+			if dial.err != nil {
+				return nil, dial.err
+			}
+			continue
+		}
+		if full {
+			// Busy connections still queue the request on their streams
+			cc := p.reserveLocked(addr, true)
+			p.mu.Unlock()
+			if cc == nil {
+				return nil, fmt.Errorf("http2: no connection to %s can take requests", addr)
+			}
+			return cc, nil
+		}
+		dial := &http2Dial{done: make(chan struct{}), waiters: 1}
+		p.dials[addr] = dial
+		p.dialing[addr]++
+		p.mu.Unlock()
+
+		cc, err := p.newClientConn(req, addr)
+		p.mu.Lock()
+		p.dialing[addr]--
+		if p.dials[addr] == dial {
+			delete(p.dials, addr)
+		}
+		if err == nil {
+			p.conns[addr] = append(p.conns[addr], cc)
+		}
+		p.mu.Unlock()
+		dial.err = err
+		close(dial.done)
+
+		if err != nil {
+			return nil, err
+		}
+		if cc.ReserveNewRequest() {
+			return cc, nil
+		}
+	}
+}
+
+// reserveLocked reserves a stream on the least busy connection to addr,
+// only connections with a free stream qualify unless busy is set. The
+// caller must hold p.mu
+func (p *http2ConnPool) reserveLocked(addr string, busy bool) *http2.ClientConn {
+	type candidate struct {
+		cc      *http2.ClientConn
+		streams int
+	}
+	var live []*http2.ClientConn
+	var candidates []candidate
+	for _, cc := range p.conns[addr] {
+		state := cc.State()
+		if state.Closed || state.Closing {
+			continue
+		}
+		live = append(live, cc)
+		candidates = append(candidates, candidate{cc, state.StreamsActive + state.StreamsReserved + state.StreamsPending})
+	}
+	p.conns[addr] = live
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].streams < candidates[j].streams })
+	for _, c := range candidates {
+		if (c.streams < p.streams || busy) && c.cc.ReserveNewRequest() {
+			return c.cc
+		}
+	}
+	return nil
+}
+
+// newClientConn dials addr and starts an HTTP/2 connection on it
+func (p *http2ConnPool) newClientConn(req *http.Request, addr string) (*http2.ClientConn, error) {
+	conn, err := p.dialConn(req, addr)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := p.transport.NewClientConn(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return cc, nil
+}
+
+// dialConn connects to addr, negotiating h2 with ALPN for https:// servers
+func (p *http2ConnPool) dialConn(req *http.Request, addr string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), 30*time.Second)
+	defer cancel()
+
+	conn, err := p.dial(ctx, "tcp", addr)
+	if err != nil || req.URL.Scheme != "https" {
+		return conn, err
+	}
+
+	host, _, _ := net.SplitHostPort(addr)
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: host, NextProtos: []string{http2.NextProtoTLS}})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if protocol := tlsConn.ConnectionState().NegotiatedProtocol; protocol != http2.NextProtoTLS {
+		tlsConn.Close()
+		return nil, fmt.Errorf("http2: server %s negotiated %q instead of h2", addr, protocol)
+	}
+
+	return tlsConn, nil
+}
+
+func (p *http2ConnPool) MarkDead(cc *http2.ClientConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for addr, conns := range p.conns {
+		for i, c := range conns {
+			if c == cc {
+				p.conns[addr] = append(conns[:i:i], conns[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// closeIdle closes the connections without streams
+func (p *http2ConnPool) closeIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, conns := range p.conns {
+		for _, cc := range conns {
+			if state := cc.State(); state.StreamsActive+state.StreamsReserved+state.StreamsPending == 0 {
+				cc.Close()
+			}
+		}
+	}
+}
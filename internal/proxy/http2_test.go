@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestProxy_UpstreamHTTP2(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	var mu sync.Mutex
+	conns := make(map[string]bool)
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		conns[r.RemoteAddr] = true
+		mu.Unlock()
+		if n := inFlight.Add(1); n > peak.Load() {
+			peak.Store(n)
+		}
+		defer inFlight.Add(-1)
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+		w.Write([]byte(r.Proto))
+	})
+	backend := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer backend.Close()
+
+	routes := []*config.RouteConfig{{Name: "api", Match: config.RouteMatch{Path: "/*"}, Service: "api-service"}}
+	services := map[string]*config.ServiceConfig{
+		"api-service": {Name: "api-service", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: backend.URL}}},
+	}
+	p := NewProxy(route.NewRouter(routes, services))
+	p.SetUpstreamConfig(config.UpstreamConfig{HTTP2: &config.UpstreamHTTP2Config{MaxConcurrentStreams: 2, MaxConnections: 1}})
+	defer p.transport.(*http2Transport).CloseIdleConnections()
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "HTTP/2.0", w.Body.String())
+
+	// Three requests share the only connection, the third waits for a stream
+	var wg sync.WaitGroup
+	codes := make([]int, 3)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+			codes[i] = w.Code
+		}(i)
+	}
+	require.Eventually(t, func() bool { return inFlight.Load() == 2 }, time.Second, 5*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(2), inFlight.Load())
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusOK}, codes)
+	assert.Equal(t, int32(2), peak.Load())
+	assert.Len(t, conns, 1)
+	assert.Equal(t, int64(1), p.Pool().Snapshot()[backend.URL].Open)
+}
+
+func TestProxy_UpstreamHTTP2ConnectionsPerStreams(t *testing.T) {
+	var mu sync.Mutex
+	conns := make(map[string]bool)
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	started.Add(4)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		conns[r.RemoteAddr] = true
+		mu.Unlock()
+		started.Done()
+		<-release
+	})
+	backend := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer backend.Close()
+
+	routes := []*config.RouteConfig{{Name: "api", Match: config.RouteMatch{Path: "/*"}, Service: "api-service"}}
+	services := map[string]*config.ServiceConfig{
+		"api-service": {Name: "api-service", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: backend.URL}}},
+	}
+	p := NewProxy(route.NewRouter(routes, services))
+	p.SetUpstreamConfig(config.UpstreamConfig{HTTP2: &config.UpstreamHTTP2Config{MaxConcurrentStreams: 2}})
+	defer p.transport.(*http2Transport).CloseIdleConnections()
+
+	// Another connection is opened once the streams of the first are busy
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/wait", nil))
+		}()
+	}
+	started.Wait()
+	close(release)
+	wg.Wait()
+
+	assert.Len(t, conns, 2)
+}
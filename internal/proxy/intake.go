@@ -0,0 +1,199 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"nexus/internal/config"
+)
+
+// ErrServiceNotFound is matched by the errors of pausing or resuming an
+// unknown service, it is config.ErrServiceNotFound
+var ErrServiceNotFound = config.ErrServiceNotFound
+
+var errIntakePaused = errors.New("service intake is paused")
+
+// DefaultHoldTimeout bounds how long requests wait for a paused service to
+// resume when the pause sets no timeout
+const DefaultHoldTimeout = 30 * time.Second
+
+// ServiceQueue reports the requests of a service being served or waiting
+type ServiceQueue struct {
+	Service       string     `json:"service"`
+	InFlight      int        `json:"in_flight"`                // Requests forwarded and not complete yet
+	Queued        int        `json:"queued"`                   // Requests waiting for a concurrency slot
+	Held          int        `json:"held"`                     // Requests waiting for the intake to resume
+	MaxConcurrent int        `json:"max_concurrent,omitempty"` // Concurrency limit of the service
+	MaxQueue      int        `json:"max_queue,omitempty"`
+	Paused        bool       `json:"paused"`
+	PausedSince   *time.Time `json:"paused_since,omitempty"`
+	HoldTimeout   string     `json:"hold_timeout,omitempty"`
+}
+
+// serviceIntake admits the requests of a service, a paused intake holds new
+// requests until it resumes while admitted ones complete
+type serviceIntake struct {
+	mu      sync.Mutex
+	active  int // Admitted requests, including those waiting for a concurrency slot
+	held    int
+	paused  bool
+	since   time.Time
+	hold    time.Duration
+	resumed chan struct{} // Closed when a paused intake resumes
+}
+
+// enter admits a request, waiting while the intake is paused
+func (i *serviceIntake) enter(ctx context.Context) error {
+	i.mu.Lock()
+	for i.paused {
+		resumed, hold := i.resumed, i.hold
+		i.held++
+		i.mu.Unlock()
+
+		timer := time.NewTimer(hold)
+		var err error
+		select {
+		case <-resumed:
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-timer.C:
+			err = errIntakePaused
+		}
+		timer.Stop()
+
+		i.mu.Lock()
+		i.held--
+		if err != nil {
+			i.mu.Unlock()
+			return err
+		}
+	}
+	i.active++
+	i.mu.Unlock()
+
+	return nil
+}
+
+func (i *serviceIntake) leave() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.active--
+}
+
+// serviceIntake returns the intake of a service
+func (p *Proxy) serviceIntake(service string) *serviceIntake {
+	value, _ := p.intakes.LoadOrStore(service, &serviceIntake{})
+	return value.(*serviceIntake)
+}
+
+// PauseIntake holds the new requests of a service until ResumeIntake, for at
+// most hold each. Requests already forwarded complete, so once InFlight
+// drops to zero the backends can be redeployed. Pauses outlive config reloads
+func (p *Proxy) PauseIntake(service string, hold time.Duration) error {
+	if _, ok := p.router.Service(service); !ok {
+		return &config.ServiceNotFoundError{Service: service}
+	}
+	if hold <= 0 {
+		hold = DefaultHoldTimeout
+	}
+
+	intake := p.serviceIntake(service)
+	intake.mu.Lock()
+	defer intake.mu.Unlock()
+
+	intake.hold = hold
+	if !intake.paused {
+		intake.paused = true
+		intake.since = time.Now()
+		intake.resumed = make(chan struct{})
+	}
+	return nil
+}
+
+// ResumeIntake admits the held and new requests of a paused service again
+func (p *Proxy) ResumeIntake(service string) error {
+	value, ok := p.intakes.Load(service)
+	if !ok {
+		if _, ok := p.router.Service(service); !ok {
+			return &config.ServiceNotFoundError{Service: service}
+		}
+		return nil
+	}
+
+	intake := value.(*serviceIntake)
+	intake.mu.Lock()
+	defer intake.mu.Unlock()
+
+	if intake.paused {
+		intake.paused = false
+		close(intake.resumed)
+	}
+	return nil
+}
+
+// Queues reports the queue depth, in-flight requests and intake state of the
+// services by name
+func (p *Proxy) Queues() []ServiceQueue {
+	queues := make([]ServiceQueue, 0)
+	for name, svc := range p.router.Services() {
+		queue := ServiceQueue{Service: name}
+		var active int
+		if value, ok := p.intakes.Load(name); ok {
+			intake := value.(*serviceIntake)
+			intake.mu.Lock()
+			active = intake.active
+			queue.Held = intake.held
+			queue.Paused = intake.paused
+			if intake.paused {
+				since := intake.since
+				queue.PausedSince = &since
+				queue.HoldTimeout = intake.hold.String()
+			}
+			intake.mu.Unlock()
+		}
+		if cfg := svc.Config(); cfg != nil && cfg.Concurrency != nil {
+			queue.MaxConcurrent = cfg.Concurrency.MaxConcurrent
+			queue.MaxQueue = cfg.Concurrency.MaxQueue
+			if value, ok := p.concurrencyLimiters.Load(name); ok {
+				limiter := value.(*fairLimiter)
+				limiter.mu.Lock()
+				queue.Queued = limiter.queued
+				limiter.mu.Unlock()
+			}
+		}
+		queue.InFlight = max(active-queue.Queued, 0)
+		queues = append(queues, queue)
+	}
+
+	sort.Slice(queues, func(i, j int) bool { return queues[i].Service < queues[j].Service })
+	return queues
+}
+
+// intakeMiddleware counts the requests of each service and holds them while
+// its intake is paused
+func (p *Proxy) intakeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match := p.match(r)
+		if match.service == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		intake := p.serviceIntake(match.service.Name())
+		if err := intake.enter(r.Context()); err != nil {
+			if errors.Is(err, errIntakePaused) {
+				w.Header().Set("Retry-After", "5")
+				http.Error(w, "Service paused", http.StatusServiceUnavailable)
+			}
+			return
+		}
+		defer intake.leave()
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_Intake(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+	}))
+	defer backend.Close()
+
+	routes := []*config.RouteConfig{{Name: "api", Match: config.RouteMatch{Path: "/*"}, Service: "api"}}
+	services := map[string]*config.ServiceConfig{
+		"api": {Name: "api", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: backend.URL}}},
+	}
+	p := NewProxy(route.NewRouter(routes, services))
+
+	queue := func() ServiceQueue {
+		queues := p.Queues()
+		require.Len(t, queues, 1)
+		return queues[0]
+	}
+	waitFor := func(cond func(ServiceQueue) bool) {
+		require.Eventually(t, func() bool { return cond(queue()) }, time.Second, 5*time.Millisecond)
+	}
+	serve := func(path string) <-chan int {
+		done := make(chan int, 1)
+		go func() {
+			w := httptest.NewRecorder()
+			p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			done <- w.Code
+		}()
+		return done
+	}
+
+	slow := serve("/slow")
+	waitFor(func(q ServiceQueue) bool { return q.InFlight == 1 })
+
+	// Paused intakes hold new requests while admitted ones complete
+	assert.ErrorIs(t, p.PauseIntake("unknown", 0), config.ErrServiceNotFound)
+	require.NoError(t, p.PauseIntake("api", 0))
+	held := serve("/fast")
+	waitFor(func(q ServiceQueue) bool { return q.Held == 1 })
+	q := queue()
+	assert.True(t, q.Paused)
+	assert.Equal(t, DefaultHoldTimeout.String(), q.HoldTimeout)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-slow)
+	waitFor(func(q ServiceQueue) bool { return q.InFlight == 0 })
+	select {
+	case <-held:
+		t.Fatal("held request was forwarded while paused")
+	default:
+	}
+
+	require.NoError(t, p.ResumeIntake("api"))
+	assert.Equal(t, http.StatusOK, <-held)
+	assert.False(t, queue().Paused)
+
+	// Requests held past the hold timeout are rejected
+	require.NoError(t, p.PauseIntake("api", 20*time.Millisecond))
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Retry-After"))
+	require.NoError(t, p.ResumeIntake("api"))
+}
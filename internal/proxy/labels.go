@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"sort"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// labelPrefix prefixes service and server labels in telemetry attribute keys
+const labelPrefix = "label."
+
+// labels returns the labels of the matched service and a backend of it
+func (m *matchResult) labels(backend string) map[string]string {
+	if m.service == nil {
+		return nil
+	}
+	svcConfig := m.service.Config()
+	if svcConfig == nil {
+		return nil
+	}
+	if backend == "" {
+		return svcConfig.Labels
+	}
+
+	return svcConfig.ServerLabels(backend)
+}
+
+// labelAttributes returns labels as telemetry attributes, sorted by name
+func labelAttributes(labels map[string]string) []attribute.KeyValue {
+	if len(labels) == 0 {
+		return nil
+	}
+	attrs := make([]attribute.KeyValue, 0, len(labels))
+	for name, value := range labels {
+		attrs = append(attrs, attribute.String(labelPrefix+name, value))
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].Key < attrs[j].Key
+	})
+
+	return attrs
+}
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestProxy_Labels(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	routes := []*config.RouteConfig{
+		{Name: "api", Match: config.RouteMatch{Path: "/api"}, Service: "api-service"},
+		{Name: "down", Match: config.RouteMatch{Path: "/down"}, Service: "down-service"},
+	}
+	services := map[string]*config.ServiceConfig{
+		"api-service": {
+			Name:         "api-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL, Labels: map[string]string{"zone": "eu-1a", "version": "v2"}}},
+			Labels:       map[string]string{"tier": "gold", "version": "v1"},
+		},
+		"down-service": {
+			Name:         "down-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: closed.URL, Labels: map[string]string{"zone": "eu-1b"}}},
+		},
+	}
+	p := NewProxy(route.NewRouter(routes, services))
+	exporter := tracetest.NewInMemoryExporter()
+	p.tracer = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)).Tracer("test")
+	reader := sdkmetric.NewManualReader()
+	p.SetMeter(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"))
+	logFile := filepath.Join(t.TempDir(), "access.log")
+	require.NoError(t, p.SetMiddleware([]*config.MiddlewareConfig{{Type: config.MiddlewareAccessLog, Format: "json", File: logFile}}))
+	var info ErrorInfo
+	p.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+		info, _ = ErrorInfoFromRequest(r)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	want := map[string]string{"tier": "gold", "zone": "eu-1a", "version": "v2"}
+
+	// Server labels override service labels on the request span
+	attrs := make(map[string]string)
+	for _, span := range exporter.GetSpans() {
+		if span.Name != "Proxy.Request" {
+			continue
+		}
+		for _, attr := range span.Attributes {
+			attrs[string(attr.Key)] = attr.Value.Emit()
+		}
+	}
+	assert.Equal(t, "gold", attrs["label.tier"])
+	assert.Equal(t, "eu-1a", attrs["label.zone"])
+	assert.Equal(t, "v2", attrs["label.version"])
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	var sizes metricdata.Histogram[int64]
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		if m.Name == "nexus.http.response.size" {
+			sizes = m.Data.(metricdata.Histogram[int64])
+		}
+	}
+	require.Len(t, sizes.DataPoints, 1)
+	zone, _ := sizes.DataPoints[0].Attributes.Value(attribute.Key("label.zone"))
+	assert.Equal(t, "eu-1a", zone.AsString())
+
+	// Error handlers get the labels of the failed backend
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/down", nil))
+	require.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, map[string]string{"zone": "eu-1b"}, info.Labels)
+
+	data, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	var entry accessLogEntry
+	require.NoError(t, json.NewDecoder(bytes.NewReader(data)).Decode(&entry))
+	assert.Equal(t, "api", entry.Route)
+	assert.Equal(t, "api-service", entry.Service)
+	assert.Equal(t, backend.URL, entry.Backend)
+	assert.Equal(t, want, entry.Labels)
+}
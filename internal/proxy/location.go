@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"nexus/internal/config"
+	"nexus/internal/service"
+)
+
+// locationHeaders are the response headers that may carry backend URLs
+var locationHeaders = []string{"Location", "Content-Location"}
+
+// locationTransport rewrites absolute URLs in backend responses that point at
+// a backend of the service, so clients are sent through the proxy instead of
+// to the private upstream address
+type locationTransport struct {
+	next    http.RoundTripper
+	service service.Service
+	cfg     *config.LocationRewriteConfig
+}
+
+func newLocationTransport(next http.RoundTripper, svc service.Service, cfg *config.LocationRewriteConfig) *locationTransport {
+	return &locationTransport{
+		next:    next,
+		service: svc,
+		cfg:     cfg,
+	}
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *locationTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	for _, header := range locationHeaders {
+		if value := resp.Header.Get(header); value != "" {
+			if rewritten, ok := t.rewrite(value, req); ok {
+				resp.Header.Set(header, rewritten)
+			}
+		}
+	}
+
+	return resp, nil
+}
+
+// rewrite maps a backend URL to the public address of the proxy. Relative
+// and external URLs are left alone
+func (t *locationTransport) rewrite(location string, req *http.Request) (string, bool) {
+	u, err := url.Parse(location)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	backend := t.backend(u.Host, req)
+	if backend == nil {
+		return "", false
+	}
+
+	// Requests are forwarded below the path of the server address, so that
+	// prefix is not part of the public URL
+	if base := strings.TrimRight(backend.Path, "/"); base != "" && (u.Path == base || strings.HasPrefix(u.Path, base+"/")) {
+		u.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(u.Path, base), "/")
+		u.RawPath = ""
+	}
+
+	u.Scheme = t.cfg.Scheme
+	if u.Scheme == "" {
+		u.Scheme = "http"
+		if req.TLS != nil {
+			u.Scheme = "https"
+		}
+	}
+	u.Host = t.cfg.Host
+	if u.Host == "" {
+		u.Host = req.Host
+	}
+
+	return u.String(), true
+}
+
+// backend returns the address of the service backend at host, nil when host
+// is not one of them
+func (t *locationTransport) backend(host string, req *http.Request) *url.URL {
+	if match, ok := req.Context().Value(matchContextKey{}).(*matchResult); ok && match.target != nil {
+		if strings.EqualFold(match.target.Host, host) {
+			return match.target
+		}
+	}
+
+	svcConfig := t.service.Config()
+	if svcConfig == nil {
+		return nil
+	}
+	for _, server := range svcConfig.Servers {
+		if u, err := url.Parse(server.Address); err == nil && strings.EqualFold(u.Host, host) {
+			return u
+		}
+	}
+
+	return nil
+}
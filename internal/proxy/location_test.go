@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_LocationRewrite(t *testing.T) {
+	var location, contentLocation string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", location)
+		if contentLocation != "" {
+			w.Header().Set("Content-Location", contentLocation)
+		}
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer backend.Close()
+	other := "http://10.0.0.2:8080"
+
+	newProxy := func(rewrite *config.LocationRewriteConfig) *Proxy {
+		router := route.NewRouter([]*config.RouteConfig{
+			{
+				Name:            "app",
+				Match:           config.RouteMatch{Path: "/app/*"},
+				Service:         "app-service",
+				LocationRewrite: rewrite,
+			},
+		}, map[string]*config.ServiceConfig{
+			"app-service": {
+				Name:         "app-service",
+				BalancerType: "round_robin",
+				Servers:      []config.ServerConfig{{Address: backend.URL + "/internal"}, {Address: other}},
+			},
+		})
+		return NewProxy(router)
+	}
+
+	tests := []struct {
+		name            string
+		rewrite         *config.LocationRewriteConfig
+		location        string
+		contentLocation string
+		expected        string
+		expectedContent string
+	}{
+		{
+			name:     "disabled",
+			location: backend.URL + "/internal/app/login",
+			expected: backend.URL + "/internal/app/login",
+		},
+		{
+			name:     "backend address",
+			rewrite:  &config.LocationRewriteConfig{},
+			location: backend.URL + "/internal/app/login?next=%2Fapp",
+			expected: "http://proxy.example.com/app/login?next=%2Fapp",
+		},
+		{
+			name:            "other backend of the service",
+			rewrite:         &config.LocationRewriteConfig{},
+			location:        "/app/relative",
+			contentLocation: other + "/app/item/1",
+			expected:        "/app/relative",
+			expectedContent: "http://proxy.example.com/app/item/1",
+		},
+		{
+			name:     "external address",
+			rewrite:  &config.LocationRewriteConfig{},
+			location: "https://login.example.org/authorize",
+			expected: "https://login.example.org/authorize",
+		},
+		{
+			name:     "public address",
+			rewrite:  &config.LocationRewriteConfig{Scheme: "https", Host: "www.example.com"},
+			location: backend.URL + "/internal/app/login",
+			expected: "https://www.example.com/app/login",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			location, contentLocation = tt.location, tt.contentLocation
+			p := newProxy(tt.rewrite)
+
+			w := httptest.NewRecorder()
+			p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://proxy.example.com/app/login", nil))
+
+			require.Equal(t, http.StatusFound, w.Code)
+			assert.Equal(t, tt.expected, w.Header().Get("Location"))
+			assert.Equal(t, tt.expectedContent, w.Header().Get("Content-Location"))
+		})
+	}
+}
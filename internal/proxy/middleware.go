@@ -0,0 +1,377 @@
+package proxy
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+	"nexus/internal/realip"
+	"nexus/internal/stats"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultRequestIDHeader carries the request ID when the middleware sets no header
+const defaultRequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength bounds the client supplied request IDs that are kept
+const maxRequestIDLength = 128
+
+// listenerChains are the handlers of the plain and TLS listeners, with their
+// middlewares in front of the proxy
+type listenerChains struct {
+	http  http.Handler
+	https http.Handler
+}
+
+// SetMiddleware sets the middlewares applied to every request before it is
+// routed, the first one runs first
+func (p *Proxy) SetMiddleware(cfgs []*config.MiddlewareConfig) error {
+	chains := &listenerChains{}
+	used := make(map[string]bool)
+	var err error
+	if chains.http, err = p.buildMiddleware(cfgs, "http", used); err != nil {
+		return err
+	}
+	if chains.https, err = p.buildMiddleware(cfgs, "https", used); err != nil {
+		return err
+	}
+	p.middleware.Store(chains)
+	p.closeUnusedSinks(used)
+
+	return nil
+}
+
+// buildMiddleware chains the middlewares of a listener in front of the proxy,
+// adding the access log sinks they write to to used
+func (p *Proxy) buildMiddleware(cfgs []*config.MiddlewareConfig, listener string, used map[string]bool) (http.Handler, error) {
+	handler := p.handler
+	// The access log reports the ID of the request_id middleware
+	requestIDHeader := defaultRequestIDHeader
+	for _, cfg := range cfgs {
+		if cfg.Type == config.MiddlewareRequestID && cfg.Header != "" {
+			requestIDHeader = cfg.Header
+		}
+	}
+
+	for i := len(cfgs) - 1; i >= 0; i-- {
+		cfg := cfgs[i]
+		if len(cfg.Listeners) > 0 && !slices.Contains(cfg.Listeners, listener) {
+			continue
+		}
+		switch cfg.Type {
+		case config.MiddlewareRequestID:
+			handler = requestIDMiddleware(handler, cfg)
+		case config.MiddlewareAccessLog:
+			out, key, err := p.accessLogOutput(cfg)
+			if err != nil {
+				return nil, err
+			}
+			used[key] = true
+			handler = accessLogMiddleware(handler, cfg, out, requestIDHeader)
+		case config.MiddlewareHeaders:
+			handler = headersMiddleware(handler, cfg)
+		default:
+			return nil, fmt.Errorf("unsupported middleware type: %s", cfg.Type)
+		}
+	}
+
+	return handler, nil
+}
+
+// requestIDMiddleware keeps the request ID sent by the client or generates
+// one, and passes it to the backend and back to the client
+func requestIDMiddleware(next http.Handler, cfg *config.MiddlewareConfig) http.Handler {
+	header := cfg.Header
+	if header == "" {
+		header = defaultRequestIDHeader
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(header)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = newRequestID()
+			r.Header.Set(header, id)
+		}
+		w.Header().Set(header, id)
+
+		next.ServeHTTP(w, r.WithContext(lg.WithFields(r.Context(), lg.F("request_id", id))))
+	})
+}
+
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// accessLogWriter serializes the lines of an access log output
+type accessLogWriter struct {
+	mu   sync.Mutex
+	out  io.Writer
+	path string // File of the output, empty for stdout
+}
+
+func (a *accessLogWriter) write(_ *accessLogEntry, line []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.out.Write(line)
+}
+
+// reopen replaces the file of the output by a new one at the same path, after
+// it was moved away by log rotation
+func (a *accessLogWriter) reopen() error {
+	if a.path == "" {
+		return nil
+	}
+	file, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("reopening access log: %w", err)
+	}
+
+	a.mu.Lock()
+	previous := a.out
+	a.out = file
+	a.mu.Unlock()
+
+	if closer, ok := previous.(io.Closer); ok {
+		closer.Close()
+	}
+	return nil
+}
+
+// accessLogFile returns the writer of an access log file, stdout without a
+// path. Files stay open across reloads so lines of in-flight requests are not
+// lost
+func (p *Proxy) accessLogFile(path string) (*accessLogWriter, error) {
+	if value, ok := p.accessLogs.Load(path); ok {
+		return value.(*accessLogWriter), nil
+	}
+	if path == "" {
+		value, _ := p.accessLogs.LoadOrStore(path, &accessLogWriter{out: os.Stdout})
+		return value.(*accessLogWriter), nil
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening access log: %w", err)
+	}
+	value, loaded := p.accessLogs.LoadOrStore(path, &accessLogWriter{out: file, path: path})
+	if loaded {
+		file.Close()
+	}
+
+	return value.(*accessLogWriter), nil
+}
+
+// ReopenLogs reopens the access log and record files at their paths, so the
+// files moved away by log rotation are released
+func (p *Proxy) ReopenLogs() error {
+	var errs []error
+	p.accessLogs.Range(func(_, value any) bool {
+		if out, ok := value.(*accessLogWriter); ok {
+			if err := out.reopen(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return true
+	})
+
+	p.mu.RLock()
+	recorder := p.recorder
+	p.mu.RUnlock()
+	if recorder != nil {
+		if err := recorder.Reopen(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+type requestInfoKey struct{}
+
+// requestInfo receives the routing decision and span of a request for its
+// access log
+type requestInfo struct {
+	match *matchResult
+	span  trace.SpanContext
+}
+
+// accessLogEntry is a json access log line
+type accessLogEntry struct {
+	Time      time.Time `json:"time"`
+	Remote    string    `json:"remote"`
+	Method    string    `json:"method"`
+	Host      string    `json:"host"`
+	URI       string    `json:"uri"`
+	Proto     string    `json:"proto"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	LatencyMs float64   `json:"latency_ms"`
+	Referer   string    `json:"referer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	TraceID   string    `json:"trace_id,omitempty"`
+	SpanID    string    `json:"span_id,omitempty"`
+
+	Route   string            `json:"route,omitempty"`
+	Service string            `json:"service,omitempty"`
+	Backend string            `json:"backend,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"` // Of the service and backend
+	Tags    map[string]string `json:"tags,omitempty"`   // Set by the classification rules
+}
+
+// accessLogMiddleware writes a line per request once the response is sent
+func accessLogMiddleware(next http.Handler, cfg *config.MiddlewareConfig, out accessLogSink, requestIDHeader string) http.Handler {
+	jsonFormat := cfg.Format == "json"
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &requestInfo{}
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info))
+		recorder := &statsRecorder{ResponseWriter: w}
+		// Deferred so responses aborted with a panic are logged too
+		defer func() {
+			entry := accessLogEntry{
+				Time:      start,
+				Remote:    clientAddr(r),
+				Method:    r.Method,
+				Host:      r.Host,
+				URI:       r.RequestURI,
+				Proto:     r.Proto,
+				Status:    recorder.status,
+				Bytes:     recorder.n,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+				Referer:   r.Referer(),
+				UserAgent: r.UserAgent(),
+				RequestID: w.Header().Get(requestIDHeader),
+			}
+			if entry.Status == 0 {
+				entry.Status = http.StatusOK
+			}
+			// Requests the client canceled are logged with status 499
+			if clientCanceled(r) {
+				entry.Status = stats.StatusClientClosedRequest
+			}
+			if info.span.IsValid() {
+				entry.TraceID = info.span.TraceID().String()
+				entry.SpanID = info.span.SpanID().String()
+			}
+			if match := info.match; match != nil {
+				if match.route != nil {
+					entry.Route = match.route.Name
+				}
+				if match.service != nil {
+					entry.Service = match.service.Name()
+				}
+				match.attempts.mu.Lock()
+				entry.Backend = match.attempts.backend
+				match.attempts.mu.Unlock()
+				entry.Labels = match.labels(entry.Backend)
+				entry.Tags = match.tags
+			}
+			if jsonFormat {
+				line, err := json.Marshal(entry)
+				if err == nil {
+					out.write(&entry, append(line, '\n'))
+				}
+				return
+			}
+			out.write(&entry, []byte(formatAccessLog(entry)))
+		}()
+
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// clientAddr returns the client IP relayed by trusted proxies, the peer
+// address with its port otherwise
+func clientAddr(r *http.Request) string {
+	if ip, ok := realip.FromContext(r.Context()); ok {
+		return ip.String()
+	}
+	return r.RemoteAddr
+}
+
+// formatAccessLog formats an entry in the combined log format followed by
+// the latency and request ID
+func formatAccessLog(e accessLogEntry) string {
+	host, _, err := net.SplitHostPort(e.Remote)
+	if err != nil {
+		host = e.Remote
+	}
+	requestID := e.RequestID
+	if requestID == "" {
+		requestID = "-"
+	}
+
+	return fmt.Sprintf("%s - - [%s] %q %d %d %q %q %.3fms %s\n",
+		host, e.Time.Format("02/Jan/2006:15:04:05 -0700"), e.Method+" "+e.URI+" "+e.Proto,
+		e.Status, e.Bytes, e.Referer, e.UserAgent, e.LatencyMs, requestID)
+}
+
+// headersMiddleware sets request headers before the request is routed and
+// response headers once the response headers are written
+func headersMiddleware(next http.Handler, cfg *config.MiddlewareConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		applyHeaders(r.Header, cfg.RequestHeaders)
+		if len(cfg.ResponseHeaders) > 0 {
+			w = &headerWriter{ResponseWriter: w, headers: cfg.ResponseHeaders}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// applyHeaders sets headers, removing those with an empty value
+func applyHeaders(header http.Header, values map[string]string) {
+	for name, value := range values {
+		if value == "" {
+			header.Del(name)
+			continue
+		}
+		header.Set(name, value)
+	}
+}
+
+// headerWriter applies response headers over those of the backend
+type headerWriter struct {
+	http.ResponseWriter
+	headers map[string]string
+	applied bool
+}
+
+func (h *headerWriter) WriteHeader(status int) {
+	// Informational responses keep their own headers
+	if !h.applied && (status >= 200 || status == http.StatusSwitchingProtocols) {
+		h.applied = true
+		applyHeaders(h.Header(), h.headers)
+	}
+	h.ResponseWriter.WriteHeader(status)
+}
+
+func (h *headerWriter) Write(b []byte) (int, error) {
+	if !h.applied {
+		h.WriteHeader(http.StatusOK)
+	}
+	return h.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (h *headerWriter) Unwrap() http.ResponseWriter {
+	return h.ResponseWriter
+}
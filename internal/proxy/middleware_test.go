@@ -0,0 +1,188 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+	"nexus/internal/replay"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_Middleware(t *testing.T) {
+	var requestID, env, debug string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID = r.Header.Get("X-Request-Id")
+		env = r.Header.Get("X-Env")
+		debug = r.Header.Get("X-Debug")
+		w.Header().Set("Server", "backend")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	}))
+	defer backend.Close()
+
+	routes := []*config.RouteConfig{{Name: "api", Match: config.RouteMatch{Path: "/api"}, Service: "api-service"}}
+	services := map[string]*config.ServiceConfig{
+		"api-service": {
+			Name:         "api-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+	}
+	logFile := filepath.Join(t.TempDir(), "access.log")
+	p := NewProxy(route.NewRouter(routes, services))
+	require.NoError(t, p.SetMiddleware([]*config.MiddlewareConfig{
+		{Type: config.MiddlewareAccessLog, Format: "json", File: logFile},
+		{Type: config.MiddlewareRequestID},
+		{
+			Type:            config.MiddlewareHeaders,
+			Listeners:       []string{"https"},
+			RequestHeaders:  map[string]string{"X-Env": "prod", "X-Debug": ""},
+			ResponseHeaders: map[string]string{"Server": "nexus", "Strict-Transport-Security": "max-age=31536000"},
+		},
+	}))
+
+	// Generated IDs are sent to the backend and the client
+	r := httptest.NewRequest(http.MethodGet, "/api?page=2", nil)
+	r.Header.Set("X-Debug", "1")
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, r)
+	require.Equal(t, http.StatusCreated, w.Code)
+	generated := requestID
+	assert.Len(t, generated, 32)
+	assert.Equal(t, generated, w.Header().Get("X-Request-Id"))
+	assert.Empty(t, env)
+	assert.Equal(t, "1", debug)
+	assert.Equal(t, "backend", w.Header().Get("Server"))
+
+	// Client IDs are kept, the headers middleware only runs on TLS requests
+	r = httptest.NewRequest(http.MethodGet, "/api", nil)
+	r.TLS = &tls.ConnectionState{}
+	r.Header.Set("X-Request-Id", "client-id")
+	r.Header.Set("X-Debug", "1")
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, r)
+	assert.Equal(t, "client-id", requestID)
+	assert.Equal(t, "prod", env)
+	assert.Empty(t, debug)
+	assert.Equal(t, []string{"nexus"}, w.Header().Values("Server"))
+	assert.Equal(t, "max-age=31536000", w.Header().Get("Strict-Transport-Security"))
+
+	data, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	var entry accessLogEntry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, http.MethodGet, entry.Method)
+	assert.Equal(t, "/api?page=2", entry.URI)
+	assert.Equal(t, http.StatusCreated, entry.Status)
+	assert.Equal(t, int64(len("created")), entry.Bytes)
+	assert.Equal(t, generated, entry.RequestID)
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, "client-id", entry.RequestID)
+}
+
+func TestProxy_ReopenLogs(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	routes := []*config.RouteConfig{{Name: "api", Match: config.RouteMatch{Path: "/api"}, Service: "api-service"}}
+	services := map[string]*config.ServiceConfig{
+		"api-service": {
+			Name:         "api-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+	}
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "access.log")
+	recordFile := filepath.Join(dir, "record.jsonl")
+	p := NewProxy(route.NewRouter(routes, services))
+	require.NoError(t, p.SetMiddleware([]*config.MiddlewareConfig{{Type: config.MiddlewareAccessLog, File: logFile}}))
+	recorder, err := replay.NewRecorder(recordFile)
+	require.NoError(t, err)
+	defer recorder.Close()
+	p.SetRecorder(recorder)
+	p.SetRecordConfig(config.RecordConfig{Enabled: true, File: recordFile})
+
+	serve := func() {
+		t.Helper()
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	lines := func(path string) int {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return strings.Count(string(data), "\n")
+	}
+
+	// Rotated files keep receiving lines until the files are reopened
+	serve()
+	require.NoError(t, os.Rename(logFile, logFile+".1"))
+	require.NoError(t, os.Rename(recordFile, recordFile+".1"))
+	serve()
+	require.NoError(t, p.ReopenLogs())
+	serve()
+
+	assert.Equal(t, 2, lines(logFile+".1"))
+	assert.Equal(t, 2, lines(recordFile+".1"))
+	assert.Equal(t, 1, lines(logFile))
+	assert.Equal(t, 1, lines(recordFile))
+}
+
+func TestFormatAccessLog(t *testing.T) {
+	entry := accessLogEntry{
+		Time:      time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+		Remote:    "192.0.2.1:51234",
+		Method:    http.MethodGet,
+		URI:       "/index.html",
+		Proto:     "HTTP/1.1",
+		Status:    http.StatusOK,
+		Bytes:     512,
+		LatencyMs: 1.5,
+		UserAgent: "curl/8.0",
+	}
+
+	assert.Equal(t, `192.0.2.1 - - [01/Mar/2024:10:00:00 +0000] "GET /index.html HTTP/1.1" 200 512 "" "curl/8.0" 1.500ms -`+"\n", formatAccessLog(entry))
+}
+
+func TestProxy_RequestLogger(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	routes := []*config.RouteConfig{{Name: "api", Match: config.RouteMatch{Path: "/api"}, Service: "api-service"}}
+	services := map[string]*config.ServiceConfig{
+		"api-service": {Name: "api-service", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: closed.URL}}},
+	}
+	p := NewProxy(route.NewRouter(routes, services))
+	require.NoError(t, p.SetMiddleware([]*config.MiddlewareConfig{{Type: config.MiddlewareRequestID}}))
+	var fields []lg.Field
+	p.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+		fields = lg.FromContext(r.Context()).Fields()
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/api", nil)
+	r.Header.Set("X-Request-Id", "client-id")
+	p.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, []lg.Field{
+		lg.F("request_id", "client-id"),
+		lg.F("route", "api"),
+		lg.F("service", "api-service"),
+		lg.F("backend", closed.URL),
+	}, fields)
+}
@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"nexus/internal/balancer"
 	"nexus/internal/config"
+	"nexus/internal/route"
 	"nexus/internal/service"
 )
 
@@ -19,10 +20,53 @@ func (m *MockRouter) Match(req *http.Request) service.Service {
 	return m.services["mock"]
 }
 
+func (m *MockRouter) MatchRoute(req *http.Request) (*config.RouteConfig, service.Service) {
+	return nil, m.services["mock"]
+}
+
 func (m *MockRouter) Update(routes []*config.RouteConfig, services map[string]*config.ServiceConfig) error {
 	return nil
 }
 
+func (m *MockRouter) Services() map[string]service.Service {
+	return m.services
+}
+
+func (m *MockRouter) Service(name string) (service.Service, bool) {
+	svc, ok := m.services[name]
+	return svc, ok
+}
+
+func (m *MockRouter) AddRoute(route *config.RouteConfig) error {
+	m.routes = append(m.routes, route)
+	return nil
+}
+
+func (m *MockRouter) RemoveRoute(name string) error {
+	return nil
+}
+
+func (m *MockRouter) UpdateRoutes(fn func(routes []*config.RouteConfig) ([]*config.RouteConfig, error)) error {
+	routes, err := fn(m.routes)
+	if err != nil {
+		return err
+	}
+	m.routes = routes
+	return nil
+}
+
+func (m *MockRouter) ListRoutes() []*config.RouteConfig {
+	return m.routes
+}
+
+func (m *MockRouter) Table() []route.TableEntry {
+	return nil
+}
+
+func (m *MockRouter) SetCacheSize(size int) {}
+
+func (m *MockRouter) SetSource(src balancer.Source) {}
+
 type MockService struct {
 	backend *httptest.Server
 }
@@ -55,3 +99,19 @@ func (m *MockService) Name() string {
 func (m *MockService) Update(config *config.ServiceConfig) error {
 	return nil
 }
+
+func (m *MockService) Config() *config.ServiceConfig {
+	return &config.ServiceConfig{Name: m.Name()}
+}
+
+func (m *MockService) Drain(address, reason string) error {
+	return nil
+}
+
+func (m *MockService) Undrain(address, reason string) error {
+	return nil
+}
+
+func (m *MockService) Drained() map[string][]string {
+	return nil
+}
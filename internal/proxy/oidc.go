@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"net/http"
+
+	"nexus/internal/config"
+	"nexus/internal/oidc"
+)
+
+// oidcRoute is the authenticator built from the OIDC config of a route
+type oidcRoute struct {
+	cfg  *config.OIDCConfig
+	auth *oidc.Authenticator
+}
+
+// oidcMiddleware requires an OpenID Connect login for routes with an oidc
+// config and forwards the identity claims of the session to the backend
+func (p *Proxy) oidcMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match := p.match(r)
+		if match.route == nil || match.route.OIDC == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		auth, err := p.oidcAuthenticator(match.route)
+		if err != nil {
+			requestLogger(r).Error("OIDC setup failed: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		r, ok := auth.Authenticate(w, r)
+		if !ok {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// oidcAuthenticator returns the authenticator of a route, rebuilt when its
+// config changes
+func (p *Proxy) oidcAuthenticator(route *config.RouteConfig) (*oidc.Authenticator, error) {
+	if cached, ok := p.oidcRoutes.Load(route.Name); ok {
+		if cached := cached.(*oidcRoute); cached.cfg == route.OIDC {
+			return cached.auth, nil
+		}
+	}
+
+	auth, err := oidc.NewAuthenticator(route.Name, route.OIDC)
+	if err != nil {
+		return nil, err
+	}
+	p.oidcRoutes.Store(route.Name, &oidcRoute{cfg: route.OIDC, auth: auth})
+
+	return auth, nil
+}
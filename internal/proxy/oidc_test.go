@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxy_OIDC(t *testing.T) {
+	var idp *httptest.Server
+	idp = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	}))
+	defer idp.Close()
+
+	var forwarded atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	router := route.NewRouter([]*config.RouteConfig{
+		{
+			Name:    "public",
+			Match:   config.RouteMatch{Path: "/public/*"},
+			Service: "app-service",
+		},
+		{
+			Name:    "app",
+			Match:   config.RouteMatch{Path: "/app/*"},
+			Service: "app-service",
+			OIDC: &config.OIDCConfig{
+				Issuer:       idp.URL,
+				ClientID:     "nexus",
+				CookieSecret: "0123456789abcdef",
+				RedirectURL:  "/app/callback",
+			},
+		},
+	}, map[string]*config.ServiceConfig{
+		"app-service": {
+			Name:         "app-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+	})
+	p := NewProxy(router)
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/public/a", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.EqualValues(t, 1, forwarded.Load())
+
+	// Browsers are sent to the provider without reaching the backend
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/app/a", nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Location"), idp.URL+"/authorize?"))
+	assert.Contains(t, w.Header().Get("Location"), "redirect_uri=http%3A%2F%2Fexample.com%2Fapp%2Fcallback")
+
+	// Spoofed identities don't get through
+	req := httptest.NewRequest(http.MethodPost, "/app/a", nil)
+	req.Header.Set("X-Forwarded-User", "admin")
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.EqualValues(t, 1, forwarded.Load())
+}
@@ -0,0 +1,214 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"nexus/internal/config"
+)
+
+var errPathAboveRoot = errors.New("path escapes the root")
+
+// SetPathConfig sets the normalization applied to request paths before
+// routes are matched
+func (p *Proxy) SetPathConfig(cfg config.PathConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pathConfig = cfg
+}
+
+// normalizeRequest returns the request with its path normalized, or false
+// when it was answered with a redirect or an error
+func (p *Proxy) normalizeRequest(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	p.mu.RLock()
+	cfg := p.pathConfig
+	p.mu.RUnlock()
+	if !cfg.MergeSlashes && !cfg.ResolveDotSegments && !cfg.DecodeUnreserved &&
+		(cfg.TrailingSlash == "" || cfg.TrailingSlash == config.TrailingSlashKeep) &&
+		(cfg.EncodedSlashes == "" || cfg.EncodedSlashes == config.EncodedSlashesKeep) {
+		return r, true
+	}
+
+	path, err := normalizePath(r.URL.Path, cfg)
+	if err != nil {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return nil, false
+	}
+	rawPath := r.URL.RawPath
+	if rawPath != "" && hasEncodedSlash(rawPath) {
+		switch cfg.EncodedSlashes {
+		case config.EncodedSlashesReject:
+			http.Error(w, "Invalid path", http.StatusBadRequest)
+			return nil, false
+		case config.EncodedSlashesDecode:
+			// The path already holds the decoded slashes
+			rawPath = ""
+		}
+	}
+	if rawPath != "" && cfg.DecodeUnreserved {
+		rawPath = normalizeEscapes(rawPath)
+	}
+	if rawPath != "" {
+		// Escaped slashes are not separators, the raw path is normalized on
+		// its own and only kept while it still encodes the path
+		if rawPath, err = normalizePath(rawPath, cfg); err != nil {
+			http.Error(w, "Invalid path", http.StatusBadRequest)
+			return nil, false
+		}
+		if unescaped, err := url.PathUnescape(rawPath); err != nil || unescaped != path {
+			rawPath = ""
+		}
+	}
+
+	if cfg.TrailingSlash == config.TrailingSlashRedirect {
+		if trimmed := trimTrailingSlash(path); trimmed != path {
+			target := *r.URL
+			target.Path, target.RawPath = trimmed, trimTrailingSlash(rawPath)
+			http.Redirect(w, r, target.RequestURI(), http.StatusPermanentRedirect)
+			return nil, false
+		}
+	}
+	if path == r.URL.Path && rawPath == r.URL.RawPath {
+		return r, true
+	}
+
+	u := *r.URL
+	u.Path, u.RawPath = path, rawPath
+	normalized := new(http.Request)
+	*normalized = *r
+	normalized.URL = &u
+	return normalized, true
+}
+
+// normalizePath merges slashes, resolves dot segments and trims trailing
+// slashes as configured, redirects are left to the caller
+func normalizePath(path string, cfg config.PathConfig) (string, error) {
+	if cfg.MergeSlashes && strings.Contains(path, "//") {
+		var b strings.Builder
+		b.Grow(len(path))
+		for i := 0; i < len(path); i++ {
+			if path[i] == '/' && i > 0 && path[i-1] == '/' {
+				continue
+			}
+			b.WriteByte(path[i])
+		}
+		path = b.String()
+	}
+	if cfg.ResolveDotSegments {
+		var err error
+		if path, err = resolveDotSegments(path); err != nil {
+			return "", err
+		}
+	}
+	if cfg.TrailingSlash == config.TrailingSlashStrip {
+		path = trimTrailingSlash(path)
+	}
+
+	return path, nil
+}
+
+// resolveDotSegments removes . and .. segments like RFC 3986 section 5.2.4,
+// except that .. above the root is an error instead of being dropped
+func resolveDotSegments(path string) (string, error) {
+	if !strings.Contains(path, ".") {
+		return path, nil
+	}
+
+	segments := strings.Split(path, "/")
+	resolved := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			if last {
+				resolved = append(resolved, "")
+			}
+		case "..":
+			// The first segment is the empty one before the leading slash
+			if len(resolved) <= 1 {
+				return "", errPathAboveRoot
+			}
+			resolved = resolved[:len(resolved)-1]
+			if last {
+				resolved = append(resolved, "")
+			}
+		default:
+			resolved = append(resolved, segment)
+		}
+	}
+
+	return strings.Join(resolved, "/"), nil
+}
+
+func trimTrailingSlash(path string) string {
+	if len(path) <= 1 || !strings.HasSuffix(path, "/") {
+		return path
+	}
+	if trimmed := strings.TrimRight(path, "/"); trimmed != "" {
+		return trimmed
+	}
+	return "/"
+}
+
+// hasEncodedSlash reports whether an escaped path holds %2F or %5C, which
+// backends may decode into separators the routes never saw
+func hasEncodedSlash(rawPath string) bool {
+	for i := 0; i+2 < len(rawPath); i++ {
+		if rawPath[i] != '%' {
+			continue
+		}
+		if b := unhex(rawPath[i+1])<<4 | unhex(rawPath[i+2]); b == '/' || b == '\\' {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeEscapes decodes the escaped unreserved characters of RFC 3986
+// section 2.3 and uppercases the other escapes, so equivalent paths are
+// forwarded the same way
+func normalizeEscapes(rawPath string) string {
+	if !strings.Contains(rawPath, "%") {
+		return rawPath
+	}
+
+	var b strings.Builder
+	b.Grow(len(rawPath))
+	for i := 0; i < len(rawPath); i++ {
+		if rawPath[i] != '%' || i+2 >= len(rawPath) || !isHex(rawPath[i+1]) || !isHex(rawPath[i+2]) {
+			b.WriteByte(rawPath[i])
+			continue
+		}
+		if c := unhex(rawPath[i+1])<<4 | unhex(rawPath[i+2]); isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteString(strings.ToUpper(rawPath[i+1 : i+3]))
+		}
+		i += 2
+	}
+	return b.String()
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10
+	}
+	return 0
+}
@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizePath(t *testing.T) {
+	all := config.PathConfig{MergeSlashes: true, ResolveDotSegments: true, TrailingSlash: config.TrailingSlashStrip}
+	tests := []struct {
+		path string
+		cfg  config.PathConfig
+		want string
+		err  bool
+	}{
+		{path: "/api//v1///users", cfg: config.PathConfig{MergeSlashes: true}, want: "/api/v1/users"},
+		{path: "/api//v1", cfg: config.PathConfig{}, want: "/api//v1"},
+		{path: "/api/./v1/../v2", cfg: config.PathConfig{ResolveDotSegments: true}, want: "/api/v2"},
+		{path: "/api/v1/..", cfg: config.PathConfig{ResolveDotSegments: true}, want: "/api/"},
+		{path: "/api/..", cfg: config.PathConfig{ResolveDotSegments: true}, want: "/"},
+		{path: "/api/../../etc/passwd", cfg: config.PathConfig{ResolveDotSegments: true}, err: true},
+		{path: "/api/v1/", cfg: config.PathConfig{TrailingSlash: config.TrailingSlashStrip}, want: "/api/v1"},
+		{path: "/", cfg: all, want: "/"},
+		{path: "//api/./v1//", cfg: all, want: "/api/v1"},
+		{path: "/files/..hidden", cfg: all, want: "/files/..hidden"},
+	}
+	for _, tt := range tests {
+		got, err := normalizePath(tt.path, tt.cfg)
+		if tt.err {
+			assert.Error(t, err, tt.path)
+			continue
+		}
+		require.NoError(t, err, tt.path)
+		assert.Equal(t, tt.want, got, tt.path)
+	}
+}
+
+func TestProxy_PathNormalization(t *testing.T) {
+	var forwarded string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.URL.RequestURI()
+	}))
+	defer backend.Close()
+
+	routes := []*config.RouteConfig{{Name: "users", Match: config.RouteMatch{Path: "/api/v1/users"}, Service: "api"}}
+	services := map[string]*config.ServiceConfig{
+		"api": {Name: "api", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: backend.URL}}},
+	}
+	p := NewProxy(route.NewRouter(routes, services))
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	// Without normalization only the exact path matches
+	serve("/api//v1/users/")
+	assert.Empty(t, forwarded)
+
+	p.SetPathConfig(config.PathConfig{MergeSlashes: true, ResolveDotSegments: true, TrailingSlash: config.TrailingSlashStrip})
+	w := serve("/api//v1/admin/../users/?page=2")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "/api/v1/users?page=2", forwarded)
+	assert.Equal(t, http.StatusBadRequest, serve("/api/../../users").Code)
+
+	p.SetPathConfig(config.PathConfig{TrailingSlash: config.TrailingSlashRedirect})
+	w = serve("/api/v1/users/?page=2")
+	assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+	assert.Equal(t, "/api/v1/users?page=2", w.Header().Get("Location"))
+	assert.Equal(t, http.StatusOK, serve("/api/v1/users").Code)
+}
+
+func TestNormalizeEscapes(t *testing.T) {
+	assert.Equal(t, "/~user/a-b_c.d", normalizeEscapes("/%7euser/a%2Db%5Fc%2ed"))
+	assert.Equal(t, "/a%2Fb%3Fc", normalizeEscapes("/a%2fb%3fc"))
+	assert.Equal(t, "/100%", normalizeEscapes("/100%"))
+	assert.True(t, hasEncodedSlash("/a%2fb"))
+	assert.True(t, hasEncodedSlash("/a%5Cb"))
+	assert.False(t, hasEncodedSlash("/a%25b"))
+}
+
+func TestProxy_PathEscapes(t *testing.T) {
+	var forwarded string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.URL.RequestURI()
+	}))
+	defer backend.Close()
+
+	routes := []*config.RouteConfig{{Name: "files", Match: config.RouteMatch{Path: "/files/*"}, Service: "api"}}
+	services := map[string]*config.ServiceConfig{
+		"api": {Name: "api", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: backend.URL}}},
+	}
+	p := NewProxy(route.NewRouter(routes, services))
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	// Escapes are forwarded as sent by default
+	serve("/files/%7euser/a%2fb")
+	assert.Equal(t, "/files/%7euser/a%2fb", forwarded)
+
+	p.SetPathConfig(config.PathConfig{DecodeUnreserved: true, ResolveDotSegments: true})
+	assert.Equal(t, http.StatusOK, serve("/files/%7euser/a%2fb").Code)
+	assert.Equal(t, "/files/~user/a%2Fb", forwarded)
+	assert.Equal(t, http.StatusOK, serve("/files/x/%2e%2e/y").Code)
+	assert.Equal(t, "/files/y", forwarded)
+
+	p.SetPathConfig(config.PathConfig{EncodedSlashes: config.EncodedSlashesDecode})
+	serve("/files/a%2Fb")
+	assert.Equal(t, "/files/a/b", forwarded)
+
+	p.SetPathConfig(config.PathConfig{EncodedSlashes: config.EncodedSlashesReject})
+	assert.Equal(t, http.StatusBadRequest, serve("/files/a%2Fb").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("/files/a%5cb").Code)
+	assert.Equal(t, http.StatusOK, serve("/files/a%20b").Code)
+}
@@ -0,0 +1,273 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"reflect"
+	"sync"
+	"time"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+	"nexus/internal/stats"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+)
+
+// defaultLeakTimeout is the age of open response bodies reported as leaked
+const defaultLeakTimeout = 5 * time.Minute
+
+// poolServerKey is the context key of the backend server a connection is dialed for
+type poolServerKey struct{}
+
+// newPooledTransport clones the default transport, counting the connections
+// it dials in pool. Connections are counted for the server in the dial
+// context, or their address. Hosts are resolved through dns
+func newPooledTransport(pool *stats.Pool, dns *dnsCache) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dial := dns.dialer(transport.DialContext)
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		server, _ := ctx.Value(poolServerKey{}).(string)
+		if server == "" {
+			server = addr
+		}
+		pool.Add(server, stats.PoolCounters{Open: 1})
+		return &pooledConn{Conn: conn, pool: pool, server: server}, nil
+	}
+
+	return transport
+}
+
+// Pool returns the collector counting the connections of the default transport
+func (p *Proxy) Pool() *stats.Pool {
+	return p.pool
+}
+
+// SetUpstreamConfig sets the upstream connection config. A change of the
+// HTTP/2 settings replaces the transport, unless one was set by SetTransport.
+// DNS settings apply to every transport created by the proxy
+func (p *Proxy) SetUpstreamConfig(cfg config.UpstreamConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	previous := p.upstreamConfig.HTTP2
+	p.upstreamConfig = cfg
+	p.dns.setConfig(cfg.DNS)
+	if p.customTransport || reflect.DeepEqual(previous, cfg.HTTP2) {
+		return
+	}
+	if closer, ok := p.transport.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+	p.transport = newUpstreamTransport(p.pool, p.dns, cfg.HTTP2)
+	p.upstream = attemptTransport{next: otelhttp.NewTransport(p.transport), proxy: p, pool: p.pool}
+	p.generation++
+}
+
+// leakTimeout returns the age of open response bodies reported as leaked
+func (p *Proxy) leakTimeout() time.Duration {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.upstreamConfig.LeakTimeout > 0 {
+		return p.upstreamConfig.LeakTimeout
+	}
+	return defaultLeakTimeout
+}
+
+// pooledConn is an upstream connection counted in the pool statistics. It is
+// active while requests are in flight on it, HTTP/2 connections carry several
+type pooledConn struct {
+	net.Conn
+	pool   *stats.Pool
+	server string
+
+	mu       sync.Mutex
+	requests int
+	closed   bool
+}
+
+func (c *pooledConn) acquire(reused bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delta := stats.PoolCounters{New: 1}
+	if reused {
+		delta = stats.PoolCounters{Reused: 1}
+	}
+	c.requests++
+	if c.requests == 1 && !c.closed {
+		delta.Active = 1
+	}
+	c.pool.Add(c.server, delta)
+}
+
+func (c *pooledConn) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests--
+	if c.requests == 0 && !c.closed {
+		c.pool.Add(c.server, stats.PoolCounters{Active: -1})
+	}
+}
+
+func (c *pooledConn) Close() error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		delta := stats.PoolCounters{Open: -1}
+		if c.requests > 0 {
+			delta.Active = -1
+		}
+		c.pool.Add(c.server, delta)
+	}
+	c.mu.Unlock()
+
+	return c.Conn.Close()
+}
+
+// pooledRequest tracks the connection of one upstream request until the
+// connection returns to the pool, the response body ends or the request fails
+type pooledRequest struct {
+	mu   sync.Mutex
+	conn *pooledConn
+}
+
+// gotConn is called by the client trace, the transport may retry on another
+// connection when a pooled one turns out to be closed
+func (r *pooledRequest) gotConn(info httptrace.GotConnInfo) {
+	conn := info.Conn
+	if tlsConn, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		conn = tlsConn.NetConn()
+	}
+	pooled, ok := conn.(*pooledConn)
+	if !ok {
+		return
+	}
+
+	r.mu.Lock()
+	previous := r.conn
+	r.conn = pooled
+	r.mu.Unlock()
+
+	if previous != nil {
+		previous.release()
+	}
+	pooled.acquire(info.Reused)
+}
+
+func (r *pooledRequest) release() {
+	r.mu.Lock()
+	conn := r.conn
+	r.conn = nil
+	r.mu.Unlock()
+
+	if conn != nil {
+		conn.release()
+	}
+}
+
+// trackResponse releases the connection of failed requests and responses
+// without body, other bodies are tracked until they end or are closed
+func (p *Proxy) trackResponse(resp *http.Response, err error, pooled *pooledRequest, server, routeName string) {
+	if err != nil || resp.Body == nil || resp.Body == http.NoBody {
+		pooled.release()
+		return
+	}
+	// Upgraded connections are handed over to the client with the body
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		return
+	}
+
+	body := &trackedBody{ReadCloser: resp.Body, pooled: pooled, pool: p.pool, server: server}
+	timeout := p.leakTimeout()
+	body.timer = time.AfterFunc(timeout, func() {
+		p.pool.Add(server, stats.PoolCounters{Leaked: 1})
+		lg.GetInstance().Warn("Response body of %s on route %s still open after %s, it must be read to the end or closed to release its connection",
+			server, routeName, timeout)
+	})
+	resp.Body = body
+}
+
+// trackedBody releases the connection of a response when its body ends.
+// Bodies closed early are counted as discarded, HTTP/1 connections can't be
+// reused after them
+type trackedBody struct {
+	io.ReadCloser
+	pooled *pooledRequest
+	pool   *stats.Pool
+	server string
+	timer  *time.Timer
+	eof    bool
+	once   sync.Once
+}
+
+func (b *trackedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.eof = true
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *trackedBody) Close() error {
+	if !b.eof {
+		b.once.Do(func() {
+			b.pool.Add(b.server, stats.PoolCounters{Discarded: 1})
+			lg.GetInstance().Debug("Response body of %s closed before its end, its connection is not reused", b.server)
+		})
+	}
+	b.finish()
+
+	return b.ReadCloser.Close()
+}
+
+func (b *trackedBody) finish() {
+	b.timer.Stop()
+	b.pooled.release()
+}
+
+// registerPoolMetrics reports the pool statistics as OpenTelemetry metrics
+func registerPoolMetrics(meter otelmetric.Meter, pool *stats.Pool) error {
+	connections, err := meter.Int64ObservableGauge("nexus.upstream.connections",
+		otelmetric.WithDescription("Open upstream connections by state, active or idle"))
+	if err != nil {
+		return err
+	}
+	requests, err := meter.Int64ObservableCounter("nexus.upstream.connection.requests",
+		otelmetric.WithDescription("Upstream requests by whether they reused a pooled connection"))
+	if err != nil {
+		return err
+	}
+	unreleased, err := meter.Int64ObservableCounter("nexus.upstream.response.unreleased",
+		otelmetric.WithDescription("Response bodies closed before their end (discarded) or left open past the leak timeout (leaked)"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o otelmetric.Observer) error {
+		for server, cnt := range pool.Snapshot() {
+			backend := attribute.String("backend.address", server)
+			o.ObserveInt64(connections, cnt.Active, otelmetric.WithAttributes(backend, attribute.String("state", "active")))
+			o.ObserveInt64(connections, cnt.Idle, otelmetric.WithAttributes(backend, attribute.String("state", "idle")))
+			o.ObserveInt64(requests, cnt.New, otelmetric.WithAttributes(backend, attribute.Bool("reused", false)))
+			o.ObserveInt64(requests, cnt.Reused, otelmetric.WithAttributes(backend, attribute.Bool("reused", true)))
+			o.ObserveInt64(unreleased, cnt.Discarded, otelmetric.WithAttributes(backend, attribute.String("reason", "discarded")))
+			o.ObserveInt64(unreleased, cnt.Leaked, otelmetric.WithAttributes(backend, attribute.String("reason", "leaked")))
+		}
+		return nil
+	}, connections, requests, unreleased)
+
+	return err
+}
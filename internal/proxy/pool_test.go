@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+	"nexus/internal/stats"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_PoolStats(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	routes := []*config.RouteConfig{{Name: "api", Match: config.RouteMatch{Path: "/api"}, Service: "api-service"}}
+	services := map[string]*config.ServiceConfig{
+		"api-service": {
+			Name:         "api-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+	}
+	p := NewProxy(route.NewRouter(routes, services))
+	defer p.transport.(*http.Transport).CloseIdleConnections()
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	// Connections are returned to the pool after the response is copied
+	assert.Eventually(t, func() bool {
+		return p.Pool().Snapshot()[backend.URL].Active == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, stats.PoolCounters{Open: 1, Idle: 1, New: 1, Reused: 1}, p.Pool().Snapshot()[backend.URL])
+
+	p.transport.(*http.Transport).CloseIdleConnections()
+	assert.Equal(t, stats.PoolCounters{New: 1, Reused: 1}, p.Pool().Snapshot()[backend.URL])
+}
+
+func TestProxy_TrackResponse(t *testing.T) {
+	p := NewProxy(route.NewRouter(nil, nil))
+	p.SetUpstreamConfig(config.UpstreamConfig{LeakTimeout: 20 * time.Millisecond})
+
+	// Bodies read to the end are released
+	resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok"))}
+	p.trackResponse(resp, nil, &pooledRequest{}, "http://backend1", "api")
+	_, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	// Bodies closed early are discarded
+	resp = &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok"))}
+	p.trackResponse(resp, nil, &pooledRequest{}, "http://backend1", "api")
+	require.NoError(t, resp.Body.Close())
+	require.NoError(t, resp.Body.Close())
+
+	// Bodies left open are reported once the leak timeout passes
+	resp = &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok"))}
+	p.trackResponse(resp, nil, &pooledRequest{}, "http://backend2", "api")
+	assert.Eventually(t, func() bool {
+		return p.Pool().Snapshot()["http://backend2"].Leaked == 1
+	}, time.Second, 5*time.Millisecond)
+
+	time.Sleep(40 * time.Millisecond)
+	assert.Equal(t, stats.PoolCounters{Discarded: 1}, p.Pool().Snapshot()["http://backend1"])
+}
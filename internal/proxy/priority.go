@@ -0,0 +1,30 @@
+package proxy
+
+import "nexus/internal/config"
+
+// priority is the overload class of a route, lower values are served first
+type priority int
+
+const (
+	priorityCritical priority = iota
+	priorityDefault
+	priorityBestEffort
+
+	numPriorities = 3
+)
+
+// routePriority returns the priority class of a route, requests without a
+// route have the default class
+func routePriority(route *config.RouteConfig) priority {
+	if route == nil {
+		return priorityDefault
+	}
+	switch route.Priority {
+	case config.PriorityCritical:
+		return priorityCritical
+	case config.PriorityBestEffort:
+		return priorityBestEffort
+	default:
+		return priorityDefault
+	}
+}
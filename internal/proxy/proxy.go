@@ -1,13 +1,27 @@
 package proxy
 
 import (
+	"context"
 	"net/http"
-	"net/http/httptrace"
 	"net/http/httputil"
 	"net/url"
 	"nexus/internal/balancer"
+	"nexus/internal/cache"
+	"nexus/internal/classify"
+	"nexus/internal/config"
+	"nexus/internal/fault"
+	"nexus/internal/geo"
+	"nexus/internal/pressure"
+	"nexus/internal/ratelimit"
+	"nexus/internal/realip"
+	"nexus/internal/replay"
 	"nexus/internal/route"
+	"nexus/internal/service"
+	"nexus/internal/stats"
+	"nexus/internal/telemetry"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
@@ -18,55 +32,237 @@ import (
 
 // Proxy struct represents a reverse proxy
 type Proxy struct {
-	mu           sync.RWMutex
-	router       route.Router
-	transport    http.RoundTripper
-	errorHandler func(http.ResponseWriter, *http.Request, error)
-	tracer       trace.Tracer
+	mu                  sync.RWMutex
+	router              route.Router
+	transport           http.RoundTripper
+	upstream            http.RoundTripper // transport wrapped for tracing
+	generation          uint64            // bumped whenever the transport changes
+	handler             http.Handler
+	middleware          atomic.Pointer[listenerChains]
+	accessLogs          sync.Map // access log file or network sink config -> accessLogSink
+	reverseProxy        *httputil.ReverseProxy
+	targets             sync.Map // server address -> *url.URL
+	routeChains         sync.Map // route name -> *routeChain
+	bufferUsages        sync.Map // route name -> *bufferUsage
+	sloTrackers         sync.Map // route name -> *slo.Tracker
+	deprecationCallers  sync.Map // route name -> *callerLog
+	errorHandler        ErrorHandler
+	tracer              trace.Tracer
+	timings             *upstreamTimings
+	sizes               *messageSizes
+	cancellations       *clientCancellations
+	experiments         *experimentMetrics
+	faults              *fault.Injector
+	bandwidth           *bandwidthBuckets
+	hedgeBudgets        sync.Map // route name -> *hedgeBudget
+	concurrencyLimiters sync.Map // service name -> *fairLimiter
+	intakes             sync.Map // service name -> *serviceIntake
+	oidcRoutes          sync.Map // route name -> *oidcRoute
+	dedup               config.DedupConfig
+	dedupCalls          *dedupGroup
+	cache               *cache.Cache
+	cacheConfig         config.CacheConfig
+	limiter             *ratelimit.Limiter
+	tarpits             sync.Map // Route name -> *atomic.Int64 of the rejections held
+	pressure            *pressure.Monitor
+	loadShed            config.LoadShedConfig
+	geoResolver         geo.Resolver
+	realIP              *realip.Resolver
+	classifier          *classify.Classifier
+	sampler             *route.Sampler
+	recorder            *replay.Recorder
+	recordConfig        config.RecordConfig
+	stats               *stats.Collector
+	statsd              *telemetry.StatsD
+	logExporter         *telemetry.LogExporter
+	pool                *stats.Pool
+	dns                 *dnsCache
+	upstreamConfig      config.UpstreamConfig
+	customTransport     bool // Set by SetTransport, kept when the upstream config changes
+	pathConfig          config.PathConfig
+	smuggling           config.SmugglingProtectionConfig
+	signature           config.SignatureConfig
 }
 
+// matchResult holds the route and service selected for a request
+type matchResult struct {
+	route   *config.RouteConfig
+	service service.Service
+	variant *config.ExperimentVariant // Assigned variant of experiment routes
+	tags    map[string]string         // Set by the classification rules
+
+	// Set by handleRequest for the shared reverse proxy
+	url       *url.URL // Request URL before it is pointed at the target
+	target    *url.URL
+	transport http.RoundTripper
+	attempts  attemptLog
+}
+
+type matchContextKey struct{}
+
 // NewProxy creates a new reverse proxy instance
 func NewProxy(router route.Router) *Proxy {
-	return &Proxy{
-		router:    router,
-		transport: http.DefaultTransport,
-		tracer:    otel.Tracer("nexus.proxy"),
+	pool := stats.NewPool()
+	dns := newDNSCache()
+	p := &Proxy{
+		router:       router,
+		transport:    newPooledTransport(pool, dns),
+		reverseProxy: newReverseProxy(),
+		tracer:       otel.Tracer("nexus.proxy"),
+		dedupCalls:   newDedupGroup(),
+		cache:        cache.New(cache.DefaultMaxSize),
+		limiter:      ratelimit.NewLimiter(ratelimit.NewLocalStore()),
+		pool:         pool,
+		dns:          dns,
+		bandwidth:    newBandwidthBuckets(),
 	}
+	p.upstream = attemptTransport{next: otelhttp.NewTransport(p.transport), proxy: p, pool: pool}
+	p.reverseProxy.ErrorHandler = p.handleUpstreamError
+	// Middlewares read their settings per request, so the chain is built once
+	handler := p.statsMiddleware(p.recordMiddleware(p.shedMiddleware(p.deprecationMiddleware(p.sanitizeMiddleware(p.clientCertMiddleware(p.oidcMiddleware(p.rateLimitMiddleware(p.faultMiddleware(p.bandwidthMiddleware(p.cacheMiddleware(p.dedupMiddleware(p.intakeMiddleware(p.concurrencyMiddleware(http.HandlerFunc(p.handleRequest)))))))))))))))
+	p.handler = p.tracingMiddleware(p.sloMiddleware(p.experimentMiddleware(handler)))
+
+	return p
 }
 
 // ServeHTTP implements the http.Handler interface
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	handler := http.HandlerFunc(p.handleRequest)
-	p.tracingMiddleware(handler).ServeHTTP(w, r)
+	if !p.checkFraming(w, r) {
+		return
+	}
+	r, ok := p.normalizeRequest(w, r)
+	if !ok {
+		return
+	}
+	r = p.resolveClientIP(r)
+	w = p.sign(w, r)
+	if chains := p.middleware.Load(); chains != nil {
+		if r.TLS != nil {
+			chains.https.ServeHTTP(w, r)
+		} else {
+			chains.http.ServeHTTP(w, r)
+		}
+		return
+	}
+	p.handler.ServeHTTP(w, r)
 }
 
 // Add tracing middleware
 func (p *Proxy) tracingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Resolve the client location before matching so routes can use it
+		r, loc, located := p.locate(r)
+		r, tags := p.classify(r)
 		ctx := r.Context()
 
-		service := p.router.Match(r)
+		// Match once so tracing and forwarding agree on the selected service
+		match := p.match(r)
+		r, match = p.assignExperiment(w, r, match)
+		match.tags = tags
+		ctx = context.WithValue(r.Context(), matchContextKey{}, match)
+		info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+		if info != nil {
+			info.match = match
+		}
+
+		p.mu.RLock()
+		sampler := p.sampler
+		p.mu.RUnlock()
+		if sampler != nil {
+			sampler.Record(r)
+		}
+
+		var b balancer.Balancer
+		if match.service != nil {
+			b = match.service.Balancer()
+		}
 
 		// Create span with load balancer information
 		ctx, span := p.tracer.Start(ctx, "Proxy.Request",
 			trace.WithAttributes(
-				attribute.String("lb.strategy", p.getBalancerStrategy(service.Balancer())),
-				attribute.Int("backend.count", p.getBackendCount(service.Balancer())),
+				attribute.String("lb.strategy", p.getBalancerStrategy(b)),
+				attribute.Int("backend.count", p.getBackendCount(b)),
 			))
 		defer span.End()
+		if info != nil {
+			info.span = span.SpanContext()
+		}
+		// Deferred, aborted responses unwind with a panic
+		defer func() {
+			if clientCanceled(r) {
+				span.SetAttributes(attribute.String("result", "client_canceled"))
+			}
+		}()
+		// Backend labels replace these once a backend is selected
+		if labels := labelAttributes(match.labels("")); labels != nil {
+			span.SetAttributes(labels...)
+		}
+		if tags := tagAttributes(tags); tags != nil {
+			span.SetAttributes(tags...)
+		}
+		if located {
+			span.SetAttributes(
+				attribute.String("client.geo.country", loc.Country),
+				attribute.String("client.geo.continent", loc.Continent),
+			)
+		}
 
 		// Inject tracing context into request
 		propagator := otel.GetTextMapPropagator()
 		propagator.Inject(ctx, propagation.HeaderCarrier(r.Header))
-
-		// Create tracing client
-		traceCtx := httptrace.WithClientTrace(ctx, p.createClientTrace(span))
-		r = r.WithContext(traceCtx)
+		r = r.WithContext(ctx)
 
 		next.ServeHTTP(w, r)
 	})
 }
 
+// SetSampler sets the sampler recording the signatures of proxied requests
+func (p *Proxy) SetSampler(sampler *route.Sampler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.sampler = sampler
+}
+
+// SetGeoResolver sets the resolver used for country and continent matching
+func (p *Proxy) SetGeoResolver(resolver geo.Resolver) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.geoResolver = resolver
+}
+
+// locate stores the resolved client location in the request context
+func (p *Proxy) locate(r *http.Request) (*http.Request, geo.Location, bool) {
+	p.mu.RLock()
+	resolver := p.geoResolver
+	p.mu.RUnlock()
+
+	if resolver == nil {
+		return r, geo.Location{}, false
+	}
+	ip := geo.ClientIP(r)
+	if ip == nil {
+		return r, geo.Location{}, false
+	}
+	loc, err := resolver.Lookup(ip)
+	if err != nil {
+		return r, geo.Location{}, false
+	}
+
+	return r.WithContext(geo.NewContext(r.Context(), loc)), loc, true
+}
+
+// match selects the route and service for a request
+func (p *Proxy) match(r *http.Request) *matchResult {
+	if match, ok := r.Context().Value(matchContextKey{}).(*matchResult); ok {
+		return match
+	}
+
+	route, service := p.router.MatchRoute(r)
+	return &matchResult{route: route, service: service}
+}
+
 func (p *Proxy) getBalancerStrategy(b balancer.Balancer) string {
 	switch b.(type) {
 	case *balancer.RoundRobinBalancer:
@@ -93,67 +289,173 @@ func (p *Proxy) getBackendCount(b balancer.Balancer) int {
 	}
 }
 
-func (p *Proxy) createClientTrace(span trace.Span) *httptrace.ClientTrace {
-	return &httptrace.ClientTrace{
-		GotConn: func(connInfo httptrace.GotConnInfo) {
-			span.AddEvent("Acquired connection",
-				trace.WithAttributes(
-					attribute.Bool("reused", connInfo.Reused),
-					attribute.String("remote", connInfo.Conn.RemoteAddr().String()),
-				))
-		},
-	}
-}
-
 // handleRequest handles the request
 func (p *Proxy) handleRequest(w http.ResponseWriter, r *http.Request) {
 	// Select backend server
-	service := p.router.Match(r)
-	target, err := service.NextServer(r.Context())
-
+	match, ok := r.Context().Value(matchContextKey{}).(*matchResult)
+	if !ok {
+		match = p.match(r)
+		r = r.WithContext(context.WithValue(r.Context(), matchContextKey{}, match))
+	}
+	if match.service == nil {
+		p.handleError(w, r, route.ErrNoRoute)
+		return
+	}
+	target, err := p.selectServer(w, r, match.service)
+	if err != nil {
+		if fallback := p.fallbackService(match); fallback != nil {
+			traceFallback(r.Context(), match.service, fallback, err.Error())
+			match.service = fallback
+			target, err = p.selectServer(w, r, fallback)
+		}
+	}
 	if err != nil {
 		p.handleError(w, r, err)
 		return
 	}
+	match.attempts.selected(target)
+	defer match.attempts.release()
+	if req := stats.FromContext(r.Context()); req != nil {
+		req.SetServer(target)
+	}
 
 	// Parse target URL
-	targetURL, err := url.Parse(target)
+	match.target, err = p.targetURL(target)
 	if err != nil {
 		p.handleError(w, r, err)
 		return
 	}
 
 	// Forward request
-	proxy := httputil.NewSingleHostReverseProxy(targetURL)
-	proxy.Transport = otelhttp.NewTransport(http.DefaultTransport)
-
-	proxy.ServeHTTP(w, r)
+	match.url = r.URL
+	chain := p.routeChain(match)
+	match.transport = chain.transport
+	chain.reverseProxy.ServeHTTP(forwardingWriter(w, match.route), r)
 }
 
-// SetTransport sets a custom Transport
-func (p *Proxy) SetTransport(transport http.RoundTripper) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+// selectServer picks the backend for a request, honoring session affinity
+func (p *Proxy) selectServer(w http.ResponseWriter, r *http.Request, svc service.Service) (string, error) {
+	svcConfig := svc.Config()
+	if svcConfig == nil || svcConfig.Affinity == nil {
+		return nextServer(r, svc)
+	}
 
-	p.transport = transport
-}
+	now := time.Now()
+	if target, ok := affinityServer(svc, r, now); ok {
+		return target, nil
+	}
 
-// SetErrorHandler sets a custom error handler function
-func (p *Proxy) SetErrorHandler(handler func(http.ResponseWriter, *http.Request, error)) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+	target, err := nextServer(r, svc)
+	if err != nil {
+		return "", err
+	}
+	if cookie := affinityCookie(svcConfig, target, now); cookie != nil {
+		http.SetCookie(w, cookie)
+	}
 
-	p.errorHandler = handler
+	return target, nil
 }
 
-// handleError handles errors during the proxy process
-func (p *Proxy) handleError(w http.ResponseWriter, r *http.Request, err error) {
+// routeTransport returns the upstream transport for the matched route
+func (p *Proxy) routeTransport(match *matchResult) http.RoundTripper {
+	return p.routeChain(match).transport
+}
+
+// routeChain returns the upstream transport and reverse proxy for the
+// matched route. Chains are cached per route and rebuilt when the route, its
+// service or the base transport change
+func (p *Proxy) routeChain(match *matchResult) *routeChain {
 	p.mu.RLock()
-	defer p.mu.RUnlock()
+	upstream := p.upstream
+	generation := p.generation
+	p.mu.RUnlock()
 
-	if p.errorHandler != nil {
-		p.errorHandler(w, r, err)
-	} else {
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+	if match.route == nil {
+		return &routeChain{transport: upstream, reverseProxy: p.reverseProxy}
+	}
+	if cached, ok := p.routeChains.Load(match.route.Name); ok {
+		chain := cached.(*routeChain)
+		if chain.route == match.route && chain.service == match.service && chain.generation == generation {
+			return chain
+		}
 	}
+
+	chain := &routeChain{
+		route:        match.route,
+		service:      match.service,
+		generation:   generation,
+		transport:    p.buildRouteTransport(upstream, match),
+		reverseProxy: p.routeReverseProxy(match.route),
+	}
+	p.routeChains.Store(match.route.Name, chain)
+
+	return chain
+}
+
+// buildRouteTransport wraps the upstream transport with the route's features
+func (p *Proxy) buildRouteTransport(rt http.RoundTripper, match *matchResult) http.RoundTripper {
+	upstream := rt
+	rt = p.buildServiceTransport(upstream, match.route, match.service)
+	// After retries and hedges, so the fallback service only gets the
+	// requests the service failed for good
+	if match.route.FallbackService != "" {
+		rt = newFallbackTransport(rt, p, upstream, match.route)
+	}
+	if match.route.LocationRewrite != nil {
+		rt = newLocationTransport(rt, match.service, match.route.LocationRewrite)
+	}
+	if match.route.SubFilter != nil {
+		rt = newSubFilterTransport(rt, match.route.SubFilter)
+	}
+	// After retries and hedges so the page replaces the final response, only
+	// buffering wraps it
+	if len(match.route.ErrorPages) > 0 {
+		rt = newErrorPageTransport(rt, match.route.Name, match.route.ErrorPages)
+	}
+	if match.route.Buffering != nil && match.route.Buffering.Response {
+		rt = newBufferingTransport(rt, match.route.Buffering, p.bufferUsage(match.route.Name))
+	}
+	if match.route.Forwarding != nil {
+		rt = newForwardingTransport(rt, match.route.Forwarding)
+	}
+
+	return rt
+}
+
+// buildServiceTransport wraps the upstream transport with the route's
+// features sending requests to the servers of svc
+func (p *Proxy) buildServiceTransport(rt http.RoundTripper, route *config.RouteConfig, svc service.Service) http.RoundTripper {
+	// Innermost so retried and hedged requests carry the credentials and
+	// signatures too, signing covers the injected credentials
+	rt = newSigningTransport(rt, svc)
+	rt = newCredentialsTransport(rt, svc)
+	if route.UpstreamCompression != nil {
+		rt = newCompressionTransport(rt, route.UpstreamCompression)
+	}
+	if route.Expect != nil {
+		rt = newExpectTransport(rt, svc, route.Expect)
+	}
+	if route.Hedge != nil {
+		rt = newHedgingTransport(rt, svc, route.Hedge, p.hedgeBudget(route.Name))
+	}
+
+	return rt
+}
+
+// hedgeBudget returns the shared hedge budget of a route
+func (p *Proxy) hedgeBudget(routeName string) *hedgeBudget {
+	budget, _ := p.hedgeBudgets.LoadOrStore(routeName, &hedgeBudget{})
+	return budget.(*hedgeBudget)
+}
+
+// SetTransport sets a custom Transport, its connections aren't counted in
+// the pool statistics
+func (p *Proxy) SetTransport(transport http.RoundTripper) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.transport = transport
+	p.customTransport = true
+	p.upstream = attemptTransport{next: otelhttp.NewTransport(transport), proxy: p}
+	p.generation++
 }
@@ -2,12 +2,16 @@ package proxy
 
 import (
 	"bytes"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"sync"
 	"testing"
 	"time"
 
+	"nexus/internal/config"
+	"nexus/internal/geo"
+	"nexus/internal/route"
 	"nexus/internal/service"
 
 	"go.opentelemetry.io/otel"
@@ -242,3 +246,68 @@ func TestTracingMiddleware(t *testing.T) {
 		}
 	}
 }
+
+// staticResolver resolves every address to a fixed location
+type staticResolver struct {
+	loc geo.Location
+}
+
+func (s staticResolver) Lookup(ip net.IP) (geo.Location, error) {
+	return s.loc, nil
+}
+
+func TestProxy_GeoRouting(t *testing.T) {
+	newBackend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}))
+	}
+	eu, other := newBackend("eu"), newBackend("other")
+	defer eu.Close()
+	defer other.Close()
+
+	router := route.NewRouter([]*config.RouteConfig{
+		{Name: "eu", Match: config.RouteMatch{Path: "/shop", Continents: []string{"EU"}}, Service: "eu"},
+		{Name: "other", Match: config.RouteMatch{Path: "/shop"}, Service: "other"},
+	}, map[string]*config.ServiceConfig{
+		"eu":    {Name: "eu", Servers: []config.ServerConfig{{Address: eu.URL}}},
+		"other": {Name: "other", Servers: []config.ServerConfig{{Address: other.URL}}},
+	})
+
+	exporter := tracetest.NewInMemoryExporter()
+	p := NewProxy(router)
+	p.tracer = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)).Tracer("test")
+
+	tests := []struct {
+		location geo.Location
+		expected string
+	}{
+		{geo.Location{Country: "DE", Continent: "EU"}, "eu"},
+		{geo.Location{Country: "JP", Continent: "AS"}, "other"},
+	}
+
+	for _, tt := range tests {
+		p.SetGeoResolver(staticResolver{loc: tt.location})
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/shop", nil))
+		if rec.Body.String() != tt.expected {
+			t.Errorf("Expected %s for %s, got %s", tt.expected, tt.location.Country, rec.Body.String())
+		}
+	}
+
+	// The resolved country is recorded on the request span
+	found := false
+	for _, span := range exporter.GetSpans() {
+		if span.Name != "Proxy.Request" {
+			continue
+		}
+		for _, attr := range span.Attributes {
+			if attr.Key == "client.geo.country" && attr.Value.AsString() == "DE" {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Error("Missing client.geo.country attribute on the request span")
+	}
+}
@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"nexus/internal/classify"
+	"nexus/internal/ratelimit"
+	"nexus/internal/realip"
+)
+
+// SetRateLimitStore sets the store used to count route rate limits
+func (p *Proxy) SetRateLimitStore(store ratelimit.Store) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.limiter = ratelimit.NewLimiter(store)
+}
+
+// rateLimitMiddleware rejects requests exceeding the rate limit of their
+// route, clients far over it wait for the rejection with a tarpit
+func (p *Proxy) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match := p.match(r)
+		if match.route == nil || match.route.RateLimit == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p.mu.RLock()
+		limiter := p.limiter
+		p.mu.RUnlock()
+
+		cfg := match.route.RateLimit
+		key := match.route.Name + ":" + rateLimitKey(cfg.Key, r)
+		result, err := limiter.Allow(r.Context(), key, cfg.Requests, cfg.Window)
+		if err != nil {
+			// Fail open, an unavailable store must not take the route down
+			requestLogger(r).Error("Rate limit check failed: %v", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		reset := strconv.Itoa(int(math.Ceil(result.Reset.Seconds())))
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("X-RateLimit-Reset", reset)
+
+		if !result.Allowed {
+			if cfg.Tarpit != nil {
+				p.tarpit(r, match.route.Name, cfg.Tarpit, result)
+			}
+			w.Header().Set("Retry-After", reset)
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitKey identifies the client a request is counted against
+func rateLimitKey(key string, r *http.Request) string {
+	switch {
+	case key == "route":
+		return "route"
+	case strings.HasPrefix(key, "header:"):
+		return "header:" + r.Header.Get(strings.TrimPrefix(key, "header:"))
+	case strings.HasPrefix(key, "tag:"):
+		return "tag:" + classify.FromContext(r.Context())[strings.TrimPrefix(key, "tag:")]
+	default:
+		if ip := realip.ClientIP(r); ip.IsValid() {
+			return "ip:" + ip.String()
+		}
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		return "ip:" + host
+	}
+}
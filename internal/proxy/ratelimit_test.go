@@ -0,0 +1,186 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testResponseBody))
+	}))
+	defer backend.Close()
+
+	router := route.NewRouter([]*config.RouteConfig{
+		{
+			Name:      "limited-route",
+			Match:     config.RouteMatch{Path: "/login"},
+			Service:   "limited-service",
+			RateLimit: &config.RateLimitConfig{Requests: 2, Window: time.Minute, Key: "header:X-Api-Key"},
+		},
+	}, map[string]*config.ServiceConfig{
+		"limited-service": {
+			Name:         "limited-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+	})
+	p := NewProxy(router)
+
+	do := func(apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/login", nil)
+		req.Header.Set("X-Api-Key", apiKey)
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("alice")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("X-RateLimit-Remaining"))
+
+	assert.Equal(t, http.StatusOK, do("alice").Code)
+
+	rec = do("alice")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	// Other clients have their own window
+	assert.Equal(t, http.StatusOK, do("bob").Code)
+}
+
+func TestRateLimitKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.10:51234"
+	req.Header.Set("X-Api-Key", "secret")
+
+	assert.Equal(t, "ip:192.0.2.10", rateLimitKey("", req))
+	assert.Equal(t, "ip:192.0.2.10", rateLimitKey("ip", req))
+	assert.Equal(t, "route", rateLimitKey("route", req))
+	assert.Equal(t, "header:secret", rateLimitKey("header:X-Api-Key", req))
+}
+
+func TestRateLimit_RealIP(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testResponseBody))
+	}))
+	defer backend.Close()
+
+	router := route.NewRouter([]*config.RouteConfig{
+		{
+			Name:      "limited-route",
+			Match:     config.RouteMatch{Path: "/login"},
+			Service:   "limited-service",
+			RateLimit: &config.RateLimitConfig{Requests: 1, Window: time.Minute, Key: "ip"},
+		},
+	}, map[string]*config.ServiceConfig{
+		"limited-service": {
+			Name:         "limited-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+	})
+	p := NewProxy(router)
+	require.NoError(t, p.SetRealIP(config.RealIPConfig{TrustedProxies: []string{"10.0.0.0/8"}}))
+
+	do := func(remote, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/login", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Clients behind the same load balancer have their own window
+	assert.Equal(t, http.StatusOK, do("10.0.0.1:5000", "198.51.100.1"))
+	assert.Equal(t, http.StatusTooManyRequests, do("10.0.0.2:5000", "198.51.100.1"))
+	assert.Equal(t, http.StatusOK, do("10.0.0.1:5000", "198.51.100.2"))
+
+	// Untrusted peers can't pick the address they are counted against
+	assert.Equal(t, http.StatusOK, do("203.0.113.7:5000", "198.51.100.3"))
+	assert.Equal(t, http.StatusTooManyRequests, do("203.0.113.7:5000", "198.51.100.4"))
+}
+
+func TestRateLimit_Tarpit(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testResponseBody))
+	}))
+	defer backend.Close()
+
+	tarpitted := func(name, path string, tarpit *config.TarpitConfig) *config.RouteConfig {
+		return &config.RouteConfig{
+			Name:      name,
+			Match:     config.RouteMatch{Path: path},
+			Service:   "limited-service",
+			RateLimit: &config.RateLimitConfig{Requests: 1, Window: time.Minute, Tarpit: tarpit},
+		}
+	}
+	router := route.NewRouter([]*config.RouteConfig{
+		tarpitted("short", "/short", &config.TarpitConfig{Delay: 50 * time.Millisecond}),
+		tarpitted("long", "/long", &config.TarpitConfig{Delay: time.Minute, MaxConcurrent: 1}),
+	}, map[string]*config.ServiceConfig{
+		"limited-service": {
+			Name:         "limited-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+	})
+	p := NewProxy(router)
+
+	do := func(req *http.Request) (int, time.Duration) {
+		rec := httptest.NewRecorder()
+		start := time.Now()
+		p.ServeHTTP(rec, req)
+		return rec.Code, time.Since(start)
+	}
+
+	// Up to twice the limit is rejected right away, further requests are held
+	code, _ := do(httptest.NewRequest(http.MethodGet, "/short", nil))
+	assert.Equal(t, http.StatusOK, code)
+	code, elapsed := do(httptest.NewRequest(http.MethodGet, "/short", nil))
+	assert.Equal(t, http.StatusTooManyRequests, code)
+	assert.Less(t, elapsed, 50*time.Millisecond)
+	code, elapsed = do(httptest.NewRequest(http.MethodGet, "/short", nil))
+	assert.Equal(t, http.StatusTooManyRequests, code)
+	assert.GreaterOrEqual(t, elapsed, 50*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		do(httptest.NewRequest(http.MethodGet, "/long", nil))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	held := make(chan int)
+	go func() {
+		code, _ := do(httptest.NewRequest(http.MethodGet, "/long", nil).WithContext(ctx))
+		held <- code
+	}()
+	require.Eventually(t, func() bool {
+		value, ok := p.tarpits.Load("long")
+		return ok && value.(*atomic.Int64).Load() == 1
+	}, time.Second, 5*time.Millisecond)
+
+	// Past max_concurrent, rejections are answered right away
+	code, _ = do(httptest.NewRequest(http.MethodGet, "/long", nil))
+	assert.Equal(t, http.StatusTooManyRequests, code)
+
+	// Clients going away end the delay
+	cancel()
+	select {
+	case code := <-held:
+		assert.Equal(t, http.StatusTooManyRequests, code)
+	case <-time.After(time.Second):
+		t.Fatal("tarpitted request not released")
+	}
+}
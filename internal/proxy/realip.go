@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"net/http"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+	"nexus/internal/realip"
+)
+
+// SetRealIP sets the proxies trusted to relay the client IP of requests
+func (p *Proxy) SetRealIP(cfg config.RealIPConfig) error {
+	resolver, err := realip.New(cfg)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.realIP = resolver
+	return nil
+}
+
+// resolveClientIP stores the client IP of requests in their context, so rate
+// limits, geo matching and logs agree on it. Without trusted proxies the
+// peer address is used where needed instead
+func (p *Proxy) resolveClientIP(r *http.Request) *http.Request {
+	p.mu.RLock()
+	resolver := p.realIP
+	p.mu.RUnlock()
+
+	if resolver == nil {
+		return r
+	}
+	ip := resolver.Resolve(r)
+	if !ip.IsValid() {
+		return r
+	}
+	ctx := realip.NewContext(r.Context(), ip)
+	return r.WithContext(lg.WithFields(ctx, lg.F("client_ip", ip.String())))
+}
@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/replay"
+
+	"go.opentelemetry.io/otel"
+)
+
+// defaultRecordBodySize bounds the recorded request body when max_body_size is not set
+const defaultRecordBodySize = 64 << 10
+
+// SetRecorder sets the recorder matched requests are written to
+func (p *Proxy) SetRecorder(recorder *replay.Recorder) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.recorder = recorder
+}
+
+// SetRecordConfig sets the recorded routes and body size
+func (p *Proxy) SetRecordConfig(cfg config.RecordConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.recordConfig = cfg
+}
+
+// recordMiddleware records matched requests, with their body up to the
+// configured size and the status sent to the client, for nexus replay
+func (p *Proxy) recordMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.RLock()
+		recorder := p.recorder
+		cfg := p.recordConfig
+		p.mu.RUnlock()
+
+		match := p.match(r)
+		if recorder == nil || !cfg.Enabled || match.route == nil ||
+			(len(cfg.Routes) > 0 && !slices.Contains(cfg.Routes, match.route.Name)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		entry := replay.Entry{
+			Time:   time.Now(),
+			Route:  match.route.Name,
+			Method: r.Method,
+			URI:    r.URL.RequestURI(),
+			Host:   r.Host,
+			Header: recordedHeader(r.Header, cfg.RedactHeaders),
+		}
+		if r.Body != nil && r.Body != http.NoBody {
+			limit := cfg.MaxBodySize
+			if limit <= 0 {
+				limit = defaultRecordBodySize
+			}
+			body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+			if err != nil {
+				http.Error(w, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+			// The forwarded body continues with the bytes not read yet
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+			if int64(len(body)) > limit {
+				body = body[:limit]
+				entry.Truncated = true
+			}
+			entry.Body = body
+		}
+
+		response := &statsRecorder{ResponseWriter: w}
+		next.ServeHTTP(response, r)
+
+		entry.Status = response.status
+		if entry.Status == 0 {
+			entry.Status = http.StatusOK
+		}
+		entry.LatencyMs = float64(time.Since(entry.Time).Microseconds()) / 1000
+		if err := recorder.Record(entry); err != nil {
+			requestLogger(r).Error("Failed to record request: %v", err)
+		}
+	})
+}
+
+// recordedHeader copies the client headers without the trace context injected
+// by the proxy, redacted headers are recorded with a placeholder value
+func recordedHeader(header http.Header, redact []string) http.Header {
+	recorded := header.Clone()
+	for _, name := range otel.GetTextMapPropagator().Fields() {
+		recorded.Del(name)
+	}
+	for _, name := range redact {
+		if _, ok := recorded[http.CanonicalHeaderKey(name)]; ok {
+			recorded[http.CanonicalHeaderKey(name)] = []string{"REDACTED"}
+		}
+	}
+
+	return recorded
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/replay"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_Record(t *testing.T) {
+	var received string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		if r.URL.Path == "/api/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer backend.Close()
+
+	routes := []*config.RouteConfig{
+		{Name: "api", Match: config.RouteMatch{Path: "/api/*"}, Service: "api-service"},
+		{Name: "web", Match: config.RouteMatch{Path: "/*"}, Service: "api-service"},
+	}
+	services := map[string]*config.ServiceConfig{
+		"api-service": {
+			Name:         "api-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "record.jsonl")
+	recorder, err := replay.NewRecorder(path)
+	require.NoError(t, err)
+	p := NewProxy(route.NewRouter(routes, services))
+	p.SetRecorder(recorder)
+	p.SetRecordConfig(config.RecordConfig{
+		Enabled:       true,
+		Routes:        []string{"api"},
+		MaxBodySize:   4,
+		RedactHeaders: []string{"authorization"},
+	})
+
+	serve := func(method, target, body string) {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer token")
+		r.Header.Set("X-Test", "record")
+		p.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	serve(http.MethodPost, "/api/items?id=1", "abc")
+	serve(http.MethodPost, "/api/items", "abcdefgh")
+	// The forwarded body is complete even when the recorded one is truncated
+	assert.Equal(t, "abcdefgh", received)
+	serve(http.MethodGet, "/api/missing", "")
+	serve(http.MethodGet, "/index.html", "")
+	require.NoError(t, recorder.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var entries []replay.Entry
+	require.NoError(t, replay.Read(bytes.NewReader(data), func(entry replay.Entry) error {
+		entries = append(entries, entry)
+		return nil
+	}))
+
+	require.Len(t, entries, 3)
+	assert.Equal(t, "/api/items?id=1", entries[0].URI)
+	assert.Equal(t, []byte("abc"), entries[0].Body)
+	assert.False(t, entries[0].Truncated)
+	assert.Equal(t, http.StatusOK, entries[0].Status)
+	assert.Equal(t, "REDACTED", entries[0].Header.Get("Authorization"))
+	assert.Equal(t, "record", entries[0].Header.Get("X-Test"))
+	assert.Empty(t, entries[0].Header.Get("Traceparent"))
+
+	assert.Equal(t, []byte("abcd"), entries[1].Body)
+	assert.True(t, entries[1].Truncated)
+	assert.Equal(t, http.StatusNotFound, entries[2].Status)
+}
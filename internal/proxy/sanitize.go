@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"nexus/internal/config"
+)
+
+// defaultMaxHeaderSize is the largest header value when max_header_size is not set
+const defaultMaxHeaderSize = 8 << 10
+
+// singletonHeaders are read from their first or last value depending on the
+// parser, so repeated values can smuggle a second meaning past the proxy
+var singletonHeaders = []string{"Host", "Content-Type", "Authorization", "X-Forwarded-Host", "X-Forwarded-Proto"}
+
+// sanitizeMiddleware rejects request bodies of types the route doesn't allow
+// and sanitizes the request headers of routes with a sanitize config
+func (p *Proxy) sanitizeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match := p.match(r)
+		if match.route == nil || (len(match.route.AllowedContentTypes) == 0 && match.route.Sanitize == nil) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if types := match.route.AllowedContentTypes; len(types) > 0 && r.ContentLength != 0 {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || !matchContentType(mediaType, types) {
+				requestLogger(r).Debug("[%s] Rejected request body of type %q", r.RemoteAddr, r.Header.Get("Content-Type"))
+				http.Error(w, "Unsupported media type", http.StatusUnsupportedMediaType)
+				return
+			}
+		}
+		if cfg := match.route.Sanitize; cfg != nil {
+			if status, reason := sanitizeHeaders(r, cfg); status != 0 {
+				requestLogger(r).Debug("[%s] Rejected request: %s", r.RemoteAddr, reason)
+				http.Error(w, http.StatusText(status), status)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sanitizeHeaders checks the request headers, returning the status and reason
+// of the rejection in reject mode. Strip mode removes oversized and invalid
+// values, keeps the first value of singleton headers and trims whitespace
+func sanitizeHeaders(r *http.Request, cfg *config.SanitizeConfig) (int, string) {
+	strip := cfg.Mode == config.SanitizeStrip
+	maxSize := cfg.MaxHeaderSize
+	if maxSize == 0 {
+		maxSize = defaultMaxHeaderSize
+	}
+
+	for name, values := range r.Header {
+		kept := values[:0:0]
+		for _, value := range values {
+			switch {
+			case len(value) > maxSize:
+				if !strip {
+					return http.StatusRequestHeaderFieldsTooLarge, "oversized header " + name
+				}
+			case !validHeaderValue(value):
+				if !strip {
+					return http.StatusBadRequest, "invalid characters in header " + name
+				}
+			default:
+				kept = append(kept, strings.Trim(value, " \t"))
+			}
+		}
+		if !strip {
+			continue
+		}
+		if len(kept) == 0 {
+			r.Header.Del(name)
+		} else {
+			r.Header[name] = kept
+		}
+	}
+
+	for _, name := range singletonHeaders {
+		if values := r.Header.Values(name); len(values) > 1 {
+			if !strip {
+				return http.StatusBadRequest, "repeated header " + name
+			}
+			r.Header.Set(name, values[0])
+		}
+	}
+	// The request host left the header, a Host header still present was sent twice
+	if host := r.Header.Get("Host"); host != "" && host != r.Host {
+		if !strip {
+			return http.StatusBadRequest, "conflicting Host headers"
+		}
+		r.Header.Del("Host")
+	}
+
+	return 0, ""
+}
+
+// validHeaderValue reports whether a header value only holds printable ASCII
+// characters and tabs
+func validHeaderValue(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; (c < ' ' && c != '\t') || c > '~' {
+			return false
+		}
+	}
+	return true
+}
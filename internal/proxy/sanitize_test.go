@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_AllowedContentTypes(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	routes := []*config.RouteConfig{{
+		Name: "api", Match: config.RouteMatch{Path: "/api"}, Service: "api",
+		AllowedContentTypes: []string{"application/json", "text/*"},
+	}}
+	services := map[string]*config.ServiceConfig{
+		"api": {Name: "api", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: backend.URL}}},
+	}
+	p := NewProxy(route.NewRouter(routes, services))
+
+	tests := []struct {
+		contentType string
+		body        string
+		want        int
+	}{
+		{"application/json; charset=utf-8", "{}", http.StatusOK},
+		{"text/csv", "a,b", http.StatusOK},
+		{"application/xml", "<a/>", http.StatusUnsupportedMediaType},
+		{"", "data", http.StatusUnsupportedMediaType},
+		// Requests without a body have no type to check
+		{"", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api", strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, req)
+		assert.Equal(t, tt.want, w.Code, "content type %q", tt.contentType)
+	}
+}
+
+func TestSanitizeHeaders(t *testing.T) {
+	newRequest := func(header http.Header) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		return req
+	}
+	reject := &config.SanitizeConfig{MaxHeaderSize: 16}
+	strip := &config.SanitizeConfig{Mode: config.SanitizeStrip, MaxHeaderSize: 16}
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		status   int
+		stripped http.Header
+	}{
+		{
+			name:     "clean",
+			header:   http.Header{"Accept": {" text/html "}},
+			stripped: http.Header{"Accept": {"text/html"}},
+		},
+		{
+			name:     "oversized",
+			header:   http.Header{"Cookie": {strings.Repeat("a", 17)}, "Accept": {"*/*"}},
+			status:   http.StatusRequestHeaderFieldsTooLarge,
+			stripped: http.Header{"Accept": {"*/*"}},
+		},
+		{
+			name:     "invalid characters",
+			header:   http.Header{"X-Name": {"caf\xc3\xa9", "ok"}},
+			status:   http.StatusBadRequest,
+			stripped: http.Header{"X-Name": {"ok"}},
+		},
+		{
+			name:     "repeated singleton",
+			header:   http.Header{"Authorization": {"Bearer a", "Bearer b"}},
+			status:   http.StatusBadRequest,
+			stripped: http.Header{"Authorization": {"Bearer a"}},
+		},
+		{
+			name:     "duplicate host",
+			header:   http.Header{"Host": {"internal"}},
+			status:   http.StatusBadRequest,
+			stripped: http.Header{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _ := sanitizeHeaders(newRequest(tt.header), reject)
+			assert.Equal(t, tt.status, status)
+
+			req := newRequest(tt.header)
+			status, _ = sanitizeHeaders(req, strip)
+			require.Zero(t, status)
+			assert.Equal(t, tt.stripped, req.Header)
+		})
+	}
+}
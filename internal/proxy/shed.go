@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"nexus/internal/config"
+	"nexus/internal/pressure"
+)
+
+// shedRetryAfter is the delay in seconds suggested to shed clients
+const shedRetryAfter = 1
+
+// SetPressureMonitor sets the monitor whose pressure triggers load shedding
+func (p *Proxy) SetPressureMonitor(monitor *pressure.Monitor) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pressure = monitor
+}
+
+// SetLoadShedConfig sets the load shedding policies
+func (p *Proxy) SetLoadShedConfig(cfg config.LoadShedConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.loadShed = cfg
+}
+
+// shedMiddleware rejects a share of the requests of low priority routes while
+// the process is under resource pressure
+func (p *Proxy) shedMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.RLock()
+		monitor := p.pressure
+		cfg := p.loadShed
+		p.mu.RUnlock()
+
+		if monitor == nil || !cfg.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		percent := shedPercent(cfg.Policies, monitor.Pressure(), p.match(r).route)
+		if percent > 0 && rand.Float64()*100 < percent {
+			w.Header().Set("Retry-After", strconv.Itoa(shedRetryAfter))
+			http.Error(w, "Service overloaded", http.StatusServiceUnavailable)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// shedPercent returns the share of requests of a route to reject, the
+// strictest policy reached by the pressure applies. Best-effort routes are
+// shed by every policy and critical routes only by the policies naming them,
+// so best-effort traffic is dropped first
+func shedPercent(policies []config.LoadShedPolicy, pressure float64, route *config.RouteConfig) float64 {
+	var routeName string
+	if route != nil {
+		routeName = route.Name
+	}
+	prio := routePriority(route)
+
+	var percent float64
+	for _, policy := range policies {
+		if pressure < policy.Pressure || policy.Percent <= percent {
+			continue
+		}
+		named := slices.Contains(policy.Routes, routeName)
+		switch {
+		case prio == priorityBestEffort || named:
+		case prio == priorityCritical || len(policy.Routes) > 0:
+			continue
+		}
+		percent = policy.Percent
+	}
+
+	return percent
+}
@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/pressure"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxy_LoadShedding(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testResponseBody))
+	}))
+	defer backend.Close()
+
+	router := route.NewRouter([]*config.RouteConfig{
+		{Name: "batch", Match: config.RouteMatch{Path: "/batch/*"}, Service: "app-service"},
+		{Name: "api", Match: config.RouteMatch{Path: "/api/*"}, Service: "app-service"},
+	}, map[string]*config.ServiceConfig{
+		"app-service": {
+			Name:         "app-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+	})
+	p := NewProxy(router)
+
+	cfg := config.LoadShedConfig{
+		Enabled:    true,
+		Goroutines: 1 << 20,
+		Policies:   []config.LoadShedPolicy{{Pressure: 1, Percent: 100, Routes: []string{"batch"}}},
+	}
+	monitor := pressure.NewMonitor(cfg)
+	monitor.Check()
+	p.SetPressureMonitor(monitor)
+	p.SetLoadShedConfig(cfg)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// Below the policy pressure nothing is shed
+	assert.Equal(t, http.StatusOK, get("/batch/job").Code)
+
+	cfg.Goroutines = 1
+	monitor.SetConfig(cfg)
+	w := get("/batch/job")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, get("/api/users").Code)
+
+	cfg.Enabled = false
+	p.SetLoadShedConfig(cfg)
+	assert.Equal(t, http.StatusOK, get("/batch/job").Code)
+}
+
+func TestShedPercent(t *testing.T) {
+	policies := []config.LoadShedPolicy{
+		{Pressure: 0.8, Percent: 20},
+		{Pressure: 1, Percent: 50, Routes: []string{"batch"}},
+		{Pressure: 1.2, Percent: 10},
+		{Pressure: 2, Percent: 30, Routes: []string{"payments"}},
+	}
+
+	tests := []struct {
+		name     string
+		pressure float64
+		route    *config.RouteConfig
+		expected float64
+	}{
+		{name: "below every policy", pressure: 0.5, route: &config.RouteConfig{Name: "batch"}, expected: 0},
+		{name: "all routes", pressure: 0.9, route: &config.RouteConfig{Name: "api"}, expected: 20},
+		{name: "route policy", pressure: 1, route: &config.RouteConfig{Name: "batch"}, expected: 50},
+		{name: "other route", pressure: 1.5, route: &config.RouteConfig{Name: "api"}, expected: 20},
+		{name: "strictest policy", pressure: 1.5, route: &config.RouteConfig{Name: "batch"}, expected: 50},
+		{name: "no route", pressure: 0.9, route: nil, expected: 20},
+		{name: "best-effort route", pressure: 1, route: &config.RouteConfig{Name: "reports", Priority: config.PriorityBestEffort}, expected: 50},
+		{name: "critical route", pressure: 1.5, route: &config.RouteConfig{Name: "checkout", Priority: config.PriorityCritical}, expected: 0},
+		{name: "named critical route", pressure: 2, route: &config.RouteConfig{Name: "payments", Priority: config.PriorityCritical}, expected: 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, shedPercent(policies, tt.pressure, tt.route))
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"nexus/internal/config"
+)
+
+// Version is the nexus version reported in Via headers, set at build time
+// with -ldflags "-X nexus/internal/proxy.Version=<version>"
+var Version = "dev"
+
+// hopByHopHeaders only apply to one connection. Connection, Upgrade and TE are
+// left to the reverse proxy, which needs them for upgrades and trailers, and
+// Transfer-Encoding and Trailer to the server
+var hopByHopHeaders = []string{"Keep-Alive", "Proxy-Connection", "Proxy-Authenticate", "Proxy-Authorization"}
+
+// SetSignatureConfig sets the headers identifying the proxy and its backends
+func (p *Proxy) SetSignatureConfig(cfg config.SignatureConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.signature = cfg
+}
+
+// sign adds the proxy to the Via header of the request and returns the
+// writer applying the Server, Via and hop-by-hop policies to the response
+func (p *Proxy) sign(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	p.mu.RLock()
+	cfg := p.signature
+	p.mu.RUnlock()
+	if (cfg.Server == "" || cfg.Server == config.ServerHeaderKeep) && !cfg.Via && !cfg.StripHopByHop {
+		return w
+	}
+
+	if cfg.StripHopByHop {
+		removeHopByHop(r.Header, strings.EqualFold(r.Header.Get("Connection"), "upgrade"))
+	}
+	via := ""
+	if cfg.Via {
+		name := cfg.ViaName
+		if name == "" {
+			name = "nexus/" + Version
+		}
+		via = viaProtocol(r) + " " + name
+		r.Header.Add("Via", via)
+	}
+	return &signatureWriter{ResponseWriter: w, cfg: cfg, via: via}
+}
+
+// viaProtocol returns the protocol version of a request as written in Via
+// headers, the name is left out for HTTP
+func viaProtocol(r *http.Request) string {
+	if r.ProtoMajor >= 2 && r.ProtoMinor == 0 {
+		return strconv.Itoa(r.ProtoMajor)
+	}
+	return strconv.Itoa(r.ProtoMajor) + "." + strconv.Itoa(r.ProtoMinor)
+}
+
+// removeHopByHop removes the hop-by-hop headers and the headers listed in
+// Connection, except the ones upgrades need
+func removeHopByHop(h http.Header, upgrade bool) {
+	for _, value := range h["Connection"] {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" || strings.EqualFold(name, "close") || strings.EqualFold(name, "keep-alive") ||
+				(upgrade && strings.EqualFold(name, "upgrade")) {
+				continue
+			}
+			h.Del(name)
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		h.Del(name)
+	}
+}
+
+// signatureWriter applies the signature policies to the final response
+// headers, whichever handler writes them
+type signatureWriter struct {
+	http.ResponseWriter
+	cfg    config.SignatureConfig
+	via    string
+	signed bool
+}
+
+func (s *signatureWriter) WriteHeader(status int) {
+	// Informational responses precede the final one, except protocol switches
+	if !s.signed && (status >= 200 || status == http.StatusSwitchingProtocols) {
+		s.signed = true
+		s.signHeader(status)
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *signatureWriter) Write(b []byte) (int, error) {
+	if !s.signed {
+		s.WriteHeader(http.StatusOK)
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (s *signatureWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+func (s *signatureWriter) signHeader(status int) {
+	h := s.Header()
+	switch s.cfg.Server {
+	case "", config.ServerHeaderKeep:
+	case config.ServerHeaderStrip:
+		h.Del("Server")
+	default:
+		h.Set("Server", s.cfg.Server)
+	}
+	if s.via != "" {
+		h.Add("Via", s.via)
+	}
+	if s.cfg.StripHopByHop {
+		removeHopByHop(h, status == http.StatusSwitchingProtocols)
+		if status != http.StatusSwitchingProtocols {
+			h.Del("Upgrade")
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxy_Signature(t *testing.T) {
+	var forwarded http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Clone()
+		w.Header().Set("Server", "Apache/2.4.1")
+		w.Header().Set("Via", "1.1 cdn")
+	}))
+	defer backend.Close()
+
+	routes := []*config.RouteConfig{{Name: "api", Match: config.RouteMatch{Path: "/api"}, Service: "api"}}
+	services := map[string]*config.ServiceConfig{
+		"api": {Name: "api", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: backend.URL}}},
+	}
+	p := NewProxy(route.NewRouter(routes, services))
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, r)
+		return w
+	}
+
+	// Backend headers pass by default
+	w := serve(httptest.NewRequest(http.MethodGet, "/api", nil))
+	assert.Equal(t, "Apache/2.4.1", w.Header().Get("Server"))
+	assert.Empty(t, forwarded.Get("Via"))
+
+	p.SetSignatureConfig(config.SignatureConfig{Server: config.ServerHeaderStrip, Via: true})
+	r := httptest.NewRequest(http.MethodGet, "/api", nil)
+	r.Header.Set("Via", "1.0 edge")
+	w = serve(r)
+	assert.Empty(t, w.Header().Get("Server"))
+	assert.Equal(t, []string{"1.0 edge", "1.1 nexus/" + Version}, forwarded.Values("Via"))
+	assert.Equal(t, []string{"1.1 cdn", "1.1 nexus/" + Version}, w.Header().Values("Via"))
+
+	// Responses of the proxy itself are signed too
+	p.SetSignatureConfig(config.SignatureConfig{Server: "nexus", Via: true, ViaName: "gw"})
+	w = serve(httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, "nexus", w.Header().Get("Server"))
+	assert.Equal(t, "1.1 gw", w.Header().Get("Via"))
+
+	p.SetSignatureConfig(config.SignatureConfig{StripHopByHop: true})
+	r = httptest.NewRequest(http.MethodGet, "/api", nil)
+	r.Header.Set("Connection", "X-Session")
+	r.Header.Set("X-Session", "hop")
+	r.Header.Set("Keep-Alive", "timeout=5")
+	r.Header.Set("X-Kept", "end")
+	serve(r)
+	assert.Empty(t, r.Header.Get("X-Session"))
+	assert.Empty(t, forwarded.Get("X-Session"))
+	assert.Empty(t, forwarded.Get("Keep-Alive"))
+	assert.Equal(t, "end", forwarded.Get("X-Kept"))
+}
+
+func TestRemoveHopByHop(t *testing.T) {
+	h := http.Header{}
+	h.Set("Connection", "close, X-Trace")
+	h.Set("X-Trace", "1")
+	h.Set("Proxy-Connection", "keep-alive")
+	h.Set("Upgrade", "websocket")
+	removeHopByHop(h, false)
+	assert.Equal(t, http.Header{"Connection": {"close, X-Trace"}, "Upgrade": {"websocket"}}, h)
+
+	h = http.Header{}
+	h.Set("Connection", "Upgrade")
+	h.Set("Upgrade", "websocket")
+	removeHopByHop(h, true)
+	assert.Equal(t, "websocket", h.Get("Upgrade"))
+}
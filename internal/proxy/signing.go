@@ -0,0 +1,303 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/service"
+)
+
+const (
+	sigV4Algorithm         = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat        = "20060102T150405Z"
+	sigV4UnsignedPayload   = "UNSIGNED-PAYLOAD"
+	defaultSignatureHeader = "X-Signature"
+	defaultTimestampHeader = "X-Timestamp"
+)
+
+// signingKeys are the secrets resolved from a signing config
+type signingKeys struct {
+	cfg          *config.RequestSigningConfig
+	generation   uint64
+	accessKeyID  string
+	secret       string // Secret access key or HMAC secret
+	sessionToken string
+}
+
+// signingTransport signs requests forwarded to the service with AWS SigV4 or
+// an HMAC. It sits below the credentials so they are covered by HMAC signed
+// headers, and signs every retried and hedged attempt for its own backend
+type signingTransport struct {
+	next    http.RoundTripper
+	service service.Service
+	keys    atomic.Pointer[signingKeys]
+	now     func() time.Time
+}
+
+func newSigningTransport(next http.RoundTripper, svc service.Service) *signingTransport {
+	return &signingTransport{
+		next:    next,
+		service: svc,
+		now:     time.Now,
+	}
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	svcConfig := t.service.Config()
+	if svcConfig == nil || svcConfig.Signing == nil {
+		return t.next.RoundTrip(req)
+	}
+	cfg := svcConfig.Signing
+
+	keys, err := t.resolve(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("signing keys of service %s: %w", svcConfig.Name, err)
+	}
+
+	// A RoundTripper must not modify the request it was given
+	req = req.Clone(req.Context())
+	var payloadHash string
+	if cfg.Type == "sigv4" && cfg.UnsignedPayload {
+		payloadHash = sigV4UnsignedPayload
+	} else if payloadHash, err = hashBody(req); err != nil {
+		return nil, fmt.Errorf("signing request body: %w", err)
+	}
+
+	switch cfg.Type {
+	case "sigv4":
+		signSigV4(req, cfg, keys, payloadHash, t.now())
+	case "hmac":
+		signHMAC(req, cfg, keys, payloadHash, t.now())
+	default:
+		return nil, fmt.Errorf("unsupported signing type: %s", cfg.Type)
+	}
+
+	return t.next.RoundTrip(req)
+}
+
+// resolve returns the signing secrets, references are resolved once per
+// config instance and again after refreshed secrets changed
+func (t *signingTransport) resolve(cfg *config.RequestSigningConfig) (*signingKeys, error) {
+	generation := config.CredentialGeneration()
+	if keys := t.keys.Load(); keys != nil && keys.cfg == cfg && keys.generation == generation {
+		return keys, nil
+	}
+
+	keys := &signingKeys{cfg: cfg, generation: generation}
+	var err error
+	if cfg.Type == "sigv4" {
+		if keys.accessKeyID, err = config.ResolveCredential(cfg.AccessKeyID); err != nil {
+			return nil, err
+		}
+		if keys.secret, err = config.ResolveCredential(cfg.SecretAccessKey); err != nil {
+			return nil, err
+		}
+		if keys.sessionToken, err = config.ResolveCredential(cfg.SessionToken); err != nil {
+			return nil, err
+		}
+	} else if keys.secret, err = config.ResolveCredential(cfg.Secret); err != nil {
+		return nil, err
+	}
+	t.keys.Store(keys)
+
+	return keys, nil
+}
+
+// hashBody returns the hex SHA-256 of the request body, buffering it so it
+// can still be sent and replayed
+func hashBody(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		sum := sha256.Sum256(nil)
+		return hex.EncodeToString(sum[:]), nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// signSigV4 adds the AWS Signature Version 4 headers to the request
+func signSigV4(req *http.Request, cfg *config.RequestSigningConfig, keys *signingKeys, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(sigV4TimeFormat)
+	date := amzDate[:8]
+
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", amzDate)
+	// S3 requires the payload hash header, other services accept it unsigned
+	if cfg.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	if keys.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", keys.sessionToken)
+	}
+
+	headers := map[string]string{"host": upstreamHost(req)}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = canonicalHeaderValue(values)
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	canonical.WriteString(req.Method + "\n")
+	canonical.WriteString(sigV4Path(req.URL, cfg.Service != "s3") + "\n")
+	canonical.WriteString(sigV4Query(req.URL) + "\n")
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonical.WriteString("\n" + signedHeaders + "\n" + payloadHash)
+
+	scope := date + "/" + cfg.Region + "/" + cfg.Service + "/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonical.String()))
+	stringToSign := sigV4Algorithm + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSum(sha256.New, []byte("AWS4"+keys.secret), date)
+	key = hmacSum(sha256.New, key, cfg.Region)
+	key = hmacSum(sha256.New, key, cfg.Service)
+	key = hmacSum(sha256.New, key, "aws4_request")
+	signature := hex.EncodeToString(hmacSum(sha256.New, key, stringToSign))
+
+	req.Header.Set("Authorization", sigV4Algorithm+" Credential="+keys.accessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// signHMAC adds the timestamp and HMAC signature headers. The string to sign
+// is the method, path with query, timestamp, hex SHA-256 of the body and the
+// values of the signed headers, one per line
+func signHMAC(req *http.Request, cfg *config.RequestSigningConfig, keys *signingKeys, payloadHash string, now time.Time) {
+	header := cfg.Header
+	if header == "" {
+		header = defaultSignatureHeader
+	}
+	timestampHeader := cfg.TimestampHeader
+	if timestampHeader == "" {
+		timestampHeader = defaultTimestampHeader
+	}
+	newHash := sha256.New
+	if cfg.Algorithm == "sha512" {
+		newHash = sha512.New
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set(timestampHeader, timestamp)
+
+	var b strings.Builder
+	b.WriteString(req.Method + "\n")
+	b.WriteString(req.URL.RequestURI() + "\n")
+	b.WriteString(timestamp + "\n")
+	b.WriteString(payloadHash)
+	for _, name := range cfg.SignedHeaders {
+		value := req.Header.Get(name)
+		if strings.EqualFold(name, "Host") {
+			value = upstreamHost(req)
+		}
+		b.WriteString("\n" + value)
+	}
+
+	req.Header.Set(header, hex.EncodeToString(hmacSum(newHash, []byte(keys.secret), b.String())))
+}
+
+// upstreamHost returns the Host header sent to the backend
+func upstreamHost(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
+
+func hmacSum(newHash func() hash.Hash, key []byte, data string) []byte {
+	mac := hmac.New(newHash, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalHeaderValue joins the values of a header with commas, trimming
+// them and collapsing inner runs of spaces
+func canonicalHeaderValue(values []string) string {
+	trimmed := make([]string, len(values))
+	for i, value := range values {
+		trimmed[i] = strings.Join(strings.Fields(value), " ")
+	}
+	return strings.Join(trimmed, ",")
+}
+
+// sigV4Path returns the canonical URI, each segment encoded once for S3 and
+// twice for other services
+func sigV4Path(u *url.URL, doubleEncode bool) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	// Split the path as sent so encoded slashes stay within their segment
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segment = unescaped
+		}
+		segments[i] = sigV4Escape(segment)
+		if doubleEncode {
+			segments[i] = sigV4Escape(segments[i])
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// sigV4Query returns the canonical query string, sorted by name then value
+func sigV4Query(u *url.URL) string {
+	query := u.Query()
+	pairs := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, sigV4Escape(name)+"="+sigV4Escape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// sigV4Escape percent-encodes everything except the RFC 3986 unreserved characters
+func sigV4Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
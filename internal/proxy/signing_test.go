@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignSigV4(t *testing.T) {
+	// The get-vanilla case of the AWS SigV4 test suite
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	require.NoError(t, err)
+	cfg := &config.RequestSigningConfig{Type: "sigv4", Region: "us-east-1", Service: "service"}
+	keys := &signingKeys{accessKeyID: "AKIDEXAMPLE", secret: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	payloadHash, err := hashBody(req)
+	require.NoError(t, err)
+
+	signSigV4(req, cfg, keys, payloadHash, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"))
+}
+
+func TestSigV4Path(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/a%20b/c%2Fd?b=2&a=1&a=%20", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "/a%2520b/c%252Fd", sigV4Path(req.URL, true))
+	assert.Equal(t, "/a%20b/c%2Fd", sigV4Path(req.URL, false))
+	assert.Equal(t, "a=%20&a=1&b=2", sigV4Query(req.URL))
+}
+
+func TestProxy_RequestSigningHMAC(t *testing.T) {
+	var header http.Header
+	var body string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer backend.Close()
+
+	t.Setenv("NEXUS_TEST_SIGNING_SECRET", "s3cret")
+	routes := []*config.RouteConfig{{Name: "api", Match: config.RouteMatch{Path: "/api"}, Service: "api-service"}}
+	services := map[string]*config.ServiceConfig{
+		"api-service": {
+			Name:         "api-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+			Signing: &config.RequestSigningConfig{
+				Type:          "hmac",
+				Secret:        "env://NEXUS_TEST_SIGNING_SECRET",
+				SignedHeaders: []string{"Content-Type"},
+			},
+		},
+	}
+	p := NewProxy(route.NewRouter(routes, services))
+
+	r := httptest.NewRequest(http.MethodPost, "/api?id=1", strings.NewReader(`{"a":1}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"a":1}`, body)
+
+	timestamp := header.Get("X-Timestamp")
+	require.NotEmpty(t, timestamp)
+	bodyHash := sha256.Sum256([]byte(`{"a":1}`))
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte("POST\n/api?id=1\n" + timestamp + "\n" + hex.EncodeToString(bodyHash[:]) + "\napplication/json"))
+	assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), header.Get("X-Signature"))
+}
@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+	"nexus/internal/slo"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+)
+
+// sloMiddleware counts the requests of routes with a latency objective
+// against it, and logs when their error budget burns too fast or recovers
+func (p *Proxy) sloMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match := p.match(r)
+		if match.route == nil || match.route.SLO == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		tracker := p.sloTracker(match.route.Name, match.route.SLO)
+		start := time.Now()
+		recorder := &statsRecorder{ResponseWriter: w}
+		// Deferred so aborted requests are counted against the objective
+		defer func() {
+			status, changed := tracker.Record(tracker.Good(recorder.status, time.Since(start)))
+			if !changed {
+				return
+			}
+			logger := requestLogger(r).With(
+				lg.F("compliance", status.Compliance),
+				lg.F("burn_rate", status.BurnRate),
+				lg.F("short_burn_rate", status.ShortBurnRate),
+			)
+			if status.Alerting {
+				logger.Warn("SLO error budget burning too fast")
+			} else {
+				logger.Info("SLO error budget burn rate recovered")
+			}
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// sloTracker returns the tracker of a route, a changed objective starts over
+func (p *Proxy) sloTracker(routeName string, cfg *config.SLOConfig) *slo.Tracker {
+	if value, ok := p.sloTrackers.Load(routeName); ok {
+		if tracker := value.(*slo.Tracker); tracker.Config() == *cfg {
+			return tracker
+		}
+	}
+	tracker := slo.NewTracker(*cfg)
+	p.sloTrackers.Store(routeName, tracker)
+	return tracker
+}
+
+// registerSLOMetrics reports the compliance and burn rates of the routes
+// with an objective as OpenTelemetry metrics
+func (p *Proxy) registerSLOMetrics(meter otelmetric.Meter) error {
+	compliance, err := meter.Float64ObservableGauge("nexus.slo.compliance",
+		otelmetric.WithDescription("Share of the requests of a route meeting its latency objective over the window"),
+		otelmetric.WithUnit("1"))
+	if err != nil {
+		return err
+	}
+	burnRate, err := meter.Float64ObservableGauge("nexus.slo.burn_rate",
+		otelmetric.WithDescription("Error budget consumption of a route relative to its window, over the window (long) or its last twelfth (short)"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o otelmetric.Observer) error {
+		p.sloTrackers.Range(func(key, value any) bool {
+			status := value.(*slo.Tracker).Status()
+			route := attribute.String("route", key.(string))
+			o.ObserveFloat64(compliance, status.Compliance, otelmetric.WithAttributes(route))
+			o.ObserveFloat64(burnRate, status.BurnRate, otelmetric.WithAttributes(route, attribute.String("window", "long")))
+			o.ObserveFloat64(burnRate, status.ShortBurnRate, otelmetric.WithAttributes(route, attribute.String("window", "short")))
+			return true
+		})
+		return nil
+	}, compliance, burnRate)
+
+	return err
+}
@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestProxy_SLO(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer backend.Close()
+
+	routes := []*config.RouteConfig{
+		{
+			Name:    "api",
+			Match:   config.RouteMatch{Path: "/api/*"},
+			Service: "api-service",
+			SLO:     &config.SLOConfig{Objective: 90, Latency: time.Second},
+		},
+		{Name: "other", Match: config.RouteMatch{Path: "/other"}, Service: "api-service"},
+	}
+	p := NewProxy(route.NewRouter(routes, map[string]*config.ServiceConfig{
+		"api-service": {Name: "api-service", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: backend.URL}}},
+	}))
+	reader := sdkmetric.NewManualReader()
+	p.SetMeter(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"))
+
+	for _, path := range []string{"/api/ok", "/api/ok", "/api/ok", "/api/fail", "/other"} {
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	values := make(map[string]float64)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		if m.Name != "nexus.slo.compliance" && m.Name != "nexus.slo.burn_rate" {
+			continue
+		}
+		for _, dp := range m.Data.(metricdata.Gauge[float64]).DataPoints {
+			routeName, _ := dp.Attributes.Value(attribute.Key("route"))
+			key := m.Name + "/" + routeName.AsString()
+			if window, ok := dp.Attributes.Value(attribute.Key("window")); ok {
+				key += "/" + window.AsString()
+			}
+			values[key] = dp.Value
+		}
+	}
+
+	// A quarter of the requests failed with a 10% budget
+	assert.Len(t, values, 3)
+	assert.InDelta(t, 0.75, values["nexus.slo.compliance/api"], 1e-9)
+	assert.InDelta(t, 2.5, values["nexus.slo.burn_rate/api/long"], 1e-9)
+	assert.InDelta(t, 2.5, values["nexus.slo.burn_rate/api/short"], 1e-9)
+}
@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+)
+
+// SetSmugglingProtection sets whether requests that servers along the way may
+// frame or read differently are rejected
+func (p *Proxy) SetSmugglingProtection(cfg config.SmugglingProtectionConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.smuggling = cfg
+}
+
+// checkFraming rejects ambiguous requests with 400 and closes their
+// connection, reporting whether the request may be served. The HTTP/1 parser
+// already rejects conflicting Content-Length values and control characters
+// in the request line and headers, these checks also cover the other
+// protocols and what the parser lets through
+func (p *Proxy) checkFraming(w http.ResponseWriter, r *http.Request) bool {
+	p.mu.RLock()
+	enabled := p.smuggling.Enabled
+	p.mu.RUnlock()
+	if !enabled {
+		return true
+	}
+
+	if reason := ambiguousRequest(r); reason != "" {
+		lg.GetInstance().Debug("[%s] Rejected ambiguous request %s %s: %s", r.RemoteAddr, r.Method, r.URL.EscapedPath(), reason)
+		w.Header().Set("Connection", "close")
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return false
+	}
+	// The parser drops the Content-Length of chunked requests, a server in
+	// front that followed it instead reads the rest of the body as the next
+	// request, so the connection is not reused
+	if r.ProtoMajor == 1 && len(r.TransferEncoding) > 0 {
+		w.Header().Set("Connection", "close")
+	}
+	return true
+}
+
+// ambiguousRequest returns why a request is ambiguous, or an empty string
+func ambiguousRequest(r *http.Request) string {
+	chunked := len(r.TransferEncoding) > 0
+	if chunked && (r.ProtoMajor == 1 && r.ProtoMinor == 0) {
+		return "chunked HTTP/1.0 request"
+	}
+	if len(r.TransferEncoding) > 1 || (chunked && r.TransferEncoding[0] != "chunked") {
+		return "unsupported transfer encoding " + strings.Join(r.TransferEncoding, ", ")
+	}
+	if _, ok := r.Header["Transfer-Encoding"]; ok {
+		return "transfer encoding header"
+	}
+	if lengths := r.Header["Content-Length"]; len(lengths) > 0 {
+		if chunked {
+			return "both content length and transfer encoding"
+		}
+		if len(lengths) > 1 {
+			return "repeated content length"
+		}
+		if strings.Trim(lengths[0], "0123456789") != "" || lengths[0] == "" {
+			return "invalid content length " + lengths[0]
+		}
+	}
+	for name, values := range r.Header {
+		if strings.ContainsAny(name, "\r\n\x00") {
+			return "invalid header name"
+		}
+		for _, value := range values {
+			if strings.ContainsAny(value, "\r\n\x00") {
+				return "line break in header " + name
+			}
+		}
+	}
+	// Escaped line breaks reach backends decoding the path
+	for i := 0; i < len(r.URL.Path); i++ {
+		if c := r.URL.Path[i]; c < ' ' || c == 0x7f {
+			return "control character in path"
+		}
+	}
+	return ""
+}
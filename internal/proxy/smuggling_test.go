@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAmbiguousRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(r *http.Request)
+		want    string
+	}{
+		{name: "plain", prepare: func(r *http.Request) {}},
+		{name: "content length", prepare: func(r *http.Request) { r.Header.Set("Content-Length", "4") }},
+		{name: "chunked", prepare: func(r *http.Request) { r.TransferEncoding = []string{"chunked"} }},
+		{name: "chunked http/1.0", prepare: func(r *http.Request) {
+			r.ProtoMinor = 0
+			r.TransferEncoding = []string{"chunked"}
+		}, want: "chunked HTTP/1.0 request"},
+		{name: "gzip", prepare: func(r *http.Request) { r.TransferEncoding = []string{"gzip", "chunked"} }, want: "unsupported transfer encoding gzip, chunked"},
+		{name: "transfer encoding header", prepare: func(r *http.Request) { r.Header.Set("Transfer-Encoding", "chunked") }, want: "transfer encoding header"},
+		{name: "both", prepare: func(r *http.Request) {
+			r.TransferEncoding = []string{"chunked"}
+			r.Header.Set("Content-Length", "4")
+		}, want: "both content length and transfer encoding"},
+		{name: "repeated length", prepare: func(r *http.Request) { r.Header["Content-Length"] = []string{"4", "4"} }, want: "repeated content length"},
+		{name: "signed length", prepare: func(r *http.Request) { r.Header.Set("Content-Length", "+4") }, want: "invalid content length +4"},
+		{name: "line break", prepare: func(r *http.Request) { r.Header["X-Test"] = []string{"a\r\nX-Injected: b"} }, want: "line break in header X-Test"},
+		{name: "escaped line break", prepare: func(r *http.Request) { r.URL.Path = "/api/a\r\nb" }, want: "control character in path"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/api", nil)
+		tt.prepare(r)
+		assert.Equal(t, tt.want, ambiguousRequest(r), tt.name)
+	}
+}
+
+func TestProxy_SmugglingProtection(t *testing.T) {
+	var forwarded []string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		forwarded = append(forwarded, r.URL.Path+" "+string(body))
+	}))
+	defer backend.Close()
+
+	routes := []*config.RouteConfig{{Name: "api", Match: config.RouteMatch{Path: "/api/*"}, Service: "api"}}
+	services := map[string]*config.ServiceConfig{
+		"api": {Name: "api", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: backend.URL}}},
+	}
+	p := NewProxy(route.NewRouter(routes, services))
+	p.SetSmugglingProtection(config.SmugglingProtectionConfig{Enabled: true})
+	server := httptest.NewServer(p)
+	defer server.Close()
+
+	send := func(raw string) *http.Response {
+		conn, err := net.Dial("tcp", server.Listener.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.Write([]byte(raw))
+		require.NoError(t, err)
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	// The chunked body wins, the connection is closed after the response
+	resp := send("POST /api/a HTTP/1.1\r\nHost: nexus\r\nContent-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"3\r\nabc\r\n0\r\n\r\nGET /api/smuggled HTTP/1.1\r\nHost: nexus\r\n\r\n")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, resp.Close)
+
+	resp = send("GET /api/a%0d%0aX-Injected:%20b HTTP/1.1\r\nHost: nexus\r\n\r\n")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.True(t, resp.Close)
+
+	assert.Equal(t, []string{"/api/a abc"}, forwarded)
+}
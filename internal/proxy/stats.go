@@ -0,0 +1,203 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	lg "nexus/internal/logger"
+	"nexus/internal/stats"
+	"nexus/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+)
+
+// SetStats sets the collector counting traffic per frontend, backend and server
+func (p *Proxy) SetStats(collector *stats.Collector) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stats = collector
+}
+
+// SetStatsD sets the statsd emitter receiving request metrics
+func (p *Proxy) SetStatsD(statsd *telemetry.StatsD) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.statsd = statsd
+}
+
+// statsMiddleware counts the request in the stats collector and statsd.
+// Requests the client canceled are counted with status 499
+func (p *Proxy) statsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.RLock()
+		collector := p.stats
+		statsd := p.statsd
+		sizes := p.sizes
+		cancellations := p.cancellations
+		p.mu.RUnlock()
+
+		if collector == nil && statsd == nil && sizes == nil && cancellations == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		frontend := "http"
+		if r.TLS != nil {
+			frontend = "https"
+		}
+		match := p.match(r)
+		var routeName, backend string
+		if match.route != nil {
+			routeName = match.route.Name
+		}
+		if match.service != nil {
+			backend = match.service.Name()
+		}
+
+		var req *stats.Request
+		if collector != nil {
+			req = collector.Start(frontend, backend)
+			req.SetRoute(routeName)
+			r = r.WithContext(stats.NewContext(r.Context(), req))
+		}
+		body := &countingBody{ReadCloser: r.Body}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = body
+		}
+		recorder := &statsRecorder{ResponseWriter: w}
+
+		defer func() {
+			status := recorder.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			canceled := clientCanceled(r)
+			if canceled {
+				status = stats.StatusClientClosedRequest
+			}
+			if req != nil {
+				req.Finish(status, body.n, recorder.n)
+			}
+			if statsd != nil {
+				tags := []string{
+					"frontend:" + frontend,
+					"route:" + routeName,
+					"service:" + backend,
+					"status_class:" + strconv.Itoa(status/100) + "xx",
+				}
+				for _, tag := range tagAttributes(match.tags) {
+					tags = append(tags, string(tag.Key)+":"+tag.Value.AsString())
+				}
+				statsd.Count("requests", 1, tags...)
+				statsd.Timing("request.duration", time.Since(start), tags...)
+				statsd.Count("bytes.in", body.n, tags...)
+				statsd.Count("bytes.out", recorder.n, tags...)
+				if canceled {
+					statsd.Count("requests.client_canceled", 1, tags...)
+				}
+			}
+			if sizes != nil || (canceled && cancellations != nil) {
+				match.attempts.mu.Lock()
+				server := match.attempts.backend
+				match.attempts.mu.Unlock()
+				labels := append(labelAttributes(match.labels(server)), tagAttributes(match.tags)...)
+				if sizes != nil {
+					sizes.record(r.Context(), routeName, backend, server, body.n, recorder.n, labels)
+				}
+				if canceled && cancellations != nil {
+					cancellations.record(r.Context(), routeName, backend, server, labels)
+				}
+			}
+		}()
+
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// messageSizes records request and response body sizes per route, service
+// and backend server
+type messageSizes struct {
+	request  otelmetric.Int64Histogram
+	response otelmetric.Int64Histogram
+}
+
+func newMessageSizes(meter otelmetric.Meter) *messageSizes {
+	request, err := meter.Int64Histogram(
+		"nexus.http.request.size",
+		otelmetric.WithDescription("Size of proxied request bodies"),
+		otelmetric.WithUnit("By"),
+	)
+	if err != nil {
+		lg.GetInstance().Error("Failed to register request size metrics: %v", err)
+		return nil
+	}
+	response, err := meter.Int64Histogram(
+		"nexus.http.response.size",
+		otelmetric.WithDescription("Size of response bodies sent to clients"),
+		otelmetric.WithUnit("By"),
+	)
+	if err != nil {
+		lg.GetInstance().Error("Failed to register response size metrics: %v", err)
+		return nil
+	}
+
+	return &messageSizes{request: request, response: response}
+}
+
+func (m *messageSizes) record(ctx context.Context, route, service, server string, bytesIn, bytesOut int64, labels []attribute.KeyValue) {
+	attrs := otelmetric.WithAttributes(append([]attribute.KeyValue{
+		attribute.String("route", route),
+		attribute.String("service", service),
+		attribute.String("backend.address", server),
+	}, labels...)...)
+	m.request.Record(ctx, bytesIn, attrs)
+	m.response.Record(ctx, bytesOut, attrs)
+}
+
+// countingBody counts the request body bytes read
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// statsRecorder captures the response status and body size
+type statsRecorder struct {
+	http.ResponseWriter
+	status int
+	n      int64
+}
+
+func (s *statsRecorder) WriteHeader(status int) {
+	// Informational responses precede the final one, except protocol switches
+	if s.status == 0 && (status >= 200 || status == http.StatusSwitchingProtocols) {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statsRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.n += int64(n)
+	return n, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (s *statsRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
@@ -0,0 +1,187 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+	"nexus/internal/stats"
+	"nexus/internal/telemetry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestProxy_Stats(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(testResponseBody))
+	}))
+	defer backend.Close()
+
+	router := route.NewRouter([]*config.RouteConfig{
+		{
+			Name:    "stats-route",
+			Match:   config.RouteMatch{Path: "/items"},
+			Service: "stats-service",
+		},
+	}, map[string]*config.ServiceConfig{
+		"stats-service": {
+			Name:         "stats-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+	})
+	collector := stats.NewCollector()
+	p := NewProxy(router)
+	p.SetStats(collector)
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/items", strings.NewReader("payload")))
+	require.Equal(t, http.StatusCreated, w.Code)
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+	snapshot := collector.Snapshot()
+	frontend := snapshot.Frontends["http"]
+	assert.Equal(t, int64(2), frontend.Total)
+	assert.Equal(t, int64(1), frontend.Errors)
+
+	server := snapshot.Servers["stats-service"][backend.URL]
+	assert.Equal(t, int64(1), server.Total)
+	assert.Equal(t, int64(0), server.Current)
+	assert.Equal(t, int64(len("payload")), server.BytesIn)
+	assert.Equal(t, int64(len(testResponseBody)), server.BytesOut)
+	assert.Equal(t, int64(1), server.Responses[1])
+
+	route := snapshot.Routes["stats-route"]
+	assert.Equal(t, int64(1), route.Total)
+	assert.Equal(t, int64(len("payload")), route.BytesIn)
+	assert.Equal(t, int64(len(testResponseBody)), route.BytesOut)
+}
+
+func TestProxy_MessageSizes(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte(testResponseBody))
+	}))
+	defer backend.Close()
+
+	router := route.NewRouter([]*config.RouteConfig{
+		{
+			Name:    "sizes-route",
+			Match:   config.RouteMatch{Path: "/items"},
+			Service: "sizes-service",
+		},
+	}, map[string]*config.ServiceConfig{
+		"sizes-service": {
+			Name:         "sizes-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+	})
+	p := NewProxy(router)
+	reader := sdkmetric.NewManualReader()
+	p.SetMeter(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"))
+
+	for _, body := range []string{"payload", "larger payload"} {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	sums := make(map[string]int64)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		histogram, ok := m.Data.(metricdata.Histogram[int64])
+		if !ok {
+			continue
+		}
+		require.Len(t, histogram.DataPoints, 1)
+		point := histogram.DataPoints[0]
+		assert.Equal(t, uint64(2), point.Count)
+		routeName, _ := point.Attributes.Value("route")
+		assert.Equal(t, "sizes-route", routeName.AsString())
+		service, _ := point.Attributes.Value("service")
+		assert.Equal(t, "sizes-service", service.AsString())
+		server, _ := point.Attributes.Value("backend.address")
+		assert.Equal(t, backend.URL, server.AsString())
+		sums[m.Name] = point.Sum
+	}
+	assert.Equal(t, map[string]int64{
+		"nexus.http.request.size":  int64(len("payload") + len("larger payload")),
+		"nexus.http.response.size": int64(2 * len(testResponseBody)),
+	}, sums)
+}
+
+func TestProxy_StatsD(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testResponseBody))
+	}))
+	defer backend.Close()
+
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer server.Close()
+	statsd, err := telemetry.NewStatsD(config.StatsDConfig{
+		Enabled:       true,
+		Address:       server.LocalAddr().String(),
+		Datadog:       true,
+		FlushInterval: time.Hour,
+	})
+	require.NoError(t, err)
+	defer statsd.Close()
+
+	router := route.NewRouter([]*config.RouteConfig{
+		{
+			Name:    "stats-route",
+			Match:   config.RouteMatch{Path: "/items"},
+			Service: "stats-service",
+		},
+	}, map[string]*config.ServiceConfig{
+		"stats-service": {
+			Name:         "stats-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+	})
+	p := NewProxy(router)
+	p.SetStatsD(statsd)
+
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items", nil))
+	statsd.Flush()
+
+	buf := make([]byte, 2048)
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := server.Read(buf)
+	require.NoError(t, err)
+
+	tags := "|#frontend:http,route:stats-route,service:stats-service,status_class:2xx"
+	lines := strings.Split(string(buf[:n]), "\n")
+	require.Len(t, lines, 6)
+
+	// Upstream timings are emitted while the request is forwarded
+	upstreamTags := "|ms|#backend:" + backend.URL
+	assert.True(t, strings.HasPrefix(lines[0], "nexus.upstream.connect:"))
+	assert.True(t, strings.HasSuffix(lines[0], upstreamTags))
+	assert.True(t, strings.HasPrefix(lines[1], "nexus.upstream.ttfb:"))
+	assert.True(t, strings.HasSuffix(lines[1], upstreamTags))
+
+	lines = lines[2:]
+	assert.Equal(t, "nexus.requests:1|c"+tags, lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "nexus.request.duration:"))
+	assert.True(t, strings.HasSuffix(lines[1], "|ms"+tags))
+	assert.Equal(t, "nexus.bytes.out:"+strconv.Itoa(len(testResponseBody))+"|c"+tags, lines[3])
+}
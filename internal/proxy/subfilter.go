@@ -0,0 +1,211 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"nexus/internal/config"
+)
+
+const (
+	// defaultSubFilterMaxBodySize is the number of bytes rewritten when
+	// max_body_size is not set
+	defaultSubFilterMaxBodySize = 1 << 20
+	// subFilterMaxLineSize bounds the buffered partial line, longer lines are
+	// rewritten in pieces so a match across the cut is not replaced
+	subFilterMaxLineSize = 64 << 10
+	subFilterChunkSize   = 32 << 10
+)
+
+// subFilter is a compiled sub filter configuration
+type subFilter struct {
+	replacements []subFilterReplacement
+	contentTypes []string
+	maxBodySize  int64
+}
+
+type subFilterReplacement struct {
+	from    []byte
+	pattern *regexp.Regexp
+	to      []byte
+}
+
+func newSubFilter(cfg *config.SubFilterConfig) *subFilter {
+	f := &subFilter{maxBodySize: cfg.MaxBodySize}
+	for _, contentType := range cfg.ContentTypes {
+		f.contentTypes = append(f.contentTypes, strings.ToLower(contentType))
+	}
+	if len(f.contentTypes) == 0 {
+		f.contentTypes = []string{"text/html"}
+	}
+	if f.maxBodySize == 0 {
+		f.maxBodySize = defaultSubFilterMaxBodySize
+	}
+	for _, r := range cfg.Replacements {
+		replacement := subFilterReplacement{to: []byte(r.To)}
+		if r.Pattern != "" {
+			// Validated when the config is loaded
+			replacement.pattern = regexp.MustCompile(r.Pattern)
+		} else {
+			replacement.from = []byte(r.From)
+		}
+		f.replacements = append(f.replacements, replacement)
+	}
+
+	return f
+}
+
+// applies reports whether the response body is rewritten
+func (f *subFilter) applies(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return false
+	}
+	// Encoded bodies can't be rewritten without decoding them
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return false
+	}
+	if resp.ContentLength > f.maxBodySize {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return matchContentType(mediaType, f.contentTypes)
+}
+
+// matchContentType reports whether a media type is listed, "text/*" matches
+// every text type
+func matchContentType(mediaType string, contentTypes []string) bool {
+	for _, contentType := range contentTypes {
+		if family, ok := strings.CutSuffix(contentType, "/*"); ok {
+			if strings.HasPrefix(mediaType, family+"/") {
+				return true
+			}
+		} else if mediaType == contentType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// apply runs the replacements over a piece of the body in order
+func (f *subFilter) apply(data []byte) []byte {
+	for _, r := range f.replacements {
+		if r.pattern != nil {
+			data = r.pattern.ReplaceAll(data, r.to)
+		} else {
+			data = bytes.ReplaceAll(data, r.from, r.to)
+		}
+	}
+	return data
+}
+
+// subFilterTransport rewrites matching text responses while they stream
+type subFilterTransport struct {
+	next   http.RoundTripper
+	filter *subFilter
+}
+
+func newSubFilterTransport(next http.RoundTripper, cfg *config.SubFilterConfig) *subFilterTransport {
+	return &subFilterTransport{
+		next:   next,
+		filter: newSubFilter(cfg),
+	}
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *subFilterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || req.Method == http.MethodHead || !t.filter.applies(resp) {
+		return resp, err
+	}
+
+	// The rewritten length is not known up front, and the body is only
+	// semantically equivalent to the one a strong validator was issued for
+	resp.Body = newSubFilterReader(resp.Body, t.filter)
+	resp.ContentLength = -1
+	resp.Header.Del("Content-Length")
+	if etag := resp.Header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		resp.Header.Set("ETag", "W/"+etag)
+	}
+
+	return resp, nil
+}
+
+// subFilterReader applies a sub filter to complete lines of the body, the
+// bytes after max_body_size are passed on unchanged
+type subFilterReader struct {
+	src       io.ReadCloser
+	filter    *subFilter
+	remaining int64
+	chunk     []byte
+	pending   []byte // partial line not rewritten yet
+	out       []byte // rewritten bytes not read yet
+	err       error
+}
+
+func newSubFilterReader(src io.ReadCloser, filter *subFilter) *subFilterReader {
+	return &subFilterReader{
+		src:       src,
+		filter:    filter,
+		remaining: filter.maxBodySize,
+		chunk:     make([]byte, subFilterChunkSize),
+	}
+}
+
+// Read implements the io.Reader interface
+func (r *subFilterReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.fill()
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// fill reads the next chunk of the source into out
+func (r *subFilterReader) fill() {
+	n, err := r.src.Read(r.chunk)
+	data := r.chunk[:n]
+	r.err = err
+
+	if r.remaining <= 0 {
+		r.out = append(r.out[:0], data...)
+		return
+	}
+
+	var rest []byte
+	if int64(len(data)) > r.remaining {
+		data, rest = data[:r.remaining], data[r.remaining:]
+	}
+	r.remaining -= int64(len(data))
+
+	r.pending = append(r.pending, data...)
+	cut := bytes.LastIndexByte(r.pending, '\n') + 1
+	if err != nil || r.remaining <= 0 || (cut == 0 && len(r.pending) >= subFilterMaxLineSize) {
+		cut = len(r.pending)
+	}
+	if cut == 0 {
+		return
+	}
+
+	r.out = append(r.out[:0], r.filter.apply(r.pending[:cut])...)
+	r.out = append(r.out, rest...)
+	r.pending = append(r.pending[:0], r.pending[cut:]...)
+}
+
+// Close implements the io.Closer interface
+func (r *subFilterReader) Close() error {
+	return r.src.Close()
+}
@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubFilterReader(t *testing.T) {
+	filter := newSubFilter(&config.SubFilterConfig{
+		Replacements: []config.SubFilterReplacement{
+			{From: "http://app.internal:8080", To: "https://www.example.com"},
+			{Pattern: `internal-([a-z]+)\.svc`, To: "$1.example.com"},
+		},
+	})
+	body := "<a href=\"http://app.internal:8080/login\">\n<img src=\"//internal-cdn.svc/logo.png\">\nhttp://app.internal:8080"
+
+	// Matches spanning reads of the source are still replaced
+	reader := newSubFilterReader(io.NopCloser(iotest.OneByteReader(strings.NewReader(body))), filter)
+	out, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "<a href=\"https://www.example.com/login\">\n<img src=\"//cdn.example.com/logo.png\">\nhttps://www.example.com", string(out))
+}
+
+func TestSubFilterReader_MaxBodySize(t *testing.T) {
+	filter := newSubFilter(&config.SubFilterConfig{
+		Replacements: []config.SubFilterReplacement{{From: "a", To: "b"}},
+		MaxBodySize:  4,
+	})
+
+	reader := newSubFilterReader(io.NopCloser(strings.NewReader("aa\naa\naa")), filter)
+	out, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "bb\nba\naa", string(out))
+}
+
+func TestProxy_SubFilter(t *testing.T) {
+	var contentType, encoding string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		if encoding != "" {
+			w.Header().Set("Content-Encoding", encoding)
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"self": "http://app.internal:8080/items/1"}`))
+	}))
+	defer backend.Close()
+
+	router := route.NewRouter([]*config.RouteConfig{
+		{
+			Name:    "app",
+			Match:   config.RouteMatch{Path: "/items/*"},
+			Service: "app-service",
+			SubFilter: &config.SubFilterConfig{
+				Replacements: []config.SubFilterReplacement{{From: "http://app.internal:8080", To: "https://api.example.com"}},
+				ContentTypes: []string{"application/json", "text/*"},
+			},
+		},
+	}, map[string]*config.ServiceConfig{
+		"app-service": {
+			Name:         "app-service",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: backend.URL}},
+		},
+	})
+	p := NewProxy(router)
+
+	tests := []struct {
+		name        string
+		contentType string
+		encoding    string
+		expected    string
+		etag        string
+	}{
+		{name: "json", contentType: "application/json; charset=utf-8", expected: `{"self": "https://api.example.com/items/1"}`, etag: `W/"v1"`},
+		{name: "type family", contentType: "text/plain", expected: `{"self": "https://api.example.com/items/1"}`, etag: `W/"v1"`},
+		{name: "other type", contentType: "application/octet-stream", expected: `{"self": "http://app.internal:8080/items/1"}`, etag: `"v1"`},
+		{name: "encoded body", contentType: "application/json", encoding: "gzip", expected: `{"self": "http://app.internal:8080/items/1"}`, etag: `"v1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contentType, encoding = tt.contentType, tt.encoding
+
+			r := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+			if tt.encoding != "" {
+				// Keeps the upstream transport from decoding the body
+				r.Header.Set("Accept-Encoding", tt.encoding)
+			}
+			w := httptest.NewRecorder()
+			p.ServeHTTP(w, r)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.expected, w.Body.String())
+			assert.Equal(t, tt.etag, w.Header().Get("ETag"))
+			if tt.etag != `"v1"` {
+				assert.Empty(t, w.Header().Get("Content-Length"))
+			}
+		})
+	}
+}
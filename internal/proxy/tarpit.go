@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/ratelimit"
+)
+
+// Defaults of rate limit tarpits
+const (
+	defaultTarpitFactor        = 2
+	defaultTarpitDelay         = 10 * time.Second
+	defaultTarpitMaxConcurrent = 100
+)
+
+// tarpit holds the rejection of a client far over the rate limit of route
+// until the tarpit delay passed or the client went away. Rejections beyond
+// max_concurrent held for the route are answered right away, so a flood
+// can't pile up requests in the proxy
+func (p *Proxy) tarpit(r *http.Request, route string, cfg *config.TarpitConfig, result ratelimit.Result) {
+	factor, delay, maxConcurrent := cfg.Factor, cfg.Delay, cfg.MaxConcurrent
+	if factor == 0 {
+		factor = defaultTarpitFactor
+	}
+	if delay == 0 {
+		delay = defaultTarpitDelay
+	}
+	if maxConcurrent == 0 {
+		maxConcurrent = defaultTarpitMaxConcurrent
+	}
+	if float64(result.Hits) <= factor*float64(result.Limit) {
+		return
+	}
+
+	value, _ := p.tarpits.LoadOrStore(route, new(atomic.Int64))
+	held := value.(*atomic.Int64)
+	if held.Add(1) > int64(maxConcurrent) {
+		held.Add(-1)
+		return
+	}
+	defer held.Add(-1)
+
+	requestLogger(r).Debug("Tarpitting request %s %s for %s", r.Method, r.URL.Path, delay)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+}
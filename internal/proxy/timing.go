@@ -0,0 +1,174 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	lg "nexus/internal/logger"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Phases of an upstream request recorded by the client trace
+const (
+	phaseDNS     = "dns"
+	phaseConnect = "connect"
+	phaseTLS     = "tls"
+	phaseTTFB    = "ttfb" // From the start of the request to the first response byte
+)
+
+// upstreamTimings records the duration of upstream request phases per backend
+type upstreamTimings struct {
+	histogram otelmetric.Float64Histogram
+}
+
+func newUpstreamTimings(meter otelmetric.Meter) *upstreamTimings {
+	histogram, err := meter.Float64Histogram(
+		"nexus.upstream.phase.duration",
+		otelmetric.WithDescription("Duration of DNS lookups, connects, TLS handshakes and time to first byte of upstream requests"),
+		otelmetric.WithUnit("ms"),
+	)
+	if err != nil {
+		lg.GetInstance().Error("Failed to register upstream timing metrics: %v", err)
+		return nil
+	}
+
+	return &upstreamTimings{histogram: histogram}
+}
+
+func (u *upstreamTimings) record(ctx context.Context, backend, phase string, d time.Duration, labels []attribute.KeyValue) {
+	u.histogram.Record(ctx, float64(d)/float64(time.Millisecond), otelmetric.WithAttributes(append([]attribute.KeyValue{
+		attribute.String("backend.address", backend),
+		attribute.String("phase", phase),
+	}, labels...)...))
+}
+
+// SetMeter sets the meter recording upstream timing and message size
+// histograms, client cancellations and experiment variants, and reporting the
+// pool statistics, buffer usage and route objectives
+func (p *Proxy) SetMeter(meter otelmetric.Meter) {
+	timings := newUpstreamTimings(meter)
+	sizes := newMessageSizes(meter)
+	cancellations := newClientCancellations(meter)
+	experiments := newExperimentMetrics(meter)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.timings = timings
+	p.sizes = sizes
+	p.cancellations = cancellations
+	p.experiments = experiments
+
+	if err := registerPoolMetrics(meter, p.pool); err != nil {
+		lg.GetInstance().Error("Failed to register upstream pool metrics: %v", err)
+	}
+	if err := p.registerBufferMetrics(meter); err != nil {
+		lg.GetInstance().Error("Failed to register buffer metrics: %v", err)
+	}
+	if err := p.registerSLOMetrics(meter); err != nil {
+		lg.GetInstance().Error("Failed to register SLO metrics: %v", err)
+	}
+}
+
+// createClientTrace records the connection and latency breakdown of one
+// upstream request as span events, histograms and statsd timings. Connection
+// phases are only seen when the request opens a new connection. The labels of
+// the backend are added to the histograms. Connections of pooled requests are
+// tracked for the pool statistics. Nil is returned when nothing would record
+// the timings
+func (p *Proxy) createClientTrace(ctx context.Context, backend string, labels []attribute.KeyValue, pooled *pooledRequest) *httptrace.ClientTrace {
+	span := trace.SpanFromContext(ctx)
+	p.mu.RLock()
+	statsd := p.statsd
+	timings := p.timings
+	p.mu.RUnlock()
+
+	if !span.IsRecording() && statsd == nil && timings == nil && pooled == nil {
+		return nil
+	}
+
+	var mu sync.Mutex
+	var dnsStart, connectStart, tlsStart time.Time
+	start := time.Now()
+
+	observe := func(phase string, since time.Time, attrs ...attribute.KeyValue) {
+		d := time.Since(since)
+		span.AddEvent("Upstream "+phase, trace.WithAttributes(append([]attribute.KeyValue{
+			attribute.String("backend.address", backend),
+			attribute.Float64("duration_ms", float64(d)/float64(time.Millisecond)),
+		}, attrs...)...))
+		if timings != nil {
+			timings.record(ctx, backend, phase, d, labels)
+		}
+		if statsd != nil {
+			statsd.Timing("upstream."+phase, d, "backend:"+backend)
+		}
+	}
+
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			dnsStart = time.Now()
+			mu.Unlock()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			mu.Lock()
+			since := dnsStart
+			mu.Unlock()
+			observe(phaseDNS, since, attribute.Bool("error", info.Err != nil))
+		},
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			// Dual stack dials may race, the first attempt starts the phase
+			if connectStart.IsZero() {
+				connectStart = time.Now()
+			}
+			mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			since := connectStart
+			mu.Unlock()
+			observe(phaseConnect, since, attribute.String("net.peer.addr", addr))
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			tlsStart = time.Now()
+			mu.Unlock()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			mu.Lock()
+			since := tlsStart
+			mu.Unlock()
+			observe(phaseTLS, since, attribute.Bool("error", err != nil))
+		},
+		GotConn: func(connInfo httptrace.GotConnInfo) {
+			if pooled != nil {
+				pooled.gotConn(connInfo)
+			}
+			span.AddEvent("Acquired connection",
+				trace.WithAttributes(
+					attribute.String("backend.address", backend),
+					attribute.Bool("reused", connInfo.Reused),
+					attribute.String("remote", connInfo.Conn.RemoteAddr().String()),
+				))
+		},
+		PutIdleConn: func(err error) {
+			if pooled != nil {
+				pooled.release()
+			}
+		},
+		GotFirstResponseByte: func() {
+			observe(phaseTTFB, start)
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nexus/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestProxy_UpstreamTimings(t *testing.T) {
+	mockSvc := &MockService{
+		backend: httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(testResponseBody))
+		})),
+	}
+	defer mockSvc.Close()
+
+	p := NewProxy(&MockRouter{
+		services: map[string]service.Service{"mock": mockSvc},
+	})
+	p.SetTransport(mockSvc.backend.Client().Transport)
+	exporter := tracetest.NewInMemoryExporter()
+	p.tracer = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)).Tracer("test")
+	reader := sdkmetric.NewManualReader()
+	p.SetMeter(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"))
+
+	// The second request reuses the connection of the first
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	events := make(map[string]int)
+	for _, span := range exporter.GetSpans() {
+		if span.Name != "Proxy.Request" {
+			continue
+		}
+		for _, event := range span.Events {
+			events[event.Name]++
+		}
+	}
+	assert.Equal(t, 1, events["Upstream connect"])
+	assert.Equal(t, 1, events["Upstream tls"])
+	assert.Equal(t, 2, events["Upstream ttfb"])
+	assert.Equal(t, 2, events["Acquired connection"])
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	var histogram metricdata.Histogram[float64]
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		if m.Name == "nexus.upstream.phase.duration" {
+			histogram = m.Data.(metricdata.Histogram[float64])
+		}
+	}
+	require.NotEmpty(t, histogram.DataPoints)
+
+	counts := make(map[string]uint64)
+	for _, point := range histogram.DataPoints {
+		backend, _ := point.Attributes.Value(attribute.Key("backend.address"))
+		assert.Equal(t, mockSvc.backend.URL, backend.AsString())
+		phase, _ := point.Attributes.Value(attribute.Key("phase"))
+		counts[phase.AsString()] = point.Count
+	}
+	assert.Equal(t, map[string]uint64{"connect": 1, "tls": 1, "ttfb": 2}, counts)
+}
@@ -0,0 +1,164 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+)
+
+// Store counts hits per key in fixed windows, implementations may be shared
+// between nexus replicas
+type Store interface {
+	// Increment adds a hit to the current window of key and returns the number
+	// of hits in that window and the time left until it resets
+	Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error)
+}
+
+// Result is the outcome of a rate limit check
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Duration
+	Hits      int // Hits in the current window, this one included
+}
+
+// Limiter enforces fixed window limits on top of a store
+type Limiter struct {
+	store Store
+}
+
+// NewLimiter creates a limiter backed by the given store
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{store: store}
+}
+
+// Allow records a hit for key and reports whether it is within limit
+func (l *Limiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error) {
+	count, reset, err := l.store.Increment(ctx, key, window)
+	if err != nil {
+		return Result{}, err
+	}
+
+	remaining := limit - int(count)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return Result{
+		Allowed:   count <= int64(limit),
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     reset,
+		Hits:      int(count),
+	}, nil
+}
+
+// NewStore creates the store described by the config, Redis stores fall back
+// to local counting while Redis is unreachable
+func NewStore(cfg config.RateLimitStoreConfig) Store {
+	switch cfg.Store {
+	case "redis":
+		return NewFallbackStore(NewRedisStore(cfg.Redis), NewLocalStore())
+	default:
+		return NewLocalStore()
+	}
+}
+
+// LocalStore counts hits in memory, limits are per replica
+type LocalStore struct {
+	mu      sync.Mutex
+	windows map[string]*localWindow
+	now     func() time.Time
+	sweep   time.Time
+}
+
+type localWindow struct {
+	count   int64
+	resetAt time.Time
+}
+
+// NewLocalStore creates an in-memory store
+func NewLocalStore() *LocalStore {
+	return &LocalStore{
+		windows: make(map[string]*localWindow),
+		now:     time.Now,
+	}
+}
+
+// Increment implements the Store interface
+func (s *LocalStore) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.expire(now)
+
+	w, ok := s.windows[key]
+	if !ok || !now.Before(w.resetAt) {
+		w = &localWindow{resetAt: now.Add(window)}
+		s.windows[key] = w
+	}
+	w.count++
+
+	return w.count, w.resetAt.Sub(now), nil
+}
+
+// expire drops finished windows at most once per second, callers must hold the lock
+func (s *LocalStore) expire(now time.Time) {
+	if now.Before(s.sweep) {
+		return
+	}
+	for key, w := range s.windows {
+		if !now.Before(w.resetAt) {
+			delete(s.windows, key)
+		}
+	}
+	s.sweep = now.Add(time.Second)
+}
+
+// fallbackRetry is how long the primary store is bypassed after a failure
+const fallbackRetry = 5 * time.Second
+
+// FallbackStore uses a primary store and switches to a secondary one while
+// the primary is failing
+type FallbackStore struct {
+	mu        sync.Mutex
+	primary   Store
+	secondary Store
+	downUntil time.Time
+	now       func() time.Time
+}
+
+// NewFallbackStore creates a store that falls back to secondary on primary errors
+func NewFallbackStore(primary, secondary Store) *FallbackStore {
+	return &FallbackStore{
+		primary:   primary,
+		secondary: secondary,
+		now:       time.Now,
+	}
+}
+
+// Increment implements the Store interface
+func (s *FallbackStore) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	s.mu.Lock()
+	down := s.now().Before(s.downUntil)
+	s.mu.Unlock()
+
+	if !down {
+		count, reset, err := s.primary.Increment(ctx, key, window)
+		if err == nil {
+			return count, reset, nil
+		}
+
+		s.mu.Lock()
+		s.downUntil = s.now().Add(fallbackRetry)
+		s.mu.Unlock()
+		lg.GetInstance().Warn("Rate limit store unavailable, using local limits: %v", err)
+	}
+
+	return s.secondary.Increment(ctx, key, window)
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStore_Increment(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := NewLocalStore()
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	count, reset, err := s.Increment(ctx, "a", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	assert.Equal(t, time.Minute, reset)
+
+	now = now.Add(20 * time.Second)
+	count, reset, _ = s.Increment(ctx, "a", time.Minute)
+	assert.Equal(t, int64(2), count)
+	assert.Equal(t, 40*time.Second, reset)
+
+	count, _, _ = s.Increment(ctx, "b", time.Minute)
+	assert.Equal(t, int64(1), count)
+
+	// A new window starts once the previous one is over
+	now = now.Add(40 * time.Second)
+	count, reset, _ = s.Increment(ctx, "a", time.Minute)
+	assert.Equal(t, int64(1), count)
+	assert.Equal(t, time.Minute, reset)
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	l := NewLimiter(NewLocalStore())
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		result, err := l.Allow(ctx, "client", 3, time.Minute)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 2-i, result.Remaining)
+	}
+
+	result, err := l.Allow(ctx, "client", 3, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.Equal(t, 3, result.Limit)
+}
+
+// failingStore always fails and counts its calls
+type failingStore struct {
+	calls int
+}
+
+func (s *failingStore) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	s.calls++
+	return 0, 0, errors.New("connection refused")
+}
+
+func TestFallbackStore(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	primary := &failingStore{}
+	s := NewFallbackStore(primary, NewLocalStore())
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	count, _, err := s.Increment(ctx, "a", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	assert.Equal(t, 1, primary.calls)
+
+	// The primary is bypassed until the retry delay passes
+	count, _, _ = s.Increment(ctx, "a", time.Minute)
+	assert.Equal(t, int64(2), count)
+	assert.Equal(t, 1, primary.calls)
+
+	now = now.Add(fallbackRetry)
+	s.Increment(ctx, "a", time.Minute)
+	assert.Equal(t, 2, primary.calls)
+}
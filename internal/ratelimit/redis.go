@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/redis"
+)
+
+// incrementScript increments a counter and starts its window on the first hit
+const incrementScript = `local count = redis.call('INCR', KEYS[1])
+if count == 1 then redis.call('PEXPIRE', KEYS[1], ARGV[1]) end
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < 0 then redis.call('PEXPIRE', KEYS[1], ARGV[1]); ttl = tonumber(ARGV[1]) end
+return {count, ttl}`
+
+const defaultRedisPrefix = "nexus:ratelimit:"
+
+// RedisStore counts hits in Redis so limits are shared between replicas
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore creates a Redis backed store, connections are opened lazily
+func NewRedisStore(cfg config.RedisConfig) *RedisStore {
+	prefix := cfg.KeyPrefix
+	if prefix == "" {
+		prefix = defaultRedisPrefix
+	}
+
+	return &RedisStore{
+		client: redis.NewClient(cfg),
+		prefix: prefix,
+	}
+}
+
+// Increment implements the Store interface
+func (s *RedisStore) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	reply, err := s.client.Do(ctx, "EVAL", incrementScript, "1", s.prefix+key, strconv.FormatInt(window.Milliseconds(), 10))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return 0, 0, fmt.Errorf("unexpected redis reply: %v", reply)
+	}
+	count, ok := values[0].(int64)
+	if !ok {
+		return 0, 0, fmt.Errorf("unexpected redis count: %v", values[0])
+	}
+	ttl, ok := values[1].(int64)
+	if !ok {
+		return 0, 0, fmt.Errorf("unexpected redis ttl: %v", values[1])
+	}
+
+	return count, time.Duration(ttl) * time.Millisecond, nil
+}
@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/redis/redistest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCounterServer serves the increment script from an in-memory counter map
+func newCounterServer(t *testing.T, counters map[string]int64) *redistest.Server {
+	return redistest.NewServer(t, func(args []string) string {
+		switch args[0] {
+		case "AUTH":
+			if args[1] == "secret" {
+				return "+OK\r\n"
+			}
+			return "-WRONGPASS invalid password\r\n"
+		case "EVAL":
+			counters[args[3]]++
+			return fmt.Sprintf("*2\r\n:%d\r\n:%s\r\n", counters[args[3]], args[4])
+		default:
+			return "-ERR unknown command\r\n"
+		}
+	})
+}
+
+func TestRedisStore_Increment(t *testing.T) {
+	counters := make(map[string]int64)
+	server := newCounterServer(t, counters)
+	s := NewRedisStore(config.RedisConfig{Address: server.Addr(), Password: "secret"})
+	ctx := context.Background()
+
+	count, reset, err := s.Increment(ctx, "route:ip:192.0.2.10", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	assert.Equal(t, time.Minute, reset)
+
+	count, _, err = s.Increment(ctx, "route:ip:192.0.2.10", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// The connection is reused, so AUTH is only sent once
+	assert.Equal(t, []string{"AUTH", "EVAL", "EVAL"}, server.Commands())
+	assert.Equal(t, int64(2), counters[defaultRedisPrefix+"route:ip:192.0.2.10"])
+}
+
+func TestRedisStore_AuthFailure(t *testing.T) {
+	server := newCounterServer(t, make(map[string]int64))
+	s := NewRedisStore(config.RedisConfig{Address: server.Addr(), Password: "wrong"})
+
+	_, _, err := s.Increment(context.Background(), "key", time.Minute)
+	assert.ErrorContains(t, err, "redis auth failed")
+}
+
+func TestNewStore_RedisFallsBackToLocal(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	s := NewStore(config.RateLimitStoreConfig{Store: "redis", Redis: config.RedisConfig{Address: address}})
+	l := NewLimiter(s)
+
+	result, err := l.Allow(context.Background(), "key", 1, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	result, err = l.Allow(context.Background(), "key", 1, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+}
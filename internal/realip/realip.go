@@ -0,0 +1,191 @@
+package realip
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"nexus/internal/config"
+)
+
+// DefaultHeaders are the headers tried when the config lists none, in order
+var DefaultHeaders = []string{
+	config.RealIPHeaderForwarded,
+	config.RealIPHeaderRealIP,
+	config.RealIPHeaderForwardedFor,
+	config.RealIPHeaderCloudflare,
+}
+
+// Resolver takes the client IP of requests relayed by trusted proxies from
+// the headers they set
+type Resolver struct {
+	trusted []netip.Prefix
+	headers []string // Canonical header names, in order
+}
+
+// New compiles the real IP config, nil when no proxy is trusted
+func New(cfg config.RealIPConfig) (*Resolver, error) {
+	if len(cfg.TrustedProxies) == 0 {
+		return nil, nil
+	}
+
+	r := &Resolver{}
+	for _, entry := range cfg.TrustedProxies {
+		prefix, err := config.ParseTrustedProxy(entry)
+		if err != nil {
+			return nil, err
+		}
+		r.trusted = append(r.trusted, prefix)
+	}
+	headers := cfg.Headers
+	if len(headers) == 0 {
+		headers = DefaultHeaders
+	}
+	for _, header := range headers {
+		r.headers = append(r.headers, http.CanonicalHeaderKey(header))
+	}
+
+	return r, nil
+}
+
+// Trusted reports whether addr is a trusted proxy
+func (r *Resolver) Trusted(addr netip.Addr) bool {
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the client IP of a request. Requests from trusted proxies
+// get it from the first header set, the proxies listed in chained headers
+// are skipped from the right. Other requests, and requests without a valid
+// header, get the address of the peer, invalid when RemoteAddr isn't one
+func (r *Resolver) Resolve(req *http.Request) netip.Addr {
+	peer := PeerIP(req)
+	if r == nil || !peer.IsValid() || !r.Trusted(peer) {
+		return peer
+	}
+
+	for _, header := range r.headers {
+		values := req.Header.Values(header)
+		if len(values) == 0 {
+			continue
+		}
+
+		var ip netip.Addr
+		switch header {
+		case config.RealIPHeaderForwarded:
+			ip = r.rightmostUntrusted(forwardedFor(values))
+		case config.RealIPHeaderForwardedFor:
+			ip = r.rightmostUntrusted(splitList(values))
+		default:
+			ip = parseHop(values[0])
+		}
+		if ip.IsValid() {
+			return ip
+		}
+	}
+
+	return peer
+}
+
+// rightmostUntrusted walks the hops of a chain from the closest one and
+// returns the first that isn't a trusted proxy, the leftmost hop when all
+// are trusted. Invalid hops can't be attributed, they void the chain
+func (r *Resolver) rightmostUntrusted(hops []string) netip.Addr {
+	var ip netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip = parseHop(hops[i])
+		if !ip.IsValid() {
+			return netip.Addr{}
+		}
+		if !r.Trusted(ip) {
+			return ip
+		}
+	}
+	return ip
+}
+
+// forwardedFor returns the for parameters of the elements of Forwarded
+// headers, see RFC 7239
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, element := range splitList(values) {
+		for _, pair := range strings.Split(element, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(key, "for") {
+				hops = append(hops, value)
+			}
+		}
+	}
+	return hops
+}
+
+// splitList splits comma separated header values
+func splitList(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// parseHop parses an address of a forwarding header, with an optional port
+// and the quotes and brackets of Forwarded
+func parseHop(value string) netip.Addr {
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+	if addr, err := netip.ParseAddr(value); err == nil {
+		return addr.Unmap()
+	}
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(value, "[]"))
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+// PeerIP returns the address of the connecting peer
+func PeerIP(req *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the resolved client IP
+func NewContext(ctx context.Context, ip netip.Addr) context.Context {
+	return context.WithValue(ctx, contextKey{}, ip)
+}
+
+// FromContext returns the client IP stored in the context
+func FromContext(ctx context.Context) (netip.Addr, bool) {
+	ip, ok := ctx.Value(contextKey{}).(netip.Addr)
+	return ip, ok
+}
+
+// ClientIP returns the client IP of a request, the resolved one when the
+// proxy stored it in the context and the peer address otherwise
+func ClientIP(req *http.Request) netip.Addr {
+	if ip, ok := FromContext(req.Context()); ok {
+		return ip
+	}
+	return PeerIP(req)
+}
@@ -0,0 +1,102 @@
+package realip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  []string // Config headers, the defaults when empty
+		remote   string
+		request  map[string][]string
+		expected string
+	}{
+		{
+			name:     "untrusted peer keeps its address",
+			remote:   "203.0.113.7:5000",
+			request:  map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			expected: "203.0.113.7",
+		},
+		{
+			name:     "trusted peer without headers",
+			remote:   "10.0.0.1:5000",
+			expected: "10.0.0.1",
+		},
+		{
+			name:     "forwarded for skips trusted hops",
+			remote:   "10.0.0.1:5000",
+			request:  map[string][]string{"X-Forwarded-For": {"198.51.100.1, 203.0.113.9", "10.0.0.2"}},
+			expected: "203.0.113.9",
+		},
+		{
+			name:     "forwarded for of trusted hops only",
+			remote:   "10.0.0.1:5000",
+			request:  map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			expected: "10.0.0.3",
+		},
+		{
+			name:     "forwarded takes precedence",
+			remote:   "10.0.0.1:5000",
+			request:  map[string][]string{"Forwarded": {`for="[2001:db8::17]:4711";proto=https, for=10.0.0.2`}, "X-Real-Ip": {"198.51.100.1"}},
+			expected: "2001:db8::17",
+		},
+		{
+			name:     "unknown forwarded hop falls back to the next header",
+			remote:   "10.0.0.1:5000",
+			request:  map[string][]string{"Forwarded": {"for=unknown"}, "X-Real-Ip": {"198.51.100.1"}},
+			expected: "198.51.100.1",
+		},
+		{
+			name:     "configured header order",
+			headers:  []string{"cf-connecting-ip", "x-forwarded-for"},
+			remote:   "10.0.0.1:5000",
+			request:  map[string][]string{"X-Forwarded-For": {"198.51.100.1"}, "Cf-Connecting-Ip": {"::ffff:203.0.113.5"}},
+			expected: "203.0.113.5",
+		},
+		{
+			name:     "headers not configured are ignored",
+			headers:  []string{"X-Real-IP"},
+			remote:   "10.0.0.1:5000",
+			request:  map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			expected: "10.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver, err := New(config.RealIPConfig{TrustedProxies: []string{"10.0.0.0/8", "::1"}, Headers: tt.headers})
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			for name, values := range tt.request {
+				req.Header[name] = values
+			}
+			assert.Equal(t, netip.MustParseAddr(tt.expected), resolver.Resolve(req))
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	resolver, err := New(config.RealIPConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, resolver)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "[::1]:5000"
+	req.Header.Set("X-Real-IP", "198.51.100.1")
+	assert.Equal(t, netip.MustParseAddr("::1"), resolver.Resolve(req))
+	assert.Equal(t, netip.MustParseAddr("::1"), ClientIP(req))
+
+	req = req.WithContext(NewContext(req.Context(), netip.MustParseAddr("198.51.100.1")))
+	assert.Equal(t, netip.MustParseAddr("198.51.100.1"), ClientIP(req))
+}
@@ -0,0 +1,200 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"nexus/internal/config"
+)
+
+const (
+	defaultTimeout = 100 * time.Millisecond
+	maxIdleConns   = 16
+)
+
+// Error is an error reply sent by the server
+type Error string
+
+func (e Error) Error() string {
+	return "redis: " + string(e)
+}
+
+// Client is a minimal RESP client with a small connection pool
+type Client struct {
+	address  string
+	password string // password or reference
+	db       int
+	timeout  time.Duration
+	idle     chan *conn
+}
+
+// conn is a single RESP connection
+type conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewClient creates a Redis client, connections are opened lazily
+func NewClient(cfg config.RedisConfig) *Client {
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	return &Client{
+		address:  cfg.Address,
+		password: cfg.Password,
+		db:       cfg.DB,
+		timeout:  timeout,
+		idle:     make(chan *conn, maxIdleConns),
+	}
+}
+
+// Do runs a command on a pooled connection and returns its reply, replies are
+// strings, int64, nil or []interface{} of those
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	cn.conn.SetDeadline(deadline)
+
+	reply, err := cn.command(args...)
+	var redisErr Error
+	if err != nil && !errors.As(err, &redisErr) {
+		// The connection state is unknown after I/O errors
+		cn.conn.Close()
+		return nil, err
+	}
+	c.put(cn)
+
+	return reply, err
+}
+
+// get returns an idle connection or dials a new one
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	select {
+	case cn := <-c.idle:
+		return cn, nil
+	default:
+	}
+
+	dialer := net.Dialer{Timeout: c.timeout}
+	nc, err := dialer.DialContext(ctx, "tcp", c.address)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{conn: nc, reader: bufio.NewReader(nc)}
+	nc.SetDeadline(time.Now().Add(c.timeout))
+
+	if c.password != "" {
+		// Resolved per connection so rotated passwords are picked up
+		password, err := config.ResolveCredential(c.password)
+		if err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("redis password: %w", err)
+		}
+		if _, err := cn.command("AUTH", password); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("redis auth failed: %w", err)
+		}
+	}
+	if c.db != 0 {
+		if _, err := cn.command("SELECT", strconv.Itoa(c.db)); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("redis select failed: %w", err)
+		}
+	}
+
+	return cn, nil
+}
+
+// put returns a connection to the idle pool
+func (c *Client) put(cn *conn) {
+	select {
+	case c.idle <- cn:
+	default:
+		cn.conn.Close()
+	}
+}
+
+// command writes a RESP array of bulk strings and reads the reply
+func (c *conn) command(args ...string) (interface{}, error) {
+	if _, err := c.conn.Write(AppendCommand(nil, args...)); err != nil {
+		return nil, err
+	}
+
+	return ReadReply(c.reader)
+}
+
+// AppendCommand encodes a command as a RESP array of bulk strings
+func AppendCommand(buf []byte, args ...string) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+
+	return buf
+}
+
+// ReadReply parses a single RESP reply
+func ReadReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("invalid redis reply: %q", line)
+	}
+	prefix, payload := line[0], line[1:len(line)-2]
+
+	switch prefix {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, Error(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			if values[i], err = ReadReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("invalid redis reply: %q", line)
+	}
+}
@@ -0,0 +1,75 @@
+package redis_test
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/redis"
+	"nexus/internal/redis/redistest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected interface{}
+		err      string
+	}{
+		{"SimpleString", "+OK\r\n", "OK", ""},
+		{"Integer", ":42\r\n", int64(42), ""},
+		{"BulkString", "$5\r\nhello\r\n", "hello", ""},
+		{"NilBulkString", "$-1\r\n", nil, ""},
+		{"Array", "*2\r\n:1\r\n$1\r\na\r\n", []interface{}{int64(1), "a"}, ""},
+		{"Error", "-ERR boom\r\n", nil, "redis: ERR boom"},
+		{"Invalid", "?\r\n", nil, "invalid redis reply"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply, err := redis.ReadReply(bufio.NewReader(strings.NewReader(tt.input)))
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, reply)
+		})
+	}
+}
+
+func TestAppendCommand(t *testing.T) {
+	assert.Equal(t, "*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n", string(redis.AppendCommand(nil, "GET", "key")))
+}
+
+func TestClient_Do(t *testing.T) {
+	server := redistest.NewServer(t, func(args []string) string {
+		switch args[0] {
+		case "SELECT":
+			return "+OK\r\n"
+		case "GET":
+			return "$5\r\nvalue\r\n"
+		default:
+			return "-ERR unknown command\r\n"
+		}
+	})
+	c := redis.NewClient(config.RedisConfig{Address: server.Addr(), DB: 2})
+
+	reply, err := c.Do(context.Background(), "GET", "key")
+	require.NoError(t, err)
+	assert.Equal(t, "value", reply)
+
+	// Error replies keep the connection usable
+	_, err = c.Do(context.Background(), "HELLO")
+	var redisErr redis.Error
+	assert.ErrorAs(t, err, &redisErr)
+
+	_, err = c.Do(context.Background(), "GET", "key")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SELECT", "GET", "HELLO", "GET"}, server.Commands())
+}
@@ -0,0 +1,91 @@
+package redistest
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"testing"
+
+	"nexus/internal/redis"
+)
+
+// Handler answers a command with a raw RESP reply, e.g. "+OK\r\n"
+type Handler func(args []string) string
+
+// Server is a fake Redis server for tests, commands are handled one at a time
+type Server struct {
+	mu       sync.Mutex
+	listener net.Listener
+	handler  Handler
+	commands [][]string
+}
+
+// NewServer starts a fake Redis server that is closed when the test ends
+func NewServer(t *testing.T, handler Handler) *Server {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	s := &Server{listener: listener, handler: handler}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+// Addr returns the server address
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Commands returns the names of the received commands
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.commands))
+	for _, args := range s.commands {
+		names = append(names, args[0])
+	}
+	return names
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	for {
+		reply, err := redis.ReadReply(reader)
+		if err != nil {
+			return
+		}
+		values, ok := reply.([]interface{})
+		if !ok || len(values) == 0 {
+			return
+		}
+		args := make([]string, len(values))
+		for i, v := range values {
+			args[i], _ = v.(string)
+		}
+
+		s.mu.Lock()
+		s.commands = append(s.commands, args)
+		out := s.handler(args)
+		s.mu.Unlock()
+
+		if _, err := conn.Write([]byte(out)); err != nil {
+			return
+		}
+	}
+}
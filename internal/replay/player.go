@@ -0,0 +1,200 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nexus/internal/bench"
+)
+
+// maxMismatches bounds the mismatches kept for the report
+const maxMismatches = 20
+
+// Options configures a replay run
+type Options struct {
+	Target       string        // Base URL of the target
+	Routes       []string      // Replayed routes, every route when empty
+	Connections  int           // Concurrent clients (default 1)
+	PreserveHost bool          // Send the recorded Host instead of the target host
+	Timeout      time.Duration // Request timeout (default 10s)
+}
+
+// Mismatch is a replayed request whose status differs from the recorded one
+type Mismatch struct {
+	Method   string
+	URI      string
+	Recorded int
+	Replayed int // 0 on transport errors
+}
+
+// Result summarizes a replay run
+type Result struct {
+	Total      int // Replayed requests
+	Matched    int // Requests getting the recorded status
+	Skipped    int // Requests of other routes or with truncated bodies
+	Mismatched int
+	Errors     int // Transport errors, counted as mismatches too
+	Duration   time.Duration
+	P50        time.Duration
+	P99        time.Duration
+	Mismatches []Mismatch // The first mismatches
+}
+
+// Play sends the recorded requests read from rd to the target and compares
+// the response statuses with the recorded ones. Requests are sent in recorded
+// order, spread over the connections
+func Play(ctx context.Context, rd io.Reader, opts Options) (Result, error) {
+	if opts.Target == "" {
+		return Result{}, errors.New("target is required")
+	}
+	if opts.Connections <= 0 {
+		opts.Connections = 1
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	target := strings.TrimSuffix(opts.Target, "/")
+
+	client := &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &http.Transport{
+			MaxIdleConns:        opts.Connections,
+			MaxIdleConnsPerHost: opts.Connections,
+		},
+		// Redirects are compared as recorded
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		result    Result
+		latencies []time.Duration
+		entries   = make(chan Entry)
+	)
+	for i := 0; i < opts.Connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range entries {
+				start := time.Now()
+				status := send(ctx, client, target, entry, opts.PreserveHost)
+				latency := time.Since(start)
+				// Requests interrupted by cancelling the run are not counted
+				if status == 0 && ctx.Err() != nil {
+					continue
+				}
+
+				mu.Lock()
+				result.Total++
+				latencies = append(latencies, latency)
+				if status == entry.Status {
+					result.Matched++
+					mu.Unlock()
+					continue
+				}
+				if status == 0 {
+					result.Errors++
+				}
+				result.Mismatched++
+				if len(result.Mismatches) < maxMismatches {
+					result.Mismatches = append(result.Mismatches, Mismatch{
+						Method:   entry.Method,
+						URI:      entry.URI,
+						Recorded: entry.Status,
+						Replayed: status,
+					})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	start := time.Now()
+	err := Read(rd, func(entry Entry) error {
+		if entry.Truncated || (len(opts.Routes) > 0 && !slices.Contains(opts.Routes, entry.Route)) {
+			mu.Lock()
+			result.Skipped++
+			mu.Unlock()
+			return nil
+		}
+		select {
+		case entries <- entry:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(entries)
+	wg.Wait()
+	if errors.Is(err, context.Canceled) {
+		// Interrupting the run still reports the requests replayed so far
+		err = nil
+	}
+
+	result.Duration = time.Since(start)
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	result.P50 = bench.Percentile(latencies, 0.50)
+	result.P99 = bench.Percentile(latencies, 0.99)
+
+	return result, err
+}
+
+// send replays one entry and returns the response status, 0 on transport errors
+func send(ctx context.Context, client *http.Client, target string, entry Entry, preserveHost bool) int {
+	req, err := http.NewRequestWithContext(ctx, entry.Method, target+entry.URI, bytes.NewReader(entry.Body))
+	if err != nil {
+		return 0
+	}
+	for name, values := range entry.Header {
+		req.Header[name] = values
+	}
+	if preserveHost {
+		req.Host = entry.Host
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0
+	}
+	defer resp.Body.Close()
+
+	// Read the body so the connection is reused
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return 0
+	}
+
+	return resp.StatusCode
+}
+
+// Print writes a human readable report of the result
+func (r Result) Print(w io.Writer) {
+	fmt.Fprintf(w, "Requests:      %d replayed, %d skipped\n", r.Total, r.Skipped)
+	fmt.Fprintf(w, "Status:        %d matched, %d mismatched (%d transport errors)\n", r.Matched, r.Mismatched, r.Errors)
+	fmt.Fprintf(w, "Duration:      %v\n", r.Duration.Round(time.Millisecond))
+	fmt.Fprintf(w, "Latency:       p50 %v, p99 %v\n", r.P50, r.P99)
+	for _, m := range r.Mismatches {
+		replayed := fmt.Sprint(m.Replayed)
+		if m.Replayed == 0 {
+			replayed = "transport error"
+		}
+		fmt.Fprintf(w, "Mismatch:      %s %s recorded %d, replayed %s\n", m.Method, m.URI, m.Recorded, replayed)
+	}
+	if r.Mismatched > len(r.Mismatches) {
+		fmt.Fprintf(w, "               ... %d more\n", r.Mismatched-len(r.Mismatches))
+	}
+}
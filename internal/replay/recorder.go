@@ -0,0 +1,106 @@
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry is a recorded request with the status the client received
+type Entry struct {
+	Time      time.Time   `json:"time"`
+	Route     string      `json:"route"`
+	Method    string      `json:"method"`
+	URI       string      `json:"uri"` // Path and query as sent by the client
+	Host      string      `json:"host"`
+	Header    http.Header `json:"header"`
+	Body      []byte      `json:"body,omitempty"`
+	Truncated bool        `json:"truncated,omitempty"` // The body exceeded the recorded size
+	Status    int         `json:"status"`
+	LatencyMs float64     `json:"latency_ms"`
+}
+
+// Recorder appends entries to a file, one JSON object per line
+type Recorder struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// NewRecorder opens the file entries are appended to, creating it if needed
+func NewRecorder(path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening record file: %w", err)
+	}
+
+	return &Recorder{path: path, file: file}, nil
+}
+
+// Record appends an entry, each entry is written in a single write so
+// concurrent processes appending to the same file don't interleave lines
+func (r *Recorder) Record(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err = r.file.Write(line)
+	return err
+}
+
+// Reopen replaces the record file by a new one at the same path, after it
+// was moved away by log rotation
+func (r *Recorder) Reopen() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("reopening record file: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous := r.file
+	r.file = file
+	return previous.Close()
+}
+
+// Close closes the record file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.file.Close()
+}
+
+// Read calls fn for each entry in a record, in recorded order
+func Read(rd io.Reader, fn func(Entry) error) error {
+	scanner := bufio.NewScanner(rd)
+	// Lines hold base64 encoded bodies
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
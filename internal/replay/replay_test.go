@@ -0,0 +1,106 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "record.jsonl")
+	recorder, err := NewRecorder(path)
+	require.NoError(t, err)
+	require.NoError(t, recorder.Record(Entry{Route: "api", Method: http.MethodPost, URI: "/api?a=1", Body: []byte("hello"), Status: 201}))
+	require.NoError(t, recorder.Record(Entry{Route: "web", Method: http.MethodGet, URI: "/", Status: 200}))
+	require.NoError(t, recorder.Close())
+
+	// Reopening appends to the record
+	recorder, err = NewRecorder(path)
+	require.NoError(t, err)
+	require.NoError(t, recorder.Record(Entry{Route: "web", Method: http.MethodGet, URI: "/about", Status: 404}))
+	require.NoError(t, recorder.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var entries []Entry
+	require.NoError(t, Read(f, func(entry Entry) error {
+		entries = append(entries, entry)
+		return nil
+	}))
+
+	require.Len(t, entries, 3)
+	assert.Equal(t, "/api?a=1", entries[0].URI)
+	assert.Equal(t, []byte("hello"), entries[0].Body)
+	assert.Equal(t, 201, entries[0].Status)
+	assert.Equal(t, "/about", entries[2].URI)
+}
+
+func TestRead_InvalidLine(t *testing.T) {
+	err := Read(bytes.NewBufferString("{\"uri\":\"/\"}\nnot json\n"), func(Entry) error { return nil })
+	assert.ErrorContains(t, err, "line 2")
+}
+
+func TestPlay(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	var hosts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		hosts = append(hosts, r.Host)
+		mu.Unlock()
+		assert.Equal(t, "replay", r.Header.Get("X-Test"))
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var record bytes.Buffer
+	for _, line := range []string{
+		`{"route":"api","method":"POST","uri":"/api","host":"www.example.com","header":{"X-Test":["replay"]},"body":"aGVsbG8=","status":200}`,
+		`{"route":"api","method":"GET","uri":"/missing","host":"www.example.com","header":{"X-Test":["replay"]},"status":200}`,
+		`{"route":"api","method":"POST","uri":"/api","host":"www.example.com","body":"aGVs","truncated":true,"status":200}`,
+		`{"route":"web","method":"GET","uri":"/","host":"www.example.com","status":200}`,
+	} {
+		record.WriteString(line + "\n")
+	}
+
+	result, err := Play(context.Background(), &record, Options{
+		Target:       server.URL,
+		Routes:       []string{"api"},
+		PreserveHost: true,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, result.Total)
+	assert.Equal(t, 1, result.Matched)
+	assert.Equal(t, 1, result.Mismatched)
+	assert.Equal(t, 2, result.Skipped)
+	assert.Equal(t, []Mismatch{{Method: http.MethodGet, URI: "/missing", Recorded: 200, Replayed: 404}}, result.Mismatches)
+	assert.Equal(t, []string{"hello", ""}, bodies)
+	assert.Equal(t, []string{"www.example.com", "www.example.com"}, hosts)
+}
+
+func TestPlay_TransportError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	record := bytes.NewBufferString(`{"route":"api","method":"GET","uri":"/","status":200}` + "\n")
+	result, err := Play(context.Background(), record, Options{Target: server.URL})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, result.Mismatched)
+	assert.Equal(t, 1, result.Errors)
+}
@@ -0,0 +1,210 @@
+package route
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"nexus/internal/config"
+)
+
+// DefaultBodyMatchSize is the number of body bytes inspected when max_size is not set
+const DefaultBodyMatchSize = 4 << 10
+
+// bodyMatcher matches a bounded prefix of the request body
+type bodyMatcher struct {
+	maxSize    int64
+	jsonField  string
+	value      string
+	soapAction string
+	pattern    *regexp.Regexp
+}
+
+// newBodyMatcher compiles a body match condition, nil when none is configured
+func newBodyMatcher(match *config.BodyMatch) *bodyMatcher {
+	if match == nil {
+		return nil
+	}
+
+	m := &bodyMatcher{
+		maxSize:    match.MaxSize,
+		jsonField:  match.JSONField,
+		value:      match.Value,
+		soapAction: match.SOAPAction,
+	}
+	if m.maxSize <= 0 {
+		m.maxSize = DefaultBodyMatchSize
+	}
+	if match.Pattern != "" {
+		// Patterns are validated when the config is loaded
+		m.pattern, _ = regexp.Compile(match.Pattern)
+	}
+
+	return m
+}
+
+// match inspects the body prefix, the body stays intact for forwarding
+func (m *bodyMatcher) match(req *http.Request) bool {
+	// SOAP 1.1 and 1.2 carry the action outside the body
+	if m.soapAction != "" {
+		if action := soapActionHeader(req); action != "" {
+			return action == m.soapAction
+		}
+	}
+
+	prefix, complete, err := peekBody(req, m.maxSize)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case m.jsonField != "":
+		// A truncated document can't be decoded, oversized bodies never match
+		if !complete {
+			return false
+		}
+		return matchJSONField(prefix, m.jsonField, m.value)
+	case m.soapAction != "":
+		return soapBodyOperation(prefix) == m.soapAction
+	case m.pattern != nil:
+		return m.pattern.Match(prefix)
+	default:
+		return true
+	}
+}
+
+// peekedBody replays the inspected prefix before the rest of the original body
+type peekedBody struct {
+	buf    []byte
+	off    int
+	rest   io.Reader
+	closer io.Closer
+	eof    bool
+}
+
+func (b *peekedBody) Read(p []byte) (int, error) {
+	if b.off < len(b.buf) {
+		n := copy(p, b.buf[b.off:])
+		b.off += n
+		return n, nil
+	}
+	if b.eof {
+		return 0, io.EOF
+	}
+	return b.rest.Read(p)
+}
+
+func (b *peekedBody) Close() error {
+	return b.closer.Close()
+}
+
+// peekBody returns up to n leading body bytes without consuming them, complete
+// reports whether the whole body fits in the prefix
+func peekBody(req *http.Request, n int64) ([]byte, bool, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, true, nil
+	}
+
+	pb, ok := req.Body.(*peekedBody)
+	if !ok {
+		pb = &peekedBody{rest: req.Body, closer: req.Body}
+		req.Body = pb
+	}
+	if pb.off > 0 {
+		return nil, false, fmt.Errorf("request body already consumed")
+	}
+
+	// Read one byte past the limit to tell whether the body is larger
+	if !pb.eof && int64(len(pb.buf)) <= n {
+		more, err := io.ReadAll(io.LimitReader(pb.rest, n+1-int64(len(pb.buf))))
+		pb.buf = append(pb.buf, more...)
+		if err != nil {
+			return nil, false, err
+		}
+		if int64(len(pb.buf)) <= n {
+			pb.eof = true
+		}
+	}
+
+	if int64(len(pb.buf)) > n {
+		return pb.buf[:n], false, nil
+	}
+	return pb.buf, true, nil
+}
+
+// matchJSONField checks a dotted field path, an empty value only requires presence
+func matchJSONField(data []byte, path, value string) bool {
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return false
+	}
+
+	current := doc
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if current, ok = obj[key]; !ok {
+			return false
+		}
+	}
+	if value == "" {
+		return true
+	}
+
+	switch v := current.(type) {
+	case string:
+		return v == value
+	case json.Number:
+		return v.String() == value
+	case bool:
+		return fmt.Sprint(v) == value
+	default:
+		return false
+	}
+}
+
+// soapActionHeader returns the SOAPAction header (SOAP 1.1) or the action
+// content type parameter (SOAP 1.2)
+func soapActionHeader(req *http.Request) string {
+	if action := strings.Trim(req.Header.Get("SOAPAction"), `"`); action != "" {
+		return action
+	}
+	if _, params, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err == nil {
+		return params["action"]
+	}
+
+	return ""
+}
+
+// soapBodyOperation returns the name of the first element inside the SOAP Body,
+// decoding stops there so truncated prefixes are fine
+func soapBodyOperation(data []byte) string {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	inBody := false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if inBody {
+			return start.Name.Local
+		}
+		if start.Name.Local == "Body" {
+			inBody = true
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package route
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBodyTestRouter() Router {
+	routes := []*config.RouteConfig{
+		{
+			Name:    "create_orders",
+			Match:   config.RouteMatch{Path: "/rpc", Body: &config.BodyMatch{JSONField: "method", Value: "orders.create"}},
+			Service: "orders",
+		},
+		{
+			Name:    "priority_jobs",
+			Match:   config.RouteMatch{Path: "/rpc", Body: &config.BodyMatch{JSONField: "params.priority", Value: "1"}},
+			Service: "priority",
+		},
+		{
+			Name:    "soap_payment",
+			Match:   config.RouteMatch{Path: "/soap", Body: &config.BodyMatch{SOAPAction: "SubmitPayment"}},
+			Service: "payments",
+		},
+		{
+			Name:    "graphql_mutation",
+			Match:   config.RouteMatch{Path: "/graphql", Body: &config.BodyMatch{Pattern: `^\s*\{\s*"query"\s*:\s*"mutation`}},
+			Service: "writer",
+		},
+		{Name: "rpc_default", Match: config.RouteMatch{Path: "/rpc"}, Service: "default"},
+		{
+			Name:    "batch_orders",
+			Match:   config.RouteMatch{Path: "/batch/*", Body: &config.BodyMatch{JSONField: "method", Value: "orders.create"}},
+			Service: "orders",
+		},
+		{Name: "batch_default", Match: config.RouteMatch{Path: "/batch/*"}, Service: "default"},
+	}
+
+	services := make(map[string]*config.ServiceConfig)
+	for _, name := range []string{"orders", "priority", "payments", "writer", "default"} {
+		services[name] = &config.ServiceConfig{
+			Name:         name,
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: "http://" + name}},
+		}
+	}
+
+	return NewRouter(routes, services)
+}
+
+func TestRouter_MatchBody(t *testing.T) {
+	router := newBodyTestRouter()
+
+	tests := []struct {
+		name     string
+		path     string
+		headers  map[string]string
+		body     string
+		expected string
+	}{
+		{
+			name:     "JSON field value",
+			path:     "/rpc",
+			body:     `{"method": "orders.create", "params": {}}`,
+			expected: "orders",
+		},
+		{
+			name:     "Nested JSON number",
+			path:     "/rpc",
+			body:     `{"method": "jobs.run", "params": {"priority": 1}}`,
+			expected: "priority",
+		},
+		{
+			name:     "No body condition matches",
+			path:     "/rpc",
+			body:     `{"method": "users.list"}`,
+			expected: "default",
+		},
+		{
+			name:     "Invalid JSON falls through",
+			path:     "/rpc",
+			body:     `not json`,
+			expected: "default",
+		},
+		{
+			name:     "SOAP body operation",
+			path:     "/soap",
+			body:     `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><SubmitPayment><Amount>10</Amount></SubmitPayment></soap:Body></soap:Envelope>`,
+			expected: "payments",
+		},
+		{
+			name:     "SOAPAction header",
+			path:     "/soap",
+			headers:  map[string]string{"SOAPAction": `"SubmitPayment"`},
+			body:     `<Envelope/>`,
+			expected: "payments",
+		},
+		{
+			name:     "SOAP 1.2 action parameter",
+			path:     "/soap",
+			headers:  map[string]string{"Content-Type": `application/soap+xml; charset=utf-8; action="SubmitPayment"`},
+			expected: "payments",
+		},
+		{
+			name:     "SOAP other operation",
+			path:     "/soap",
+			body:     `<Envelope><Body><Refund/></Body></Envelope>`,
+			expected: "",
+		},
+		{
+			name:     "Body pattern",
+			path:     "/graphql",
+			body:     `{"query": "mutation { addUser }"}`,
+			expected: "writer",
+		},
+		{
+			name:     "Wildcard path body condition",
+			path:     "/batch/v1",
+			body:     `{"method": "orders.create"}`,
+			expected: "orders",
+		},
+		{
+			name:     "Wildcard path without body match",
+			path:     "/batch/v1",
+			body:     `{"method": "users.list"}`,
+			expected: "default",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			svc := router.Match(req)
+			if tt.expected == "" {
+				assert.Nil(t, svc)
+			} else {
+				require.NotNil(t, svc)
+				assert.Equal(t, tt.expected, svc.Name())
+			}
+
+			// The body is forwarded intact after inspection
+			body, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.body, string(body))
+		})
+	}
+}
+
+func TestRouter_MatchBodySizeLimit(t *testing.T) {
+	router := NewRouter([]*config.RouteConfig{
+		{
+			Name:    "small",
+			Match:   config.RouteMatch{Path: "/rpc", Body: &config.BodyMatch{JSONField: "method", MaxSize: 16}},
+			Service: "small",
+		},
+	}, map[string]*config.ServiceConfig{
+		"small": {Name: "small", Servers: []config.ServerConfig{{Address: "http://small"}}},
+	})
+
+	payload := `{"method": "a", "padding": "` + strings.Repeat("x", 64) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(payload))
+
+	// Documents larger than max_size are never decoded
+	assert.Nil(t, router.Match(req))
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, payload, string(body))
+}
+
+func TestPeekBody(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello world"))
+
+	prefix, complete, err := peekBody(req, 5)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(prefix))
+	assert.False(t, complete)
+
+	// Later peeks extend the buffered prefix
+	prefix, complete, err = peekBody(req, 64)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(prefix))
+	assert.True(t, complete)
+
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(body))
+}
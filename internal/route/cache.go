@@ -0,0 +1,80 @@
+package route
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+)
+
+// matchCache is a bounded LRU of routing decisions keyed by method, host and
+// normalized path. Only decisions that depend on nothing else are stored, and
+// the cache is purged whenever the route tree changes
+type matchCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+}
+
+type cacheEntry struct {
+	key  string
+	info *routeInfo // nil caches a miss
+}
+
+func newMatchCache(size int) *matchCache {
+	return &matchCache{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+// cacheKey identifies the request attributes a cacheable decision depends on
+func cacheKey(req *http.Request) string {
+	return req.Method + "\x00" + req.Host + "\x00" + cleanPattern(req.URL.Path)
+}
+
+func (c *matchCache) get(key string) (*routeInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).info, true
+}
+
+func (c *matchCache) add(key string, info *routeInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*cacheEntry).info = info
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, info: info})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *matchCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element, c.size)
+	c.order.Init()
+}
+
+func (c *matchCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
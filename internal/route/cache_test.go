@@ -0,0 +1,83 @@
+package route
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchCache_Evicts(t *testing.T) {
+	c := newMatchCache(2)
+	a, b := &routeInfo{service: "a"}, &routeInfo{service: "b"}
+
+	c.add("a", a)
+	c.add("b", b)
+	_, ok := c.get("a")
+	require.True(t, ok)
+
+	// b is the least recently used entry
+	c.add("miss", nil)
+	_, ok = c.get("b")
+	assert.False(t, ok)
+	info, ok := c.get("a")
+	assert.True(t, ok)
+	assert.Same(t, a, info)
+	info, ok = c.get("miss")
+	assert.True(t, ok)
+	assert.Nil(t, info)
+
+	c.purge()
+	assert.Equal(t, 0, c.len())
+}
+
+func TestRouter_MatchCache(t *testing.T) {
+	services := map[string]*config.ServiceConfig{
+		"service_a": {Name: "service_a", BalancerType: "round_robin"},
+		"service_b": {Name: "service_b", BalancerType: "round_robin"},
+	}
+	rt := NewRouter([]*config.RouteConfig{
+		{Name: "static", Service: "service_a", Match: config.RouteMatch{Path: "/static", Method: "GET"}},
+		{Name: "host", Service: "service_b", Match: config.RouteMatch{Path: "/static", Host: "b.example.com"}},
+		{Name: "tagged", Service: "service_b", Match: config.RouteMatch{Path: "/tagged", Headers: map[string]string{"X-Tag": "b"}}},
+		{Name: "untagged", Service: "service_a", Match: config.RouteMatch{Path: "/tagged"}},
+		{Name: "prefix", Service: "service_a", Match: config.RouteMatch{Path: "/files/*"}},
+	}, services).(*router)
+	rt.SetCacheSize(100)
+
+	// Method, host and path decide these, so they are cached
+	assert.Equal(t, "service_a", rt.Match(httptest.NewRequest("GET", "http://a.example.com/static/", nil)).Name())
+	assert.Equal(t, "service_b", rt.Match(httptest.NewRequest("POST", "http://b.example.com/static", nil)).Name())
+	assert.Equal(t, "service_a", rt.Match(httptest.NewRequest("GET", "/files/a.txt", nil)).Name())
+	assert.Nil(t, rt.Match(httptest.NewRequest("GET", "/missing", nil)))
+	assert.Equal(t, 4, rt.cache.len())
+
+	info, ok := rt.cache.get(cacheKey(httptest.NewRequest("GET", "http://a.example.com/static", nil)))
+	require.True(t, ok)
+	assert.Equal(t, "static", info.config.Name)
+
+	// Header conditions make the decision depend on more than the key
+	req := httptest.NewRequest("GET", "/tagged", nil)
+	req.Header.Set("X-Tag", "b")
+	assert.Equal(t, "service_b", rt.Match(req).Name())
+	assert.Equal(t, "service_a", rt.Match(httptest.NewRequest("GET", "/tagged", nil)).Name())
+	assert.Equal(t, 4, rt.cache.len())
+
+	// Route updates invalidate cached decisions
+	require.NoError(t, rt.AddRoute(&config.RouteConfig{Name: "missing", Service: "service_b", Match: config.RouteMatch{Path: "/missing"}}))
+	assert.Equal(t, 0, rt.cache.len())
+	assert.Equal(t, "service_b", rt.Match(httptest.NewRequest("GET", "/missing", nil)).Name())
+
+	require.NoError(t, rt.Update([]*config.RouteConfig{
+		{Name: "static", Service: "service_b", Match: config.RouteMatch{Path: "/static", Method: "GET"}},
+	}, services))
+	assert.Equal(t, 0, rt.cache.len())
+	assert.Equal(t, "service_b", rt.Match(httptest.NewRequest("GET", "http://a.example.com/static", nil)).Name())
+
+	rt.SetCacheSize(0)
+	assert.Nil(t, rt.cache)
+	assert.Equal(t, "service_b", rt.Match(httptest.NewRequest("GET", "/static", nil)).Name())
+}
@@ -0,0 +1,101 @@
+package route
+
+import (
+	"context"
+	"hash/fnv"
+	"net/http"
+	"strings"
+
+	"nexus/internal/balancer"
+	"nexus/internal/config"
+)
+
+// DefaultExperimentHeader is the response header carrying the variant name
+// when the experiment sets none
+const DefaultExperimentHeader = "X-Experiment-Variant"
+
+type experimentKeyContextKey struct{}
+
+// WithExperimentKey returns a context assigning its request to the variant of
+// key, for requests of new users that don't carry the experiment key yet
+func WithExperimentKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, experimentKeyContextKey{}, key)
+}
+
+// ExperimentName returns the name of the experiment of a route, which
+// defaults to the route name
+func ExperimentName(route *config.RouteConfig) string {
+	if route.Experiment.Name != "" {
+		return route.Experiment.Name
+	}
+	return route.Name
+}
+
+// ExperimentKeySource returns the source of the key of an experiment, cookie
+// or header, and the name of the cookie or header
+func ExperimentKeySource(route *config.RouteConfig) (source, name string) {
+	if route.Experiment.Key == "" {
+		return "cookie", "nexus_exp_" + ExperimentName(route)
+	}
+	source, name, _ = strings.Cut(route.Experiment.Key, ":")
+	return source, name
+}
+
+// ExperimentKey returns the key assigning a request to a variant of the
+// experiment of its route, empty when the request carries none
+func ExperimentKey(req *http.Request, route *config.RouteConfig) string {
+	if key, ok := req.Context().Value(experimentKeyContextKey{}).(string); ok {
+		return key
+	}
+
+	source, name := ExperimentKeySource(route)
+	if source == "header" {
+		return req.Header.Get(name)
+	}
+	cookie, err := req.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// AssignVariant returns the variant of a route experiment for a key. Keys
+// are hashed with the experiment name, so the same user lands in the same
+// variant on every request while experiments are assigned independently.
+// Requests without a key get a random variant
+func AssignVariant(route *config.RouteConfig, key string) *config.ExperimentVariant {
+	return assignVariant(route, key, balancer.DefaultSource)
+}
+
+// assignVariant draws the variant of requests without a key from src
+func assignVariant(route *config.RouteConfig, key string, src balancer.Source) *config.ExperimentVariant {
+	variants := route.Experiment.Variants
+	totalWeight := 0
+	for _, variant := range variants {
+		totalWeight += variant.Weight
+	}
+	if totalWeight == 0 {
+		return variants[0]
+	}
+
+	var point int
+	if key == "" {
+		point = src.IntN(totalWeight)
+	} else {
+		h := fnv.New64a()
+		h.Write([]byte(ExperimentName(route)))
+		h.Write([]byte{'/'})
+		h.Write([]byte(key))
+		point = int(h.Sum64() % uint64(totalWeight))
+	}
+
+	currentWeight := 0
+	for _, variant := range variants {
+		currentWeight += variant.Weight
+		if point < currentWeight {
+			return variant
+		}
+	}
+
+	return variants[0]
+}
@@ -0,0 +1,110 @@
+package route
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteExperiment(t *testing.T) {
+	newRoute := func(name, key string) *config.RouteConfig {
+		return &config.RouteConfig{
+			Name:  name,
+			Match: config.RouteMatch{Path: "/" + name},
+			Experiment: &config.ExperimentConfig{
+				Key: key,
+				Variants: []*config.ExperimentVariant{
+					{Name: "control", Service: "service-a", Weight: 75},
+					{Name: "treatment", Service: "service-b", Weight: 25, Selector: "version=v2"},
+				},
+			},
+		}
+	}
+	checkout := newRoute("checkout", "")
+	search := newRoute("search", "header:X-User")
+	r := NewRouter([]*config.RouteConfig{checkout, search}, map[string]*config.ServiceConfig{
+		"service-a": {Name: "service-a", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: "http://a1"}}},
+		"service-b": {Name: "service-b", BalancerType: "round_robin", Servers: []config.ServerConfig{
+			{Address: "http://b1", Labels: map[string]string{"version": "v1"}},
+			{Address: "http://b2", Labels: map[string]string{"version": "v2"}},
+		}},
+	})
+
+	t.Run("Deterministic", func(t *testing.T) {
+		counts := make(map[string]int)
+		for i := 0; i < 2000; i++ {
+			key := "user-" + strconv.Itoa(i)
+			variant := AssignVariant(checkout, key)
+			assert.Same(t, variant, AssignVariant(checkout, key))
+			counts[variant.Name]++
+		}
+		// Weights hold within 5%
+		assert.InDelta(t, 1500, counts["control"], 100)
+		assert.InDelta(t, 500, counts["treatment"], 100)
+	})
+
+	t.Run("Cookie", func(t *testing.T) {
+		source, name := ExperimentKeySource(checkout)
+		assert.Equal(t, "cookie", source)
+		assert.Equal(t, "nexus_exp_checkout", name)
+
+		for i := 0; i < 20; i++ {
+			key := "user-" + strconv.Itoa(i)
+			req := httptest.NewRequest(http.MethodGet, "/checkout", nil)
+			req.AddCookie(&http.Cookie{Name: name, Value: key})
+			assert.Equal(t, key, ExperimentKey(req, checkout))
+
+			_, svc := r.MatchRoute(req)
+			require.NotNil(t, svc)
+			assert.Equal(t, AssignVariant(checkout, key).Service, svc.Name())
+			if svc.Name() == "service-b" {
+				// The variant selector subsets the service
+				server, err := svc.NextServer(req.Context())
+				require.NoError(t, err)
+				assert.Equal(t, "http://b2", server)
+			}
+		}
+	})
+
+	t.Run("Header", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/search", nil)
+		assert.Empty(t, ExperimentKey(req, search))
+		req.Header.Set("X-User", "user-1")
+		assert.Equal(t, "user-1", ExperimentKey(req, search))
+
+		// Assigned keys take precedence over the request
+		req = req.WithContext(WithExperimentKey(req.Context(), "user-2"))
+		assert.Equal(t, "user-2", ExperimentKey(req, search))
+	})
+
+	t.Run("Independent", func(t *testing.T) {
+		// The experiment name is part of the hash, so users aren't in the
+		// same variant of every experiment
+		same := 0
+		for i := 0; i < 200; i++ {
+			key := "user-" + strconv.Itoa(i)
+			if AssignVariant(checkout, key).Name == AssignVariant(search, key).Name {
+				same++
+			}
+		}
+		assert.Less(t, same, 200)
+	})
+
+	t.Run("Table", func(t *testing.T) {
+		for _, entry := range r.Table() {
+			if entry.Route != "checkout" {
+				continue
+			}
+			assert.Equal(t, []TableTarget{
+				{Service: "service-a", Weight: 75, Variant: "control"},
+				{Service: "service-b", Weight: 25, Selector: "version=v2", Variant: "treatment"},
+			}, entry.Split)
+		}
+	})
+}
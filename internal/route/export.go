@@ -0,0 +1,283 @@
+package route
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// TableEntry is a route of the compiled route tree. Entries are listed in
+// lookup order: by host (exact, wildcard and regular expression hosts, then
+// routes without a host), then exact paths before wildcards with the longest
+// prefix first, then routes of the same path in configuration order
+type TableEntry struct {
+	Order      int           `json:"order"`
+	Host       string        `json:"host,omitempty"`
+	HostMatch  string        `json:"host_match"` // exact, wildcard, regexp or any
+	Path       string        `json:"path"`
+	Wildcard   bool          `json:"wildcard"`
+	Method     string        `json:"method,omitempty"`
+	Route      string        `json:"route"`
+	Service    string        `json:"service,omitempty"`
+	Split      []TableTarget `json:"split,omitempty"`
+	Conditions []string      `json:"conditions,omitempty"` // Conditions beyond host, method and path
+}
+
+// TableTarget is a weighted service of a split route or experiment variant
+type TableTarget struct {
+	Service  string `json:"service"`
+	Weight   int    `json:"weight"`
+	Selector string `json:"selector,omitempty"`
+	Variant  string `json:"variant,omitempty"`
+}
+
+// Table returns the routes of the compiled route tree in lookup order
+func (r *router) Table() []TableEntry {
+	r.mu.RLock()
+	tree := r.tree
+	r.mu.RUnlock()
+
+	var entries []TableEntry
+	hosts := make([]string, 0, len(tree.exact))
+	for host := range tree.exact {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		entries = appendTable(entries, tree.exact[host], host, "exact")
+	}
+	for _, p := range tree.patterns {
+		kind := "regexp"
+		if p.wildcard {
+			kind = "wildcard"
+		}
+		entries = appendTable(entries, p.tree, p.pattern, kind)
+	}
+	entries = appendTable(entries, tree.any, "", "any")
+
+	for i := range entries {
+		entries[i].Order = i + 1
+	}
+	return entries
+}
+
+// appendTable adds the routes of a host tree in lookup order
+func appendTable(entries []TableEntry, root *node, host, hostMatch string) []TableEntry {
+	var nodes []*node
+	var walk func(n *node)
+	walk = func(n *node) {
+		if n.isEnd {
+			nodes = append(nodes, n)
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if a.isWild != b.isWild {
+			return !a.isWild
+		}
+		if da, db := depth(a.pattern), depth(b.pattern); a.isWild && da != db {
+			return da > db
+		}
+		return a.pattern < b.pattern
+	})
+
+	for _, n := range nodes {
+		for _, info := range n.routeInfos {
+			entry := TableEntry{
+				Host:       host,
+				HostMatch:  hostMatch,
+				Path:       n.pattern,
+				Wildcard:   n.isWild,
+				Method:     info.method,
+				Route:      info.config.Name,
+				Service:    info.service,
+				Conditions: conditions(info),
+			}
+			for _, split := range info.split {
+				entry.Split = append(entry.Split, TableTarget{Service: split.Service, Weight: split.Weight, Selector: split.Selector})
+			}
+			if exp := info.config.Experiment; exp != nil {
+				for _, variant := range exp.Variants {
+					entry.Split = append(entry.Split, TableTarget{Service: variant.Service, Weight: variant.Weight, Selector: variant.Selector, Variant: variant.Name})
+				}
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// depth returns the number of path segments of a pattern
+func depth(pattern string) int {
+	return strings.Count(strings.Trim(pattern, "/"), "/") + 1
+}
+
+// conditions describes the match conditions of a route beyond host, method and path
+func conditions(info *routeInfo) []string {
+	match := info.config.Match
+	var conds []string
+
+	names := make([]string, 0, len(match.Headers))
+	for name := range match.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		conds = append(conds, "header "+name+"="+match.Headers[name])
+	}
+	if match.Body != nil {
+		conds = append(conds, "body")
+	}
+	if len(match.Languages) > 0 {
+		conds = append(conds, "languages "+strings.Join(match.Languages, ","))
+	}
+	if len(match.Countries) > 0 {
+		conds = append(conds, "countries "+strings.Join(match.Countries, ","))
+	}
+	if len(match.Continents) > 0 {
+		conds = append(conds, "continents "+strings.Join(match.Continents, ","))
+	}
+	if match.Time != nil {
+		conds = append(conds, "time")
+	}
+	tags := make([]string, 0, len(match.Tags))
+	for name := range match.Tags {
+		tags = append(tags, name)
+	}
+	sort.Strings(tags)
+	for _, name := range tags {
+		conds = append(conds, "tag "+name+"="+match.Tags[name])
+	}
+	if tls := match.TLS; tls != nil {
+		if len(tls.SNI) > 0 {
+			conds = append(conds, "sni "+strings.Join(tls.SNI, ","))
+		}
+		if len(tls.ALPN) > 0 {
+			conds = append(conds, "alpn "+strings.Join(tls.ALPN, ","))
+		}
+		if len(tls.ClientCommonNames) > 0 {
+			conds = append(conds, "client cn "+strings.Join(tls.ClientCommonNames, ","))
+		}
+		if len(tls.ClientOrganizations) > 0 {
+			conds = append(conds, "client o "+strings.Join(tls.ClientOrganizations, ","))
+		}
+	}
+	return conds
+}
+
+// name identifies the target service, with the selector of a subset
+func (t TableTarget) name() string {
+	if t.Selector == "" {
+		return t.Service
+	}
+	return t.Service + "{" + t.Selector + "}"
+}
+
+// target describes where the route sends requests
+func (e TableEntry) target() string {
+	if len(e.Split) == 0 {
+		return e.Service
+	}
+
+	targets := make([]string, 0, len(e.Split))
+	for _, split := range e.Split {
+		targets = append(targets, split.name()+"="+strconv.Itoa(split.Weight))
+	}
+	return strings.Join(targets, " ")
+}
+
+// WriteTable writes the route table as aligned text columns
+func WriteTable(w io.Writer, entries []TableEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ORDER\tHOST\tMETHOD\tPATH\tROUTE\tTARGET\tCONDITIONS")
+	for _, e := range entries {
+		host := e.Host
+		if host == "" {
+			host = "*"
+		}
+		method := e.Method
+		if method == "" {
+			method = "*"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Order, host, method, e.Path, e.Route, e.target(), strings.Join(e.Conditions, "; "))
+	}
+	return tw.Flush()
+}
+
+// WriteDOT writes the route table as a Graphviz graph from hosts over paths
+// and routes to the services, split edges are labeled with their weights
+func WriteDOT(w io.Writer, entries []TableEntry) error {
+	var b strings.Builder
+	b.WriteString("digraph routes {\n\trankdir=LR;\n\tnode [shape=box];\n")
+
+	declared := make(map[string]bool)
+	declare := func(id, label, attrs string) {
+		if declared[id] {
+			return
+		}
+		declared[id] = true
+		fmt.Fprintf(&b, "\t%s [label=%s%s];\n", strconv.Quote(id), strconv.Quote(label), attrs)
+	}
+	edges := make(map[string]bool)
+	edge := func(from, to, label string) {
+		line := fmt.Sprintf("\t%s -> %s", strconv.Quote(from), strconv.Quote(to))
+		if label != "" {
+			line += fmt.Sprintf(" [label=%s]", strconv.Quote(label))
+		}
+		if !edges[line] {
+			edges[line] = true
+			b.WriteString(line + ";\n")
+		}
+	}
+
+	for _, e := range entries {
+		host := e.Host
+		if host == "" {
+			host = "*"
+		}
+		hostID := "host:" + host
+		declare(hostID, host, ", shape=ellipse")
+
+		pathID := "path:" + host + e.Path
+		pathAttrs := ""
+		if e.Wildcard {
+			pathAttrs = ", style=dashed"
+		}
+		declare(pathID, e.Path, pathAttrs)
+		edge(hostID, pathID, "")
+
+		label := fmt.Sprintf("#%d %s", e.Order, e.Route)
+		if e.Method != "" {
+			label += "\n" + e.Method
+		}
+		for _, cond := range e.Conditions {
+			label += "\n" + cond
+		}
+		routeID := "route:" + e.Route
+		declare(routeID, label, ", style=rounded")
+		edge(pathID, routeID, "")
+
+		if len(e.Split) == 0 {
+			declare("service:"+e.Service, e.Service, ", shape=component")
+			edge(routeID, "service:"+e.Service, "")
+			continue
+		}
+		for _, split := range e.Split {
+			declare("service:"+split.name(), split.name(), ", shape=component")
+			edge(routeID, "service:"+split.name(), strconv.Itoa(split.Weight))
+		}
+	}
+
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
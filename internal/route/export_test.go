@@ -0,0 +1,72 @@
+package route
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newExportTestRouter() Router {
+	return NewRouter([]*config.RouteConfig{
+		{Name: "fallback", Match: config.RouteMatch{Path: "/*"}, Service: "web"},
+		{Name: "api", Match: config.RouteMatch{Path: "/api/*"}, Service: "api"},
+		{Name: "login", Match: config.RouteMatch{Path: "/api/login", Method: "POST"}, Service: "auth"},
+		{Name: "beta", Match: config.RouteMatch{Path: "/api/login", Headers: map[string]string{"X-Beta": "1"}}, Service: "auth"},
+		{Name: "shop", Match: config.RouteMatch{Host: "*.shop.com", Path: "/*"}, Split: []*config.RouteSplit{
+			{Service: "web", Weight: 80},
+			{Service: "web-v2", Weight: 20},
+		}},
+		{Name: "admin", Match: config.RouteMatch{Host: "admin.example.com", Path: "/"}, Service: "admin"},
+	}, nil)
+}
+
+func TestRouter_Table(t *testing.T) {
+	entries := newExportTestRouter().Table()
+
+	assert.Equal(t, []TableEntry{
+		{Order: 1, Host: "admin.example.com", HostMatch: "exact", Path: "/", Route: "admin", Service: "admin"},
+		{Order: 2, Host: "*.shop.com", HostMatch: "wildcard", Path: "/*", Wildcard: true, Route: "shop",
+			Split: []TableTarget{{Service: "web", Weight: 80}, {Service: "web-v2", Weight: 20}}},
+		{Order: 3, HostMatch: "any", Path: "/api/login", Method: "POST", Route: "login", Service: "auth"},
+		{Order: 4, HostMatch: "any", Path: "/api/login", Route: "beta", Service: "auth", Conditions: []string{"header X-Beta=1"}},
+		{Order: 5, HostMatch: "any", Path: "/api/*", Wildcard: true, Route: "api", Service: "api"},
+		{Order: 6, HostMatch: "any", Path: "/*", Wildcard: true, Route: "fallback", Service: "web"},
+	}, entries)
+}
+
+func TestWriteTable(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, WriteTable(&out, newExportTestRouter().Table()))
+
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		lines = append(lines, strings.TrimRight(line, " "))
+	}
+	assert.Equal(t, []string{
+		"ORDER  HOST               METHOD  PATH        ROUTE     TARGET            CONDITIONS",
+		"1      admin.example.com  *       /           admin     admin",
+		"2      *.shop.com         *       /*          shop      web=80 web-v2=20",
+		"3      *                  POST    /api/login  login     auth",
+		"4      *                  *       /api/login  beta      auth              header X-Beta=1",
+		"5      *                  *       /api/*      api       api",
+		"6      *                  *       /*          fallback  web",
+	}, lines)
+}
+
+func TestWriteDOT(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, WriteDOT(&out, newExportTestRouter().Table()))
+
+	dot := out.String()
+	assert.Contains(t, dot, "digraph routes {")
+	assert.Contains(t, dot, `"host:*.shop.com" -> "path:*.shop.com/*";`)
+	assert.Contains(t, dot, `"path:*.shop.com/*" [label="/*", style=dashed];`)
+	assert.Contains(t, dot, `"route:shop" -> "service:web-v2" [label="20"];`)
+	assert.Contains(t, dot, `"route:beta" [label="#4 beta\nheader X-Beta=1", style=rounded];`)
+	// Services shared by routes are declared once
+	assert.Equal(t, 1, bytes.Count(out.Bytes(), []byte(`"service:auth" [`)))
+}
@@ -0,0 +1,197 @@
+package route
+
+import (
+	"maps"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// hostTree keeps a separate path tree per virtual host, so routes of
+// different hosts never compete for the same path. A request is looked up in
+// the tree of its exact host first, then in the trees of matching wildcard and
+// regular expression hosts, and finally among the routes without a host
+type hostTree struct {
+	exact    map[string]*node
+	patterns []*hostPattern // wildcards by longest suffix, then regular expressions
+	any      *node          // routes without a host
+}
+
+// hostPattern is the path tree of a wildcard or regular expression host
+type hostPattern struct {
+	pattern  string
+	wildcard bool
+	re       *regexp.Regexp // nil for wildcards and invalid expressions
+	tree     *node
+}
+
+func newHostTree() *hostTree {
+	return &hostTree{
+		exact: make(map[string]*node),
+		any:   newNode(),
+	}
+}
+
+// isHostPattern reports whether a route host is matched by pattern rather
+// than by equality, following matchHost
+func isHostPattern(host string) bool {
+	return strings.HasPrefix(host, "*") || strings.HasPrefix(host, "^") || strings.HasSuffix(host, "$")
+}
+
+func newHostPattern(pattern string, tree *node) *hostPattern {
+	p := &hostPattern{pattern: pattern, tree: tree}
+	if strings.HasPrefix(pattern, "*") {
+		p.wildcard = true
+	} else {
+		p.re, _ = regexp.Compile(pattern)
+	}
+	return p
+}
+
+func (p *hostPattern) match(host string) bool {
+	if p.wildcard {
+		return strings.HasSuffix(host, p.pattern[1:])
+	}
+	return p.re != nil && p.re.MatchString(host)
+}
+
+// sortHostPatterns orders patterns from the most to the least specific
+func sortHostPatterns(patterns []*hostPattern) {
+	sort.SliceStable(patterns, func(i, j int) bool {
+		a, b := patterns[i], patterns[j]
+		if a.wildcard != b.wildcard {
+			return a.wildcard
+		}
+		if len(a.pattern) != len(b.pattern) {
+			return len(a.pattern) > len(b.pattern)
+		}
+		return a.pattern < b.pattern
+	})
+}
+
+// get returns the path tree of a route host, nil when there is none
+func (t *hostTree) get(host string) *node {
+	switch {
+	case host == "":
+		return t.any
+	case !isHostPattern(host):
+		return t.exact[host]
+	}
+	for _, p := range t.patterns {
+		if p.pattern == host {
+			return p.tree
+		}
+	}
+	return nil
+}
+
+// set replaces the path tree of a route host in place, empty trees are dropped
+func (t *hostTree) set(host string, tree *node) {
+	empty := !tree.isEnd && len(tree.children) == 0
+	switch {
+	case host == "":
+		t.any = tree
+		return
+	case !isHostPattern(host):
+		if empty {
+			delete(t.exact, host)
+		} else {
+			t.exact[host] = tree
+		}
+		return
+	}
+
+	for i, p := range t.patterns {
+		if p.pattern != host {
+			continue
+		}
+		if empty {
+			t.patterns = append(t.patterns[:i], t.patterns[i+1:]...)
+		} else {
+			// Patterns may be shared with other trees, so they are replaced
+			t.patterns[i] = &hostPattern{pattern: p.pattern, wildcard: p.wildcard, re: p.re, tree: tree}
+		}
+		return
+	}
+	if !empty {
+		t.patterns = append(t.patterns, newHostPattern(host, tree))
+		sortHostPatterns(t.patterns)
+	}
+}
+
+// clone returns a copy of the host index sharing the path trees
+func (t *hostTree) clone() *hostTree {
+	return &hostTree{
+		exact:    maps.Clone(t.exact),
+		patterns: append([]*hostPattern{}, t.patterns...),
+		any:      t.any,
+	}
+}
+
+// insert adds a route while the tree is being built
+func (t *hostTree) insert(route *routeInfo) {
+	tree := t.get(route.host)
+	if tree == nil {
+		tree = newNode()
+	}
+	tree.insert(route.config.Match.Path, route)
+	t.set(route.host, tree)
+}
+
+// withRoute returns a copy of the tree with the route added, see node.withRoute
+func (t *hostTree) withRoute(route *routeInfo, order map[string]int) *hostTree {
+	tree := t.get(route.host)
+	if tree == nil {
+		tree = newNode()
+	}
+
+	c := t.clone()
+	c.set(route.host, tree.withRoute(route.config.Match.Path, route, order))
+	return c
+}
+
+// withoutRoute returns a copy of the tree without the route, see node.withoutRoute
+func (t *hostTree) withoutRoute(host, pattern, name string) *hostTree {
+	tree := t.get(host)
+	if tree == nil {
+		return t
+	}
+
+	c := t.clone()
+	c.set(host, tree.withoutRoute(pattern, name))
+	return c
+}
+
+// search returns the matching route information
+func (t *hostTree) search(req *http.Request) *routeInfo {
+	info, _ := t.searchCacheable(req)
+	return info
+}
+
+// searchCacheable searches the trees the request host falls into and reports
+// whether the result only depends on the method, host and path of the request
+func (t *hostTree) searchCacheable(req *http.Request) (*routeInfo, bool) {
+	cacheable := true
+	if tree, ok := t.exact[req.Host]; ok {
+		info, ok := tree.searchCacheable(req)
+		if info != nil {
+			return info, ok
+		}
+		cacheable = ok
+	}
+
+	for _, p := range t.patterns {
+		if !p.match(req.Host) {
+			continue
+		}
+		info, ok := p.tree.searchCacheable(req)
+		if info != nil {
+			return info, cacheable && ok
+		}
+		cacheable = cacheable && ok
+	}
+
+	info, ok := t.any.searchCacheable(req)
+	return info, cacheable && ok
+}
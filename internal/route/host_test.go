@@ -0,0 +1,102 @@
+package route
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_HostTrees(t *testing.T) {
+	services := map[string]*config.ServiceConfig{}
+	routes := []*config.RouteConfig{
+		{Name: "a_api", Match: config.RouteMatch{Host: "a.example.com", Path: "/api"}},
+		{Name: "b_api", Match: config.RouteMatch{Host: "b.example.com", Path: "/api"}},
+		{Name: "a_files", Match: config.RouteMatch{Host: "a.example.com", Path: "/files/*"}},
+		{Name: "b_files_v1", Match: config.RouteMatch{Host: "b.example.com", Path: "/files/v1/*"}},
+		{Name: "any_files", Match: config.RouteMatch{Path: "/files/*"}},
+		{Name: "api_exact", Match: config.RouteMatch{Host: "api.example.com", Path: "/status"}},
+		{Name: "api_wildcard", Match: config.RouteMatch{Host: "*.example.com", Path: "/status"}},
+		{Name: "api_regex", Match: config.RouteMatch{Host: "^api[0-9]+\\.internal$", Path: "/status"}},
+		{Name: "any_status", Match: config.RouteMatch{Path: "/status"}},
+	}
+	for _, route := range routes {
+		route.Service = route.Name
+		services[route.Name] = &config.ServiceConfig{Name: route.Name, BalancerType: "round_robin"}
+	}
+	rt := NewRouter(routes, services)
+
+	tests := []struct {
+		name     string
+		host     string
+		path     string
+		expected string
+	}{
+		{name: "same path on host a", host: "a.example.com", path: "/api", expected: "a_api"},
+		{name: "same path on host b", host: "b.example.com", path: "/api", expected: "b_api"},
+		{name: "same path on unknown host", host: "c.example.com", path: "/api"},
+		{name: "shorter wildcard of own host", host: "a.example.com", path: "/files/v1/report", expected: "a_files"},
+		{name: "longer wildcard of own host", host: "b.example.com", path: "/files/v1/report", expected: "b_files_v1"},
+		{name: "falls back to routes without host", host: "b.example.com", path: "/files/v2/report", expected: "any_files"},
+		{name: "exact host before wildcard host", host: "api.example.com", path: "/status", expected: "api_exact"},
+		{name: "wildcard host", host: "www.example.com", path: "/status", expected: "api_wildcard"},
+		{name: "regex host", host: "api2.internal", path: "/status", expected: "api_regex"},
+		{name: "no host pattern matches", host: "other.internal", path: "/status", expected: "any_status"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.Host = tt.host
+
+			svc := rt.Match(req)
+			if tt.expected == "" {
+				assert.Nil(t, svc)
+				return
+			}
+			require.NotNil(t, svc)
+			assert.Equal(t, tt.expected, svc.Name())
+		})
+	}
+}
+
+func TestRouter_HostTreesUpdate(t *testing.T) {
+	services := map[string]*config.ServiceConfig{
+		"service_a": {Name: "service_a", BalancerType: "round_robin"},
+		"service_b": {Name: "service_b", BalancerType: "round_robin"},
+	}
+	rt := NewRouter([]*config.RouteConfig{
+		{Name: "a", Service: "service_a", Match: config.RouteMatch{Host: "a.example.com", Path: "/api"}},
+	}, services).(*router)
+	req := func(host string) string {
+		r := httptest.NewRequest("GET", "/api", nil)
+		r.Host = host
+		if svc := rt.Match(r); svc != nil {
+			return svc.Name()
+		}
+		return ""
+	}
+
+	require.NoError(t, rt.AddRoute(&config.RouteConfig{Name: "b", Service: "service_b", Match: config.RouteMatch{Host: "b.example.com", Path: "/api"}}))
+	require.NoError(t, rt.AddRoute(&config.RouteConfig{Name: "sub", Service: "service_b", Match: config.RouteMatch{Host: "*.sub.example.com", Path: "/api"}}))
+	assert.Equal(t, "service_a", req("a.example.com"))
+	assert.Equal(t, "service_b", req("b.example.com"))
+	assert.Equal(t, "service_b", req("x.sub.example.com"))
+
+	// Removing the last route of a host drops its tree
+	require.NoError(t, rt.RemoveRoute("b"))
+	require.NoError(t, rt.RemoveRoute("sub"))
+	assert.Equal(t, "", req("b.example.com"))
+	assert.NotContains(t, rt.tree.exact, "b.example.com")
+	assert.Empty(t, rt.tree.patterns)
+
+	require.NoError(t, rt.Update([]*config.RouteConfig{
+		{Name: "a", Service: "service_a", Match: config.RouteMatch{Host: "a.example.com", Path: "/api"}},
+		{Name: "moved", Service: "service_b", Match: config.RouteMatch{Host: "c.example.com", Path: "/api"}},
+	}, services))
+	assert.Equal(t, "service_a", req("a.example.com"))
+	assert.Equal(t, "service_b", req("c.example.com"))
+}
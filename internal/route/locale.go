@@ -0,0 +1,120 @@
+package route
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"nexus/internal/config"
+	"nexus/internal/geo"
+)
+
+// localeMatcher matches the preferred language and the resolved client location
+type localeMatcher struct {
+	languages  []string
+	countries  map[string]bool
+	continents map[string]bool
+}
+
+// newLocaleMatcher compiles the locale and geo conditions, nil when none is configured
+func newLocaleMatcher(match config.RouteMatch) *localeMatcher {
+	if len(match.Languages) == 0 && len(match.Countries) == 0 && len(match.Continents) == 0 {
+		return nil
+	}
+
+	m := &localeMatcher{}
+	for _, lang := range match.Languages {
+		m.languages = append(m.languages, strings.ToLower(lang))
+	}
+	m.countries = upperSet(match.Countries)
+	m.continents = upperSet(match.Continents)
+
+	return m
+}
+
+func upperSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[strings.ToUpper(v)] = true
+	}
+	return set
+}
+
+func (m *localeMatcher) match(req *http.Request) bool {
+	if len(m.languages) > 0 && !matchLanguage(m.languages, preferredLanguage(req.Header.Get("Accept-Language"))) {
+		return false
+	}
+
+	if m.countries == nil && m.continents == nil {
+		return true
+	}
+	loc, ok := geo.FromContext(req.Context())
+	if !ok {
+		return false
+	}
+	if m.countries != nil && !m.countries[loc.Country] {
+		return false
+	}
+	if m.continents != nil && !m.continents[loc.Continent] {
+		return false
+	}
+
+	return true
+}
+
+// matchLanguage matches a language tag, a bare language such as "de" also
+// matches its regional variants such as "de-AT"
+func matchLanguage(languages []string, tag string) bool {
+	if tag == "" {
+		return false
+	}
+	for _, lang := range languages {
+		if tag == lang || strings.HasPrefix(tag, lang+"-") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// preferredLanguage returns the lower-cased tag with the highest quality in an
+// Accept-Language header, earlier tags win ties
+func preferredLanguage(header string) string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag: tag, q: q})
+		}
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	return tags[0].tag
+}
@@ -0,0 +1,82 @@
+package route
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/geo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{"", ""},
+		{"de-AT", "de-at"},
+		{"en-US,en;q=0.9,de;q=0.8", "en-us"},
+		{"fr;q=0.5, de;q=0.9", "de"},
+		{"*;q=1, it;q=0.3", "it"},
+		{"es;q=0, pt", "pt"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, preferredLanguage(tt.header), tt.header)
+	}
+}
+
+func TestRouter_MatchLocale(t *testing.T) {
+	routes := []*config.RouteConfig{
+		{Name: "german", Match: config.RouteMatch{Path: "/shop", Languages: []string{"de"}}, Service: "de"},
+		{Name: "swiss", Match: config.RouteMatch{Path: "/shop", Countries: []string{"ch"}}, Service: "ch"},
+		{Name: "europe", Match: config.RouteMatch{Path: "/shop", Continents: []string{"EU"}}, Service: "eu"},
+		{Name: "global", Match: config.RouteMatch{Path: "/shop"}, Service: "global"},
+		{Name: "german_store", Match: config.RouteMatch{Path: "/store/*", Languages: []string{"de"}}, Service: "de"},
+		{Name: "swiss_store", Match: config.RouteMatch{Path: "/store/*", Countries: []string{"ch"}}, Service: "ch"},
+		{Name: "europe_store", Match: config.RouteMatch{Path: "/store/*", Continents: []string{"EU"}}, Service: "eu"},
+		{Name: "global_store", Match: config.RouteMatch{Path: "/store/*"}, Service: "global"},
+	}
+	services := make(map[string]*config.ServiceConfig)
+	for _, name := range []string{"de", "ch", "eu", "global"} {
+		services[name] = &config.ServiceConfig{Name: name, Servers: []config.ServerConfig{{Address: "http://" + name}}}
+	}
+	router := NewRouter(routes, services)
+
+	tests := []struct {
+		name     string
+		language string
+		location *geo.Location
+		expected string
+	}{
+		{"Preferred language", "de-DE,en;q=0.5", nil, "de"},
+		{"Less preferred language is ignored", "en,de;q=0.5", nil, "global"},
+		{"Country", "", &geo.Location{Country: "CH", Continent: "EU"}, "ch"},
+		{"Continent", "", &geo.Location{Country: "FR", Continent: "EU"}, "eu"},
+		{"Other continent", "", &geo.Location{Country: "US", Continent: "NA"}, "global"},
+		{"Unresolved location", "", nil, "global"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Exact and wildcard paths evaluate the conditions alike
+			for _, path := range []string{"/shop", "/store/items/42"} {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				if tt.language != "" {
+					req.Header.Set("Accept-Language", tt.language)
+				}
+				if tt.location != nil {
+					req = req.WithContext(geo.NewContext(req.Context(), *tt.location))
+				}
+
+				svc := router.Match(req)
+				require.NotNil(t, svc, path)
+				assert.Equal(t, tt.expected, svc.Name(), path)
+			}
+		})
+	}
+}
@@ -2,8 +2,10 @@ package route
 
 import (
 	"net/http"
+	"nexus/internal/classify"
 	"nexus/internal/config"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -14,6 +16,8 @@ type node struct {
 	isWild     bool
 	isEnd      bool
 	routeInfos []*routeInfo
+	methods    map[string][]*routeInfo // method -> routes of that method and of any method, in order
+	anyMethod  []*routeInfo            // routes without a method
 }
 
 type routeInfo struct {
@@ -23,6 +27,12 @@ type routeInfo struct {
 	service string
 	path    string
 	split   []*config.RouteSplit
+	config  *config.RouteConfig
+	body    *bodyMatcher
+	locale  *localeMatcher
+	time    *timeMatcher
+	tls     *tlsMatcher
+	tags    map[string]string
 }
 
 func newNode() *node {
@@ -32,18 +42,56 @@ func newNode() *node {
 	}
 }
 
-// insert Insert path to radix tree
-func (n *node) insert(pattern string, route *routeInfo) {
+// reindex rebuilds the method index after routeInfos changed
+func (n *node) reindex() {
+	n.methods = nil
+	n.anyMethod = nil
+	for _, info := range n.routeInfos {
+		if info.method == "" {
+			n.anyMethod = append(n.anyMethod, info)
+		} else if _, ok := n.methods[info.method]; !ok {
+			if n.methods == nil {
+				n.methods = make(map[string][]*routeInfo)
+			}
+			n.methods[info.method] = nil
+		}
+	}
+	for method := range n.methods {
+		for _, info := range n.routeInfos {
+			if info.method == "" || info.method == method {
+				n.methods[method] = append(n.methods[method], info)
+			}
+		}
+	}
+}
+
+// routesFor returns the routes of the node a request with the method can match
+func (n *node) routesFor(method string) []*routeInfo {
+	if routes, ok := n.methods[method]; ok {
+		return routes
+	}
+	return n.anyMethod
+}
+
+// cleanPattern normalizes a route path pattern
+func cleanPattern(pattern string) string {
 	pattern = strings.TrimRight(pattern, "/")
 	if pattern == "" {
 		pattern = "/"
 	}
+	return pattern
+}
+
+// insert Insert path to radix tree
+func (n *node) insert(pattern string, route *routeInfo) {
+	pattern = cleanPattern(pattern)
 	route.path = pattern
 
 	if pattern == "/" {
 		n.pattern = pattern
 		n.isEnd = true
 		n.routeInfos = append(n.routeInfos, route)
+		n.reindex()
 		return
 	}
 
@@ -70,35 +118,153 @@ func (n *node) insert(pattern string, route *routeInfo) {
 			current.pattern = pattern
 			current.isEnd = true
 			current.routeInfos = append(current.routeInfos, route)
+			current.reindex()
+		}
+	}
+}
+
+// clone returns a shallow copy of the node whose slices can be modified
+// without affecting the original
+func (n *node) clone() *node {
+	c := *n
+	c.children = append(make([]*node, 0, len(n.children)+1), n.children...)
+	c.routeInfos = append(make([]*routeInfo, 0, len(n.routeInfos)+1), n.routeInfos...)
+	return &c
+}
+
+// withRoute returns a copy of the tree with the route added. Only the nodes on
+// the route's path are copied, the rest is shared with the original tree, so
+// readers of the original are never affected. Routes of the same node are
+// kept sorted by order
+func (n *node) withRoute(pattern string, route *routeInfo, order map[string]int) *node {
+	pattern = cleanPattern(pattern)
+	route.path = pattern
+
+	root := n.clone()
+	current := root
+	if pattern != "/" {
+		for _, part := range strings.Split(strings.Trim(pattern, "/"), "/") {
+			var next *node
+			for i, child := range current.children {
+				if child.part == part {
+					next = child.clone()
+					current.children[i] = next
+					break
+				}
+			}
+			if next == nil {
+				next = newNode()
+				next.part = part
+				next.isWild = part == "*" || part == "**"
+				current.children = append(current.children, next)
+			}
+			current = next
+		}
+	}
+
+	current.pattern = pattern
+	current.isEnd = true
+	current.routeInfos = append(current.routeInfos, route)
+	sort.SliceStable(current.routeInfos, func(i, j int) bool {
+		return order[current.routeInfos[i].config.Name] < order[current.routeInfos[j].config.Name]
+	})
+	current.reindex()
+
+	return root
+}
+
+// withoutRoute returns a copy of the tree without the named route at pattern,
+// copying only the nodes on its path. Nodes left without routes or children
+// are pruned
+func (n *node) withoutRoute(pattern, name string) *node {
+	pattern = cleanPattern(pattern)
+
+	var parts []string
+	if pattern != "/" {
+		parts = strings.Split(strings.Trim(pattern, "/"), "/")
+	}
+
+	root := n.clone()
+	path := []*node{root}
+	current := root
+	for _, part := range parts {
+		var next *node
+		for i, child := range current.children {
+			if child.part == part {
+				next = child.clone()
+				current.children[i] = next
+				break
+			}
+		}
+		if next == nil {
+			return n
 		}
+		path = append(path, next)
+		current = next
 	}
+
+	infos := current.routeInfos[:0]
+	for _, info := range current.routeInfos {
+		if info.config.Name != name {
+			infos = append(infos, info)
+		}
+	}
+	current.routeInfos = infos
+	current.reindex()
+	if len(infos) == 0 {
+		current.isEnd = false
+		current.pattern = ""
+	}
+
+	// Prune empty nodes bottom up, the root always stays
+	for i := len(path) - 1; i > 0; i-- {
+		child := path[i]
+		if child.isEnd || len(child.children) > 0 {
+			break
+		}
+		parent := path[i-1]
+		for j, c := range parent.children {
+			if c == child {
+				parent.children = append(parent.children[:j], parent.children[j+1:]...)
+				break
+			}
+		}
+	}
+
+	return root
 }
 
 // search Search matching route information in radix tree
 func (n *node) search(req *http.Request) *routeInfo {
-	path := strings.TrimRight(req.URL.Path, "/")
-	if path == "" {
-		path = "/"
-	}
+	info, _ := n.searchCacheable(req)
+	return info
+}
+
+// searchCacheable searches the tree and reports whether the result only
+// depends on the method, host and path of the request
+func (n *node) searchCacheable(req *http.Request) (*routeInfo, bool) {
+	path := cleanPattern(req.URL.Path)
+	dynamic := false
 
 	if path == "/" {
 		if n.isEnd {
-			return n.findMatchingRoute(req, nil)
+			return n.findMatchingRoute(req, nil, &dynamic), !dynamic
 		}
-		return nil
+		return nil, true
 	}
 
 	// First try exact match
-	if exactMatch := n.searchExactPath(path, req); exactMatch != nil {
-		return exactMatch
+	if exactMatch := n.searchExactPath(path, req, &dynamic); exactMatch != nil {
+		return exactMatch, !dynamic
 	}
 
 	// Then try wildcard match
-	return n.searchWildcardPath(path, req)
+	info := n.searchWildcardPath(path, req, &dynamic)
+	return info, !dynamic
 }
 
 // searchExactPath Try exact match path
-func (n *node) searchExactPath(path string, req *http.Request) *routeInfo {
+func (n *node) searchExactPath(path string, req *http.Request, dynamic *bool) *routeInfo {
 	parts := strings.Split(strings.Trim(path, "/"), "/")
 	current := n
 
@@ -117,24 +283,29 @@ func (n *node) searchExactPath(path string, req *http.Request) *routeInfo {
 	}
 
 	if current.isEnd {
-		return current.findMatchingRoute(req, nil)
+		return current.findMatchingRoute(req, nil, dynamic)
 	}
 
 	return nil
 }
 
-// searchWildcardPath Try wildcard match path
-func (n *node) searchWildcardPath(path string, req *http.Request) *routeInfo {
+// wildcardCandidate is a wildcard route whose prefix matches the request path
+type wildcardCandidate struct {
+	info   *routeInfo
+	length int // Segments of the prefix
+}
+
+// searchWildcardPath Try wildcard match path. The routes with the longest
+// matching prefix are tried first, in order, and those with shorter prefixes
+// when none of them matches the other conditions of the request
+func (n *node) searchWildcardPath(path string, req *http.Request, dynamic *bool) *routeInfo {
 	// Get all possible wildcard routes
 	wildcardRoutes := make([]*routeInfo, 0)
 
-	// Recursively collect all wildcard routes
-	n.collectWildcardRoutes("", &wildcardRoutes)
-
-	// Sort by path length, select the longest match
-	var bestMatch *routeInfo
-	var bestMatchLength int
+	// Recursively collect all wildcard routes the method can match
+	n.collectWildcardRoutes("", req.Method, &wildcardRoutes)
 
+	candidates := make([]wildcardCandidate, 0, len(wildcardRoutes))
 	for _, info := range wildcardRoutes {
 		routePath := info.path
 
@@ -144,32 +315,39 @@ func (n *node) searchWildcardPath(path string, req *http.Request) *routeInfo {
 			if strings.HasPrefix(path, prefix+"/") {
 				// Calculate prefix length
 				prefixParts := strings.Split(strings.Trim(prefix, "/"), "/")
-				if len(prefixParts) > bestMatchLength {
-					bestMatchLength = len(prefixParts)
-					bestMatch = info
-				}
+				candidates = append(candidates, wildcardCandidate{info: info, length: len(prefixParts)})
 			}
 		}
 
-		// If the path is "*", find the matching route from other conditions
+		// The path "*" matches every path, after the prefixed routes
 		if routePath == "*" {
-			bestMatch = n.findMatchingRoute(req, wildcardRoutes)
+			candidates = append(candidates, wildcardCandidate{info: info})
 		}
 	}
 
-	if bestMatch != nil {
-		return bestMatch
+	// Sort by prefix length, keeping the order of routes of the same length
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].length > candidates[j].length
+	})
+	group := make([]*routeInfo, 0, len(candidates))
+	for i, candidate := range candidates {
+		group = append(group, candidate.info)
+		if i+1 < len(candidates) && candidates[i+1].length == candidate.length {
+			continue
+		}
+		if info := n.findMatchingRoute(req, group, dynamic); info != nil {
+			return info
+		}
+		group = group[:0]
 	}
 
 	return nil
 }
 
-// collectWildcardRoutes Collect all wildcard routes
-func (n *node) collectWildcardRoutes(currentPath string, routes *[]*routeInfo) {
+// collectWildcardRoutes Collect all wildcard routes of a method
+func (n *node) collectWildcardRoutes(currentPath, method string, routes *[]*routeInfo) {
 	if n.isWild && n.isEnd {
-		for _, info := range n.routeInfos {
-			*routes = append(*routes, info)
-		}
+		*routes = append(*routes, n.routesFor(method)...)
 	}
 
 	for _, child := range n.children {
@@ -178,22 +356,27 @@ func (n *node) collectWildcardRoutes(currentPath string, routes *[]*routeInfo) {
 			newPath += "/"
 		}
 		newPath += child.part
-		child.collectWildcardRoutes(newPath, routes)
+		child.collectWildcardRoutes(newPath, method, routes)
 	}
 }
 
-// findMatchingRoute Find matching route information
-func (n *node) findMatchingRoute(req *http.Request, routes []*routeInfo) *routeInfo {
+// findMatchingRoute Find matching route information, dynamic is set when a
+// route looked at has conditions beyond method, host and path
+func (n *node) findMatchingRoute(req *http.Request, routes []*routeInfo, dynamic *bool) *routeInfo {
 	if len(routes) == 0 {
-		routes = n.routeInfos
+		routes = n.routesFor(req.Method)
 	}
 
-	// If there is only one route information, return directly
-	if len(routes) == 1 {
+	// If there is only one route information, return directly unless it has
+	// body, locale, time, TLS or tag conditions
+	if len(routes) == 1 && routes[0].body == nil && routes[0].locale == nil && routes[0].time == nil && routes[0].tls == nil && routes[0].tags == nil {
 		return routes[0]
 	}
 
 	for _, info := range routes {
+		if info.dynamic() {
+			*dynamic = true
+		}
 		if matchRouteInfo(info, req) {
 			return info
 		}
@@ -202,6 +385,11 @@ func (n *node) findMatchingRoute(req *http.Request, routes []*routeInfo) *routeI
 	return nil
 }
 
+// dynamic reports whether matching depends on more than method, host and path
+func (info *routeInfo) dynamic() bool {
+	return len(info.headers) > 0 || info.body != nil || info.locale != nil || info.time != nil || info.tls != nil || info.tags != nil
+}
+
 // matchRouteInfo Check if the request matches the route information
 func matchRouteInfo(info *routeInfo, req *http.Request) bool {
 	// Check HTTP method matching
@@ -224,6 +412,36 @@ func matchRouteInfo(info *routeInfo, req *http.Request) bool {
 		}
 	}
 
+	// Check the tags of the classification rules
+	if info.tags != nil {
+		tags := classify.FromContext(req.Context())
+		for name, value := range info.tags {
+			if actual, ok := tags[name]; !ok || actual != value {
+				return false
+			}
+		}
+	}
+
+	// Check SNI, ALPN and client certificate matching
+	if info.tls != nil && !info.tls.match(req) {
+		return false
+	}
+
+	// Check time window matching
+	if info.time != nil && !info.time.match(now()) {
+		return false
+	}
+
+	// Check Accept-Language and client location matching
+	if info.locale != nil && !info.locale.match(req) {
+		return false
+	}
+
+	// Check body content matching last, it is the most expensive
+	if info.body != nil && !info.body.match(req) {
+		return false
+	}
+
 	return true
 }
 
@@ -1,31 +1,51 @@
 package route
 
 import (
-	"math/rand"
+	"errors"
+	"fmt"
 	"net/http"
+	"reflect"
 	"sync"
-	"time"
 
+	"nexus/internal/balancer"
 	"nexus/internal/config"
 	"nexus/internal/service"
 )
 
 var (
-	// 为分流功能创建一个全局随机数生成器
-	rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	// ErrRouteExists is returned when adding a route whose name is taken
+	ErrRouteExists = errors.New("route already exists")
+	// ErrRouteNotFound is returned when removing an unknown route
+	ErrRouteNotFound = errors.New("route not found")
+	// ErrNoRoute is reported to error handlers for requests no route matches
+	ErrNoRoute = errors.New("no matching route")
 )
 
 // Router is responsible for matching requests to the corresponding service
 type Router interface {
 	Match(*http.Request) service.Service
+	MatchRoute(*http.Request) (*config.RouteConfig, service.Service)
 	Update(routes []*config.RouteConfig, services map[string]*config.ServiceConfig) error
+	Services() map[string]service.Service
+	Service(name string) (service.Service, bool)
+	AddRoute(route *config.RouteConfig) error
+	RemoveRoute(name string) error
+	UpdateRoutes(fn func(routes []*config.RouteConfig) ([]*config.RouteConfig, error)) error
+	ListRoutes() []*config.RouteConfig
+	Table() []TableEntry
+	SetCacheSize(size int)
+	SetSource(src balancer.Source)
 }
 
 // Add read-write lock to ensure concurrent safety
 type router struct {
 	mu       sync.RWMutex
+	updateMu sync.Mutex // serializes writers, held while the next tree is built
 	services map[string]service.Service
-	tree     *node
+	routes   []*config.RouteConfig
+	tree     *hostTree
+	cache    *matchCache // nil when match caching is disabled
+	source   balancer.Source
 }
 
 // NewRouter Create a new router instance
@@ -37,7 +57,9 @@ func NewRouter(routes []*config.RouteConfig, services map[string]*config.Service
 
 	r := &router{
 		services: serviceMap,
+		routes:   append([]*config.RouteConfig{}, routes...),
 		tree:     buildTree(routes),
+		source:   balancer.DefaultSource,
 	}
 
 	return r
@@ -45,24 +67,110 @@ func NewRouter(routes []*config.RouteConfig, services map[string]*config.Service
 
 // Match Method requires read lock
 func (r *router) Match(req *http.Request) service.Service {
+	_, svc := r.MatchRoute(req)
+	return svc
+}
+
+// MatchRoute returns the matched route config together with the selected service
+func (r *router) MatchRoute(req *http.Request) (*config.RouteConfig, service.Service) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	routeInfo := r.tree.search(req)
+	routeInfo := r.search(req)
 	if routeInfo == nil {
-		return nil
+		return nil, nil
+	}
+
+	if routeInfo.config.Experiment != nil {
+		variant := assignVariant(routeInfo.config, ExperimentKey(req, routeInfo.config), r.source)
+		svc := r.services[variant.Service]
+		if subsetter, ok := svc.(service.Subsetter); ok && variant.Selector != "" {
+			svc = subsetter.Subset(variant.Selector)
+		}
+		return routeInfo.config, svc
 	}
 
 	if len(routeInfo.split) > 0 {
 		// Handle split routing based on weights
-		return r.services[r.selectServiceBySplit(routeInfo)]
+		split := r.selectSplit(routeInfo)
+		svc := r.services[split.Service]
+		if subsetter, ok := svc.(service.Subsetter); ok && split.Selector != "" {
+			svc = subsetter.Subset(split.Selector)
+		}
+		return routeInfo.config, svc
+	}
+
+	return routeInfo.config, r.services[routeInfo.service]
+}
+
+// search looks the request up in the match cache before the tree
+func (r *router) search(req *http.Request) *routeInfo {
+	if r.cache == nil {
+		return r.tree.search(req)
+	}
+
+	key := cacheKey(req)
+	if info, ok := r.cache.get(key); ok {
+		return info
+	}
+	info, cacheable := r.tree.searchCacheable(req)
+	if cacheable {
+		r.cache.add(key, info)
 	}
 
-	return r.services[routeInfo.service]
+	return info
+}
+
+// SetCacheSize enables an LRU cache of size routing decisions, 0 disables it
+func (r *router) SetCacheSize(size int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case size <= 0:
+		r.cache = nil
+	case r.cache == nil || r.cache.size != size:
+		r.cache = newMatchCache(size)
+	}
+}
+
+// SetSource sets the randomness of traffic splits, experiments without a key
+// and the balancers of the services, including services added by updates
+func (r *router) SetSource(src balancer.Source) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.source = src
+	for _, svc := range r.services {
+		if sourced, ok := svc.(service.Sourced); ok {
+			sourced.SetSource(src)
+		}
+	}
+}
+
+// swapTree publishes a new route tree, the caller holds the write lock
+func (r *router) swapTree(tree *hostTree) {
+	r.tree = tree
+	if r.cache != nil {
+		r.cache.purge()
+	}
 }
 
 // Update Implement configuration hot update
 func (r *router) Update(routes []*config.RouteConfig, services map[string]*config.ServiceConfig) error {
+	r.updateMu.Lock()
+	defer r.updateMu.Unlock()
+
+	// Reject dangling references before anything is applied
+	for _, route := range routes {
+		if err := config.ValidateRouteServices(route, services); err != nil {
+			return err
+		}
+	}
+
+	// Build the next tree aside, requests keep matching against the current one
+	tree, routes := r.updatedTree(routes)
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -75,7 +183,11 @@ func (r *router) Update(routes []*config.RouteConfig, services map[string]*confi
 			}
 		} else {
 			// Add new service
-			r.services[name] = service.NewService(conf)
+			svc := service.NewService(conf)
+			if sourced, ok := svc.(service.Sourced); ok {
+				sourced.SetSource(r.source)
+			}
+			r.services[name] = svc
 		}
 	}
 
@@ -86,33 +198,290 @@ func (r *router) Update(routes []*config.RouteConfig, services map[string]*confi
 		}
 	}
 
-	// Update route tree
-	r.tree = buildTree(routes)
+	// Swap in the updated route tree
+	r.routes = routes
+	r.swapTree(tree)
+	return nil
+}
+
+// updatedTree applies the difference between the current and the new routes
+// to a copy of the tree, so unchanged routes keep their compiled state. Routes
+// are told apart by name and compared by value, unchanged routes are returned
+// as the existing instances. It falls back to a full rebuild when names are
+// missing or duplicated, or when unchanged routes were reordered
+func (r *router) updatedTree(routes []*config.RouteConfig) (*hostTree, []*config.RouteConfig) {
+	rebuild := func() (*hostTree, []*config.RouteConfig) {
+		return buildTree(routes), append([]*config.RouteConfig{}, routes...)
+	}
+	if !uniqueNames(r.routes) {
+		return rebuild()
+	}
+	index, ok := routeIndex(routes)
+	if !ok {
+		return rebuild()
+	}
+
+	// Unchanged routes keep their place in the tree, so their order must hold
+	next := make([]*config.RouteConfig, len(routes))
+	last := -1
+	for _, route := range r.routes {
+		i, ok := index[route.Name]
+		if !ok || (route != routes[i] && !reflect.DeepEqual(route, routes[i])) {
+			continue
+		}
+		if i < last {
+			return rebuild()
+		}
+		last = i
+		next[i] = route
+	}
+
+	tree := r.tree
+	for _, route := range r.routes {
+		if i, ok := index[route.Name]; !ok || next[i] != route {
+			tree = tree.withoutRoute(route.Match.Host, route.Match.Path, route.Name)
+		}
+	}
+	for i, route := range routes {
+		if next[i] == nil {
+			tree = tree.withRoute(newRouteInfo(route), index)
+			next[i] = route
+		}
+	}
+
+	return tree, next
+}
+
+// routeIndex maps route names to their position, ok is false when a name is
+// missing or duplicated
+func routeIndex(routes []*config.RouteConfig) (map[string]int, bool) {
+	index := make(map[string]int, len(routes))
+	for i, route := range routes {
+		if route.Name == "" {
+			return nil, false
+		}
+		if _, ok := index[route.Name]; ok {
+			return nil, false
+		}
+		index[route.Name] = i
+	}
+
+	return index, true
+}
+
+// uniqueNames reports whether every route has a name of its own
+func uniqueNames(routes []*config.RouteConfig) bool {
+	_, ok := routeIndex(routes)
+	return ok
+}
+
+// AddRoute registers a route after the existing ones, its services must be
+// registered already
+func (r *router) AddRoute(route *config.RouteConfig) error {
+	r.updateMu.Lock()
+	defer r.updateMu.Unlock()
+
+	if route.Name == "" {
+		return errors.New("route name cannot be empty")
+	}
+	for _, existing := range r.routes {
+		if existing.Name == route.Name {
+			return fmt.Errorf("%w: %s", ErrRouteExists, route.Name)
+		}
+	}
+	if err := r.checkRoute(route); err != nil {
+		return err
+	}
+
+	// The new route sorts after the others of its node without an explicit order
+	tree := r.tree.withRoute(newRouteInfo(route), nil)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.routes = append(r.routes[:len(r.routes):len(r.routes)], route)
+	r.swapTree(tree)
+	return nil
+}
+
+// UpdateRoutes replaces the routes with those fn returns for the current
+// ones. No other route change runs in between, and an error of fn or of the
+// returned routes leaves the routes unchanged. Routes need distinct names and
+// registered services
+func (r *router) UpdateRoutes(fn func(routes []*config.RouteConfig) ([]*config.RouteConfig, error)) error {
+	r.updateMu.Lock()
+	defer r.updateMu.Unlock()
+
+	routes, err := fn(append([]*config.RouteConfig{}, r.routes...))
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		if names[route.Name] {
+			return fmt.Errorf("%w: %s", ErrRouteExists, route.Name)
+		}
+		names[route.Name] = true
+		if err := r.checkRoute(route); err != nil {
+			return err
+		}
+	}
+
+	tree, routes := r.updatedTree(routes)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.routes = routes
+	r.swapTree(tree)
+	return nil
+}
+
+// checkRoute checks that a route is named and its services are registered,
+// the caller holds updateMu
+func (r *router) checkRoute(route *config.RouteConfig) error {
+	if route.Name == "" {
+		return errors.New("route name cannot be empty")
+	}
+	if len(route.Split) == 0 && route.Experiment == nil {
+		if _, ok := r.services[route.Service]; !ok {
+			return fmt.Errorf("route %s: %w", route.Name, &config.ServiceNotFoundError{Service: route.Service})
+		}
+	}
+	for _, split := range route.Split {
+		if err := r.checkTarget(route, split.Service, split.Selector); err != nil {
+			return err
+		}
+	}
+	if route.Experiment != nil {
+		for _, variant := range route.Experiment.Variants {
+			if err := r.checkTarget(route, variant.Service, variant.Selector); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkTarget checks that a split or experiment variant service of a route is
+// registered and its selector is valid
+func (r *router) checkTarget(route *config.RouteConfig, serviceName, selector string) error {
+	if _, ok := r.services[serviceName]; !ok {
+		return fmt.Errorf("route %s: %w", route.Name, &config.ServiceNotFoundError{Service: serviceName})
+	}
+	if selector != "" {
+		if _, err := config.ParseSelector(selector); err != nil {
+			return fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	}
 	return nil
 }
 
-// buildTree Build radix tree
-func buildTree(routes []*config.RouteConfig) *node {
-	tree := newNode()
+// RemoveRoute unregisters a route by name
+func (r *router) RemoveRoute(name string) error {
+	r.updateMu.Lock()
+	defer r.updateMu.Unlock()
+
+	for i, route := range r.routes {
+		if route.Name == name {
+			routes := append(r.routes[:i:i], r.routes[i+1:]...)
+			var tree *hostTree
+			if uniqueNames(r.routes) {
+				tree = r.tree.withoutRoute(route.Match.Host, route.Match.Path, name)
+			} else {
+				// Removing by name would drop routes sharing it
+				tree = buildTree(routes)
+			}
+
+			r.mu.Lock()
+			defer r.mu.Unlock()
+
+			r.routes = routes
+			r.swapTree(tree)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrRouteNotFound, name)
+}
+
+// ListRoutes returns the registered routes in match order
+func (r *router) ListRoutes() []*config.RouteConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]*config.RouteConfig{}, r.routes...)
+}
+
+// Services returns a snapshot of the registered services
+func (r *router) Services() map[string]service.Service {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	services := make(map[string]service.Service, len(r.services))
+	for name, svc := range r.services {
+		services[name] = svc
+	}
+
+	return services
+}
+
+// Service returns a registered service by name, without copying the
+// services like Services does
+func (r *router) Service(name string) (service.Service, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	svc, ok := r.services[name]
+	return svc, ok
+}
+
+// buildTree Build the per-host radix trees
+func buildTree(routes []*config.RouteConfig) *hostTree {
+	tree := newHostTree()
 
 	for _, route := range routes {
-		tree.insert(route.Match.Path, &routeInfo{
-			method:  route.Match.Method,
-			host:    route.Match.Host,
-			headers: route.Match.Headers,
-			service: route.Service,
-			split:   route.Split,
-		})
+		tree.insert(newRouteInfo(route))
 	}
 
 	return tree
 }
 
+// newRouteInfo compiles the match conditions of a route
+func newRouteInfo(route *config.RouteConfig) *routeInfo {
+	return &routeInfo{
+		method:  route.Match.Method,
+		host:    route.Match.Host,
+		headers: route.Match.Headers,
+		service: route.Service,
+		split:   route.Split,
+		config:  route,
+		body:    newBodyMatcher(route.Match.Body),
+		locale:  newLocaleMatcher(route.Match),
+		time:    newTimeMatcher(route.Match.Time),
+		tls:     newTLSMatcher(route.Match.TLS),
+		tags:    tagCondition(route.Match.Tags),
+	}
+}
+
+// tagCondition returns the tags a route requires, nil without any
+func tagCondition(tags map[string]string) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
+
 // selectServiceBySplit selects a service based on the configured weights
 func (r *router) selectServiceBySplit(routeInfo *routeInfo) string {
+	return r.selectSplit(routeInfo).Service
+}
+
+// selectSplit selects a split target based on the configured weights
+func (r *router) selectSplit(routeInfo *routeInfo) *config.RouteSplit {
 	// If there's only one split entry, return it directly
 	if len(routeInfo.split) == 1 {
-		return routeInfo.split[0].Service
+		return routeInfo.split[0]
 	}
 
 	// Calculate total weight
@@ -123,21 +492,21 @@ func (r *router) selectServiceBySplit(routeInfo *routeInfo) string {
 
 	// If total weight is 0, return the first service (should not happen)
 	if totalWeight == 0 {
-		return routeInfo.split[0].Service
+		return routeInfo.split[0]
 	}
 
 	// Generate a random number between 0 and totalWeight
-	randomWeight := rng.Intn(totalWeight)
+	randomWeight := r.source.IntN(totalWeight)
 
 	// Select service based on weight
 	currentWeight := 0
 	for _, split := range routeInfo.split {
 		currentWeight += split.Weight
 		if randomWeight < currentWeight {
-			return split.Service
+			return split
 		}
 	}
 
 	// Fallback to the first service (should not happen)
-	return routeInfo.split[0].Service
+	return routeInfo.split[0]
 }
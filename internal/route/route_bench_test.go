@@ -2,6 +2,7 @@ package route
 
 import (
 	"net/http/httptest"
+	"strconv"
 	"testing"
 
 	"nexus/internal/config"
@@ -360,3 +361,94 @@ func BenchmarkSelectServiceBySplit(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkRouter_UpdateOneRoute(b *testing.B) {
+	services := map[string]*config.ServiceConfig{
+		"service_a": {Name: "service_a", BalancerType: "round_robin"},
+		"service_b": {Name: "service_b", BalancerType: "round_robin"},
+	}
+	// Reloads parse a fresh config, so every route is a new instance
+	newRoutes := func(service string) []*config.RouteConfig {
+		routes := make([]*config.RouteConfig, 10000)
+		for i := range routes {
+			routes[i] = &config.RouteConfig{
+				Name:    "route_" + strconv.Itoa(i),
+				Service: "service_a",
+				Match: config.RouteMatch{
+					Path:    "/api/" + strconv.Itoa(i%100) + "/items/" + strconv.Itoa(i),
+					Headers: map[string]string{"X-Tenant": strconv.Itoa(i % 7)},
+					Body:    &config.BodyMatch{Pattern: `"tenant":\s*"` + strconv.Itoa(i) + `"`},
+				},
+			}
+		}
+		routes[5000].Service = service
+		return routes
+	}
+	configs := [][]*config.RouteConfig{newRoutes("service_a"), newRoutes("service_b")}
+
+	b.Run("Incremental", func(b *testing.B) {
+		rt := NewRouter(configs[0], services)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			rt.Update(configs[(i+1)%2], services)
+		}
+	})
+
+	b.Run("Rebuild", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			buildTree(configs[(i+1)%2])
+		}
+	})
+}
+
+func BenchmarkRouter_MatchCache(b *testing.B) {
+	services := map[string]*config.ServiceConfig{
+		"service_a": {Name: "service_a", BalancerType: "round_robin"},
+	}
+	routes := make([]*config.RouteConfig, 1000)
+	for i := range routes {
+		routes[i] = &config.RouteConfig{
+			Name:    "route_" + strconv.Itoa(i),
+			Service: "service_a",
+			Match: config.RouteMatch{
+				Path:   "/api/" + strconv.Itoa(i%50) + "/items/" + strconv.Itoa(i),
+				Method: "GET",
+			},
+		}
+	}
+	// A handful of wildcard routes make misses of the exact lookup expensive
+	for i := 0; i < 20; i++ {
+		routes = append(routes, &config.RouteConfig{
+			Name:    "prefix_" + strconv.Itoa(i),
+			Service: "service_a",
+			Match:   config.RouteMatch{Path: "/static/" + strconv.Itoa(i) + "/*"},
+		})
+	}
+	requests := []struct {
+		name string
+		path string
+	}{
+		{name: "ExactPath", path: "/api/7/items/507"},
+		{name: "WildcardPath", path: "/static/19/css/app.css"},
+	}
+
+	for _, req := range requests {
+		for _, size := range []int{0, 1024} {
+			name := req.name + "/Uncached"
+			if size > 0 {
+				name = req.name + "/Cached"
+			}
+			b.Run(name, func(b *testing.B) {
+				rt := NewRouter(routes, services)
+				rt.SetCacheSize(size)
+				r := httptest.NewRequest("GET", req.path, nil)
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					rt.Match(r)
+				}
+			})
+		}
+	}
+}
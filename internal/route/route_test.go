@@ -1,14 +1,23 @@
 package route
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"nexus/internal/balancer"
+	"nexus/internal/classify"
 	"nexus/internal/config"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRouter_Match(t *testing.T) {
@@ -439,6 +448,216 @@ func TestRouter_Update(t *testing.T) {
 			t.Errorf("Partial update failed to apply new route")
 		}
 	})
+
+	// Test rejected update
+	t.Run("Dangling service reference", func(t *testing.T) {
+		for _, route := range []*config.RouteConfig{
+			{Name: "orphan", Service: "missing", Match: config.RouteMatch{Path: "/orphan"}},
+			{Name: "orphan_split", Match: config.RouteMatch{Path: "/orphan"}, Split: []*config.RouteSplit{
+				{Service: "service_c", Weight: 50},
+				{Service: "missing", Weight: 50},
+			}},
+		} {
+			err := rt.Update([]*config.RouteConfig{route}, map[string]*config.ServiceConfig{
+				"service_c": {Name: "service_c", BalancerType: "round_robin"},
+			})
+			if err == nil || !strings.Contains(err.Error(), "missing not found") {
+				t.Errorf("Expected dangling reference of %s to be rejected, got %v", route.Name, err)
+			}
+		}
+
+		// The previous routes keep serving
+		req := httptest.NewRequest("PATCH", "/partial", nil)
+		if svc := rt.Match(req); svc == nil || svc.Name() != "service_c" {
+			t.Errorf("Rejected update changed the routes")
+		}
+	})
+}
+
+func TestRouter_AddRemoveRoute(t *testing.T) {
+	rt := NewRouter([]*config.RouteConfig{
+		{
+			Name:    "existing",
+			Service: "service_a",
+			Match:   config.RouteMatch{Path: "/existing"},
+		},
+	}, map[string]*config.ServiceConfig{
+		"service_a": {Name: "service_a", BalancerType: "round_robin"},
+		"service_b": {Name: "service_b", BalancerType: "round_robin"},
+	})
+
+	added := &config.RouteConfig{
+		Name:    "added",
+		Service: "service_b",
+		Match:   config.RouteMatch{Path: "/added/*"},
+	}
+	assert.NoError(t, rt.AddRoute(added))
+	assert.Equal(t, "service_b", rt.Match(httptest.NewRequest("GET", "/added/item", nil)).Name())
+	assert.Equal(t, "service_a", rt.Match(httptest.NewRequest("GET", "/existing", nil)).Name())
+
+	routes := rt.ListRoutes()
+	assert.Len(t, routes, 2)
+	assert.Equal(t, "added", routes[1].Name)
+
+	assert.ErrorIs(t, rt.AddRoute(added), ErrRouteExists)
+	err := rt.AddRoute(&config.RouteConfig{Name: "orphan", Service: "missing"})
+	assert.EqualError(t, err, "route orphan: service missing not found")
+	var notFound *config.ServiceNotFoundError
+	if assert.ErrorAs(t, err, &notFound) {
+		assert.Equal(t, "missing", notFound.Service)
+	}
+	assert.ErrorIs(t, err, config.ErrServiceNotFound)
+	assert.ErrorContains(t, rt.AddRoute(&config.RouteConfig{
+		Name:  "orphan_split",
+		Split: []*config.RouteSplit{{Service: "service_a", Weight: 1}, {Service: "missing", Weight: 1}},
+	}), "service missing not found")
+
+	assert.NoError(t, rt.RemoveRoute("existing"))
+	assert.Nil(t, rt.Match(httptest.NewRequest("GET", "/existing", nil)))
+	assert.Equal(t, "service_b", rt.Match(httptest.NewRequest("GET", "/added/item", nil)).Name())
+	assert.ErrorIs(t, rt.RemoveRoute("existing"), ErrRouteNotFound)
+
+	// The listed routes are a snapshot
+	routes = rt.ListRoutes()
+	routes[0] = nil
+	assert.NotNil(t, rt.ListRoutes()[0])
+}
+
+func TestRouter_UpdateRoutes(t *testing.T) {
+	existing := &config.RouteConfig{Name: "existing", Service: "service_a", Match: config.RouteMatch{Path: "/existing"}}
+	rt := NewRouter([]*config.RouteConfig{existing}, map[string]*config.ServiceConfig{
+		"service_a": {Name: "service_a", BalancerType: "round_robin"},
+		"service_b": {Name: "service_b", BalancerType: "round_robin"},
+	})
+
+	err := rt.UpdateRoutes(func(routes []*config.RouteConfig) ([]*config.RouteConfig, error) {
+		assert.Equal(t, []*config.RouteConfig{existing}, routes)
+		return append(routes, &config.RouteConfig{Name: "added", Service: "service_b", Match: config.RouteMatch{Path: "/added/*"}}), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "service_b", rt.Match(httptest.NewRequest("GET", "/added/item", nil)).Name())
+	assert.Same(t, existing, rt.ListRoutes()[0], "unchanged routes keep their instance")
+
+	// A failing update leaves the routes as they were
+	failed := errors.New("rejected")
+	assert.ErrorIs(t, rt.UpdateRoutes(func(routes []*config.RouteConfig) ([]*config.RouteConfig, error) {
+		return nil, failed
+	}), failed)
+	assert.ErrorIs(t, rt.UpdateRoutes(func(routes []*config.RouteConfig) ([]*config.RouteConfig, error) {
+		return append(routes, &config.RouteConfig{Name: "existing", Service: "service_b", Match: config.RouteMatch{Path: "/other"}}), nil
+	}), ErrRouteExists)
+	assert.ErrorIs(t, rt.UpdateRoutes(func(routes []*config.RouteConfig) ([]*config.RouteConfig, error) {
+		return []*config.RouteConfig{{Name: "orphan", Service: "missing", Match: config.RouteMatch{Path: "/"}}}, nil
+	}), config.ErrServiceNotFound)
+	assert.Len(t, rt.ListRoutes(), 2)
+
+	require.NoError(t, rt.UpdateRoutes(func(routes []*config.RouteConfig) ([]*config.RouteConfig, error) {
+		return nil, nil
+	}))
+	assert.Empty(t, rt.ListRoutes())
+	assert.Nil(t, rt.Match(httptest.NewRequest("GET", "/existing", nil)))
+}
+
+func TestRouter_AddRouteConcurrent(t *testing.T) {
+	rt := NewRouter(nil, map[string]*config.ServiceConfig{
+		"service_a": {Name: "service_a", BalancerType: "round_robin"},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			rt.Match(httptest.NewRequest("GET", "/route/1", nil))
+			rt.ListRoutes()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		name := "route_" + strconv.Itoa(i)
+		assert.NoError(t, rt.AddRoute(&config.RouteConfig{
+			Name:    name,
+			Service: "service_a",
+			Match:   config.RouteMatch{Path: "/route/" + strconv.Itoa(i)},
+		}))
+	}
+	<-done
+
+	assert.Len(t, rt.ListRoutes(), 100)
+}
+
+func TestRouter_IncrementalUpdate(t *testing.T) {
+	services := map[string]*config.ServiceConfig{
+		"service_a": {Name: "service_a", BalancerType: "round_robin"},
+		"service_b": {Name: "service_b", BalancerType: "round_robin"},
+	}
+	newRoutes := func() []*config.RouteConfig {
+		return []*config.RouteConfig{
+			{Name: "kept", Service: "service_a", Match: config.RouteMatch{Path: "/kept"}},
+			{Name: "changed", Service: "service_a", Match: config.RouteMatch{Path: "/changed"}},
+			{Name: "removed", Service: "service_a", Match: config.RouteMatch{Path: "/removed/deep"}},
+			{Name: "tagged", Service: "service_a", Match: config.RouteMatch{Path: "/shared", Headers: map[string]string{"X-Tag": "a"}}},
+			{Name: "fallback", Service: "service_a", Match: config.RouteMatch{Path: "/shared"}},
+		}
+	}
+	rt := NewRouter(newRoutes(), services).(*router)
+	request := func(path, tag string) *http.Request {
+		req := httptest.NewRequest("GET", path, nil)
+		if tag != "" {
+			req.Header.Set("X-Tag", tag)
+		}
+		return req
+	}
+	keptInfo := rt.tree.search(request("/kept", ""))
+	oldTree := rt.tree
+
+	routes := newRoutes()
+	routes[1].Service = "service_b"
+	routes = append(routes[:2], routes[3:]...)
+	// Inserted before the catch-all route of the same path
+	routes = append(routes[:3], append([]*config.RouteConfig{
+		{Name: "added", Service: "service_b", Match: config.RouteMatch{Path: "/shared", Headers: map[string]string{"X-Tag": "b"}}},
+	}, routes[3:]...)...)
+	assert.NoError(t, rt.Update(routes, services))
+
+	assert.Same(t, keptInfo, rt.tree.search(request("/kept", "")), "unchanged routes keep their compiled state")
+	assert.Equal(t, "service_b", rt.Match(request("/changed", "")).Name())
+	assert.Nil(t, rt.Match(request("/removed/deep", "")))
+	assert.Equal(t, "added", rt.tree.search(request("/shared", "b")).config.Name)
+	assert.Equal(t, "tagged", rt.tree.search(request("/shared", "a")).config.Name)
+	assert.Equal(t, "fallback", rt.tree.search(request("/shared", "")).config.Name)
+
+	// Empty nodes are pruned
+	for _, child := range rt.tree.any.children {
+		assert.NotEqual(t, "removed", child.part)
+	}
+
+	// The previous tree is left untouched for requests still using it
+	assert.Equal(t, "service_a", oldTree.search(request("/changed", "")).service)
+	assert.NotNil(t, oldTree.search(request("/removed/deep", "")))
+	assert.Equal(t, "fallback", oldTree.search(request("/shared", "b")).config.Name)
+
+	names := make([]string, 0)
+	for _, route := range rt.ListRoutes() {
+		names = append(names, route.Name)
+	}
+	assert.Equal(t, []string{"kept", "changed", "tagged", "added", "fallback"}, names)
+}
+
+func TestRouter_UpdateReorderedRoutes(t *testing.T) {
+	services := map[string]*config.ServiceConfig{
+		"service_a": {Name: "service_a", BalancerType: "round_robin"},
+		"service_b": {Name: "service_b", BalancerType: "round_robin"},
+	}
+	tagged := &config.RouteConfig{Name: "tagged", Service: "service_a", Match: config.RouteMatch{Path: "/shared", Headers: map[string]string{"X-Tag": "a"}}}
+	fallback := &config.RouteConfig{Name: "fallback", Service: "service_b", Match: config.RouteMatch{Path: "/shared"}}
+	rt := NewRouter([]*config.RouteConfig{tagged, fallback}, services)
+
+	req := httptest.NewRequest("GET", "/shared", nil)
+	req.Header.Set("X-Tag", "a")
+	assert.Equal(t, "service_a", rt.Match(req).Name())
+
+	// Moving the catch-all route first changes the match order
+	assert.NoError(t, rt.Update([]*config.RouteConfig{fallback, tagged}, services))
+	assert.Equal(t, "service_b", rt.Match(req).Name())
 }
 
 func TestRouteSplit(t *testing.T) {
@@ -548,4 +767,286 @@ func TestRouteSplit(t *testing.T) {
 			t.Errorf("Expected service-a, got %s", service.Name())
 		}
 	})
+
+	t.Run("TestSelectorSplit", func(t *testing.T) {
+		route := NewRouter([]*config.RouteConfig{
+			{
+				Name:  "selector-split-route",
+				Match: config.RouteMatch{Path: "/api/*"},
+				Split: []*config.RouteSplit{
+					{Service: "service-a", Weight: 100, Selector: "version=v2"},
+				},
+			},
+		}, map[string]*config.ServiceConfig{
+			"service-a": {Name: "service-a", BalancerType: "round_robin", Servers: []config.ServerConfig{
+				{Address: "http://v1:8080", Labels: map[string]string{"version": "v1"}},
+				{Address: "http://v2:8080", Labels: map[string]string{"version": "v2"}},
+			}},
+		})
+
+		for i := 0; i < 4; i++ {
+			service := route.Match(req)
+			if service == nil {
+				t.Fatalf("Request should match route")
+			}
+			assert.Equal(t, "service-a", service.Name())
+			addr, err := service.NextServer(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, "http://v2:8080", addr)
+		}
+	})
+}
+
+func TestRouteSplit_Concurrent(t *testing.T) {
+	rt := NewRouter([]*config.RouteConfig{
+		{
+			Name:  "split-route",
+			Match: config.RouteMatch{Path: "/api/*"},
+			Split: []*config.RouteSplit{
+				{Service: "service-a", Weight: 50},
+				{Service: "service-b", Weight: 30},
+				{Service: "service-c", Weight: 20},
+			},
+		},
+	}, map[string]*config.ServiceConfig{
+		"service-a": {Name: "service-a", BalancerType: "round_robin"},
+		"service-b": {Name: "service-b", BalancerType: "round_robin"},
+		"service-c": {Name: "service-c", BalancerType: "round_robin"},
+	})
+
+	// Split decisions run concurrently on every request, run with -race
+	const goroutines, iterations = 16, 500
+	var mu sync.Mutex
+	serviceCount := make(map[string]int)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			counts := make(map[string]int)
+			for i := 0; i < iterations; i++ {
+				svc := rt.Match(httptest.NewRequest("GET", "/api/users", nil))
+				if svc == nil {
+					t.Error("Request should match route")
+					return
+				}
+				counts[svc.Name()]++
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for name, count := range counts {
+				serviceCount[name] += count
+			}
+		}()
+	}
+	wg.Wait()
+
+	total := float64(goroutines * iterations)
+	for name, share := range map[string]float64{"service-a": 0.5, "service-b": 0.3, "service-c": 0.2} {
+		assert.InDelta(t, share, float64(serviceCount[name])/total, 0.05, name)
+	}
+}
+
+func TestRouteSplit_SeededSource(t *testing.T) {
+	routes := []*config.RouteConfig{
+		{
+			Name:  "split-route",
+			Match: config.RouteMatch{Path: "/api/*"},
+			Split: []*config.RouteSplit{
+				{Service: "service-a", Weight: 50},
+				{Service: "service-b", Weight: 50},
+			},
+		},
+	}
+	services := map[string]*config.ServiceConfig{
+		"service-a": {Name: "service-a", BalancerType: "round_robin"},
+		"service-b": {Name: "service-b", BalancerType: "round_robin"},
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	splits := func() []string {
+		rt := NewRouter(routes, services)
+		rt.SetSource(balancer.NewSeededSource(42, clock))
+		names := make([]string, 0, 20)
+		for i := 0; i < 20; i++ {
+			names = append(names, rt.Match(httptest.NewRequest("GET", "/api/users", nil)).Name())
+		}
+		return names
+	}
+	first := splits()
+	assert.Equal(t, first, splits())
+	assert.Contains(t, first, "service-a")
+	assert.Contains(t, first, "service-b")
+
+	// Services added by an update get the source as well
+	rt := NewRouter(routes, services)
+	rt.SetSource(balancer.NewSeededSource(42, clock))
+	require.NoError(t, rt.Update(routes, map[string]*config.ServiceConfig{
+		"service-a": services["service-a"],
+		"service-b": services["service-b"],
+		"service-c": {Name: "service-c", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: "http://c:8080"}}},
+	}))
+	svc, ok := rt.Service("service-c")
+	require.True(t, ok)
+	_, ok = rt.Service("service-d")
+	assert.False(t, ok)
+	_, err := svc.NextServer(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, now, svc.Balancer().Stats()["http://c:8080"].LastSelected.UTC())
+}
+
+func TestRouter_MethodIndex(t *testing.T) {
+	routes := []*config.RouteConfig{
+		{Name: "get_items", Match: config.RouteMatch{Path: "/items", Method: "GET"}},
+		{Name: "any_items", Match: config.RouteMatch{Path: "/items"}},
+		{Name: "post_items", Match: config.RouteMatch{Path: "/items", Method: "POST"}},
+		{Name: "get_only", Match: config.RouteMatch{Path: "/reports", Method: "GET"}},
+		{Name: "get_files", Match: config.RouteMatch{Path: "/files/*", Method: "GET"}},
+		{Name: "post_uploads", Match: config.RouteMatch{Path: "/files/uploads/*", Method: "POST"}},
+	}
+	services := map[string]*config.ServiceConfig{}
+	for _, route := range routes {
+		route.Service = route.Name
+		services[route.Name] = &config.ServiceConfig{Name: route.Name, BalancerType: "round_robin"}
+	}
+	rt := NewRouter(routes, services).(*router)
+
+	// Routes without a method are kept in order among the method's routes
+	node := rt.tree.any.children[0]
+	require.Equal(t, "items", node.part)
+	names := func(infos []*routeInfo) []string {
+		names := make([]string, 0, len(infos))
+		for _, info := range infos {
+			names = append(names, info.config.Name)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"get_items", "any_items"}, names(node.routesFor("GET")))
+	assert.Equal(t, []string{"any_items", "post_items"}, names(node.routesFor("POST")))
+	assert.Equal(t, []string{"any_items"}, names(node.routesFor("DELETE")))
+
+	tests := []struct {
+		method   string
+		path     string
+		expected string
+	}{
+		{method: "GET", path: "/items", expected: "get_items"},
+		{method: "POST", path: "/items", expected: "any_items"},
+		{method: "DELETE", path: "/items", expected: "any_items"},
+		{method: "GET", path: "/reports", expected: "get_only"},
+		// A single route of another method no longer matches
+		{method: "POST", path: "/reports"},
+		// The longest wildcard of another method doesn't shadow the request's method
+		{method: "GET", path: "/files/uploads/a.txt", expected: "get_files"},
+		{method: "POST", path: "/files/uploads/a.txt", expected: "post_uploads"},
+		{method: "POST", path: "/files/other.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			svc := rt.Match(httptest.NewRequest(tt.method, tt.path, nil))
+			if tt.expected == "" {
+				assert.Nil(t, svc)
+				return
+			}
+			require.NotNil(t, svc)
+			assert.Equal(t, tt.expected, svc.Name())
+		})
+	}
+
+	// Incremental updates keep the index in sync
+	require.NoError(t, rt.RemoveRoute("get_items"))
+	assert.Equal(t, "any_items", rt.Match(httptest.NewRequest("GET", "/items", nil)).Name())
+	require.NoError(t, rt.AddRoute(&config.RouteConfig{Name: "delete_items", Service: "get_items", Match: config.RouteMatch{Path: "/items", Method: "DELETE"}}))
+	assert.Equal(t, "any_items", rt.Match(httptest.NewRequest("DELETE", "/items", nil)).Name())
+}
+
+func TestRouter_WildcardConditions(t *testing.T) {
+	routes := []*config.RouteConfig{
+		{Name: "german_docs", Match: config.RouteMatch{Path: "/docs/*", Languages: []string{"de"}}, Service: "de"},
+		{Name: "mobile_api", Match: config.RouteMatch{Path: "/api/v1/*", Tags: map[string]string{"class": "mobile"}}, Service: "mobile"},
+		{Name: "api", Match: config.RouteMatch{Path: "/api/*"}, Service: "default"},
+		{Name: "catch_all", Match: config.RouteMatch{Path: "/*"}, Service: "default"},
+	}
+	services := make(map[string]*config.ServiceConfig)
+	for _, name := range []string{"de", "mobile", "default"} {
+		services[name] = &config.ServiceConfig{Name: name, Servers: []config.ServerConfig{{Address: "http://" + name}}}
+	}
+	router := NewRouter(routes, services)
+	router.SetCacheSize(16)
+
+	match := func(path string, req func(*http.Request) *http.Request) string {
+		r := req(httptest.NewRequest(http.MethodGet, path, nil))
+		route, _ := router.MatchRoute(r)
+		require.NotNil(t, route, path)
+		return route.Name
+	}
+	german := func(r *http.Request) *http.Request {
+		r.Header.Set("Accept-Language", "de")
+		return r
+	}
+	english := func(r *http.Request) *http.Request {
+		r.Header.Set("Accept-Language", "en")
+		return r
+	}
+	mobile := func(r *http.Request) *http.Request {
+		return r.WithContext(classify.NewContext(r.Context(), map[string]string{"class": "mobile"}))
+	}
+	untagged := func(r *http.Request) *http.Request { return r }
+
+	// Routes of the longest prefix whose conditions don't match leave the
+	// request to shorter prefixes, and the result isn't cached
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, "german_docs", match("/docs/intro", german))
+		assert.Equal(t, "catch_all", match("/docs/intro", english))
+		assert.Equal(t, "mobile_api", match("/api/v1/users", mobile))
+		assert.Equal(t, "api", match("/api/v1/users", untagged))
+	}
+}
+
+func TestRouter_MatchTags(t *testing.T) {
+	routes := []*config.RouteConfig{
+		{Name: "mobile", Match: config.RouteMatch{Path: "/app", Tags: map[string]string{"class": "mobile"}}, Service: "mobile"},
+		{Name: "bots", Match: config.RouteMatch{Path: "/app", Tags: map[string]string{"class": "desktop", "bot": "true"}}, Service: "bots"},
+		{Name: "default", Match: config.RouteMatch{Path: "/app"}, Service: "default"},
+	}
+	// The same routes on a wildcard path
+	for _, route := range append([]*config.RouteConfig{}, routes...) {
+		wildcard := *route
+		wildcard.Name += "_wildcard"
+		wildcard.Match.Path = "/static/*"
+		routes = append(routes, &wildcard)
+	}
+	services := make(map[string]*config.ServiceConfig)
+	for _, name := range []string{"mobile", "bots", "default"} {
+		services[name] = &config.ServiceConfig{Name: name, Servers: []config.ServerConfig{{Address: "http://" + name}}}
+	}
+	router := NewRouter(routes, services)
+	router.SetCacheSize(16)
+
+	tests := []struct {
+		name     string
+		tags     map[string]string
+		expected string
+	}{
+		{"Tag", map[string]string{"class": "mobile", "bot": "true"}, "mobile"},
+		{"Every tag", map[string]string{"class": "desktop", "bot": "true"}, "bots"},
+		{"Missing tag", map[string]string{"class": "desktop"}, "default"},
+		{"Untagged", nil, "default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, path := range []string{"/app", "/static/app.js"} {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				if tt.tags != nil {
+					req = req.WithContext(classify.NewContext(req.Context(), tt.tags))
+				}
+
+				svc := router.Match(req)
+				require.NotNil(t, svc, path)
+				assert.Equal(t, tt.expected, svc.Name(), path)
+			}
+		})
+	}
 }
@@ -0,0 +1,133 @@
+package route
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"nexus/internal/config"
+)
+
+// DefaultSampleSize is the number of recent requests kept by a sampler
+const DefaultSampleSize = 1000
+
+// Signature is the routing relevant part of a request
+type Signature struct {
+	Method string `json:"method"`
+	Host   string `json:"host"`
+	Path   string `json:"path"`
+}
+
+// Sampler keeps the signatures of the most recent requests in a ring buffer
+type Sampler struct {
+	mu    sync.Mutex
+	ring  []Signature
+	next  int
+	count int
+}
+
+// NewSampler creates a sampler of the given number of requests
+func NewSampler(size int) *Sampler {
+	if size <= 0 {
+		size = DefaultSampleSize
+	}
+
+	return &Sampler{ring: make([]Signature, size)}
+}
+
+// Record adds the signature of a request
+func (s *Sampler) Record(req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ring[s.next] = Signature{Method: req.Method, Host: req.Host, Path: req.URL.Path}
+	s.next = (s.next + 1) % len(s.ring)
+	if s.count < len(s.ring) {
+		s.count++
+	}
+}
+
+// Signatures returns the distinct recorded signatures, most recent first
+func (s *Sampler) Signatures() []Signature {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[Signature]bool, s.count)
+	signatures := make([]Signature, 0, s.count)
+	for i := 1; i <= s.count; i++ {
+		sig := s.ring[(s.next-i+len(s.ring))%len(s.ring)]
+		if !seen[sig] {
+			seen[sig] = true
+			signatures = append(signatures, sig)
+		}
+	}
+
+	return signatures
+}
+
+// Decision is the routing of a request signature by the current and a
+// candidate configuration
+type Decision struct {
+	Signature
+	Route            string `json:"route,omitempty"`
+	Service          string `json:"service,omitempty"`
+	CandidateRoute   string `json:"candidate_route,omitempty"`
+	CandidateService string `json:"candidate_service,omitempty"`
+	Changed          bool   `json:"changed"`
+}
+
+// Simulate routes request signatures with the current and a candidate router,
+// the candidate is never applied. Only the method, host and path are matched,
+// so routes that depend on other request attributes may match differently
+func Simulate(current, candidate Router, signatures []Signature) []Decision {
+	decisions := make([]Decision, 0, len(signatures))
+	for _, sig := range signatures {
+		req := sig.request()
+		currentRoute, _ := current.MatchRoute(req)
+		candidateRoute, _ := candidate.MatchRoute(req)
+
+		decision := Decision{Signature: sig}
+		decision.Route, decision.Service = describe(currentRoute)
+		decision.CandidateRoute, decision.CandidateService = describe(candidateRoute)
+		decision.Changed = decision.Route != decision.CandidateRoute || decision.Service != decision.CandidateService
+		decisions = append(decisions, decision)
+	}
+
+	return decisions
+}
+
+// request builds a request reproducing a signature
+func (sig Signature) request() *http.Request {
+	req, err := http.NewRequest(sig.Method, "http://localhost", nil)
+	if err != nil {
+		req, _ = http.NewRequest(http.MethodGet, "http://localhost", nil)
+	}
+	req.Host = sig.Host
+	req.URL.Path = sig.Path
+
+	return req
+}
+
+// describe names the matched route and its service, split and experiment
+// routes list the services they divide traffic between
+func describe(route *config.RouteConfig) (string, string) {
+	if route == nil {
+		return "", ""
+	}
+	if route.Experiment != nil {
+		services := make([]string, 0, len(route.Experiment.Variants))
+		for _, variant := range route.Experiment.Variants {
+			services = append(services, variant.Service)
+		}
+		return route.Name, strings.Join(services, ",")
+	}
+	if len(route.Split) == 0 {
+		return route.Name, route.Service
+	}
+
+	services := make([]string, 0, len(route.Split))
+	for _, split := range route.Split {
+		services = append(services, split.Service)
+	}
+	return route.Name, strings.Join(services, ",")
+}
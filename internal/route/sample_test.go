@@ -0,0 +1,67 @@
+package route
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampler(t *testing.T) {
+	s := NewSampler(3)
+	assert.Empty(t, s.Signatures())
+
+	for _, path := range []string{"/a", "/b", "/a", "/c", "/d"} {
+		s.Record(httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil))
+	}
+
+	// Only the latest requests are kept, without duplicates
+	assert.Equal(t, []Signature{
+		{Method: http.MethodGet, Host: "example.com", Path: "/d"},
+		{Method: http.MethodGet, Host: "example.com", Path: "/c"},
+		{Method: http.MethodGet, Host: "example.com", Path: "/a"},
+	}, s.Signatures())
+}
+
+func TestSimulate(t *testing.T) {
+	services := map[string]*config.ServiceConfig{
+		"web": {Name: "web", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: "http://web"}}},
+		"v2":  {Name: "v2", BalancerType: "round_robin", Servers: []config.ServerConfig{{Address: "http://v2"}}},
+	}
+	current := NewRouter([]*config.RouteConfig{
+		{Name: "web", Match: config.RouteMatch{Path: "/*"}, Service: "web"},
+	}, services)
+	candidate := NewRouter([]*config.RouteConfig{
+		{Name: "web", Match: config.RouteMatch{Path: "/*"}, Service: "web"},
+		{Name: "shop", Match: config.RouteMatch{Host: "shop.example.com", Path: "/*"}, Split: []*config.RouteSplit{
+			{Service: "web", Weight: 90},
+			{Service: "v2", Weight: 10},
+		}},
+	}, services)
+
+	decisions := Simulate(current, candidate, []Signature{
+		{Method: http.MethodGet, Host: "example.com", Path: "/home"},
+		{Method: http.MethodPost, Host: "shop.example.com", Path: "/cart"},
+	})
+
+	assert.Equal(t, []Decision{
+		{
+			Signature:        Signature{Method: http.MethodGet, Host: "example.com", Path: "/home"},
+			Route:            "web",
+			Service:          "web",
+			CandidateRoute:   "web",
+			CandidateService: "web",
+		},
+		{
+			Signature:        Signature{Method: http.MethodPost, Host: "shop.example.com", Path: "/cart"},
+			Route:            "web",
+			Service:          "web",
+			CandidateRoute:   "shop",
+			CandidateService: "web,v2",
+			Changed:          true,
+		},
+	}, decisions)
+}
@@ -0,0 +1,54 @@
+package route
+
+import (
+	"time"
+
+	"nexus/internal/config"
+	"nexus/internal/schedule"
+)
+
+// now returns the current time, replaced in tests
+var now = time.Now
+
+// timeMatcher matches requests arriving within a recurring time window
+type timeMatcher struct {
+	cron     *schedule.Cron
+	duration time.Duration
+	window   *schedule.Window
+	location *time.Location
+}
+
+// newTimeMatcher compiles a time match condition, nil when none is configured
+func newTimeMatcher(match *config.TimeMatch) *timeMatcher {
+	if match == nil {
+		return nil
+	}
+
+	// Conditions are validated when the config is loaded
+	m := &timeMatcher{duration: match.Duration, location: time.UTC}
+	if match.Timezone != "" {
+		if loc, err := time.LoadLocation(match.Timezone); err == nil {
+			m.location = loc
+		}
+	}
+	if match.Cron != "" {
+		m.cron, _ = schedule.ParseCron(match.Cron)
+	} else {
+		m.window, _ = schedule.ParseWindow(match.Weekdays, match.Hours)
+	}
+
+	return m
+}
+
+func (m *timeMatcher) match(t time.Time) bool {
+	t = t.In(m.location)
+
+	switch {
+	case m.cron != nil:
+		return m.cron.ActiveWithin(t, m.duration)
+	case m.window != nil:
+		return m.window.Contains(t)
+	default:
+		return false
+	}
+}
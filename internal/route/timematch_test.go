@@ -0,0 +1,74 @@
+package route
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_MatchTime(t *testing.T) {
+	routes := []*config.RouteConfig{
+		{
+			Name: "business_hours",
+			Match: config.RouteMatch{
+				Path: "/support",
+				Time: &config.TimeMatch{Weekdays: []string{"mon-fri"}, Hours: "09:00-17:00", Timezone: "Europe/Berlin"},
+			},
+			Service: "agents",
+		},
+		{
+			Name: "nightly_batch",
+			Match: config.RouteMatch{
+				Path: "/support",
+				Time: &config.TimeMatch{Cron: "0 2 * * *", Duration: time.Hour},
+			},
+			Service: "batch",
+		},
+		{Name: "off_hours", Match: config.RouteMatch{Path: "/support"}, Service: "chatbot"},
+	}
+	// The same routes on a wildcard path
+	for _, route := range append([]*config.RouteConfig{}, routes...) {
+		wildcard := *route
+		wildcard.Name += "_wildcard"
+		wildcard.Match.Path = "/help/*"
+		routes = append(routes, &wildcard)
+	}
+	services := make(map[string]*config.ServiceConfig)
+	for _, name := range []string{"agents", "batch", "chatbot"} {
+		services[name] = &config.ServiceConfig{Name: name, Servers: []config.ServerConfig{{Address: "http://" + name}}}
+	}
+	router := NewRouter(routes, services)
+	defer func() { now = time.Now }()
+
+	tests := []struct {
+		name     string
+		at       string
+		expected string
+	}{
+		{"Business hours in Berlin", "2025-03-10T08:30:00Z", "agents"}, // 09:30 CET
+		{"Before opening in Berlin", "2025-03-10T07:30:00Z", "chatbot"},
+		{"Weekend", "2025-03-15T10:00:00Z", "chatbot"},
+		{"Cron window", "2025-03-15T02:15:00Z", "batch"},
+		{"After cron window", "2025-03-15T03:00:00Z", "chatbot"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, err := time.Parse(time.RFC3339, tt.at)
+			require.NoError(t, err)
+			now = func() time.Time { return at }
+
+			for _, path := range []string{"/support", "/help/tickets"} {
+				svc := router.Match(httptest.NewRequest(http.MethodGet, path, nil))
+				require.NotNil(t, svc, path)
+				assert.Equal(t, tt.expected, svc.Name(), path)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package route
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"nexus/internal/config"
+)
+
+// tlsMatcher matches the attributes of the TLS connection a request came in on
+type tlsMatcher struct {
+	serverNames   []string
+	protocols     []string
+	commonNames   []string
+	organizations []string
+}
+
+// newTLSMatcher compiles the TLS conditions, nil when none is configured
+func newTLSMatcher(match *config.TLSMatch) *tlsMatcher {
+	if match == nil {
+		return nil
+	}
+
+	m := &tlsMatcher{
+		protocols:     match.ALPN,
+		commonNames:   match.ClientCommonNames,
+		organizations: match.ClientOrganizations,
+	}
+	for _, name := range match.SNI {
+		m.serverNames = append(m.serverNames, strings.ToLower(name))
+	}
+
+	return m
+}
+
+func (m *tlsMatcher) match(req *http.Request) bool {
+	state := req.TLS
+	if state == nil {
+		return false
+	}
+	if len(m.serverNames) > 0 && !MatchServerName(m.serverNames, strings.ToLower(state.ServerName)) {
+		return false
+	}
+	if len(m.protocols) > 0 && !slices.Contains(m.protocols, state.NegotiatedProtocol) {
+		return false
+	}
+
+	if len(m.commonNames) == 0 && len(m.organizations) == 0 {
+		return true
+	}
+	// The listener verified the chain when client_auth is configured
+	if len(state.PeerCertificates) == 0 {
+		return false
+	}
+	subject := state.PeerCertificates[0].Subject
+	if len(m.commonNames) > 0 && !slices.Contains(m.commonNames, subject.CommonName) {
+		return false
+	}
+	if len(m.organizations) > 0 && !slices.ContainsFunc(subject.Organization, func(org string) bool {
+		return slices.Contains(m.organizations, org)
+	}) {
+		return false
+	}
+
+	return true
+}
+
+// MatchServerName matches a lowercase SNI server name against lowercase
+// patterns, "*.example.com" matches the subdomains of example.com but not
+// example.com itself
+func MatchServerName(patterns []string, name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if pattern == name {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasSuffix(name, suffix) && len(name) > len(suffix) {
+			return true
+		}
+	}
+	return false
+}
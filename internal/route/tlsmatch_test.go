@@ -0,0 +1,67 @@
+package route
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_MatchTLS(t *testing.T) {
+	routes := []*config.RouteConfig{
+		{Name: "grpc", Match: config.RouteMatch{Path: "/", TLS: &config.TLSMatch{ALPN: []string{"h2"}, SNI: []string{"grpc.example.com"}}}, Service: "grpc"},
+		{Name: "acme", Match: config.RouteMatch{Path: "/", TLS: &config.TLSMatch{ClientOrganizations: []string{"Acme"}}}, Service: "acme"},
+		{Name: "tenants", Match: config.RouteMatch{Path: "/", TLS: &config.TLSMatch{SNI: []string{"*.tenants.example.com"}}}, Service: "tenants"},
+		{Name: "default", Match: config.RouteMatch{Path: "/"}, Service: "default"},
+	}
+	// The same routes on a wildcard path
+	for _, route := range append([]*config.RouteConfig{}, routes...) {
+		wildcard := *route
+		wildcard.Name += "_wildcard"
+		wildcard.Match.Path = "/api/*"
+		routes = append(routes, &wildcard)
+	}
+	services := make(map[string]*config.ServiceConfig)
+	for _, name := range []string{"grpc", "acme", "tenants", "default"} {
+		services[name] = &config.ServiceConfig{Name: name, Servers: []config.ServerConfig{{Address: "http://" + name}}}
+	}
+	router := NewRouter(routes, services)
+	router.SetCacheSize(16)
+
+	client := func(orgs ...string) []*x509.Certificate {
+		return []*x509.Certificate{{Subject: pkix.Name{CommonName: "client", Organization: orgs}}}
+	}
+	tests := []struct {
+		name     string
+		state    *tls.ConnectionState
+		expected string
+	}{
+		{"Plain HTTP", nil, "default"},
+		{"SNI and ALPN", &tls.ConnectionState{ServerName: "grpc.example.com", NegotiatedProtocol: "h2"}, "grpc"},
+		{"SNI without ALPN", &tls.ConnectionState{ServerName: "grpc.example.com", NegotiatedProtocol: "http/1.1"}, "default"},
+		{"Client organization", &tls.ConnectionState{PeerCertificates: client("Globex", "Acme")}, "acme"},
+		{"Other organization", &tls.ConnectionState{PeerCertificates: client("Globex")}, "default"},
+		{"Wildcard SNI", &tls.ConnectionState{ServerName: "Blue.Tenants.example.com"}, "tenants"},
+		{"Wildcard parent", &tls.ConnectionState{ServerName: "tenants.example.com"}, "default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, path := range []string{"/", "/api/v1/users"} {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				req.TLS = tt.state
+
+				svc := router.Match(req)
+				require.NotNil(t, svc, path)
+				assert.Equal(t, tt.expected, svc.Name(), path)
+			}
+		})
+	}
+}
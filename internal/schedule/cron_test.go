@@ -0,0 +1,79 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron_Invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	}
+
+	for _, expr := range tests {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("Expected error for cron expression %q", expr)
+		}
+	}
+}
+
+func TestCron_Next(t *testing.T) {
+	base := time.Date(2025, 3, 14, 10, 30, 0, 0, time.UTC) // Friday
+
+	tests := []struct {
+		expr     string
+		from     time.Time
+		expected time.Time
+	}{
+		{"* * * * *", base, base.Add(time.Minute)},
+		{"0 * * * *", base, time.Date(2025, 3, 14, 11, 0, 0, 0, time.UTC)},
+		{"*/15 9-17 * * *", base, time.Date(2025, 3, 14, 10, 45, 0, 0, time.UTC)},
+		{"0 2 * * 0", base, time.Date(2025, 3, 16, 2, 0, 0, 0, time.UTC)},
+		{"0 2 * * 7", base, time.Date(2025, 3, 16, 2, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", base, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 4 29 2 *", base, time.Date(2028, 2, 29, 4, 30, 0, 0, time.UTC)},
+		{"0 9 1,15 * 1", base, time.Date(2025, 3, 15, 9, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", tt.expr, err)
+		}
+		if got := c.Next(tt.from); !got.Equal(tt.expected) {
+			t.Errorf("%q: expected next %v, got %v", tt.expr, tt.expected, got)
+		}
+	}
+}
+
+func TestCron_ActiveWithin(t *testing.T) {
+	c, err := ParseCron("0 2 * * *")
+	if err != nil {
+		t.Fatalf("Failed to parse cron: %v", err)
+	}
+
+	tests := []struct {
+		at     time.Time
+		active bool
+	}{
+		{time.Date(2025, 3, 14, 1, 59, 0, 0, time.UTC), false},
+		{time.Date(2025, 3, 14, 2, 0, 0, 0, time.UTC), true},
+		{time.Date(2025, 3, 14, 3, 59, 59, 0, time.UTC), true},
+		{time.Date(2025, 3, 14, 4, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		if got := c.ActiveWithin(tt.at, 2*time.Hour); got != tt.active {
+			t.Errorf("At %v: expected active=%v, got %v", tt.at, tt.active, got)
+		}
+	}
+}
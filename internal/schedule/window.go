@@ -0,0 +1,121 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window is a recurring weekly window such as mon-fri 09:00-17:00, hour ranges
+// may wrap past midnight and then belong to the day they start on
+type Window struct {
+	weekdays [7]bool
+	start    int // minutes after midnight
+	end      int
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseWindow parses weekday names or ranges ("mon", "mon-fri") and an hour
+// range ("09:00-17:00"), empty values mean every day and all day
+func ParseWindow(weekdays []string, hours string) (*Window, error) {
+	w := &Window{end: 24 * 60}
+
+	if len(weekdays) == 0 {
+		for i := range w.weekdays {
+			w.weekdays[i] = true
+		}
+	}
+	for _, spec := range weekdays {
+		if err := w.addWeekdays(strings.ToLower(strings.TrimSpace(spec))); err != nil {
+			return nil, err
+		}
+	}
+
+	if hours != "" {
+		from, to, ok := strings.Cut(hours, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid hour range: %q", hours)
+		}
+		var err error
+		if w.start, err = parseClock(from); err != nil {
+			return nil, err
+		}
+		if w.end, err = parseClock(to); err != nil {
+			return nil, err
+		}
+		if w.start == w.end {
+			return nil, fmt.Errorf("invalid hour range: %q", hours)
+		}
+	}
+
+	return w, nil
+}
+
+func (w *Window) addWeekdays(spec string) error {
+	from, to, isRange := strings.Cut(spec, "-")
+	first, ok := weekdayNames[from]
+	if !ok {
+		return fmt.Errorf("invalid weekday: %q", spec)
+	}
+	if !isRange {
+		w.weekdays[first] = true
+		return nil
+	}
+
+	last, ok := weekdayNames[to]
+	if !ok {
+		return fmt.Errorf("invalid weekday: %q", spec)
+	}
+	// Ranges may wrap around the week, e.g. fri-mon
+	for d := first; ; d = (d + 1) % 7 {
+		w.weekdays[d] = true
+		if d == last {
+			break
+		}
+	}
+
+	return nil
+}
+
+// parseClock parses HH:MM into minutes after midnight, 24:00 is allowed as an end
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid time of day: %q", s)
+	}
+	hour, err := strconv.Atoi(h)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day: %q", s)
+	}
+	minute, err := strconv.Atoi(m)
+	if err != nil || minute < 0 || minute > 59 || hour < 0 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time of day: %q", s)
+	}
+
+	return hour*60 + minute, nil
+}
+
+// Contains reports whether t falls within the window, in t's location
+func (w *Window) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+
+	if w.start < w.end {
+		return w.weekdays[t.Weekday()] && minute >= w.start && minute < w.end
+	}
+
+	// Overnight window: the evening part belongs to today, the morning part to yesterday
+	if minute >= w.start {
+		return w.weekdays[t.Weekday()]
+	}
+	return minute < w.end && w.weekdays[(t.Weekday()+6)%7]
+}
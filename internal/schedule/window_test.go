@@ -0,0 +1,63 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWindow_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		weekdays []string
+		hours    string
+	}{
+		{"UnknownWeekday", []string{"monday"}, ""},
+		{"UnknownRangeEnd", []string{"mon-xyz"}, ""},
+		{"MissingDash", nil, "09:00"},
+		{"InvalidHour", nil, "25:00-26:00"},
+		{"InvalidMinute", nil, "09:60-17:00"},
+		{"EmptyRange", nil, "09:00-09:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseWindow(tt.weekdays, tt.hours)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestWindow_Contains(t *testing.T) {
+	// 2025-03-10 is a Monday
+	date := func(s string) time.Time {
+		ts, err := time.Parse("2006-01-02 15:04", s)
+		require.NoError(t, err)
+		return ts
+	}
+
+	business, err := ParseWindow([]string{"mon-fri"}, "09:00-17:00")
+	require.NoError(t, err)
+	assert.True(t, business.Contains(date("2025-03-10 09:00")))
+	assert.True(t, business.Contains(date("2025-03-14 16:59")))
+	assert.False(t, business.Contains(date("2025-03-10 17:00")))
+	assert.False(t, business.Contains(date("2025-03-15 12:00"))) // Saturday
+
+	overnight, err := ParseWindow([]string{"fri"}, "22:00-06:00")
+	require.NoError(t, err)
+	assert.True(t, overnight.Contains(date("2025-03-14 23:30")))  // Friday night
+	assert.True(t, overnight.Contains(date("2025-03-15 05:59")))  // Saturday morning belongs to Friday
+	assert.False(t, overnight.Contains(date("2025-03-14 05:00"))) // Friday morning belongs to Thursday
+
+	weekend, err := ParseWindow([]string{"sat", "sun"}, "")
+	require.NoError(t, err)
+	assert.True(t, weekend.Contains(date("2025-03-16 00:00")))
+	assert.False(t, weekend.Contains(date("2025-03-17 00:00")))
+
+	wrapped, err := ParseWindow([]string{"fri-mon"}, "00:00-24:00")
+	require.NoError(t, err)
+	assert.True(t, wrapped.Contains(date("2025-03-17 23:59")))  // Monday
+	assert.False(t, wrapped.Contains(date("2025-03-18 12:00"))) // Tuesday
+}
@@ -0,0 +1,195 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nexus/internal/config"
+	lg "nexus/internal/logger"
+)
+
+const (
+	// DefaultRefreshInterval is how often cached secrets are read again when
+	// refresh_interval is not set
+	DefaultRefreshInterval = 5 * time.Minute
+	// lookupTimeout bounds a single provider read
+	lookupTimeout = 10 * time.Second
+)
+
+// Provider reads the secrets of secret://<provider>/<path>#<key> references
+type Provider interface {
+	// Get returns the value of a key of the secret at path
+	Get(ctx context.Context, path, key string) (string, error)
+}
+
+// Store resolves file:// and secret:// references and caches their values,
+// so requests never wait on a provider. Cached values are refreshed in the
+// background and kept when a provider is unavailable
+type Store struct {
+	mu         sync.RWMutex
+	providers  map[string]Provider
+	values     map[string]string // resolved value by reference
+	generation atomic.Uint64
+	stopChan   chan struct{}
+}
+
+// NewStore creates a store with the providers of the configuration
+func NewStore(cfg config.SecretsConfig) (*Store, error) {
+	s := &Store{
+		providers: make(map[string]Provider),
+		values:    make(map[string]string),
+		stopChan:  make(chan struct{}),
+	}
+
+	if cfg.Vault != nil {
+		vault, err := NewVault(*cfg.Vault)
+		if err != nil {
+			return nil, err
+		}
+		s.Register("vault", vault)
+	}
+
+	return s, nil
+}
+
+// Register adds a provider for secret://<name>/ references
+func (s *Store) Register(name string, provider Provider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.providers[name] = provider
+}
+
+// ParseRef splits a secret://<provider>/<path>#<key> reference
+func ParseRef(ref string) (provider, path, key string, err error) {
+	rest, ok := strings.CutPrefix(ref, "secret://")
+	if !ok {
+		return "", "", "", fmt.Errorf("not a secret reference: %s", ref)
+	}
+	rest, key, _ = strings.Cut(rest, "#")
+	provider, path, _ = strings.Cut(rest, "/")
+	if provider == "" || path == "" || key == "" {
+		return "", "", "", fmt.Errorf("secret reference %s must be secret://<provider>/<path>#<key>", ref)
+	}
+
+	return provider, path, key, nil
+}
+
+// Resolve returns the value of a file:// or secret:// reference, reading it
+// only the first time. It implements config.SecretResolver
+func (s *Store) Resolve(ref string) (string, error) {
+	s.mu.RLock()
+	value, ok := s.values[ref]
+	s.mu.RUnlock()
+	if ok {
+		return value, nil
+	}
+
+	value, err := s.fetch(ref)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.values[ref] = value
+	s.mu.Unlock()
+
+	return value, nil
+}
+
+// Generation changes whenever a refresh changed a cached value. It implements
+// config.SecretResolver
+func (s *Store) Generation() uint64 {
+	return s.generation.Load()
+}
+
+// fetch reads a reference from its source
+func (s *Store) fetch(ref string) (string, error) {
+	if path, ok := strings.CutPrefix(ref, "file://"); ok {
+		return config.ReadCredentialFile(path)
+	}
+
+	name, path, key, err := ParseRef(ref)
+	if err != nil {
+		return "", err
+	}
+	s.mu.RLock()
+	provider, ok := s.providers[name]
+	s.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("unknown secret provider %s in %s", name, ref)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+
+	value, err := provider.Get(ctx, path, key)
+	if err != nil {
+		return "", fmt.Errorf("secret %s: %w", ref, err)
+	}
+
+	return value, nil
+}
+
+// Refresh reads every cached reference again, values that can't be read keep
+// their previous value
+func (s *Store) Refresh() error {
+	s.mu.RLock()
+	refs := make([]string, 0, len(s.values))
+	for ref := range s.values {
+		refs = append(refs, ref)
+	}
+	s.mu.RUnlock()
+
+	var errs []error
+	changed := false
+	for _, ref := range refs {
+		value, err := s.fetch(ref)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		s.mu.Lock()
+		if s.values[ref] != value {
+			s.values[ref] = value
+			changed = true
+		}
+		s.mu.Unlock()
+	}
+	if changed {
+		s.generation.Add(1)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Watch refreshes the cached secrets periodically until Stop is called
+func (s *Store) Watch(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Refresh(); err != nil {
+				lg.GetInstance().Error("Failed to refresh secrets: %v", err)
+			}
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// Stop terminates the refresh loop
+func (s *Store) Stop() {
+	close(s.stopChan)
+}
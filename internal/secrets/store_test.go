@@ -0,0 +1,135 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProvider serves secrets from a map and counts reads
+type fakeProvider struct {
+	mu      sync.Mutex
+	secrets map[string]string // value by path#key
+	reads   int
+	err     error
+}
+
+func (p *fakeProvider) Get(ctx context.Context, path, key string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.reads++
+	if p.err != nil {
+		return "", p.err
+	}
+	value, ok := p.secrets[path+"#"+key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found", key)
+	}
+	return value, nil
+}
+
+func (p *fakeProvider) set(ref, value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.secrets[ref] = value
+}
+
+func TestParseRef(t *testing.T) {
+	provider, path, key, err := ParseRef("secret://vault/kv/app/db#password")
+	require.NoError(t, err)
+	assert.Equal(t, "vault", provider)
+	assert.Equal(t, "kv/app/db", path)
+	assert.Equal(t, "password", key)
+
+	for _, ref := range []string{"secret://vault/kv/app", "secret://vault#key", "secret:///kv/app#key", "file:///run/secrets/x"} {
+		_, _, _, err := ParseRef(ref)
+		assert.Error(t, err, ref)
+	}
+}
+
+func TestStore_ResolveCaches(t *testing.T) {
+	provider := &fakeProvider{secrets: map[string]string{"app/db#password": "s3cret"}}
+	store, err := NewStore(config.SecretsConfig{})
+	require.NoError(t, err)
+	store.Register("fake", provider)
+
+	for i := 0; i < 3; i++ {
+		value, err := store.Resolve("secret://fake/app/db#password")
+		require.NoError(t, err)
+		assert.Equal(t, "s3cret", value)
+	}
+	assert.Equal(t, 1, provider.reads)
+
+	_, err = store.Resolve("secret://fake/app/db#missing")
+	assert.ErrorContains(t, err, "key missing not found")
+	_, err = store.Resolve("secret://other/app/db#password")
+	assert.ErrorContains(t, err, "unknown secret provider other")
+}
+
+func TestStore_Refresh(t *testing.T) {
+	provider := &fakeProvider{secrets: map[string]string{"app/db#password": "old"}}
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(path, []byte("file-old\n"), 0o600))
+
+	store, err := NewStore(config.SecretsConfig{})
+	require.NoError(t, err)
+	store.Register("fake", provider)
+
+	_, err = store.Resolve("secret://fake/app/db#password")
+	require.NoError(t, err)
+	value, err := store.Resolve("file://" + path)
+	require.NoError(t, err)
+	assert.Equal(t, "file-old", value)
+
+	// Unchanged values keep the generation
+	require.NoError(t, store.Refresh())
+	assert.Equal(t, uint64(0), store.Generation())
+
+	provider.set("app/db#password", "new")
+	require.NoError(t, os.WriteFile(path, []byte("file-new\n"), 0o600))
+	require.NoError(t, store.Refresh())
+	assert.Equal(t, uint64(1), store.Generation())
+	value, _ = store.Resolve("secret://fake/app/db#password")
+	assert.Equal(t, "new", value)
+	value, _ = store.Resolve("file://" + path)
+	assert.Equal(t, "file-new", value)
+
+	// An unavailable provider keeps the last value
+	provider.err = errors.New("sealed")
+	assert.ErrorContains(t, store.Refresh(), "sealed")
+	value, err = store.Resolve("secret://fake/app/db#password")
+	require.NoError(t, err)
+	assert.Equal(t, "new", value)
+	assert.Equal(t, uint64(1), store.Generation())
+}
+
+func TestStore_ConfigResolver(t *testing.T) {
+	provider := &fakeProvider{secrets: map[string]string{"app/api#token": "t0ken"}}
+	store, err := NewStore(config.SecretsConfig{})
+	require.NoError(t, err)
+	store.Register("fake", provider)
+
+	config.SetSecretResolver(store)
+	defer config.SetSecretResolver(nil)
+
+	value, err := config.ResolveCredential("secret://fake/app/api#token")
+	require.NoError(t, err)
+	assert.Equal(t, "t0ken", value)
+
+	provider.set("app/api#token", "rotated")
+	require.NoError(t, store.Refresh())
+	assert.Equal(t, store.Generation(), config.CredentialGeneration())
+	value, _ = config.ResolveCredential("secret://fake/app/api#token")
+	assert.Equal(t, "rotated", value)
+}
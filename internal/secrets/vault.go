@@ -0,0 +1,129 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"nexus/internal/config"
+)
+
+const defaultVaultTimeout = 5 * time.Second
+
+// Vault reads secrets from a HashiCorp Vault KV secrets engine. Paths start
+// with the mount of the engine, secret://vault/kv/app/db#password reads the
+// password key of app/db in the engine mounted at kv
+type Vault struct {
+	address   string
+	token     string // token or env:// / file:// reference
+	namespace string
+	kvVersion int
+	client    *http.Client
+}
+
+// NewVault creates a Vault provider, address and token default to the
+// VAULT_ADDR and VAULT_TOKEN environment variables
+func NewVault(cfg config.VaultConfig) (*Vault, error) {
+	address := cfg.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if address == "" {
+		return nil, errors.New("vault address is not configured")
+	}
+	token := cfg.Token
+	if token == "" {
+		token = "env://VAULT_TOKEN"
+	}
+	kvVersion := cfg.KVVersion
+	if kvVersion == 0 {
+		kvVersion = 2
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultVaultTimeout
+	}
+
+	return &Vault{
+		address:   strings.TrimRight(address, "/"),
+		token:     token,
+		namespace: cfg.Namespace,
+		kvVersion: kvVersion,
+		client:    &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Get implements the Provider interface
+func (v *Vault) Get(ctx context.Context, path, key string) (string, error) {
+	mount, secretPath, _ := strings.Cut(path, "/")
+	if mount == "" || secretPath == "" {
+		return "", fmt.Errorf("vault path %s must be <mount>/<path>", path)
+	}
+	// The token is read on every request so a rotated token file is picked up
+	token, err := config.ResolveCredential(v.token)
+	if err != nil {
+		return "", fmt.Errorf("vault token: %w", err)
+	}
+
+	endpoint := v.address + "/v1/" + mount + "/" + secretPath
+	if v.kvVersion == 2 {
+		endpoint = v.address + "/v1/" + mount + "/data/" + secretPath
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil && resp.StatusCode == http.StatusOK {
+		return "", fmt.Errorf("invalid vault response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %s: %s", resp.Status, strings.Join(body.Errors, "; "))
+	}
+
+	// KV version 2 nests the secret below its metadata
+	data := body.Data
+	if v.kvVersion == 2 {
+		var versioned struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(data, &versioned); err != nil {
+			return "", fmt.Errorf("invalid vault response: %w", err)
+		}
+		data = versioned.Data
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return "", fmt.Errorf("invalid vault response: %w", err)
+	}
+
+	value, ok := values[key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found", key)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("key %s is not a string", key)
+	}
+
+	return s, nil
+}
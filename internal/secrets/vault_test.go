@@ -0,0 +1,90 @@
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newVaultServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("X-Vault-Token") != "root-token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/data/app/db":
+			w.Write([]byte(`{"data":{"data":{"password":"v2-secret","port":5432},"metadata":{"version":3}}}`))
+		case "/v1/legacy/app/db":
+			if r.Header.Get("X-Vault-Namespace") != "team" {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"errors":[]}`))
+				return
+			}
+			w.Write([]byte(`{"data":{"password":"v1-secret"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestVault_Get(t *testing.T) {
+	server := newVaultServer(t)
+	t.Setenv("NEXUS_TEST_VAULT_TOKEN", "root-token")
+
+	v2, err := NewVault(config.VaultConfig{Address: server.URL + "/", Token: "env://NEXUS_TEST_VAULT_TOKEN"})
+	require.NoError(t, err)
+	value, err := v2.Get(context.Background(), "kv/app/db", "password")
+	require.NoError(t, err)
+	assert.Equal(t, "v2-secret", value)
+
+	_, err = v2.Get(context.Background(), "kv/app/db", "missing")
+	assert.ErrorContains(t, err, "key missing not found")
+	_, err = v2.Get(context.Background(), "kv/app/db", "port")
+	assert.ErrorContains(t, err, "not a string")
+	_, err = v2.Get(context.Background(), "kv/app/other", "password")
+	assert.ErrorContains(t, err, "404")
+	_, err = v2.Get(context.Background(), "kv", "password")
+	assert.ErrorContains(t, err, "<mount>/<path>")
+
+	v1, err := NewVault(config.VaultConfig{Address: server.URL, Token: "root-token", Namespace: "team", KVVersion: 1})
+	require.NoError(t, err)
+	value, err = v1.Get(context.Background(), "legacy/app/db", "password")
+	require.NoError(t, err)
+	assert.Equal(t, "v1-secret", value)
+
+	denied, err := NewVault(config.VaultConfig{Address: server.URL, Token: "wrong"})
+	require.NoError(t, err)
+	_, err = denied.Get(context.Background(), "kv/app/db", "password")
+	assert.ErrorContains(t, err, "permission denied")
+}
+
+func TestNewVault_Defaults(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "")
+	_, err := NewVault(config.VaultConfig{})
+	assert.Error(t, err)
+
+	server := newVaultServer(t)
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "root-token")
+	store, err := NewStore(config.SecretsConfig{Vault: &config.VaultConfig{}})
+	require.NoError(t, err)
+
+	value, err := store.Resolve("secret://vault/kv/app/db#password")
+	require.NoError(t, err)
+	assert.Equal(t, "v2-secret", value)
+}
@@ -2,8 +2,10 @@ package service
 
 import (
 	"context"
+	"fmt"
 	lb "nexus/internal/balancer"
 	"nexus/internal/config"
+	"sort"
 	"sync"
 )
 
@@ -13,6 +15,18 @@ type Service interface {
 	NextServer(ctx context.Context) (string, error)
 	Balancer() lb.Balancer
 	Update(config *config.ServiceConfig) error
+	Config() *config.ServiceConfig
+	Drain(address, reason string) error
+	Undrain(address, reason string) error
+	Drained() map[string][]string
+}
+
+// Sourced is implemented by services whose balancers can draw randomness
+// and time from an injected source, see balancer.Source
+type Sourced interface {
+	// SetSource sets the source of the balancers of the service, including
+	// those it creates later
+	SetSource(src lb.Source)
 }
 
 // Basic service implementation
@@ -20,12 +34,18 @@ type serviceImpl struct {
 	mu       sync.RWMutex
 	name     string
 	balancer lb.Balancer
+	config   *config.ServiceConfig
+	drains   map[string]map[string]bool // address -> reasons the server is out of rotation
+	subsets  map[string]*subsetService  // selector -> subset
+	source   lb.Source                  // nil keeps the default of the balancers
 }
 
 func NewService(config *config.ServiceConfig) Service {
 	return &serviceImpl{
 		name:     config.Name,
-		balancer: newBalancer(config),
+		balancer: newBalancer(config.BalancerType, config.Servers, nil),
+		config:   config,
+		drains:   make(map[string]map[string]bool),
 	}
 }
 
@@ -33,39 +53,174 @@ func (s *serviceImpl) Name() string {
 	return s.name
 }
 
-func newBalancer(config *config.ServiceConfig) lb.Balancer {
-	balancer := lb.NewBalancer(config.BalancerType)
+func newBalancer(balancerType string, servers []config.ServerConfig, source lb.Source) lb.Balancer {
+	balancer := lb.NewBalancer(balancerType)
+	if source != nil {
+		balancer.SetSource(source)
+	}
 
-	for _, server := range config.Servers {
-		if wrr, ok := balancer.(*lb.WeightedRoundRobinBalancer); ok {
-			wrr.AddWithWeight(server.Address, server.Weight)
-		} else {
-			balancer.Add(server.Address)
-		}
+	for _, server := range servers {
+		addServer(balancer, server)
 	}
 
 	return balancer
 }
 
+// addServer adds a server to the balancer, keeping its weight when supported
+func addServer(balancer lb.Balancer, server config.ServerConfig) {
+	if wrr, ok := balancer.(*lb.WeightedRoundRobinBalancer); ok {
+		wrr.AddWithWeight(server.Address, server.Weight)
+	} else {
+		balancer.Add(server.Address)
+	}
+}
+
 func (s *serviceImpl) Balancer() lb.Balancer {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	return s.balancer
 }
 
 func (s *serviceImpl) NextServer(ctx context.Context) (string, error) {
-	return s.balancer.Next(ctx)
+	return s.Balancer().Next(ctx)
 }
 
 func (s *serviceImpl) Update(config *config.ServiceConfig) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// Forget drains of servers that are no longer configured
+	for address := range s.drains {
+		if _, ok := findServer(config, address); !ok {
+			delete(s.drains, address)
+		}
+	}
+
+	servers := s.activeServers(config.Servers)
 	if config.BalancerType != s.balancer.Type() {
-		s.balancer = newBalancer(config)
+		s.balancer = newBalancer(config.BalancerType, servers, s.source)
 	} else {
-		s.balancer.UpdateServers(config.Servers)
+		s.balancer.UpdateServers(servers)
 	}
 
 	s.name = config.Name
+	s.config = config
+	for _, subset := range s.subsets {
+		subset.update(config.BalancerType, servers)
+	}
+
+	return nil
+}
+
+// SetSource implements the Sourced interface
+func (s *serviceImpl) SetSource(src lb.Source) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.source = src
+	s.balancer.SetSource(src)
+	for _, subset := range s.subsets {
+		subset.balancer.SetSource(src)
+	}
+}
+
+func (s *serviceImpl) Config() *config.ServiceConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.config
+}
+
+// Drain takes a server out of rotation for the given reason
+func (s *serviceImpl) Drain(address, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := findServer(s.config, address); !ok {
+		return fmt.Errorf("server %s not found in service %s", address, s.name)
+	}
+
+	reasons, ok := s.drains[address]
+	if !ok {
+		reasons = make(map[string]bool)
+		s.drains[address] = reasons
+		s.balancer.Remove(address)
+		for _, subset := range s.subsets {
+			subset.balancer.Remove(address)
+		}
+	}
+	reasons[reason] = true
+
+	return nil
+}
+
+// Undrain clears a drain reason, the server returns to rotation once no reasons remain
+func (s *serviceImpl) Undrain(address, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	server, ok := findServer(s.config, address)
+	if !ok {
+		return fmt.Errorf("server %s not found in service %s", address, s.name)
+	}
+
+	reasons, ok := s.drains[address]
+	if !ok || !reasons[reason] {
+		return nil
+	}
+	delete(reasons, reason)
+
+	if len(reasons) == 0 {
+		delete(s.drains, address)
+		addServer(s.balancer, server)
+		for _, subset := range s.subsets {
+			if subset.matches(server) {
+				addServer(subset.balancer, server)
+			}
+		}
+	}
 
 	return nil
 }
+
+// Drained returns the drained servers with their drain reasons
+func (s *serviceImpl) Drained() map[string][]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	drained := make(map[string][]string, len(s.drains))
+	for address, reasons := range s.drains {
+		list := make([]string, 0, len(reasons))
+		for reason := range reasons {
+			list = append(list, reason)
+		}
+		sort.Strings(list)
+		drained[address] = list
+	}
+
+	return drained
+}
+
+// activeServers filters out drained servers
+func (s *serviceImpl) activeServers(servers []config.ServerConfig) []config.ServerConfig {
+	active := make([]config.ServerConfig, 0, len(servers))
+	for _, server := range servers {
+		if _, drained := s.drains[server.Address]; !drained {
+			active = append(active, server)
+		}
+	}
+
+	return active
+}
+
+// findServer looks up a server config by address
+func findServer(svcConfig *config.ServiceConfig, address string) (config.ServerConfig, bool) {
+	for _, server := range svcConfig.Servers {
+		if server.Address == address {
+			return server, true
+		}
+	}
+
+	return config.ServerConfig{}, false
+}
@@ -58,6 +58,71 @@ func TestService(t *testing.T) {
 		assert.IsType(t, &balancer.WeightedRoundRobinBalancer{}, s.Balancer())
 	})
 
+	t.Run("TestConfig", func(t *testing.T) {
+		s := NewService(cfg)
+		assert.Same(t, cfg, s.Config())
+
+		newCfg := &config.ServiceConfig{
+			Name:         "test-service",
+			BalancerType: "round_robin",
+			Servers:      cfg.Servers,
+			Dedup:        &config.DedupConfig{Enabled: true},
+		}
+		assert.Nil(t, s.Update(newCfg))
+		assert.Same(t, newCfg, s.Config())
+	})
+
+	t.Run("TestDrain", func(t *testing.T) {
+		s := NewService(cfg)
+		ctx := context.Background()
+
+		assert.Nil(t, s.Drain("server1:8080", "maintenance"))
+		assert.Nil(t, s.Drain("server1:8080", "manual"))
+		assert.Equal(t, map[string][]string{"server1:8080": {"maintenance", "manual"}}, s.Drained())
+		for i := 0; i < 4; i++ {
+			addr, err := s.NextServer(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, "server2:8080", addr)
+		}
+
+		// The server stays drained until every reason is cleared
+		assert.Nil(t, s.Undrain("server1:8080", "maintenance"))
+		assert.Equal(t, map[string][]string{"server1:8080": {"manual"}}, s.Drained())
+		assert.Nil(t, s.Undrain("server1:8080", "manual"))
+		assert.Empty(t, s.Drained())
+
+		seen := make(map[string]bool)
+		for i := 0; i < 4; i++ {
+			addr, _ := s.NextServer(ctx)
+			seen[addr] = true
+		}
+		assert.True(t, seen["server1:8080"])
+
+		assert.NotNil(t, s.Drain("unknown:8080", "manual"))
+	})
+
+	t.Run("TestDrainSurvivesUpdate", func(t *testing.T) {
+		s := NewService(cfg)
+		assert.Nil(t, s.Drain("server1:8080", "manual"))
+
+		assert.Nil(t, s.Update(&config.ServiceConfig{
+			Name:         "test-service",
+			BalancerType: "least_connections",
+			Servers:      cfg.Servers,
+		}))
+		assert.Contains(t, s.Drained(), "server1:8080")
+		addr, _ := s.NextServer(context.Background())
+		assert.Equal(t, "server2:8080", addr)
+
+		// Removing the server from config forgets its drain state
+		assert.Nil(t, s.Update(&config.ServiceConfig{
+			Name:         "test-service",
+			BalancerType: "least_connections",
+			Servers:      []config.ServerConfig{{Address: "server2:8080"}},
+		}))
+		assert.Empty(t, s.Drained())
+	})
+
 	t.Run("TestConcurrentUpdate", func(t *testing.T) {
 		s := NewService(cfg)
 		var wg sync.WaitGroup
@@ -77,3 +142,52 @@ func TestService(t *testing.T) {
 		assert.Equal(t, "concurrent-update", s.Name())
 	})
 }
+
+func TestService_Subset(t *testing.T) {
+	cfg := &config.ServiceConfig{
+		Name:         "test-service",
+		BalancerType: "round_robin",
+		Labels:       map[string]string{"tier": "gold"},
+		Servers: []config.ServerConfig{
+			{Address: "server1:8080", Labels: map[string]string{"version": "v1"}},
+			{Address: "server2:8080", Labels: map[string]string{"version": "v2"}},
+			{Address: "server3:8080", Labels: map[string]string{"version": "v2"}},
+		},
+	}
+	s := NewService(cfg)
+	subset := s.(Subsetter).Subset("version=v2, tier=gold")
+	assert.Same(t, subset, s.(Subsetter).Subset("version=v2, tier=gold"))
+	assert.Equal(t, "test-service", subset.Name())
+
+	next := func(svc Service) map[string]bool {
+		seen := make(map[string]bool)
+		for i := 0; i < 6; i++ {
+			if addr, err := svc.NextServer(context.Background()); err == nil {
+				seen[addr] = true
+			}
+		}
+		return seen
+	}
+	assert.Equal(t, map[string]bool{"server2:8080": true, "server3:8080": true}, next(subset))
+
+	// Drains of the service apply to its subsets
+	assert.NoError(t, subset.Drain("server2:8080", "manual"))
+	assert.Equal(t, map[string]bool{"server3:8080": true}, next(subset))
+	assert.NotContains(t, next(s), "server2:8080")
+	assert.NoError(t, s.Undrain("server2:8080", "manual"))
+	assert.Equal(t, map[string]bool{"server2:8080": true, "server3:8080": true}, next(subset))
+
+	// Subsets follow configuration updates
+	assert.NoError(t, s.Update(&config.ServiceConfig{
+		Name:         "test-service",
+		BalancerType: "least_connections",
+		Servers: []config.ServerConfig{
+			{Address: "server1:8080", Labels: map[string]string{"version": "v2", "tier": "gold"}},
+			{Address: "server2:8080", Labels: map[string]string{"version": "v1"}},
+		},
+	}))
+	assert.IsType(t, &balancer.LeastConnectionsBalancer{}, subset.Balancer())
+	assert.Equal(t, map[string]bool{"server1:8080": true}, next(subset))
+
+	assert.Empty(t, next(s.(Subsetter).Subset("invalid")))
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	lb "nexus/internal/balancer"
+	"nexus/internal/config"
+)
+
+// Subsetter is implemented by services that can balance over the subset of
+// their servers selected by labels
+type Subsetter interface {
+	// Subset returns the service restricted to the servers matching a
+	// selector, see config.ParseSelector
+	Subset(selector string) Service
+}
+
+// subsetService balances over the servers of a service matching a label
+// selector. Its servers follow the configuration and drains of the service
+type subsetService struct {
+	parent   *serviceImpl
+	selector map[string]string // nil when the selector is invalid, nothing matches
+	balancer lb.Balancer       // guarded by parent.mu
+}
+
+// Subset implements the Subsetter interface, subsets are created on first use
+func (s *serviceImpl) Subset(selector string) Service {
+	s.mu.RLock()
+	subset, ok := s.subsets[selector]
+	s.mu.RUnlock()
+	if ok {
+		return subset
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if subset, ok := s.subsets[selector]; ok {
+		return subset
+	}
+	subset = &subsetService{parent: s}
+	subset.selector, _ = config.ParseSelector(selector)
+	subset.balancer = newBalancer(s.config.BalancerType, subset.servers(s.activeServers(s.config.Servers)), s.source)
+	if s.subsets == nil {
+		s.subsets = make(map[string]*subsetService)
+	}
+	s.subsets[selector] = subset
+
+	return subset
+}
+
+// servers filters the servers matching the selector, the caller holds parent.mu
+func (s *subsetService) servers(servers []config.ServerConfig) []config.ServerConfig {
+	matching := make([]config.ServerConfig, 0, len(servers))
+	for _, server := range servers {
+		if s.matches(server) {
+			matching = append(matching, server)
+		}
+	}
+
+	return matching
+}
+
+// matches reports whether a server belongs to the subset, the caller holds parent.mu
+func (s *subsetService) matches(server config.ServerConfig) bool {
+	return s.selector != nil && config.MatchLabels(s.parent.config.ServerLabels(server.Address), s.selector)
+}
+
+// update applies the active servers of the service, the caller holds parent.mu
+func (s *subsetService) update(balancerType string, active []config.ServerConfig) {
+	servers := s.servers(active)
+	if balancerType != s.balancer.Type() {
+		s.balancer = newBalancer(balancerType, servers, s.parent.source)
+		return
+	}
+	s.balancer.UpdateServers(servers)
+}
+
+func (s *subsetService) Name() string {
+	return s.parent.Name()
+}
+
+func (s *subsetService) NextServer(ctx context.Context) (string, error) {
+	return s.Balancer().Next(ctx)
+}
+
+func (s *subsetService) Balancer() lb.Balancer {
+	s.parent.mu.RLock()
+	defer s.parent.mu.RUnlock()
+
+	return s.balancer
+}
+
+// Update is not supported, subsets follow the configuration of their service
+func (s *subsetService) Update(*config.ServiceConfig) error {
+	return errors.New("subsets are updated with their service")
+}
+
+func (s *subsetService) Config() *config.ServiceConfig {
+	return s.parent.Config()
+}
+
+func (s *subsetService) Drain(address, reason string) error {
+	return s.parent.Drain(address, reason)
+}
+
+func (s *subsetService) Undrain(address, reason string) error {
+	return s.parent.Undrain(address, reason)
+}
+
+func (s *subsetService) Drained() map[string][]string {
+	return s.parent.Drained()
+}
@@ -0,0 +1,148 @@
+package slo
+
+import (
+	"sync"
+	"time"
+
+	"nexus/internal/config"
+)
+
+// DefaultWindow is the rolling window of objectives that set none
+const DefaultWindow = time.Hour
+
+// buckets is the resolution of the rolling window, the short window of
+// burn rate alerts spans the last twelfth of them
+const (
+	buckets      = 60
+	shortBuckets = buckets / 12
+)
+
+// Status is the compliance of a route with its objective
+type Status struct {
+	Total         int64   // Requests over the window
+	Good          int64   // Requests answered within the latency
+	Compliance    float64 // Share of good requests, 1 without requests
+	BurnRate      float64 // Error budget consumption over the window
+	ShortBurnRate float64 // Error budget consumption over the last twelfth of the window
+	Alerting      bool    // Both burn rates exceed the alert burn rate
+}
+
+type bucket struct {
+	index int64 // Start of the bucket in bucket widths since the epoch
+	total int64
+	good  int64
+}
+
+// Tracker counts the good requests of a route over a rolling window
+type Tracker struct {
+	cfg    config.SLOConfig
+	width  time.Duration
+	budget float64 // Share of requests allowed to be bad
+
+	mu       sync.Mutex
+	buckets  [buckets]bucket
+	alerting bool
+	now      func() time.Time
+}
+
+// NewTracker returns a tracker of the objective
+func NewTracker(cfg config.SLOConfig) *Tracker {
+	window := cfg.Window
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	return &Tracker{
+		cfg:    cfg,
+		width:  window / buckets,
+		budget: 1 - cfg.Objective/100,
+		now:    time.Now,
+	}
+}
+
+// Config returns the objective of the tracker
+func (t *Tracker) Config() config.SLOConfig {
+	return t.cfg
+}
+
+// Window returns the rolling window of the compliance
+func (t *Tracker) Window() time.Duration {
+	return t.width * buckets
+}
+
+// Good reports whether a request meets the objective, status is 0 when the
+// request was aborted before a response
+func (t *Tracker) Good(status int, elapsed time.Duration) bool {
+	return status != 0 && status < 500 && elapsed <= t.cfg.Latency
+}
+
+// Record counts a request. The alert is evaluated when a bucket starts, it
+// reports whether the alert started or ended with the request
+func (t *Tracker) Record(good bool) (status Status, changed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	index := t.now().UnixNano() / int64(t.width)
+	b := &t.buckets[index%buckets]
+	if b.index != index {
+		*b = bucket{index: index}
+		status = t.status(index)
+		if alerting := t.cfg.AlertBurnRate > 0 && status.BurnRate > t.cfg.AlertBurnRate && status.ShortBurnRate > t.cfg.AlertBurnRate; alerting != t.alerting {
+			t.alerting = alerting
+			changed = true
+		}
+	}
+	b.total++
+	if good {
+		b.good++
+	}
+	if !changed {
+		return Status{}, false
+	}
+	status.Alerting = t.alerting
+	return status, true
+}
+
+// Status returns the compliance over the window
+func (t *Tracker) Status() Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := t.status(t.now().UnixNano() / int64(t.width))
+	status.Alerting = t.alerting
+	return status
+}
+
+// status sums the buckets of the window ending with the bucket at index, the
+// caller holds the lock
+func (t *Tracker) status(index int64) Status {
+	var status Status
+	var shortTotal, shortGood int64
+	for i := range t.buckets {
+		b := &t.buckets[i]
+		age := index - b.index
+		if age < 0 || age >= buckets {
+			continue
+		}
+		status.Total += b.total
+		status.Good += b.good
+		if age < shortBuckets {
+			shortTotal += b.total
+			shortGood += b.good
+		}
+	}
+
+	status.Compliance = 1
+	if status.Total > 0 {
+		status.Compliance = float64(status.Good) / float64(status.Total)
+	}
+	status.BurnRate = t.burnRate(status.Total, status.Good)
+	status.ShortBurnRate = t.burnRate(shortTotal, shortGood)
+	return status
+}
+
+func (t *Tracker) burnRate(total, good int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(total-good) / float64(total) / t.budget
+}
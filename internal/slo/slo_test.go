@@ -0,0 +1,95 @@
+package slo
+
+import (
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracker_Compliance(t *testing.T) {
+	tracker := NewTracker(config.SLOConfig{Objective: 99, Latency: 300 * time.Millisecond, Window: time.Hour})
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	assert.Equal(t, Status{Compliance: 1}, tracker.Status())
+
+	assert.True(t, tracker.Good(200, 300*time.Millisecond))
+	assert.False(t, tracker.Good(200, 301*time.Millisecond))
+	assert.False(t, tracker.Good(503, time.Millisecond))
+	assert.False(t, tracker.Good(0, time.Millisecond))
+	assert.True(t, tracker.Good(404, time.Millisecond))
+
+	for i := 0; i < 98; i++ {
+		tracker.Record(true)
+	}
+	tracker.Record(false)
+	tracker.Record(false)
+
+	status := tracker.Status()
+	assert.Equal(t, int64(100), status.Total)
+	assert.Equal(t, int64(98), status.Good)
+	assert.InDelta(t, 0.98, status.Compliance, 1e-9)
+	// 2% bad requests spend a 1% budget twice as fast as the window allows
+	assert.InDelta(t, 2, status.BurnRate, 1e-9)
+	assert.InDelta(t, 2, status.ShortBurnRate, 1e-9)
+
+	// Requests leave the short window after 5 minutes and the window after an hour
+	now = now.Add(10 * time.Minute)
+	status = tracker.Status()
+	assert.Equal(t, int64(100), status.Total)
+	assert.Zero(t, status.ShortBurnRate)
+	now = now.Add(time.Hour)
+	assert.Equal(t, Status{Compliance: 1}, tracker.Status())
+}
+
+func TestTracker_Alert(t *testing.T) {
+	tracker := NewTracker(config.SLOConfig{Objective: 99, Latency: time.Second, Window: time.Hour, AlertBurnRate: 10})
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	// Half of the requests fail, 50 times the budget
+	for i := 0; i < 10; i++ {
+		_, changed := tracker.Record(i%2 == 0)
+		assert.False(t, changed)
+	}
+
+	// The alert is evaluated when the next bucket starts
+	now = now.Add(time.Minute)
+	status, changed := tracker.Record(true)
+	assert.True(t, changed)
+	assert.True(t, status.Alerting)
+	assert.InDelta(t, 50, status.BurnRate, 1e-9)
+	_, changed = tracker.Record(true)
+	assert.False(t, changed)
+	assert.True(t, tracker.Status().Alerting)
+
+	// The alert ends once the failures leave the short window, while the
+	// burn rate over the window still exceeds the threshold
+	for i := 0; i < 3; i++ {
+		now = now.Add(time.Minute)
+		_, changed = tracker.Record(true)
+		assert.False(t, changed)
+	}
+	now = now.Add(time.Minute)
+	status, changed = tracker.Record(true)
+	assert.True(t, changed)
+	assert.False(t, status.Alerting)
+	assert.Zero(t, status.ShortBurnRate)
+	assert.Greater(t, status.BurnRate, 10.0)
+}
+
+func TestTracker_NoAlertBurnRate(t *testing.T) {
+	tracker := NewTracker(config.SLOConfig{Objective: 99, Latency: time.Second})
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+	assert.Equal(t, DefaultWindow, tracker.Window())
+
+	tracker.Record(false)
+	now = now.Add(time.Minute)
+	_, changed := tracker.Record(false)
+	assert.False(t, changed)
+	assert.False(t, tracker.Status().Alerting)
+}
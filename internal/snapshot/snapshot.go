@@ -0,0 +1,219 @@
+package snapshot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultKeep is the number of snapshots kept when the config sets none
+const DefaultKeep = 10
+
+// timeFormat is the time in snapshot names, they sort by name
+const timeFormat = "20060102T150405.000Z"
+
+// namePattern matches snapshot file names: time, digest prefix, extension
+var namePattern = regexp.MustCompile(`^(\d{8}T\d{6}\.\d{3}Z)-([0-9a-f]{12})(\.[a-z]+)?$`)
+
+// ErrNotFound is returned for names matching no snapshot
+var ErrNotFound = errors.New("snapshot not found")
+
+// Snapshot is a config file kept by a store
+type Snapshot struct {
+	Name   string    `json:"name"`
+	Time   time.Time `json:"time"`
+	Digest string    `json:"digest"` // Prefix of the SHA-256 of the contents
+	Size   int64     `json:"size"`
+}
+
+// Store keeps the last config files applied in a directory, one file per
+// snapshot. Saving the contents of the latest snapshot again is a no-op
+type Store struct {
+	dir  string
+	keep int
+	ext  string // Extension of the config file, kept so snapshots load the same way
+	now  func() time.Time
+
+	mu sync.Mutex
+}
+
+// New creates a store keeping keep snapshots of a config file with extension
+// ext in dir, DefaultKeep when keep is 0
+func New(dir string, keep int, ext string) *Store {
+	if keep == 0 {
+		keep = DefaultKeep
+	}
+	return &Store{dir: dir, keep: keep, ext: ext, now: time.Now}
+}
+
+// Dir returns the snapshot directory
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Save keeps data as the latest snapshot and removes the ones past the
+// number kept, it returns the snapshot holding data
+func (s *Store) Save(data []byte) (Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return Snapshot{}, err
+	}
+	snapshots, err := s.list()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	digest := digest(data)
+	if len(snapshots) > 0 && snapshots[0].Digest == digest {
+		return snapshots[0], nil
+	}
+
+	now := s.now().UTC().Truncate(time.Millisecond)
+	if len(snapshots) > 0 && !now.After(snapshots[0].Time) {
+		// Names sort in the order of the snapshots
+		now = snapshots[0].Time.Add(time.Millisecond)
+	}
+	snap := Snapshot{
+		Name:   now.Format(timeFormat) + "-" + digest + s.ext,
+		Time:   now,
+		Digest: digest,
+		Size:   int64(len(data)),
+	}
+	// Configs may carry secrets
+	if err := writeFile(filepath.Join(s.dir, snap.Name), data, 0o600); err != nil {
+		return Snapshot{}, err
+	}
+	snapshots = append([]Snapshot{snap}, snapshots...)
+	for _, old := range snapshots[min(s.keep, len(snapshots)):] {
+		if err := os.Remove(filepath.Join(s.dir, old.Name)); err != nil && !os.IsNotExist(err) {
+			return snap, err
+		}
+	}
+
+	return snap, nil
+}
+
+// List returns the snapshots, latest first
+func (s *Store) List() ([]Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.list()
+}
+
+// Read returns the name and contents of the snapshot named name, or the only
+// one whose name starts with it
+func (s *Store) Read(name string) (string, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshots, err := s.list()
+	if err != nil {
+		return "", nil, err
+	}
+	var matches []string
+	for _, snap := range snapshots {
+		if snap.Name == name {
+			matches = []string{name}
+			break
+		}
+		if name != "" && strings.HasPrefix(snap.Name, name) {
+			matches = append(matches, snap.Name)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	case 1:
+	default:
+		return "", nil, fmt.Errorf("snapshot name %s is ambiguous, it matches %s", name, strings.Join(matches, ", "))
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, matches[0]))
+	return matches[0], data, err
+}
+
+// list reads the snapshots in the directory, the caller must hold s.mu
+func (s *Store) list() ([]Snapshot, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	for _, entry := range entries {
+		m := namePattern.FindStringSubmatch(entry.Name())
+		if m == nil || !entry.Type().IsRegular() {
+			continue
+		}
+		t, err := time.Parse(timeFormat, m[1])
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{Name: entry.Name(), Time: t, Digest: m[2], Size: info.Size()})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Name > snapshots[j].Name
+	})
+
+	return snapshots, nil
+}
+
+// digest returns the prefix of the SHA-256 of data in snapshot names
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+// WriteFile replaces the file at path with data atomically, readers see the
+// previous or the new contents but never a partial file. The permissions of
+// the replaced file are kept
+func WriteFile(path string, data []byte) error {
+	perm := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	return writeFile(path, data, perm)
+}
+
+// writeFile writes data to a temporary file next to path and renames it
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
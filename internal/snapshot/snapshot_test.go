@@ -0,0 +1,98 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStore returns a store whose clock moves a second per snapshot
+func newTestStore(t *testing.T, keep int) *Store {
+	s := New(filepath.Join(t.TempDir(), "snapshots"), keep, ".yaml")
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	return s
+}
+
+func TestStore_SaveAndPrune(t *testing.T) {
+	s := newTestStore(t, 2)
+
+	first, err := s.Save([]byte("a"))
+	require.NoError(t, err)
+	assert.Equal(t, "20240501T120001.000Z-"+digest([]byte("a"))+".yaml", first.Name)
+
+	// The same contents are kept once
+	again, err := s.Save([]byte("a"))
+	require.NoError(t, err)
+	assert.Equal(t, first.Name, again.Name)
+
+	_, err = s.Save([]byte("b"))
+	require.NoError(t, err)
+	latest, err := s.Save([]byte("c"))
+	require.NoError(t, err)
+
+	snapshots, err := s.List()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, latest, snapshots[0])
+	assert.Equal(t, digest([]byte("b")), snapshots[1].Digest)
+
+	info, err := os.Stat(filepath.Join(s.Dir(), latest.Name))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestStore_Read(t *testing.T) {
+	s := newTestStore(t, 0)
+
+	_, _, err := s.Read("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	first, err := s.Save([]byte("a"))
+	require.NoError(t, err)
+	_, err = s.Save([]byte("b"))
+	require.NoError(t, err)
+
+	name, data, err := s.Read(first.Name)
+	require.NoError(t, err)
+	assert.Equal(t, first.Name, name)
+	assert.Equal(t, "a", string(data))
+
+	name, data, err = s.Read("20240501T120002")
+	require.NoError(t, err)
+	assert.Equal(t, "b", string(data))
+	assert.NotEqual(t, first.Name, name)
+
+	_, _, err = s.Read("20240501T1200")
+	assert.ErrorContains(t, err, "ambiguous")
+
+	// Only files of the store are read
+	require.NoError(t, os.WriteFile(filepath.Join(t.TempDir(), "other.yaml"), []byte("x"), 0o600))
+	_, _, err = s.Read("../other.yaml")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0o640))
+
+	require.NoError(t, WriteFile(path, []byte("new")))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+
+	// No temporary file is left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
@@ -0,0 +1,159 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	lg "nexus/internal/logger"
+	"nexus/internal/route"
+)
+
+// currentVersion is the version of the state file format
+const currentVersion = 1
+
+// State is the operational state that survives restarts
+type State struct {
+	Version int `json:"version"`
+
+	// Drains maps service -> server address -> drain reasons set by operators
+	Drains map[string]map[string][]string `json:"drains"`
+}
+
+// Store persists runtime state to a small JSON file
+type Store struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewStore creates a state store backed by the given file
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Path returns the state file path
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Load reads the state file, a missing file yields an empty state
+func (s *Store) Load() (*State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return newState(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	st := newState()
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", s.path, err)
+	}
+	if st.Version > currentVersion {
+		return nil, fmt.Errorf("unsupported state file version: %d", st.Version)
+	}
+
+	return st, nil
+}
+
+// Save atomically replaces the state file
+func (s *Store) Save(st *State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st.Version = currentVersion
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".nexus-state-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Capture collects the drains with one of the given reasons from the router
+func Capture(router route.Router, reasons ...string) *State {
+	keep := make(map[string]bool, len(reasons))
+	for _, reason := range reasons {
+		keep[reason] = true
+	}
+
+	st := newState()
+	for name, svc := range router.Services() {
+		for address, drainReasons := range svc.Drained() {
+			persisted := make([]string, 0, len(drainReasons))
+			for _, reason := range drainReasons {
+				if keep[reason] {
+					persisted = append(persisted, reason)
+				}
+			}
+			if len(persisted) == 0 {
+				continue
+			}
+			sort.Strings(persisted)
+			if st.Drains[name] == nil {
+				st.Drains[name] = make(map[string][]string)
+			}
+			st.Drains[name][address] = persisted
+		}
+	}
+
+	return st
+}
+
+// Apply restores the state on the router, entries for unknown services or servers are skipped
+func Apply(router route.Router, st *State) {
+	logger := lg.GetInstance()
+	services := router.Services()
+
+	for name, servers := range st.Drains {
+		svc, ok := services[name]
+		if !ok {
+			logger.Warn("Skipping persisted state of unknown service %s", name)
+			continue
+		}
+		for address, reasons := range servers {
+			for _, reason := range reasons {
+				if err := svc.Drain(address, reason); err != nil {
+					logger.Warn("Skipping persisted drain: %v", err)
+				}
+			}
+		}
+	}
+}
+
+func newState() *State {
+	return &State{
+		Version: currentVersion,
+		Drains:  make(map[string]map[string][]string),
+	}
+}
@@ -0,0 +1,86 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRouter() route.Router {
+	return route.NewRouter(nil, map[string]*config.ServiceConfig{
+		"api": {
+			Name:         "api",
+			BalancerType: "round_robin",
+			Servers: []config.ServerConfig{
+				{Address: "http://backend1"},
+				{Address: "http://backend2"},
+			},
+		},
+	})
+}
+
+func TestStore_LoadMissingFile(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "state.json"))
+
+	st, err := store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, currentVersion, st.Version)
+	assert.Empty(t, st.Drains)
+}
+
+func TestStore_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+	store := NewStore(path)
+
+	st := newState()
+	st.Drains["api"] = map[string][]string{"http://backend1": {"manual"}}
+	require.NoError(t, store.Save(st))
+
+	loaded, err := store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, st.Drains, loaded.Drains)
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestStore_LoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o644))
+	_, err := NewStore(path).Load()
+	assert.ErrorContains(t, err, "invalid state file")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"version": 99}`), 0o644))
+	_, err = NewStore(path).Load()
+	assert.ErrorContains(t, err, "unsupported state file version")
+}
+
+func TestCaptureAndApply(t *testing.T) {
+	router := newTestRouter()
+	svc := router.Services()["api"]
+	require.NoError(t, svc.Drain("http://backend1", "manual"))
+	require.NoError(t, svc.Drain("http://backend2", "maintenance"))
+
+	// Only the requested reasons are captured
+	st := Capture(router, "manual")
+	assert.Equal(t, map[string]map[string][]string{
+		"api": {"http://backend1": {"manual"}},
+	}, st.Drains)
+
+	// A fresh router after restart gets the operator drains back
+	st.Drains["web"] = map[string][]string{"http://web1": {"manual"}}
+	st.Drains["api"]["http://gone"] = []string{"manual"}
+
+	restarted := newTestRouter()
+	Apply(restarted, st)
+	assert.Equal(t, map[string][]string{"http://backend1": {"manual"}}, restarted.Services()["api"].Drained())
+}
@@ -0,0 +1,57 @@
+package stats
+
+import "sync"
+
+// PoolCounters are the upstream connection counters of a backend server
+type PoolCounters struct {
+	Open      int64 // Connections dialed and not closed yet
+	Active    int64 // Open connections serving a request
+	Idle      int64 // Open connections waiting in the pool, computed by Snapshot
+	New       int64 // Requests sent on a newly dialed connection
+	Reused    int64 // Requests sent on a pooled connection
+	Discarded int64 // Response bodies closed before their end, their connection isn't reused
+	Leaked    int64 // Response bodies still open after the leak timeout
+}
+
+// Pool counts the upstream connections of the proxy per backend server
+type Pool struct {
+	mu      sync.Mutex
+	servers map[string]*PoolCounters
+}
+
+// NewPool creates an empty pool collector
+func NewPool() *Pool {
+	return &Pool{servers: make(map[string]*PoolCounters)}
+}
+
+// Add adds the counters of delta to those of a server
+func (p *Pool) Add(server string, delta PoolCounters) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cnt, ok := p.servers[server]
+	if !ok {
+		cnt = &PoolCounters{}
+		p.servers[server] = cnt
+	}
+	cnt.Open += delta.Open
+	cnt.Active += delta.Active
+	cnt.New += delta.New
+	cnt.Reused += delta.Reused
+	cnt.Discarded += delta.Discarded
+	cnt.Leaked += delta.Leaked
+}
+
+// Snapshot returns a copy of the counters by server address
+func (p *Pool) Snapshot() map[string]PoolCounters {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	snapshot := make(map[string]PoolCounters, len(p.servers))
+	for server, cnt := range p.servers {
+		c := *cnt
+		c.Idle = max(c.Open-c.Active, 0)
+		snapshot[server] = c
+	}
+	return snapshot
+}
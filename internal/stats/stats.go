@@ -0,0 +1,240 @@
+package stats
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// StatusClientClosedRequest is the status recorded for requests the client
+// abandoned before the response ended, as logged by nginx
+const StatusClientClosedRequest = 499
+
+// Counters are the traffic counters of a frontend, backend or server
+type Counters struct {
+	Current   int64    // Requests in flight
+	Max       int64    // Highest number of requests in flight
+	Total     int64    // Requests started
+	BytesIn   int64    // Request body bytes received
+	BytesOut  int64    // Response body bytes sent
+	Responses [6]int64 // Responses by status class: 1xx to 5xx, then other
+	Denied    int64    // Requests rejected before reaching a server, e.g. rate limited
+	Errors    int64    // Requests without a matching route or an available server
+	Canceled  int64    // Requests abandoned by the client before the response ended
+	Rate      int64    // Requests started during the last second
+	RateMax   int64    // Highest requests per second
+
+	second int64 // unix second counted by count
+	count  int64
+}
+
+// Snapshot is a copy of every counter
+type Snapshot struct {
+	Frontends map[string]Counters
+	Backends  map[string]Counters
+	Servers   map[string]map[string]Counters // backend -> server address
+	Routes    map[string]Counters
+}
+
+// Collector counts traffic per frontend, backend and server
+type Collector struct {
+	mu        sync.Mutex
+	frontends map[string]*Counters
+	backends  map[string]*Counters
+	servers   map[string]map[string]*Counters
+	routes    map[string]*Counters
+	now       func() time.Time
+}
+
+// NewCollector creates an empty collector
+func NewCollector() *Collector {
+	return &Collector{
+		frontends: make(map[string]*Counters),
+		backends:  make(map[string]*Counters),
+		servers:   make(map[string]map[string]*Counters),
+		routes:    make(map[string]*Counters),
+		now:       time.Now,
+	}
+}
+
+// Request tracks one request until it finishes
+type Request struct {
+	c        *Collector
+	frontend string
+	backend  string
+	server   string
+	route    string
+}
+
+// Start counts a new request, backend is empty when no route matched
+func (c *Collector) Start(frontend, backend string) *Request {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now().Unix()
+	start(counters(c.frontends, frontend), now)
+	if backend != "" {
+		start(counters(c.backends, backend), now)
+	}
+
+	return &Request{c: c, frontend: frontend, backend: backend}
+}
+
+// SetServer records the backend server selected for the request
+func (r *Request) SetServer(address string) {
+	if r.backend == "" || r.server != "" {
+		return
+	}
+
+	r.c.mu.Lock()
+	defer r.c.mu.Unlock()
+
+	servers, ok := r.c.servers[r.backend]
+	if !ok {
+		servers = make(map[string]*Counters)
+		r.c.servers[r.backend] = servers
+	}
+	start(counters(servers, address), r.c.now().Unix())
+	r.server = address
+}
+
+// SetRoute records the route matched by the request
+func (r *Request) SetRoute(name string) {
+	if name == "" || r.route != "" {
+		return
+	}
+
+	r.c.mu.Lock()
+	defer r.c.mu.Unlock()
+
+	start(counters(r.c.routes, name), r.c.now().Unix())
+	r.route = name
+}
+
+// Finish records the response of the request
+func (r *Request) Finish(status int, bytesIn, bytesOut int64) {
+	r.c.mu.Lock()
+	defer r.c.mu.Unlock()
+
+	if r.route != "" {
+		finish(r.c.routes[r.route], status, bytesIn, bytesOut)
+	}
+
+	// Canceled requests are neither errors nor denied
+	canceled := status == StatusClientClosedRequest
+	frontend := r.c.frontends[r.frontend]
+	finish(frontend, status, bytesIn, bytesOut)
+	if r.backend == "" {
+		if !canceled {
+			frontend.Errors++
+		}
+		return
+	}
+	backend := r.c.backends[r.backend]
+	finish(backend, status, bytesIn, bytesOut)
+	if r.server == "" {
+		if canceled {
+			return
+		}
+		if status >= 500 {
+			backend.Errors++
+		} else {
+			backend.Denied++
+		}
+		return
+	}
+	finish(r.c.servers[r.backend][r.server], status, bytesIn, bytesOut)
+}
+
+// Snapshot returns a copy of the counters
+func (c *Collector) Snapshot() Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now().Unix()
+	snapshot := Snapshot{
+		Frontends: copyCounters(c.frontends, now),
+		Backends:  copyCounters(c.backends, now),
+		Servers:   make(map[string]map[string]Counters, len(c.servers)),
+		Routes:    copyCounters(c.routes, now),
+	}
+	for backend, servers := range c.servers {
+		snapshot.Servers[backend] = copyCounters(servers, now)
+	}
+
+	return snapshot
+}
+
+func counters(m map[string]*Counters, name string) *Counters {
+	cnt, ok := m[name]
+	if !ok {
+		cnt = &Counters{}
+		m[name] = cnt
+	}
+	return cnt
+}
+
+func start(cnt *Counters, now int64) {
+	cnt.Current++
+	cnt.Total++
+	if cnt.Current > cnt.Max {
+		cnt.Max = cnt.Current
+	}
+
+	cnt.roll(now)
+	cnt.count++
+	if cnt.count > cnt.RateMax {
+		cnt.RateMax = cnt.count
+	}
+}
+
+func finish(cnt *Counters, status int, bytesIn, bytesOut int64) {
+	cnt.Current--
+	cnt.BytesIn += bytesIn
+	cnt.BytesOut += bytesOut
+
+	class := status/100 - 1
+	if class < 0 || class > 4 {
+		class = 5
+	}
+	cnt.Responses[class]++
+	if status == StatusClientClosedRequest {
+		cnt.Canceled++
+	}
+}
+
+// roll moves the per second count forward, Rate holds the last full second
+func (cnt *Counters) roll(now int64) {
+	if now == cnt.second {
+		return
+	}
+	if now == cnt.second+1 {
+		cnt.Rate = cnt.count
+	} else {
+		cnt.Rate = 0
+	}
+	cnt.second = now
+	cnt.count = 0
+}
+
+func copyCounters(m map[string]*Counters, now int64) map[string]Counters {
+	copied := make(map[string]Counters, len(m))
+	for name, cnt := range m {
+		cnt.roll(now)
+		copied[name] = *cnt
+	}
+	return copied
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the request tracker
+func NewContext(ctx context.Context, req *Request) context.Context {
+	return context.WithValue(ctx, contextKey{}, req)
+}
+
+// FromContext returns the request tracker stored in the context, nil when none
+func FromContext(ctx context.Context) *Request {
+	req, _ := ctx.Value(contextKey{}).(*Request)
+	return req
+}
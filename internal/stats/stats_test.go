@@ -0,0 +1,137 @@
+package stats
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector()
+
+	first := c.Start("http", "api")
+	first.SetServer("http://backend1")
+	second := c.Start("http", "api")
+	second.SetServer("http://backend2")
+	snapshot := c.Snapshot()
+	assert.Equal(t, int64(2), snapshot.Frontends["http"].Current)
+	assert.Equal(t, int64(2), snapshot.Backends["api"].Current)
+	assert.Equal(t, int64(1), snapshot.Servers["api"]["http://backend1"].Current)
+
+	first.Finish(http.StatusOK, 10, 100)
+	second.Finish(http.StatusBadGateway, 0, 20)
+	c.Start("http", "").Finish(http.StatusServiceUnavailable, 0, 5)
+	c.Start("http", "api").Finish(http.StatusTooManyRequests, 0, 5)
+
+	snapshot = c.Snapshot()
+	frontend := snapshot.Frontends["http"]
+	assert.Equal(t, int64(0), frontend.Current)
+	assert.Equal(t, int64(2), frontend.Max)
+	assert.Equal(t, int64(4), frontend.Total)
+	assert.Equal(t, int64(10), frontend.BytesIn)
+	assert.Equal(t, int64(130), frontend.BytesOut)
+	assert.Equal(t, [6]int64{0, 1, 0, 1, 2, 0}, frontend.Responses)
+	assert.Equal(t, int64(1), frontend.Errors)
+
+	backend := snapshot.Backends["api"]
+	assert.Equal(t, int64(3), backend.Total)
+	assert.Equal(t, int64(1), backend.Denied)
+	assert.Equal(t, int64(0), backend.Errors)
+
+	server := snapshot.Servers["api"]["http://backend2"]
+	assert.Equal(t, int64(1), server.Total)
+	assert.Equal(t, [6]int64{0, 0, 0, 0, 1, 0}, server.Responses)
+}
+
+func TestCollector_Routes(t *testing.T) {
+	c := NewCollector()
+
+	req := c.Start("http", "api")
+	req.SetRoute("users")
+	req.SetRoute("ignored")
+	req.SetServer("http://backend1")
+	assert.Equal(t, int64(1), c.Snapshot().Routes["users"].Current)
+	req.Finish(http.StatusOK, 10, 100)
+
+	req = c.Start("http", "api")
+	req.SetRoute("users")
+	req.Finish(http.StatusTooManyRequests, 5, 20)
+	c.Start("http", "").Finish(http.StatusNotFound, 0, 9)
+
+	snapshot := c.Snapshot()
+	require.Len(t, snapshot.Routes, 1)
+	route := snapshot.Routes["users"]
+	assert.Equal(t, int64(0), route.Current)
+	assert.Equal(t, int64(2), route.Total)
+	assert.Equal(t, int64(15), route.BytesIn)
+	assert.Equal(t, int64(120), route.BytesOut)
+	assert.Equal(t, [6]int64{0, 1, 0, 1, 0, 0}, route.Responses)
+}
+
+func TestCollector_Rate(t *testing.T) {
+	c := NewCollector()
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		c.Start("http", "").Finish(http.StatusOK, 0, 0)
+	}
+	assert.Equal(t, int64(0), c.Snapshot().Frontends["http"].Rate)
+
+	now = now.Add(time.Second)
+	c.Start("http", "").Finish(http.StatusOK, 0, 0)
+	frontend := c.Snapshot().Frontends["http"]
+	assert.Equal(t, int64(3), frontend.Rate)
+	assert.Equal(t, int64(3), frontend.RateMax)
+
+	// An idle second resets the rate
+	now = now.Add(2 * time.Second)
+	assert.Equal(t, int64(0), c.Snapshot().Frontends["http"].Rate)
+}
+
+func TestContext(t *testing.T) {
+	assert.Nil(t, FromContext(context.Background()))
+
+	req := NewCollector().Start("http", "api")
+	require.Same(t, req, FromContext(NewContext(context.Background(), req)))
+}
+
+func TestPool(t *testing.T) {
+	p := NewPool()
+
+	p.Add("http://backend1", PoolCounters{Open: 1, Active: 1, New: 1})
+	p.Add("http://backend1", PoolCounters{Open: 1, Active: 1, New: 1})
+	p.Add("http://backend1", PoolCounters{Active: -1})
+	p.Add("http://backend1", PoolCounters{Active: 1, Reused: 1})
+	p.Add("http://backend1", PoolCounters{Open: -1, Active: -1, Discarded: 1})
+	p.Add("http://backend2", PoolCounters{Leaked: 1})
+
+	assert.Equal(t, map[string]PoolCounters{
+		"http://backend1": {Open: 1, Active: 1, New: 2, Reused: 1, Discarded: 1},
+		"http://backend2": {Leaked: 1},
+	}, p.Snapshot())
+
+	p.Add("http://backend1", PoolCounters{Active: -1})
+	assert.Equal(t, int64(1), p.Snapshot()["http://backend1"].Idle)
+}
+
+func TestCollector_Canceled(t *testing.T) {
+	c := NewCollector()
+
+	req := c.Start("http", "api")
+	req.SetServer("http://backend1")
+	req.Finish(StatusClientClosedRequest, 0, 10)
+	c.Start("http", "api").Finish(StatusClientClosedRequest, 0, 0)
+	c.Start("http", "").Finish(StatusClientClosedRequest, 0, 0)
+
+	snapshot := c.Snapshot()
+	assert.Equal(t, int64(3), snapshot.Frontends["http"].Canceled)
+	assert.Equal(t, int64(0), snapshot.Frontends["http"].Errors)
+	assert.Equal(t, int64(2), snapshot.Backends["api"].Canceled)
+	assert.Equal(t, int64(0), snapshot.Backends["api"].Denied)
+	assert.Equal(t, int64(1), snapshot.Servers["api"]["http://backend1"].Canceled)
+}
@@ -0,0 +1,215 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+)
+
+// ConnCounters are the client connections of a listener
+type ConnCounters struct {
+	Listener          string `json:"listener"`
+	Accepted          int64  `json:"accepted"`           // Connections accepted since start
+	New               int64  `json:"new"`                // Open connections before their first request
+	Active            int64  `json:"active"`             // Open connections serving a request
+	Idle              int64  `json:"idle"`               // Open connections waiting for the next request
+	HandshakeFailures int64  `json:"handshake_failures"` // TLS connections closed before completing the handshake
+	BytesIn           int64  `json:"bytes_in"`           // Bytes read from clients, TLS records included
+	BytesOut          int64  `json:"bytes_out"`          // Bytes written to clients, TLS records included
+}
+
+// ConnMetrics counts the client connections of the listeners, by state and
+// with the bytes they carried
+type ConnMetrics struct {
+	mu        sync.Mutex
+	listeners map[string]*listenerCounters
+}
+
+// listenerCounters are the counters of one listener, bytes are counted on
+// every read and write so they are atomics
+type listenerCounters struct {
+	accepted          atomic.Int64
+	handshakeFailures atomic.Int64
+	bytesIn           atomic.Int64
+	bytesOut          atomic.Int64
+
+	mu     sync.Mutex
+	states map[net.Conn]http.ConnState // Open connections, hijacked ones are no longer tracked
+}
+
+// NewConnMetrics creates connection metrics without listeners
+func NewConnMetrics() *ConnMetrics {
+	return &ConnMetrics{listeners: make(map[string]*listenerCounters)}
+}
+
+// Listen listens on the address of server and counts the connections served
+// by it under name
+func (m *ConnMetrics) Listen(name string, server *http.Server) (net.Listener, error) {
+	addr := server.Addr
+	if addr == "" && server.TLSConfig != nil {
+		addr = ":https"
+	} else if addr == "" {
+		addr = ":http"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return m.Instrument(name, ln, server), nil
+}
+
+// Instrument counts the connections accepted by ln and served by server under
+// name, the returned listener must be passed to Serve or ServeTLS
+func (m *ConnMetrics) Instrument(name string, ln net.Listener, server *http.Server) net.Listener {
+	m.mu.Lock()
+	counters, ok := m.listeners[name]
+	if !ok {
+		counters = &listenerCounters{states: make(map[net.Conn]http.ConnState)}
+		m.listeners[name] = counters
+	}
+	m.mu.Unlock()
+
+	next := server.ConnState
+	server.ConnState = func(conn net.Conn, state http.ConnState) {
+		counters.setState(conn, state)
+		if next != nil {
+			next(conn, state)
+		}
+	}
+	return &countingListener{Listener: ln, counters: counters}
+}
+
+// setState moves a connection to its new state
+func (c *listenerCounters) setState(conn net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateClosed:
+		// Listeners serving TLS hand out tls.Conn, closed before the handshake
+		// completed when it failed
+		if tc, ok := conn.(*tls.Conn); ok && !tc.ConnectionState().HandshakeComplete {
+			c.handshakeFailures.Add(1)
+		}
+		fallthrough
+	case http.StateHijacked:
+		c.mu.Lock()
+		delete(c.states, conn)
+		c.mu.Unlock()
+	default:
+		c.mu.Lock()
+		c.states[conn] = state
+		c.mu.Unlock()
+	}
+}
+
+// Snapshot returns the counters of the listeners sorted by name
+func (m *ConnMetrics) Snapshot() []ConnCounters {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make([]ConnCounters, 0, len(m.listeners))
+	for name, c := range m.listeners {
+		counters := ConnCounters{
+			Listener:          name,
+			Accepted:          c.accepted.Load(),
+			HandshakeFailures: c.handshakeFailures.Load(),
+			BytesIn:           c.bytesIn.Load(),
+			BytesOut:          c.bytesOut.Load(),
+		}
+		c.mu.Lock()
+		for _, state := range c.states {
+			switch state {
+			case http.StateNew:
+				counters.New++
+			case http.StateActive:
+				counters.Active++
+			case http.StateIdle:
+				counters.Idle++
+			}
+		}
+		c.mu.Unlock()
+		snapshot = append(snapshot, counters)
+	}
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].Listener < snapshot[j].Listener })
+
+	return snapshot
+}
+
+// Register reports the connection counters as OpenTelemetry metrics
+func (m *ConnMetrics) Register(meter otelmetric.Meter) error {
+	accepted, err := meter.Int64ObservableCounter("nexus.server.connections.accepted",
+		otelmetric.WithDescription("Client connections accepted by a listener"))
+	if err != nil {
+		return err
+	}
+	connections, err := meter.Int64ObservableGauge("nexus.server.connections",
+		otelmetric.WithDescription("Open client connections by state, new, active or idle"))
+	if err != nil {
+		return err
+	}
+	failures, err := meter.Int64ObservableCounter("nexus.server.tls.handshake.failures",
+		otelmetric.WithDescription("Client connections closed before completing the TLS handshake"))
+	if err != nil {
+		return err
+	}
+	transferred, err := meter.Int64ObservableCounter("nexus.server.io",
+		otelmetric.WithDescription("Bytes exchanged with clients by direction, in or out"),
+		otelmetric.WithUnit("By"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o otelmetric.Observer) error {
+		for _, c := range m.Snapshot() {
+			listener := attribute.String("listener", c.Listener)
+			o.ObserveInt64(accepted, c.Accepted, otelmetric.WithAttributes(listener))
+			o.ObserveInt64(connections, c.New, otelmetric.WithAttributes(listener, attribute.String("state", "new")))
+			o.ObserveInt64(connections, c.Active, otelmetric.WithAttributes(listener, attribute.String("state", "active")))
+			o.ObserveInt64(connections, c.Idle, otelmetric.WithAttributes(listener, attribute.String("state", "idle")))
+			o.ObserveInt64(failures, c.HandshakeFailures, otelmetric.WithAttributes(listener))
+			o.ObserveInt64(transferred, c.BytesIn, otelmetric.WithAttributes(listener, attribute.String("direction", "in")))
+			o.ObserveInt64(transferred, c.BytesOut, otelmetric.WithAttributes(listener, attribute.String("direction", "out")))
+		}
+		return nil
+	}, accepted, connections, failures, transferred)
+
+	return err
+}
+
+// countingListener counts the accepted connections and their bytes
+type countingListener struct {
+	net.Listener
+	counters *listenerCounters
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.counters.accepted.Add(1)
+	return &countingConn{Conn: conn, counters: l.counters}, nil
+}
+
+// countingConn counts the bytes read and written
+type countingConn struct {
+	net.Conn
+	counters *listenerCounters
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.counters.bytesIn.Add(int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.counters.bytesOut.Add(int64(n))
+	return n, err
+}
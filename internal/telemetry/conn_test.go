@@ -0,0 +1,77 @@
+package telemetry
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// connTestServer starts a server whose connections are counted under name
+func connTestServer(t *testing.T, m *ConnMetrics, name string, useTLS bool) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	server.Listener = m.Instrument(name, server.Listener, server.Config)
+	if useTLS {
+		server.StartTLS()
+	} else {
+		server.Start()
+	}
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestConnMetrics_CountsConnectionsAndBytes(t *testing.T) {
+	m := NewConnMetrics()
+	server := connTestServer(t, m, "http", false)
+
+	resp, err := server.Client().Get(server.URL)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "hello", string(body))
+
+	// The keep-alive connection waits for the next request
+	require.Eventually(t, func() bool { return m.Snapshot()[0].Idle == 1 }, time.Second, 5*time.Millisecond)
+	counters := m.Snapshot()[0]
+	assert.Equal(t, "http", counters.Listener)
+	assert.Equal(t, int64(1), counters.Accepted)
+	assert.Zero(t, counters.Active)
+	assert.Positive(t, counters.BytesIn)
+	assert.Greater(t, counters.BytesOut, int64(len("hello")))
+
+	server.Client().CloseIdleConnections()
+	require.Eventually(t, func() bool { return m.Snapshot()[0].Idle == 0 }, time.Second, 5*time.Millisecond)
+	assert.Zero(t, m.Snapshot()[0].HandshakeFailures)
+}
+
+func TestConnMetrics_CountsHandshakeFailures(t *testing.T) {
+	m := NewConnMetrics()
+	server := connTestServer(t, m, "https", true)
+
+	resp, err := server.Client().Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	server.Client().CloseIdleConnections()
+
+	// A client that doesn't trust the certificate, and one that never handshakes
+	_, err = (&http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{}}}).Get(server.URL)
+	require.Error(t, err)
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	require.NoError(t, err)
+	conn.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+	conn.Close()
+
+	require.Eventually(t, func() bool {
+		counters := m.Snapshot()[0]
+		return counters.HandshakeFailures == 2 && counters.New+counters.Active+counters.Idle == 0
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int64(3), m.Snapshot()[0].Accepted)
+}
@@ -0,0 +1,266 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lg "nexus/internal/logger"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Defaults of the OpenTelemetry batch log processor
+const (
+	logBatchSize     = 512
+	logQueueSize     = 2048
+	logFlushInterval = time.Second
+	logExportTimeout = 30 * time.Second
+)
+
+// LogRecord is a log exported as an OpenTelemetry log record
+type LogRecord struct {
+	Time       time.Time
+	Severity   lg.LogLevel
+	Body       string
+	Attributes []attribute.KeyValue
+	TraceID    trace.TraceID // Taken from the span of the context passed to Emit when empty
+	SpanID     trace.SpanID
+}
+
+// Proto returns the OTLP log record
+func (r LogRecord) Proto() *logspb.LogRecord {
+	severity, text := logSeverity(r.Severity)
+	record := &logspb.LogRecord{
+		TimeUnixNano:         uint64(r.Time.UnixNano()),
+		ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+		SeverityNumber:       severity,
+		SeverityText:         text,
+		Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: r.Body}},
+		Attributes:           protoAttributes(r.Attributes),
+	}
+	if r.TraceID.IsValid() {
+		record.TraceId = r.TraceID[:]
+	}
+	if r.SpanID.IsValid() {
+		record.SpanId = r.SpanID[:]
+	}
+	return record
+}
+
+func logSeverity(level lg.LogLevel) (logspb.SeverityNumber, string) {
+	switch level {
+	case lg.LevelDebug:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG, "DEBUG"
+	case lg.LevelWarn:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN, "WARN"
+	case lg.LevelError:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, "ERROR"
+	case lg.LevelFatal:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL, "FATAL"
+	default:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO, "INFO"
+	}
+}
+
+// protoAttributes converts attributes to OTLP, slices are sent as strings
+func protoAttributes(attributes []attribute.KeyValue) []*commonpb.KeyValue {
+	if len(attributes) == 0 {
+		return nil
+	}
+	kvs := make([]*commonpb.KeyValue, 0, len(attributes))
+	for _, kv := range attributes {
+		value := &commonpb.AnyValue{}
+		switch kv.Value.Type() {
+		case attribute.BOOL:
+			value.Value = &commonpb.AnyValue_BoolValue{BoolValue: kv.Value.AsBool()}
+		case attribute.INT64:
+			value.Value = &commonpb.AnyValue_IntValue{IntValue: kv.Value.AsInt64()}
+		case attribute.FLOAT64:
+			value.Value = &commonpb.AnyValue_DoubleValue{DoubleValue: kv.Value.AsFloat64()}
+		case attribute.STRING:
+			value.Value = &commonpb.AnyValue_StringValue{StringValue: kv.Value.AsString()}
+		default:
+			value.Value = &commonpb.AnyValue_StringValue{StringValue: kv.Value.Emit()}
+		}
+		kvs = append(kvs, &commonpb.KeyValue{Key: string(kv.Key), Value: value})
+	}
+	return kvs
+}
+
+// ExportRequest returns the OTLP export request of records logged by scope
+func ExportRequest(res *resource.Resource, scope string, records []*logspb.LogRecord) *collogspb.ExportLogsServiceRequest {
+	return &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: &resourcepb.Resource{Attributes: protoAttributes(res.Attributes())},
+			ScopeLogs: []*logspb.ScopeLogs{{
+				Scope:      &commonpb.InstrumentationScope{Name: scope},
+				LogRecords: records,
+			}},
+		}},
+	}
+}
+
+// LogExporter batches log records and exports them to the collector over
+// OTLP gRPC. Records are queued so logging never waits for the collector,
+// they are dropped while the queue is full
+type LogExporter struct {
+	conn     *grpc.ClientConn
+	client   collogspb.LogsServiceClient
+	resource *resource.Resource
+	queue    chan *logspb.LogRecord
+	done     chan struct{}
+	stopped  chan struct{}
+	once     sync.Once
+	dropped  atomic.Int64
+}
+
+// NewLogExporter creates an exporter sending logs to the collector at
+// endpoint, described by res
+func NewLogExporter(endpoint string, res *resource.Resource) (*LogExporter, error) {
+	conn, err := grpc.NewClient(endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log exporter: %w", err)
+	}
+
+	e := &LogExporter{
+		conn:     conn,
+		client:   collogspb.NewLogsServiceClient(conn),
+		resource: res,
+		queue:    make(chan *logspb.LogRecord, logQueueSize),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go e.run()
+
+	return e, nil
+}
+
+// Emit queues a record for export, records logged during a request are
+// correlated with its span through ctx
+func (e *LogExporter) Emit(ctx context.Context, r LogRecord) {
+	if !r.TraceID.IsValid() {
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			r.TraceID, r.SpanID = sc.TraceID(), sc.SpanID()
+		}
+	}
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+
+	select {
+	case e.queue <- r.Proto():
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+// LogHook returns a logger hook exporting the application logs from level
+// up, with their fields as attributes, see Logger.SetHook
+func (e *LogExporter) LogHook(level lg.LogLevel) func(lg.LogLevel, string, []lg.Field) {
+	return func(l lg.LogLevel, msg string, fields []lg.Field) {
+		if l >= level {
+			e.Emit(context.Background(), LogRecord{Severity: l, Body: msg, Attributes: fieldAttributes(fields)})
+		}
+	}
+}
+
+// fieldAttributes converts logger fields to attributes, values of other types
+// than booleans, numbers and strings are formatted
+func fieldAttributes(fields []lg.Field) []attribute.KeyValue {
+	if len(fields) == 0 {
+		return nil
+	}
+	attrs := make([]attribute.KeyValue, 0, len(fields))
+	for _, field := range fields {
+		switch v := field.Value.(type) {
+		case bool:
+			attrs = append(attrs, attribute.Bool(field.Key, v))
+		case int:
+			attrs = append(attrs, attribute.Int(field.Key, v))
+		case int64:
+			attrs = append(attrs, attribute.Int64(field.Key, v))
+		case float64:
+			attrs = append(attrs, attribute.Float64(field.Key, v))
+		case string:
+			attrs = append(attrs, attribute.String(field.Key, v))
+		default:
+			attrs = append(attrs, attribute.String(field.Key, fmt.Sprint(v)))
+		}
+	}
+	return attrs
+}
+
+// run exports full batches right away and partial ones every flush interval,
+// until the exporter is shut down
+func (e *LogExporter) run() {
+	defer close(e.stopped)
+
+	ticker := time.NewTicker(logFlushInterval)
+	defer ticker.Stop()
+
+	failing := false
+	batch := make([]*logspb.LogRecord, 0, logBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), logExportTimeout)
+		_, err := e.client.Export(ctx, ExportRequest(e.resource, "nexus", batch))
+		cancel()
+		// Logged once per outage, the warning is exported too
+		if err != nil && !failing {
+			lg.GetInstance().Warn("Failed to export %d logs: %v", len(batch), err)
+		}
+		failing = err != nil
+		batch = make([]*logspb.LogRecord, 0, logBatchSize)
+	}
+
+	for {
+		select {
+		case record := <-e.queue:
+			batch = append(batch, record)
+			if len(batch) >= logBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			if dropped := e.dropped.Swap(0); dropped > 0 {
+				lg.GetInstance().Warn("Dropped %d logs, the export queue is full", dropped)
+			}
+			flush()
+		case <-e.done:
+			for {
+				select {
+				case record := <-e.queue:
+					batch = append(batch, record)
+					if len(batch) >= logBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Shutdown exports the queued records and closes the connection
+func (e *LogExporter) Shutdown(ctx context.Context) error {
+	e.once.Do(func() { close(e.done) })
+	select {
+	case <-e.stopped:
+	case <-ctx.Done():
+	}
+	return e.conn.Close()
+}
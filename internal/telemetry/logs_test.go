@@ -0,0 +1,107 @@
+package telemetry
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	lg "nexus/internal/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/grpc"
+)
+
+// logCollector is an OTLP logs collector recording the exported records
+type logCollector struct {
+	collogspb.UnimplementedLogsServiceServer
+
+	mu       sync.Mutex
+	requests []*collogspb.ExportLogsServiceRequest
+}
+
+func (c *logCollector) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, req)
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+func (c *logCollector) records() []*logspb.LogRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var records []*logspb.LogRecord
+	for _, req := range c.requests {
+		records = append(records, req.ResourceLogs[0].ScopeLogs[0].LogRecords...)
+	}
+	return records
+}
+
+func startLogCollector(t *testing.T) (*logCollector, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	collector := &logCollector{}
+	server := grpc.NewServer()
+	collogspb.RegisterLogsServiceServer(server, collector)
+	go server.Serve(ln)
+	t.Cleanup(server.Stop)
+	return collector, ln.Addr().String()
+}
+
+func TestLogExporter(t *testing.T) {
+	collector, addr := startLogCollector(t)
+	exporter, err := NewLogExporter(addr, resource.NewSchemaless(attribute.String("service.name", "nexus-test")))
+	require.NoError(t, err)
+
+	// Records logged during a request carry its span
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	exporter.Emit(ctx, LogRecord{Severity: lg.LevelError, Body: "upstream failed", Attributes: []attribute.KeyValue{attribute.Int("attempt", 2)}})
+
+	hook := exporter.LogHook(lg.LevelInfo)
+	hook(lg.LevelDebug, "skipped", nil)
+	hook(lg.LevelWarn, "Configuration changed", []lg.Field{lg.F("route", "api")})
+
+	// Queued records are exported on shutdown
+	require.NoError(t, exporter.Shutdown(context.Background()))
+	records := collector.records()
+	require.Len(t, records, 2)
+	assert.Equal(t, "upstream failed", records[0].Body.GetStringValue())
+	assert.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, records[0].SeverityNumber)
+	assert.Equal(t, traceID[:], records[0].TraceId)
+	assert.Equal(t, spanID[:], records[0].SpanId)
+	assert.Equal(t, "attempt", records[0].Attributes[0].Key)
+	assert.Equal(t, int64(2), records[0].Attributes[0].Value.GetIntValue())
+	assert.Equal(t, "Configuration changed", records[1].Body.GetStringValue())
+	assert.Equal(t, "WARN", records[1].SeverityText)
+	assert.Equal(t, "route", records[1].Attributes[0].Key)
+	assert.Equal(t, "api", records[1].Attributes[0].Value.GetStringValue())
+	assert.Empty(t, records[1].TraceId)
+
+	collector.mu.Lock()
+	resource := collector.requests[0].ResourceLogs[0].Resource
+	collector.mu.Unlock()
+	assert.Equal(t, "nexus-test", resource.Attributes[0].Value.GetStringValue())
+}
+
+func TestLogExporter_BatchesRecords(t *testing.T) {
+	collector, addr := startLogCollector(t)
+	exporter, err := NewLogExporter(addr, resource.Empty())
+	require.NoError(t, err)
+	defer exporter.Shutdown(context.Background())
+
+	for i := 0; i < logBatchSize+1; i++ {
+		exporter.Emit(context.Background(), LogRecord{Body: "line"})
+	}
+	// The full batch is exported without waiting for the flush interval
+	require.Eventually(t, func() bool { return len(collector.records()) >= logBatchSize }, time.Second/2, 5*time.Millisecond)
+	require.Eventually(t, func() bool { return len(collector.records()) == logBatchSize+1 }, 2*time.Second, 5*time.Millisecond)
+}
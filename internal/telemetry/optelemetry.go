@@ -21,6 +21,7 @@ type Telemetry struct {
 	tracerProvider *sdktrace.TracerProvider
 	meterProvider  *sdkmetric.MeterProvider
 	meter          otelmetric.Meter
+	logExporter    *LogExporter
 }
 
 func NewTelemetry(ctx context.Context, cfg config.OpenTelemetryConfig) (*Telemetry, error) {
@@ -57,6 +58,14 @@ func NewTelemetry(ctx context.Context, cfg config.OpenTelemetryConfig) (*Telemet
 		return nil, err
 	}
 
+	// Initialize Log exporter
+	var logExporter *LogExporter
+	if cfg.Logs.Enabled {
+		if logExporter, err = NewLogExporter(cfg.Endpoint, res); err != nil {
+			return nil, err
+		}
+	}
+
 	// Create Trace Provider
 	tracerProvider := sdktrace.NewTracerProvider(
 		sdktrace.WithBatcher(traceExporter),
@@ -83,6 +92,7 @@ func NewTelemetry(ctx context.Context, cfg config.OpenTelemetryConfig) (*Telemet
 		tracerProvider: tracerProvider,
 		meterProvider:  meterProvider,
 		meter:          meterProvider.Meter(cfg.ServiceName),
+		logExporter:    logExporter,
 	}, nil
 }
 
@@ -113,6 +123,11 @@ func (t *Telemetry) Shutdown(ctx context.Context) error {
 	if err := t.meterProvider.Shutdown(ctx); err != nil {
 		errs = append(errs, err)
 	}
+	if t.logExporter != nil {
+		if err := t.logExporter.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
 	if len(errs) > 0 {
 		return fmt.Errorf("telemetry shutdown errors: %v", errs)
 	}
@@ -130,3 +145,9 @@ func (t *Telemetry) GetMeterProvider() *sdkmetric.MeterProvider {
 func (t *Telemetry) GetMeter() otelmetric.Meter {
 	return t.meter
 }
+
+// GetLogExporter returns the exporter of application and access logs, nil
+// when log export is disabled
+func (t *Telemetry) GetLogExporter() *LogExporter {
+	return t.logExporter
+}
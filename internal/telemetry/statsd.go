@@ -0,0 +1,180 @@
+package telemetry
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"nexus/internal/config"
+)
+
+const (
+	defaultStatsDAddress       = "127.0.0.1:8125"
+	defaultStatsDPrefix        = "nexus."
+	defaultStatsDFlushInterval = time.Second
+
+	// maxStatsDPacket keeps datagrams below the common network MTU
+	maxStatsDPacket = 1432
+)
+
+// StatsD sends counters and timers to a statsd server over UDP, with
+// DogStatsD tags when the datadog format is enabled. Metrics are buffered
+// and flushed periodically or when a packet is full
+type StatsD struct {
+	mu       sync.Mutex
+	conn     net.Conn
+	prefix   string
+	datadog  bool
+	tags     []string
+	buf      []byte
+	interval time.Duration
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewStatsD creates a statsd emitter and starts its flush loop, it returns
+// nil when statsd is disabled
+func NewStatsD(cfg config.StatsDConfig) (*StatsD, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	address := cfg.Address
+	if address == "" {
+		address = defaultStatsDAddress
+	}
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &StatsD{
+		conn:     conn,
+		prefix:   cfg.Prefix,
+		datadog:  cfg.Datadog,
+		tags:     sanitizeTags(cfg.Tags),
+		interval: cfg.FlushInterval,
+		done:     make(chan struct{}),
+	}
+	if s.prefix == "" {
+		s.prefix = defaultStatsDPrefix
+	}
+	if s.interval <= 0 {
+		s.interval = defaultStatsDFlushInterval
+	}
+
+	s.wg.Add(1)
+	go s.flushLoop()
+
+	return s, nil
+}
+
+// Count adds value to a counter, tags are "key:value" pairs
+func (s *StatsD) Count(name string, value int64, tags ...string) {
+	s.emit(name, strconv.FormatInt(value, 10), "c", tags)
+}
+
+// Timing records a duration in milliseconds
+func (s *StatsD) Timing(name string, d time.Duration, tags ...string) {
+	s.emit(name, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64), "ms", tags)
+}
+
+// Gauge sets a gauge to value
+func (s *StatsD) Gauge(name string, value float64, tags ...string) {
+	s.emit(name, strconv.FormatFloat(value, 'f', -1, 64), "g", tags)
+}
+
+func (s *StatsD) emit(name, value, kind string, tags []string) {
+	var line strings.Builder
+	line.WriteString(sanitizeName(s.prefix + name))
+	line.WriteByte(':')
+	line.WriteString(value)
+	line.WriteByte('|')
+	line.WriteString(kind)
+	if s.datadog && len(s.tags)+len(tags) > 0 {
+		line.WriteString("|#")
+		line.WriteString(strings.Join(append(append([]string{}, s.tags...), sanitizeTags(tags)...), ","))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.buf) > 0 && len(s.buf)+1+line.Len() > maxStatsDPacket {
+		s.flushLocked()
+	}
+	if len(s.buf) > 0 {
+		s.buf = append(s.buf, '\n')
+	}
+	s.buf = append(s.buf, line.String()...)
+}
+
+// Flush sends the buffered metrics
+func (s *StatsD) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.flushLocked()
+}
+
+func (s *StatsD) flushLocked() {
+	if len(s.buf) == 0 {
+		return
+	}
+	// Delivery is best effort, a missing statsd server must not affect traffic
+	s.conn.Write(s.buf)
+	s.buf = s.buf[:0]
+}
+
+func (s *StatsD) flushLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Flush()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Close flushes the remaining metrics and closes the connection
+func (s *StatsD) Close() error {
+	close(s.done)
+	s.wg.Wait()
+	s.Flush()
+
+	return s.conn.Close()
+}
+
+// sanitizeName replaces the characters that delimit the statsd line format
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', '#', ',', '\n', ' ':
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// sanitizeTags replaces the characters that delimit DogStatsD tags, the
+// key:value colon is kept
+func sanitizeTags(tags []string) []string {
+	sanitized := make([]string, len(tags))
+	for i, tag := range tags {
+		sanitized[i] = strings.Map(func(r rune) rune {
+			switch r {
+			case '|', ',', '#', '\n', ' ':
+				return '_'
+			}
+			return r
+		}, tag)
+	}
+	return sanitized
+}
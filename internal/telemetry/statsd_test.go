@@ -0,0 +1,106 @@
+package telemetry
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"nexus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listenStatsD returns a UDP listener standing in for the statsd server
+func listenStatsD(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readPacket(t *testing.T, conn *net.UDPConn) string {
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	return string(buf[:n])
+}
+
+func TestStatsD_Disabled(t *testing.T) {
+	statsd, err := NewStatsD(config.StatsDConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, statsd)
+}
+
+func TestStatsD_Format(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.StatsDConfig
+		expected []string
+	}{
+		{
+			name: "Plain",
+			cfg:  config.StatsDConfig{Prefix: "lb."},
+			expected: []string{
+				"lb.requests:1|c",
+				"lb.request.duration:12.5|ms",
+				"lb.backends_up:3|g",
+			},
+		},
+		{
+			name: "Datadog",
+			cfg:  config.StatsDConfig{Datadog: true, Tags: []string{"env:prod"}},
+			expected: []string{
+				"nexus.requests:1|c|#env:prod,service:api",
+				"nexus.request.duration:12.5|ms|#env:prod,service:api",
+				"nexus.backends_up:3|g|#env:prod,service:api",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := listenStatsD(t)
+			tt.cfg.Enabled = true
+			tt.cfg.Address = server.LocalAddr().String()
+			tt.cfg.FlushInterval = time.Hour
+
+			statsd, err := NewStatsD(tt.cfg)
+			require.NoError(t, err)
+			defer statsd.Close()
+
+			statsd.Count("requests", 1, "service:api")
+			statsd.Timing("request.duration", 12500*time.Microsecond, "service:api")
+			statsd.Gauge("backends_up", 3, "service:api")
+			statsd.Flush()
+
+			assert.Equal(t, tt.expected, strings.Split(readPacket(t, server), "\n"))
+		})
+	}
+}
+
+func TestStatsD_PacketSize(t *testing.T) {
+	server := listenStatsD(t)
+	statsd, err := NewStatsD(config.StatsDConfig{
+		Enabled:       true,
+		Address:       server.LocalAddr().String(),
+		FlushInterval: time.Hour,
+	})
+	require.NoError(t, err)
+	defer statsd.Close()
+
+	// A full buffer is sent before it grows past one packet
+	for i := 0; i < 200; i++ {
+		statsd.Count("requests", 1)
+	}
+	packet := readPacket(t, server)
+	assert.LessOrEqual(t, len(packet), maxStatsDPacket)
+	assert.True(t, strings.HasPrefix(packet, "nexus.requests:1|c\n"))
+}
+
+func TestStatsD_Sanitize(t *testing.T) {
+	assert.Equal(t, "nexus.bad_name_", sanitizeName("nexus.bad:name|"))
+	assert.Equal(t, []string{"route:a_b_c"}, sanitizeTags([]string{"route:a,b|c"}))
+}
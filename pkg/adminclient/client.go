@@ -0,0 +1,294 @@
+// Package adminclient is a client of the nexus admin REST API, for tools
+// managing nexus instances: listing and changing routes, draining backends,
+// purging the response cache and reloading the config
+package adminclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"nexus/internal/config"
+	"nexus/internal/snapshot"
+)
+
+// Route types are those of the config, so routes round-trip unchanged
+type (
+	Route      = config.RouteConfig
+	RouteMatch = config.RouteMatch
+	RouteSplit = config.RouteSplit
+)
+
+// Snapshot is a config file kept by the config snapshots
+type Snapshot = snapshot.Snapshot
+
+// Backend is a backend server as reported by the admin API
+type Backend struct {
+	Service     string   `json:"service"`
+	Address     string   `json:"address"`
+	Weight      int      `json:"weight"`
+	Healthy     *bool    `json:"healthy,omitempty"` // Nil without health checking
+	Drained     []string `json:"drained,omitempty"` // Drain reasons, e.g. manual or maintenance
+	Maintenance bool     `json:"maintenance"`
+	Version     string   `json:"version,omitempty"`
+}
+
+// Error is an answer of the admin API other than 2xx
+type Error struct {
+	StatusCode int
+	Message    string       // Error reported by nexus, the status text when it sent none
+	Routes     []RouteError // Invalid routes of a rejected import
+}
+
+// RouteError is the validation error of an imported route
+type RouteError struct {
+	Index int    `json:"index"` // Position of the route in the import
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// ImportOptions control how imported routes are applied
+type ImportOptions struct {
+	Merge  bool // Keep the routes not imported instead of removing them
+	DryRun bool // Report the changes without applying them
+}
+
+// ImportResult lists the route changes of an import by name
+type ImportResult struct {
+	Applied   bool     `json:"applied"` // False for dry-runs
+	Added     []string `json:"added"`
+	Updated   []string `json:"updated"`
+	Removed   []string `json:"removed"`
+	Unchanged int      `json:"unchanged"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("nexus admin API: %d %s", e.StatusCode, e.Message)
+}
+
+// Client calls the admin API of one nexus instance, it is safe for
+// concurrent use once configured
+type Client struct {
+	baseURL  string
+	client   *http.Client
+	token    string
+	username string
+	password string
+}
+
+// New creates a client of the admin API listening at baseURL, e.g.
+// http://127.0.0.1:9090
+func New(baseURL string) *Client {
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  http.DefaultClient,
+	}
+}
+
+// SetToken authenticates requests with a bearer token of admin.auth.tokens
+func (c *Client) SetToken(token string) {
+	c.token = token
+}
+
+// SetBasicAuth authenticates requests as a user of admin.auth.users
+func (c *Client) SetBasicAuth(username, password string) {
+	c.username, c.password = username, password
+}
+
+// SetHTTPClient sets the HTTP client of the requests, e.g. with the client
+// certificate of an mTLS admin listener
+func (c *Client) SetHTTPClient(client *http.Client) {
+	c.client = client
+}
+
+// Routes lists the routes of the instance in match order
+func (c *Client) Routes(ctx context.Context) ([]*Route, error) {
+	var routes []*Route
+	err := c.do(ctx, http.MethodGet, "/admin/routes", nil, &routes)
+	return routes, err
+}
+
+// AddRoute adds a route until the next config reload, its services must
+// exist. It returns the route as registered
+func (c *Client) AddRoute(ctx context.Context, route *Route) (*Route, error) {
+	var added Route
+	if err := c.do(ctx, http.MethodPost, "/admin/routes", route, &added); err != nil {
+		return nil, err
+	}
+	return &added, nil
+}
+
+// RemoveRoute removes a route until the next config reload
+func (c *Client) RemoveRoute(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/admin/routes/"+url.PathEscape(name), nil, nil)
+}
+
+// ExportRoutes returns the routes of the instance in match order
+func (c *Client) ExportRoutes(ctx context.Context) ([]*Route, error) {
+	var set struct {
+		Routes []*Route `json:"routes"`
+	}
+	err := c.do(ctx, http.MethodGet, "/admin/routes/export", nil, &set)
+	return set.Routes, err
+}
+
+// ImportRoutes replaces the routes of the instance until the next config
+// reload. All routes are validated first, an invalid one rejects the import
+// with an *Error listing every invalid route
+func (c *Client) ImportRoutes(ctx context.Context, routes []*Route, opts ImportOptions) (*ImportResult, error) {
+	query := url.Values{}
+	if opts.Merge {
+		query.Set("mode", "merge")
+	}
+	if opts.DryRun {
+		query.Set("dry_run", "true")
+	}
+	path := "/admin/routes/import"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	if routes == nil {
+		routes = []*Route{}
+	}
+
+	var result ImportResult
+	set := struct {
+		Routes []*Route `json:"routes"`
+	}{Routes: routes}
+	if err := c.do(ctx, http.MethodPost, path, set, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Backends lists the backends of all services with their state
+func (c *Client) Backends(ctx context.Context) ([]Backend, error) {
+	var backends []Backend
+	err := c.do(ctx, http.MethodGet, "/admin/backends", nil, &backends)
+	return backends, err
+}
+
+// Drain takes a backend of a service out of rotation, it returns the
+// reasons the backend is drained for
+func (c *Client) Drain(ctx context.Context, service, address string) ([]string, error) {
+	return c.drain(ctx, "/admin/backends/drain", service, address)
+}
+
+// Undrain returns a backend drained with Drain to rotation, it returns the
+// reasons the backend is still drained for
+func (c *Client) Undrain(ctx context.Context, service, address string) ([]string, error) {
+	return c.drain(ctx, "/admin/backends/undrain", service, address)
+}
+
+func (c *Client) drain(ctx context.Context, path, service, address string) ([]string, error) {
+	req := map[string]string{"service": service, "address": address}
+	var resp struct {
+		Drained []string `json:"drained"`
+	}
+	err := c.do(ctx, http.MethodPost, path, req, &resp)
+	return resp.Drained, err
+}
+
+// PurgeURL removes the cached responses of a URL, a path matches every host.
+// It returns the number of responses removed
+func (c *Client) PurgeURL(ctx context.Context, rawURL string) (int, error) {
+	return c.purge(ctx, map[string]string{"url": rawURL})
+}
+
+// PurgePrefix removes the cached responses of the URLs starting with prefix
+func (c *Client) PurgePrefix(ctx context.Context, prefix string) (int, error) {
+	return c.purge(ctx, map[string]string{"prefix": prefix})
+}
+
+// PurgeTag removes the cached responses tagged with tag
+func (c *Client) PurgeTag(ctx context.Context, tag string) (int, error) {
+	return c.purge(ctx, map[string]string{"tag": tag})
+}
+
+func (c *Client) purge(ctx context.Context, req map[string]string) (int, error) {
+	var resp struct {
+		Purged int `json:"purged"`
+	}
+	err := c.do(ctx, http.MethodPost, "/admin/cache/purge", req, &resp)
+	return resp.Purged, err
+}
+
+// Reload applies the config file of an instance run with -runtime=service
+func (c *Client) Reload(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/admin/config/reload", nil, nil)
+}
+
+// Snapshots lists the config snapshots of the instance, latest first
+func (c *Client) Snapshots(ctx context.Context) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	err := c.do(ctx, http.MethodGet, "/admin/config/snapshots", nil, &snapshots)
+	return snapshots, err
+}
+
+// Rollback restores and applies a config snapshot by name or unique prefix,
+// it returns the name of the snapshot restored
+func (c *Client) Rollback(ctx context.Context, to string) (string, error) {
+	var resp struct {
+		Restored string `json:"restored"`
+	}
+	err := c.do(ctx, http.MethodPost, "/admin/config/rollback", map[string]string{"to": to}, &resp)
+	return resp.Restored, err
+}
+
+// do sends a request with body encoded as JSON, and decodes the response
+// into out unless it is nil
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	switch {
+	case c.token != "":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	case c.username != "":
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+		var errBody struct {
+			Error  string       `json:"error"`
+			Routes []RouteError `json:"routes"`
+		}
+		if json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&errBody) == nil && errBody.Error != "" {
+			apiErr.Message, apiErr.Routes = errBody.Error, errBody.Routes
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response of %s %s: %w", method, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package adminclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nexus/api"
+	"nexus/internal/cache"
+	"nexus/internal/config"
+	"nexus/internal/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	router := route.NewRouter(nil, map[string]*config.ServiceConfig{
+		"api": {
+			Name:         "api",
+			BalancerType: "round_robin",
+			Servers:      []config.ServerConfig{{Address: "http://backend1"}, {Address: "http://backend2"}},
+		},
+	})
+	admin := api.NewAdmin(router)
+	admin.SetCache(cache.New(cache.DefaultMaxSize))
+	admin.SetAuth(&config.AdminAuthConfig{
+		Tokens: []config.AdminTokenConfig{{Name: "automation", Token: "secret", Role: config.AdminRoleWrite}},
+	})
+
+	server := httptest.NewServer(admin)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient(t *testing.T) {
+	server := newTestServer(t)
+	client := New(server.URL + "/")
+	client.SetToken("secret")
+	ctx := context.Background()
+
+	route, err := client.AddRoute(ctx, &Route{
+		Name:    "users",
+		Match:   RouteMatch{Path: "/users/*"},
+		Service: "api",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "users", route.Name)
+
+	routes, err := client.Routes(ctx)
+	require.NoError(t, err)
+	require.Len(t, routes, 1)
+	assert.Equal(t, "/users/*", routes[0].Match.Path)
+
+	drained, err := client.Drain(ctx, "api", "http://backend1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{api.ManualDrainReason}, drained)
+
+	backends, err := client.Backends(ctx)
+	require.NoError(t, err)
+	require.Len(t, backends, 2)
+	assert.Equal(t, []string{api.ManualDrainReason}, backends[0].Drained)
+	assert.Empty(t, backends[1].Drained)
+
+	drained, err = client.Undrain(ctx, "api", "http://backend1")
+	require.NoError(t, err)
+	assert.Empty(t, drained)
+
+	purged, err := client.PurgePrefix(ctx, "/users/")
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+
+	require.NoError(t, client.RemoveRoute(ctx, "users"))
+	routes, err = client.Routes(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, routes)
+}
+
+func TestClient_ImportRoutes(t *testing.T) {
+	server := newTestServer(t)
+	client := New(server.URL)
+	client.SetToken("secret")
+	ctx := context.Background()
+
+	routes := []*Route{
+		{Name: "users", Match: RouteMatch{Path: "/users/*"}, Service: "api"},
+		{Name: "orders", Match: RouteMatch{Path: "/orders/*"}, Service: "api"},
+	}
+	result, err := client.ImportRoutes(ctx, routes, ImportOptions{DryRun: true})
+	require.NoError(t, err)
+	assert.False(t, result.Applied)
+	assert.Equal(t, []string{"users", "orders"}, result.Added)
+
+	result, err = client.ImportRoutes(ctx, routes, ImportOptions{})
+	require.NoError(t, err)
+	assert.True(t, result.Applied)
+
+	exported, err := client.ExportRoutes(ctx)
+	require.NoError(t, err)
+	require.Len(t, exported, 2)
+	assert.Equal(t, "orders", exported[1].Name)
+
+	_, err = client.ImportRoutes(ctx, []*Route{{Name: "orphan", Match: RouteMatch{Path: "/"}, Service: "missing"}}, ImportOptions{Merge: true})
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, []RouteError{{Index: 0, Name: "orphan", Error: "route orphan: service missing not found"}}, apiErr.Routes)
+
+	result, err = client.ImportRoutes(ctx, nil, ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"users", "orders"}, result.Removed)
+}
+
+func TestClient_Errors(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+
+	var apiErr *Error
+	_, err := New(server.URL).Routes(ctx)
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	assert.Equal(t, "authentication required", apiErr.Message)
+
+	client := New(server.URL)
+	client.SetToken("secret")
+	err = client.RemoveRoute(ctx, "unknown")
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+
+	// Lifecycle control needs -runtime=service
+	err = client.Reload(ctx)
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Contains(t, apiErr.Message, "-runtime=service")
+}
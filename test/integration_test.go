@@ -89,11 +89,13 @@ func TestIntegration(t *testing.T) {
 				{Address: backend1URL, Weight: 1},
 				{Address: backend2URL, Weight: 1},
 			},
+			// Each request ends before the next one, so the first server
+			// stays the least loaded
 			expectedOrder: []string{
 				"Response from backend 1",
-				"Response from backend 2",
 				"Response from backend 1",
-				"Response from backend 2",
+				"Response from backend 1",
+				"Response from backend 1",
 			},
 		},
 	}